@@ -0,0 +1,2102 @@
+// Code generated by cmd/genclient. DO NOT EDIT.
+
+// Package client is a typed client for the Greenlight v1 API. Regenerate it with
+// "make client/generate" after changing the API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client sends requests to the API. Token is sent as a bearer token when it is set.
+// The consistency token of the last write is sent back with the following requests, so
+// that they see the write even when the API reads from a replica.
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+
+	mutex       sync.Mutex
+	consistency string
+}
+
+// New returns a client for the API at the base URL, such as "https://greenlight.example.com"
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Error is returned for responses with a 4xx or 5xx status code. Details holds the
+// "error" value of the response, which for failed validations maps each invalid
+// field to its error message. Code is the stable error code, such as "movie_not_found",
+// which is listed by the ErrorCodes endpoint. For failed validations, Fields holds the
+// code and parameters of each invalid field's error.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    json.RawMessage
+	Fields     map[string]FieldError
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("greenlight: %d: %s", e.StatusCode, e.Message)
+	}
+
+	return fmt.Sprintf("greenlight: %d: %s", e.StatusCode, e.Details)
+}
+
+// Send a request and decode the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader, out interface{}) error {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	c.mutex.Lock()
+	if c.consistency != "" {
+		req.Header.Set("X-Consistency-Token", c.consistency)
+	}
+	c.mutex.Unlock()
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if token := res.Header.Get("X-Consistency-Token"); token != "" {
+		c.mutex.Lock()
+		c.consistency = token
+		c.mutex.Unlock()
+	}
+
+	if res.StatusCode >= 400 {
+		return readError(res)
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// Read the error from a failed response. The error is either a message or an object,
+// which has a message unless it holds the errors of a failed validation.
+func readError(res *http.Response) error {
+	apiErr := &Error{StatusCode: res.StatusCode}
+
+	var env struct {
+		Code   string                `json:"code"`
+		Error  json.RawMessage       `json:"error"`
+		Fields map[string]FieldError `json:"fields"`
+	}
+
+	err := json.NewDecoder(res.Body).Decode(&env)
+	if err != nil {
+		apiErr.Message = http.StatusText(res.StatusCode)
+		return apiErr
+	}
+
+	apiErr.Code = env.Code
+	apiErr.Details = env.Error
+	apiErr.Fields = env.Fields
+
+	var message string
+	if json.Unmarshal(env.Error, &message) == nil {
+		apiErr.Message = message
+		return apiErr
+	}
+
+	var object struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(env.Error, &object) == nil {
+		apiErr.Message = object.Message
+	}
+
+	return apiErr
+}
+
+// Send a request with an optional JSON body
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	if in == nil {
+		return c.do(ctx, method, path, query, "", nil, out)
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, method, path, query, "application/json", bytes.NewReader(body), out)
+}
+
+// Send a request with the items as newline-delimited JSON
+func doNDJSON[T any](ctx context.Context, c *Client, method, path string, items []T, out interface{}) error {
+	var body bytes.Buffer
+
+	encoder := json.NewEncoder(&body)
+	for _, item := range items {
+		err := encoder.Encode(item)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.do(ctx, method, path, nil, "application/x-ndjson", &body, out)
+}
+
+// Metadata: Pagination metadata of a list
+type Metadata struct {
+	CurrentPage  int          `json:"current_page,omitempty"`
+	PageSize     int          `json:"page_size,omitempty"`
+	FirstPage    int          `json:"first_page,omitempty"`
+	LastPage     int          `json:"last_page,omitempty"`
+	TotalRecords int          `json:"total_records,omitempty"`
+	NextCursor   string       `json:"next_cursor,omitempty"`
+	Facets       *MovieFacets `json:"facets,omitempty"`
+}
+
+// MovieFacets: Number of movies matching a search by genre, year, decade (such as "1990s"), runtime range (such as "90-119") and certification (such as "US:PG-13")
+type MovieFacets struct {
+	Genres         map[string]int `json:"genres"`
+	Years          map[string]int `json:"years"`
+	Decades        map[string]int `json:"decades"`
+	Runtimes       map[string]int `json:"runtimes"`
+	Certifications map[string]int `json:"certifications"`
+}
+
+// MovieSuggestion: A movie title suggested as the user types
+type MovieSuggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year,omitempty"`
+}
+
+// Movie: A movie. Runtimes are strings such as "102 mins".
+type Movie struct {
+	ID                  int64             `json:"id"`
+	Title               string            `json:"title"`
+	Year                int32             `json:"year,omitempty"`
+	Runtime             string            `json:"runtime,omitempty"`
+	Genres              []string          `json:"genres,omitempty"`
+	Synopsis            string            `json:"synopsis,omitempty"`
+	OriginalLanguage    string            `json:"original_language,omitempty"`
+	ProductionCountries []string          `json:"production_countries,omitempty"`
+	Certifications      map[string]string `json:"certifications,omitempty"`
+	Metadata            map[string]string `json:"metadata,omitempty"`
+	CreatedBy           int64             `json:"created_by,omitempty"`
+	Status              string            `json:"status"`
+	PublishAt           *time.Time        `json:"publish_at,omitempty"`
+	Version             int32             `json:"version"`
+	Views               int64             `json:"views"`
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+	Collection          *Collection       `json:"collection,omitempty"`
+}
+
+// MovieInput: The fields of a new movie
+type MovieInput struct {
+	Title               string            `json:"title"`
+	Year                int32             `json:"year"`
+	Runtime             string            `json:"runtime"`
+	Genres              []string          `json:"genres"`
+	Synopsis            string            `json:"synopsis,omitempty"`
+	OriginalLanguage    string            `json:"original_language,omitempty"`
+	ProductionCountries []string          `json:"production_countries,omitempty"`
+	Certifications      map[string]string `json:"certifications,omitempty"`
+	Metadata            map[string]string `json:"metadata,omitempty"`
+	Status              string            `json:"status,omitempty"`
+	PublishAt           *time.Time        `json:"publish_at,omitempty"`
+}
+
+// MovieUpdate: The fields to change on a movie. Nil fields are left as they are, and metadata keys set to nil are removed.
+type MovieUpdate struct {
+	Title               *string            `json:"title,omitempty"`
+	Year                *int32             `json:"year,omitempty"`
+	Runtime             *string            `json:"runtime,omitempty"`
+	Genres              []string           `json:"genres,omitempty"`
+	Synopsis            *string            `json:"synopsis,omitempty"`
+	OriginalLanguage    *string            `json:"original_language,omitempty"`
+	ProductionCountries []string           `json:"production_countries,omitempty"`
+	Certifications      map[string]string  `json:"certifications,omitempty"`
+	Metadata            map[string]*string `json:"metadata,omitempty"`
+	Status              *string            `json:"status,omitempty"`
+	PublishAt           *time.Time         `json:"publish_at,omitempty"`
+}
+
+// MovieChange: A change to a movie proposed by a contributor, applied once an editor approves it
+type MovieChange struct {
+	ID           int64       `json:"id"`
+	CreatedAt    time.Time   `json:"created_at"`
+	MovieID      int64       `json:"movie_id"`
+	UserID       int64       `json:"user_id"`
+	BaseVersion  int32       `json:"base_version"`
+	Changes      MovieUpdate `json:"changes"`
+	Status       string      `json:"status"`
+	ReviewReason string      `json:"review_reason,omitempty"`
+	ReviewedAt   *time.Time  `json:"reviewed_at,omitempty"`
+	Version      int32       `json:"version"`
+}
+
+// Release: A release of a movie in a country
+type Release struct {
+	ID          int64  `json:"id"`
+	MovieID     int64  `json:"movie_id"`
+	Country     string `json:"country"`
+	Type        string `json:"type"`
+	ReleaseDate string `json:"release_date"`
+	Note        string `json:"note,omitempty"`
+	Version     int32  `json:"version"`
+}
+
+// ReleaseInput: The fields of a new release. Dates are in the format "2006-01-02".
+type ReleaseInput struct {
+	Country     string `json:"country"`
+	Type        string `json:"type"`
+	ReleaseDate string `json:"release_date"`
+	Note        string `json:"note,omitempty"`
+}
+
+// ReleaseUpdate: The fields to change on a release. Nil fields are left as they are.
+type ReleaseUpdate struct {
+	Country     *string `json:"country,omitempty"`
+	Type        *string `json:"type,omitempty"`
+	ReleaseDate *string `json:"release_date,omitempty"`
+	Note        *string `json:"note,omitempty"`
+}
+
+// Collection: A collection of related movies
+type Collection struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	MovieIDs    []int64   `json:"movie_ids"`
+	Version     int32     `json:"version"`
+}
+
+// CollectionInput: The fields of a new collection
+type CollectionInput struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	MovieIDs    []int64 `json:"movie_ids"`
+}
+
+// CollectionUpdate: The fields to change on a collection. Nil fields are left as they are.
+type CollectionUpdate struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	MovieIDs    []int64 `json:"movie_ids,omitempty"`
+}
+
+// Review: A user's review of a movie
+type Review struct {
+	ID               int64      `json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	MovieID          int64      `json:"movie_id"`
+	UserID           int64      `json:"user_id"`
+	UserName         string     `json:"user_name"`
+	Rating           int16      `json:"rating"`
+	Body             string     `json:"body"`
+	Status           string     `json:"status"`
+	ModerationReason string     `json:"moderation_reason,omitempty"`
+	ModeratedAt      *time.Time `json:"moderated_at,omitempty"`
+	Version          int32      `json:"version"`
+}
+
+// ReviewInput: The fields of a new review
+type ReviewInput struct {
+	Rating int16  `json:"rating"`
+	Body   string `json:"body"`
+}
+
+// RejectionInput: The optional reason for rejecting a review or a proposed change
+type RejectionInput struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// Report: A user's report of an abusive review
+type Report struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReviewID   int64      `json:"review_id"`
+	UserID     int64      `json:"user_id"`
+	Reason     string     `json:"reason"`
+	Comment    string     `json:"comment,omitempty"`
+	Status     string     `json:"status"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Version    int32      `json:"version"`
+}
+
+// ReportInput: The fields of a new report
+type ReportInput struct {
+	Reason  string `json:"reason"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// ResolutionInput: How a report is resolved, either upheld or dismissed
+type ResolutionInput struct {
+	Status string `json:"status"`
+}
+
+// User: A registered user
+type User struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Activated bool      `json:"activated"`
+}
+
+// EmailStatus: The delivery status of a user's email address, as reported by the email provider: ok, soft_bounce, bounced or complained
+type EmailStatus struct {
+	Status    string     `json:"status"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// RegistrationInput: The details of a new user, with the invitation code they were sent when registration is closed
+type RegistrationInput struct {
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	Invitation string `json:"invitation,omitempty"`
+}
+
+// Invitation: An invitation for someone to register
+type Invitation struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Email      string     `json:"email"`
+	InvitedBy  int64      `json:"invited_by"`
+	Expiry     time.Time  `json:"expiry"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	Status     string     `json:"status"`
+}
+
+// ActivationInput: The token sent to a new user
+type ActivationInput struct {
+	Token string `json:"token"`
+}
+
+// PasswordResetInput: A new password and the password reset token
+type PasswordResetInput struct {
+	Password string `json:"password"`
+	Token    string `json:"token"`
+}
+
+// EmailInput: The email address to send a token to
+type EmailInput struct {
+	Email string `json:"email"`
+}
+
+// CredentialsInput: A user's email address and password, with the permissions to restrict the token to and its expiry
+type CredentialsInput struct {
+	Email    string     `json:"email"`
+	Password string     `json:"password"`
+	Scopes   []string   `json:"scopes,omitempty"`
+	Expiry   *time.Time `json:"expiry,omitempty"`
+}
+
+// Token: An authentication token
+type Token struct {
+	Token  string    `json:"token"`
+	Expiry time.Time `json:"expiry"`
+	Scopes []string  `json:"scopes,omitempty"`
+}
+
+// Notification: A notification sent to the user
+type Notification struct {
+	ID        int64                  `json:"id"`
+	CreatedAt time.Time              `json:"created_at"`
+	Kind      string                 `json:"kind"`
+	Title     string                 `json:"title"`
+	Body      string                 `json:"body"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	ReadAt    *time.Time             `json:"read_at"`
+}
+
+// ChannelPreferences: The channels a kind of notification is sent on
+type ChannelPreferences struct {
+	Email bool `json:"email"`
+	InApp bool `json:"in_app"`
+}
+
+// SavedSearch: A movie search the user is alerted about
+type SavedSearch struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Title     string    `json:"title"`
+	Genres    []string  `json:"genres"`
+}
+
+// SavedSearchInput: The fields of a new saved search
+type SavedSearchInput struct {
+	Name   string   `json:"name"`
+	Title  string   `json:"title"`
+	Genres []string `json:"genres"`
+}
+
+// DigestSubscription: The user's subscription to the digest emails of new movies in their favorite genres
+type DigestSubscription struct {
+	CreatedAt    time.Time `json:"created_at"`
+	Frequency    string    `json:"frequency"`
+	Genres       []string  `json:"genres"`
+	NextDigestAt time.Time `json:"next_digest_at"`
+}
+
+// DigestSubscriptionInput: How often the user wants a digest (daily or weekly) and their favorite genres
+type DigestSubscriptionInput struct {
+	Frequency string   `json:"frequency"`
+	Genres    []string `json:"genres"`
+}
+
+// TOSAcceptance: The user's acceptance of a version of the terms of service
+type TOSAcceptance struct {
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"accepted_at"`
+}
+
+// TOSAcceptanceInput: The version of the terms of service the user accepts
+type TOSAcceptanceInput struct {
+	Version string `json:"version"`
+}
+
+// ServiceAccount: A non-human client acting for the user with some of their permissions
+type ServiceAccount struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	OwnerID     int64     `json:"owner_id"`
+	Name        string    `json:"name"`
+	KeyPrefix   string    `json:"key_prefix"`
+	Permissions []string  `json:"permissions"`
+	Active      bool      `json:"active"`
+	Version     int       `json:"version"`
+}
+
+// ServiceAccountInput: The fields of a new service account
+type ServiceAccountInput struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// ServiceAccountUpdate: The fields to change on a service account. Nil fields are left as they are.
+type ServiceAccountUpdate struct {
+	Name        *string  `json:"name,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// ImportFailure: A line of a bulk import which couldn't be imported
+type ImportFailure struct {
+	Line   int                   `json:"line"`
+	Code   string                `json:"code"`
+	Error  interface{}           `json:"error"`
+	Fields map[string]FieldError `json:"fields,omitempty"`
+}
+
+// FieldError: The code, message and parameters of a field which failed validation
+type FieldError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type MovieResponse struct {
+	Movie Movie `json:"movie"`
+}
+
+type MovieListResponse struct {
+	Movies   []Movie  `json:"movies"`
+	Metadata Metadata `json:"metadata,omitempty"`
+}
+
+type SuggestionListResponse struct {
+	Suggestions []MovieSuggestion `json:"suggestions"`
+}
+
+type ReleaseResponse struct {
+	Release Release `json:"release"`
+}
+
+type ReleaseListResponse struct {
+	Releases []Release `json:"releases"`
+}
+
+type CollectionResponse struct {
+	Collection Collection `json:"collection"`
+	Movies     []Movie    `json:"movies,omitempty"`
+}
+
+type CollectionListResponse struct {
+	Collections []Collection `json:"collections"`
+	Metadata    Metadata     `json:"metadata"`
+}
+
+type MovieChangeResponse struct {
+	Change MovieChange `json:"change"`
+}
+
+type MovieChangeApprovalResponse struct {
+	Change MovieChange `json:"change"`
+	Movie  Movie       `json:"movie"`
+}
+
+type MovieChangeListResponse struct {
+	Changes  []MovieChange `json:"changes"`
+	Metadata Metadata      `json:"metadata"`
+}
+
+type ReviewResponse struct {
+	Review Review `json:"review"`
+}
+
+type ReviewListResponse struct {
+	Reviews  []Review `json:"reviews"`
+	Metadata Metadata `json:"metadata"`
+}
+
+type ReportResponse struct {
+	Report Report `json:"report"`
+}
+
+type ReportListResponse struct {
+	Reports  []Report `json:"reports"`
+	Metadata Metadata `json:"metadata"`
+}
+
+type InvitationResponse struct {
+	Invitation Invitation `json:"invitation"`
+}
+
+type InvitationListResponse struct {
+	Invitations []Invitation `json:"invitations"`
+	Metadata    Metadata     `json:"metadata"`
+}
+
+type UserResponse struct {
+	User User `json:"user"`
+}
+
+type AdminUserResponse struct {
+	User        User        `json:"user"`
+	EmailStatus EmailStatus `json:"email_status"`
+}
+
+type TokenResponse struct {
+	AuthenticationToken Token `json:"authentication_token"`
+}
+
+type GuestTokenResponse struct {
+	GuestToken Token `json:"guest_token"`
+}
+
+type ImpersonationTokenResponse struct {
+	ImpersonationToken Token `json:"impersonation_token"`
+	User               User  `json:"user"`
+}
+
+type NotificationListResponse struct {
+	Notifications []Notification `json:"notifications"`
+	Metadata      Metadata       `json:"metadata"`
+}
+
+type PreferencesResponse struct {
+	Preferences map[string]ChannelPreferences `json:"preferences"`
+}
+
+type SavedSearchResponse struct {
+	SavedSearch SavedSearch `json:"saved_search"`
+}
+
+type DigestSubscriptionResponse struct {
+	DigestSubscription DigestSubscription `json:"digest_subscription"`
+}
+
+type TOSAcceptanceResponse struct {
+	TOSAcceptance TOSAcceptance `json:"tos_acceptance"`
+}
+
+type SavedSearchListResponse struct {
+	SavedSearches []SavedSearch `json:"saved_searches"`
+}
+
+type ServiceAccountResponse struct {
+	ServiceAccount ServiceAccount `json:"service_account"`
+}
+
+type ServiceAccountKeyResponse struct {
+	ServiceAccount ServiceAccount `json:"service_account"`
+	Key            string         `json:"key"`
+}
+
+type ServiceAccountListResponse struct {
+	ServiceAccounts []ServiceAccount `json:"service_accounts"`
+}
+
+type ImportResponse struct {
+	Imported int             `json:"imported"`
+	Failed   int             `json:"failed"`
+	Failures []ImportFailure `json:"failures"`
+}
+
+type HealthcheckResponse struct {
+	Status     string            `json:"status"`
+	SystemInfo map[string]string `json:"system_info"`
+}
+
+type MetaResponse struct {
+	Build    map[string]string `json:"build"`
+	Features map[string]bool   `json:"features"`
+}
+
+// Route: A route served by the API, with the permission it requires
+type Route struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Handler    string `json:"handler"`
+	Permission string `json:"permission,omitempty"`
+	RateLimit  string `json:"rate_limit"`
+}
+
+type RouteListResponse struct {
+	Routes []Route `json:"routes"`
+}
+
+type ChangelogResponse struct {
+	Changelog []map[string]string `json:"changelog"`
+}
+
+// ErrorCode: An error code sent with error responses
+type ErrorCode struct {
+	Code        string `json:"code"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+type ErrorCodesResponse struct {
+	ErrorCodes []ErrorCode `json:"error_codes"`
+}
+
+type MovieListingResponse struct {
+	Listing  json.RawMessage `json:"listing"`
+	PageSize map[string]int  `json:"page_size"`
+	MaxPage  int             `json:"max_page"`
+}
+
+type AvailabilityResponse struct {
+	Availability json.RawMessage `json:"availability"`
+}
+
+type AdminStatsResponse struct {
+	GeneratedAt  time.Time                  `json:"generated_at"`
+	Users        json.RawMessage            `json:"users"`
+	Movies       json.RawMessage            `json:"movies"`
+	Reviews      json.RawMessage            `json:"reviews"`
+	Requests     map[string]json.RawMessage `json:"requests"`
+	MailerQueue  json.RawMessage            `json:"mailer_queue,omitempty"`
+	EventsOutbox json.RawMessage            `json:"events_outbox,omitempty"`
+}
+
+// MetricSnapshot: The metrics of an instance at a point in time
+type MetricSnapshot struct {
+	ID        int64                      `json:"id"`
+	CreatedAt time.Time                  `json:"created_at"`
+	Label     string                     `json:"label,omitempty"`
+	Instance  string                     `json:"instance"`
+	Metrics   map[string]json.RawMessage `json:"metrics"`
+}
+
+// MetricSnapshotInput: The optional label of a snapshot, and whether to reset the counters once it is stored
+type MetricSnapshotInput struct {
+	Label string `json:"label,omitempty"`
+	Reset bool   `json:"reset,omitempty"`
+}
+
+type MetricsResponse struct {
+	Metrics map[string]json.RawMessage `json:"metrics"`
+	Rates   map[string]float64         `json:"rates"`
+}
+
+type MetricSnapshotResponse struct {
+	Snapshot MetricSnapshot `json:"snapshot"`
+}
+
+type MetricSnapshotListResponse struct {
+	Snapshots []MetricSnapshot `json:"snapshots"`
+	Metadata  Metadata         `json:"metadata"`
+}
+
+type BanListResponse struct {
+	Bans json.RawMessage `json:"bans"`
+}
+
+// RateLimitClient: A client tracked by the rate limiter of an API instance
+type RateLimitClient struct {
+	Key             string    `json:"key"`
+	TokensRemaining float64   `json:"tokens_remaining"`
+	LastSeen        time.Time `json:"last_seen"`
+}
+
+type RateLimitListResponse struct {
+	RateLimits []RateLimitClient `json:"rate_limits"`
+}
+
+// Backup: A dump of the database kept in the backup storage
+type Backup struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Size       int64      `json:"size"`
+	Error      string     `json:"error,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type BackupListResponse struct {
+	Backups  []Backup `json:"backups"`
+	Metadata Metadata `json:"metadata"`
+}
+
+type OperationInput struct {
+	Name string `json:"name"`
+}
+
+// Operation: A run of an online schema change, such as an index build or a batched backfill
+type Operation struct {
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Progress    int64      `json:"progress"`
+	Total       int64      `json:"total"`
+	RowsUpdated int64      `json:"rows_updated"`
+	Error       string     `json:"error,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+type OperationResponse struct {
+	Operation Operation `json:"operation"`
+}
+
+type OperationListResponse struct {
+	Operations []Operation `json:"operations"`
+	Metadata   Metadata    `json:"metadata"`
+}
+
+// Healthcheck: Report the status of the API.
+//
+//	GET /v1/healthcheck
+func (c *Client) Healthcheck(ctx context.Context) (*HealthcheckResponse, error) {
+	var out HealthcheckResponse
+
+	path := "/v1/healthcheck"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// Meta: Describe the running build and its enabled features.
+//
+//	GET /v1/meta
+func (c *Client) Meta(ctx context.Context) (*MetaResponse, error) {
+	var out MetaResponse
+
+	path := "/v1/meta"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListRoutes: List the routes served by the API, with the permission each requires.
+//
+//	GET /v1/meta/routes
+func (c *Client) ListRoutes(ctx context.Context) (*RouteListResponse, error) {
+	var out RouteListResponse
+
+	path := "/v1/meta/routes"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// Changelog: List the changes made to the API.
+//
+//	GET /v1/changelog
+func (c *Client) Changelog(ctx context.Context) (*ChangelogResponse, error) {
+	var out ChangelogResponse
+
+	path := "/v1/changelog"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ErrorCodes: List the error codes sent with error responses.
+//
+//	GET /v1/error-codes
+func (c *Client) ErrorCodes(ctx context.Context) (*ErrorCodesResponse, error) {
+	var out ErrorCodesResponse
+
+	path := "/v1/error-codes"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListMovies: List the movies matching the query string filters.
+//
+//	GET /v1/movies
+func (c *Client) ListMovies(ctx context.Context, query url.Values) (*MovieListResponse, error) {
+	var out MovieListResponse
+
+	path := "/v1/movies"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// MovieListing: Describe how movies can be sorted, filtered and paginated.
+//
+//	GET /v1/movies/_meta
+func (c *Client) MovieListing(ctx context.Context) (*MovieListingResponse, error) {
+	var out MovieListingResponse
+
+	path := "/v1/movies/_meta"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// TrendingMovies: List the most viewed recent movies.
+//
+//	GET /v1/movies/trending
+func (c *Client) TrendingMovies(ctx context.Context, query url.Values) (*MovieListResponse, error) {
+	var out MovieListResponse
+
+	path := "/v1/movies/trending"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// RecentMovies: List the newest movies.
+//
+//	GET /v1/movies/recent
+func (c *Client) RecentMovies(ctx context.Context, query url.Values) (*MovieListResponse, error) {
+	var out MovieListResponse
+
+	path := "/v1/movies/recent"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// AutocompleteMovies: Suggest movie titles matching the q parameter as the user types.
+//
+//	GET /v1/movies/autocomplete
+func (c *Client) AutocompleteMovies(ctx context.Context, query url.Values) (*SuggestionListResponse, error) {
+	var out SuggestionListResponse
+
+	path := "/v1/movies/autocomplete"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// GetMovie: Fetch a movie.
+//
+//	GET /v1/movies/:id
+func (c *Client) GetMovie(ctx context.Context, id int64, query url.Values) (*MovieResponse, error) {
+	var out MovieResponse
+
+	path := fmt.Sprintf("/v1/movies/%d", id)
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateMovie: Create a movie.
+//
+//	POST /v1/movies
+func (c *Client) CreateMovie(ctx context.Context, input *MovieInput) (*MovieResponse, error) {
+	var out MovieResponse
+
+	path := "/v1/movies"
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ImportMovies: Create movies in bulk. Movies which fail validation are reported in the response rather than failing the import.
+//
+//	POST /v1/movies
+func (c *Client) ImportMovies(ctx context.Context, input []MovieInput) (*ImportResponse, error) {
+	var out ImportResponse
+
+	path := "/v1/movies"
+
+	err := doNDJSON(ctx, c, "POST", path, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// UpdateMovie: Change some of a movie's fields.
+//
+//	PATCH /v1/movies/:id
+func (c *Client) UpdateMovie(ctx context.Context, id int64, input *MovieUpdate) (*MovieResponse, error) {
+	var out MovieResponse
+
+	path := fmt.Sprintf("/v1/movies/%d", id)
+
+	err := c.doJSON(ctx, "PATCH", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// DeleteMovie: Delete a movie.
+//
+//	DELETE /v1/movies/:id
+func (c *Client) DeleteMovie(ctx context.Context, id int64) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := fmt.Sprintf("/v1/movies/%d", id)
+
+	err := c.doJSON(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ShowMovieAvailability: List where a movie can be streamed, when the API has a watch-provider configured.
+//
+//	GET /v1/movies/:id/availability
+func (c *Client) ShowMovieAvailability(ctx context.Context, id int64, query url.Values) (*AvailabilityResponse, error) {
+	var out AvailabilityResponse
+
+	path := fmt.Sprintf("/v1/movies/%d/availability", id)
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListMovieReleases: List a movie's releases.
+//
+//	GET /v1/movies/:id/releases
+func (c *Client) ListMovieReleases(ctx context.Context, id int64) (*ReleaseListResponse, error) {
+	var out ReleaseListResponse
+
+	path := fmt.Sprintf("/v1/movies/%d/releases", id)
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateMovieRelease: Add a release to a movie.
+//
+//	POST /v1/movies/:id/releases
+func (c *Client) CreateMovieRelease(ctx context.Context, id int64, input *ReleaseInput) (*ReleaseResponse, error) {
+	var out ReleaseResponse
+
+	path := fmt.Sprintf("/v1/movies/%d/releases", id)
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// UpdateMovieRelease: Change some of a release's fields.
+//
+//	PATCH /v1/movies/:id/releases/:release_id
+func (c *Client) UpdateMovieRelease(ctx context.Context, id int64, releaseID int64, input *ReleaseUpdate) (*ReleaseResponse, error) {
+	var out ReleaseResponse
+
+	path := fmt.Sprintf("/v1/movies/%d/releases/%d", id, releaseID)
+
+	err := c.doJSON(ctx, "PATCH", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// DeleteMovieRelease: Delete a release.
+//
+//	DELETE /v1/movies/:id/releases/:release_id
+func (c *Client) DeleteMovieRelease(ctx context.Context, id int64, releaseID int64) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := fmt.Sprintf("/v1/movies/%d/releases/%d", id, releaseID)
+
+	err := c.doJSON(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ProposeMovieChange: Propose a change to a movie for an editor to approve.
+//
+//	POST /v1/movies/:id/pending-changes
+func (c *Client) ProposeMovieChange(ctx context.Context, id int64, input *MovieUpdate) (*MovieChangeResponse, error) {
+	var out MovieChangeResponse
+
+	path := fmt.Sprintf("/v1/movies/%d/pending-changes", id)
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListMovieChanges: List the changes proposed for a movie with a status, pending by default.
+//
+//	GET /v1/movies/:id/pending-changes
+func (c *Client) ListMovieChanges(ctx context.Context, id int64, query url.Values) (*MovieChangeListResponse, error) {
+	var out MovieChangeListResponse
+
+	path := fmt.Sprintf("/v1/movies/%d/pending-changes", id)
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ApproveMovieChange: Apply a proposed change to its movie.
+//
+//	PUT /v1/movies/:id/pending-changes/:change_id/approve
+func (c *Client) ApproveMovieChange(ctx context.Context, id int64, changeID int64) (*MovieChangeApprovalResponse, error) {
+	var out MovieChangeApprovalResponse
+
+	path := fmt.Sprintf("/v1/movies/%d/pending-changes/%d/approve", id, changeID)
+
+	err := c.doJSON(ctx, "PUT", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// RejectMovieChange: Reject a proposed change.
+//
+//	PUT /v1/movies/:id/pending-changes/:change_id/reject
+func (c *Client) RejectMovieChange(ctx context.Context, id int64, changeID int64, input *RejectionInput) (*MovieChangeResponse, error) {
+	var out MovieChangeResponse
+
+	path := fmt.Sprintf("/v1/movies/%d/pending-changes/%d/reject", id, changeID)
+
+	err := c.doJSON(ctx, "PUT", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListMovieReviews: List a movie's published reviews.
+//
+//	GET /v1/movies/:id/reviews
+func (c *Client) ListMovieReviews(ctx context.Context, id int64, query url.Values) (*ReviewListResponse, error) {
+	var out ReviewListResponse
+
+	path := fmt.Sprintf("/v1/movies/%d/reviews", id)
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateMovieReview: Review a movie.
+//
+//	POST /v1/movies/:id/reviews
+func (c *Client) CreateMovieReview(ctx context.Context, id int64, input *ReviewInput) (*ReviewResponse, error) {
+	var out ReviewResponse
+
+	path := fmt.Sprintf("/v1/movies/%d/reviews", id)
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ReportReview: Report an abusive review.
+//
+//	POST /v1/reviews/:id/reports
+func (c *Client) ReportReview(ctx context.Context, id int64, input *ReportInput) (*ReportResponse, error) {
+	var out ReportResponse
+
+	path := fmt.Sprintf("/v1/reviews/%d/reports", id)
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListCollections: List the collections matching the query string filters.
+//
+//	GET /v1/collections
+func (c *Client) ListCollections(ctx context.Context, query url.Values) (*CollectionListResponse, error) {
+	var out CollectionListResponse
+
+	path := "/v1/collections"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// GetCollection: Fetch a collection along with its movies.
+//
+//	GET /v1/collections/:id
+func (c *Client) GetCollection(ctx context.Context, id int64) (*CollectionResponse, error) {
+	var out CollectionResponse
+
+	path := fmt.Sprintf("/v1/collections/%d", id)
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateCollection: Create a collection.
+//
+//	POST /v1/collections
+func (c *Client) CreateCollection(ctx context.Context, input *CollectionInput) (*CollectionResponse, error) {
+	var out CollectionResponse
+
+	path := "/v1/collections"
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// UpdateCollection: Change some of a collection's fields.
+//
+//	PATCH /v1/collections/:id
+func (c *Client) UpdateCollection(ctx context.Context, id int64, input *CollectionUpdate) (*CollectionResponse, error) {
+	var out CollectionResponse
+
+	path := fmt.Sprintf("/v1/collections/%d", id)
+
+	err := c.doJSON(ctx, "PATCH", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// DeleteCollection: Delete a collection.
+//
+//	DELETE /v1/collections/:id
+func (c *Client) DeleteCollection(ctx context.Context, id int64) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := fmt.Sprintf("/v1/collections/%d", id)
+
+	err := c.doJSON(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListModerationReviews: List the reviews with a moderation status, pending by default.
+//
+//	GET /v1/moderation/reviews
+func (c *Client) ListModerationReviews(ctx context.Context, query url.Values) (*ReviewListResponse, error) {
+	var out ReviewListResponse
+
+	path := "/v1/moderation/reviews"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ApproveReview: Publish a review.
+//
+//	PUT /v1/moderation/reviews/:id/approve
+func (c *Client) ApproveReview(ctx context.Context, id int64) (*ReviewResponse, error) {
+	var out ReviewResponse
+
+	path := fmt.Sprintf("/v1/moderation/reviews/%d/approve", id)
+
+	err := c.doJSON(ctx, "PUT", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// RejectReview: Reject a review.
+//
+//	PUT /v1/moderation/reviews/:id/reject
+func (c *Client) RejectReview(ctx context.Context, id int64, input *RejectionInput) (*ReviewResponse, error) {
+	var out ReviewResponse
+
+	path := fmt.Sprintf("/v1/moderation/reviews/%d/reject", id)
+
+	err := c.doJSON(ctx, "PUT", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListReports: List the review reports with a status, open by default.
+//
+//	GET /v1/moderation/reports
+func (c *Client) ListReports(ctx context.Context, query url.Values) (*ReportListResponse, error) {
+	var out ReportListResponse
+
+	path := "/v1/moderation/reports"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ResolveReport: Uphold or dismiss a report.
+//
+//	PUT /v1/moderation/reports/:id
+func (c *Client) ResolveReport(ctx context.Context, id int64, input *ResolutionInput) (*ReportResponse, error) {
+	var out ReportResponse
+
+	path := fmt.Sprintf("/v1/moderation/reports/%d", id)
+
+	err := c.doJSON(ctx, "PUT", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListInvitations: List the invitations, optionally with a status.
+//
+//	GET /v1/invitations
+func (c *Client) ListInvitations(ctx context.Context, query url.Values) (*InvitationListResponse, error) {
+	var out InvitationListResponse
+
+	path := "/v1/invitations"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateInvitation: Email an invitation to register.
+//
+//	POST /v1/invitations
+func (c *Client) CreateInvitation(ctx context.Context, input *EmailInput) (*InvitationResponse, error) {
+	var out InvitationResponse
+
+	path := "/v1/invitations"
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// RevokeInvitation: Revoke an invitation which hasn't been accepted.
+//
+//	DELETE /v1/invitations/:id
+func (c *Client) RevokeInvitation(ctx context.Context, id int64) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := fmt.Sprintf("/v1/invitations/%d", id)
+
+	err := c.doJSON(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// RegisterUser: Register a new user, who is sent an activation token unless they were invited.
+//
+//	POST /v1/users
+func (c *Client) RegisterUser(ctx context.Context, input *RegistrationInput) (*UserResponse, error) {
+	var out UserResponse
+
+	path := "/v1/users"
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ActivateUser: Activate a user with their activation token.
+//
+//	PUT /v1/users/activated
+func (c *Client) ActivateUser(ctx context.Context, input *ActivationInput) (*UserResponse, error) {
+	var out UserResponse
+
+	path := "/v1/users/activated"
+
+	err := c.doJSON(ctx, "PUT", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ResetPassword: Change a user's password with their password reset token.
+//
+//	PUT /v1/users/password
+func (c *Client) ResetPassword(ctx context.Context, input *PasswordResetInput) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := "/v1/users/password"
+
+	err := c.doJSON(ctx, "PUT", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListNotifications: List the user's notifications.
+//
+//	GET /v1/users/me/notifications
+func (c *Client) ListNotifications(ctx context.Context, query url.Values) (*NotificationListResponse, error) {
+	var out NotificationListResponse
+
+	path := "/v1/users/me/notifications"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// MarkNotificationRead: Mark a notification as read.
+//
+//	PUT /v1/users/me/notifications/:id/read
+func (c *Client) MarkNotificationRead(ctx context.Context, id int64) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := fmt.Sprintf("/v1/users/me/notifications/%d/read", id)
+
+	err := c.doJSON(ctx, "PUT", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// GetNotificationPreferences: Fetch the user's notification preferences.
+//
+//	GET /v1/users/me/notification-preferences
+func (c *Client) GetNotificationPreferences(ctx context.Context) (*PreferencesResponse, error) {
+	var out PreferencesResponse
+
+	path := "/v1/users/me/notification-preferences"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// UpdateNotificationPreferences: Change the user's notification preferences.
+//
+//	PUT /v1/users/me/notification-preferences
+func (c *Client) UpdateNotificationPreferences(ctx context.Context, input map[string]ChannelPreferences) (*PreferencesResponse, error) {
+	var out PreferencesResponse
+
+	path := "/v1/users/me/notification-preferences"
+
+	err := c.doJSON(ctx, "PUT", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListSavedSearches: List the user's saved searches.
+//
+//	GET /v1/users/me/saved-searches
+func (c *Client) ListSavedSearches(ctx context.Context) (*SavedSearchListResponse, error) {
+	var out SavedSearchListResponse
+
+	path := "/v1/users/me/saved-searches"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateSavedSearch: Save a search to be alerted about.
+//
+//	POST /v1/users/me/saved-searches
+func (c *Client) CreateSavedSearch(ctx context.Context, input *SavedSearchInput) (*SavedSearchResponse, error) {
+	var out SavedSearchResponse
+
+	path := "/v1/users/me/saved-searches"
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// DeleteSavedSearch: Delete a saved search.
+//
+//	DELETE /v1/users/me/saved-searches/:id
+func (c *Client) DeleteSavedSearch(ctx context.Context, id int64) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := fmt.Sprintf("/v1/users/me/saved-searches/%d", id)
+
+	err := c.doJSON(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ShowDigestSubscription: Fetch the user's digest subscription.
+//
+//	GET /v1/users/me/digest
+func (c *Client) ShowDigestSubscription(ctx context.Context) (*DigestSubscriptionResponse, error) {
+	var out DigestSubscriptionResponse
+
+	path := "/v1/users/me/digest"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// UpdateDigestSubscription: Subscribe to the digest emails of new movies in the given genres, or change the subscription.
+//
+//	PUT /v1/users/me/digest
+func (c *Client) UpdateDigestSubscription(ctx context.Context, input *DigestSubscriptionInput) (*DigestSubscriptionResponse, error) {
+	var out DigestSubscriptionResponse
+
+	path := "/v1/users/me/digest"
+
+	err := c.doJSON(ctx, "PUT", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// DeleteDigestSubscription: Unsubscribe from the digest emails.
+//
+//	DELETE /v1/users/me/digest
+func (c *Client) DeleteDigestSubscription(ctx context.Context) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := "/v1/users/me/digest"
+
+	err := c.doJSON(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// AcceptTOS: Accept the current version of the terms of service, which is required before using the API when the deployment has terms.
+//
+//	POST /v1/users/me/tos-acceptance
+func (c *Client) AcceptTOS(ctx context.Context, input *TOSAcceptanceInput) (*TOSAcceptanceResponse, error) {
+	var out TOSAcceptanceResponse
+
+	path := "/v1/users/me/tos-acceptance"
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListServiceAccounts: List the user's service accounts.
+//
+//	GET /v1/users/me/service-accounts
+func (c *Client) ListServiceAccounts(ctx context.Context) (*ServiceAccountListResponse, error) {
+	var out ServiceAccountListResponse
+
+	path := "/v1/users/me/service-accounts"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateServiceAccount: Create a service account, returning its key.
+//
+//	POST /v1/users/me/service-accounts
+func (c *Client) CreateServiceAccount(ctx context.Context, input *ServiceAccountInput) (*ServiceAccountKeyResponse, error) {
+	var out ServiceAccountKeyResponse
+
+	path := "/v1/users/me/service-accounts"
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ShowServiceAccount: Fetch one of the user's service accounts.
+//
+//	GET /v1/users/me/service-accounts/:id
+func (c *Client) ShowServiceAccount(ctx context.Context, id int64) (*ServiceAccountResponse, error) {
+	var out ServiceAccountResponse
+
+	path := fmt.Sprintf("/v1/users/me/service-accounts/%d", id)
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// UpdateServiceAccount: Change some of a service account's fields.
+//
+//	PATCH /v1/users/me/service-accounts/:id
+func (c *Client) UpdateServiceAccount(ctx context.Context, id int64, input *ServiceAccountUpdate) (*ServiceAccountResponse, error) {
+	var out ServiceAccountResponse
+
+	path := fmt.Sprintf("/v1/users/me/service-accounts/%d", id)
+
+	err := c.doJSON(ctx, "PATCH", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// RotateServiceAccountKey: Replace a service account's key, returning the new one.
+//
+//	POST /v1/users/me/service-accounts/:id/key
+func (c *Client) RotateServiceAccountKey(ctx context.Context, id int64) (*ServiceAccountKeyResponse, error) {
+	var out ServiceAccountKeyResponse
+
+	path := fmt.Sprintf("/v1/users/me/service-accounts/%d/key", id)
+
+	err := c.doJSON(ctx, "POST", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// DeleteServiceAccount: Delete a service account.
+//
+//	DELETE /v1/users/me/service-accounts/:id
+func (c *Client) DeleteServiceAccount(ctx context.Context, id int64) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := fmt.Sprintf("/v1/users/me/service-accounts/%d", id)
+
+	err := c.doJSON(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateActivationToken: Send a new activation token to a user.
+//
+//	POST /v1/tokens/activation
+func (c *Client) CreateActivationToken(ctx context.Context, input *EmailInput) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := "/v1/tokens/activation"
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreatePasswordResetToken: Send a password reset token to a user.
+//
+//	POST /v1/tokens/password-reset
+func (c *Client) CreatePasswordResetToken(ctx context.Context, input *EmailInput) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := "/v1/tokens/password-reset"
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateAuthenticationToken: Exchange a user's credentials for an authentication token.
+//
+//	POST /v1/tokens/authentication
+func (c *Client) CreateAuthenticationToken(ctx context.Context, input *CredentialsInput) (*TokenResponse, error) {
+	var out TokenResponse
+
+	path := "/v1/tokens/authentication"
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateGuestToken: Start a guest session, returning a read-only token for browsing the catalog.
+//
+//	POST /v1/tokens/guest
+func (c *Client) CreateGuestToken(ctx context.Context) (*GuestTokenResponse, error) {
+	var out GuestTokenResponse
+
+	path := "/v1/tokens/guest"
+
+	err := c.doJSON(ctx, "POST", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ImpersonateUser: Get a short-lived token acting as a user, for reproducing the issues they report.
+//
+//	POST /v1/admin/impersonate/:id
+func (c *Client) ImpersonateUser(ctx context.Context, id int64) (*ImpersonationTokenResponse, error) {
+	var out ImpersonationTokenResponse
+
+	path := fmt.Sprintf("/v1/admin/impersonate/%d", id)
+
+	err := c.doJSON(ctx, "POST", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// AdminStats: Fetch the figures shown on the ops dashboard.
+//
+//	GET /v1/admin/stats
+func (c *Client) AdminStats(ctx context.Context, query url.Values) (*AdminStatsResponse, error) {
+	var out AdminStatsResponse
+
+	path := "/v1/admin/stats"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ShowUser: Fetch a user along with the delivery status of their email address.
+//
+//	GET /v1/admin/users/:id
+func (c *Client) ShowUser(ctx context.Context, id int64) (*AdminUserResponse, error) {
+	var out AdminUserResponse
+
+	path := fmt.Sprintf("/v1/admin/users/%d", id)
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ShowMetrics: Fetch the current metrics and request rates.
+//
+//	GET /v1/admin/metrics
+func (c *Client) ShowMetrics(ctx context.Context) (*MetricsResponse, error) {
+	var out MetricsResponse
+
+	path := "/v1/admin/metrics"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateMetricSnapshot: Store the current metrics in the database.
+//
+//	POST /v1/admin/metrics/snapshots
+func (c *Client) CreateMetricSnapshot(ctx context.Context, input *MetricSnapshotInput) (*MetricSnapshotResponse, error) {
+	var out MetricSnapshotResponse
+
+	path := "/v1/admin/metrics/snapshots"
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListMetricSnapshots: List the stored metric snapshots, newest first.
+//
+//	GET /v1/admin/metrics/snapshots
+func (c *Client) ListMetricSnapshots(ctx context.Context, query url.Values) (*MetricSnapshotListResponse, error) {
+	var out MetricSnapshotListResponse
+
+	path := "/v1/admin/metrics/snapshots"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ResetMetrics: Reset the in-memory metric counters.
+//
+//	POST /v1/admin/metrics/reset
+func (c *Client) ResetMetrics(ctx context.Context) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := "/v1/admin/metrics/reset"
+
+	err := c.doJSON(ctx, "POST", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListBans: List the banned IP addresses.
+//
+//	GET /v1/admin/bans
+func (c *Client) ListBans(ctx context.Context) (*BanListResponse, error) {
+	var out BanListResponse
+
+	path := "/v1/admin/bans"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// DeleteBan: Lift the ban on an IP address.
+//
+//	DELETE /v1/admin/bans/:ip
+func (c *Client) DeleteBan(ctx context.Context, ip string) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := fmt.Sprintf("/v1/admin/bans/%s", url.PathEscape(ip))
+
+	err := c.doJSON(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListRateLimits: List the clients tracked by the rate limiter of the instance handling the request.
+//
+//	GET /v1/admin/rate-limits
+func (c *Client) ListRateLimits(ctx context.Context) (*RateLimitListResponse, error) {
+	var out RateLimitListResponse
+
+	path := "/v1/admin/rate-limits"
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// DeleteRateLimit: Reset a client's rate limit on the instance handling the request.
+//
+//	DELETE /v1/admin/rate-limits/:key
+func (c *Client) DeleteRateLimit(ctx context.Context, key string) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := fmt.Sprintf("/v1/admin/rate-limits/%s", url.PathEscape(key))
+
+	err := c.doJSON(ctx, "DELETE", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateBackup: Queue a backup of the database.
+//
+//	POST /v1/admin/backups
+func (c *Client) CreateBackup(ctx context.Context) (*MessageResponse, error) {
+	var out MessageResponse
+
+	path := "/v1/admin/backups"
+
+	err := c.doJSON(ctx, "POST", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListBackups: List the database backups, newest first.
+//
+//	GET /v1/admin/backups
+func (c *Client) ListBackups(ctx context.Context, query url.Values) (*BackupListResponse, error) {
+	var out BackupListResponse
+
+	path := "/v1/admin/backups"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// CreateOperation: Queue an online schema change.
+//
+//	POST /v1/admin/operations
+func (c *Client) CreateOperation(ctx context.Context, input *OperationInput) (*OperationResponse, error) {
+	var out OperationResponse
+
+	path := "/v1/admin/operations"
+
+	err := c.doJSON(ctx, "POST", path, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListOperations: List the schema operations, newest first.
+//
+//	GET /v1/admin/operations
+func (c *Client) ListOperations(ctx context.Context, query url.Values) (*OperationListResponse, error) {
+	var out OperationListResponse
+
+	path := "/v1/admin/operations"
+
+	err := c.doJSON(ctx, "GET", path, query, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// GetOperation: Fetch a schema operation and its progress.
+//
+//	GET /v1/admin/operations/:id
+func (c *Client) GetOperation(ctx context.Context, id int64) (*OperationResponse, error) {
+	var out OperationResponse
+
+	path := fmt.Sprintf("/v1/admin/operations/%d", id)
+
+	err := c.doJSON(ctx, "GET", path, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
@@ -0,0 +1,1094 @@
+// Code generated by cmd/genclient. DO NOT EDIT.
+
+// A typed client for the Greenlight v1 API. Regenerate it with
+// "make client/generate" after changing the API.
+
+/**
+ * Thrown for error responses. code is the stable error code, such as "movie_not_found", listed by
+ * errorCodes(). For failed validations, fields holds the code and parameters of each invalid field's error.
+ */
+export class GreenlightError extends Error {
+  constructor(public status: number, public details: unknown, public code?: string, public fields?: Record<string, FieldError>) {
+    super(typeof details === "string" ? details : JSON.stringify(details));
+  }
+}
+
+/** Pagination metadata of a list */
+export interface Metadata {
+  current_page?: number;
+  page_size?: number;
+  first_page?: number;
+  last_page?: number;
+  total_records?: number;
+  next_cursor?: string;
+  facets?: MovieFacets | null;
+}
+
+/** Number of movies matching a search by genre, year, decade (such as "1990s"), runtime range (such as "90-119") and certification (such as "US:PG-13") */
+export interface MovieFacets {
+  genres: Record<string, number>;
+  years: Record<string, number>;
+  decades: Record<string, number>;
+  runtimes: Record<string, number>;
+  certifications: Record<string, number>;
+}
+
+/** A movie title suggested as the user types */
+export interface MovieSuggestion {
+  id: number;
+  title: string;
+  year?: number;
+}
+
+/** A movie. Runtimes are strings such as "102 mins". */
+export interface Movie {
+  id: number;
+  title: string;
+  year?: number;
+  runtime?: string;
+  genres?: string[];
+  synopsis?: string;
+  original_language?: string;
+  production_countries?: string[];
+  certifications?: Record<string, string>;
+  metadata?: Record<string, string>;
+  created_by?: number;
+  status: string;
+  publish_at?: string | null;
+  version: number;
+  views: number;
+  created_at: string;
+  updated_at: string;
+  collection?: Collection | null;
+}
+
+/** The fields of a new movie */
+export interface MovieInput {
+  title: string;
+  year: number;
+  runtime: string;
+  genres: string[];
+  synopsis?: string;
+  original_language?: string;
+  production_countries?: string[];
+  certifications?: Record<string, string>;
+  metadata?: Record<string, string>;
+  status?: string;
+  publish_at?: string | null;
+}
+
+/** The fields to change on a movie. Nil fields are left as they are, and metadata keys set to nil are removed. */
+export interface MovieUpdate {
+  title?: string | null;
+  year?: number | null;
+  runtime?: string | null;
+  genres?: string[];
+  synopsis?: string | null;
+  original_language?: string | null;
+  production_countries?: string[];
+  certifications?: Record<string, string>;
+  metadata?: Record<string, string | null>;
+  status?: string | null;
+  publish_at?: string | null;
+}
+
+/** A change to a movie proposed by a contributor, applied once an editor approves it */
+export interface MovieChange {
+  id: number;
+  created_at: string;
+  movie_id: number;
+  user_id: number;
+  base_version: number;
+  changes: MovieUpdate;
+  status: string;
+  review_reason?: string;
+  reviewed_at?: string | null;
+  version: number;
+}
+
+/** A release of a movie in a country */
+export interface Release {
+  id: number;
+  movie_id: number;
+  country: string;
+  type: string;
+  release_date: string;
+  note?: string;
+  version: number;
+}
+
+/** The fields of a new release. Dates are in the format "2006-01-02". */
+export interface ReleaseInput {
+  country: string;
+  type: string;
+  release_date: string;
+  note?: string;
+}
+
+/** The fields to change on a release. Nil fields are left as they are. */
+export interface ReleaseUpdate {
+  country?: string | null;
+  type?: string | null;
+  release_date?: string | null;
+  note?: string | null;
+}
+
+/** A collection of related movies */
+export interface Collection {
+  id: number;
+  created_at: string;
+  name: string;
+  description?: string;
+  movie_ids: number[];
+  version: number;
+}
+
+/** The fields of a new collection */
+export interface CollectionInput {
+  name: string;
+  description?: string;
+  movie_ids: number[];
+}
+
+/** The fields to change on a collection. Nil fields are left as they are. */
+export interface CollectionUpdate {
+  name?: string | null;
+  description?: string | null;
+  movie_ids?: number[];
+}
+
+/** A user's review of a movie */
+export interface Review {
+  id: number;
+  created_at: string;
+  movie_id: number;
+  user_id: number;
+  user_name: string;
+  rating: number;
+  body: string;
+  status: string;
+  moderation_reason?: string;
+  moderated_at?: string | null;
+  version: number;
+}
+
+/** The fields of a new review */
+export interface ReviewInput {
+  rating: number;
+  body: string;
+}
+
+/** The optional reason for rejecting a review or a proposed change */
+export interface RejectionInput {
+  reason?: string;
+}
+
+/** A user's report of an abusive review */
+export interface Report {
+  id: number;
+  created_at: string;
+  review_id: number;
+  user_id: number;
+  reason: string;
+  comment?: string;
+  status: string;
+  resolved_at?: string | null;
+  version: number;
+}
+
+/** The fields of a new report */
+export interface ReportInput {
+  reason: string;
+  comment?: string;
+}
+
+/** How a report is resolved, either upheld or dismissed */
+export interface ResolutionInput {
+  status: string;
+}
+
+/** A registered user */
+export interface User {
+  id: number;
+  created_at: string;
+  name: string;
+  email: string;
+  activated: boolean;
+}
+
+/** The delivery status of a user's email address, as reported by the email provider: ok, soft_bounce, bounced or complained */
+export interface EmailStatus {
+  status: string;
+  updated_at: string | null;
+}
+
+/** The details of a new user, with the invitation code they were sent when registration is closed */
+export interface RegistrationInput {
+  name: string;
+  email: string;
+  password: string;
+  invitation?: string;
+}
+
+/** An invitation for someone to register */
+export interface Invitation {
+  id: number;
+  created_at: string;
+  email: string;
+  invited_by: number;
+  expiry: string;
+  accepted_at: string | null;
+  status: string;
+}
+
+/** The token sent to a new user */
+export interface ActivationInput {
+  token: string;
+}
+
+/** A new password and the password reset token */
+export interface PasswordResetInput {
+  password: string;
+  token: string;
+}
+
+/** The email address to send a token to */
+export interface EmailInput {
+  email: string;
+}
+
+/** A user's email address and password, with the permissions to restrict the token to and its expiry */
+export interface CredentialsInput {
+  email: string;
+  password: string;
+  scopes?: string[];
+  expiry?: string | null;
+}
+
+/** An authentication token */
+export interface Token {
+  token: string;
+  expiry: string;
+  scopes?: string[];
+}
+
+/** A notification sent to the user */
+export interface Notification {
+  id: number;
+  created_at: string;
+  kind: string;
+  title: string;
+  body: string;
+  data?: Record<string, unknown>;
+  read_at: string | null;
+}
+
+/** The channels a kind of notification is sent on */
+export interface ChannelPreferences {
+  email: boolean;
+  in_app: boolean;
+}
+
+/** A movie search the user is alerted about */
+export interface SavedSearch {
+  id: number;
+  created_at: string;
+  name: string;
+  title: string;
+  genres: string[];
+}
+
+/** The fields of a new saved search */
+export interface SavedSearchInput {
+  name: string;
+  title: string;
+  genres: string[];
+}
+
+/** The user's subscription to the digest emails of new movies in their favorite genres */
+export interface DigestSubscription {
+  created_at: string;
+  frequency: string;
+  genres: string[];
+  next_digest_at: string;
+}
+
+/** How often the user wants a digest (daily or weekly) and their favorite genres */
+export interface DigestSubscriptionInput {
+  frequency: string;
+  genres: string[];
+}
+
+/** The user's acceptance of a version of the terms of service */
+export interface TOSAcceptance {
+  version: string;
+  accepted_at: string;
+}
+
+/** The version of the terms of service the user accepts */
+export interface TOSAcceptanceInput {
+  version: string;
+}
+
+/** A non-human client acting for the user with some of their permissions */
+export interface ServiceAccount {
+  id: number;
+  created_at: string;
+  owner_id: number;
+  name: string;
+  key_prefix: string;
+  permissions: string[];
+  active: boolean;
+  version: number;
+}
+
+/** The fields of a new service account */
+export interface ServiceAccountInput {
+  name: string;
+  permissions: string[];
+}
+
+/** The fields to change on a service account. Nil fields are left as they are. */
+export interface ServiceAccountUpdate {
+  name?: string | null;
+  permissions?: string[];
+  active?: boolean | null;
+}
+
+/** A line of a bulk import which couldn't be imported */
+export interface ImportFailure {
+  line: number;
+  code: string;
+  error: unknown;
+  fields?: Record<string, FieldError>;
+}
+
+/** The code, message and parameters of a field which failed validation */
+export interface FieldError {
+  code: string;
+  message: string;
+  params?: Record<string, unknown>;
+}
+
+export interface MessageResponse {
+  message: string;
+}
+
+export interface MovieResponse {
+  movie: Movie;
+}
+
+export interface MovieListResponse {
+  movies: Movie[];
+  metadata?: Metadata;
+}
+
+export interface SuggestionListResponse {
+  suggestions: MovieSuggestion[];
+}
+
+export interface ReleaseResponse {
+  release: Release;
+}
+
+export interface ReleaseListResponse {
+  releases: Release[];
+}
+
+export interface CollectionResponse {
+  collection: Collection;
+  movies?: Movie[];
+}
+
+export interface CollectionListResponse {
+  collections: Collection[];
+  metadata: Metadata;
+}
+
+export interface MovieChangeResponse {
+  change: MovieChange;
+}
+
+export interface MovieChangeApprovalResponse {
+  change: MovieChange;
+  movie: Movie;
+}
+
+export interface MovieChangeListResponse {
+  changes: MovieChange[];
+  metadata: Metadata;
+}
+
+export interface ReviewResponse {
+  review: Review;
+}
+
+export interface ReviewListResponse {
+  reviews: Review[];
+  metadata: Metadata;
+}
+
+export interface ReportResponse {
+  report: Report;
+}
+
+export interface ReportListResponse {
+  reports: Report[];
+  metadata: Metadata;
+}
+
+export interface InvitationResponse {
+  invitation: Invitation;
+}
+
+export interface InvitationListResponse {
+  invitations: Invitation[];
+  metadata: Metadata;
+}
+
+export interface UserResponse {
+  user: User;
+}
+
+export interface AdminUserResponse {
+  user: User;
+  email_status: EmailStatus;
+}
+
+export interface TokenResponse {
+  authentication_token: Token;
+}
+
+export interface GuestTokenResponse {
+  guest_token: Token;
+}
+
+export interface ImpersonationTokenResponse {
+  impersonation_token: Token;
+  user: User;
+}
+
+export interface NotificationListResponse {
+  notifications: Notification[];
+  metadata: Metadata;
+}
+
+export interface PreferencesResponse {
+  preferences: Record<string, ChannelPreferences>;
+}
+
+export interface SavedSearchResponse {
+  saved_search: SavedSearch;
+}
+
+export interface DigestSubscriptionResponse {
+  digest_subscription: DigestSubscription;
+}
+
+export interface TOSAcceptanceResponse {
+  tos_acceptance: TOSAcceptance;
+}
+
+export interface SavedSearchListResponse {
+  saved_searches: SavedSearch[];
+}
+
+export interface ServiceAccountResponse {
+  service_account: ServiceAccount;
+}
+
+export interface ServiceAccountKeyResponse {
+  service_account: ServiceAccount;
+  key: string;
+}
+
+export interface ServiceAccountListResponse {
+  service_accounts: ServiceAccount[];
+}
+
+export interface ImportResponse {
+  imported: number;
+  failed: number;
+  failures: ImportFailure[];
+}
+
+export interface HealthcheckResponse {
+  status: string;
+  system_info: Record<string, string>;
+}
+
+export interface MetaResponse {
+  build: Record<string, string>;
+  features: Record<string, boolean>;
+}
+
+/** A route served by the API, with the permission it requires */
+export interface Route {
+  method: string;
+  path: string;
+  handler: string;
+  permission?: string;
+  rate_limit: string;
+}
+
+export interface RouteListResponse {
+  routes: Route[];
+}
+
+export interface ChangelogResponse {
+  changelog: Record<string, string>[];
+}
+
+/** An error code sent with error responses */
+export interface ErrorCode {
+  code: string;
+  status: number;
+  description: string;
+}
+
+export interface ErrorCodesResponse {
+  error_codes: ErrorCode[];
+}
+
+export interface MovieListingResponse {
+  listing: unknown;
+  page_size: Record<string, number>;
+  max_page: number;
+}
+
+export interface AvailabilityResponse {
+  availability: unknown;
+}
+
+export interface AdminStatsResponse {
+  generated_at: string;
+  users: unknown;
+  movies: unknown;
+  reviews: unknown;
+  requests: Record<string, unknown>;
+  mailer_queue?: unknown;
+  events_outbox?: unknown;
+}
+
+/** The metrics of an instance at a point in time */
+export interface MetricSnapshot {
+  id: number;
+  created_at: string;
+  label?: string;
+  instance: string;
+  metrics: Record<string, unknown>;
+}
+
+/** The optional label of a snapshot, and whether to reset the counters once it is stored */
+export interface MetricSnapshotInput {
+  label?: string;
+  reset?: boolean;
+}
+
+export interface MetricsResponse {
+  metrics: Record<string, unknown>;
+  rates: Record<string, number>;
+}
+
+export interface MetricSnapshotResponse {
+  snapshot: MetricSnapshot;
+}
+
+export interface MetricSnapshotListResponse {
+  snapshots: MetricSnapshot[];
+  metadata: Metadata;
+}
+
+export interface BanListResponse {
+  bans: unknown;
+}
+
+/** A client tracked by the rate limiter of an API instance */
+export interface RateLimitClient {
+  key: string;
+  tokens_remaining: number;
+  last_seen: string;
+}
+
+export interface RateLimitListResponse {
+  rate_limits: RateLimitClient[];
+}
+
+/** A dump of the database kept in the backup storage */
+export interface Backup {
+  id: number;
+  created_at: string;
+  name: string;
+  status: string;
+  size: number;
+  error?: string;
+  finished_at?: string | null;
+}
+
+export interface BackupListResponse {
+  backups: Backup[];
+  metadata: Metadata;
+}
+
+export interface OperationInput {
+  name: string;
+}
+
+/** A run of an online schema change, such as an index build or a batched backfill */
+export interface Operation {
+  id: number;
+  created_at: string;
+  name: string;
+  status: string;
+  progress: number;
+  total: number;
+  rows_updated: number;
+  error?: string;
+  updated_at: string;
+  finished_at?: string | null;
+}
+
+export interface OperationResponse {
+  operation: Operation;
+}
+
+export interface OperationListResponse {
+  operations: Operation[];
+  metadata: Metadata;
+}
+
+export class GreenlightClient {
+  // The consistency token of the last write, sent back so that reads see the write
+  private consistency?: string;
+
+  constructor(private baseURL: string, public token?: string) {
+    this.baseURL = baseURL.replace(/\/$/, "");
+  }
+
+  private async request<T>(method: string, path: string, query?: Record<string, string>, body?: string, contentType = "application/json"): Promise<T> {
+    let url = this.baseURL + path;
+    if (query && Object.keys(query).length > 0) {
+      url += "?" + new URLSearchParams(query).toString();
+    }
+
+    const headers: Record<string, string> = { Accept: "application/json" };
+    if (body !== undefined) {
+      headers["Content-Type"] = contentType;
+    }
+    if (this.token) {
+      headers["Authorization"] = "Bearer " + this.token;
+    }
+    if (this.consistency) {
+      headers["X-Consistency-Token"] = this.consistency;
+    }
+
+    const res = await fetch(url, { method, headers, body });
+    this.consistency = res.headers.get("X-Consistency-Token") ?? this.consistency;
+    const data = await res.json().catch(() => ({}));
+    if (!res.ok) {
+      throw new GreenlightError(res.status, data.error ?? res.statusText, data.code, data.fields);
+    }
+
+    return data as T;
+  }
+
+  /** Report the status of the API. GET /v1/healthcheck */
+  healthcheck(): Promise<HealthcheckResponse> {
+    return this.request("GET", `/v1/healthcheck`, undefined);
+  }
+
+  /** Describe the running build and its enabled features. GET /v1/meta */
+  meta(): Promise<MetaResponse> {
+    return this.request("GET", `/v1/meta`, undefined);
+  }
+
+  /** List the routes served by the API, with the permission each requires. GET /v1/meta/routes */
+  listRoutes(): Promise<RouteListResponse> {
+    return this.request("GET", `/v1/meta/routes`, undefined);
+  }
+
+  /** List the changes made to the API. GET /v1/changelog */
+  changelog(): Promise<ChangelogResponse> {
+    return this.request("GET", `/v1/changelog`, undefined);
+  }
+
+  /** List the error codes sent with error responses. GET /v1/error-codes */
+  errorCodes(): Promise<ErrorCodesResponse> {
+    return this.request("GET", `/v1/error-codes`, undefined);
+  }
+
+  /** List the movies matching the query string filters. GET /v1/movies */
+  listMovies(query?: Record<string, string>): Promise<MovieListResponse> {
+    return this.request("GET", `/v1/movies`, query);
+  }
+
+  /** Describe how movies can be sorted, filtered and paginated. GET /v1/movies/_meta */
+  movieListing(): Promise<MovieListingResponse> {
+    return this.request("GET", `/v1/movies/_meta`, undefined);
+  }
+
+  /** List the most viewed recent movies. GET /v1/movies/trending */
+  trendingMovies(query?: Record<string, string>): Promise<MovieListResponse> {
+    return this.request("GET", `/v1/movies/trending`, query);
+  }
+
+  /** List the newest movies. GET /v1/movies/recent */
+  recentMovies(query?: Record<string, string>): Promise<MovieListResponse> {
+    return this.request("GET", `/v1/movies/recent`, query);
+  }
+
+  /** Suggest movie titles matching the q parameter as the user types. GET /v1/movies/autocomplete */
+  autocompleteMovies(query?: Record<string, string>): Promise<SuggestionListResponse> {
+    return this.request("GET", `/v1/movies/autocomplete`, query);
+  }
+
+  /** Fetch a movie. GET /v1/movies/:id */
+  getMovie(id: number, query?: Record<string, string>): Promise<MovieResponse> {
+    return this.request("GET", `/v1/movies/${encodeURIComponent(String(id))}`, query);
+  }
+
+  /** Create a movie. POST /v1/movies */
+  createMovie(input: MovieInput): Promise<MovieResponse> {
+    return this.request("POST", `/v1/movies`, undefined, JSON.stringify(input));
+  }
+
+  /** Create movies in bulk. Movies which fail validation are reported in the response rather than failing the import. POST /v1/movies */
+  importMovies(input: MovieInput[]): Promise<ImportResponse> {
+    return this.request("POST", `/v1/movies`, undefined, input.map((item) => JSON.stringify(item)).join("\n") + "\n", "application/x-ndjson");
+  }
+
+  /** Change some of a movie's fields. PATCH /v1/movies/:id */
+  updateMovie(id: number, input: MovieUpdate): Promise<MovieResponse> {
+    return this.request("PATCH", `/v1/movies/${encodeURIComponent(String(id))}`, undefined, JSON.stringify(input));
+  }
+
+  /** Delete a movie. DELETE /v1/movies/:id */
+  deleteMovie(id: number): Promise<MessageResponse> {
+    return this.request("DELETE", `/v1/movies/${encodeURIComponent(String(id))}`, undefined);
+  }
+
+  /** List where a movie can be streamed, when the API has a watch-provider configured. GET /v1/movies/:id/availability */
+  showMovieAvailability(id: number, query?: Record<string, string>): Promise<AvailabilityResponse> {
+    return this.request("GET", `/v1/movies/${encodeURIComponent(String(id))}/availability`, query);
+  }
+
+  /** List a movie's releases. GET /v1/movies/:id/releases */
+  listMovieReleases(id: number): Promise<ReleaseListResponse> {
+    return this.request("GET", `/v1/movies/${encodeURIComponent(String(id))}/releases`, undefined);
+  }
+
+  /** Add a release to a movie. POST /v1/movies/:id/releases */
+  createMovieRelease(id: number, input: ReleaseInput): Promise<ReleaseResponse> {
+    return this.request("POST", `/v1/movies/${encodeURIComponent(String(id))}/releases`, undefined, JSON.stringify(input));
+  }
+
+  /** Change some of a release's fields. PATCH /v1/movies/:id/releases/:release_id */
+  updateMovieRelease(id: number, releaseID: number, input: ReleaseUpdate): Promise<ReleaseResponse> {
+    return this.request("PATCH", `/v1/movies/${encodeURIComponent(String(id))}/releases/${encodeURIComponent(String(releaseID))}`, undefined, JSON.stringify(input));
+  }
+
+  /** Delete a release. DELETE /v1/movies/:id/releases/:release_id */
+  deleteMovieRelease(id: number, releaseID: number): Promise<MessageResponse> {
+    return this.request("DELETE", `/v1/movies/${encodeURIComponent(String(id))}/releases/${encodeURIComponent(String(releaseID))}`, undefined);
+  }
+
+  /** Propose a change to a movie for an editor to approve. POST /v1/movies/:id/pending-changes */
+  proposeMovieChange(id: number, input: MovieUpdate): Promise<MovieChangeResponse> {
+    return this.request("POST", `/v1/movies/${encodeURIComponent(String(id))}/pending-changes`, undefined, JSON.stringify(input));
+  }
+
+  /** List the changes proposed for a movie with a status, pending by default. GET /v1/movies/:id/pending-changes */
+  listMovieChanges(id: number, query?: Record<string, string>): Promise<MovieChangeListResponse> {
+    return this.request("GET", `/v1/movies/${encodeURIComponent(String(id))}/pending-changes`, query);
+  }
+
+  /** Apply a proposed change to its movie. PUT /v1/movies/:id/pending-changes/:change_id/approve */
+  approveMovieChange(id: number, changeID: number): Promise<MovieChangeApprovalResponse> {
+    return this.request("PUT", `/v1/movies/${encodeURIComponent(String(id))}/pending-changes/${encodeURIComponent(String(changeID))}/approve`, undefined);
+  }
+
+  /** Reject a proposed change. PUT /v1/movies/:id/pending-changes/:change_id/reject */
+  rejectMovieChange(id: number, changeID: number, input: RejectionInput): Promise<MovieChangeResponse> {
+    return this.request("PUT", `/v1/movies/${encodeURIComponent(String(id))}/pending-changes/${encodeURIComponent(String(changeID))}/reject`, undefined, JSON.stringify(input));
+  }
+
+  /** List a movie's published reviews. GET /v1/movies/:id/reviews */
+  listMovieReviews(id: number, query?: Record<string, string>): Promise<ReviewListResponse> {
+    return this.request("GET", `/v1/movies/${encodeURIComponent(String(id))}/reviews`, query);
+  }
+
+  /** Review a movie. POST /v1/movies/:id/reviews */
+  createMovieReview(id: number, input: ReviewInput): Promise<ReviewResponse> {
+    return this.request("POST", `/v1/movies/${encodeURIComponent(String(id))}/reviews`, undefined, JSON.stringify(input));
+  }
+
+  /** Report an abusive review. POST /v1/reviews/:id/reports */
+  reportReview(id: number, input: ReportInput): Promise<ReportResponse> {
+    return this.request("POST", `/v1/reviews/${encodeURIComponent(String(id))}/reports`, undefined, JSON.stringify(input));
+  }
+
+  /** List the collections matching the query string filters. GET /v1/collections */
+  listCollections(query?: Record<string, string>): Promise<CollectionListResponse> {
+    return this.request("GET", `/v1/collections`, query);
+  }
+
+  /** Fetch a collection along with its movies. GET /v1/collections/:id */
+  getCollection(id: number): Promise<CollectionResponse> {
+    return this.request("GET", `/v1/collections/${encodeURIComponent(String(id))}`, undefined);
+  }
+
+  /** Create a collection. POST /v1/collections */
+  createCollection(input: CollectionInput): Promise<CollectionResponse> {
+    return this.request("POST", `/v1/collections`, undefined, JSON.stringify(input));
+  }
+
+  /** Change some of a collection's fields. PATCH /v1/collections/:id */
+  updateCollection(id: number, input: CollectionUpdate): Promise<CollectionResponse> {
+    return this.request("PATCH", `/v1/collections/${encodeURIComponent(String(id))}`, undefined, JSON.stringify(input));
+  }
+
+  /** Delete a collection. DELETE /v1/collections/:id */
+  deleteCollection(id: number): Promise<MessageResponse> {
+    return this.request("DELETE", `/v1/collections/${encodeURIComponent(String(id))}`, undefined);
+  }
+
+  /** List the reviews with a moderation status, pending by default. GET /v1/moderation/reviews */
+  listModerationReviews(query?: Record<string, string>): Promise<ReviewListResponse> {
+    return this.request("GET", `/v1/moderation/reviews`, query);
+  }
+
+  /** Publish a review. PUT /v1/moderation/reviews/:id/approve */
+  approveReview(id: number): Promise<ReviewResponse> {
+    return this.request("PUT", `/v1/moderation/reviews/${encodeURIComponent(String(id))}/approve`, undefined);
+  }
+
+  /** Reject a review. PUT /v1/moderation/reviews/:id/reject */
+  rejectReview(id: number, input: RejectionInput): Promise<ReviewResponse> {
+    return this.request("PUT", `/v1/moderation/reviews/${encodeURIComponent(String(id))}/reject`, undefined, JSON.stringify(input));
+  }
+
+  /** List the review reports with a status, open by default. GET /v1/moderation/reports */
+  listReports(query?: Record<string, string>): Promise<ReportListResponse> {
+    return this.request("GET", `/v1/moderation/reports`, query);
+  }
+
+  /** Uphold or dismiss a report. PUT /v1/moderation/reports/:id */
+  resolveReport(id: number, input: ResolutionInput): Promise<ReportResponse> {
+    return this.request("PUT", `/v1/moderation/reports/${encodeURIComponent(String(id))}`, undefined, JSON.stringify(input));
+  }
+
+  /** List the invitations, optionally with a status. GET /v1/invitations */
+  listInvitations(query?: Record<string, string>): Promise<InvitationListResponse> {
+    return this.request("GET", `/v1/invitations`, query);
+  }
+
+  /** Email an invitation to register. POST /v1/invitations */
+  createInvitation(input: EmailInput): Promise<InvitationResponse> {
+    return this.request("POST", `/v1/invitations`, undefined, JSON.stringify(input));
+  }
+
+  /** Revoke an invitation which hasn't been accepted. DELETE /v1/invitations/:id */
+  revokeInvitation(id: number): Promise<MessageResponse> {
+    return this.request("DELETE", `/v1/invitations/${encodeURIComponent(String(id))}`, undefined);
+  }
+
+  /** Register a new user, who is sent an activation token unless they were invited. POST /v1/users */
+  registerUser(input: RegistrationInput): Promise<UserResponse> {
+    return this.request("POST", `/v1/users`, undefined, JSON.stringify(input));
+  }
+
+  /** Activate a user with their activation token. PUT /v1/users/activated */
+  activateUser(input: ActivationInput): Promise<UserResponse> {
+    return this.request("PUT", `/v1/users/activated`, undefined, JSON.stringify(input));
+  }
+
+  /** Change a user's password with their password reset token. PUT /v1/users/password */
+  resetPassword(input: PasswordResetInput): Promise<MessageResponse> {
+    return this.request("PUT", `/v1/users/password`, undefined, JSON.stringify(input));
+  }
+
+  /** List the user's notifications. GET /v1/users/me/notifications */
+  listNotifications(query?: Record<string, string>): Promise<NotificationListResponse> {
+    return this.request("GET", `/v1/users/me/notifications`, query);
+  }
+
+  /** Mark a notification as read. PUT /v1/users/me/notifications/:id/read */
+  markNotificationRead(id: number): Promise<MessageResponse> {
+    return this.request("PUT", `/v1/users/me/notifications/${encodeURIComponent(String(id))}/read`, undefined);
+  }
+
+  /** Fetch the user's notification preferences. GET /v1/users/me/notification-preferences */
+  getNotificationPreferences(): Promise<PreferencesResponse> {
+    return this.request("GET", `/v1/users/me/notification-preferences`, undefined);
+  }
+
+  /** Change the user's notification preferences. PUT /v1/users/me/notification-preferences */
+  updateNotificationPreferences(input: Record<string, ChannelPreferences>): Promise<PreferencesResponse> {
+    return this.request("PUT", `/v1/users/me/notification-preferences`, undefined, JSON.stringify(input));
+  }
+
+  /** List the user's saved searches. GET /v1/users/me/saved-searches */
+  listSavedSearches(): Promise<SavedSearchListResponse> {
+    return this.request("GET", `/v1/users/me/saved-searches`, undefined);
+  }
+
+  /** Save a search to be alerted about. POST /v1/users/me/saved-searches */
+  createSavedSearch(input: SavedSearchInput): Promise<SavedSearchResponse> {
+    return this.request("POST", `/v1/users/me/saved-searches`, undefined, JSON.stringify(input));
+  }
+
+  /** Delete a saved search. DELETE /v1/users/me/saved-searches/:id */
+  deleteSavedSearch(id: number): Promise<MessageResponse> {
+    return this.request("DELETE", `/v1/users/me/saved-searches/${encodeURIComponent(String(id))}`, undefined);
+  }
+
+  /** Fetch the user's digest subscription. GET /v1/users/me/digest */
+  showDigestSubscription(): Promise<DigestSubscriptionResponse> {
+    return this.request("GET", `/v1/users/me/digest`, undefined);
+  }
+
+  /** Subscribe to the digest emails of new movies in the given genres, or change the subscription. PUT /v1/users/me/digest */
+  updateDigestSubscription(input: DigestSubscriptionInput): Promise<DigestSubscriptionResponse> {
+    return this.request("PUT", `/v1/users/me/digest`, undefined, JSON.stringify(input));
+  }
+
+  /** Unsubscribe from the digest emails. DELETE /v1/users/me/digest */
+  deleteDigestSubscription(): Promise<MessageResponse> {
+    return this.request("DELETE", `/v1/users/me/digest`, undefined);
+  }
+
+  /** Accept the current version of the terms of service, which is required before using the API when the deployment has terms. POST /v1/users/me/tos-acceptance */
+  acceptTOS(input: TOSAcceptanceInput): Promise<TOSAcceptanceResponse> {
+    return this.request("POST", `/v1/users/me/tos-acceptance`, undefined, JSON.stringify(input));
+  }
+
+  /** List the user's service accounts. GET /v1/users/me/service-accounts */
+  listServiceAccounts(): Promise<ServiceAccountListResponse> {
+    return this.request("GET", `/v1/users/me/service-accounts`, undefined);
+  }
+
+  /** Create a service account, returning its key. POST /v1/users/me/service-accounts */
+  createServiceAccount(input: ServiceAccountInput): Promise<ServiceAccountKeyResponse> {
+    return this.request("POST", `/v1/users/me/service-accounts`, undefined, JSON.stringify(input));
+  }
+
+  /** Fetch one of the user's service accounts. GET /v1/users/me/service-accounts/:id */
+  showServiceAccount(id: number): Promise<ServiceAccountResponse> {
+    return this.request("GET", `/v1/users/me/service-accounts/${encodeURIComponent(String(id))}`, undefined);
+  }
+
+  /** Change some of a service account's fields. PATCH /v1/users/me/service-accounts/:id */
+  updateServiceAccount(id: number, input: ServiceAccountUpdate): Promise<ServiceAccountResponse> {
+    return this.request("PATCH", `/v1/users/me/service-accounts/${encodeURIComponent(String(id))}`, undefined, JSON.stringify(input));
+  }
+
+  /** Replace a service account's key, returning the new one. POST /v1/users/me/service-accounts/:id/key */
+  rotateServiceAccountKey(id: number): Promise<ServiceAccountKeyResponse> {
+    return this.request("POST", `/v1/users/me/service-accounts/${encodeURIComponent(String(id))}/key`, undefined);
+  }
+
+  /** Delete a service account. DELETE /v1/users/me/service-accounts/:id */
+  deleteServiceAccount(id: number): Promise<MessageResponse> {
+    return this.request("DELETE", `/v1/users/me/service-accounts/${encodeURIComponent(String(id))}`, undefined);
+  }
+
+  /** Send a new activation token to a user. POST /v1/tokens/activation */
+  createActivationToken(input: EmailInput): Promise<MessageResponse> {
+    return this.request("POST", `/v1/tokens/activation`, undefined, JSON.stringify(input));
+  }
+
+  /** Send a password reset token to a user. POST /v1/tokens/password-reset */
+  createPasswordResetToken(input: EmailInput): Promise<MessageResponse> {
+    return this.request("POST", `/v1/tokens/password-reset`, undefined, JSON.stringify(input));
+  }
+
+  /** Exchange a user's credentials for an authentication token. POST /v1/tokens/authentication */
+  createAuthenticationToken(input: CredentialsInput): Promise<TokenResponse> {
+    return this.request("POST", `/v1/tokens/authentication`, undefined, JSON.stringify(input));
+  }
+
+  /** Start a guest session, returning a read-only token for browsing the catalog. POST /v1/tokens/guest */
+  createGuestToken(): Promise<GuestTokenResponse> {
+    return this.request("POST", `/v1/tokens/guest`, undefined);
+  }
+
+  /** Get a short-lived token acting as a user, for reproducing the issues they report. POST /v1/admin/impersonate/:id */
+  impersonateUser(id: number): Promise<ImpersonationTokenResponse> {
+    return this.request("POST", `/v1/admin/impersonate/${encodeURIComponent(String(id))}`, undefined);
+  }
+
+  /** Fetch the figures shown on the ops dashboard. GET /v1/admin/stats */
+  adminStats(query?: Record<string, string>): Promise<AdminStatsResponse> {
+    return this.request("GET", `/v1/admin/stats`, query);
+  }
+
+  /** Fetch a user along with the delivery status of their email address. GET /v1/admin/users/:id */
+  showUser(id: number): Promise<AdminUserResponse> {
+    return this.request("GET", `/v1/admin/users/${encodeURIComponent(String(id))}`, undefined);
+  }
+
+  /** Fetch the current metrics and request rates. GET /v1/admin/metrics */
+  showMetrics(): Promise<MetricsResponse> {
+    return this.request("GET", `/v1/admin/metrics`, undefined);
+  }
+
+  /** Store the current metrics in the database. POST /v1/admin/metrics/snapshots */
+  createMetricSnapshot(input: MetricSnapshotInput): Promise<MetricSnapshotResponse> {
+    return this.request("POST", `/v1/admin/metrics/snapshots`, undefined, JSON.stringify(input));
+  }
+
+  /** List the stored metric snapshots, newest first. GET /v1/admin/metrics/snapshots */
+  listMetricSnapshots(query?: Record<string, string>): Promise<MetricSnapshotListResponse> {
+    return this.request("GET", `/v1/admin/metrics/snapshots`, query);
+  }
+
+  /** Reset the in-memory metric counters. POST /v1/admin/metrics/reset */
+  resetMetrics(): Promise<MessageResponse> {
+    return this.request("POST", `/v1/admin/metrics/reset`, undefined);
+  }
+
+  /** List the banned IP addresses. GET /v1/admin/bans */
+  listBans(): Promise<BanListResponse> {
+    return this.request("GET", `/v1/admin/bans`, undefined);
+  }
+
+  /** Lift the ban on an IP address. DELETE /v1/admin/bans/:ip */
+  deleteBan(ip: string): Promise<MessageResponse> {
+    return this.request("DELETE", `/v1/admin/bans/${encodeURIComponent(String(ip))}`, undefined);
+  }
+
+  /** List the clients tracked by the rate limiter of the instance handling the request. GET /v1/admin/rate-limits */
+  listRateLimits(): Promise<RateLimitListResponse> {
+    return this.request("GET", `/v1/admin/rate-limits`, undefined);
+  }
+
+  /** Reset a client's rate limit on the instance handling the request. DELETE /v1/admin/rate-limits/:key */
+  deleteRateLimit(key: string): Promise<MessageResponse> {
+    return this.request("DELETE", `/v1/admin/rate-limits/${encodeURIComponent(String(key))}`, undefined);
+  }
+
+  /** Queue a backup of the database. POST /v1/admin/backups */
+  createBackup(): Promise<MessageResponse> {
+    return this.request("POST", `/v1/admin/backups`, undefined);
+  }
+
+  /** List the database backups, newest first. GET /v1/admin/backups */
+  listBackups(query?: Record<string, string>): Promise<BackupListResponse> {
+    return this.request("GET", `/v1/admin/backups`, query);
+  }
+
+  /** Queue an online schema change. POST /v1/admin/operations */
+  createOperation(input: OperationInput): Promise<OperationResponse> {
+    return this.request("POST", `/v1/admin/operations`, undefined, JSON.stringify(input));
+  }
+
+  /** List the schema operations, newest first. GET /v1/admin/operations */
+  listOperations(query?: Record<string, string>): Promise<OperationListResponse> {
+    return this.request("GET", `/v1/admin/operations`, query);
+  }
+
+  /** Fetch a schema operation and its progress. GET /v1/admin/operations/:id */
+  getOperation(id: number): Promise<OperationResponse> {
+    return this.request("GET", `/v1/admin/operations/${encodeURIComponent(String(id))}`, undefined);
+  }
+}
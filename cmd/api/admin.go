@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// The expvar variables included in the admin stats as the request metrics snapshot
+var adminStatsMetrics = []string{
+	"total_requests_received",
+	"total_responses_sent",
+	"total_processing_time_μs",
+	"total_responses_sent_by_status",
+	"goroutines",
+	"database",
+	"database_pool_health",
+}
+
+// Define an adminStatsCache type which keeps the last admin stats response for a short
+// while, so that dashboards refreshing every few seconds don't each run the count
+// queries
+type adminStatsCache struct {
+	mutex   sync.Mutex
+	days    int
+	env     envelope
+	expires time.Time
+}
+
+// Return the cached stats for the number of days, calling fetch and caching its result
+// for the given ttl if there aren't fresh ones. Errors are never cached.
+func (c *adminStatsCache) get(days int, ttl time.Duration, fetch func() (envelope, error)) (envelope, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.env != nil && c.days == days && time.Now().Before(c.expires) {
+		return c.env, nil
+	}
+
+	env, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl > 0 {
+		c.days = days
+		c.env = env
+		c.expires = time.Now().Add(ttl)
+	}
+
+	return env, nil
+}
+
+// Handler for the "GET /v1/admin/stats" endpoint, which gathers the figures shown on
+// the ops dashboard. The stats are cached for a short while, and the generated_at field
+// tells the dashboard how old they are.
+func (app *application) adminStatsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	days := app.readInt(r.URL.Query(), "days", 30, v)
+
+	v.Check(days > 0, "days", "must be greater than zero", validator.CodeTooSmall, validator.Params{"min": 1})
+	v.Check(days <= 90, "days", "must be a maximum of 90", validator.CodeTooLarge, validator.Params{"max": 90})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	env, err := app.adminStats.get(days, app.config.cache.statsTTL, func() (envelope, error) {
+		stats, err := app.models.AdminStats.Get(r.Context(), days)
+		if err != nil {
+			return nil, err
+		}
+
+		env := envelope{
+			"generated_at": time.Now(),
+			"users":        stats.Users,
+			"movies":       stats.Movies,
+			"reviews":      stats.Reviews,
+			"requests":     readMetricsSnapshot(),
+		}
+
+		if app.jobs != nil {
+			queue, err := app.jobs.Stats(r.Context(), emailJob{}.Kind())
+			if err != nil {
+				return nil, err
+			}
+
+			env["mailer_queue"] = queue
+		}
+
+		if app.events != nil {
+			outbox, err := app.events.Stats(r.Context())
+			if err != nil {
+				return nil, err
+			}
+
+			env["events_outbox"] = outbox
+		}
+
+		return env, nil
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/admin/users/:id" endpoint, which shows a user along with the
+// delivery status of their email address, so that support can tell why they aren't
+// receiving our emails
+func (app *application) showUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeUserNotFound)
+		return
+	}
+
+	user, err := app.models.User.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeUserNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	status, err := app.models.User.GetEmailStatus(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user, "email_status": status}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Return the current values of the request metrics published to expvar
+func readMetricsSnapshot() map[string]json.RawMessage {
+	snapshot := make(map[string]json.RawMessage)
+
+	for _, name := range adminStatsMetrics {
+		if value := expvar.Get(name); value != nil {
+			snapshot[name] = json.RawMessage(value.String())
+		}
+	}
+
+	return snapshot
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/metrics"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// The expvar variables left out of metric snapshots, as they are large and describe
+// the process rather than the traffic it served
+var snapshotExcludedVars = []string{"cmdline", "memstats"}
+
+// Define a metricsSample struct holding the request counters at a point in time
+type metricsSample struct {
+	at               time.Time
+	requests         int64
+	responses        int64
+	processingMicros int64
+	serverErrors     int64
+}
+
+// Read the current values of the request counters
+func takeMetricsSample(now time.Time) metricsSample {
+	sample := metricsSample{
+		at:               now,
+		requests:         totalRequestsReceived.Value(),
+		responses:        totalResponsesSent.Value(),
+		processingMicros: totalProcessingTimeMicroseconds.Value(),
+	}
+
+	totalResponsesSentByStatus.Do(func(kv expvar.KeyValue) {
+		if count, ok := kv.Value.(*expvar.Int); ok && strings.HasPrefix(kv.Key, "5") {
+			sample.serverErrors += count.Value()
+		}
+	})
+
+	return sample
+}
+
+// Define a metricsRates struct holding the request rates derived from the samples
+type metricsRates struct {
+	WindowSeconds         float64 `json:"window_seconds"` // The time between the oldest and newest samples
+	RequestsPerSecond     float64 `json:"requests_per_second"`
+	ResponsesPerSecond    float64 `json:"responses_per_second"`
+	AverageResponseMillis float64 `json:"average_response_ms"`
+	ServerErrorRate       float64 `json:"server_error_rate"` // The fraction of responses with a 5xx status code
+}
+
+// Define a rateSampler type which keeps samples of the request counters over a sliding
+// window, so that rates can be derived from the cumulative counters
+type rateSampler struct {
+	mutex   sync.Mutex
+	window  time.Duration
+	samples []metricsSample
+}
+
+// Return a new, empty rateSampler with the given window
+func newRateSampler(window time.Duration) *rateSampler {
+	return &rateSampler{window: window}
+}
+
+// Add a sample, dropping those which have fallen out of the window
+func (s *rateSampler) add(sample metricsSample) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.samples = append(s.samples, sample)
+
+	cutoff := sample.at.Add(-s.window)
+	for len(s.samples) > 1 && s.samples[0].at.Before(cutoff) {
+		s.samples = s.samples[1:]
+	}
+}
+
+// Drop all samples. This is called when the counters are reset, as the rates would
+// otherwise be negative until the window has passed.
+func (s *rateSampler) clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.samples = nil
+}
+
+// Return the rates between the oldest and newest samples in the window. The rates are
+// zero until there are at least two samples.
+func (s *rateSampler) rates() metricsRates {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var rates metricsRates
+
+	if len(s.samples) < 2 {
+		return rates
+	}
+
+	first, last := s.samples[0], s.samples[len(s.samples)-1]
+
+	rates.WindowSeconds = last.at.Sub(first.at).Seconds()
+	if rates.WindowSeconds <= 0 {
+		return rates
+	}
+
+	responses := last.responses - first.responses
+
+	rates.RequestsPerSecond = float64(last.requests-first.requests) / rates.WindowSeconds
+	rates.ResponsesPerSecond = float64(responses) / rates.WindowSeconds
+
+	if responses > 0 {
+		rates.AverageResponseMillis = float64(last.processingMicros-first.processingMicros) / float64(responses) / 1000
+		rates.ServerErrorRate = float64(last.serverErrors-first.serverErrors) / float64(responses)
+	}
+
+	return rates
+}
+
+// Sample the request counters at the given interval until the application shuts down
+func (app *application) startMetricsSampler(interval time.Duration) {
+	app.sampler.add(takeMetricsSample(time.Now()))
+
+	app.background("sample_metrics", func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				app.sampler.add(takeMetricsSample(now))
+			case <-app.shutdown:
+				return
+			}
+		}
+	})
+}
+
+// Return the current values of the expvar variables, other than the excluded ones
+func readAllMetrics() map[string]json.RawMessage {
+	values := make(map[string]json.RawMessage)
+
+	expvar.Do(func(kv expvar.KeyValue) {
+		for _, excluded := range snapshotExcludedVars {
+			if kv.Key == excluded {
+				return
+			}
+		}
+
+		values[kv.Key] = json.RawMessage(kv.Value.String())
+	})
+
+	return values
+}
+
+// Reset the request counters, the background task panic counts, the shadow validation
+// failure counts and the histograms. Requests which are in flight at the time are
+// counted as responses afterwards, so the counters can briefly disagree by that many.
+func (app *application) resetMetrics() {
+	totalRequestsReceived.Set(0)
+	totalResponsesSent.Set(0)
+	totalProcessingTimeMicroseconds.Set(0)
+	totalResponsesSentByStatus.Init()
+	totalRequestsOverloaded.Set(0)
+	totalRequestsCancelled.Set(0)
+	totalReadsSticky.Set(0)
+	backgroundTasksPanicked.Init()
+	validationShadowFailures.Init()
+
+	metrics.Do(func(h *metrics.HistogramVec) {
+		h.Reset()
+	})
+
+	app.sampler.clear()
+}
+
+// Handler for the "GET /v1/admin/metrics" endpoint, which returns the current metrics
+// along with the request rates over the sampling window
+func (app *application) showMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	env := envelope{
+		"metrics": readAllMetrics(),
+		"rates":   app.sampler.rates(),
+	}
+
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "POST /v1/admin/metrics/snapshots" endpoint, which stores the current
+// metrics in the database. The counters can be reset once the snapshot is stored, to
+// start a new window such as after a deploy. Requests served between the two aren't
+// included in either window.
+func (app *application) createMetricSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Label string `json:"label"`
+		Reset bool   `json:"reset"`
+	}
+
+	// The body can be left out altogether for an unlabelled snapshot
+	if r.ContentLength != 0 {
+		err := app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	instance, err := os.Hostname()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	values, err := json.Marshal(readAllMetrics())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	snapshot := &data.MetricSnapshot{
+		Label:    input.Label,
+		Instance: instance,
+		Metrics:  values,
+	}
+
+	v := validator.New()
+
+	if data.ValidateMetricSnapshot(v, snapshot); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.MetricSnapshots.Insert(r.Context(), snapshot)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if input.Reset {
+		app.resetMetrics()
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"snapshot": snapshot}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/admin/metrics/snapshots" endpoint
+func (app *application) listMetricSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	filters := app.readListingFilters(r.URL.Query(), metricSnapshotListing, v)
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	snapshots, metadata, err := app.models.MetricSnapshots.GetAll(r.Context(), filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePage(w, r, "snapshots", snapshots, metadata)
+}
+
+// Handler for the "POST /v1/admin/metrics/reset" endpoint
+func (app *application) resetMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	app.resetMetrics()
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"message": "metrics successfully reset"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
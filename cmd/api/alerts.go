@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/circuit"
+	"github.com/LuisBarroso37/Greenlight/internal/notify"
+)
+
+// The number of responses the request rates must be derived from before the error
+// rate is alerted on, so that a single failed request on an idle instance doesn't
+// count as a 100% error rate
+const alertMinResponses = 20
+
+// Define an alert struct holding an operational problem to tell the ops team about.
+// The title identifies the kind of problem for the cooldown.
+type alert struct {
+	title   string
+	message string
+}
+
+// Define an alertMonitor type which compares the metrics with the alert thresholds at
+// each check. The counters only climb, so the monitor keeps their values from the
+// previous check to work out what happened since. The cooldowns are per instance, so
+// each instance of the application can send its own alert for the same problem.
+type alertMonitor struct {
+	pool *poolMonitor
+	smtp *circuit.Breaker
+
+	prevSample       metricsSample
+	prevEmailFailed  int
+	checkedEmails    bool
+	prevPoolChecked  int64
+	prevPingFailures int64
+	lastSent         map[string]time.Time
+}
+
+// Return a new alertMonitor watching the given pool monitor and SMTP circuit breaker
+func newAlertMonitor(pool *poolMonitor, smtp *circuit.Breaker) *alertMonitor {
+	return &alertMonitor{
+		pool:       pool,
+		smtp:       smtp,
+		prevSample: takeMetricsSample(time.Now()),
+		lastSent:   make(map[string]time.Time),
+	}
+}
+
+// Compare the metrics with the alert thresholds, returning the alerts which are due
+func (app *application) checkAlerts(ctx context.Context, monitor *alertMonitor, now time.Time) ([]alert, error) {
+	var alerts []alert
+
+	thresholds := app.config.alerts
+
+	rates := app.sampler.rates()
+	responses := rates.ResponsesPerSecond * rates.WindowSeconds
+
+	if thresholds.errorRate > 0 && responses >= alertMinResponses && rates.ServerErrorRate >= thresholds.errorRate {
+		alerts = append(alerts, alert{
+			title: "High error rate",
+			message: fmt.Sprintf("%.1f%% of the responses over the last %s were server errors, over the %.1f%% threshold.",
+				rates.ServerErrorRate*100, time.Duration(rates.WindowSeconds*float64(time.Second)), thresholds.errorRate*100),
+		})
+	}
+
+	// The counters go back to zero when the metrics are reset, which is counted as no
+	// server errors rather than a negative number
+	sample := takeMetricsSample(now)
+	serverErrors := sample.serverErrors - monitor.prevSample.serverErrors
+	if serverErrors < 0 {
+		serverErrors = 0
+	}
+
+	monitor.prevSample = sample
+
+	if thresholds.serverErrors > 0 && serverErrors >= int64(thresholds.serverErrors) {
+		alerts = append(alerts, alert{
+			title:   "Server error spike",
+			message: fmt.Sprintf("%d server errors were sent since the previous check, %s ago.", serverErrors, thresholds.interval),
+		})
+	}
+
+	if monitor.smtp.Stats().Open == 1 {
+		alerts = append(alerts, alert{
+			title:   "Email delivery failing",
+			message: "The circuit breaker for the SMTP server is open, so emails are failing fast until the server recovers. As this email was queued like any other, it may only arrive once the server has recovered.",
+		})
+	}
+
+	stats, err := app.jobs.Stats(ctx, emailJob{}.Kind())
+	if err != nil {
+		return alerts, err
+	}
+
+	emailFailed := stats.Failed - monitor.prevEmailFailed
+	checkedEmails := monitor.checkedEmails
+
+	monitor.prevEmailFailed = stats.Failed
+	monitor.checkedEmails = true
+
+	// The first check only records the count, as the failures could be from long ago
+	if thresholds.emailFailures > 0 && checkedEmails && emailFailed >= thresholds.emailFailures {
+		alerts = append(alerts, alert{
+			title:   "Emails failing",
+			message: fmt.Sprintf("%d emails ran out of attempts since the previous check, %s ago.", emailFailed, thresholds.interval),
+		})
+	}
+
+	// Only look at the pool monitor's figures when it has checked the pool since the
+	// previous alert check, so that one saturated interval isn't counted twice
+	health := monitor.pool.Health()
+
+	if thresholds.databasePool && health.LastChecked != monitor.prevPoolChecked {
+		averageWait := time.Duration(health.AverageWaitMillis * float64(time.Millisecond))
+
+		switch {
+		case health.PingFailures > monitor.prevPingFailures && monitor.prevPoolChecked != 0:
+			alerts = append(alerts, alert{
+				title:   "Database unreachable",
+				message: "The database connection pool failed to ping the database.",
+			})
+		case health.WaitsPerInterval > 0 && averageWait >= monitor.pool.waitThreshold:
+			alerts = append(alerts, alert{
+				title: "Database connection pool saturated",
+				message: fmt.Sprintf("%d queries waited %s on average for a database connection over the last %s.",
+					health.WaitsPerInterval, averageWait, time.Duration(health.IntervalSeconds*float64(time.Second))),
+			})
+		}
+	}
+
+	monitor.prevPoolChecked = health.LastChecked
+	monitor.prevPingFailures = health.PingFailures
+
+	return alerts, nil
+}
+
+// Email the ops address and post to the chat webhooks about an alert, unless an alert
+// with the same title was sent within the cooldown. The email is queued like any other,
+// so alerts sent while the SMTP server is failing arrive once it recovers.
+func (app *application) sendAlert(ctx context.Context, monitor *alertMonitor, a alert, now time.Time) error {
+	if last, ok := monitor.lastSent[a.title]; ok && now.Sub(last) < app.config.alerts.cooldown {
+		return nil
+	}
+
+	app.notifyOps(notify.Message{
+		Level: notify.LevelWarning,
+		Title: a.title,
+		Text:  a.message,
+	})
+
+	monitor.lastSent[a.title] = now
+
+	app.logger.PrintInfo("alert sent", map[string]string{"alert": a.title})
+
+	if app.config.alerts.email == "" {
+		return nil
+	}
+
+	instance, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	return app.sendEmail(ctx, app.config.alerts.email, "alert.tmpl", map[string]interface{}{
+		"env":      app.config.env,
+		"title":    a.title,
+		"message":  a.message,
+		"instance": instance,
+		"time":     now.Format(time.RFC1123),
+		"cooldown": app.config.alerts.cooldown.String(),
+	})
+}
+
+// The startAlertMonitor() method checks the metrics against the alert thresholds at the
+// given interval until the application shuts down
+func (app *application) startAlertMonitor(monitor *alertMonitor, interval time.Duration) {
+	app.background("monitor_alerts", func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+
+				alerts, err := app.checkAlerts(ctx, monitor, now)
+				if err != nil {
+					app.logger.PrintError(err, map[string]string{"task": "monitor_alerts"})
+				}
+
+				for _, a := range alerts {
+					err = app.sendAlert(ctx, monitor, a, now)
+					if err != nil {
+						app.logger.PrintError(err, map[string]string{"task": "monitor_alerts", "alert": a.title})
+					}
+				}
+
+				cancel()
+			case <-app.shutdown:
+				return
+			}
+		}
+	})
+}
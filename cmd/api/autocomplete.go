@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Queries shorter than this many characters match too many titles to be useful, and
+// can't use the trigram index, so no suggestions are returned for them
+const minAutocompleteLength = 2
+
+// Define a cached list of title suggestions along with the time at which it expires
+type suggestionEntry struct {
+	suggestions []*data.MovieSuggestion
+	expires     time.Time
+}
+
+// Define a suggestionCache type which keeps the title suggestions for recent queries in
+// memory. Many users type the same first few letters, so most keystrokes are answered
+// from the cache. Unlike the movie list cache the keys come from users, so the number of
+// entries is bounded.
+type suggestionCache struct {
+	ttl        time.Duration
+	maxEntries int
+	mutex      sync.Mutex
+	entries    map[string]suggestionEntry
+}
+
+// Return a new suggestionCache which holds up to maxEntries queries for the given ttl
+func newSuggestionCache(ttl time.Duration, maxEntries int) *suggestionCache {
+	return &suggestionCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]suggestionEntry),
+	}
+}
+
+// Return the cached suggestions for a key, if there is an unexpired entry
+func (c *suggestionCache) get(key string) ([]*data.MovieSuggestion, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return entry.suggestions, true
+}
+
+// Store the suggestions for a key. When the cache is full, expired entries are removed
+// first, and if that doesn't free up any space the cache is cleared.
+func (c *suggestionCache) set(key string, suggestions []*data.MovieSuggestion) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		now := time.Now()
+
+		for key, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, key)
+			}
+		}
+
+		if len(c.entries) >= c.maxEntries {
+			c.entries = make(map[string]suggestionEntry)
+		}
+	}
+
+	c.entries[key] = suggestionEntry{suggestions: suggestions, expires: time.Now().Add(c.ttl)}
+}
+
+// Handler for the "GET /v1/movies/autocomplete" endpoint, which suggests movie titles as
+// the user types. It is called on every keystroke, so suggestions are cached and the
+// query is given a strict time budget. A query which runs out of time returns no
+// suggestions rather than an error, as the next keystroke will ask again.
+func (app *application) autocompleteMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	queryString := r.URL.Query()
+
+	query := strings.TrimSpace(app.readString(queryString, "q", ""))
+	limit := app.readInt(queryString, "limit", 10, v)
+
+	v.Check(len(query) <= 100, "q", "must not be more than 100 bytes long", validator.CodeTooLong, validator.Params{"max": 100})
+	v.Check(limit > 0, "limit", "must be greater than zero", validator.CodeTooSmall, validator.Params{"min": 1})
+	v.Check(limit <= 20, "limit", "must be a maximum of 20", validator.CodeTooLarge, validator.Params{"max": 20})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	suggestions := []*data.MovieSuggestion{}
+
+	if utf8.RuneCountInString(query) >= minAutocompleteLength {
+		// Titles are matched ignoring case, so queries differing only in case share
+		// a cache entry
+		key := strconv.Itoa(limit) + ":" + strings.ToLower(query)
+
+		cached, found := app.suggestions.get(key)
+		if found {
+			suggestions = cached
+		} else {
+			ctx := r.Context()
+
+			if app.config.autocomplete.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, app.config.autocomplete.timeout)
+				defer cancel()
+			}
+
+			// The driver reports a cancelled query in different ways depending on
+			// when the deadline passed, so the context is checked rather than the
+			// error
+			results, err := app.models.Movie.Autocomplete(ctx, query, limit)
+			switch {
+			case err == nil:
+				suggestions = results
+				app.suggestions.set(key, results)
+			case errors.Is(ctx.Err(), context.DeadlineExceeded) && r.Context().Err() == nil:
+				// Out of time, so no suggestions are sent (or cached)
+			default:
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+	}
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(app.config.autocomplete.cacheTTL.Seconds())))
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
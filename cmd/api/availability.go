@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
+	"github.com/LuisBarroso37/Greenlight/internal/providers"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// The number of consecutive movies whose availability can fail to refresh before a run
+// gives up, as the watch-provider API is then most likely unavailable
+const availabilityMaxFailures = 10
+
+// Define an availabilityRefreshJob struct for the recurring job which fetches the
+// streaming availability of movies from the watch-provider API. It has no payload, as
+// it goes through every movie whose availability is out of date.
+type availabilityRefreshJob struct{}
+
+func (availabilityRefreshJob) Kind() string {
+	return "availability_refresh"
+}
+
+// Schedule the next run of the availability refresh job. Only one run is ever pending,
+// however many instances of the application schedule it.
+func (app *application) scheduleAvailabilityRefresh(ctx context.Context, runAt time.Time) error {
+	return app.jobs.Enqueue(ctx, availabilityRefreshJob{}, jobs.EnqueueOptions{
+		RunAt:  runAt,
+		Unique: true,
+	})
+}
+
+// Fetch the availability of every movie which hasn't been refreshed within the
+// availability TTL. The next run is scheduled first, so that the job keeps recurring
+// even if this run fails. Movies which fail to refresh are logged and retried on the
+// next run, unless so many fail in a row that the API looks to be down. Runs left in
+// the queue after the API key has been removed do nothing.
+func (app *application) refreshAvailability(ctx context.Context, _ availabilityRefreshJob) error {
+	if app.providers == nil {
+		return nil
+	}
+
+	if app.config.availability.refreshInterval > 0 {
+		err := app.scheduleAvailabilityRefresh(ctx, time.Now().Add(app.config.availability.refreshInterval))
+		if err != nil {
+			return err
+		}
+	}
+
+	refreshedBefore := time.Now().Add(-app.config.availability.ttl)
+
+	var afterID int64
+	failures := 0
+
+	for {
+		refreshes, err := app.models.Availability.GetBatchForRefresh(ctx, refreshedBefore, afterID, 100)
+		if err != nil {
+			return err
+		}
+
+		if len(refreshes) == 0 {
+			return nil
+		}
+
+		for _, refresh := range refreshes {
+			afterID = refresh.MovieID
+
+			err = app.refreshMovieAvailability(ctx, refresh)
+			if err != nil {
+				failures++
+
+				if failures >= availabilityMaxFailures {
+					return fmt.Errorf("refreshing availability failed for %d movies in a row: %w", failures, err)
+				}
+
+				app.logger.PrintError(err, map[string]string{
+					"component": "availability",
+					"movie_id":  fmt.Sprint(refresh.MovieID),
+				})
+
+				continue
+			}
+
+			failures = 0
+		}
+	}
+}
+
+// Fetch and store the availability of a single movie. The movie is looked up in the
+// watch-provider API the first time, and the API's ID is stored for later refreshes.
+// Movies the API doesn't know about are stored without any availability, so that they
+// aren't looked up again until the TTL has passed.
+func (app *application) refreshMovieAvailability(ctx context.Context, refresh *data.AvailabilityRefresh) error {
+	providerMovieID := refresh.ProviderMovieID
+
+	if providerMovieID == 0 {
+		id, err := app.providers.FindMovie(ctx, refresh.Title, refresh.Year)
+		if err != nil {
+			switch {
+			case errors.Is(err, providers.ErrNotFound):
+				return app.storeAvailability(ctx, refresh.MovieID, 0, nil)
+			default:
+				return err
+			}
+		}
+
+		providerMovieID = id
+	}
+
+	availability, err := app.providers.Availability(ctx, providerMovieID)
+	if err != nil {
+		switch {
+		// The API's ID for the movie is no longer valid, so look it up again next time
+		case errors.Is(err, providers.ErrNotFound):
+			return app.storeAvailability(ctx, refresh.MovieID, 0, nil)
+		default:
+			return err
+		}
+	}
+
+	countries := make(map[string]data.CountryAvailability, len(availability))
+
+	for country, countryAvailability := range availability {
+		offers := make([]data.StreamingOffer, len(countryAvailability.Offers))
+		for i, offer := range countryAvailability.Offers {
+			offers[i] = data.StreamingOffer{
+				Type:         offer.Type,
+				ProviderID:   offer.ProviderID,
+				ProviderName: offer.ProviderName,
+				LogoURL:      offer.LogoURL,
+			}
+		}
+
+		countries[country] = data.CountryAvailability{Link: countryAvailability.Link, Offers: offers}
+	}
+
+	return app.storeAvailability(ctx, refresh.MovieID, providerMovieID, countries)
+}
+
+// Store the availability of a movie, ignoring movies which were deleted while their
+// availability was being fetched
+func (app *application) storeAvailability(ctx context.Context, movieID, providerMovieID int64, countries map[string]data.CountryAvailability) error {
+	err := app.models.Availability.Set(ctx, movieID, providerMovieID, countries)
+	if errors.Is(err, data.ErrRecordNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// Handler for the "GET /v1/movies/:id/availability" endpoint. Availability is served
+// from the table kept up to date by the refresh job, so a movie which hasn't been
+// refreshed yet has no offers and a null refreshed_at.
+func (app *application) showMovieAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	v := validator.New()
+
+	country := app.readString(r.URL.Query(), "country", "")
+
+	v.Check(country != "", "country", "must be provided", validator.CodeRequired)
+	v.Check(validator.Matches(country, data.CountryRegex), "country", "must be an uppercase ISO 3166-1 alpha-2 country code", validator.CodeInvalidFormat, validator.Params{"format": "ISO 3166-1 alpha-2"})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	movie, err := app.models.Movie.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	visible, err := app.movieVisible(r, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !visible {
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	availability, err := app.models.Availability.GetForCountry(r.Context(), id, country)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"availability": availability}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
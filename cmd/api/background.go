@@ -0,0 +1,56 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/LuisBarroso37/Greenlight/internal/errtrack"
+)
+
+// Publish the number of background tasks currently running and the number which have
+// panicked, both broken down by task name
+var (
+	backgroundTasksInFlight = expvar.NewMap("background_tasks_in_flight")
+	backgroundTasksPanicked = expvar.NewMap("background_tasks_panicked")
+)
+
+// The background() helper runs a function in a background goroutine, such as sending
+// an email after the response has been written. The name identifies the task in logs
+// and metrics. A panic in the function is recovered, logged with its stack trace and
+// reported to the error tracker, so that it can't take the whole process down. The
+// application waits for running tasks to finish when it shuts down.
+func (app *application) background(name string, fn func()) {
+	// Increment the WaitGroup counter
+	app.wg.Add(1)
+
+	backgroundTasksInFlight.Add(name, 1)
+
+	// Launch a background goroutine.
+	go func() {
+		// Use defer to decrement the WaitGroup counter before the goroutine returns
+		defer app.wg.Done()
+		defer backgroundTasksInFlight.Add(name, -1)
+
+		// Recover any panic. The deferred function runs before the stack is unwound, so
+		// the stack trace still shows where the panic happened.
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err := fmt.Errorf("background task %s panicked: %v", name, recovered)
+
+				backgroundTasksPanicked.Add(name, 1)
+
+				app.logger.PrintError(err, map[string]string{
+					"task":  name,
+					"stack": string(debug.Stack()),
+				})
+
+				app.tracker.Capture(err, errtrack.Context{}, 0)
+				app.notifyPanic("Background task panicked", recovered, map[string]string{"task": name})
+			}
+		}()
+
+		// Execute the arbitrary function that we passed as the parameter.
+		fn()
+	}()
+}
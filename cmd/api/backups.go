@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/backup"
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Define a backupJob struct for the job which backs up the database when an admin asks
+// for a backup. It has no payload.
+type backupJob struct{}
+
+func (backupJob) Kind() string {
+	return "backup"
+}
+
+// Back up the database from a job. Backups are only attempted once, as a failed dump is
+// recorded with its error and retrying it could just as well fail again part way
+// through.
+func (app *application) runBackupJob(ctx context.Context, _ backupJob) error {
+	_, err := app.backupDatabase(ctx)
+	return err
+}
+
+// Dump the database to the backup storage, recording the backup and its outcome, then
+// delete the backups which are no longer kept. A failure to delete old backups is
+// logged rather than returned, as the new backup has succeeded.
+func (app *application) backupDatabase(ctx context.Context) (*data.Backup, error) {
+	if app.backups == nil {
+		return nil, errors.New("backups are disabled, as no -backup-dir is configured")
+	}
+
+	b := &data.Backup{
+		Name: fmt.Sprintf("greenlight-%s.dump", time.Now().UTC().Format("20060102-150405")),
+	}
+
+	err := app.models.Backups.Insert(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	dumper := backup.Dumper{
+		Command: app.config.backup.pgDump,
+		DSN:     app.config.db.dsn,
+	}
+
+	size, dumpErr := dumper.Dump(ctx, app.backups, b.Name)
+	if dumpErr != nil {
+		b.Error = dumpErr.Error()
+	}
+
+	b.Size = size
+
+	// Record the outcome even if the job's context has run out, which is the usual
+	// reason for a dump to fail part way through
+	finishCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = app.models.Backups.Finish(finishCtx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	if dumpErr != nil {
+		return nil, dumpErr
+	}
+
+	app.logger.PrintInfo("database backed up", map[string]string{
+		"name": b.Name,
+		"size": fmt.Sprint(b.Size),
+	})
+
+	if app.config.backup.keep > 0 {
+		err = app.deleteExpiredBackups(ctx)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"component": "backup"})
+		}
+	}
+
+	return b, nil
+}
+
+// Delete the backups which are no longer kept from the storage, then their records
+func (app *application) deleteExpiredBackups(ctx context.Context) error {
+	expired, err := app.models.Backups.GetExpired(ctx, app.config.backup.keep)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range expired {
+		err = app.backups.Delete(ctx, b.Name)
+		if err != nil {
+			return err
+		}
+
+		err = app.models.Backups.Delete(ctx, b.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handler for the "POST /v1/admin/backups" endpoint, which queues a backup of the
+// database. Only one backup is ever queued, so asking again before it has started
+// doesn't queue another.
+func (app *application) createBackupHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.jobs.Enqueue(r.Context(), backupJob{}, jobs.EnqueueOptions{
+		MaxAttempts: 1,
+		Unique:      true,
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": "backup queued, list the backups to follow its progress"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/admin/backups" endpoint
+func (app *application) listBackupsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	filters := app.readListingFilters(r.URL.Query(), backupListing, v)
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	backups, metadata, err := app.models.Backups.GetAll(r.Context(), filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePage(w, r, "backups", backups, metadata)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/cache"
+	"github.com/felixge/httpsnoop"
+	"github.com/julienschmidt/httprouter"
+	"github.com/tomasen/realip"
+)
+
+// Struct used for holding a temporary ban of a client IP address
+type ban struct {
+	IP      string    `json:"ip"`
+	Reason  string    `json:"reason"`
+	Expires time.Time `json:"expires"`
+}
+
+// Define a banStore interface for storing bans. Bans are kept in Redis when it is
+// configured, so that they are shared between instances and survive restarts, and in
+// memory otherwise.
+type banStore interface {
+	Get(ip string) (*ban, error)
+	Add(b ban) error
+	List() ([]ban, error)
+	Remove(ip string) error
+}
+
+// Define a memoryBanStore type which keeps bans in memory
+type memoryBanStore struct {
+	mutex sync.Mutex
+	bans  map[string]ban
+}
+
+func newMemoryBanStore() *memoryBanStore {
+	return &memoryBanStore{bans: make(map[string]ban)}
+}
+
+// Return the ban for an IP address, or nil if it isn't banned
+func (s *memoryBanStore) Get(ip string) (*ban, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, found := s.bans[ip]
+	if !found {
+		return nil, nil
+	}
+
+	if time.Now().After(b.Expires) {
+		delete(s.bans, ip)
+		return nil, nil
+	}
+
+	return &b, nil
+}
+
+func (s *memoryBanStore) Add(b ban) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.bans[b.IP] = b
+
+	return nil
+}
+
+// Return the bans which haven't expired yet
+func (s *memoryBanStore) List() ([]ban, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bans := make([]ban, 0, len(s.bans))
+	now := time.Now()
+
+	for ip, b := range s.bans {
+		if now.After(b.Expires) {
+			delete(s.bans, ip)
+			continue
+		}
+
+		bans = append(bans, b)
+	}
+
+	return bans, nil
+}
+
+func (s *memoryBanStore) Remove(ip string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.bans, ip)
+
+	return nil
+}
+
+// The prefix of the Redis keys holding bans
+const banKeyPrefix = "greenlight:ban:"
+
+// Define a redisBanStore type which keeps each ban in a Redis key which expires with
+// the ban
+type redisBanStore struct {
+	redis *cache.Redis
+}
+
+// Return the ban for an IP address, or nil if it isn't banned
+func (s redisBanStore) Get(ip string) (*ban, error) {
+	value, err := s.redis.Get(banKeyPrefix + ip)
+	if err != nil {
+		switch {
+		case errors.Is(err, cache.ErrCacheMiss):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	var b ban
+
+	err = json.Unmarshal(value, &b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func (s redisBanStore) Add(b ban) error {
+	value, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	return s.redis.Set(banKeyPrefix+b.IP, value, time.Until(b.Expires))
+}
+
+// Return the current bans. A ban which expires between listing the keys and fetching
+// it is skipped.
+func (s redisBanStore) List() ([]ban, error) {
+	keys, err := s.redis.Keys(banKeyPrefix + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	bans := make([]ban, 0, len(keys))
+
+	for _, key := range keys {
+		b, err := s.Get(strings.TrimPrefix(key, banKeyPrefix))
+		if err != nil {
+			return nil, err
+		}
+
+		if b != nil {
+			bans = append(bans, *b)
+		}
+	}
+
+	return bans, nil
+}
+
+func (s redisBanStore) Remove(ip string) error {
+	return s.redis.Delete(banKeyPrefix + ip)
+}
+
+// The detectAbuse() middleware rejects requests from banned IP addresses, and bans
+// clients which keep getting 429 Too Many Requests or 401 Unauthorized responses: once
+// a client has had the configured number of them within the window, it is banned for
+// the configured duration. Strikes are counted in memory, so each instance of the
+// application counts them separately, but bans are shared through the ban store.
+func (app *application) detectAbuse(next http.Handler) http.Handler {
+	// Define a strikes struct holding the number of strikes for a client in the current
+	// window
+	type strikes struct {
+		count       int
+		windowStart time.Time
+	}
+
+	var (
+		mutex   sync.Mutex
+		clients = make(map[string]*strikes)
+	)
+
+	// Remove the strikes of clients whose window has ended once every minute
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+
+			mutex.Lock()
+
+			for ip, s := range clients {
+				if time.Since(s.windowStart) > app.config.abuse.window {
+					delete(clients, ip)
+				}
+			}
+
+			mutex.Unlock()
+		}
+	}()
+
+	// Record a strike for a client, returning true if it has reached the threshold
+	strike := func(ip string) bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		s, found := clients[ip]
+		if !found || time.Since(s.windowStart) > app.config.abuse.window {
+			s = &strikes{windowStart: time.Now()}
+			clients[ip] = s
+		}
+
+		s.count++
+
+		if s.count < app.config.abuse.threshold {
+			return false
+		}
+
+		delete(clients, ip)
+
+		return true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := realip.FromRequest(r)
+
+		// Ban lookups which fail are logged and the request is let through, so that an
+		// unavailable ban store doesn't take the API down
+		b, err := app.bans.Get(ip)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"component": "bans"})
+		}
+
+		if b != nil {
+			app.bannedResponse(w, r, b)
+			return
+		}
+
+		m := httpsnoop.CaptureMetrics(next, w, r)
+
+		if m.Code != http.StatusTooManyRequests && m.Code != http.StatusUnauthorized {
+			return
+		}
+
+		if !strike(ip) {
+			return
+		}
+
+		b = &ban{
+			IP:      ip,
+			Reason:  "repeated " + strconv.Itoa(m.Code) + " responses",
+			Expires: time.Now().Add(app.config.abuse.banDuration),
+		}
+
+		err = app.bans.Add(*b)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"component": "bans"})
+			return
+		}
+
+		app.logger.PrintInfo("client banned", map[string]string{
+			"ip":      b.IP,
+			"reason":  b.Reason,
+			"expires": b.Expires.Format(time.RFC3339),
+		})
+	})
+}
+
+// Handler for the "GET /v1/admin/bans" endpoint
+func (app *application) listBansHandler(w http.ResponseWriter, r *http.Request) {
+	bans, err := app.bans.List()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Expires.Before(bans[j].Expires) })
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"bans": bans}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "DELETE /v1/admin/bans/:ip" endpoint
+func (app *application) deleteBanHandler(w http.ResponseWriter, r *http.Request) {
+	ip := httprouter.ParamsFromContext(r.Context()).ByName("ip")
+
+	b, err := app.bans.Get(ip)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if b == nil {
+		app.resourceNotFoundResponse(w, r, codeBanNotFound)
+		return
+	}
+
+	err = app.bans.Remove(ip)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logger.PrintInfo("client ban lifted", map[string]string{"ip": ip})
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "ban successfully lifted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
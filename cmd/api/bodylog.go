@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/LuisBarroso37/Greenlight/internal/reqctx"
+	"github.com/felixge/httpsnoop"
+)
+
+// The header which turns on body logging for a single request. It is only honoured for
+// users holding the debug:read permission.
+const debugBodiesHeader = "X-Debug-Log-Bodies"
+
+// Match JSON string values whose key looks like it holds a credential, including a
+// value cut off by the size limit, so that they can be redacted before logging. Keys
+// are matched when they are "key" or end in "_key" (like "api_key"), but not when they
+// merely start with it, like "key_prefix" or "keywords".
+var sensitiveJSONValue = regexp.MustCompile(`(?i)("(?:[^"]*(?:password|token|secret|signature|authorization)[^"]*|(?:[^"]*_)?key)"\s*:\s*)"(?:[^"\\]|\\.)*(?:"|\\?$)`)
+
+// Define a cappedBuffer type which keeps the first limit bytes written to it and counts
+// the rest
+type cappedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+	total int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
+
+// Return the captured bytes with credentials redacted, noting if the body was cut off
+func (b *cappedBuffer) String() string {
+	body := sensitiveJSONValue.ReplaceAllString(b.buf.String(), `$1"[REDACTED]"`)
+
+	if b.total > b.buf.Len() {
+		body += "... (" + strconv.Itoa(b.total) + " bytes in total)"
+	}
+
+	return body
+}
+
+// Define a teeReadCloser type which copies everything read from a request body into a
+// cappedBuffer, so that the body the handler actually read can be logged without
+// buffering it up front
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// The logBodies() middleware logs the request and response bodies, up to the
+// configured size limit and with credentials redacted, along with the request ID. It
+// is meant for troubleshooting client integrations, so it is either enabled for every
+// request with the -debug-log-bodies flag (which should only be used outside of
+// production), or for a single request by a user with the debug:read permission
+// sending the X-Debug-Log-Bodies header. It must run after the authentication
+// middleware.
+func (app *application) logBodies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.debug.logBodies && !app.bodyLoggingRequested(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestBody := &cappedBuffer{limit: app.config.debug.bodyLimit}
+		responseBody := &cappedBuffer{limit: app.config.debug.bodyLimit}
+
+		r.Body = teeReadCloser{Reader: io.TeeReader(r.Body, requestBody), Closer: r.Body}
+
+		status := http.StatusOK
+
+		w = httpsnoop.Wrap(w, httpsnoop.Hooks{
+			WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+				return func(code int) {
+					status = code
+					next(code)
+				}
+			},
+			Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(p []byte) (int, error) {
+					responseBody.Write(p)
+					return next(p)
+				}
+			},
+		})
+
+		next.ServeHTTP(w, r)
+
+		properties := reqctx.FromContext(r.Context()).LogProperties()
+		properties["request_method"] = r.Method
+		properties["request_url"] = r.URL.String()
+		properties["status"] = strconv.Itoa(status)
+		properties["request_body"] = requestBody.String()
+		properties["response_body"] = responseBody.String()
+
+		app.logger.PrintInfo("request bodies", properties)
+	})
+}
+
+// Report whether the request asks for its bodies to be logged and comes from a user
+// allowed to do so. Permission lookup errors are logged and treated as a refusal.
+func (app *application) bodyLoggingRequested(r *http.Request) bool {
+	if r.Header.Get(debugBodiesHeader) == "" {
+		return false
+	}
+
+	allowed, err := app.userHasPermissions(r, "debug:read")
+	if err != nil {
+		app.logError(r, err)
+		return false
+	}
+
+	return allowed
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LuisBarroso37/Greenlight/internal/cdn"
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
+)
+
+// The surrogate key every list of movies is tagged with. Any change to a movie can
+// change any list, so they are all purged together.
+const movieListSurrogateKey = "movies"
+
+// Return the surrogate key responses including a movie are tagged with
+func movieSurrogateKey(id int64) string {
+	return "movie-" + strconv.FormatInt(id, 10)
+}
+
+// Define a purgeCDNJob struct holding the payload of a job which purges the responses
+// tagged with the given surrogate keys from the CDN
+type purgeCDNJob struct {
+	Keys []string `json:"keys"`
+}
+
+func (purgeCDNJob) Kind() string {
+	return "purge_cdn"
+}
+
+// The openPurger() function returns the purger for the configured CDN. The zone is the
+// Fastly service ID or the Cloudflare zone ID.
+func openPurger(provider, zone, token string) (cdn.Purger, error) {
+	switch provider {
+	case "fastly":
+		return cdn.NewFastly(zone, token), nil
+	case "cloudflare":
+		return cdn.NewCloudflare(zone, token), nil
+	default:
+		return nil, fmt.Errorf("unknown CDN provider %q", provider)
+	}
+}
+
+// The setSurrogateKeys() helper lets a CDN cache a response to an anonymous request for
+// the public catalog, tagging it with surrogate keys so that it can be purged when the
+// movies in it change. Fastly reads the keys from the Surrogate-Key header and Cloudflare
+// from the Cache-Tag header. Browsers are told to revalidate every time, as they can't
+// be purged. Responses to authenticated requests stay private, and as they vary on the
+// Authorization header a CDN never serves them to anyone else.
+func (app *application) setSurrogateKeys(r *http.Request, headers http.Header, keys ...string) {
+	if !app.config.catalog.public || app.config.cdn.maxAge <= 0 {
+		return
+	}
+
+	if r.Header.Get("Authorization") != "" {
+		return
+	}
+
+	if user := app.contextLookupUser(r); user != nil && !user.IsAnonymous() {
+		return
+	}
+
+	headers.Set("Cache-Control", fmt.Sprintf("public, max-age=0, s-maxage=%d", int(app.config.cdn.maxAge.Seconds())))
+	headers.Set("Surrogate-Key", strings.Join(keys, " "))
+	headers.Set("Cache-Tag", strings.Join(keys, ","))
+}
+
+// The queueCDNPurge() helper queues a job to purge the cached responses including a
+// movie which has changed, along with every list of movies. It is called for every
+// movie event, like queueMovieIndexing(). Until the purge runs the CDN serves the
+// previous version, for at most cdn.maxAge.
+func (app *application) queueCDNPurge(ctx context.Context, id int64) error {
+	if app.purger == nil {
+		return nil
+	}
+
+	job := purgeCDNJob{Keys: []string{movieSurrogateKey(id), movieListSurrogateKey}}
+
+	return app.jobs.Enqueue(ctx, job, jobs.EnqueueOptions{})
+}
+
+// Purge the responses tagged with the keys of the job from the CDN. Jobs left in the
+// queue after purging has been turned off do nothing.
+func (app *application) purgeCDN(ctx context.Context, job purgeCDNJob) error {
+	if app.purger == nil {
+		return nil
+	}
+
+	return app.purger.Purge(ctx, job.Keys...)
+}
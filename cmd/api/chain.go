@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Define a chainLink struct holding a named middleware of a chain. Disabled links are
+// kept so that routes can still opt out of them by name.
+type chainLink struct {
+	name    string
+	wrap    func(http.Handler) http.Handler
+	enabled bool
+}
+
+// Define a chain type holding the middleware which wraps every request of a listener,
+// before it is routed. The middleware are named, so that routes can opt out of one of
+// them (such as the healthcheck skipping rate limiting) when they are registered on a
+// route group created with the chain. The chain also keeps the registry of the routes
+// registered on those groups.
+type chain struct {
+	links  []chainLink
+	routes []*routeInfo
+
+	// The routes which opted out of each middleware. The routes are registered on a
+	// router of their own, so that a request can be matched against their patterns
+	// before it reaches the real router.
+	skips map[string]*httprouter.Router
+}
+
+// Return a new, empty chain
+func newChain() *chain {
+	return &chain{skips: make(map[string]*httprouter.Router)}
+}
+
+// Use adds a middleware to the chain. The middleware added first is the outermost, so
+// it runs first.
+func (c *chain) Use(name string, wrap func(http.Handler) http.Handler) *chain {
+	return c.UseIf(true, name, wrap)
+}
+
+// UseIf adds a middleware to the chain which is only applied when enabled is true,
+// typically because it is switched on by the configuration
+func (c *chain) UseIf(enabled bool, name string, wrap func(http.Handler) http.Handler) *chain {
+	for _, link := range c.links {
+		if link.name == name {
+			panic(fmt.Sprintf("middleware %q added to the chain twice", name))
+		}
+	}
+
+	c.links = append(c.links, chainLink{name: name, wrap: wrap, enabled: enabled})
+
+	return c
+}
+
+// Skip records that requests for the given method and route pattern bypass the named
+// middleware
+func (c *chain) Skip(name, method, pattern string) {
+	router, ok := c.skips[name]
+	if !ok {
+		router = httprouter.New()
+		c.skips[name] = router
+	}
+
+	router.Handle(method, pattern, func(http.ResponseWriter, *http.Request, httprouter.Params) {})
+}
+
+// Then wraps the handler with the middleware of the chain. It must be called once the
+// routes have been registered, as the routes' opt-outs are read here. It panics if a
+// route opted out of a middleware the chain doesn't have, which is most likely a typo.
+func (c *chain) Then(handler http.Handler) http.Handler {
+	for name := range c.skips {
+		if !c.has(name) {
+			panic(fmt.Sprintf("routes opt out of unknown middleware %q", name))
+		}
+	}
+
+	for i := len(c.links) - 1; i >= 0; i-- {
+		link := c.links[i]
+		if !link.enabled {
+			continue
+		}
+
+		next := handler
+		wrapped := link.wrap(next)
+
+		skips, ok := c.skips[link.name]
+		if !ok {
+			handler = wrapped
+			continue
+		}
+
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if handle, _, _ := skips.Lookup(r.Method, r.URL.Path); handle != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+
+	return handler
+}
+
+// Return the entries of the route registry, sorted by path and then by method
+func (c *chain) Routes() []*routeInfo {
+	routes := make([]*routeInfo, len(c.routes))
+	copy(routes, c.routes)
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+
+		return routes[i].Method < routes[j].Method
+	})
+
+	return routes
+}
+
+// Report whether the chain has a middleware with the given name, enabled or not
+func (c *chain) has(name string) bool {
+	for _, link := range c.links {
+		if link.name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"net/http"
+)
+
+// Struct used for holding a single entry of the API changelog
+type changelogEntry struct {
+	Date        string `json:"date"`
+	Type        string `json:"type"` // One of "added", "changed", "deprecated" or "removed"
+	Endpoint    string `json:"endpoint,omitempty"`
+	Description string `json:"description"`
+}
+
+// The changelog lists changes to the API which clients may need to act on, newest
+// first. Add an entry whenever an endpoint or field is added, changed, deprecated or
+// removed.
+var changelog = []changelogEntry{
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Description: "Time budgets set with the X-Request-Timeout or grpc-timeout header are now at least 500ms by default. Shorter budgets are raised to the minimum rather than being cut short.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Endpoint:    "POST /v1/users/me/service-accounts",
+		Description: "Requests made with an authentication token restricted to some permissions can only create or update service accounts, or rotate their keys, when their permissions are all allowed by the token. Others are sent a 422 Unprocessable Entity response for the permissions field.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Endpoint:    "POST /v1/tokens/password-reset",
+		Description: "By default, at most 5 activation and password reset emails are sent to an address per hour. Further requests to POST /v1/tokens/activation and POST /v1/tokens/password-reset for the address are sent a 429 Too Many Requests response with the email_rate_limited code and a Retry-After header.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/admin/mail-templates/:name/preview",
+		Description: "Renders an email template, such as user_welcome, with sample data without sending it. The format parameter chooses the HTML (the default) or plain text body, and the subject is sent in the X-Mail-Subject header.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "PUT /v1/users/me/digest",
+		Description: "Users can subscribe to a daily or weekly digest email listing the movies added in their favorite genres since the previous digest. GET /v1/users/me/digest shows the subscription, including when the next digest is due, and DELETE /v1/users/me/digest cancels it.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/admin/users/:id",
+		Description: "Shows a user along with the delivery status of their email address (ok, soft_bounce, bounced or complained), which is now kept up to date from the email provider's bounce and complaint webhooks. Emails are no longer sent to addresses which have bounced for good or complained.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/users/me/tos-acceptance",
+		Description: "Deployments can require users to accept their terms of service. Until they accept the current version, authenticated requests get a 428 Precondition Required response with the tos_not_accepted code and the version to accept in tos_version, which is also shown by GET /v1/meta. Users accept it by sending the version to this endpoint.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/admin/impersonate/:id",
+		Description: "Gives support staff holding the users:impersonate permission a short-lived authentication token acting as a user who holds none of the permissions they don't. Requests made with it are logged with the admin's ID, and it can't be used to manage the user's password or service accounts (impersonation_not_permitted).",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "Users are sent a new_sign_in notification, by email and in the app, when they get an authentication token from a device (user agent and IP address) they haven't signed in from before. The emails can be turned off with the new_sign_in notification preference.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/tokens/guest",
+		Description: "When guest sessions are enabled, clients without an account can get a guest token, which grants read-only access to the movies and collections until it expires. Requests made with a guest token are rate limited on the guest session's own quota as well as by IP address, and each IP address can only get a few guest tokens (3 at once and one every 20 seconds by default).",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Description: "Permissions can be wildcards: movies:* grants every movies permission and * grants every permission. The scopes of authentication tokens and the permissions of service accounts may hold wildcards the user is granted, and must be valid permission codes (lowercase segments separated by colons).",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "The lists of a movie's reviews, of reviews awaiting moderation and of the user's notifications can be paginated with a cursor when sorted by id: the metadata holds a next_cursor, which is passed back in the cursor query parameter instead of page. Cursor pages don't skip or repeat records added while paginating. Every paginated list now sends a Link header, including the notifications.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "When the API reads from a replica, responses to writes carry an X-Consistency-Token header. Sending it back with later requests makes their reads go to the primary for a few seconds after the write, so that clients see their own changes. Authenticated users get the same for writes handled by the same instance without sending the token.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/admin/operations",
+		Description: "Queues an online schema change by name, such as building an index concurrently or backfilling a column in batches, so that large tables aren't locked. Operations and their progress are listed with GET /v1/admin/operations and shown with GET /v1/admin/operations/:id. Requires the operations:write permission when served on the public port.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/admin/rate-limits",
+		Description: "Lists the clients tracked by the rate limiter of the instance handling the request, with the tokens left in their buckets and when they were last seen. DELETE /v1/admin/rate-limits/:key resets a client. Requires the bans:write permission when served on the public port.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/meta/routes",
+		Description: "Lists the routes served by the API, with the handler, the permission each requires and whether it counts towards the client's rate limit.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "Requests can set a time budget with an X-Request-Timeout header, holding a duration such as 500ms or a number of seconds, or with a gRPC-style grpc-timeout header. The budget is capped by the server, and requests which run out of it are sent a 504 status code with the deadline_exceeded error code.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "Requests can carry W3C Trace Context traceparent and tracestate headers. The trace ID is recorded in the API's logs and passed on to the services the API calls while handling the request, including from the jobs it queues.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/admin/backups",
+		Description: "Queues a backup of the database with pg_dump, when backups are enabled. Backups and their status are listed with GET /v1/admin/backups, and only the newest completed ones are kept. Requires the backups:write permission when served on the public port.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "Movies have a status (draft, scheduled or published, which is the default) and a publish_at timestamp, which scheduled movies must have. Draft and scheduled movies are only shown to users holding the movies:write permission, and are not found for everyone else. Scheduled movies are published once their publish_at time has passed, emitting a movie.published event and a movie_published notification to the user who created the movie.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/movies/:id/pending-changes",
+		Description: "Proposes a change to a movie, with the same fields as PATCH /v1/movies/:id. Editors list proposed changes with GET /v1/movies/:id/pending-changes and approve or reject them with PUT /v1/movies/:id/pending-changes/:change_id/approve and /reject. Approving a change proposed against an older version of the movie fails with an edit conflict.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "Movies have a created_by field holding the ID of the user who created them, and GET /v1/movies lists a user's own movies with created_by=me. In ownership mode, editing a movie created by someone else requires the movies:admin permission, and fails with the movie_not_owned error code otherwise.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "Movies have a metadata field holding deployment-specific string attributes. PATCH /v1/movies/:id merges metadata into the movie's, removing keys set to null, and GET /v1/movies filters on it with metadata.<key>=<value> parameters.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Description: "With a public catalog, responses to anonymous requests for movies and movie lists can be cached by a CDN, and carry Surrogate-Key and Cache-Tag headers.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /sitemap.xml",
+		Description: "Deployments running in public catalog mode let anyone read movies and collections without authenticating, and list every movie URL for web crawlers in a sitemap (or a sitemap index with ?page= pages for catalogs over 50,000 movies). GET /v1/meta reports the public_catalog and sitemap features.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies/autocomplete",
+		Description: "Suggests up to limit (10 by default, at most 20) movie titles containing or similar to the q parameter, for typeahead search boxes. Queries under 2 characters, and queries which run out of their time budget, return no suggestions.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Endpoint:    "GET /v1/movies",
+		Description: "metadata.facets is only returned when requested with ?include=facets, whichever search backend is used, and also counts the matching movies by decade and certification.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies",
+		Description: "When movies are searched through Elasticsearch or Meilisearch, metadata.facets counts the matching movies by genre, year and 30-minute runtime range.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/admin/stats",
+		Description: "Includes the number of domain events waiting in the outbox, and how long the oldest has been waiting, as events_outbox when events are published to a message broker.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Description: "Failed validation responses have a fields object alongside error, giving the code, message and parameters of each invalid field's error, such as {\"code\": \"too_long\", \"params\": {\"max\": 500}}, so that clients can localize the messages. Lines of a bulk import which fail validation have fields too.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Description: "Every error response has a code field alongside error, holding a stable, machine-readable error code such as movie_not_found, edit_conflict or rate_limited. Lines which fail a bulk import have a code too. Codes are never changed once published.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/error-codes",
+		Description: "Lists every error code with the status code it is sent with and a description.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Description: "Expired activation, password reset and authentication tokens are reported with the error code token_expired, instead of as invalid tokens, so that clients can prompt for a new one. Requesting a new activation token with POST /v1/tokens/activation now invalidates any earlier ones.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Endpoint:    "POST /v1/users",
+		Description: "Rejects registrations without an invitation using a disposable email address, or an email domain the deployment has blocked or not allowed, with a validation error on the email field.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/invitations",
+		Description: "Emails an invitation code to register, for users holding the invitations:write permission. Invitations are listed with GET /v1/invitations and revoked with DELETE /v1/invitations/:id.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Endpoint:    "POST /v1/users",
+		Description: "Accepts an optional invitation code, which is required when registration is closed. Invited users are activated straight away and a 201 Created response is sent.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/users/me/service-accounts",
+		Description: "Creates a service account for a CI system or partner integration. It authenticates by sending its key as a bearer token, and acts for its owner with only the permissions it was given. Service accounts are listed, shown, updated, deleted and have their keys rotated under /v1/users/me/service-accounts.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/tokens/authentication",
+		Description: "Accepts an optional scopes array restricting the token to some of the user's permissions, and an optional expiry of up to 24 hours. Requests made with a restricted token are refused for endpoints needing any other permission.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/admin/metrics",
+		Description: "The metrics include database_pool_health, with the latest ping time and how many queries waited for a database connection, and for how long on average, over the last monitor interval.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Description: "The server keeps retrying the database for up to -startup-timeout when starting, instead of exiting if it isn't reachable yet. The SMTP server can be checked too with -smtp-verify, and -fail-fast restores the previous behaviour.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Description: "While the database can't be reached, requests which need it are sent a 503 Service Unavailable response with a Retry-After header straight away instead of waiting for a connection to time out.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Description: "Requests are sent a 503 Service Unavailable response with a Retry-After header when the server is already handling too many requests and the queue of waiting requests is full, or a request has waited for too long.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/admin/metrics",
+		Description: "The metrics include http_request_duration_seconds, a histogram of request durations by method and route pattern with estimated p50, p95 and p99 values. It is also exported to Prometheus.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/admin/metrics/snapshots",
+		Description: "Stores the current metrics in the database, optionally resetting the in-memory counters afterwards. Snapshots are listed with GET /v1/admin/metrics/snapshots, and the counters can also be reset on their own with POST /v1/admin/metrics/reset.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/admin/metrics",
+		Description: "Returns the current metrics along with the request, response and server error rates over the last minute.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/admin/stats",
+		Description: "Returns user, movie and review counts, new users per day, a snapshot of the request metrics and the health of the email queue for the ops dashboard. Requires the admin:read permission.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies/_meta",
+		Description: "Describes how the movie list can be sorted, filtered and paginated, including the accepted values of restricted filters, so that clients don't need to hardcode them.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies",
+		Description: "Movies can be filtered by when they were added with the created_after and created_before query parameters, which take RFC 3339 timestamps.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Description: "Movies, collections and users include a created_at timestamp, and movies also include an updated_at timestamp. All timestamps are written in RFC 3339 format in UTC unless the deployment is configured with another time zone.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "Movie runtimes can be sent as ISO 8601 durations such as \"PT2H28M\". Responses use them for the runtime field when requested with the duration_format=iso8601 query parameter or an Accept header of application/json;duration=iso8601.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "Movie runtimes can be sent as a number of minutes as well as a \"<runtime> mins\" string. Responses include a numeric runtime_minutes field alongside the runtime string when requested with the runtime_minutes=true query parameter or an Accept header of application/json;runtime=minutes.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Description: "Errors for unknown fields in request bodies are an object with the message, the field name and, when configured, a documentation_url. Deployments can choose to ignore unknown fields with a warning instead, except on the movie and collection write endpoints, which always reject them.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Description: "Request bodies which are too large are rejected with a 413 status code instead of 400, and the error is an object with the message and the max_bytes limit which was exceeded. The user and token endpoints now only accept bodies of up to 16KB by default.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/movies",
+		Description: "Movies can be created in bulk by sending an application/x-ndjson body of up to 64MB, with one movie per line. The response gives the number of movies imported and failed, and lists up to 100 failed lines with their errors. Malformed or invalid lines don't stop the upload.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/moderation/reports",
+		Description: "Lists review reports by status (open by default) and optionally reason. Open reports are upheld or dismissed with PUT /v1/moderation/reports/:id. Approving or rejecting a review dismisses or upholds its open reports. Requires the moderation:write permission.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/reviews/:id/reports",
+		Description: "Users can report a published review once, with a reason of spam, harassment, hate_speech, spoilers, off_topic or other (which requires a comment). Reviews with enough open reports are hidden until a moderator approves or rejects them.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/moderation/reviews",
+		Description: "Lists reviews by moderation status (pending by default), oldest first. Reviews are approved or rejected with PUT /v1/moderation/reviews/:id/approve and PUT /v1/moderation/reviews/:id/reject, which takes an optional reason. Requires the moderation:write permission.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/movies/:id/reviews",
+		Description: "Users can review a movie once with a rating from 1 to 10 and a body. Reviews which fail the profanity screening are held for a moderator with a pending status, and only approved reviews are listed by GET /v1/movies/:id/reviews.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies/:id/availability",
+		Description: "Lists where a movie can be streamed, rented or bought in the country given by the required country query parameter. Availability is refreshed in the background, and refreshed_at is null until it has been fetched. Only available when the availability feature is enabled.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies/:id/releases",
+		Description: "Movies have release dates per country and release type, such as theatrical or streaming. They are managed with POST /v1/movies/:id/releases and PATCH and DELETE on /v1/movies/:id/releases/:release_id.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies",
+		Description: "Movies can be filtered with the released_before and released_after query parameters (YYYY-MM-DD), which match movies with a release between the two dates.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/movies",
+		Description: "Movies have synopsis, original_language, production_countries and certifications fields, which can also be set with PUT /v1/movies/:id. Certifications map a country code to an age rating from the country's list of supported ratings.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies",
+		Description: "Movies can be filtered with the synopsis, language, countries and certification query parameters, where certification takes a list like US:PG-13,GB:12A.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/collections/:id",
+		Description: "Collections group related movies, such as a franchise, in order. They are managed with GET, POST, PATCH and DELETE on /v1/collections, and showing a collection includes its movies.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies/:id",
+		Description: "Movie responses include the collection the movie belongs to when requested with ?include=collection, which is also supported by GET /v1/movies.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "changed",
+		Endpoint:    "GET /v1/movies/:id",
+		Description: "The ETag is calculated from the whole response, so it changes when the view count changes.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "POST /v1/users/me/saved-searches",
+		Description: "Save a movie search (name, title and genres) to be emailed when matching movies are added. Saved searches are listed with GET and removed with DELETE /v1/users/me/saved-searches/:id.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/users/me/notifications",
+		Description: "Lists the authenticated user's in-app notifications, newest first. Accepts unread, page and page_size parameters. Notifications are marked as read with PUT /v1/users/me/notifications/:id/read.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "PUT /v1/users/me/notification-preferences",
+		Description: "Choose whether each kind of notification is delivered by email, in-app or both. The current preferences are returned by GET /v1/users/me/notification-preferences.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies",
+		Description: "Movies include a views field with the number of times they have been viewed. It is updated periodically rather than on every view.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies/trending",
+		Description: "Lists the most viewed movies over the last day. Accepts a limit parameter (default 10, maximum 50).",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies/recent",
+		Description: "Lists the most recently added movies. Accepts a limit parameter (default 10, maximum 50).",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "Every response includes an X-Request-ID header. A valid X-Request-ID sent by the client is reused.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "Clients which repeatedly receive 429 or 401 responses are temporarily banned and receive 403 responses with a Retry-After header.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "Partner clients can authenticate by signing requests with the X-Client-Key, X-Timestamp and X-Signature headers when request signing is enabled.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/changelog",
+		Description: "Machine-readable feed of API changes. Deprecated endpoints send Deprecation and Sunset headers, and responses list warnings in the _warnings field.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies",
+		Description: "Responses include a Link header with first, prev, next and last page URLs.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies",
+		Description: "Responses include the X-Total-Count and ETag headers.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/movies/:id",
+		Description: "Responses include an ETag header.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Description: "HEAD is supported on every GET endpoint, and OPTIONS lists the methods supported by a path in the Allow header.",
+	},
+	{
+		Date:        "2026-10-16",
+		Type:        "added",
+		Endpoint:    "GET /v1/meta",
+		Description: "Build and feature information for the running instance.",
+	},
+}
+
+// Handler for the "GET /v1/changelog" endpoint
+func (app *application) changelogHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"changelog": changelog}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+)
+
+// The header carrying the consistency token. It is sent with the response to every
+// write, holding the time of the write in Unix milliseconds, and clients send it back
+// so that their reads go to the primary until the replica has caught up with the write.
+const consistencyTokenHeader = "X-Consistency-Token"
+
+// How far in the future a consistency token's time may be, to allow for the clocks of
+// the instances drifting apart
+const consistencyTokenSkew = time.Second
+
+// Count the reads which went to the primary rather than the replica because the client
+// had just written
+var totalReadsSticky = expvar.NewInt("total_reads_sticky")
+
+// Define a writeTracker type which remembers when each user last wrote through this
+// instance, for clients which don't send the consistency token back. Users who haven't
+// written within the window are removed once a minute.
+type writeTracker struct {
+	window time.Duration
+
+	mutex sync.Mutex
+	users map[int64]time.Time
+}
+
+// Return a new writeTracker remembering writes for the given window
+func newWriteTracker(window time.Duration) *writeTracker {
+	t := &writeTracker{
+		window: window,
+		users:  make(map[int64]time.Time),
+	}
+
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+
+			t.mutex.Lock()
+
+			for userID, wrote := range t.users {
+				if time.Since(wrote) >= t.window {
+					delete(t.users, userID)
+				}
+			}
+
+			t.mutex.Unlock()
+		}
+	}()
+
+	return t
+}
+
+// Record that the user wrote at the given time
+func (t *writeTracker) record(userID int64, wrote time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.users[userID] = wrote
+}
+
+// Report whether the user wrote through this instance within the window
+func (t *writeTracker) recent(userID int64, now time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	wrote, found := t.users[userID]
+
+	return found && now.Sub(wrote) < t.window
+}
+
+// The readConsistency() middleware lets the reads of GET and HEAD requests go to the
+// read replica, unless the client wrote within the sticky window: either the request
+// carries a recent consistency token, or the authenticated user wrote through this
+// instance. Any other request reads from the primary, so that it sees the rows it is
+// about to change, and is sent a consistency token. It does nothing when no replica is
+// configured.
+func (app *application) readConsistency(next http.Handler) http.Handler {
+	if app.writes == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		now := time.Now()
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set(consistencyTokenHeader, strconv.FormatInt(now.UnixMilli(), 10))
+
+			next.ServeHTTP(w, r)
+
+			// Start the window once the write has been committed
+			if !user.IsAnonymous() {
+				app.writes.record(user.ID, time.Now())
+			}
+
+			return
+		}
+
+		if app.readsOwnWrites(r, user, now) {
+			totalReadsSticky.Add(1)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(data.WithReplicaReads(r.Context())))
+	})
+}
+
+// Report whether the request's reads must see the client's recent writes. Tokens which
+// can't be parsed or are too far in the future are ignored, as the client may have made
+// them up.
+func (app *application) readsOwnWrites(r *http.Request, user *data.User, now time.Time) bool {
+	if !user.IsAnonymous() && app.writes.recent(user.ID, now) {
+		return true
+	}
+
+	token := r.Header.Get(consistencyTokenHeader)
+	if token == "" {
+		return false
+	}
+
+	millis, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	wrote := time.UnixMilli(millis)
+
+	return wrote.Before(now.Add(consistencyTokenSkew)) && now.Sub(wrote) < app.config.db.stickyWindow
+}
@@ -3,34 +3,332 @@ package main
 import (
 	"context"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/reqctx"
+	"github.com/LuisBarroso37/Greenlight/internal/tracing"
 )
 
 // Define a custom contextKey type, with the underlying type string
 type contextKey string
 
-// Convert the string "user" to a contextKey type and assign it to the userContextKey
-// constant. We'll use this constant as the key for getting and setting user information
-// in the request context.
-const userContextKey = contextKey("user")
+// The key for getting the request context bag from the request context
+const requestContextKey = contextKey("request_context")
 
-// The contextSetUser() method returns a new copy of the request with the provided
-// User struct added to the context. Note that we use our userContextKey constant as the key.
-func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
-	ctx := context.WithValue(r.Context(), userContextKey, user)
+// Define a requestContext struct holding all the values scoped to a single request. It
+// is added to the context once by the requestContext() middleware and then filled in
+// by the middleware further down the chain, so every copy of the request shares it.
+// The values which the models and the logger need are kept in the embedded
+// reqctx.Values, which is also added to the context on its own.
+type requestContext struct {
+	*reqctx.Values
+	user               *data.User
+	serviceAccount     *data.ServiceAccount
+	bodyLimit          *int64
+	allowUnknownFields *bool
+	route              *routeInfo
+}
+
+// The requestContext() middleware adds a new request context bag to the request,
+// recording the locale picked from the Accept-Language header and a snapshot of the
+// feature flags, so that a request sees the same flags from start to finish
+func (app *application) requestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := &requestContext{
+			Values: &reqctx.Values{
+				Locale: negotiateLocale(r.Header.Get("Accept-Language"), app.config.locales),
+				Flags:  app.enabledFeatures(),
+			},
+		}
+
+		w.Header().Add("Vary", "Accept-Language")
+
+		next.ServeHTTP(w, app.contextWithRequestContext(r, rc))
+	})
+}
+
+// The contextWithRequestContext() method returns a new copy of the request with the
+// given request context bag added to the context
+func (app *application) contextWithRequestContext(r *http.Request, rc *requestContext) *http.Request {
+	ctx := context.WithValue(r.Context(), requestContextKey, rc)
+	ctx = reqctx.NewContext(ctx, rc.Values)
 
 	return r.WithContext(ctx)
 }
 
+// The contextRequestContext() method returns the request context bag along with the
+// request carrying it. Requests which don't have one yet (for example in the admin
+// listener) are given a new, empty one.
+func (app *application) contextRequestContext(r *http.Request) (*http.Request, *requestContext) {
+	if rc, ok := r.Context().Value(requestContextKey).(*requestContext); ok {
+		return r, rc
+	}
+
+	rc := &requestContext{Values: &reqctx.Values{}}
+
+	return app.contextWithRequestContext(r, rc), rc
+}
+
+// The contextLookup() method returns the request context bag, or nil if the request
+// doesn't have one
+func (app *application) contextLookup(r *http.Request) *requestContext {
+	rc, _ := r.Context().Value(requestContextKey).(*requestContext)
+
+	return rc
+}
+
+// The contextSetUser() method records the user making the request in the request
+// context bag, returning the request carrying it
+func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	r, rc := app.contextRequestContext(r)
+
+	rc.user = user
+	rc.UserID = 0
+	if !user.IsAnonymous() {
+		rc.UserID = user.ID
+	}
+
+	rc.ImpersonatorID = user.ImpersonatorID
+
+	return r
+}
+
 // The contextGetUser() retrieves the User struct from the request context. The only
 // time that we'll use this helper is when we logically expect there to be User struct
 // value in the context, and if it doesn't exist it will firmly be an 'unexpected' error.
 func (app *application) contextGetUser(r *http.Request) *data.User {
-	user, ok := r.Context().Value(userContextKey).(*data.User)
-	if !ok {
+	rc := app.contextLookup(r)
+	if rc == nil || rc.user == nil {
 		panic("missing user value in request context")
 	}
 
-	return user
+	return rc.user
+}
+
+// The contextLookupUser() method retrieves the user making the request from the
+// request context, returning nil if the request hasn't been authenticated yet
+func (app *application) contextLookupUser(r *http.Request) *data.User {
+	if rc := app.contextLookup(r); rc != nil {
+		return rc.user
+	}
+
+	return nil
+}
+
+// The contextSetServiceAccount() method records the service account making the
+// request in the request context bag, returning the request carrying it
+func (app *application) contextSetServiceAccount(r *http.Request, account *data.ServiceAccount) *http.Request {
+	r, rc := app.contextRequestContext(r)
+
+	rc.serviceAccount = account
+
+	return r
+}
+
+// The contextGetServiceAccount() method retrieves the service account making the
+// request from the request context, returning nil if the request was made by a user
+func (app *application) contextGetServiceAccount(r *http.Request) *data.ServiceAccount {
+	if rc := app.contextLookup(r); rc != nil {
+		return rc.serviceAccount
+	}
+
+	return nil
+}
+
+// The contextSetRequestID() method records the request ID in the request context bag,
+// returning the request carrying it
+func (app *application) contextSetRequestID(r *http.Request, id string) *http.Request {
+	r, rc := app.contextRequestContext(r)
+
+	rc.RequestID = id
+
+	return r
+}
+
+// The contextSetTrace() method adds the request's trace to the request context, both
+// on its own for outgoing requests and in the request context bag for log entries
+func (app *application) contextSetTrace(r *http.Request, trace tracing.Trace) *http.Request {
+	r, rc := app.contextRequestContext(r)
+
+	rc.TraceID = trace.TraceID
+	rc.SpanID = trace.SpanID
+
+	return r.WithContext(tracing.NewContext(r.Context(), trace))
+}
+
+// The contextGetRequestID() method retrieves the request ID from the request context,
+// returning an empty string if there isn't one (for example in the admin listener)
+func (app *application) contextGetRequestID(r *http.Request) string {
+	if rc := app.contextLookup(r); rc != nil {
+		return rc.RequestID
+	}
+
+	return ""
+}
+
+// The contextGetLocale() method retrieves the locale negotiated for the request,
+// returning the default locale if there isn't one
+func (app *application) contextGetLocale(r *http.Request) string {
+	if rc := app.contextLookup(r); rc != nil && rc.Locale != "" {
+		return rc.Locale
+	}
+
+	if len(app.config.locales) > 0 {
+		return app.config.locales[0]
+	}
+
+	return ""
+}
+
+// The contextSetTenant() method records the tenant the request is made for, such as the
+// partner client of a signed request, in the request context bag, returning the request
+// carrying it
+func (app *application) contextSetTenant(r *http.Request, tenant string) *http.Request {
+	r, rc := app.contextRequestContext(r)
+
+	rc.Tenant = tenant
+
+	return r
+}
+
+// The contextGetTenant() method retrieves the tenant the request is made for from the
+// request context, returning an empty string if there isn't one
+func (app *application) contextGetTenant(r *http.Request) string {
+	if rc := app.contextLookup(r); rc != nil {
+		return rc.Tenant
+	}
+
+	return ""
+}
+
+// The contextGetFlags() method retrieves the snapshot of the feature flags taken when
+// the request started, taking a new one if the request doesn't have a snapshot
+func (app *application) contextGetFlags(r *http.Request) map[string]bool {
+	if rc := app.contextLookup(r); rc != nil && rc.Flags != nil {
+		return rc.Flags
+	}
+
+	return app.enabledFeatures()
+}
+
+// The contextSetBodyLimit() method records the route's maximum request body size in
+// the request context bag, returning the request carrying it
+func (app *application) contextSetBodyLimit(r *http.Request, maxBytes int64) *http.Request {
+	r, rc := app.contextRequestContext(r)
+
+	rc.bodyLimit = &maxBytes
+
+	return r
+}
+
+// The contextGetBodyLimit() method retrieves the maximum request body size for the
+// route from the request context, returning the default limit if the route doesn't
+// set one
+func (app *application) contextGetBodyLimit(r *http.Request) int64 {
+	if rc := app.contextLookup(r); rc != nil && rc.bodyLimit != nil {
+		return *rc.bodyLimit
+	}
+
+	return app.config.body.maxBytes
+}
+
+// The contextSetAllowUnknownFields() method records whether unknown fields are allowed
+// in the request body in the request context bag, returning the request carrying it
+func (app *application) contextSetAllowUnknownFields(r *http.Request, allow bool) *http.Request {
+	r, rc := app.contextRequestContext(r)
+
+	rc.allowUnknownFields = &allow
+
+	return r
+}
+
+// The contextGetAllowUnknownFields() method retrieves whether the route allows unknown
+// fields in the request body from the request context, returning the configured default
+// if the route doesn't set it
+func (app *application) contextGetAllowUnknownFields(r *http.Request) bool {
+	if rc := app.contextLookup(r); rc != nil && rc.allowUnknownFields != nil {
+		return *rc.allowUnknownFields
+	}
+
+	return app.config.body.allowUnknownFields
+}
+
+// The contextSetRoute() method records the registry entry of the matched route in the
+// request context bag. As the bag is shared with the middleware running before the
+// router, they can read it once the request has been handled. It does nothing if there
+// isn't a bag (for example in the admin listener).
+func (app *application) contextSetRoute(r *http.Request, route *routeInfo) {
+	if rc := app.contextLookup(r); rc != nil {
+		rc.route = route
+	}
+}
+
+// The contextGetRoute() method retrieves the registry entry of the matched route from
+// the request context, returning nil if the router didn't match a route
+func (app *application) contextGetRoute(r *http.Request) *routeInfo {
+	if rc := app.contextLookup(r); rc != nil {
+		return rc.route
+	}
+
+	return nil
+}
+
+// Pick the supported locale which best matches an Accept-Language header, falling back
+// to the first supported locale. Languages are matched on their full tag first and then
+// on their primary subtag, so "en-GB" matches "en" and "pt" matches "pt-BR".
+func negotiateLocale(header string, supported []string) string {
+	if len(supported) == 0 {
+		return ""
+	}
+
+	type preference struct {
+		tag     string
+		quality float64
+	}
+
+	var preferences []preference
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil || parsed <= 0 {
+				continue
+			}
+			quality = parsed
+		}
+
+		preferences = append(preferences, preference{tag: tag, quality: quality})
+	}
+
+	// Keep the order of the header for languages with the same quality
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].quality > preferences[j].quality
+	})
+
+	for _, p := range preferences {
+		for _, locale := range supported {
+			if strings.EqualFold(p.tag, locale) {
+				return locale
+			}
+		}
+
+		primary, _, _ := strings.Cut(p.tag, "-")
+		for _, locale := range supported {
+			base, _, _ := strings.Cut(locale, "-")
+			if strings.EqualFold(primary, base) {
+				return locale
+			}
+		}
+	}
+
+	return supported[0]
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Define a poolHealth struct holding the figures published by the pool monitor
+type poolHealth struct {
+	PingMillis        float64 `json:"ping_ms"`
+	PingFailures      int64   `json:"ping_failures"`
+	WaitsPerInterval  int64   `json:"waits_per_interval"` // Queries which waited for a connection since the previous check
+	AverageWaitMillis float64 `json:"average_wait_ms"`    // The average wait of those queries
+	IntervalSeconds   float64 `json:"interval_seconds"`
+	LastChecked       int64   `json:"last_checked"`
+}
+
+// Define a poolMonitor type which periodically pings the connection pool and works out
+// how many queries had to wait for a connection since the previous check. The
+// cumulative figures in sql.DBStats only climb, so they don't show a pool which is
+// saturated right now.
+type poolMonitor struct {
+	db            *sql.DB
+	waitThreshold time.Duration
+
+	mutex  sync.Mutex
+	health poolHealth
+	prev   sql.DBStats
+}
+
+// Return a new poolMonitor for the connection pool, which warns about queries waiting
+// longer than the threshold on average
+func newPoolMonitor(db *sql.DB, waitThreshold time.Duration) *poolMonitor {
+	return &poolMonitor{
+		db:            db,
+		waitThreshold: waitThreshold,
+		prev:          db.Stats(),
+	}
+}
+
+// Return the figures from the last check
+func (m *poolMonitor) Health() poolHealth {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.health
+}
+
+// Ping the pool and compare its statistics with the previous check. The returned
+// health has the ping error, if any, for logging.
+func (m *poolMonitor) check(interval time.Duration) (poolHealth, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := m.db.PingContext(ctx)
+	ping := time.Since(start)
+
+	stats := m.db.Stats()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.health.PingMillis = float64(ping.Microseconds()) / 1000
+	if err != nil {
+		m.health.PingFailures++
+	}
+
+	m.health.WaitsPerInterval = stats.WaitCount - m.prev.WaitCount
+	m.health.AverageWaitMillis = 0
+	if m.health.WaitsPerInterval > 0 {
+		wait := stats.WaitDuration - m.prev.WaitDuration
+		m.health.AverageWaitMillis = float64(wait.Microseconds()) / 1000 / float64(m.health.WaitsPerInterval)
+	}
+
+	m.health.IntervalSeconds = interval.Seconds()
+	m.health.LastChecked = start.Unix()
+	m.prev = stats
+
+	return m.health, err
+}
+
+// The startPoolMonitor() method checks the connection pool at the given interval until
+// the application shuts down, logging failed pings and intervals in which queries
+// waited longer than the threshold for a connection on average
+func (app *application) startPoolMonitor(monitor *poolMonitor, interval time.Duration) {
+	app.background("monitor_database_pool", func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				health, err := monitor.check(interval)
+				if err != nil {
+					app.logger.PrintError(err, map[string]string{"task": "monitor_database_pool"})
+					continue
+				}
+
+				if health.WaitsPerInterval > 0 && time.Duration(health.AverageWaitMillis*float64(time.Millisecond)) >= monitor.waitThreshold {
+					app.logger.PrintInfo("database connection pool saturated", map[string]string{
+						"waits":        strconv.FormatInt(health.WaitsPerInterval, 10),
+						"average_wait": time.Duration(health.AverageWaitMillis * float64(time.Millisecond)).String(),
+						"interval":     interval.String(),
+					})
+				}
+			case <-app.shutdown:
+				return
+			}
+		}
+	})
+}
+
+// The warmPool() function opens the given number of connections and returns
+// them to the pool, so that the first burst of requests doesn't wait for connections
+// to be established. Connections beyond the pool's maximum idle connections are closed
+// again when returned, so the number should be no more than that.
+func warmPool(db *sql.DB, conns int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opened := make([]*sql.Conn, 0, conns)
+	defer func() {
+		for _, conn := range opened {
+			conn.Close()
+		}
+	}()
+
+	// Hold on to each connection until they're all open, as otherwise the pool would
+	// hand the same idle connection out again
+	for i := 0; i < conns; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+
+		opened = append(opened, conn)
+
+		err = conn.PingContext(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
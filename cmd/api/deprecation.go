@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Define a deprecation struct describing a deprecated endpoint. The sunset time, when
+// the endpoint is expected to be removed, is optional.
+type deprecation struct {
+	since   time.Time
+	sunset  time.Time
+	message string
+}
+
+// Return a middleware which marks the routes of a group as deprecated. Responses carry
+// the Deprecation header (RFC 9745), the Sunset header (RFC 8594) when a removal date
+// is known, and a warning with the given message.
+func (app *application) deprecated(d deprecation) middleware {
+	return middleware{
+		wrap: func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.since.Unix(), 10))
+
+				if !d.sunset.IsZero() {
+					w.Header().Set("Sunset", d.sunset.UTC().Format(http.TimeFormat))
+				}
+
+				app.addWarning(w, d.message)
+
+				next(w, r)
+			}
+		},
+	}
+}
+
+// Add a warning to the response, for example when the client used a deprecated field.
+// Warnings are sent in Warning headers, and writeJSON() also lists them in the
+// `_warnings` field of the response body.
+func (app *application) addWarning(w http.ResponseWriter, message string) {
+	w.Header().Add("Warning", fmt.Sprintf("299 - %s", strconv.Quote(message)))
+}
+
+// Return the messages of the warnings which have been added to the response
+func responseWarnings(header http.Header) []string {
+	var warnings []string
+
+	for _, value := range header.Values("Warning") {
+		message, err := strconv.Unquote(strings.TrimPrefix(value, "299 - "))
+		if err != nil {
+			continue
+		}
+
+		warnings = append(warnings, message)
+	}
+
+	return warnings
+}
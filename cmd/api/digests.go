@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// The maximum number of new movies listed in a digest
+const digestMovieLimit = 20
+
+// Define a digestsJob struct for the recurring job which sends the digest emails which
+// are due. It has no payload, as it goes through every due subscription.
+type digestsJob struct{}
+
+func (digestsJob) Kind() string {
+	return "digests"
+}
+
+// Schedule the next run of the digests job. Only one run is ever pending, however many
+// instances of the application schedule it.
+func (app *application) scheduleDigests(ctx context.Context, runAt time.Time) error {
+	return app.jobs.Enqueue(ctx, digestsJob{}, jobs.EnqueueOptions{
+		RunAt:  runAt,
+		Unique: true,
+	})
+}
+
+// Send the digests which are due. The next run is scheduled first, so that the job keeps
+// recurring even if this run fails. The subscriptions are gone through in batches, and
+// the emails of each batch are queued to be sent -digest-batch-pause after those of the
+// previous one. Each subscription records when its next digest is due as soon as its
+// email is queued, so a retried run carries on where the failed one stopped.
+func (app *application) sendDigests(ctx context.Context, _ digestsJob) error {
+	err := app.scheduleDigests(ctx, time.Now().Add(app.config.digests.interval))
+	if err != nil {
+		return err
+	}
+
+	var afterUserID int64
+
+	sendAt := time.Now()
+
+	for {
+		subscriptions, err := app.models.Digests.GetBatchDue(ctx, afterUserID, app.config.digests.batchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(subscriptions) == 0 {
+			return nil
+		}
+
+		for _, subscription := range subscriptions {
+			err = app.sendDigest(ctx, subscription, sendAt)
+			if err != nil {
+				return err
+			}
+
+			afterUserID = subscription.UserID
+		}
+
+		sendAt = sendAt.Add(app.config.digests.batchPause)
+	}
+}
+
+// Queue a digest email listing the published movies in a user's favorite genres which
+// were added since their previous digest, to be sent at the given time. No email is
+// sent if there aren't any, but the next digest is still put back by a period.
+func (app *application) sendDigest(ctx context.Context, subscription *data.DigestSubscription, sendAt time.Time) error {
+	period := data.DigestPeriod(subscription.Frequency)
+
+	// Digests which were missed, for example while digests were disabled, aren't caught
+	// up on one by one
+	nextDigestAt := subscription.NextDigestAt.Add(period)
+	if nextDigestAt.Before(time.Now()) {
+		nextDigestAt = time.Now().Add(period)
+	}
+
+	// Fetch one more movie than we list, to find out if there are more
+	movieSearch := data.MovieSearch{AnyGenres: subscription.Genres, PublishedOnly: true}
+
+	movies, _, err := app.models.Movie.GetAll(ctx, movieSearch, data.NewestMoviesFilters(digestMovieLimit+1))
+	if err != nil {
+		return err
+	}
+
+	// The movies are passed to the template as maps, as the email job stores its data as
+	// JSON
+	var newMovies []map[string]interface{}
+
+	for _, movie := range movies {
+		if movie.ID > subscription.LastMovieID {
+			newMovies = append(newMovies, map[string]interface{}{
+				"title":  movie.Title,
+				"year":   movie.Year,
+				"genres": movie.Genres,
+			})
+		}
+	}
+
+	if len(newMovies) == 0 {
+		return app.models.Digests.MarkSent(ctx, subscription.UserID, subscription.LastMovieID, nextDigestAt)
+	}
+
+	more := len(newMovies) > digestMovieLimit
+	if more {
+		newMovies = newMovies[:digestMovieLimit]
+	}
+
+	msg := app.subscriptionMessage(subscription.UserEmail, "digest.tmpl", map[string]interface{}{
+		"name":      subscription.UserName,
+		"frequency": subscription.Frequency,
+		"genres":    subscription.Genres,
+		"movies":    newMovies,
+		"more":      more,
+	})
+
+	err = app.sendMessage(ctx, sendAt, msg)
+	if err != nil {
+		return err
+	}
+
+	// The movies are sorted newest first
+	return app.models.Digests.MarkSent(ctx, subscription.UserID, movies[0].ID, nextDigestAt)
+}
+
+// Handler for the "GET /v1/users/me/digest" endpoint
+func (app *application) showDigestSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	subscription, err := app.models.Digests.Get(r.Context(), app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeDigestNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"digest_subscription": subscription}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "PUT /v1/users/me/digest" endpoint, which subscribes the user to the
+// digest emails of new movies in their favorite genres, or changes their subscription
+func (app *application) updateDigestSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Frequency string   `json:"frequency"`
+		Genres    []string `json:"genres"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	subscription := &data.DigestSubscription{
+		UserID:    app.contextGetUser(r).ID,
+		Frequency: input.Frequency,
+		Genres:    input.Genres,
+	}
+
+	v := validator.New()
+
+	if data.ValidateDigestSubscription(v, subscription); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Digests.Upsert(r.Context(), subscription)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"digest_subscription": subscription}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "DELETE /v1/users/me/digest" endpoint, which unsubscribes the user
+// from the digest emails
+func (app *application) deleteDigestSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.models.Digests.Delete(r.Context(), app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeDigestNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "digest subscription successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
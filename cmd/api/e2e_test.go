@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/data/datatest"
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
+	"github.com/LuisBarroso37/Greenlight/internal/mailer"
+	"github.com/LuisBarroso37/Greenlight/internal/schema"
+)
+
+// Define a testServer type which runs the whole application, with the real routes,
+// middleware and job workers, against the test database. Emails are kept by a Recorder
+// instead of being sent.
+type testServer struct {
+	*httptest.Server
+	app    *application
+	mailer *mailer.Recorder
+}
+
+// Start the application for a test, configured with the flag defaults overridden by the
+// given command-line arguments. The tests are skipped when there is no test database.
+func newTestServer(t *testing.T, args ...string) *testServer {
+	t.Helper()
+
+	// Pick up queued emails quickly, so that the tests don't wait for them
+	args = append([]string{"-jobs-poll-interval=20ms"}, args...)
+
+	models, db := datatest.NewModels(t)
+	app := newTestApplication(t, models, args...)
+
+	app.jobs = jobs.New(db, jobs.Options{
+		Concurrency:  app.config.jobs.concurrency,
+		PollInterval: app.config.jobs.pollInterval,
+		OnError:      app.logger.PrintError,
+	})
+	app.schema = schema.Runner{DB: db, LockTimeout: app.config.schema.lockTimeout}
+
+	app.registerJobs()
+	app.jobs.Start()
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := app.jobs.Shutdown(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	ts := httptest.NewServer(app.routes())
+	t.Cleanup(ts.Close)
+
+	return &testServer{Server: ts, app: app, mailer: app.mailer.(*mailer.Recorder)}
+}
+
+// Send a request with a JSON body (unless body is nil) and the given authentication
+// token (unless it is empty), and decode the JSON response into dst (unless it is nil).
+// The response's status code is returned.
+func (ts *testServer) do(t *testing.T, method, path, token string, body, dst interface{}) int {
+	t.Helper()
+
+	var reqBody io.Reader
+
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reqBody = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, ts.URL+path, reqBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if dst != nil {
+		err = json.NewDecoder(res.Body).Decode(dst)
+		if err != nil {
+			t.Fatalf("%s %s: decoding the %d response: %v", method, path, res.StatusCode, err)
+		}
+	}
+
+	return res.StatusCode
+}
+
+// Wait for the job workers to send an email to the recipient and return it
+func (ts *testServer) waitForEmail(t *testing.T, recipient, templateFile string) mailer.Message {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		for _, msg := range ts.mailer.Messages() {
+			if msg.Recipient == recipient && msg.TemplateFile == templateFile {
+				return msg
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatalf("no %s email was sent to %s", templateFile, recipient)
+
+	return mailer.Message{}
+}
+
+// Register and activate a user with the activation token from the welcome email, and
+// return an authentication token for them
+func (ts *testServer) signUp(t *testing.T, email string) string {
+	t.Helper()
+
+	password := "pa55word1234"
+
+	status := ts.do(t, http.MethodPost, "/v1/users", "", map[string]string{
+		"name":     "Alice Smith",
+		"email":    email,
+		"password": password,
+	}, nil)
+	if status != http.StatusAccepted {
+		t.Fatalf("registering: got status %d; want %d", status, http.StatusAccepted)
+	}
+
+	msg := ts.waitForEmail(t, email, "user_welcome.tmpl")
+
+	activationToken, _ := msg.Data.(map[string]interface{})["activationToken"].(string)
+	if activationToken == "" {
+		t.Fatalf("the welcome email has no activation token: %v", msg.Data)
+	}
+
+	var activated struct {
+		User data.User `json:"user"`
+	}
+
+	status = ts.do(t, http.MethodPut, "/v1/users/activated", "", map[string]string{"token": activationToken}, &activated)
+	if status != http.StatusOK {
+		t.Fatalf("activating: got status %d; want %d", status, http.StatusOK)
+	}
+
+	if !activated.User.Activated {
+		t.Fatal("the user isn't activated after using the activation token")
+	}
+
+	var authenticated struct {
+		Token struct {
+			PlainText string `json:"token"`
+		} `json:"authentication_token"`
+	}
+
+	status = ts.do(t, http.MethodPost, "/v1/tokens/authentication", "", map[string]string{
+		"email":    email,
+		"password": password,
+	}, &authenticated)
+	if status != http.StatusCreated {
+		t.Fatalf("authenticating: got status %d; want %d", status, http.StatusCreated)
+	}
+
+	return authenticated.Token.PlainText
+}
+
+// Grant the permissions to the user the email address belongs to
+func (ts *testServer) grant(t *testing.T, email string, codes ...string) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	user, err := ts.app.models.User.GetByEmail(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ts.app.models.Permissions.AddForUser(ctx, user.ID, codes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type movieEnvelope struct {
+	Movie struct {
+		ID      int64    `json:"id"`
+		Title   string   `json:"title"`
+		Year    int32    `json:"year"`
+		Genres  []string `json:"genres"`
+		Version int32    `json:"version"`
+	} `json:"movie"`
+}
+
+func TestEndToEndMovieCRUD(t *testing.T) {
+	ts := newTestServer(t, "-limiter-enabled=false")
+
+	token := ts.signUp(t, "alice@example.com")
+	ts.grant(t, "alice@example.com", "movies:write")
+
+	var created movieEnvelope
+
+	status := ts.do(t, http.MethodPost, "/v1/movies", token, map[string]interface{}{
+		"title":   "Moana",
+		"year":    2016,
+		"runtime": "107 mins",
+		"genres":  []string{"animation", "adventure"},
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("creating: got status %d; want %d", status, http.StatusCreated)
+	}
+
+	path := "/v1/movies/" + strconv.FormatInt(created.Movie.ID, 10)
+
+	var shown movieEnvelope
+
+	status = ts.do(t, http.MethodGet, path, token, nil, &shown)
+	if status != http.StatusOK {
+		t.Fatalf("showing: got status %d; want %d", status, http.StatusOK)
+	}
+
+	if shown.Movie.Title != "Moana" || shown.Movie.Year != 2016 || shown.Movie.Version != 1 {
+		t.Errorf("got movie %+v; want Moana (2016) at version 1", shown.Movie)
+	}
+
+	var updated movieEnvelope
+
+	status = ts.do(t, http.MethodPatch, path, token, map[string]interface{}{"title": "Moana 2", "year": 2024}, &updated)
+	if status != http.StatusOK {
+		t.Fatalf("updating: got status %d; want %d", status, http.StatusOK)
+	}
+
+	if updated.Movie.Title != "Moana 2" || updated.Movie.Year != 2024 || updated.Movie.Version != 2 {
+		t.Errorf("got movie %+v after updating; want Moana 2 (2024) at version 2", updated.Movie)
+	}
+
+	var listed struct {
+		Movies []struct {
+			ID int64 `json:"id"`
+		} `json:"movies"`
+	}
+
+	status = ts.do(t, http.MethodGet, "/v1/movies?title=moana", token, nil, &listed)
+	if status != http.StatusOK {
+		t.Fatalf("listing: got status %d; want %d", status, http.StatusOK)
+	}
+
+	if len(listed.Movies) != 1 || listed.Movies[0].ID != created.Movie.ID {
+		t.Errorf("got movies %+v; want only movie %d", listed.Movies, created.Movie.ID)
+	}
+
+	status = ts.do(t, http.MethodDelete, path, token, nil, nil)
+	if status != http.StatusOK {
+		t.Fatalf("deleting: got status %d; want %d", status, http.StatusOK)
+	}
+
+	status = ts.do(t, http.MethodGet, path, token, nil, nil)
+	if status != http.StatusNotFound {
+		t.Errorf("showing a deleted movie: got status %d; want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestEndToEndPermissions(t *testing.T) {
+	ts := newTestServer(t, "-limiter-enabled=false")
+
+	movie := map[string]interface{}{
+		"title":   "Moana",
+		"year":    2016,
+		"runtime": "107 mins",
+		"genres":  []string{"animation"},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{name: "anonymous read", method: http.MethodGet, path: "/v1/movies", token: "", want: http.StatusUnauthorized},
+		{name: "invalid token", method: http.MethodGet, path: "/v1/movies", token: "ABCDEFGHIJKLMNOPQRSTUVWXYZ", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := ts.do(t, tt.method, tt.path, tt.token, nil, nil)
+			if status != tt.want {
+				t.Errorf("got status %d; want %d", status, tt.want)
+			}
+		})
+	}
+
+	// New users can read movies but not write them
+	token := ts.signUp(t, "bob@example.com")
+
+	status := ts.do(t, http.MethodGet, "/v1/movies", token, nil, nil)
+	if status != http.StatusOK {
+		t.Errorf("reading as a new user: got status %d; want %d", status, http.StatusOK)
+	}
+
+	status = ts.do(t, http.MethodPost, "/v1/movies", token, movie, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("writing as a new user: got status %d; want %d", status, http.StatusForbidden)
+	}
+
+	ts.grant(t, "bob@example.com", "movies:write")
+
+	status = ts.do(t, http.MethodPost, "/v1/movies", token, movie, nil)
+	if status != http.StatusCreated {
+		t.Errorf("writing with movies:write: got status %d; want %d", status, http.StatusCreated)
+	}
+
+	// Users who haven't activated their account can't use the API, even with the
+	// permission
+	status = ts.do(t, http.MethodPost, "/v1/users", "", map[string]string{
+		"name":     "Carol Smith",
+		"email":    "carol@example.com",
+		"password": "pa55word1234",
+	}, nil)
+	if status != http.StatusAccepted {
+		t.Fatalf("registering: got status %d; want %d", status, http.StatusAccepted)
+	}
+
+	var authenticated struct {
+		Token struct {
+			PlainText string `json:"token"`
+		} `json:"authentication_token"`
+	}
+
+	status = ts.do(t, http.MethodPost, "/v1/tokens/authentication", "", map[string]string{
+		"email":    "carol@example.com",
+		"password": "pa55word1234",
+	}, &authenticated)
+	if status != http.StatusCreated {
+		t.Fatalf("authenticating: got status %d; want %d", status, http.StatusCreated)
+	}
+
+	status = ts.do(t, http.MethodGet, "/v1/movies", authenticated.Token.PlainText, nil, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("reading as an inactive user: got status %d; want %d", status, http.StatusForbidden)
+	}
+}
+
+func TestEndToEndRateLimit(t *testing.T) {
+	ts := newTestServer(t, "-limiter-rps=0.01", "-limiter-burst=3")
+
+	for i := 1; i <= 3; i++ {
+		status := ts.do(t, http.MethodGet, "/v1/meta", "", nil, nil)
+		if status != http.StatusOK {
+			t.Fatalf("request %d: got status %d; want %d", i, status, http.StatusOK)
+		}
+	}
+
+	status := ts.do(t, http.MethodGet, "/v1/meta", "", nil, nil)
+	if status != http.StatusTooManyRequests {
+		t.Errorf("request over the burst: got status %d; want %d", status, http.StatusTooManyRequests)
+	}
+
+	// The healthcheck isn't rate limited
+	status = ts.do(t, http.MethodGet, "/v1/healthcheck", "", nil, nil)
+	if status != http.StatusOK {
+		t.Errorf("healthcheck: got status %d; want %d", status, http.StatusOK)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+)
+
+// Define an emailEvent struct for a bounce or complaint reported by the email provider,
+// holding the address it is about and the delivery status it gives the address
+type emailEvent struct {
+	email  string
+	status string
+}
+
+// The emailWebhookAuthorized() helper checks the secret sent in the token query
+// parameter of an email provider webhook, sending a 401 response if it is wrong. The
+// secret is compared in constant time, so that it can't be guessed from the timing.
+func (app *application) emailWebhookAuthorized(w http.ResponseWriter, r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(app.config.smtp.webhookSecret)) != 1 {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return false
+	}
+
+	return true
+}
+
+// Handler for the "POST /v1/webhooks/email/ses" endpoint, which receives the bounce and
+// complaint notifications of Amazon SES, delivered by an SNS topic. The URL confirming
+// the topic's subscription is logged rather than fetched, so that an operator can check
+// it is one of Amazon's before visiting it.
+func (app *application) sesWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.emailWebhookAuthorized(w, r) {
+		return
+	}
+
+	var input struct {
+		Type         string `json:"Type"`
+		Message      string `json:"Message"`
+		TopicARN     string `json:"TopicArn"`
+		SubscribeURL string `json:"SubscribeURL"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	switch input.Type {
+	case "SubscriptionConfirmation":
+		app.logger.PrintInfo("ses webhook subscription needs confirming", map[string]string{
+			"topic_arn":     input.TopicARN,
+			"subscribe_url": input.SubscribeURL,
+		})
+	case "Notification":
+		events, err := parseSESNotification(input.Message)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		err = app.recordEmailEvents(r, events)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Return the events of an SES notification. Permanent bounces mark the address as
+// bounced, other bounces as soft bounced, and complaints as complained. Other kinds of
+// notification, such as deliveries, have no events.
+func parseSESNotification(message string) ([]emailEvent, error) {
+	type recipient struct {
+		EmailAddress string `json:"emailAddress"`
+	}
+
+	var notification struct {
+		NotificationType string `json:"notificationType"`
+		Bounce           struct {
+			BounceType        string      `json:"bounceType"`
+			BouncedRecipients []recipient `json:"bouncedRecipients"`
+		} `json:"bounce"`
+		Complaint struct {
+			ComplainedRecipients []recipient `json:"complainedRecipients"`
+		} `json:"complaint"`
+	}
+
+	err := json.Unmarshal([]byte(message), &notification)
+	if err != nil {
+		return nil, fmt.Errorf("body contains an invalid SES notification message: %w", err)
+	}
+
+	var events []emailEvent
+
+	switch notification.NotificationType {
+	case "Bounce":
+		status := data.EmailStatusSoftBounce
+		if notification.Bounce.BounceType == "Permanent" {
+			status = data.EmailStatusBounced
+		}
+
+		for _, recipient := range notification.Bounce.BouncedRecipients {
+			events = append(events, emailEvent{email: recipient.EmailAddress, status: status})
+		}
+	case "Complaint":
+		for _, recipient := range notification.Complaint.ComplainedRecipients {
+			events = append(events, emailEvent{email: recipient.EmailAddress, status: data.EmailStatusComplained})
+		}
+	}
+
+	return events, nil
+}
+
+// Handler for the "POST /v1/webhooks/email/sendgrid" endpoint, which receives batches
+// of SendGrid event webhook events. Bounces mark the address as bounced, blocked emails
+// as soft bounced, and spam reports as complained. Other events are ignored.
+func (app *application) sendgridWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.emailWebhookAuthorized(w, r) {
+		return
+	}
+
+	var input []struct {
+		Email string `json:"email"`
+		Event string `json:"event"`
+		Type  string `json:"type"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var events []emailEvent
+
+	for _, event := range input {
+		switch {
+		case event.Event == "bounce" && event.Type == "blocked":
+			events = append(events, emailEvent{email: event.Email, status: data.EmailStatusSoftBounce})
+		case event.Event == "bounce":
+			events = append(events, emailEvent{email: event.Email, status: data.EmailStatusBounced})
+		case event.Event == "spamreport":
+			events = append(events, emailEvent{email: event.Email, status: data.EmailStatusComplained})
+		}
+	}
+
+	err = app.recordEmailEvents(r, events)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Record the delivery status reported for each address. Addresses which don't belong to
+// a user, such as those of invitations, are skipped.
+func (app *application) recordEmailEvents(r *http.Request, events []emailEvent) error {
+	for _, event := range events {
+		err := app.models.User.SetEmailStatus(r.Context(), event.email, event.status)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			return err
+		}
+
+		if err == nil {
+			app.logger.PrintInfo("email delivery problem recorded", map[string]string{"status": event.status})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+)
+
+// The stable, machine-readable codes sent alongside the message of every error
+// response, so that clients can branch on them rather than on the English messages.
+// Codes are never changed or reused once published; add new ones to errorCodes below.
+const (
+	codeServerError                = "server_error"
+	codeNotFound                   = "not_found"
+	codeMethodNotAllowed           = "method_not_allowed"
+	codeBadRequest                 = "bad_request"
+	codeUnknownField               = "unknown_field"
+	codeBodyTooLarge               = "body_too_large"
+	codeValidationFailed           = "validation_failed"
+	codeEditConflict               = "edit_conflict"
+	codeInvalidTransition          = "invalid_transition"
+	codeRateLimited                = "rate_limited"
+	codeEmailRateLimited           = "email_rate_limited"
+	codeOverloaded                 = "overloaded"
+	codeDependencyUnavailable      = "dependency_unavailable"
+	codeDeadlineExceeded           = "deadline_exceeded"
+	codeInvalidCredentials         = "invalid_credentials"
+	codeInvalidToken               = "invalid_token"
+	codeTokenExpired               = "token_expired"
+	codeInvalidSignature           = "invalid_signature"
+	codeAuthenticationRequired     = "authentication_required"
+	codeInactiveAccount            = "inactive_account"
+	codeNotPermitted               = "not_permitted"
+	codeTokenNotPermitted          = "token_not_permitted"
+	codeServiceAccountNotPermitted = "service_account_not_permitted"
+	codeImpersonationNotPermitted  = "impersonation_not_permitted"
+	codeTOSNotAccepted             = "tos_not_accepted"
+	codeBanned                     = "banned"
+	codeMovieNotOwned              = "movie_not_owned"
+
+	codeMovieNotFound          = "movie_not_found"
+	codeReleaseNotFound        = "release_not_found"
+	codeMovieChangeNotFound    = "movie_change_not_found"
+	codeCollectionNotFound     = "collection_not_found"
+	codeReviewNotFound         = "review_not_found"
+	codeReportNotFound         = "report_not_found"
+	codeNotificationNotFound   = "notification_not_found"
+	codeSavedSearchNotFound    = "saved_search_not_found"
+	codeDigestNotFound         = "digest_subscription_not_found"
+	codeServiceAccountNotFound = "service_account_not_found"
+	codeUserNotFound           = "user_not_found"
+	codeInvitationNotFound     = "invitation_not_found"
+	codeBanNotFound            = "ban_not_found"
+	codeRateLimitNotFound      = "rate_limit_not_found"
+	codeOperationNotFound      = "operation_not_found"
+	codeMailTemplateNotFound   = "mail_template_not_found"
+)
+
+// Struct used for holding a single entry of the error code registry
+type errorCodeEntry struct {
+	Code        string `json:"code"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+// The registry of error codes, served by the "GET /v1/error-codes" endpoint so that
+// client authors can see every code they may need to handle
+var errorCodes = []errorCodeEntry{
+	{codeServerError, http.StatusInternalServerError, "The server encountered an unexpected problem"},
+	{codeNotFound, http.StatusNotFound, "No endpoint matches the request path"},
+	{codeMethodNotAllowed, http.StatusMethodNotAllowed, "The endpoint doesn't support the request method; the Allow header lists the methods it does"},
+	{codeBadRequest, http.StatusBadRequest, "The request body or query string couldn't be parsed"},
+	{codeUnknownField, http.StatusBadRequest, "The request body contains a field the endpoint doesn't accept"},
+	{codeBodyTooLarge, http.StatusRequestEntityTooLarge, "The request body is larger than the endpoint accepts"},
+	{codeValidationFailed, http.StatusUnprocessableEntity, "Some fields are invalid; the error maps each of them to its message"},
+	{codeEditConflict, http.StatusConflict, "The record was changed by another request; fetch it again and retry"},
+	{codeInvalidTransition, http.StatusConflict, "The review, report or proposed change can't be moved to the requested status"},
+	{codeRateLimited, http.StatusTooManyRequests, "The client has sent too many requests"},
+	{codeEmailRateLimited, http.StatusTooManyRequests, "Too many activation or password reset emails have been sent to the address; retry after the Retry-After header"},
+	{codeOverloaded, http.StatusServiceUnavailable, "The server is handling too many requests; retry after the Retry-After header"},
+	{codeDependencyUnavailable, http.StatusServiceUnavailable, "A dependency such as the database is unavailable; retry after the Retry-After header"},
+	{codeDeadlineExceeded, http.StatusGatewayTimeout, "The time budget set with the X-Request-Timeout or grpc-timeout header ran out before the request was completed"},
+	{codeInvalidCredentials, http.StatusUnauthorized, "The email address or password is wrong"},
+	{codeInvalidToken, http.StatusUnauthorized, "The authentication token or service account key is missing, malformed or unknown"},
+	{codeTokenExpired, http.StatusUnauthorized, "The token has expired; request a new one. Sent with a 422 status code for activation and password reset tokens"},
+	{codeInvalidSignature, http.StatusUnauthorized, "The request signature is missing, invalid or has already been used"},
+	{codeAuthenticationRequired, http.StatusUnauthorized, "The endpoint requires authentication"},
+	{codeInactiveAccount, http.StatusForbidden, "The user must activate their account first"},
+	{codeNotPermitted, http.StatusForbidden, "The user doesn't hold the permission the endpoint requires"},
+	{codeTokenNotPermitted, http.StatusForbidden, "The token or service account isn't allowed the permission the endpoint requires"},
+	{codeServiceAccountNotPermitted, http.StatusForbidden, "The endpoint can't be used by service accounts"},
+	{codeImpersonationNotPermitted, http.StatusForbidden, "The endpoint can't be used with an impersonation token, or the user can't be impersonated"},
+	{codeTOSNotAccepted, http.StatusPreconditionRequired, "The user must accept the terms of service version given in tos_version with POST /v1/users/me/tos-acceptance"},
+	{codeBanned, http.StatusForbidden, "The client's IP address has been temporarily banned; retry after the Retry-After header"},
+	{codeMovieNotOwned, http.StatusForbidden, "The movie was created by another user; editing it requires the movies:admin permission"},
+	{codeMovieNotFound, http.StatusNotFound, "The movie doesn't exist"},
+	{codeReleaseNotFound, http.StatusNotFound, "The release doesn't exist for the movie"},
+	{codeMovieChangeNotFound, http.StatusNotFound, "The proposed change doesn't exist for the movie"},
+	{codeCollectionNotFound, http.StatusNotFound, "The collection doesn't exist"},
+	{codeReviewNotFound, http.StatusNotFound, "The review doesn't exist or isn't visible"},
+	{codeReportNotFound, http.StatusNotFound, "The report doesn't exist"},
+	{codeNotificationNotFound, http.StatusNotFound, "The notification doesn't exist or belongs to another user"},
+	{codeSavedSearchNotFound, http.StatusNotFound, "The saved search doesn't exist or belongs to another user"},
+	{codeDigestNotFound, http.StatusNotFound, "The user isn't subscribed to the digest emails"},
+	{codeServiceAccountNotFound, http.StatusNotFound, "The service account doesn't exist or belongs to another user"},
+	{codeUserNotFound, http.StatusNotFound, "The user doesn't exist"},
+	{codeInvitationNotFound, http.StatusNotFound, "The invitation doesn't exist or has already been accepted"},
+	{codeBanNotFound, http.StatusNotFound, "The IP address isn't banned"},
+	{codeRateLimitNotFound, http.StatusNotFound, "The client isn't tracked by the rate limiter of the instance which handled the request"},
+	{codeOperationNotFound, http.StatusNotFound, "The schema operation doesn't exist"},
+	{codeMailTemplateNotFound, http.StatusNotFound, "The email template doesn't exist"},
+}
+
+// Handler for the "GET /v1/error-codes" endpoint
+func (app *application) errorCodesHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"error_codes": errorCodes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
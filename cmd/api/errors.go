@@ -1,22 +1,51 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/circuit"
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/errtrack"
+	"github.com/LuisBarroso37/Greenlight/internal/mailer"
+	"github.com/LuisBarroso37/Greenlight/internal/reqctx"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
 )
 
 // Generic helper for logging an error message
 func (app *application) logError(r *http.Request, err error) {
-	app.logger.PrintError(err, map[string]string{
-		"request_method": r.Method,
-		"request_url":    r.URL.String(),
-	})
+	properties := reqctx.FromContext(r.Context()).LogProperties()
+	properties["request_method"] = r.Method
+	properties["request_url"] = r.URL.String()
+
+	app.logger.PrintError(err, properties)
+}
+
+// Send an error to the error tracker along with the request and the user it occurred
+// for. The stack trace starts at the caller of serverErrorResponse().
+func (app *application) reportError(r *http.Request, err error) {
+	c := errtrack.Context{
+		Request:   r,
+		RequestID: app.contextGetRequestID(r),
+	}
+
+	// The user isn't in the context yet if the error happened before authentication
+	if user := app.contextLookupUser(r); user != nil {
+		c.UserID = user.ID
+	}
+
+	app.tracker.Capture(err, c, 2)
 }
 
 // Generic helper for sending JSON-formatted error
-// messages to the client with a given status code
-func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
-	env := envelope{"error": message}
+// messages to the client with a given status code and error code
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, code string, message interface{}) {
+	env := envelope{"error": message, "code": code}
 
 	// Write the response using the writeJSON() helper. If this happens to return an
 	// error then log it, and fall back to sending the client an empty response with a
@@ -30,54 +59,185 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 
 // This method will be used to send a 500 Internal Server Error status code when our application encounters an unexpected problem at runtime
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	// A dependency which is down isn't a bug, and would flood the logs and the error
+	// tracker while its circuit breaker is open
+	var openErr *circuit.OpenError
+	if errors.As(err, &openErr) {
+		app.dependencyUnavailableResponse(w, r, openErr)
+		return
+	}
+
+	// Neither is running out of the time budget the client set for the request, which
+	// makes the database queries fail with a variety of errors
+	if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		app.deadlineExceededResponse(w, r)
+		return
+	}
+
+	// Nor is the client going away, which cancels the request's queries
+	if errors.Is(r.Context().Err(), context.Canceled) {
+		app.clientClosedResponse(w, r)
+		return
+	}
+
 	app.logError(r, err)
+	app.reportError(r, err)
 
 	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	app.errorResponse(w, r, http.StatusInternalServerError, codeServerError, message)
 }
 
 // This method will be used to send a 404 Not Found status code and JSON response to the client
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
 	message := "the requested resource could not be found"
-	app.errorResponse(w, r, http.StatusNotFound, message)
+	app.errorResponse(w, r, http.StatusNotFound, codeNotFound, message)
+}
+
+// This method will be used to send a 404 Not Found status code with the error code
+// naming the resource, such as movie_not_found, when the requested record doesn't exist
+func (app *application) resourceNotFoundResponse(w http.ResponseWriter, r *http.Request, code string) {
+	message := "the requested resource could not be found"
+	app.errorResponse(w, r, http.StatusNotFound, code, message)
 }
 
 // This method will be used to send a 405 Method Not Allowed
-// status code and JSON response to the client
+// status code and JSON response to the client. The router sets the Allow header to the
+// methods registered for the path before calling it.
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
 	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, message)
 }
 
 // This method will be used to send a 400 Bad Request
-// status code and JSON response to the client
+// status code and JSON response to the client. Bodies which were too large are sent
+// a 413 Request Entity Too Large status code instead.
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+	var tooLarge *bodyTooLargeError
+	var unknownField *unknownFieldError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		app.bodyTooLargeResponse(w, r, tooLarge)
+	case errors.As(err, &unknownField):
+		app.unknownFieldResponse(w, r, unknownField)
+	default:
+		app.errorResponse(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+	}
+}
+
+// This method will be used to send a 400 Bad Request status code for a body containing
+// an unknown field, naming the field and linking to the documentation when it is
+// configured
+func (app *application) unknownFieldResponse(w http.ResponseWriter, r *http.Request, err *unknownFieldError) {
+	message := map[string]interface{}{
+		"message": err.Error(),
+		"field":   err.field,
+	}
+
+	if app.config.docsURL != "" {
+		message["documentation_url"] = app.config.docsURL + "#unknown-fields"
+	}
+
+	app.errorResponse(w, r, http.StatusBadRequest, codeUnknownField, message)
+}
+
+// This method will be used to send a 413 Request Entity Too Large status code, with the
+// limit which was exceeded so that clients can split their requests
+func (app *application) bodyTooLargeResponse(w http.ResponseWriter, r *http.Request, err *bodyTooLargeError) {
+	message := map[string]interface{}{
+		"message":   err.Error(),
+		"max_bytes": err.maxBytes,
+	}
+
+	app.errorResponse(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge, message)
 }
 
 // This method will be used to send a 422 Unprocessable Entity status code and
-// the contents of the errors map from our Validator type as a JSON response body
-func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+// the contents of the errors map from our Validator type as a JSON response body. The
+// code, message and parameters of each failed check are sent in the fields object, so
+// that clients can localize the messages.
+func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator) {
+	env := envelope{"error": v.Errors, "code": codeValidationFailed, "fields": v.Fields}
+
+	err := app.writeJSON(w, http.StatusUnprocessableEntity, env, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
 }
 
 // This method will be used to send a 409 Conflict status code and
 // JSON response to the client
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "unable to update the record due to an edit conflict, please try again"
-	app.errorResponse(w, r, http.StatusConflict, message)
+	app.errorResponse(w, r, http.StatusConflict, codeEditConflict, message)
+}
+
+// This method will be used to send a 409 Conflict status code when a review or report
+// can't be moved to the requested moderation status
+func (app *application) invalidTransitionResponse(w http.ResponseWriter, r *http.Request, resource, from, to string) {
+	message := fmt.Sprintf("a %s which is %s can't be %s", resource, from, to)
+	app.errorResponse(w, r, http.StatusConflict, codeInvalidTransition, message)
 }
 
 // This method will be used to send a 429 Too Many Requests status code when our application encounters too many requests at the same time
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
-	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+	app.errorResponse(w, r, http.StatusTooManyRequests, codeRateLimited, message)
+}
+
+// This method will be used to send a 429 Too Many Requests status code when an email address has already been sent too many emails
+func (app *application) emailRateLimitedResponse(w http.ResponseWriter, r *http.Request, err *mailer.RecipientLimitError) {
+	retryAfter := int(err.RetryAfter.Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	message := "too many emails have been sent to this address, please try again later"
+	app.errorResponse(w, r, http.StatusTooManyRequests, codeEmailRateLimited, message)
+}
+
+func (app *application) overloadedResponse(w http.ResponseWriter, r *http.Request) {
+	retryAfter := int(app.config.concurrency.queueWait.Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	message := "the server is handling too many requests, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, codeOverloaded, message)
+}
+
+// This method will be used to send a 503 Service Unavailable status code when a dependency such as the database is failing and its circuit breaker is open
+func (app *application) dependencyUnavailableResponse(w http.ResponseWriter, r *http.Request, err *circuit.OpenError) {
+	retryAfter := int(err.RetryAfter.Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	message := "the server is temporarily unable to process your request, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, codeDependencyUnavailable, message)
+}
+
+// This method will be used to send a 504 Gateway Timeout status code when the time budget the client set for the request runs out
+func (app *application) deadlineExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the request could not be completed within its time budget"
+	app.errorResponse(w, r, http.StatusGatewayTimeout, codeDeadlineExceeded, message)
+}
+
+// Publish the number of requests abandoned by the client while they were handled
+var totalRequestsCancelled = expvar.NewInt("total_requests_cancelled")
+
+// The status code recorded for requests abandoned by the client, following nginx. It
+// is only seen by the metrics and the logs, as there's no one left to send it to.
+const statusClientClosedRequest = 499
+
+// This method will be used when the client has closed the connection (or cancelled
+// the HTTP/2 stream) while the request was being handled. The request's queries are
+// cancelled along with its context, so the handler fails, but that isn't a server
+// error and isn't logged or reported as one.
+func (app *application) clientClosedResponse(w http.ResponseWriter, r *http.Request) {
+	totalRequestsCancelled.Add(1)
+	w.WriteHeader(statusClientClosedRequest)
 }
 
 // This method will be used to send a 401 Unauthorized status code forproviding invalid authentication credentials
 func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid authentication credentials"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidCredentials, message)
 }
 
 // This method will be used to send a 401 Unauthorized status code for providing an invalid authentication token
@@ -85,23 +245,104 @@ func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter
 	w.Header().Set("WWW-Authenticate", "Bearer")
 
 	message := "invalid or missing authentication token"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidToken, message)
+}
+
+// This method will be used to send the token_expired error code for a token which exists but has expired, so that clients can prompt the user to request a new one
+func (app *application) expiredTokenResponse(w http.ResponseWriter, r *http.Request, scope string) {
+	switch scope {
+	case data.ScopeAuthentication:
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="the token has expired"`)
+
+		message := "authentication token has expired, please authenticate again"
+		app.errorResponse(w, r, http.StatusUnauthorized, codeTokenExpired, message)
+	case data.ScopeActivation:
+		message := "activation token has expired, please request a new one"
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, codeTokenExpired, message)
+	default:
+		message := "password reset token has expired, please request a new one"
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, codeTokenExpired, message)
+	}
+}
+
+// This method will be used to send a 401 Unauthorized status code for a signed request whose signature is missing, invalid or has already been used
+func (app *application) invalidSignatureResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid or missing request signature"
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidSignature, message)
 }
 
 // This method will be used to send a 401 Unauthorized status code due to user not being authenticated when trying to access a resource
 func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
 	message := "you must be authenticated to access this resource"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeAuthenticationRequired, message)
 }
 
 // This method will be used to send a 403 Forbidden status code for user not being activated when trying to access a resource
 func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
 	message := "your user account must be activated to access this resource"
-	app.errorResponse(w, r, http.StatusForbidden, message)
+	app.errorResponse(w, r, http.StatusForbidden, codeInactiveAccount, message)
 }
 
 // This method will be used to send a 403 Forbidden status code for user not having necessary permissions when trying to access a resource
 func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "your user account doesn't have the necessary permissions to access this resource"
-	app.errorResponse(w, r, http.StatusForbidden, message)
+	app.errorResponse(w, r, http.StatusForbidden, codeNotPermitted, message)
+}
+
+// This method will be used to send a 403 Forbidden status code for an authentication token or service account whose permissions don't include the one needed for a resource
+func (app *application) tokenNotPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your authentication token or service account isn't granted the necessary permissions to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, codeTokenNotPermitted, message)
+}
+
+// This method will be used to send a 403 Forbidden status code for a user trying to edit a movie created by someone else in ownership mode
+func (app *application) movieNotOwnedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this movie was created by another user, and you don't have the permission to edit other users' movies"
+	app.errorResponse(w, r, http.StatusForbidden, codeMovieNotOwned, message)
+}
+
+// This method will be used to send a 403 Forbidden status code for a service account trying to access a resource reserved for users
+func (app *application) serviceAccountNotPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "service accounts can't access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, codeServiceAccountNotPermitted, message)
+}
+
+// This method will be used to send a 403 Forbidden status code for an admin acting as a user trying to manage the user's credentials
+func (app *application) impersonationNotPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this resource can't be accessed while impersonating a user"
+	app.errorResponse(w, r, http.StatusForbidden, codeImpersonationNotPermitted, message)
+}
+
+// This method will be used to send a 403 Forbidden status code for an admin trying to impersonate a user holding permissions they don't hold themselves
+func (app *application) cannotImpersonateResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you can't impersonate yourself or a user holding permissions you don't hold"
+	app.errorResponse(w, r, http.StatusForbidden, codeImpersonationNotPermitted, message)
+}
+
+// This method will be used to send a 428 Precondition Required status code to a user who hasn't accepted the current terms of service, along with the version to accept
+func (app *application) tosNotAcceptedResponse(w http.ResponseWriter, r *http.Request) {
+	env := envelope{
+		"error":       "you must accept the current terms of service before continuing",
+		"code":        codeTOSNotAccepted,
+		"tos_version": app.config.tos.version,
+	}
+
+	if app.config.tos.url != "" {
+		env["tos_url"] = app.config.tos.url
+	}
+
+	err := app.writeJSON(w, http.StatusPreconditionRequired, env, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
+
+// This method will be used to send a 403 Forbidden status code to a client whose IP address has been temporarily banned
+func (app *application) bannedResponse(w http.ResponseWriter, r *http.Request, b *ban) {
+	retryAfter := int(time.Until(b.Expires).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	message := "your IP address has been temporarily banned due to repeated failed or rate limited requests"
+	app.errorResponse(w, r, http.StatusForbidden, codeBanned, message)
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/events"
+)
+
+// The types of the domain events published to the message broker
+const (
+	eventMovieCreated   = "movie.created"
+	eventMovieUpdated   = "movie.updated"
+	eventMovieDeleted   = "movie.deleted"
+	eventMoviePublished = "movie.published"
+	eventUserRegistered = "user.registered"
+	eventUserActivated  = "user.activated"
+)
+
+// Define a userEvent struct holding the data of user events. The email address is left
+// out, as consumers of the events don't need it to follow the lifecycle of an account.
+type userEvent struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Activated bool      `json:"activated"`
+}
+
+// Return the data of an event about the given user
+func newUserEvent(user *data.User) userEvent {
+	return userEvent{
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		Name:      user.Name,
+		Activated: user.Activated,
+	}
+}
+
+// The openPublisher() function returns the publisher for the configured message broker.
+// The address is a NATS URL, or a space separated list of Kafka brokers.
+func openPublisher(broker, addr string) (events.Publisher, error) {
+	switch broker {
+	case "nats":
+		return events.NewNATS(addr)
+	case "kafka":
+		return events.NewKafka(strings.Fields(addr))
+	default:
+		return nil, fmt.Errorf("unknown events broker %q", broker)
+	}
+}
+
+// Define a domainEvent struct holding an event about a record, before it is added to
+// the outbox
+type domainEvent struct {
+	Type string
+	ID   int64
+	Data interface{}
+}
+
+// The saveWithEvent() helper saves a change with save, which returns the domain event
+// about it, and adds the event to the outbox from which it is published to the message
+// broker. The models' queries made with the context passed to save are run in the same
+// transaction as adding the event, so the event is published if and only if the change
+// is saved. The error of save, or of adding the event, is returned for the handler to
+// respond with.
+func (app *application) saveWithEvent(r *http.Request, save func(ctx context.Context) (domainEvent, error)) error {
+	return app.saveWithEvents(r.Context(), app.contextGetRequestID(r), func(ctx context.Context) ([]domainEvent, error) {
+		event, err := save(ctx)
+
+		return []domainEvent{event}, err
+	}, func(err error) {
+		app.logError(r, err)
+	})
+}
+
+// The saveWithEvents() helper does the work of saveWithEvent() for changes which don't
+// come from a request, such as those of background jobs, and which may be about several
+// records. Once the change is committed, movie events are passed to the search indexer
+// and the CDN purger, whose failures are passed to onError as the change has already
+// been saved. Without a broker, save is run on its own and only the indexer and purger
+// are run.
+func (app *application) saveWithEvents(ctx context.Context, requestID string, save func(ctx context.Context) ([]domainEvent, error), onError func(error)) error {
+	var pending []domainEvent
+
+	if app.events == nil {
+		var err error
+
+		pending, err = save(ctx)
+		if err != nil {
+			return err
+		}
+	} else {
+		tx, err := app.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		// Rollback() is a no-op once the transaction has been committed
+		defer tx.Rollback()
+
+		txCtx := data.WithTx(ctx, tx)
+
+		pending, err = save(txCtx)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range pending {
+			err = app.events.AddTx(txCtx, tx, events.Event{
+				Type:        event.Type,
+				AggregateID: event.ID,
+				RequestID:   requestID,
+				Data:        event.Data,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+
+		// The caches are only updated now, so that they never hold a change which was
+		// rolled back
+		data.Committed(txCtx)
+
+		app.events.Wake()
+	}
+
+	for _, event := range pending {
+		if !strings.HasPrefix(event.Type, "movie.") {
+			continue
+		}
+
+		if err := app.queueMovieIndexing(ctx, event.ID); err != nil {
+			onError(err)
+		}
+
+		if err := app.queueCDNPurge(ctx, event.ID); err != nil {
+			onError(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/tomasen/realip"
+)
+
+// Guest tokens start with this prefix, so that they can be told apart from users'
+// authentication tokens and service account keys
+const guestTokenPrefix = "gst_"
+
+// The permissions granted to guests, which only allow reading the catalog
+var guestPermissions = data.Permissions{"movies:read"}
+
+// We'll return these from parseGuestToken() for tokens which weren't signed by us and
+// for tokens which have expired
+var (
+	errInvalidGuestToken = errors.New("invalid guest token")
+	errGuestTokenExpired = errors.New("guest token expired")
+)
+
+// Return a new guest token for a new guest session, along with its expiry. Guest tokens
+// aren't stored anywhere: they hold the session's random ID and expiry, signed with the
+// guest secret, in the format gst_<id>.<expiry>.<signature>.
+func (app *application) newGuestToken() (string, time.Time, error) {
+	randomBytes := make([]byte, 10)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiry := time.Now().Add(app.config.guest.ttl).Truncate(time.Second)
+	payload := hex.EncodeToString(randomBytes) + "." + strconv.FormatInt(expiry.Unix(), 10)
+
+	return guestTokenPrefix + payload + "." + app.signGuestPayload(payload), expiry, nil
+}
+
+// Check a guest token's signature and expiry, returning the ID of its guest session
+func (app *application) parseGuestToken(token string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(token, guestTokenPrefix), ".")
+	if len(parts) != 3 {
+		return "", errInvalidGuestToken
+	}
+
+	payload := parts[0] + "." + parts[1]
+
+	if !hmac.Equal([]byte(parts[2]), []byte(app.signGuestPayload(payload))) {
+		return "", errInvalidGuestToken
+	}
+
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", errInvalidGuestToken
+	}
+
+	if time.Now().Unix() >= expiry {
+		return "", errGuestTokenExpired
+	}
+
+	return parts[0], nil
+}
+
+// Return the signature of a guest token's payload
+func (app *application) signGuestPayload(payload string) string {
+	mac := hmac.New(sha256.New, []byte(app.config.guest.secret))
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Report whether a bearer token is a guest token
+func isGuestToken(token string) bool {
+	return strings.HasPrefix(token, guestTokenPrefix)
+}
+
+// Return the ID of the guest session of the request, reporting whether it carries a
+// valid guest token. It is used by the rate limiter, which runs before authentication.
+func (app *application) requestGuestID(r *http.Request) (string, bool) {
+	if app.guests == nil {
+		return "", false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !isGuestToken(token) {
+		return "", false
+	}
+
+	guestID, err := app.parseGuestToken(token)
+
+	return guestID, err == nil
+}
+
+// Handler for the "POST /v1/tokens/guest" endpoint, which starts a guest session for a
+// client without an account. The guest token grants read-only access to the catalog,
+// and the client is rate limited on the guest session's own quota while it uses it.
+// Each IP address can only get a few tokens, so that clients can't get a fresh quota
+// whenever they run out.
+func (app *application) createGuestTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if app.config.limiter.enabled && !app.guestTokens.Allow("guest-token:"+realip.FromRequest(r)) {
+		app.rateLimitExceededResponse(w, r)
+		return
+	}
+
+	plainText, expiry, err := app.newGuestToken()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token := &data.Token{
+		PlainText:   plainText,
+		Expiry:      expiry,
+		Permissions: guestPermissions,
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"guest_token": token}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
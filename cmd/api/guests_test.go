@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+)
+
+// Guests are limited by IP address as well as on their guest session's quota, so a new
+// guest token mustn't give a client a fresh quota
+func TestGuestsRateLimitedByIP(t *testing.T) {
+	app := newTestApplication(t, data.NewMockModels(), "-guest-sessions", "-guest-secret=s3cret",
+		"-limiter-rps=0.01", "-limiter-burst=4", "-guest-limiter-burst=10")
+
+	ts := &testServer{Server: httptest.NewServer(app.routes()), app: app}
+	t.Cleanup(ts.Close)
+
+	var input struct {
+		Token data.Token `json:"guest_token"`
+	}
+
+	status := ts.do(t, http.MethodPost, "/v1/tokens/guest", "", nil, &input)
+	if status != http.StatusCreated {
+		t.Fatalf("getting the first guest token: got status %d; want %d", status, http.StatusCreated)
+	}
+
+	for i := 1; i <= 2; i++ {
+		status := ts.do(t, http.MethodGet, "/v1/meta", input.Token.PlainText, nil, nil)
+		if status != http.StatusOK {
+			t.Fatalf("request %d: got status %d; want %d", i, status, http.StatusOK)
+		}
+	}
+
+	status = ts.do(t, http.MethodPost, "/v1/tokens/guest", "", nil, &input)
+	if status != http.StatusCreated {
+		t.Fatalf("getting the second guest token: got status %d; want %d", status, http.StatusCreated)
+	}
+
+	status = ts.do(t, http.MethodGet, "/v1/meta", input.Token.PlainText, nil, nil)
+	if status != http.StatusTooManyRequests {
+		t.Errorf("request with the second guest token: got status %d; want %d", status, http.StatusTooManyRequests)
+	}
+}
+
+func TestGuestTokensRateLimitedByIP(t *testing.T) {
+	app := newTestApplication(t, data.NewMockModels(), "-guest-sessions", "-guest-secret=s3cret",
+		"-guest-token-rps=0.01", "-guest-token-burst=2")
+
+	ts := &testServer{Server: httptest.NewServer(app.routes()), app: app}
+	t.Cleanup(ts.Close)
+
+	for i := 1; i <= 2; i++ {
+		status := ts.do(t, http.MethodPost, "/v1/tokens/guest", "", nil, nil)
+		if status != http.StatusCreated {
+			t.Fatalf("guest token %d: got status %d; want %d", i, status, http.StatusCreated)
+		}
+	}
+
+	status := ts.do(t, http.MethodPost, "/v1/tokens/guest", "", nil, nil)
+	if status != http.StatusTooManyRequests {
+		t.Errorf("guest token over the burst: got status %d; want %d", status, http.StatusTooManyRequests)
+	}
+}
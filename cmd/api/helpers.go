@@ -1,15 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/LuisBarroso37/Greenlight/internal/data"
 	"github.com/LuisBarroso37/Greenlight/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
@@ -18,14 +25,20 @@ type envelope map[string]interface{}
 
 // Retrieve the URL parameter `id` from the current request context, then convert it to an integer
 func (app *application) readIDParam(r *http.Request) (int64, error) {
+	return app.readNamedIDParam(r, "id")
+}
+
+// Retrieve an ID URL parameter with the given name from the current request context,
+// for routes with more than one ID (such as /v1/movies/:id/releases/:release_id)
+func (app *application) readNamedIDParam(r *http.Request, name string) (int64, error) {
 	// Extract URL parameters from request context
 	params := httprouter.ParamsFromContext(r.Context())
 
-	// `ByName()` returns a string and `id` must be a positive integer so we try to convert it
-	// If `ìd` cannot be converted to an integer or if it is smaller than 1, throw error
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	// `ByName()` returns a string and the ID must be a positive integer so we try to convert it
+	// If the ID cannot be converted to an integer or if it is smaller than 1, throw error
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 
 	return id, nil
@@ -35,6 +48,18 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 // http.ResponseWriter, the HTTP status code to send, the data to encode to JSON and a
 // header map containing any additional HTTP headers we want to include in the response.
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+	// List any warnings added to the response in the body as well, copying the envelope
+	// so that the caller's map isn't modified
+	if warnings := responseWarnings(w.Header()); len(warnings) > 0 {
+		withWarnings := make(envelope, len(data)+1)
+		for key, value := range data {
+			withWarnings[key] = value
+		}
+
+		withWarnings["_warnings"] = warnings
+		data = withWarnings
+	}
+
 	// Encode the data to JSON
 	js, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
@@ -50,93 +75,326 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 	}
 
 	// Add the "Content-Type: application/json" header, then write the status code and
-	// JSON response
+	// JSON response. The Content-Length is set explicitly so that it is also sent in
+	// response to HEAD requests, where the body is discarded.
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(js)))
 	w.WriteHeader(status)
 	w.Write(js)
 
 	return nil
 }
 
+// Return a strong ETag for the JSON encoding of the given data
+func jsonETag(data interface{}) (string, error) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(js)
+
+	return `"` + hex.EncodeToString(hash[:16]) + `"`, nil
+}
+
+// Build the value of a Link header (RFC 8288) pointing to the first, previous, next
+// and last pages of a paginated list. The links reuse the query string of the current
+// request with the page parameter replaced, and are relative to the request URL so they
+// don't depend on the Host header. Pages fetched with a cursor only link to the first
+// page and, if there is one, the next page. An empty string is returned when there are
+// no results.
+func paginationLinks(u *url.URL, metadata data.Metadata) string {
+	link := func(page int, cursor, rel string) string {
+		query := u.Query()
+		query.Del("cursor")
+		query.Set("page", strconv.Itoa(page))
+
+		if cursor != "" {
+			query.Del("page")
+			query.Set("cursor", cursor)
+		}
+
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, u.Path, query.Encode(), rel)
+	}
+
+	if metadata.CurrentPage == 0 {
+		if metadata.NextCursor == "" {
+			return ""
+		}
+
+		return link(1, "", "first") + ", " + link(0, metadata.NextCursor, "next")
+	}
+
+	links := []string{link(metadata.FirstPage, "", "first")}
+
+	// A client which asked for a page past the end is pointed back to the last page
+	if metadata.CurrentPage > metadata.FirstPage {
+		prev := metadata.CurrentPage - 1
+		if prev > metadata.LastPage {
+			prev = metadata.LastPage
+		}
+
+		links = append(links, link(prev, "", "prev"))
+	}
+
+	if metadata.CurrentPage < metadata.LastPage {
+		links = append(links, link(metadata.CurrentPage+1, "", "next"))
+	}
+
+	links = append(links, link(metadata.LastPage, "", "last"))
+
+	return strings.Join(links, ", ")
+}
+
+// The writePage() helper sends a page of a paginated list, under the given key and
+// alongside its pagination metadata, with a Link header pointing to the neighbouring
+// pages so that generic HTTP clients can paginate without reading the metadata from
+// the body
+func (app *application) writePage(w http.ResponseWriter, r *http.Request, key string, records interface{}, metadata data.Metadata) {
+	headers := make(http.Header)
+	if links := paginationLinks(r.URL, metadata); links != "" {
+		headers.Set("Link", links)
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{key: records, "metadata": metadata}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Define a bodyTooLargeError type for request bodies, or records within them, which are
+// larger than the limit. It is sent with a 413 status code by badRequestResponse().
+type bodyTooLargeError struct {
+	subject  string
+	maxBytes int64
+}
+
+func (e *bodyTooLargeError) Error() string {
+	return fmt.Sprintf("%s must not be larger than %d bytes", e.subject, e.maxBytes)
+}
+
+// Define an unknownFieldError type for JSON objects containing a key which doesn't match
+// any field of the target. It is sent with a link to the documentation by
+// badRequestResponse().
+type unknownFieldError struct {
+	subject string
+	field   string
+}
+
+func (e *unknownFieldError) Error() string {
+	return fmt.Sprintf("%s contains unknown key %q", e.subject, e.field)
+}
+
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, target interface{}) error {
-	// Use http.MaxBytesReader() to limit the size of the request body to 1MB
-	maxBytes := 1_048_576
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	// Limit the size of the request body to the route's limit. Bodies which say they
+	// are too large are turned away before reading them, and http.MaxBytesReader()
+	// stops reading any others once they reach the limit.
+	maxBytes := app.contextGetBodyLimit(r)
+	if r.ContentLength > maxBytes {
+		return &bodyTooLargeError{subject: "body", maxBytes: maxBytes}
+	}
+
+	// Read the whole body, which may need decoding twice when unknown fields are allowed
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+	if err != nil {
+		return jsonDecodeError(err, "body", maxBytes)
+	}
+
+	// Decode the request body into the target destination. Unknown fields are ignored
+	// with a warning on routes which allow them.
+	ignored, err := decodeJSONValue(body, target, "body", maxBytes, app.contextGetAllowUnknownFields(r))
+	if err != nil {
+		return err
+	}
+
+	if ignored != "" {
+		app.addWarning(w, fmt.Sprintf("the body contains unknown key %q, which was ignored", ignored))
+	}
 
+	return nil
+}
+
+// Decode a single JSON value from data into the target. Unknown fields are rejected
+// with an unknownFieldError, unless allowUnknown is set, in which case the first one
+// found is returned so that the caller can warn about it.
+func decodeJSONValue(data []byte, target interface{}, subject string, maxBytes int64, allowUnknown bool) (string, error) {
 	// Initialize the json.Decoder and call the DisallowUnknownFields() method on it
 	// before decoding. This means that if the JSON from the client now includes any
 	// field which cannot be mapped to the target destination, the decoder will return
 	// an error instead of just ignoring the field.
-	decoder := json.NewDecoder(r.Body)
+	decoder := json.NewDecoder(bytes.NewReader(data))
 	decoder.DisallowUnknownFields()
 
-	// Decode the request body into the target destination
+	ignored := ""
+
 	err := decoder.Decode(target)
 	if err != nil {
-		// If there is an error during decoding, start the triage
-		var syntaxError *json.SyntaxError
-		var unmarshalTypeError *json.UnmarshalTypeError
-		var invalidUnmarshalError *json.InvalidUnmarshalError
-
-		switch {
-		// This error occurs when there are syntax errors in the JSON
-		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains malformed JSON (at character %d)", syntaxError.Offset)
-
-		// In some circumstances Decode() may also return an io.ErrUnexpectedEOF error for syntax errors in the JSON
-		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contains malformed JSON")
-
-		//This error occurs when JSON value is of the wrong type for the target destination. If the error relates
-		// to a specific field, then we include that in our error message to make it easier for the client to debug
-		case errors.As(err, &unmarshalTypeError):
-			if unmarshalTypeError.Field != "" {
-				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
-			}
+		err = jsonDecodeError(err, subject, maxBytes)
+
+		var unknownField *unknownFieldError
+		if !allowUnknown || !errors.As(err, &unknownField) {
+			return "", err
+		}
+
+		// Decode again without rejecting unknown fields, which fills in the rest of
+		// the target
+		ignored = unknownField.field
+		decoder = json.NewDecoder(bytes.NewReader(data))
+
+		err = decoder.Decode(target)
+		if err != nil {
+			return "", jsonDecodeError(err, subject, maxBytes)
+		}
+	}
 
-			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+	// Call Decode() again, using a pointer to an empty anonymous struct as the
+	// destination. If the data only contained a single JSON value this will return an
+	// io.EOF error. So if we get anything else, we know that there is additional data
+	// and we return our own custom error message.
+	if decoder.Decode(&struct{}{}) != io.EOF {
+		return "", fmt.Errorf("%s must only contain a single JSON value", subject)
+	}
 
-		// An io.EOF error will be returned by Decode() if the request body is empty
-		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
+	return ignored, nil
+}
+
+// Report whether the request body has the given media type, ignoring any parameters
+// such as the charset
+func hasContentType(r *http.Request, mediaType string) bool {
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && contentType == mediaType
+}
+
+// The readNDJSON() helper streams a newline-delimited JSON (application/x-ndjson) request
+// body, calling handle() for each non-blank line with its line number and a function
+// which decodes the line into a target, as strictly as readJSON() decodes a body. Only
+// one line is held in memory at a time, so the body can be much larger than readJSON()
+// allows. Errors from decoding a line are returned by decode() for handle() to deal
+// with, while an error returned by handle() or from reading the body stops the stream.
+func (app *application) readNDJSON(w http.ResponseWriter, r *http.Request, handle func(line int, decode func(target interface{}) error) error) error {
+	// Limit the whole body to the bulk upload limit, and each line to the route's limit
+	maxBytes := app.config.body.bulkMaxBytes
+	maxLineBytes := app.contextGetBodyLimit(r)
+	if r.ContentLength > maxBytes {
+		return &bodyTooLargeError{subject: "body", maxBytes: maxBytes}
+	}
 
-		// If the JSON contains a field which cannot be mapped to the target destination
-		// then Decode() will now return an error message in the format "json: unknown
-		// field "<name>"". We check for this, extract the field name from the error
-		// and interpolate it into our custom error message.
-		case strings.HasPrefix(err.Error(), "json: unknown field "):
-			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
 
-			return fmt.Errorf("body contains unknown key %s", fieldName)
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), int(maxLineBytes))
 
-		// If the request body exceeds 1MB in size the decode will now fail with the
-		// error "http: request body too large"
-		case err.Error() == "http: request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+	allowUnknown := app.contextGetAllowUnknownFields(r)
+	warned := false
 
-		// A json.InvalidUnmarshalError error will be returned if we pass a non-nil
-		// pointer to Decode(). We catch this error and panic.
-		case errors.As(err, &invalidUnmarshalError):
-			panic(err)
+	line, records := 0, 0
 
-		// For anything else, return the error message as-is
-		default:
+	for scanner.Scan() {
+		line++
+
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		records++
+		subject := fmt.Sprintf("line %d", line)
+
+		decode := func(target interface{}) error {
+			ignored, err := decodeJSONValue(text, target, subject, maxLineBytes, allowUnknown)
+			if err != nil {
+				return err
+			}
+
+			// Only the first ignored key is warned about, so that large uploads don't
+			// fill the response headers with warnings
+			if ignored != "" && !warned {
+				app.addWarning(w, fmt.Sprintf("%s contains unknown key %q, which was ignored", subject, ignored))
+				warned = true
+			}
+
+			return nil
+		}
+
+		err := handle(line, decode)
+		if err != nil {
 			return err
 		}
 	}
 
-	// Call Decode() again, using a pointer to an empty anonymous struct as the
-	// destination. If the request body only contained a single JSON value this will
-	// return an io.EOF error. So if we get anything else, we know that there is
-	// additional data in the request body and we return our own custom error message.
-	err = decoder.Decode(&struct{}{})
-	if err != io.EOF {
-		return errors.New("body must only contain a single JSON value")
+	err := scanner.Err()
+	switch {
+	case errors.Is(err, bufio.ErrTooLong):
+		return &bodyTooLargeError{subject: fmt.Sprintf("line %d", line+1), maxBytes: maxLineBytes}
+	case err != nil:
+		return jsonDecodeError(err, "body", maxBytes)
+	case records == 0:
+		return errors.New("body must not be empty")
 	}
 
 	return nil
 }
 
+// Triage an error from decoding a JSON value into a message which can be sent to the
+// client. The subject names what was being decoded, such as "body" or "line 3", and
+// maxBytes is the size limit, which is included in the message when it is exceeded.
+func jsonDecodeError(err error, subject string, maxBytes int64) error {
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var invalidUnmarshalError *json.InvalidUnmarshalError
+
+	switch {
+	// This error occurs when there are syntax errors in the JSON
+	case errors.As(err, &syntaxError):
+		return fmt.Errorf("%s contains malformed JSON (at character %d)", subject, syntaxError.Offset)
+
+	// In some circumstances Decode() may also return an io.ErrUnexpectedEOF error for syntax errors in the JSON
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("%s contains malformed JSON", subject)
+
+	//This error occurs when JSON value is of the wrong type for the target destination. If the error relates
+	// to a specific field, then we include that in our error message to make it easier for the client to debug
+	case errors.As(err, &unmarshalTypeError):
+		if unmarshalTypeError.Field != "" {
+			return fmt.Errorf("%s contains incorrect JSON type for field %q", subject, unmarshalTypeError.Field)
+		}
+
+		return fmt.Errorf("%s contains incorrect JSON type (at character %d)", subject, unmarshalTypeError.Offset)
+
+	// An io.EOF error will be returned by Decode() if the request body is empty
+	case errors.Is(err, io.EOF):
+		return fmt.Errorf("%s must not be empty", subject)
+
+	// If the JSON contains a field which cannot be mapped to the target destination
+	// then Decode() will now return an error message in the format "json: unknown
+	// field "<name>"". We check for this, extract the field name from the error
+	// and interpolate it into our custom error message.
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+
+		field, unquoteErr := strconv.Unquote(fieldName)
+		if unquoteErr != nil {
+			field = fieldName
+		}
+
+		return &unknownFieldError{subject: subject, field: field}
+
+	// If the request body exceeds the size limit the decode will now fail with the
+	// error "http: request body too large"
+	case err.Error() == "http: request body too large":
+		return &bodyTooLargeError{subject: subject, maxBytes: maxBytes}
+
+	// A json.InvalidUnmarshalError error will be returned if we pass a non-nil
+	// pointer to Decode(). We catch this error and panic.
+	case errors.As(err, &invalidUnmarshalError):
+		panic(err)
+
+	// For anything else, return the error message as-is
+	default:
+		return err
+	}
+}
+
 // The readString() helper returns a string value from the query string, or the provided
 // default value if no matching key could be found
 func (app *application) readString(queryString url.Values, key string, defaultValue string) string {
@@ -168,6 +426,104 @@ func (app *application) readCSV(queryString url.Values, key string, defaultValue
 	return strings.Split(csv, ",")
 }
 
+// The readInclude() helper reads the comma-separated list of related resources which
+// the client wants included in the response. Any which aren't in the supported list
+// are recorded as errors in the provided Validator instance.
+func (app *application) readInclude(queryString url.Values, v *validator.Validator, supported ...string) map[string]bool {
+	include := make(map[string]bool)
+
+	for _, value := range app.readCSV(queryString, "include", []string{}) {
+		if !validator.In(value, supported...) {
+			v.AddError("include", "unsupported value "+strconv.Quote(value), validator.CodeNotAllowed, validator.Params{"value": value})
+			continue
+		}
+
+		include[value] = true
+	}
+
+	return include
+}
+
+// The readDate() helper reads a date in the "2006-01-02" format from the query string.
+// If no matching key could be found it returns the zero Date, which matches any date.
+// If the value couldn't be parsed, then we record an error message in the provided
+// Validator instance.
+func (app *application) readDate(queryString url.Values, key string, v *validator.Validator) data.Date {
+	value := queryString.Get(key)
+
+	if value == "" {
+		return data.Date{}
+	}
+
+	date, err := data.ParseDate(value)
+	if err != nil {
+		v.AddError(key, "must be a date in the format YYYY-MM-DD", validator.CodeInvalidFormat, validator.Params{"format": "YYYY-MM-DD"})
+		return data.Date{}
+	}
+
+	return date
+}
+
+// The readTime() helper reads an RFC 3339 timestamp (e.g. "2026-10-16T09:30:00Z") from
+// the query string, returning the zero time if the key doesn't exist. If the value
+// isn't a valid timestamp, then we record an error message in the provided Validator
+// instance.
+func (app *application) readTime(queryString url.Values, key string, v *validator.Validator) time.Time {
+	value := queryString.Get(key)
+
+	if value == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		v.AddError(key, "must be an RFC 3339 timestamp, such as 2026-10-16T09:30:00Z", validator.CodeInvalidFormat, validator.Params{"format": "RFC 3339"})
+		return time.Time{}
+	}
+
+	return t
+}
+
+// The readCertifications() helper reads a comma-separated list of age certifications in
+// the format "<country>:<certification>" (e.g. "US:PG-13,GB:12A") from the query
+// string. If a value isn't in that format, or a country is listed twice, then we record
+// an error message in the provided Validator instance.
+func (app *application) readCertifications(queryString url.Values, key string, v *validator.Validator) data.Certifications {
+	certifications := make(data.Certifications)
+
+	for _, value := range app.readCSV(queryString, key, []string{}) {
+		country, certification, found := strings.Cut(value, ":")
+		if !found {
+			v.AddError(key, "must be in the format <country>:<certification>", validator.CodeInvalidFormat, validator.Params{"format": "<country>:<certification>"})
+			return nil
+		}
+
+		if _, exists := certifications[country]; exists {
+			v.AddError(key, "must not contain duplicate countries", validator.CodeDuplicate)
+			return nil
+		}
+
+		certifications[country] = certification
+	}
+
+	return certifications
+}
+
+// The readMetadataFilters() helper reads the metadata values to filter on from the query
+// string, each in a separate parameter named after the key (e.g. ?metadata.studio=A24).
+// Only the first value of a parameter is used, like the other filters.
+func (app *application) readMetadataFilters(queryString url.Values, prefix string) data.MovieMetadata {
+	metadata := make(data.MovieMetadata)
+
+	for name := range queryString {
+		if key := strings.TrimPrefix(name, prefix+"."); key != name {
+			metadata[key] = queryString.Get(name)
+		}
+	}
+
+	return metadata
+}
+
 // The readInt() helper reads a string value from the query string and converts it to an
 // integer before returning. If no matching key could be found it returns the provided
 // default value. If the value couldn't be converted to an integer, then we record an
@@ -185,31 +541,34 @@ func (app *application) readInt(queryString url.Values, key string, defaultValue
 	// validator instance and return the default value.
 	value, err := strconv.Atoi(str)
 	if err != nil {
-		v.AddError(key, "must be an integer value")
+		v.AddError(key, "must be an integer value", validator.CodeInvalidFormat, validator.Params{"format": "integer"})
 		return defaultValue
 	}
 
 	return value
 }
 
-// The background() helper accepts an arbitrary function as a parameter
-func (app *application) background(fn func()) {
-	// Increment the WaitGroup counter
-	app.wg.Add(1)
-
-	// Launch a background goroutine.
-	go func() {
-		// Use defer to decrement the WaitGroup counter before the goroutine returns
-		defer app.wg.Done()
+// The humanDuration() helper formats a duration for people to read, such as "3 days",
+// "24 hours" or "45 minutes", for use in emails and validation messages
+func humanDuration(d time.Duration) string {
+	unit := func(n int64, name string) string {
+		if n == 1 {
+			return "1 " + name
+		}
 
-		// Recover any panic.
-		defer func() {
-			if err := recover(); err != nil {
-				app.logger.PrintError(fmt.Errorf("%s", err), nil)
-			}
-		}()
+		return fmt.Sprintf("%d %ss", n, name)
+	}
 
-		// Execute the arbitrary function that we passed as the parameter.
-		fn()
-	}()
+	day := 24 * time.Hour
+
+	switch {
+	case d >= 2*day && d%day == 0:
+		return unit(int64(d/day), "day")
+	case d >= time.Hour && d%time.Hour == 0:
+		return unit(int64(d/time.Hour), "hour")
+	case d >= time.Minute:
+		return unit(int64(d.Round(time.Minute)/time.Minute), "minute")
+	default:
+		return unit(int64(d.Round(time.Second)/time.Second), "second")
+	}
 }
@@ -0,0 +1,47 @@
+//go:build http3
+
+// HTTP/3 support is experimental, so it is only compiled in when building with
+// -tags=http3. The quic-go module it depends on is pinned in go.mod all the same, and
+// `make audit` vets the tagged build.
+
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// The serveHTTP3() method starts an HTTP/3 listener on UDP which shares the handler
+// chain of the main server. It returns a function which closes the listener.
+func (app *application) serveHTTP3(handler http.Handler) (func() error, error) {
+	certificate, err := tls.LoadX509KeyPair(app.config.tls.certFile, app.config.tls.keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http3.Server{
+		Addr:    app.config.http3.addr,
+		Handler: handler,
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS13,
+			Certificates: []tls.Certificate{certificate},
+			NextProtos:   []string{"h3"},
+		},
+	}
+
+	go func() {
+		app.logger.PrintInfo("Starting HTTP/3 server", map[string]string{
+			"addr": server.Addr,
+		})
+
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			app.logger.PrintError(err, nil)
+		}
+	}()
+
+	return server.Close, nil
+}
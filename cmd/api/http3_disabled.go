@@ -0,0 +1,14 @@
+//go:build !http3
+
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// The serveHTTP3() method is a placeholder for builds without the http3 tag, which
+// don't include the QUIC implementation
+func (app *application) serveHTTP3(handler http.Handler) (func() error, error) {
+	return nil, errors.New("HTTP/3 support is not compiled in, rebuild with -tags=http3")
+}
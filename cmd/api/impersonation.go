@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/reqctx"
+)
+
+// Handler for the "POST /v1/admin/impersonate/:id" endpoint, which gives support staff
+// a short-lived authentication token acting as a user, so that they can reproduce the
+// issues the user reports. Requests made with it are logged along with the admin, and
+// it can't be used to manage the user's credentials. Admins can only impersonate users
+// whose permissions they hold themselves, so that impersonation doesn't give them any
+// more access than they already have.
+func (app *application) createImpersonationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeUserNotFound)
+		return
+	}
+
+	admin := app.contextGetUser(r)
+
+	if id == admin.ID {
+		app.cannotImpersonateResponse(w, r)
+		return
+	}
+
+	user, err := app.models.User.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeUserNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	allowed, err := app.userHasPermissions(r, permissions...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.cannotImpersonateResponse(w, r)
+		return
+	}
+
+	token, err := app.models.Token.NewImpersonation(r.Context(), user.ID, admin.ID, app.config.tokens.impersonationTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	properties := reqctx.FromContext(r.Context()).LogProperties()
+	properties["impersonated_user_id"] = fmt.Sprint(user.ID)
+	properties["expiry"] = token.Expiry.Format(time.RFC3339)
+
+	app.logger.PrintInfo("impersonation token issued", properties)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"impersonation_token": token, "user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
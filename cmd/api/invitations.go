@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Handler for the "POST /v1/invitations" endpoint, which emails an invitation code
+// that lets the recipient register, even when registration is closed
+func (app *application) createInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	invitation, err := data.NewInvitation(input.Email, app.contextGetUser(r).ID, app.config.registration.invitationTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateInvitation(v, invitation); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Invitations.Insert(r.Context(), invitation)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Queue the invitation email, which is sent by a job worker
+	err = app.sendEmail(r.Context(), invitation.Email, "invitation.tmpl", map[string]interface{}{
+		"invitationCode": invitation.PlainText,
+		"expiry":         invitation.Expiry.Format("2 January 2006"),
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"invitation": invitation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/invitations" endpoint
+func (app *application) listInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	queryString := r.URL.Query()
+
+	input.Status = app.readString(queryString, "status", "")
+	input.Filters = app.readListingFilters(queryString, invitationListing, v)
+
+	if input.Status != "" {
+		v.Check(validator.In(input.Status, data.InvitationPending, data.InvitationAccepted, data.InvitationExpired), "status", "must be pending, accepted or expired", validator.CodeNotOneOf, validator.Params{"allowed": []string{data.InvitationPending, data.InvitationAccepted, data.InvitationExpired}})
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	invitations, metadata, err := app.models.Invitations.GetAll(r.Context(), input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePage(w, r, "invitations", invitations, metadata)
+}
+
+// Handler for the "DELETE /v1/invitations/:id" endpoint, which revokes an invitation.
+// Accepted invitations can't be revoked and are reported as not found.
+func (app *application) deleteInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeInvitationNotFound)
+		return
+	}
+
+	err = app.models.Invitations.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeInvitationNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "invitation successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
+	"github.com/LuisBarroso37/Greenlight/internal/mailer"
+)
+
+// Define an emailJob struct holding the payload of a job which sends a templated email,
+// along with any files attached to it from the mailer's storage and any extra headers.
+// The message ID is chosen when the email is queued, so that retries of the job send
+// the email with the same Message-ID.
+type emailJob struct {
+	MessageID    string                 `json:"message_id,omitempty"`
+	Recipient    string                 `json:"recipient"`
+	TemplateFile string                 `json:"template_file"`
+	Data         map[string]interface{} `json:"data"`
+	Attachments  []mailer.Attachment    `json:"attachments,omitempty"`
+	Headers      map[string]string      `json:"headers,omitempty"`
+}
+
+func (emailJob) Kind() string {
+	return "email"
+}
+
+// Register the handlers for every kind of job run by the application
+func (app *application) registerJobs() {
+	jobs.Handle(app.jobs, app.runEmailJob)
+
+	jobs.Handle(app.jobs, app.deliverNotification)
+	jobs.Handle(app.jobs, app.sendSavedSearchAlerts)
+	jobs.Handle(app.jobs, app.sendDigests)
+	jobs.Handle(app.jobs, app.refreshAvailability)
+	jobs.Handle(app.jobs, app.indexMovie)
+	jobs.Handle(app.jobs, app.reindexMovies)
+	jobs.Handle(app.jobs, app.purgeCDN)
+	jobs.Handle(app.jobs, app.publishScheduledMovies)
+	jobs.Handle(app.jobs, app.pruneData)
+	jobs.Handle(app.jobs, app.runBackupJob)
+	jobs.Handle(app.jobs, app.runSchemaOperationJob)
+}
+
+// Send a queued email, logging its message ID so that replies, bounces and spam
+// reports which quote it can be traced back to the email
+func (app *application) runEmailJob(ctx context.Context, job emailJob) error {
+	msg := &mailer.Message{
+		ID:           job.MessageID,
+		Recipient:    job.Recipient,
+		TemplateFile: job.TemplateFile,
+		Data:         job.Data,
+		Attachments:  job.Attachments,
+		Headers:      job.Headers,
+	}
+
+	err := app.mailer.SendMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	app.logger.PrintInfo("email sent", map[string]string{
+		"message_id": msg.ID,
+		"template":   msg.TemplateFile,
+	})
+
+	return nil
+}
+
+// The sendEmail() helper queues a templated email to be sent by a job worker, so that
+// it is retried if the SMTP server is unavailable and isn't lost if the application
+// restarts
+func (app *application) sendEmail(ctx context.Context, recipient, templateFile string, data map[string]interface{}) error {
+	return app.sendMessage(ctx, time.Time{}, mailer.NewMessage(recipient, templateFile, data))
+}
+
+// The sendMessage() helper queues an email built with mailer.NewMessage(), which may
+// have attachments and extra headers, to be sent once the given time has come (a zero
+// time sends it straight away). The attachments are only read from the mailer's storage
+// when the email is sent. The message's data must be a map, as it is stored in the job
+// as JSON. A message without an ID is given one, which is set on the message.
+func (app *application) sendMessage(ctx context.Context, sendAt time.Time, msg *mailer.Message) error {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok && msg.Data != nil {
+		return fmt.Errorf("the data of email %s must be a map, not %T", msg.TemplateFile, msg.Data)
+	}
+
+	if msg.ID == "" {
+		id, err := mailer.NewMessageID(app.config.smtp.sender)
+		if err != nil {
+			return err
+		}
+
+		msg.ID = id
+	}
+
+	job := emailJob{
+		MessageID:    msg.ID,
+		Recipient:    msg.Recipient,
+		TemplateFile: msg.TemplateFile,
+		Data:         data,
+		Attachments:  msg.Attachments,
+		Headers:      msg.Headers,
+	}
+
+	return app.jobs.Enqueue(ctx, job, jobs.EnqueueOptions{RunAt: sendAt})
+}
+
+// The subscriptionMessage() helper returns a new email which the user subscribed to,
+// such as a digest, with the List-Unsubscribe header set so that mail clients can offer
+// to unsubscribe rather than the user marking the email as spam
+func (app *application) subscriptionMessage(recipient, templateFile string, data map[string]interface{}) *mailer.Message {
+	msg := mailer.NewMessage(recipient, templateFile, data)
+
+	if app.config.smtp.unsubscribe != "" {
+		msg.Header("List-Unsubscribe", "<"+app.config.smtp.unsubscribe+">")
+	}
+
+	return msg
+}
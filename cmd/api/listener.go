@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The first file descriptor passed by systemd socket activation. File descriptors 0-2
+// are stdin, stdout and stderr.
+const listenFDsStart = 3
+
+// The listen() method creates the listener for the main HTTP server. If the process was
+// started by systemd socket activation the inherited socket is used, otherwise the
+// -listen flag decides between a Unix domain socket ("unix:/run/greenlight.sock") and
+// a TCP address, falling back to the -port flag if it is empty.
+func (app *application) listen() (net.Listener, error) {
+	listener, err := systemdListener()
+	if err != nil || listener != nil {
+		return listener, err
+	}
+
+	switch {
+	case strings.HasPrefix(app.config.listen, "unix:"):
+		return unixListener(strings.TrimPrefix(app.config.listen, "unix:"))
+	case app.config.listen != "":
+		return net.Listen("tcp", app.config.listen)
+	default:
+		return net.Listen("tcp", fmt.Sprintf(":%d", app.config.port))
+	}
+}
+
+// Return the socket passed in by systemd, or nil if the process wasn't socket
+// activated. The environment variables are unset afterwards so that they aren't
+// inherited by any child processes.
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	// We only serve a single socket, so use the first one we were passed
+	file := os.NewFile(uintptr(listenFDsStart), "LISTEN_FD_3")
+	defer file.Close()
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use systemd socket: %w", err)
+	}
+
+	return listener, nil
+}
+
+// Create a listener on a Unix domain socket at the given path. A socket file left
+// behind by a previous process that didn't shut down cleanly is removed first. The
+// socket file is removed again when the listener is closed on shutdown.
+func unixListener(path string) (net.Listener, error) {
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.Mode()&fs.ModeSocket != 0:
+		err = os.Remove(path)
+		if err != nil {
+			return nil, err
+		}
+	case err == nil:
+		return nil, fmt.Errorf("unable to listen on %s: file exists and is not a socket", path)
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Allow the reverse proxy, which usually runs as a different user in the same
+	// group, to connect to the socket
+	err = os.Chmod(path, 0660)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	listener.(*net.UnixListener).SetUnlinkOnClose(true)
+
+	return listener, nil
+}
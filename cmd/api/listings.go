@@ -0,0 +1,206 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Define a listingFilter struct describing a query string parameter which filters a list
+type listingFilter struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"` // One of string, csv, date, timestamp or boolean
+	Description string   `json:"description"`
+	Enum        []string `json:"enum,omitempty"` // The only values accepted, if they are restricted
+}
+
+// Define a listing struct describing how a list endpoint can be sorted, filtered and
+// paginated. Handlers read their filters through it, so the descriptions sent by the
+// introspection endpoints can't drift from what the handlers accept.
+type listing struct {
+	SortFields  []string        `json:"sort_fields"` // Each can be prefixed with "-" for a descending sort
+	DefaultSort string          `json:"default_sort"`
+	Filters     []listingFilter `json:"filters"`
+	Include     []string        `json:"include,omitempty"`
+	Cursor      bool            `json:"cursor"` // Whether the list can be paginated with the cursor parameter when sorted by id
+}
+
+// Define the listings of the list endpoints
+var (
+	movieListing = listing{
+		SortFields:  []string{"id", "title", "year", "runtime"},
+		DefaultSort: "id",
+		Filters: []listingFilter{
+			{Name: "title", Type: "string", Description: "Full-text search on the title"},
+			{Name: "synopsis", Type: "string", Description: "Full-text search on the synopsis"},
+			{Name: "genres", Type: "csv", Description: "Movies must have all of the genres"},
+			{Name: "language", Type: "string", Description: "ISO 639-1 code of the original language"},
+			{Name: "countries", Type: "csv", Description: "ISO 3166-1 alpha-2 codes of the production countries, movies must have all of them"},
+			{Name: "certification", Type: "csv", Description: "Age certifications in the format <country>:<certification>", Enum: certificationValues()},
+			{Name: "released_before", Type: "date", Description: "Movies must have a release before this date"},
+			{Name: "released_after", Type: "date", Description: "Movies must have a release after this date"},
+			{Name: "created_before", Type: "timestamp", Description: "Movies must have been added before this RFC 3339 timestamp"},
+			{Name: "created_after", Type: "timestamp", Description: "Movies must have been added after this RFC 3339 timestamp"},
+			{Name: "created_by", Type: "string", Description: "Only \"me\" is supported, listing the movies the user created"},
+			{Name: "metadata.<key>", Type: "string", Description: "Movies must have this value for the metadata key, one parameter per key"},
+		},
+		Include: []string{"collection", "facets"},
+	}
+
+	collectionListing = listing{
+		SortFields:  []string{"id", "name"},
+		DefaultSort: "id",
+		Filters: []listingFilter{
+			{Name: "name", Type: "string", Description: "Full-text search on the name"},
+		},
+	}
+
+	movieReviewListing = listing{
+		SortFields:  []string{"id", "rating"},
+		DefaultSort: "-id",
+		Cursor:      true,
+	}
+
+	moderationReviewListing = listing{
+		SortFields:  []string{"id"},
+		DefaultSort: "id",
+		Filters: []listingFilter{
+			{Name: "status", Type: "string", Description: "Status of the reviews, pending by default", Enum: []string{data.ReviewPending, data.ReviewApproved, data.ReviewRejected}},
+		},
+		Cursor: true,
+	}
+
+	movieChangeListing = listing{
+		SortFields:  []string{"id"},
+		DefaultSort: "id",
+		Filters: []listingFilter{
+			{Name: "status", Type: "string", Description: "Status of the changes, pending by default", Enum: []string{data.MovieChangePending, data.MovieChangeApproved, data.MovieChangeRejected}},
+		},
+	}
+
+	reportListing = listing{
+		SortFields:  []string{"id"},
+		DefaultSort: "id",
+		Filters: []listingFilter{
+			{Name: "status", Type: "string", Description: "Status of the reports, open by default", Enum: []string{data.ReportOpen, data.ReportUpheld, data.ReportDismissed}},
+			{Name: "reason", Type: "string", Description: "Reason the reviews were reported for", Enum: data.ReportReasons},
+		},
+	}
+
+	metricSnapshotListing = listing{
+		SortFields:  []string{"id"},
+		DefaultSort: "-id",
+	}
+
+	backupListing = listing{
+		SortFields:  []string{"id"},
+		DefaultSort: "-id",
+	}
+
+	operationListing = listing{
+		SortFields:  []string{"id"},
+		DefaultSort: "-id",
+	}
+
+	invitationListing = listing{
+		SortFields:  []string{"id"},
+		DefaultSort: "-id",
+		Filters: []listingFilter{
+			{Name: "status", Type: "string", Description: "Status of the invitations", Enum: []string{data.InvitationPending, data.InvitationAccepted, data.InvitationExpired}},
+		},
+	}
+
+	// Notifications are always listed newest first
+	notificationListing = listing{
+		SortFields:  []string{},
+		DefaultSort: "-id",
+		Filters: []listingFilter{
+			{Name: "unread", Type: "boolean", Description: "Only list unread notifications", Enum: []string{"true", "false"}},
+		},
+		Cursor: true,
+	}
+)
+
+// Return every certification accepted by the certification filter, in the format
+// "<country>:<certification>"
+func certificationValues() []string {
+	countries := make([]string, 0, len(data.CertificationSafelist))
+	for country := range data.CertificationSafelist {
+		countries = append(countries, country)
+	}
+
+	sort.Strings(countries)
+
+	var values []string
+
+	for _, country := range countries {
+		for _, certification := range data.CertificationSafelist[country] {
+			values = append(values, country+":"+certification)
+		}
+	}
+
+	return values
+}
+
+// Return the supported sort values, which are the sort fields in both directions. The
+// default sort is always supported, even when the list can't otherwise be sorted.
+func (l listing) sortSafelist() []string {
+	safelist := append(make([]string, 0, len(l.SortFields)*2+1), l.SortFields...)
+
+	for _, field := range l.SortFields {
+		safelist = append(safelist, "-"+field)
+	}
+
+	if !validator.In(l.DefaultSort, safelist...) {
+		safelist = append(safelist, l.DefaultSort)
+	}
+
+	return safelist
+}
+
+// Read the page, page_size, sort and (if the listing supports it) cursor query string
+// parameters for the listing. They still need to be checked with data.ValidateFilters().
+func (app *application) readListingFilters(queryString url.Values, l listing, v *validator.Validator) data.Filters {
+	filters := data.Filters{
+		Page:         app.readInt(queryString, "page", 1, v),
+		PageSize:     app.readInt(queryString, "page_size", data.DefaultPageSize, v),
+		Sort:         l.DefaultSort,
+		SortSafelist: l.sortSafelist(),
+	}
+
+	if len(l.SortFields) > 0 {
+		filters.Sort = app.readString(queryString, "sort", l.DefaultSort)
+	}
+
+	if cursor := app.readString(queryString, "cursor", ""); l.Cursor && cursor != "" {
+		after, err := data.DecodeCursor(cursor)
+		if err != nil {
+			v.AddError("cursor", "must be the next_cursor of a previous page", validator.CodeInvalid)
+		}
+
+		filters.After = after
+	}
+
+	return filters
+}
+
+// Handler for the "GET /v1/movies/_meta" endpoint, which describes how the movie list
+// can be sorted, filtered and paginated
+func (app *application) movieListingHandler(w http.ResponseWriter, r *http.Request) {
+	env := envelope{
+		"listing": movieListing,
+		"page_size": map[string]int{
+			"default": data.DefaultPageSize,
+			"max":     data.MaxPageSize,
+		},
+		"max_page": data.MaxPage,
+	}
+
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
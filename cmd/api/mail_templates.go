@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/mailer"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// The formats an email template can be previewed in
+var mailPreviewFormats = []string{"html", "text"}
+
+// Return the sample data for previewing each email template, with the same keys and
+// types as the data the application sends it
+func (app *application) mailTemplateSamples() map[string]map[string]interface{} {
+	movies := []*data.Movie{
+		{ID: 2, Title: "The Breakfast Club", Year: 1985, Genres: []string{"drama", "comedy"}},
+		{ID: 1, Title: "Moana", Year: 2016, Genres: []string{"animation", "adventure"}},
+	}
+
+	return map[string]map[string]interface{}{
+		"alert.tmpl": {
+			"env":      app.config.env,
+			"title":    "High error rate",
+			"message":  "7.5% of the responses over the last 5m0s were server errors, over the 5.0% threshold.",
+			"instance": "greenlight-1",
+			"time":     time.Now().Format(time.RFC1123),
+			"cooldown": app.config.alerts.cooldown.String(),
+		},
+		"digest.tmpl": {
+			"name":      "Alice Smith",
+			"frequency": data.DigestWeekly,
+			"genres":    []string{"drama", "animation"},
+			"movies": []map[string]interface{}{
+				{"title": movies[0].Title, "year": movies[0].Year, "genres": movies[0].Genres},
+				{"title": movies[1].Title, "year": movies[1].Year, "genres": movies[1].Genres},
+			},
+			"more": true,
+		},
+		"invitation.tmpl": {
+			"invitationCode": "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU",
+			"expiry":         time.Now().Add(7 * 24 * time.Hour).Format("2 January 2006"),
+		},
+		"mail_test.tmpl": {
+			"env":      app.config.env,
+			"server":   "smtp.mailtrap.io:2525",
+			"sender":   app.config.smtp.sender,
+			"instance": "greenlight-1",
+			"version":  version,
+			"time":     time.Now().Format(time.RFC1123),
+		},
+		"notification.tmpl": {
+			"title": "New sign-in to your account",
+			"body":  "Your account was signed in to from a new device.",
+		},
+		"saved_search_alert.tmpl": {
+			"name":   "80s comedies",
+			"movies": movies,
+			"more":   false,
+		},
+		"token_activation.tmpl": {
+			"activationToken": "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU",
+			"expiresIn":       humanDuration(app.config.tokens.activationTTL),
+		},
+		"token_password_reset.tmpl": {
+			"passwordResetToken": "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU",
+		},
+		"user_welcome.tmpl": {
+			"activationToken": "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU",
+			"userID":          int64(123),
+			"expiresIn":       humanDuration(app.config.tokens.activationTTL),
+		},
+	}
+}
+
+// Handler for the "GET /v1/admin/mail-templates/:name/preview" endpoint, which renders
+// an email template with sample data without sending it, so that template changes can
+// be checked before they are deployed. The format query string parameter chooses
+// between the HTML body (the default) and the plain text body, and the subject is sent
+// in the X-Mail-Subject header. The name may be given with or without the .tmpl
+// extension.
+func (app *application) previewMailTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+	templateFile := strings.TrimSuffix(name, ".tmpl") + ".tmpl"
+
+	v := validator.New()
+
+	format := app.readString(r.URL.Query(), "format", "html")
+
+	v.Check(validator.In(format, mailPreviewFormats...), "format", "must be html or text", validator.CodeNotOneOf, validator.Params{"allowed": mailPreviewFormats})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	templates, err := mailer.Templates()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !validator.In(templateFile, templates...) {
+		app.resourceNotFoundResponse(w, r, codeMailTemplateNotFound)
+		return
+	}
+
+	// The email job stores its data as JSON, so the sample goes through JSON too, and the
+	// template sees it exactly as it would when the email is sent
+	sample, err := json.Marshal(app.mailTemplateSamples()[templateFile])
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var sampleData map[string]interface{}
+
+	err = json.Unmarshal(sample, &sampleData)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	email, err := mailer.Render(templateFile, sampleData)
+	if err != nil {
+		app.serverErrorResponse(w, r, fmt.Errorf("render %s: %w", templateFile, err))
+		return
+	}
+
+	// The preview is served from the API's origin, so it is sandboxed to stop anything
+	// in a template from running scripts there
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Mail-Subject", strings.Join(strings.Fields(email.Subject), " "))
+
+	body := email.HTMLBody
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if format == "text" {
+		body = email.PlainBody
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(body))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/logger"
+	"github.com/LuisBarroso37/Greenlight/internal/mailer"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Run the mail-test command, which checks the SMTP settings by connecting to the server
+// and then sending a diagnostic email straight away, without going through the job
+// queue. The address it is sent to is given with the -to flag after the command, as in
+// `api -smtp-host=... mail-test -to=ops@example.com`.
+func runMailTest(logger *logger.Logger, cfg config, m mailer.Mailer, args []string) error {
+	flags := flag.NewFlagSet("mail-test", flag.ContinueOnError)
+	to := flags.String("to", "", "Address the test email is sent to")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+
+	if data.ValidateEmail(v, *to); !v.Valid() {
+		return fmt.Errorf("invalid -to: %s", v.Errors["email"])
+	}
+
+	properties := map[string]string{
+		"server":   m.Address(),
+		"username": cfg.smtp.username,
+	}
+
+	// Connect first, so that a connection problem and rejected credentials are reported
+	// as such rather than as a failed send
+	err = m.Verify()
+	if err != nil {
+		if errors.Is(err, mailer.ErrAuthFailed) {
+			return fmt.Errorf("%w (check -smtp-username and -smtp-password)", err)
+		}
+
+		return fmt.Errorf("%w (check -smtp-host and -smtp-port)", err)
+	}
+
+	logger.PrintInfo("smtp server accepted the credentials", properties)
+
+	instance, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	id, err := mailer.NewMessageID(cfg.smtp.sender)
+	if err != nil {
+		return err
+	}
+
+	msg := mailer.NewMessage(*to, "mail_test.tmpl", map[string]interface{}{
+		"env":      cfg.env,
+		"server":   m.Address(),
+		"sender":   cfg.smtp.sender,
+		"instance": instance,
+		"version":  version,
+		"time":     time.Now().Format(time.RFC1123),
+	})
+	msg.ID = id
+
+	err = m.SendMessage(msg)
+	if err != nil {
+		return fmt.Errorf("smtp server refused the test email: %w", err)
+	}
+
+	properties["recipient"] = *to
+	properties["message_id"] = id
+
+	logger.PrintInfo("test email sent", properties)
+
+	return nil
+}
@@ -3,23 +3,42 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"expvar"
 	"flag"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/LuisBarroso37/Greenlight/internal/backup"
+	"github.com/LuisBarroso37/Greenlight/internal/cache"
+	"github.com/LuisBarroso37/Greenlight/internal/cdn"
+	"github.com/LuisBarroso37/Greenlight/internal/circuit"
 	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/errtrack"
+	"github.com/LuisBarroso37/Greenlight/internal/events"
+	"github.com/LuisBarroso37/Greenlight/internal/hits"
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
 	"github.com/LuisBarroso37/Greenlight/internal/logger"
 	"github.com/LuisBarroso37/Greenlight/internal/mailer"
+	"github.com/LuisBarroso37/Greenlight/internal/metrics"
+	"github.com/LuisBarroso37/Greenlight/internal/moderation"
+	"github.com/LuisBarroso37/Greenlight/internal/providers"
+	"github.com/LuisBarroso37/Greenlight/internal/schema"
+	"github.com/LuisBarroso37/Greenlight/internal/search"
+	"github.com/LuisBarroso37/Greenlight/internal/secrets"
+	"github.com/LuisBarroso37/Greenlight/internal/tracing"
 
 	// Import the pq driver so that it can register itself with the database/sql
 	// package. Note that we alias this import to the blank identifier, to stop the Go
 	// compiler complaining that the package isn't being used.
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // Application version
@@ -31,38 +50,290 @@ var buildTime string
 
 // Config struct that holds all the configuration settings for our application
 type config struct {
-	port int
-	env  string
-	db   struct {
+	port     int
+	listen   string
+	env      string
+	docsURL  string
+	timezone string
+	locales  []string
+	db       struct {
 		dsn          string
 		maxOpenConns int
 		maxIdleConns int
 		maxIdleTime  string
+		slowQuery    time.Duration
+		warmConns    int
+		monitor      time.Duration
+		waitWarning  time.Duration
+		replicaDSN   string
+		stickyWindow time.Duration
 	}
 	limiter struct {
 		rps     float64
 		burst   int
 		enabled bool
 	}
+	concurrency struct {
+		maxInFlight int
+		queueSize   int
+		queueWait   time.Duration
+	}
+	deadline struct {
+		min time.Duration
+		max time.Duration
+	}
+	validation struct {
+		shadow  []string
+		enforce []string
+	}
+	startup struct {
+		timeout    time.Duration
+		failFast   bool
+		verifySMTP bool
+	}
+	circuit struct {
+		threshold int
+		cooldown  time.Duration
+	}
+	abuse struct {
+		threshold   int
+		window      time.Duration
+		banDuration time.Duration
+	}
+	body struct {
+		maxBytes           int64
+		authMaxBytes       int64
+		bulkMaxBytes       int64
+		allowUnknownFields bool
+	}
 	smtp struct {
-		host     string
-		port     int
-		username string
-		password string
-		sender   string
+		host          string
+		port          int
+		username      string
+		password      string
+		sender        string
+		webhookSecret string
+		filesDir      string
+		replyTo       string
+		unsubscribe   string
+		dkimKey       string
+		dkimDomain    string
+		dkimSelector  string
+		perRecipient  int
+	}
+	log struct {
+		format string
 	}
 	cors struct {
 		trustedOrigins []string
 	}
+	cache struct {
+		movieTTL     time.Duration
+		authTTL      time.Duration
+		authMaxUsers int
+		permTTL      time.Duration
+		permMaxUsers int
+		listTTL      time.Duration
+		statsTTL     time.Duration
+	}
+	trending struct {
+		window time.Duration
+	}
+	autocomplete struct {
+		timeout   time.Duration
+		cacheTTL  time.Duration
+		cacheSize int
+	}
+	catalog struct {
+		public bool
+		url    string
+	}
+	tos struct {
+		version string
+		url     string
+	}
+	guest struct {
+		enabled    bool
+		secret     string
+		ttl        time.Duration
+		rps        float64
+		burst      int
+		tokenRPS   float64
+		tokenBurst int
+	}
+	ownership struct {
+		enabled bool
+	}
+	cdn struct {
+		provider string
+		zone     string
+		token    string
+		maxAge   time.Duration
+	}
+	views struct {
+		flushInterval time.Duration
+	}
+	sampler struct {
+		interval time.Duration
+		window   time.Duration
+	}
+	savedSearches struct {
+		alertInterval time.Duration
+	}
+	digests struct {
+		interval   time.Duration
+		batchSize  int
+		batchPause time.Duration
+	}
+	publishing struct {
+		interval time.Duration
+	}
+	alerts struct {
+		email         string
+		interval      time.Duration
+		cooldown      time.Duration
+		errorRate     float64
+		serverErrors  int
+		emailFailures int
+		databasePool  bool
+	}
+	notify struct {
+		slackWebhook   string
+		discordWebhook string
+		panicCooldown  time.Duration
+	}
+	backup struct {
+		dir    string
+		pgDump string
+		keep   int
+	}
+	schema struct {
+		lockTimeout time.Duration
+		batchPause  time.Duration
+		budget      time.Duration
+	}
+	retention struct {
+		interval          time.Duration
+		batchSize         int
+		expiredTokens     time.Duration
+		failedJobs        time.Duration
+		readNotifications time.Duration
+		movieChanges      time.Duration
+	}
+	availability struct {
+		apiKey          string
+		apiURL          string
+		rps             float64
+		ttl             time.Duration
+		refreshInterval time.Duration
+	}
+	tokens struct {
+		activationTTL     time.Duration
+		authenticationTTL time.Duration
+		impersonationTTL  time.Duration
+	}
+	registration struct {
+		closed          bool
+		invitationTTL   time.Duration
+		allowedDomains  []string
+		deniedDomains   []string
+		blockDisposable bool
+	}
+	reviews struct {
+		premoderation   bool
+		blocklist       string
+		reportThreshold int
+	}
+	redis struct {
+		addr           string
+		password       string
+		db             int
+		movieTTL       time.Duration
+		permissionsTTL time.Duration
+	}
+	tls struct {
+		certFile string
+		keyFile  string
+	}
+	http3 struct {
+		enabled bool
+		addr    string
+	}
+	admin struct {
+		addr string
+	}
+	pprof struct {
+		enabled bool
+	}
+	debug struct {
+		logBodies bool
+		bodyLimit int
+	}
+	jobs struct {
+		concurrency  int
+		pollInterval time.Duration
+	}
+	events struct {
+		broker       string
+		addr         string
+		topicPrefix  string
+		pollInterval time.Duration
+	}
+	search struct {
+		backend string
+		url     string
+		index   string
+		apiKey  string
+	}
+	errtrack struct {
+		dsn        string
+		sampleRate float64
+		scrubPII   bool
+	}
+	signing struct {
+		enabled bool
+		window  time.Duration
+	}
+	secrets struct {
+		rotationInterval time.Duration
+		vaultAddr        string
+		awsRegion        string
+	}
 }
 
 // Application struct that holds the dependencies for our HTTP handlers, helper functions and middleware
 type application struct {
-	config config
-	logger *logger.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config       config
+	logger       *logger.Logger
+	db           *sql.DB
+	models       data.Models
+	mailer       mailer.Sender
+	mailLimiter  *mailer.RecipientLimiter
+	bans         banStore
+	limiter      clientLimiter
+	guests       clientLimiter
+	guestTokens  clientLimiter
+	tosAccepted  *tosAcceptanceCache
+	tracker      *errtrack.Tracker
+	jobs         *jobs.Queue
+	events       *events.Outbox
+	indexer      *movieIndexer
+	purger       cdn.Purger
+	hits         *hits.Tracker
+	movieLists   *movieListCache
+	suggestions  *suggestionCache
+	adminStats   *adminStatsCache
+	sampler      *rateSampler
+	providers    *providers.Client
+	screener     moderation.Screener
+	emailDomains *data.EmailDomainPolicy
+	backups      backup.Storage
+	schema       schema.Runner
+	ops          *opsNotifier
+	writes       *writeTracker
+	routeTable   []*routeInfo
+	shutdown     chan struct{}
+	wg           sync.WaitGroup
 }
 
 func main() {
@@ -73,38 +344,28 @@ func main() {
 	// Declare an instance of the config struct
 	var cfg config
 
-	// Read the value of the `port` and `env` command-line flags into the config struct. We default to using
-	// the port number 4000 and the environment "development" if no corresponding flags are provided.
-	flag.IntVar(&cfg.port, "port", 4000, "API server port")
-	flag.StringVar(&cfg.env, "env", "develoment", "Environment (development|staging|production)")
-
-	// Read the DSN value from the `db-dsn` command-line flag into the config struct. We
-	// default to using our development DSN if no flag is provided.
-	flag.StringVar(&cfg.db.dsn, "db-dsn", "", "PostgreSQL DSN")
-	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")
-	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
-	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max connection idle time")
-
-	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
-	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
-	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
-
-	flag.StringVar(&cfg.smtp.host, "smtp-host", "smtp.mailtrap.io", "SMTP host")
-	flag.IntVar(&cfg.smtp.port, "smtp-port", 2525, "SMTP port")
-	flag.StringVar(&cfg.smtp.username, "smtp-username", "", "SMTP username")
-	flag.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
-	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Greenlight <no-reply@greenlight.alexedwards.net>", "SMTP sender")
-
-	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
-		cfg.cors.trustedOrigins = strings.Fields(val)
-
-		return nil
-	})
+	// Read the configuration from the command-line flags
+	defineFlags(flag.CommandLine, &cfg)
 
 	displayVersion := flag.Bool("version", false, "Display version and exit")
 
 	flag.Parse()
 
+	// The sitemap URLs are built by appending paths to the public URL
+	cfg.catalog.url = strings.TrimSuffix(cfg.catalog.url, "/")
+
+	// Serve HTTP/3 on the same address as the API unless told otherwise, which is the
+	// -listen address when one is given as host:port. HTTP/3 can't be served alongside a
+	// Unix domain socket, which serve() refuses.
+	if cfg.http3.addr == "" {
+		switch {
+		case cfg.listen != "" && !strings.HasPrefix(cfg.listen, "unix:"):
+			cfg.http3.addr = cfg.listen
+		default:
+			cfg.http3.addr = fmt.Sprintf(":%d", cfg.port)
+		}
+	}
+
 	// If the version flag value is true, then print out the version number and
 	// immediately exit
 	if *displayVersion {
@@ -113,40 +374,573 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Write the log entries in the chosen format from here on. Production deployments
+	// keep the JSON default, while pretty is easier to read in a terminal.
+	err := logger.SetFormat(cfg.log.format)
+	if err != nil {
+		logger.PrintFatal(fmt.Errorf("invalid -log-format: %w", err), nil)
+	}
+
+	// Use the configured time zone for the timestamps we create, such as the mock models'
+	// and the ones sent in responses
+	location, err := time.LoadLocation(cfg.timezone)
+	if err != nil {
+		logger.PrintFatal(fmt.Errorf("invalid -timezone: %w", err), nil)
+	}
+
+	time.Local = location
+
+	// The retention job deletes rows in batches until a batch comes back short, so a
+	// batch size of 0 would never finish
+	if cfg.retention.batchSize < 1 {
+		logger.PrintFatal(errors.New("invalid -retention-batch-size: must be at least 1"), nil)
+	}
+
+	// The digests job fetches the due subscriptions a batch at a time, so a batch size of
+	// 0 would never get through them
+	if cfg.digests.batchSize < 1 {
+		logger.PrintFatal(errors.New("invalid -digest-batch-size: must be at least 1"), nil)
+	}
+
+	// The profiling endpoints are only served on the admin listener, as the public
+	// port's timeouts would cut CPU profiles and traces short
+	if cfg.pprof.enabled && cfg.admin.addr == "" {
+		logger.PrintFatal(errors.New("invalid -pprof-enabled: requires -admin-addr to be set"), nil)
+	}
+
+	// Guest tokens can't be checked by the other instances, or after a restart, unless
+	// they are signed with a secret set in the configuration
+	if cfg.guest.enabled && cfg.guest.secret == "" {
+		logger.PrintFatal(errors.New("invalid -guest-secret: must be set when -guest-sessions is enabled"), nil)
+	}
+
+	// Include the build information in every log entry so that log lines can be traced
+	// back to the deployed binary
+	logger.SetBaseProperties(readBuildMetadata().logProperties())
+
+	// Pass the trace of the request or job being handled on to the services we call, in
+	// the traceparent header. The HTTP clients of the internal packages all use the
+	// default transport.
+	http.DefaultTransport = tracing.Transport{Base: http.DefaultTransport}
+
+	// Keep a copy of the config values as they were written, so that rotated secrets
+	// can be matched back to the references they came from
+	rawCfg := cfg
+
+	// Replace any secret references in the config with their actual values
+	resolver, err := resolveSecrets(&cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	// Create the circuit breakers for the database, its read replica and the SMTP
+	// server, and publish their states. The replica has a breaker of its own, so that a
+	// failing replica doesn't cut off the primary.
+	dbBreaker := circuit.New("database", cfg.circuit.threshold, cfg.circuit.cooldown)
+	replicaBreaker := circuit.New("database_replica", cfg.circuit.threshold, cfg.circuit.cooldown)
+	smtpBreaker := circuit.New("smtp", cfg.circuit.threshold, cfg.circuit.cooldown)
+
+	expvar.Publish("circuit_breakers", expvar.Func(func() interface{} {
+		stats := map[string]circuit.Stats{
+			dbBreaker.Name():   dbBreaker.Stats(),
+			smtpBreaker.Name(): smtpBreaker.Stats(),
+		}
+
+		if cfg.db.replicaDSN != "" {
+			stats[replicaBreaker.Name()] = replicaBreaker.Stats()
+		}
+
+		return stats
+	}))
+
+	// Initialize the mailer used to send emails through the SMTP server. This is done
+	// before the database is opened, so that the SMTP settings can be checked with the
+	// mail-test command on its own.
+	smtpMailer := mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)
+
+	if cfg.smtp.filesDir != "" {
+		smtpMailer = smtpMailer.WithFiles(os.DirFS(cfg.smtp.filesDir))
+	}
+
+	if cfg.smtp.replyTo != "" {
+		smtpMailer = smtpMailer.WithReplyTo(cfg.smtp.replyTo)
+	}
+
+	if cfg.smtp.dkimKey != "" {
+		smtpMailer, err = smtpMailer.WithDKIM([]byte(cfg.smtp.dkimKey), cfg.smtp.dkimDomain, cfg.smtp.dkimSelector)
+		if err != nil {
+			logger.PrintFatal(fmt.Errorf("invalid -smtp-dkim-key: %w", err), nil)
+		}
+	}
+
+	// With -smtp-verify, misconfigured SMTP settings stop the application from starting
+	// rather than being found out when users don't receive their activation emails
+	if cfg.startup.verifySMTP {
+		err = waitForDependency(logger, "smtp server", cfg, smtpMailer.Verify)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		logger.PrintInfo("smtp server verified", map[string]string{
+			"server":   smtpMailer.Address(),
+			"username": cfg.smtp.username,
+		})
+	}
+
+	// When started with the mail-test argument, such as `api -smtp-host=... mail-test
+	// -to=ops@example.com`, send a test email and exit rather than serving requests
+	if flag.Arg(0) == "mail-test" {
+		err := runMailTest(logger, cfg, smtpMailer, flag.Args()[1:])
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		return
+	}
+
 	// Create connection pool
 	// If this returns an error, we log it and exit the application immediately
-	db, err := openDB(cfg)
+	db, err := openDB(cfg, cfg.db.dsn, dbBreaker)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	err = waitForDependency(logger, "database", cfg, func() error {
+		return pingDB(db)
+	})
 	if err != nil {
 		logger.PrintFatal(err, nil)
 	}
 
+	// Open some connections up front, so that the first requests don't have to. A
+	// failure here isn't fatal, as connections are opened on demand anyway.
+	if cfg.db.warmConns > 0 {
+		err = warmPool(db, cfg.db.warmConns)
+		if err != nil {
+			logger.PrintError(err, map[string]string{"task": "warm_database_pool"})
+		}
+	}
+
 	// Defer a call to db.Close() so that the connection pool is closed before the
 	// main() function exits.
 	defer db.Close()
 
 	logger.PrintInfo("database connection pool established", nil)
 
+	// Open the connection pool for the read replica, if one is configured
+	var replica *sql.DB
+
+	if cfg.db.replicaDSN != "" {
+		replica, err = openDB(cfg, cfg.db.replicaDSN, replicaBreaker)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		err = waitForDependency(logger, "database replica", cfg, func() error {
+			return pingDB(replica)
+		})
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		defer replica.Close()
+
+		expvar.Publish("database_replica", expvar.Func(func() interface{} {
+			return replica.Stats()
+		}))
+
+		logger.PrintInfo("database replica connection pool established", nil)
+	}
+
 	// Publish the number of active goroutines
 	expvar.Publish("goroutines", expvar.Func(func() interface{} {
 		return runtime.NumGoroutine()
 	}))
 
-	// Publish the database connection pool statistics
+	// Publish the database connection pool statistics, and the figures from the pool
+	// monitor's last check
 	expvar.Publish("database", expvar.Func(func() interface{} {
 		return db.Stats()
 	}))
 
+	poolMonitor := newPoolMonitor(db, cfg.db.waitWarning)
+
+	expvar.Publish("database_pool_health", expvar.Func(func() interface{} {
+		return poolMonitor.Health()
+	}))
+
 	// Publish the current Unix timestamp
 	expvar.Publish("timestamp", expvar.Func(func() interface{} {
 		return time.Now().Unix()
 	}))
 
+	// Initialize the models, recording the duration of each query and logging any slow
+	// ones. Deferring Close() here means that the prepared statements are closed before
+	// the connection pool is.
+	queryDurations := metrics.NewHistogramVec("database_query_duration_seconds", metrics.DefaultBuckets, "query")
+
+	models := data.NewInstrumentedModels(db, replica, queryDurations, cfg.db.slowQuery, func(query data.SlowQuery) {
+		properties := query.Request.LogProperties()
+		properties["name"] = query.Name
+		properties["query"] = query.Query
+		properties["arg_types"] = strings.Join(query.ArgTypes, ", ")
+		properties["duration"] = query.Duration.String()
+
+		logger.PrintInfo("slow database query", properties)
+	})
+	defer models.Close()
+
+	// Keep client bans in memory, unless Redis is configured below
+	var bans banStore = newMemoryBanStore()
+
+	// Wrap the movie and permission models with the Redis cache if one is configured.
+	// Cache errors are logged but don't fail requests, as the models fall back to
+	// querying the database.
+	if cfg.redis.addr != "" {
+		redis := cache.New(cfg.redis.addr, cfg.redis.password, cfg.redis.db, 10)
+		defer redis.Close()
+
+		err = redis.Ping()
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		logger.PrintInfo("redis cache connection established", nil)
+
+		logCacheError := func(err error) {
+			logger.PrintError(err, map[string]string{"component": "cache"})
+		}
+
+		models.Movie = data.NewCachedMovieModel(models.Movie, redis, cfg.redis.movieTTL, logCacheError)
+		models.Permissions = data.NewCachedPermissionModel(models.Permissions, redis, cfg.redis.permissionsTTL, logCacheError)
+
+		bans = redisBanStore{redis: redis}
+	}
+
+	// Otherwise cache permissions in memory, as they are checked on every authorized
+	// request
+	if cfg.redis.addr == "" && cfg.cache.permTTL > 0 {
+		logCacheError := func(err error) {
+			logger.PrintError(err, map[string]string{"component": "cache"})
+		}
+
+		models.Permissions = data.NewCachedPermissionModel(models.Permissions, cache.NewMemory(cfg.cache.permMaxUsers), cfg.cache.permTTL, logCacheError)
+	}
+
+	// Cache the users for authentication tokens in memory, which saves a database query
+	// on every authenticated request
+	if cfg.cache.authTTL > 0 {
+		tokenUserCache := data.NewTokenUserCache(cfg.cache.authTTL, cfg.cache.authMaxUsers)
+
+		models.User = data.NewCachedUserModel(models.User, tokenUserCache)
+		models.Token = data.NewCachedTokenModel(models.Token, tokenUserCache)
+	}
+
+	// Coalesce concurrent reads of the same movie into a single query, so that traffic
+	// spikes on a popular movie don't translate into a spike of database queries
+	models.Movie = data.NewCoalescingMovieModel(models.Movie, cfg.cache.movieTTL)
+
+	// Run movie searches against a search engine unless PostgreSQL is the search backend.
+	// Searches fall back to PostgreSQL if it can't be reached, so its errors are only
+	// logged.
+	var index search.Index
+	var indexer *movieIndexer
+
+	switch cfg.search.backend {
+	case "postgres":
+	case "elasticsearch":
+		index, err = search.NewElasticsearch(cfg.search.url, cfg.search.index)
+	case "meilisearch":
+		index, err = search.NewMeilisearch(cfg.search.url, cfg.search.index, cfg.search.apiKey)
+	default:
+		err = fmt.Errorf("unknown search backend %q", cfg.search.backend)
+	}
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	if index != nil {
+		indexer = &movieIndexer{index: index, movies: models.Movie}
+
+		models.Movie = data.NewSearchMovieModel(models.Movie, index, search.MaxResultWindow, func(err error) {
+			logger.PrintError(err, map[string]string{"component": "search"})
+		})
+	}
+
+	// Report server errors to Sentry if it is configured. Errors which occur while
+	// reporting are only logged, to avoid a feedback loop.
+	var reporter errtrack.Reporter
+
+	if cfg.errtrack.dsn != "" {
+		reporter, err = errtrack.NewSentryReporter(cfg.errtrack.dsn)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	tracker := errtrack.New(reporter, errtrack.Options{
+		SampleRate: cfg.errtrack.sampleRate,
+		ScrubPII:   cfg.errtrack.scrubPII,
+		Tags: map[string]string{
+			"environment": cfg.env,
+			"release":     version,
+		},
+		OnError: func(err error) {
+			logger.PrintError(err, map[string]string{"component": "errtrack"})
+		},
+	})
+	defer tracker.Close(5 * time.Second)
+
+	// Every email counts towards the limit for its recipient, so that no endpoint or job
+	// can be used to flood someone's inbox. The emails over the limit are dropped.
+	mailLimiter := mailer.NewRecipientLimiter(cfg.smtp.perRecipient, time.Hour)
+
+	limitedMailer := mailer.WithRecipientLimit(mailer.WithBreaker(smtpMailer, smtpBreaker), mailLimiter,
+		func(recipient, templateFile string, err *mailer.RecipientLimitError) {
+			logger.PrintInfo("email over the recipient limit skipped", map[string]string{
+				"template":    templateFile,
+				"retry_after": err.RetryAfter.Round(time.Second).String(),
+			})
+		})
+
+	// Emails aren't sent to the users whose addresses have bounced for good or who have
+	// marked our emails as spam, as reported by the email provider's webhooks
+	sender := mailer.WithSuppression(limitedMailer,
+		func(recipient string) (bool, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			return models.User.EmailSuppressed(ctx, recipient)
+		},
+		func(recipient, templateFile string) {
+			logger.PrintInfo("email to suppressed address skipped", map[string]string{"template": templateFile})
+		})
+
+	// Count movie views for the trending list
+	movieHits := hits.New(cfg.trending.window, 1024)
+	defer movieHits.Close()
+
+	expvar.Publish("movie_hits_dropped", expvar.Func(func() interface{} {
+		return movieHits.Dropped()
+	}))
+
 	// Declare an instance of the application struct
 	app := application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:  cfg,
+		logger:  logger,
+		db:      db,
+		models:  models,
+		mailer:  sender,
+		bans:    bans,
+		limiter: newMemoryLimiter(cfg.limiter.rps, cfg.limiter.burst),
+		tracker: tracker,
+		jobs: jobs.New(db, jobs.Options{
+			Concurrency:  cfg.jobs.concurrency,
+			PollInterval: cfg.jobs.pollInterval,
+			OnError:      logger.PrintError,
+		}),
+		hits:        movieHits,
+		movieLists:  newMovieListCache(),
+		suggestions: newSuggestionCache(cfg.autocomplete.cacheTTL, cfg.autocomplete.cacheSize),
+		adminStats:  &adminStatsCache{},
+		sampler:     newRateSampler(cfg.sampler.window),
+		schema:      schema.Runner{DB: db, LockTimeout: cfg.schema.lockTimeout},
+		shutdown:    make(chan struct{}),
+	}
+
+	// Screen reviews for the default profanities as well as any words in the blocklist
+	words := moderation.DefaultWords
+	if cfg.reviews.blocklist != "" {
+		blocklist, err := moderation.LoadWords(cfg.reviews.blocklist)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		words = append(append([]string{}, words...), blocklist...)
+	}
+
+	app.screener = moderation.NewWordScreener(words...)
+
+	app.emailDomains = data.NewEmailDomainPolicy(cfg.registration.allowedDomains, cfg.registration.deniedDomains, cfg.registration.blockDisposable)
+
+	app.mailLimiter = mailLimiter
+
+	if replica != nil {
+		app.writes = newWriteTracker(cfg.db.stickyWindow)
+	}
+
+	if cfg.guest.enabled {
+		app.guests = newMemoryLimiter(cfg.guest.rps, cfg.guest.burst)
+		app.guestTokens = newMemoryLimiter(cfg.guest.tokenRPS, cfg.guest.tokenBurst)
+	}
+
+	if cfg.tos.version != "" {
+		app.tosAccepted = newTOSAcceptanceCache()
+	}
+
+	app.ops = newOpsNotifier(cfg.notify.slackWebhook, cfg.notify.discordWebhook, cfg.notify.panicCooldown)
+
+	if cfg.backup.dir != "" {
+		app.backups = backup.Dir{Path: cfg.backup.dir}
+	}
+
+	// When started with the backup argument, such as `api -db-dsn=... backup`, back up
+	// the database and exit rather than serving requests
+	if flag.Arg(0) == "backup" {
+		_, err := app.backupDatabase(context.Background())
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		return
+	}
+
+	// When started with the operation argument, such as `api -db-dsn=... operation
+	// movies_updated_at_idx`, run the schema operation to completion and exit
+	if flag.Arg(0) == "operation" {
+		err := app.runSchemaOperationCommand(context.Background(), flag.Arg(1))
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		return
+	}
+
+	if cfg.availability.apiKey != "" {
+		app.providers = providers.New(cfg.availability.apiURL, cfg.availability.apiKey, cfg.availability.rps)
+	}
+
+	// Purge the responses cached by the CDN when movies change
+	if cfg.cdn.provider != "" {
+		app.purger, err = openPurger(cfg.cdn.provider, cfg.cdn.zone, cfg.cdn.token)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	// Publish domain events to the configured broker, starting with any left in the
+	// outbox from before the last restart
+	if cfg.events.broker != "" {
+		publisher, err := openPublisher(cfg.events.broker, cfg.events.addr)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		app.events = events.New(db, publisher, events.Options{
+			TopicPrefix:  cfg.events.topicPrefix,
+			PollInterval: cfg.events.pollInterval,
+			OnError:      logger.PrintError,
+		})
+		app.events.Start()
+	}
+
+	// Create the search index if it doesn't exist yet, and fill it with the movies in the
+	// background. If the search engine can't be reached the index is created on the next
+	// start, and searches are run against PostgreSQL until then.
+	if indexer != nil {
+		app.indexer = indexer
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+
+		created, err := indexer.index.EnsureIndex(ctx)
+		if err != nil {
+			logger.PrintError(err, map[string]string{"component": "search"})
+		}
+
+		if created {
+			err = app.jobs.Enqueue(ctx, reindexMoviesJob{Page: 1}, jobs.EnqueueOptions{Unique: true})
+			if err != nil {
+				logger.PrintFatal(err, nil)
+			}
+		}
+
+		cancel()
+	}
+
+	// Start running jobs, including any left over from before the last restart
+	app.registerJobs()
+	app.jobs.Start()
+
+	// Schedule the first saved search alerts run, unless another instance already has
+	if cfg.savedSearches.alertInterval > 0 {
+		err = app.scheduleSavedSearchAlerts(context.Background(), time.Now().Add(cfg.savedSearches.alertInterval))
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	// Schedule the first digests run, unless another instance already has
+	if cfg.digests.interval > 0 {
+		err = app.scheduleDigests(context.Background(), time.Now().Add(cfg.digests.interval))
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	// Schedule the first scheduled movies run, unless another instance already has
+	if cfg.publishing.interval > 0 {
+		err = app.schedulePublishing(context.Background(), time.Now().Add(cfg.publishing.interval))
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	// Schedule the first data retention run, unless another instance already has
+	if cfg.retention.interval > 0 {
+		err = app.scheduleRetention(context.Background(), time.Now().Add(cfg.retention.interval))
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	// Schedule the first streaming availability refresh straight away, so that new
+	// deployments don't wait for the interval before having any availability
+	if app.providers != nil && cfg.availability.refreshInterval > 0 {
+		err = app.scheduleAvailabilityRefresh(context.Background(), time.Now())
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	// Publish the request rates derived from the sampled counters
+	expvar.Publish("request_rates", expvar.Func(func() interface{} {
+		return app.sampler.rates()
+	}))
+
+	if cfg.sampler.interval > 0 {
+		app.startMetricsSampler(cfg.sampler.interval)
+	}
+
+	if cfg.db.monitor > 0 {
+		app.startPoolMonitor(poolMonitor, cfg.db.monitor)
+	}
+
+	// Tell the ops team about operational problems. The error rate is derived from the
+	// sampled request rates, so it is never alerted on when sampling is disabled.
+	if (cfg.alerts.email != "" || app.ops != nil) && cfg.alerts.interval > 0 {
+		app.startAlertMonitor(newAlertMonitor(poolMonitor, smtpBreaker), cfg.alerts.interval)
+	}
+
+	// Write the counted movie views to the database in batches
+	if cfg.views.flushInterval > 0 {
+		app.startViewFlusher(cfg.views.flushInterval)
+	}
+
+	// Pick up rotated SMTP passwords straight away. The database connection pool can't
+	// change its DSN once opened, so for that we only log that a restart is needed.
+	resolver.Watch(rawCfg.smtp.password, func(password string) {
+		smtpMailer.SetPassword(password)
+		logger.PrintInfo("smtp password rotated", nil)
+	})
+
+	resolver.Watch(rawCfg.db.dsn, func(string) {
+		logger.PrintInfo("database DSN rotated, restart the application to use it", nil)
+	})
+
+	if cfg.secrets.rotationInterval > 0 {
+		resolver.StartRotation(cfg.secrets.rotationInterval, func(err error) {
+			logger.PrintError(err, nil)
+		})
 	}
 
 	// Run server
@@ -156,14 +950,391 @@ func main() {
 	}
 }
 
-// The openDB() function returns a sql.DB connection pool
-func openDB(cfg config) (*sql.DB, error) {
-	// Create an empty connection pool using the DSN from the config struct
-	db, err := sql.Open("postgres", cfg.db.dsn)
+// The defineFlags() function defines the command-line flags on the given flag set, each
+// of which is read into the config struct when the flags are parsed. The defaults of
+// the config are the default values of the flags.
+func defineFlags(fs *flag.FlagSet, cfg *config) {
+	// Read the value of the `port` and `env` command-line flags into the config struct. We default to using
+	// the port number 4000 and the environment "development" if no corresponding flags are provided.
+	fs.IntVar(&cfg.port, "port", 4000, "API server port")
+	fs.StringVar(&cfg.listen, "listen", "", "Listen address, either host:port or unix:/path/to/socket (overrides -port)")
+	fs.StringVar(&cfg.env, "env", "develoment", "Environment (development|staging|production)")
+	fs.StringVar(&cfg.log.format, "log-format", "json", "Log output format (json|logfmt|pretty)")
+
+	// Read the DSN value from the `db-dsn` command-line flag into the config struct. We
+	// default to using our development DSN if no flag is provided.
+	fs.StringVar(&cfg.db.dsn, "db-dsn", "", "PostgreSQL DSN")
+	fs.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")
+	fs.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
+	fs.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max connection idle time")
+	fs.DurationVar(&cfg.db.slowQuery, "db-slow-query-threshold", 200*time.Millisecond, "Log queries which take longer than this (0 disables logging)")
+	fs.IntVar(&cfg.db.warmConns, "db-warm-conns", 5, "Number of PostgreSQL connections opened when starting (at most -db-max-idle-conns are kept)")
+	fs.DurationVar(&cfg.db.monitor, "db-monitor-interval", 30*time.Second, "How often the connection pool is pinged and checked for queries waiting for connections (0 disables the monitor)")
+	fs.DurationVar(&cfg.db.waitWarning, "db-wait-warning", 50*time.Millisecond, "Log when queries wait this long for a connection on average during a monitor interval")
+
+	// The reads of GET requests can be sent to a read replica, except for clients which
+	// wrote within the sticky window, whose reads go to the primary so that they see
+	// their own writes
+	fs.StringVar(&cfg.db.replicaDSN, "db-replica-dsn", "", "PostgreSQL read replica DSN (empty sends every query to the primary)")
+	fs.DurationVar(&cfg.db.stickyWindow, "db-sticky-window", 5*time.Second, "How long after a client writes its reads go to the primary rather than the replica")
+
+	fs.DurationVar(&cfg.cache.movieTTL, "movie-cache-ttl", time.Second, "How long fetched movies are micro-cached for (0 disables the cache)")
+
+	fs.DurationVar(&cfg.cache.authTTL, "auth-cache-ttl", 30*time.Second, "How long authentication token lookups are cached for (0 disables the cache)")
+	fs.IntVar(&cfg.cache.authMaxUsers, "auth-cache-size", 10_000, "Maximum number of cached authentication token lookups")
+
+	// Without Redis, permissions are cached in memory. Granting or revoking permissions
+	// only invalidates the cache of the instance which made the change, so the ttl bounds
+	// how long the other instances can act on a user's old permissions.
+	fs.DurationVar(&cfg.cache.permTTL, "permissions-cache-ttl", 5*time.Second, "How long user permissions are cached in memory when Redis isn't configured (0 disables the cache)")
+	fs.IntVar(&cfg.cache.permMaxUsers, "permissions-cache-size", 10_000, "Maximum number of users whose permissions are cached in memory")
+
+	// Trending movies are ranked by the views counted within the window. The total
+	// number of views is also written to the database periodically.
+	fs.DurationVar(&cfg.trending.window, "trending-window", 24*time.Hour, "Window over which movie views are counted for the trending list")
+	fs.DurationVar(&cfg.views.flushInterval, "movie-views-flush-interval", 10*time.Second, "How often counted movie views are written to the database (0 disables writing them)")
+	fs.DurationVar(&cfg.cache.listTTL, "movie-list-cache-ttl", time.Minute, "How long the trending and recent movie lists are cached for (0 disables the cache)")
+
+	// Title suggestions are requested on every keystroke, so they are cached and
+	// queries which take too long are abandoned
+	fs.DurationVar(&cfg.autocomplete.timeout, "autocomplete-timeout", 100*time.Millisecond, "Time budget for title suggestion queries (0 disables the budget)")
+	fs.DurationVar(&cfg.autocomplete.cacheTTL, "autocomplete-cache-ttl", time.Minute, "How long title suggestions are cached for (0 disables the cache)")
+	fs.IntVar(&cfg.autocomplete.cacheSize, "autocomplete-cache-size", 10_000, "Maximum number of cached title suggestion queries")
+
+	// Deployments exposing the movie database to web crawlers can let anyone read the
+	// movies and collections, and list the movies in a sitemap
+	fs.BoolVar(&cfg.catalog.public, "public-catalog", false, "Allow reading movies and collections without authenticating (writes still require permissions)")
+	fs.StringVar(&cfg.catalog.url, "public-url", "", "Public base URL of the API, such as https://api.example.com, used in /sitemap.xml (empty disables the sitemap, which is only served for a public catalog)")
+
+	// Users can be required to accept the terms of service before using the API. When
+	// the version changes, every user has to accept the new version.
+	fs.StringVar(&cfg.tos.version, "tos-version", "", "Current version of the terms of service users must accept (empty disables the requirement)")
+	fs.StringVar(&cfg.tos.url, "tos-url", "", "URL of the terms of service, sent to users who haven't accepted them")
+
+	// Clients without an account can instead be made to get a guest token to read the
+	// movies and collections, so that each browsing client is rate limited on its own
+	// quota as well as by IP address. Guest tokens are signed with the secret, which all
+	// the instances must share. The tokens each IP address can get are limited too, with
+	// a bucket which refills within the three minutes the limiter remembers a client for.
+	fs.BoolVar(&cfg.guest.enabled, "guest-sessions", false, "Allow clients to get a read-only guest token from POST /v1/tokens/guest")
+	fs.StringVar(&cfg.guest.secret, "guest-secret", os.Getenv("GUEST_SECRET"), "Secret used to sign guest tokens (required with -guest-sessions)")
+	fs.DurationVar(&cfg.guest.ttl, "guest-ttl", time.Hour, "How long guest tokens are valid for")
+	fs.Float64Var(&cfg.guest.rps, "guest-limiter-rps", 2, "Rate limiter maximum requests per second for each guest")
+	fs.IntVar(&cfg.guest.burst, "guest-limiter-burst", 4, "Rate limiter maximum burst for each guest")
+	fs.Float64Var(&cfg.guest.tokenRPS, "guest-token-rps", 0.05, "Maximum guest tokens issued per second to each IP address")
+	fs.IntVar(&cfg.guest.tokenBurst, "guest-token-burst", 3, "Maximum burst of guest tokens issued to each IP address")
+
+	// Catalogs with several editors can restrict movies:write to the movies each user
+	// created, leaving everyone else's to users holding movies:admin
+	fs.BoolVar(&cfg.ownership.enabled, "movie-ownership", false, "Only allow editing the movies a user created, unless they hold the movies:admin permission")
+
+	// Responses of the public catalog can be cached by a CDN, which is purged by surrogate
+	// key when movies change
+	fs.StringVar(&cfg.cdn.provider, "cdn-provider", "", "CDN caching the public catalog, purged when movies change (fastly|cloudflare, empty to disable purging)")
+	fs.StringVar(&cfg.cdn.zone, "cdn-zone", "", "Fastly service ID or Cloudflare zone ID")
+	fs.StringVar(&cfg.cdn.token, "cdn-token", os.Getenv("CDN_TOKEN"), "CDN API token allowed to purge the cache")
+	fs.DurationVar(&cfg.cdn.maxAge, "cdn-max-age", 5*time.Minute, "How long a CDN may cache responses of the public catalog for (0 disables CDN caching)")
+
+	fs.DurationVar(&cfg.sampler.interval, "metrics-sample-interval", 5*time.Second, "How often the request counters are sampled to derive request rates (0 disables sampling)")
+	fs.DurationVar(&cfg.sampler.window, "metrics-rate-window", time.Minute, "The window the request rates are derived over")
+	fs.DurationVar(&cfg.cache.statsTTL, "admin-stats-cache-ttl", 15*time.Second, "How long the admin dashboard stats are cached for (0 disables the cache)")
+
+	// The Redis cache is optional and only used when an address is provided
+	fs.StringVar(&cfg.redis.addr, "redis-addr", "", "Redis address for the model cache (empty to disable)")
+	fs.StringVar(&cfg.redis.password, "redis-password", "", "Redis password")
+	fs.IntVar(&cfg.redis.db, "redis-db", 0, "Redis database number")
+	fs.DurationVar(&cfg.redis.movieTTL, "redis-movie-ttl", 5*time.Minute, "How long movies are cached in Redis for")
+	fs.DurationVar(&cfg.redis.permissionsTTL, "redis-permissions-ttl", time.Minute, "How long user permissions are cached in Redis for")
+
+	fs.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
+	fs.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
+	fs.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+
+	// Cap the number of requests being handled at once, so that a slow database makes
+	// requests queue up briefly and then get turned away rather than piling up
+	fs.IntVar(&cfg.concurrency.maxInFlight, "max-in-flight", 500, "Maximum number of requests handled concurrently (0 disables the limit)")
+	fs.IntVar(&cfg.concurrency.queueSize, "max-in-flight-queue", 100, "Maximum number of requests waiting for one of the in-flight requests to finish")
+	fs.DurationVar(&cfg.concurrency.queueWait, "max-in-flight-wait", time.Second, "How long a request waits in the queue before a 503 response is sent")
+
+	// Let callers set a time budget for their requests, capped so that a client can't
+	// keep a request (and its database connection) busy for longer than the server would.
+	// Budgets below the minimum are raised to it, so that clients can't have most of
+	// their requests cancelled partway through their database queries.
+	fs.DurationVar(&cfg.deadline.min, "request-timeout-min", 500*time.Millisecond, "Shortest time budget a client can set with the X-Request-Timeout or grpc-timeout header")
+	fs.DurationVar(&cfg.deadline.max, "request-timeout-max", 20*time.Second, "Longest time budget a client can set with the X-Request-Timeout or grpc-timeout header (0 ignores the headers)")
+
+	// Wait for the database (and optionally the SMTP server) to come up when starting,
+	// as container orchestrators don't guarantee the order services start in
+	fs.DurationVar(&cfg.startup.timeout, "startup-timeout", time.Minute, "How long to keep retrying unreachable dependencies when starting")
+	fs.BoolVar(&cfg.startup.failFast, "fail-fast", false, "Exit straight away if a dependency is unreachable when starting, instead of retrying")
+	fs.BoolVar(&cfg.startup.verifySMTP, "smtp-verify", false, "Check that the SMTP server is reachable and accepts the credentials when starting")
+
+	// Stop trying the database and SMTP server for a while after they keep failing
+	fs.IntVar(&cfg.circuit.threshold, "circuit-threshold", 5, "Number of consecutive database connection or email failures before failing fast (0 disables the circuit breakers)")
+	fs.DurationVar(&cfg.circuit.cooldown, "circuit-cooldown", 10*time.Second, "How long a circuit breaker fails fast before trying the dependency again")
+
+	// Clients which keep getting rate limited or failing authentication are banned for a
+	// while, which stops them from reaching the database at all
+	fs.IntVar(&cfg.abuse.threshold, "abuse-ban-threshold", 30, "Number of 429 or 401 responses within the window before a client is banned (0 disables bans)")
+	fs.DurationVar(&cfg.abuse.window, "abuse-window", time.Minute, "Window for counting 429 and 401 responses")
+	fs.DurationVar(&cfg.abuse.banDuration, "abuse-ban-duration", 15*time.Minute, "How long abusive clients are banned for")
+
+	// Request bodies are limited per route, with a smaller limit for the authentication
+	// endpoints, which only take a few short fields, and a larger one for bulk uploads
+	fs.Int64Var(&cfg.body.maxBytes, "body-max-bytes", 1_048_576, "Maximum request body size in bytes, and maximum record size in bulk uploads")
+	fs.Int64Var(&cfg.body.authMaxBytes, "auth-body-max-bytes", 16_384, "Maximum request body size in bytes for the user and token endpoints")
+	fs.Int64Var(&cfg.body.bulkMaxBytes, "bulk-body-max-bytes", 67_108_864, "Maximum request body size in bytes for bulk uploads")
+
+	// Unknown fields in request bodies are rejected by default. Allowing them keeps older
+	// clients working after a field is removed, except on the movie and collection write
+	// endpoints, where an ignored misspelled field would look like a successful update.
+	fs.BoolVar(&cfg.body.allowUnknownFields, "allow-unknown-fields", false, "Ignore unknown fields in request bodies with a warning instead of rejecting them")
+	// Timestamps are stored with their time zone and written in RFC 3339 format, using
+	// this time zone's offset
+	fs.StringVar(&cfg.timezone, "timezone", "UTC", "IANA time zone of the timestamps in responses, such as UTC or Europe/Lisbon")
+	fs.StringVar(&cfg.docsURL, "docs-url", "", "URL of the API documentation, linked from error responses (empty to omit the links)")
+
+	fs.StringVar(&cfg.smtp.host, "smtp-host", "smtp.mailtrap.io", "SMTP host")
+	fs.IntVar(&cfg.smtp.port, "smtp-port", 2525, "SMTP port")
+	fs.StringVar(&cfg.smtp.username, "smtp-username", "", "SMTP username")
+	fs.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
+	fs.StringVar(&cfg.smtp.sender, "smtp-sender", "Greenlight <no-reply@greenlight.alexedwards.net>", "SMTP sender")
+	fs.StringVar(&cfg.smtp.filesDir, "smtp-files-dir", "", "Directory the files attached to emails, such as export archives and inline logos, are read from (empty disables attachments)")
+	fs.StringVar(&cfg.smtp.replyTo, "smtp-reply-to", "", "Reply-To address of emails (empty to leave the header out)")
+	fs.StringVar(&cfg.smtp.unsubscribe, "smtp-list-unsubscribe", "", "mailto: or https: URL sent in the List-Unsubscribe header of the digests and alerts users subscribe to (empty to leave the header out)")
+
+	// Emails sent through a raw SMTP relay are signed with DKIM, so that they aren't
+	// marked as spam. The domain defaults to the one of the sender address.
+	fs.StringVar(&cfg.smtp.dkimKey, "smtp-dkim-key", os.Getenv("SMTP_DKIM_KEY"), "PEM encoded RSA private key emails are signed with (empty disables DKIM signing)")
+	fs.StringVar(&cfg.smtp.dkimDomain, "smtp-dkim-domain", "", "Domain of the DKIM signature (defaults to the domain of -smtp-sender)")
+	fs.StringVar(&cfg.smtp.dkimSelector, "smtp-dkim-selector", "greenlight", "Selector of the DKIM public key record in DNS")
+	fs.IntVar(&cfg.smtp.perRecipient, "smtp-recipient-limit", 5, "Maximum number of emails sent to an address per hour (0 for no limit)")
+
+	// The email provider reports bounces and complaints to POST /v1/webhooks/email/ses or
+	// /v1/webhooks/email/sendgrid, with the secret in the token query parameter
+	fs.StringVar(&cfg.smtp.webhookSecret, "email-webhook-secret", os.Getenv("EMAIL_WEBHOOK_SECRET"), "Secret the email provider sends to the bounce webhooks (empty disables them)")
+
+	// New, stricter validation rules are rolled out by running them in shadow mode
+	// first, which only logs and counts the requests they would reject
+	fs.Func("validation-shadow", fmt.Sprintf("Validation rules run in shadow mode (space separated, out of %s)", strings.Join(data.ValidationRules, ", ")), func(val string) error {
+		rules, err := parseValidationRules(val)
+		cfg.validation.shadow = rules
+
+		return err
+	})
+	fs.Func("validation-enforce", "Validation rules being rolled out which are enforced (space separated)", func(val string) error {
+		rules, err := parseValidationRules(val)
+		cfg.validation.enforce = rules
+
+		return err
+	})
+
+	fs.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
+		cfg.cors.trustedOrigins = strings.Fields(val)
+
+		return nil
+	})
+
+	// The locale of each request is picked from its Accept-Language header, falling
+	// back to the first supported locale
+	cfg.locales = []string{"en"}
+	fs.Func("locales", "Supported locales, the first being the default (space separated, default \"en\")", func(val string) error {
+		cfg.locales = strings.Fields(val)
+
+		return nil
+	})
+
+	// Serving TLS directly enables HTTP/2. HTTP/3 is experimental and also needs the
+	// binary to be built with -tags=http3.
+	fs.StringVar(&cfg.tls.certFile, "tls-cert", "", "TLS certificate file (enables HTTPS and HTTP/2)")
+	fs.StringVar(&cfg.tls.keyFile, "tls-key", "", "TLS private key file")
+	fs.BoolVar(&cfg.http3.enabled, "http3-enabled", false, "Enable the experimental HTTP/3 listener")
+	fs.StringVar(&cfg.http3.addr, "http3-addr", "", "HTTP/3 UDP listen address (defaults to the -listen or -port value)")
+
+	// The admin listener serves /debug/vars, /metrics and /debug/pprof separately from
+	// the API. Setting it to an empty string serves the others on the public port
+	// instead, but profiling then can't be enabled.
+	fs.StringVar(&cfg.admin.addr, "admin-addr", "localhost:4001", "Admin listener address for metrics and debug endpoints (empty to disable)")
+	fs.BoolVar(&cfg.pprof.enabled, "pprof-enabled", false, "Enable the /debug/pprof endpoints on the admin listener")
+
+	// Request and response bodies can also be logged for a single request by a user
+	// with the debug:read permission, using the X-Debug-Log-Bodies header
+	fs.BoolVar(&cfg.debug.logBodies, "debug-log-bodies", false, "Log request and response bodies for every request (not for production)")
+	fs.IntVar(&cfg.debug.bodyLimit, "debug-body-limit", 4096, "Maximum number of bytes of each body to log")
+
+	// Partner integrations can sign their requests with a shared secret instead of
+	// sending a bearer token. Signatures older than the window are rejected.
+	fs.BoolVar(&cfg.signing.enabled, "request-signing-enabled", false, "Accept HMAC-signed requests from API clients")
+	fs.DurationVar(&cfg.signing.window, "request-signing-window", 5*time.Minute, "Maximum age of a signed request")
+
+	// Emails and other slow work are run by job workers from a queue in PostgreSQL
+	fs.IntVar(&cfg.jobs.concurrency, "jobs-concurrency", 4, "Number of jobs run at the same time")
+	fs.DurationVar(&cfg.jobs.pollInterval, "jobs-poll-interval", time.Second, "How often idle job workers check for new jobs")
+
+	// Movie and user lifecycle events are published to NATS or Kafka when a broker is
+	// configured, going through an outbox table so that they aren't lost while the
+	// broker is unavailable
+	fs.StringVar(&cfg.events.broker, "events-broker", "", "Message broker domain events are published to (nats|kafka, empty to disable)")
+	fs.StringVar(&cfg.events.addr, "events-addr", os.Getenv("EVENTS_ADDR"), "NATS URL (nats://[user:password@]host:port) or Kafka brokers (space separated host:port)")
+	fs.StringVar(&cfg.events.topicPrefix, "events-topic-prefix", "greenlight.", "Prefix of the topics (or subjects) events are published to")
+	fs.DurationVar(&cfg.events.pollInterval, "events-poll-interval", time.Second, "How often the outbox is checked for events which haven't been published")
+
+	// Movie searches can be run against Elasticsearch (or OpenSearch) for catalogs too
+	// large for the PostgreSQL full-text search, or against Meilisearch for smaller
+	// deployments. The search index is kept in sync by job workers.
+	fs.StringVar(&cfg.search.backend, "search-backend", "postgres", "Backend movie searches are run against (postgres|elasticsearch|meilisearch)")
+	fs.StringVar(&cfg.search.url, "search-url", os.Getenv("SEARCH_URL"), "Search engine URL (http[s]://[user:password@]host:port, credentials for Elasticsearch only)")
+	fs.StringVar(&cfg.search.index, "search-index", "movies", "Name of the search index holding the movies")
+	fs.StringVar(&cfg.search.apiKey, "search-api-key", os.Getenv("SEARCH_API_KEY"), "Meilisearch API key")
+
+	// Users are emailed about new movies matching their saved searches by a recurring job
+	fs.DurationVar(&cfg.savedSearches.alertInterval, "saved-search-alert-interval", time.Hour, "How often saved searches are checked for new movies (0 disables alerts)")
+
+	// Digest emails are sent by a recurring job to the subscribers who are due one. The
+	// emails of each batch of subscribers are queued to be sent a pause after those of
+	// the previous batch, so that a large run doesn't flood the SMTP server.
+	fs.DurationVar(&cfg.digests.interval, "digest-interval", time.Hour, "How often digest subscriptions are checked for digests which are due (0 disables digests)")
+	fs.IntVar(&cfg.digests.batchSize, "digest-batch-size", 100, "Number of digest emails queued to be sent at the same time")
+	fs.DurationVar(&cfg.digests.batchPause, "digest-batch-pause", time.Minute, "Delay between sending each batch of digest emails")
+
+	// Scheduled movies are published by a recurring job, so they can go live up to one
+	// interval after their publish_at time
+	fs.DurationVar(&cfg.publishing.interval, "publish-interval", time.Minute, "How often scheduled movies are checked for publishing (0 disables scheduled publishing)")
+
+	// Operational problems are emailed to the ops address and posted to the chat webhooks,
+	// for deployments which don't have a monitoring stack watching the metrics
+	fs.StringVar(&cfg.alerts.email, "alert-email", "", "Email address operational alerts are sent to (empty to only post them to the chat webhooks)")
+	fs.DurationVar(&cfg.alerts.interval, "alert-interval", time.Minute, "How often the metrics are checked against the alert thresholds")
+	fs.DurationVar(&cfg.alerts.cooldown, "alert-cooldown", 30*time.Minute, "How long after an alert is sent before the same alert is sent again")
+	fs.Float64Var(&cfg.alerts.errorRate, "alert-error-rate", 0.05, "Fraction of responses over the -metrics-rate-window which are server errors before alerting (0 disables the alert)")
+	fs.IntVar(&cfg.alerts.serverErrors, "alert-server-errors", 50, "Number of server errors within an -alert-interval before alerting (0 disables the alert)")
+	fs.IntVar(&cfg.alerts.emailFailures, "alert-email-failures", 5, "Number of emails running out of attempts within an -alert-interval before alerting (0 disables the alert)")
+	fs.BoolVar(&cfg.alerts.databasePool, "alert-db-pool", true, "Alert when the database can't be pinged or queries wait longer than -db-wait-warning for connections (needs -db-monitor-interval)")
+
+	// Starts, shutdowns, panics and alerts are posted to chat webhooks
+	fs.StringVar(&cfg.notify.slackWebhook, "notify-slack-webhook", os.Getenv("SLACK_WEBHOOK_URL"), "Slack incoming webhook URL operational messages are posted to (empty to disable)")
+	fs.StringVar(&cfg.notify.discordWebhook, "notify-discord-webhook", os.Getenv("DISCORD_WEBHOOK_URL"), "Discord webhook URL operational messages are posted to (empty to disable)")
+	fs.DurationVar(&cfg.notify.panicCooldown, "notify-panic-cooldown", 5*time.Minute, "How long after a panic is posted before another one is posted")
+
+	// The database can be backed up with pg_dump, either from the admin endpoints or by
+	// running the binary with the backup argument (such as from cron)
+	fs.StringVar(&cfg.backup.dir, "backup-dir", "", "Directory database backups are written to (empty disables backups)")
+	fs.StringVar(&cfg.backup.pgDump, "backup-pg-dump", "pg_dump", "Path of the pg_dump binary used for backups")
+	fs.IntVar(&cfg.backup.keep, "backup-keep", 7, "Number of completed backups kept, older ones being deleted after each backup (0 keeps them all)")
+
+	// Online schema changes, such as building indexes concurrently and backfilling
+	// columns in batches, are run from the operations API or with the operation argument
+	fs.DurationVar(&cfg.schema.lockTimeout, "schema-lock-timeout", 5*time.Second, "How long schema operations wait for a table lock before failing (0 waits forever)")
+	fs.DurationVar(&cfg.schema.batchPause, "schema-batch-pause", 100*time.Millisecond, "Pause between the batches of a backfill")
+	fs.DurationVar(&cfg.schema.budget, "schema-batch-budget", time.Minute, "How long a backfill runs in each job before queueing another to carry on")
+
+	// Data which is no longer needed is deleted by a recurring job once it has been kept
+	// for its retention window, so that the tables don't grow without bound
+	fs.DurationVar(&cfg.retention.interval, "retention-interval", time.Hour, "How often data past its retention window is deleted (0 disables deleting it)")
+	fs.IntVar(&cfg.retention.batchSize, "retention-batch-size", 1000, "Maximum number of rows deleted by each retention query")
+	fs.DurationVar(&cfg.retention.expiredTokens, "retention-expired-tokens", 7*24*time.Hour, "How long tokens are kept after they expire (0 keeps them forever)")
+	fs.DurationVar(&cfg.retention.failedJobs, "retention-failed-jobs", 30*24*time.Hour, "How long jobs which ran out of attempts are kept, including the email addresses of failed emails (0 keeps them forever)")
+	fs.DurationVar(&cfg.retention.readNotifications, "retention-read-notifications", 90*24*time.Hour, "How long notifications are kept after they are read (0 keeps them forever)")
+	fs.DurationVar(&cfg.retention.movieChanges, "retention-movie-changes", 365*24*time.Hour, "How long proposed movie changes are kept after they are reviewed (0 keeps them forever)")
+
+	// Streaming availability is fetched from a watch-provider API by a recurring job,
+	// when an API key is provided
+	fs.StringVar(&cfg.availability.apiKey, "watch-providers-api-key", os.Getenv("WATCH_PROVIDERS_API_KEY"), "Watch-provider API key (empty to disable streaming availability)")
+	fs.StringVar(&cfg.availability.apiURL, "watch-providers-url", "https://api.themoviedb.org/3", "Watch-provider API base URL")
+	fs.Float64Var(&cfg.availability.rps, "watch-providers-rps", 20, "Maximum watch-provider API requests per second")
+	fs.DurationVar(&cfg.availability.ttl, "availability-ttl", 24*time.Hour, "How long fetched streaming availability is used before it is refreshed")
+	fs.DurationVar(&cfg.availability.refreshInterval, "availability-refresh-interval", time.Hour, "How often out of date streaming availability is refreshed (0 disables refreshing)")
+
+	// Activation tokens are emailed to new users, and authentication tokens can be
+	// requested with a shorter expiry than the default
+	fs.DurationVar(&cfg.tokens.activationTTL, "activation-token-ttl", data.DefaultActivationTokenTTL, "How long activation tokens can be used for")
+	fs.DurationVar(&cfg.tokens.authenticationTTL, "authentication-token-ttl", data.DefaultAuthenticationTokenTTL, "How long authentication tokens last for, and the longest expiry clients can request")
+	fs.DurationVar(&cfg.tokens.impersonationTTL, "impersonation-token-ttl", 15*time.Minute, "How long the tokens admins get to act as a user last for")
+
+	// With closed registration, new users need an invitation sent by a user holding
+	// the invitations:write permission
+	fs.BoolVar(&cfg.registration.closed, "registration-closed", false, "Only allow users with an invitation to register")
+	fs.DurationVar(&cfg.registration.invitationTTL, "invitation-ttl", 7*24*time.Hour, "How long invitations can be accepted for")
+
+	// Users registering without an invitation can be restricted to some email domains,
+	// and kept from using others or disposable email addresses
+	fs.Func("signup-allowed-domains", "Email domains users can register with, including their subdomains (space separated, empty allows all)", func(val string) error {
+		cfg.registration.allowedDomains = strings.Fields(val)
+		return nil
+	})
+	fs.Func("signup-denied-domains", "Email domains users can't register with, including their subdomains (space separated)", func(val string) error {
+		cfg.registration.deniedDomains = strings.Fields(val)
+		return nil
+	})
+	fs.BoolVar(&cfg.registration.blockDisposable, "signup-block-disposable", true, "Reject registrations using a well-known disposable email domain")
+
+	// Reviews are screened for profanity before being published. Reviews which fail the
+	// screening, or all reviews with pre-moderation, are held for a moderator.
+	fs.BoolVar(&cfg.reviews.premoderation, "review-premoderation", false, "Hold all new reviews for a moderator")
+	fs.StringVar(&cfg.reviews.blocklist, "review-blocklist", "", "File of extra words, one per line, which hold reviews for a moderator")
+	fs.IntVar(&cfg.reviews.reportThreshold, "review-report-threshold", 3, "Number of open reports which hide a review until it is moderated (0 disables hiding)")
+
+	// Server errors and panics are reported to Sentry when a DSN is provided
+	fs.StringVar(&cfg.errtrack.dsn, "sentry-dsn", os.Getenv("SENTRY_DSN"), "Sentry DSN for error tracking (empty to disable)")
+	fs.Float64Var(&cfg.errtrack.sampleRate, "errtrack-sample-rate", 1, "Fraction of errors reported to the error tracker")
+	fs.BoolVar(&cfg.errtrack.scrubPII, "errtrack-scrub-pii", true, "Remove client IPs, query string values and email addresses from reported errors")
+
+	// Any of the DSN and SMTP credential values above can be written as a reference to a
+	// secret, such as "vault:kv/greenlight#db-dsn" or "awssm:greenlight/smtp-password".
+	// The Vault token and AWS credentials are only ever read from the environment so
+	// that they don't show up in the process arguments.
+	fs.DurationVar(&cfg.secrets.rotationInterval, "secrets-rotation-interval", 0, "Interval for re-fetching secrets (0 disables rotation)")
+	fs.StringVar(&cfg.secrets.vaultAddr, "vault-addr", os.Getenv("VAULT_ADDR"), "Vault server address")
+	fs.StringVar(&cfg.secrets.awsRegion, "aws-region", os.Getenv("AWS_REGION"), "AWS region for Secrets Manager")
+}
+
+// The resolveSecrets() function registers the configured secrets backends and replaces
+// any config values written as secret references with the values they point to
+func resolveSecrets(cfg *config) (*secrets.Resolver, error) {
+	resolver := secrets.New()
+
+	if cfg.secrets.vaultAddr != "" {
+		resolver.Register("vault", secrets.NewVaultProvider(cfg.secrets.vaultAddr, os.Getenv("VAULT_TOKEN")))
+	}
+
+	if cfg.secrets.awsRegion != "" {
+		resolver.Register("awssm", secrets.NewAWSProvider(
+			cfg.secrets.awsRegion,
+			os.Getenv("AWS_ACCESS_KEY_ID"),
+			os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			os.Getenv("AWS_SESSION_TOKEN"),
+		))
+	}
+
+	for _, value := range []*string{&cfg.db.dsn, &cfg.db.replicaDSN, &cfg.smtp.username, &cfg.smtp.password, &cfg.redis.password, &cfg.errtrack.dsn, &cfg.availability.apiKey, &cfg.events.addr, &cfg.search.url, &cfg.search.apiKey, &cfg.cdn.token, &cfg.notify.slackWebhook, &cfg.notify.discordWebhook, &cfg.guest.secret, &cfg.smtp.webhookSecret, &cfg.smtp.dkimKey} {
+		secret, err := resolver.Resolve(*value)
+		if err != nil {
+			if errors.Is(err, secrets.ErrBackendNotConfigured) {
+				return nil, fmt.Errorf("%w (vault needs -vault-addr and awssm needs -aws-region)", err)
+			}
+
+			return nil, err
+		}
+
+		*value = secret
+	}
+
+	return resolver, nil
+}
+
+// The openDB() function returns a sql.DB connection pool for the given DSN, which opens
+// connections through the given circuit breaker
+func openDB(cfg config, dsn string, breaker *circuit.Breaker) (*sql.DB, error) {
+	// Create an empty connection pool using the DSN
+	// Ask PostgreSQL for timestamps in the configured time zone, so that they match the
+	// ones created by the application
+	connector, err := pq.NewConnector(dsnWithParam(dsn, "timezone", cfg.timezone))
 	if err != nil {
 		return nil, err
 	}
 
+	db := sql.OpenDB(circuit.Connector(connector, breaker))
+
 	// Set the maximum number of open (in-use + idle) connections in the pool
 	db.SetMaxOpenConns(cfg.db.maxOpenConns)
 
@@ -180,6 +1351,11 @@ func openDB(cfg config) (*sql.DB, error) {
 	// Set the maximum idle timeout
 	db.SetConnMaxIdleTime(duration)
 
+	return db, nil
+}
+
+// The pingDB() function checks that a connection to the database can be established
+func pingDB(db *sql.DB) error {
 	// Create a context with a 5-second timeout deadline
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -187,10 +1363,74 @@ func openDB(cfg config) (*sql.DB, error) {
 	// Establish a new connection to the database, passing in the context we created
 	// above as a parameter. If the connection couldn't be established successfully
 	// within the 5 second deadline, then this will return an error.
-	err = db.PingContext(ctx)
-	if err != nil {
-		return nil, err
+	return db.PingContext(ctx)
+}
+
+// The waitForDependency() function calls check until it succeeds, backing off
+// exponentially between attempts, and returns the last error once the startup timeout
+// has passed. With -fail-fast the first error is returned straight away.
+func waitForDependency(logger *logger.Logger, name string, cfg config, check func() error) error {
+	deadline := time.Now().Add(cfg.startup.timeout)
+	backoff := 500 * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		err := check()
+		if err == nil {
+			return nil
+		}
+
+		if cfg.startup.failFast || time.Now().After(deadline) {
+			return fmt.Errorf("%s unreachable: %w", name, err)
+		}
+
+		// There's no point retrying before the dependency's circuit breaker lets a
+		// call through again
+		wait := backoff
+
+		var openErr *circuit.OpenError
+		if errors.As(err, &openErr) && openErr.RetryAfter > wait {
+			wait = openErr.RetryAfter
+		}
+
+		logger.PrintInfo("waiting for dependency", map[string]string{
+			"dependency": name,
+			"attempt":    strconv.Itoa(attempt),
+			"error":      err.Error(),
+			"retry_in":   wait.String(),
+		})
+
+		time.Sleep(wait)
+
+		if backoff < 10*time.Second {
+			backoff *= 2
+		}
 	}
+}
 
-	return db, nil
+// Add a connection parameter to a PostgreSQL DSN, in either the URL or the key/value
+// format, unless the DSN already sets it
+func dsnWithParam(dsn, key, value string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// Leave the DSN as it is, so that sql.Open() reports the problem
+			return dsn
+		}
+
+		query := u.Query()
+		if query.Get(key) == "" {
+			query.Set(key, value)
+			u.RawQuery = query.Encode()
+		}
+
+		return u.String()
+	}
+
+	for _, field := range strings.Fields(dsn) {
+		if strings.HasPrefix(field, key+"=") {
+			return dsn
+		}
+	}
+
+	return strings.TrimSpace(dsn + " " + key + "=" + value)
 }
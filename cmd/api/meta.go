@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// Struct used for holding the information that identifies a running build of the application
+type buildMetadata struct {
+	Version   string `json:"version"`
+	BuildTime string `json:"build_time"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"go_version"`
+}
+
+// The readBuildMetadata() function combines the version and build time set by the
+// linker flags with the VCS information that the Go toolchain embeds in the binary
+func readBuildMetadata() buildMetadata {
+	metadata := buildMetadata{
+		Version:   version,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return metadata
+	}
+
+	modified := false
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			metadata.Commit = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+
+	// Flag builds made from a working tree with uncommitted changes
+	if modified && metadata.Commit != "" {
+		metadata.Commit += "-dirty"
+	}
+
+	return metadata
+}
+
+// Return the log properties which identify the running build. These are added to
+// every log entry written by the application.
+func (m buildMetadata) logProperties() map[string]string {
+	return map[string]string{
+		"version":    m.Version,
+		"build_time": m.BuildTime,
+		"commit":     m.Commit,
+		"go_version": m.GoVersion,
+	}
+}
+
+// Return which optional features are enabled in the current configuration
+func (app *application) enabledFeatures() map[string]bool {
+	features := map[string]bool{
+		"rate_limiter":         app.config.limiter.enabled,
+		"cors":                 len(app.config.cors.trustedOrigins) > 0,
+		"secrets_rotation":     app.config.secrets.rotationInterval > 0,
+		"pprof":                app.config.pprof.enabled,
+		"admin_listener":       app.config.admin.addr != "",
+		"tls":                  app.config.tls.certFile != "",
+		"http3":                app.config.http3.enabled,
+		"redis_cache":          app.config.redis.addr != "",
+		"request_signing":      app.config.signing.enabled,
+		"abuse_bans":           app.config.abuse.threshold > 0,
+		"error_tracking":       app.config.errtrack.dsn != "",
+		"availability":         app.config.availability.apiKey != "",
+		"public_catalog":       app.config.catalog.public,
+		"sitemap":              app.config.catalog.public && app.config.catalog.url != "",
+		"cdn_purge":            app.config.catalog.public && app.config.cdn.provider != "",
+		"movie_ownership":      app.config.ownership.enabled,
+		"scheduled_publishing": app.config.publishing.interval > 0,
+	}
+
+	// The modes of the validation rules being rolled out
+	for _, rule := range app.config.validation.shadow {
+		features["validation_shadow."+rule] = true
+	}
+
+	for _, rule := range app.config.validation.enforce {
+		features["validation_enforce."+rule] = true
+	}
+
+	return features
+}
+
+// Handler for the "GET /v1/meta" endpoint
+func (app *application) metaHandler(w http.ResponseWriter, r *http.Request) {
+	env := envelope{
+		"build":    readBuildMetadata(),
+		"features": app.contextGetFlags(r),
+	}
+
+	if app.config.tos.version != "" {
+		env["tos_version"] = app.config.tos.version
+	}
+
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/meta/routes" endpoint, which lists the routes served on the
+// public port along with the permission each requires and whether it is rate limited.
+// The routes depend on the enabled features, so they describe this deployment.
+func (app *application) routesHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"routes": app.routeTable}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
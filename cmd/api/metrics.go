@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/LuisBarroso37/Greenlight/internal/metrics"
+)
+
+// Handler for the "GET /metrics" endpoint. This exposes the numeric expvar variables
+// in the Prometheus text exposition format so that they can be scraped without running
+// a separate exporter. Nested values are flattened into underscore separated names,
+// and the entries of expvar maps become labels.
+func (app *application) prometheusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	buffer := bufio.NewWriter(w)
+	defer buffer.Flush()
+
+	expvar.Do(func(kv expvar.KeyValue) {
+		// Histograms are published to expvar too, but are written out separately below
+		// so that they keep their bucket structure
+		if _, ok := kv.Value.(*metrics.HistogramVec); ok {
+			return
+		}
+
+		name := "greenlight_" + sanitizeMetricName(kv.Key)
+
+		// Expvar maps are keyed by things like the status code, which work best as labels
+		if m, ok := kv.Value.(*expvar.Map); ok {
+			var lines []string
+
+			m.Do(func(entry expvar.KeyValue) {
+				var value float64
+				if json.Unmarshal([]byte(entry.Value.String()), &value) == nil {
+					lines = append(lines, fmt.Sprintf("%s{key=%q} %v", name, entry.Key, value))
+				}
+			})
+
+			writeMetric(buffer, lines)
+			return
+		}
+
+		var value interface{}
+		if json.Unmarshal([]byte(kv.Value.String()), &value) != nil {
+			return
+		}
+
+		var lines []string
+		flattenMetric(name, value, &lines)
+
+		writeMetric(buffer, lines)
+	})
+
+	metrics.Do(func(h *metrics.HistogramVec) {
+		h.WritePrometheus(buffer, "greenlight_")
+	})
+}
+
+// Write the samples for a metric in a stable order. We don't write TYPE comments as
+// expvar doesn't tell us whether a value is a counter or a gauge, and Prometheus
+// treats samples without one as untyped.
+func writeMetric(w *bufio.Writer, lines []string) {
+	sort.Strings(lines)
+
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// Recursively collect the numeric values in a decoded JSON value as samples. Strings
+// and arrays have no sensible representation as a sample, so they are skipped.
+func flattenMetric(name string, value interface{}, lines *[]string) {
+	switch value := value.(type) {
+	case float64:
+		*lines = append(*lines, fmt.Sprintf("%s %v", name, value))
+	case bool:
+		if value {
+			*lines = append(*lines, name+" 1")
+		} else {
+			*lines = append(*lines, name+" 0")
+		}
+	case map[string]interface{}:
+		for key, nested := range value {
+			flattenMetric(name+"_"+sanitizeMetricName(key), nested, lines)
+		}
+	}
+}
+
+// Convert an expvar name into a valid Prometheus metric name, which may only contain
+// ASCII letters, digits and underscores
+func sanitizeMetricName(name string) string {
+	name = strings.ReplaceAll(name, "μ", "u")
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -1,20 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"expvar"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/metrics"
+	"github.com/LuisBarroso37/Greenlight/internal/reqctx"
+	"github.com/LuisBarroso37/Greenlight/internal/tracing"
 	"github.com/LuisBarroso37/Greenlight/internal/validator"
 	"github.com/felixge/httpsnoop"
 	"github.com/tomasen/realip"
-	"golang.org/x/time/rate"
 )
 
 func (app *application) recoverPanic(next http.Handler) http.Handler {
@@ -30,6 +37,13 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 				// sent.
 				w.Header().Set("Connection", "close")
 
+				fields := map[string]string{"method": r.Method, "path": r.URL.Path}
+				if trace, ok := tracing.FromContext(r.Context()); ok {
+					fields["trace_id"] = trace.TraceID
+				}
+
+				app.notifyPanic("Request panicked", err, fields)
+
 				// The value returned by recover() has the type interface{}, so we use
 				// fmt.Errorf() to normalize it into an error and call our
 				// serverErrorResponse() helpers
@@ -41,78 +55,186 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
-// We will have a bucket that starts with "b" tokens in it.
-// Each time we receive a HTTP request, we will remove one token from the bucket.
-// Every 1/r seconds, a token is added back to the bucket — up to a maximum of "b" total tokens.
-// If we receive a HTTP request and the bucket is empty, then we should return a 429 Too Many Requests response.
-func (app *application) rateLimit(next http.Handler) http.Handler {
-	////// Any code written before the return statement is only run once \\\\\\
+// The requestID() middleware assigns every request an ID, which is returned in the
+// X-Request-ID header and included in log entries so that a client's report can be
+// matched to the logs. An ID sent by the client (or a proxy in front of the API) is
+// reused if it looks sensible, otherwise a random one is generated.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
 
-	// Define a client struct to hold the rate limiter and last seen time for each client
-	type Client struct {
-		limiter  *rate.Limiter
-		lastSeen time.Time
-	}
+		if !validRequestID(id) {
+			b := make([]byte, 16)
 
-	// Declare a mutex and a map to hold the clients' IP addresses and rate limiters
-	var (
-		mutex   sync.Mutex
-		clients = make(map[string]*Client)
-	)
-
-	// Launch a background goroutine which removes old entries from the "clients" map once every minute
-	go func() {
-		for {
-			time.Sleep(time.Minute)
-
-			// Lock the mutex to prevent any rate limiter checks from happening while
-			// the cleanup is taking place
-			mutex.Lock()
-
-			// Loop through all clients. If they haven't been seen within the last three
-			// minutes, delete the corresponding entry from the map.
-			for ip, client := range clients {
-				if time.Since(client.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
-				}
+			_, err := rand.Read(b)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
 			}
 
-			// Importantly, unlock the mutex when the cleanup is complete.
-			mutex.Unlock()
+			id = hex.EncodeToString(b)
 		}
-	}()
 
+		w.Header().Set("X-Request-ID", id)
+
+		next.ServeHTTP(w, app.contextSetRequestID(r, id))
+	})
+}
+
+// The traceContext() middleware continues the trace of a traceparent header sent by
+// the client (or a proxy in front of the API), giving the request its own span ID, or
+// starts a new trace if there isn't a valid one. The trace is included in log entries
+// and passed on to the services called while handling the request.
+func (app *application) traceContext(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Only carry out the check if rate limiting is enabled
-		if app.config.limiter.enabled {
-			// Use the realip.FromRequest() function to get the client's real IP address.
-			ip := realip.FromRequest(r)
+		trace, ok := tracing.Parse(r.Header.Get(tracing.TraceParentHeader), r.Header.Get(tracing.TraceStateHeader))
+
+		var err error
+
+		if ok {
+			trace, err = trace.Child()
+		} else {
+			trace, err = tracing.New()
+		}
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, app.contextSetTrace(r, trace))
+	})
+}
+
+// The requestTimeout() middleware lets callers set a time budget for their request
+// with the X-Request-Timeout header, or the grpc-timeout header sent by gRPC gateways,
+// kept between the -request-timeout-min and -request-timeout-max settings. The deadline
+// is set on the request context, so the database queries and outgoing calls made while
+// handling the request are cancelled when it passes, and a 504 Gateway Timeout response
+// is sent instead.
+func (app *application) requestTimeout(next http.Handler) http.Handler {
+	if app.config.deadline.max <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, ok, err := readRequestTimeout(r.Header)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if timeout < app.config.deadline.min {
+			timeout = app.config.deadline.min
+		}
+
+		if timeout > app.config.deadline.max {
+			timeout = app.config.deadline.max
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-			// Lock the mutex to prevent this code from being executed concurrently.
-			mutex.Lock()
+// The units of the grpc-timeout header
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// Read the time budget set by the X-Request-Timeout header, which holds a duration such
+// as "250ms" or a number of seconds, or else by the grpc-timeout header, which holds up
+// to 8 digits followed by a unit. The boolean reports whether either header was sent.
+func readRequestTimeout(header http.Header) (time.Duration, bool, error) {
+	if value := header.Get("X-Request-Timeout"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			seconds, floatErr := strconv.ParseFloat(value, 64)
+			if floatErr != nil || math.IsNaN(seconds) {
+				return 0, false, errors.New("invalid X-Request-Timeout header: must be a duration such as 500ms or a number of seconds")
+			}
 
-			// Check to see if the IP address already exists in the map. If it doesn't, then
-			// initialize a new rate limiter and add the IP address and limiter to the map.
-			if _, found := clients[ip]; !found {
-				clients[ip] = &Client{limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst)}
+			// Budgets too long for a time.Duration are well over the cap anyway
+			if seconds >= math.MaxInt64/float64(time.Second) {
+				return time.Duration(math.MaxInt64), true, nil
 			}
 
-			// Update the last seen time for the client
-			clients[ip].lastSeen = time.Now()
+			timeout = time.Duration(seconds * float64(time.Second))
+		}
+
+		if timeout <= 0 {
+			return 0, false, errors.New("invalid X-Request-Timeout header: must be greater than zero")
+		}
+
+		return timeout, true, nil
+	}
+
+	if value := header.Get("Grpc-Timeout"); value != "" {
+		unit, ok := grpcTimeoutUnits[value[len(value)-1]]
+		digits := value[:len(value)-1]
+
+		n, err := strconv.ParseInt(digits, 10, 64)
+		if !ok || err != nil || len(digits) > 8 || n <= 0 || strings.TrimLeft(digits, "0123456789") != "" {
+			return 0, false, errors.New("invalid grpc-timeout header")
+		}
+
+		if n > int64(math.MaxInt64/unit) {
+			return time.Duration(math.MaxInt64), true, nil
+		}
+
+		return time.Duration(n) * unit, true, nil
+	}
+
+	return 0, false, nil
+}
+
+// Check that a client-provided request ID is at most 64 characters long and only
+// contains letters, digits, dashes, underscores and dots, so that it is safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
 
-			// Call the Allow() method on the rate limiter for the current IP address.
-			// if the request isn't allowed, unlock the mutex and send a 429 Too Many Requests response.
-			if !clients[ip].limiter.Allow() {
-				mutex.Unlock()
+	return true
+}
+
+// We will have a bucket that starts with "b" tokens in it.
+// Each time we receive a HTTP request, we will remove one token from the bucket.
+// Every 1/r seconds, a token is added back to the bucket — up to a maximum of "b" total tokens.
+// If we receive a HTTP request and the bucket is empty, then we should return a 429 Too Many Requests response.
+func (app *application) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only carry out the check if rate limiting is enabled. Clients are told apart
+		// by their real IP address, as the limiter runs before authentication. Guests
+		// are also limited on their guest session's own quota, on top of their IP
+		// address's, as anyone can get as many guest tokens as they like.
+		if app.config.limiter.enabled {
+			if !app.limiter.Allow(realip.FromRequest(r)) {
 				app.rateLimitExceededResponse(w, r)
 				return
 			}
 
-			// Very importantly, unlock the mutex before calling the next handler in the
-			// chain. Notice that we DON'T use defer to unlock the mutex, as that would mean
-			// that the mutex isn't unlocked until all the handlers downstream of this
-			// middleware have also returned.
-			mutex.Unlock()
+			if guestID, ok := app.requestGuestID(r); ok && !app.guests.Allow("guest:"+guestID) {
+				app.rateLimitExceededResponse(w, r)
+				return
+			}
 		}
 
 		next.ServeHTTP(w, r)
@@ -151,6 +273,45 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// Extract the actual authentication token from the header parts
 		token := headerParts[1]
 
+		// Service accounts send their key instead, and act as their owner restricted to
+		// the account's permissions
+		if data.IsServiceAccountKey(token) {
+			account, user, err := app.models.ServiceAccounts.GetForKey(r.Context(), token)
+			if err != nil {
+				switch {
+				case errors.Is(err, data.ErrRecordNotFound):
+					app.invalidAuthenticationTokenResponse(w, r)
+				default:
+					app.serverErrorResponse(w, r, err)
+				}
+				return
+			}
+
+			r = app.contextSetServiceAccount(app.contextSetUser(r, user), account)
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Guests send a guest token when guest sessions are enabled, and may only read
+		if app.guests != nil && isGuestToken(token) {
+			guestID, err := app.parseGuestToken(token)
+			if err != nil {
+				switch {
+				case errors.Is(err, errGuestTokenExpired):
+					app.expiredTokenResponse(w, r, data.ScopeAuthentication)
+				default:
+					app.invalidAuthenticationTokenResponse(w, r)
+				}
+				return
+			}
+
+			r = app.contextSetUser(r, data.NewGuestUser(guestID, guestPermissions))
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Validate the token to make sure it is in a sensible format
 		v := validator.New()
 
@@ -161,11 +322,13 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		// Retrieve the details of the user associated with the authentication token,
 		// sending back a 401 response if no matching record was found
-		user, err := app.models.User.GetForToken(data.ScopeAuthentication, token)
+		user, err := app.models.User.GetForToken(r.Context(), data.ScopeAuthentication, token)
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrRecordNotFound):
 				app.invalidAuthenticationTokenResponse(w, r)
+			case errors.Is(err, data.ErrTokenExpired):
+				app.expiredTokenResponse(w, r, data.ScopeAuthentication)
 			default:
 				app.serverErrorResponse(w, r, err)
 			}
@@ -175,6 +338,15 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// Call the contextSetUser() helper to add the user information to the request context
 		r = app.contextSetUser(r, user)
 
+		// Every request made by an admin acting as a user is logged, along with the admin
+		if user.IsImpersonated() {
+			properties := reqctx.FromContext(r.Context()).LogProperties()
+			properties["request_method"] = r.Method
+			properties["request_url"] = r.URL.String()
+
+			app.logger.PrintInfo("impersonated request", properties)
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -196,6 +368,28 @@ func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.Han
 	})
 }
 
+// Checks that the request is made by a user rather than by one of their service
+// accounts or an admin impersonating them, for endpoints managing the user's account
+// and credentials
+func (app *application) requireHumanUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.contextGetServiceAccount(r) != nil {
+			app.serviceAccountNotPermittedResponse(w, r)
+			return
+		}
+
+		// Admins acting as a user mustn't manage the user's credentials either
+		if app.contextGetUser(r).IsImpersonated() {
+			app.impersonationNotPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+
+	return app.requireAuthenticatedUser(fn)
+}
+
 // Checks that a user is both authenticated and activated
 func (app *application) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
 	// Rather than returning this http.HandlerFunc we assign it to the variable fn
@@ -224,27 +418,68 @@ func (app *application) requirePermission(code string, next http.HandlerFunc) ht
 		// Retrieve the user from the request context
 		user := app.contextGetUser(r)
 
-		// Get permissions for the user
-		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		// Check if the user holds the required permission. If they don't, then return a
+		// 403 Forbidden response.
+		held, err := app.models.Permissions.HasPermission(r.Context(), user.ID, code)
 		if err != nil {
 			app.serverErrorResponse(w, r, err)
 			return
 		}
 
-		// Check if the "permissions" slice includes the required permission. If it doesn't, then
-		// return a 403 Forbidden response.
-		if !permissions.Include(code) {
+		if !held {
 			app.notPermittedResponse(w, r)
 			return
 		}
 
+		// The token used for the request may be restricted to other permissions
+		if !user.TokenAllows(code) {
+			app.tokenNotPermittedResponse(w, r)
+			return
+		}
+
 		// Otherwise they have the required permission so we call the next handler in
 		// the chain.
 		next.ServeHTTP(w, r)
 	}
 
-	// Wrap this with the requireActivatedUser() middleware before returning it
-	return app.requireActivatedUser(fn)
+	// Wrap this with the requireActivatedUser() middleware, which turns guests away, so
+	// guests are let through here when they may read what the route serves
+	protected := app.requireActivatedUser(fn)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if user.IsGuest() {
+			if (r.Method != http.MethodGet && r.Method != http.MethodHead) || !user.TokenAllows(code) {
+				app.authenticationRequiredResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		protected(w, r)
+	}
+}
+
+// The userHasPermissions() helper reports whether the user making the request holds
+// all of the given permission codes and the token used for the request allows them, for
+// handlers which change what they do depending on the user's permissions. Anonymous
+// users hold no permissions.
+func (app *application) userHasPermissions(r *http.Request, codes ...string) (bool, error) {
+	user := app.contextGetUser(r)
+	if user.IsAnonymous() {
+		return false, nil
+	}
+
+	for _, code := range codes {
+		if !user.TokenAllows(code) {
+			return false, nil
+		}
+	}
+
+	return app.models.Permissions.HasPermission(r.Context(), user.ID, codes...)
 }
 
 func (app *application) enableCORS(next http.Handler) http.Handler {
@@ -265,19 +500,16 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 				if origin == app.config.cors.trustedOrigins[i] {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
 
+					// Let browser clients read the consistency token, so that they
+					// can send it back with their next reads
+					w.Header().Set("Access-Control-Expose-Headers", "X-Consistency-Token")
+
 					// Check if the request has the HTTP method OPTIONS and contains the
 					// "Access-Control-Request-Method" header. If it does, then we treat
-					// it as a preflight request.
+					// it as a preflight request. The allowed methods depend on the path,
+					// so they are added by the router's OPTIONS handler.
 					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
-						// Set the necessary preflight response headers
-						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
-
-						// Write the headers along with a 200 OK status and return from
-						// the middleware with no further action
-						w.WriteHeader(http.StatusOK)
-
-						return
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Traceparent, Tracestate, X-Consistency-Token, X-Request-Timeout")
 					}
 
 					break
@@ -289,13 +521,111 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 	})
 }
 
-func (app *application) metrics(next http.Handler) http.Handler {
-	// Initialize the new expvar variables when the middleware chain is first built
-	totalRequestsReceived := expvar.NewInt("total_requests_received")
-	totalResponsesReceived := expvar.NewInt("total_responses_sent")
-	totalProcessingTimeMicroseconds := expvar.NewInt("total_processing_time_μs")
-	totalResponsesSentByStatus := expvar.NewMap("total_responses_sent_by_status")
+// Publish the gauges and counter updated by the concurrency limiter
+var (
+	requestsInFlight        = expvar.NewInt("requests_in_flight")
+	requestsQueued          = expvar.NewInt("requests_queued")
+	totalRequestsOverloaded = expvar.NewInt("total_requests_overloaded")
+)
+
+// The limitConcurrency() middleware caps the number of requests being handled at once.
+// When the cap is reached, requests wait in a small queue for a slot to free up, and
+// are sent a 503 Service Unavailable response if the queue is full or they wait for
+// too long. This keeps the number of goroutines (and database connections waited on)
+// bounded when the database slows down under a spike in load.
+func (app *application) limitConcurrency(next http.Handler) http.Handler {
+	if app.config.concurrency.maxInFlight <= 0 {
+		return next
+	}
+
+	// Each request in flight holds a slot in the buffered channel
+	slots := make(chan struct{}, app.config.concurrency.maxInFlight)
+
+	// The number of requests waiting for a slot
+	var waiting int64
 
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+		default:
+			// Join the queue if there's room in it
+			if atomic.AddInt64(&waiting, 1) > int64(app.config.concurrency.queueSize) {
+				atomic.AddInt64(&waiting, -1)
+				totalRequestsOverloaded.Add(1)
+				app.overloadedResponse(w, r)
+				return
+			}
+
+			requestsQueued.Add(1)
+
+			timer := time.NewTimer(app.config.concurrency.queueWait)
+
+			select {
+			case slots <- struct{}{}:
+				timer.Stop()
+			case <-timer.C:
+				atomic.AddInt64(&waiting, -1)
+				requestsQueued.Add(-1)
+				totalRequestsOverloaded.Add(1)
+				app.overloadedResponse(w, r)
+				return
+			case <-r.Context().Done():
+				timer.Stop()
+				atomic.AddInt64(&waiting, -1)
+				requestsQueued.Add(-1)
+
+				// Either the request's time budget ran out while it was queued, or the
+				// client has gone away
+				if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+					app.deadlineExceededResponse(w, r)
+				} else {
+					app.clientClosedResponse(w, r)
+				}
+
+				return
+			}
+
+			atomic.AddInt64(&waiting, -1)
+			requestsQueued.Add(-1)
+		}
+
+		requestsInFlight.Add(1)
+
+		defer func() {
+			requestsInFlight.Add(-1)
+			<-slots
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Publish the request counters updated by the metrics middleware. They are package
+// level variables so that they can be sampled and reset by the admin metrics endpoints.
+var (
+	totalRequestsReceived           = expvar.NewInt("total_requests_received")
+	totalResponsesSent              = expvar.NewInt("total_responses_sent")
+	totalProcessingTimeMicroseconds = expvar.NewInt("total_processing_time_μs")
+	totalResponsesSentByStatus      = expvar.NewMap("total_responses_sent_by_status")
+
+	// The request durations by method and route pattern. Using the patterns rather than
+	// the raw paths keeps the number of series bounded.
+	requestDurations = metrics.NewHistogramVec("http_request_duration_seconds", metrics.DefaultBuckets, "method", "route")
+)
+
+// The methods used as labels of the request durations. Any other method is recorded as
+// "OTHER", as clients can send arbitrary ones.
+var metricsMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+func (app *application) metrics(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Increment the number of requests received by 1
 		totalRequestsReceived.Add(1)
@@ -304,7 +634,7 @@ func (app *application) metrics(next http.Handler) http.Handler {
 		metrics := httpsnoop.CaptureMetrics(next, w, r)
 
 		// On the way back up the middleware chain, increment the number of responses sent by 1
-		totalResponsesReceived.Add(1)
+		totalResponsesSent.Add(1)
 
 		// Get the request processing time in microseconds from httpsnoop and increment
 		// the cumulative processing time
@@ -314,5 +644,40 @@ func (app *application) metrics(next http.Handler) http.Handler {
 		// Note that the expvar map is string-keyed, so we need to use the strconv.Itoa()
 		// function to convert the status code (which is an integer) to a string.
 		totalResponsesSentByStatus.Add(strconv.Itoa(metrics.Code), 1)
+
+		// Record the duration against the route pattern, grouping requests which didn't
+		// match a route (such as 404s and OPTIONS requests) together
+		method := r.Method
+		if !metricsMethods[method] {
+			method = "OTHER"
+		}
+
+		// The registry entry of the route is recorded in the request context bag if the
+		// router matched a route
+		pattern := "unmatched"
+		if route := app.contextGetRoute(r); route != nil {
+			pattern = route.Path
+		}
+
+		requestDurations.Observe(metrics.Duration.Seconds(), method, pattern)
+	})
+}
+
+// Add the Alt-Svc header to responses sent over TLS, advertising the HTTP/3 listener.
+// The ma (max age) parameter lets clients remember the alternative for a day.
+func (app *application) advertiseHTTP3(next http.Handler) http.Handler {
+	_, port, err := net.SplitHostPort(app.config.http3.addr)
+	if err != nil {
+		port = strconv.Itoa(app.config.port)
+	}
+
+	altSvc := fmt.Sprintf(`h3=":%s"; ma=86400`, port)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			w.Header().Set("Alt-Svc", altSvc)
+		}
+
+		next.ServeHTTP(w, r)
 	})
 }
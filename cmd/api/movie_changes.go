@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Handler for the "POST /v1/movies/:id/pending-changes" endpoint, which lets contributors
+// propose a change to a movie. The body has the same fields as "PATCH /v1/movies/:id",
+// and the change is only applied once an editor approves it.
+func (app *application) createMovieChangeHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	movie, err := app.models.Movie.Get(r.Context(), movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	visible, err := app.movieVisible(r, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !visible {
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	var input data.MoviePatch
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// The change is made against the version of the movie the contributor saw, so that
+	// approving it can't overwrite a later edit
+	change := &data.MovieChange{
+		MovieID:     movie.ID,
+		UserID:      app.contextGetUser(r).ID,
+		BaseVersion: movie.Version,
+		Changes:     input,
+	}
+
+	v := app.newValidator(r)
+	defer app.recordShadowFailures(r, v)
+
+	if data.ValidateMovieChange(v, change, movie); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.MovieChanges.Insert(r.Context(), change)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d/pending-changes/%d", movie.ID, change.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"change": change}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/movies/:id/pending-changes" endpoint, which lists the changes
+// proposed for a movie for editors to review. Pending changes are listed by default.
+func (app *application) listMovieChangesHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, ok := app.readExistingMovieID(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	queryString := r.URL.Query()
+
+	input.Status = app.readString(queryString, "status", data.MovieChangePending)
+	input.Filters = app.readListingFilters(queryString, movieChangeListing, v)
+
+	v.Check(validator.In(input.Status, data.MovieChangePending, data.MovieChangeApproved, data.MovieChangeRejected), "status", "must be pending, approved or rejected", validator.CodeNotOneOf, validator.Params{"allowed": []string{data.MovieChangePending, data.MovieChangeApproved, data.MovieChangeRejected}})
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	changes, metadata, err := app.models.MovieChanges.GetAllForMovie(r.Context(), movieID, input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePage(w, r, "changes", changes, metadata)
+}
+
+// Handler for the "PUT /v1/movies/:id/pending-changes/:change_id/approve" endpoint, which
+// applies a proposed change to the movie through the same optimistic locking as
+// "PATCH /v1/movies/:id". A change proposed against an older version of the movie is
+// an edit conflict, as applying it could undo the edits made since; the editor can
+// reject it and the contributor propose it again.
+func (app *application) approveMovieChangeHandler(w http.ResponseWriter, r *http.Request) {
+	change, ok := app.readPendingMovieChange(w, r, data.MovieChangeApproved)
+	if !ok {
+		return
+	}
+
+	movie, err := app.models.Movie.Get(r.Context(), change.MovieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	editable, err := app.canEditMovie(r, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !editable {
+		app.movieNotOwnedResponse(w, r)
+		return
+	}
+
+	if movie.Version != change.BaseVersion {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	change.Changes.Apply(movie)
+
+	v := app.newValidator(r)
+	defer app.recordShadowFailures(r, v)
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// Apply the change before marking it as approved. Two editors approving the change at
+	// once both update the same version of the movie, so the second gets an edit
+	// conflict rather than applying it twice.
+	err = app.saveWithEvent(r, func(ctx context.Context) (domainEvent, error) {
+		err := app.models.Movie.Update(ctx, movie)
+
+		return domainEvent{Type: eventMovieUpdated, ID: movie.ID, Data: movie}, err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	change.Status = data.MovieChangeApproved
+	change.ReviewedBy = app.contextGetUser(r).ID
+
+	// The movie has already been updated, so a failure to record the decision is only
+	// logged. The change stays pending, but is now behind the movie's version and can't
+	// be approved again.
+	err = app.models.MovieChanges.Review(r.Context(), change)
+	if err != nil {
+		app.logError(r, err)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"change": change, "movie": app.movieResponse(w, r, movie)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "PUT /v1/movies/:id/pending-changes/:change_id/reject" endpoint. The
+// reason is optional and is kept with the change.
+func (app *application) rejectMovieChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	// The body can be left out altogether when no reason is given
+	if r.ContentLength != 0 {
+		err := app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	v := validator.New()
+
+	v.Check(len(input.Reason) <= 500, "reason", "must not be more than 500 bytes long", validator.CodeTooLong, validator.Params{"max": 500})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	change, ok := app.readPendingMovieChange(w, r, data.MovieChangeRejected)
+	if !ok {
+		return
+	}
+
+	if !app.requireMovieEditable(w, r, change.MovieID) {
+		return
+	}
+
+	change.Status = data.MovieChangeRejected
+	change.ReviewReason = input.Reason
+	change.ReviewedBy = app.contextGetUser(r).ID
+
+	err := app.models.MovieChanges.Review(r.Context(), change)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"change": change}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Read the change in the URL and check that it is still pending, sending the error
+// response if it isn't. Changes can only be reviewed once.
+func (app *application) readPendingMovieChange(w http.ResponseWriter, r *http.Request, status string) (*data.MovieChange, bool) {
+	movieID, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		return nil, false
+	}
+
+	id, err := app.readNamedIDParam(r, "change_id")
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeMovieChangeNotFound)
+		return nil, false
+	}
+
+	change, err := app.models.MovieChanges.Get(r.Context(), movieID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeMovieChangeNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return nil, false
+	}
+
+	if change.Status != data.MovieChangePending {
+		app.invalidTransitionResponse(w, r, "change", change.Status, status)
+		return nil, false
+	}
+
+	return change, true
+}
@@ -1,26 +1,68 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/LuisBarroso37/Greenlight/internal/data"
 	"github.com/LuisBarroso37/Greenlight/internal/validator"
 )
 
+// Define the fields which can be given when creating a movie. The field names and
+// types are a subset of the Movie struct.
+type movieInput struct {
+	Title               string              `json:"title"`
+	Year                int32               `json:"year"`
+	Runtime             data.Runtime        `json:"runtime"`
+	Genres              []string            `json:"genres"`
+	Synopsis            string              `json:"synopsis"`
+	OriginalLanguage    string              `json:"original_language"`
+	ProductionCountries []string            `json:"production_countries"`
+	Certifications      data.Certifications `json:"certifications"`
+	Metadata            data.MovieMetadata  `json:"metadata"`
+	Status              string              `json:"status"`
+	PublishAt           *time.Time          `json:"publish_at"`
+}
+
+// Return a new Movie struct with the input's values. Movies are published straight away
+// unless another status is given.
+func (input movieInput) movie() *data.Movie {
+	status := input.Status
+	if status == "" {
+		status = data.MoviePublished
+	}
+
+	return &data.Movie{
+		Title:               input.Title,
+		Year:                input.Year,
+		Runtime:             input.Runtime,
+		Genres:              input.Genres,
+		Synopsis:            input.Synopsis,
+		OriginalLanguage:    input.OriginalLanguage,
+		ProductionCountries: input.ProductionCountries,
+		Certifications:      input.Certifications,
+		Metadata:            input.Metadata,
+		Status:              status,
+		PublishAt:           input.PublishAt,
+	}
+}
+
 // Handler for the "POST /v1/movies" endpoint
 func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
-	// Declare an anonymous struct to hold the information that we expect to be in the
-	// request body. This struct will be our *target decode destination*
-	// (The field names and types in the struct are a subset of the Movie struct)
-	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
+	// Bulk uploads are sent as newline-delimited JSON, with one movie per line
+	if hasContentType(r, "application/x-ndjson") {
+		app.createMoviesHandler(w, r)
+		return
 	}
 
+	// Declare a struct to hold the information that we expect to be in the request
+	// body. This struct will be our *target decode destination*
+	var input movieInput
+
 	// Read request body and decode it into the input struct
 	err := app.readJSON(w, r, &input)
 	if err != nil {
@@ -28,25 +70,27 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Copy the values from the input struct to a new Movie struct
-	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
-	}
+	// Copy the values from the input struct to a new Movie struct, recording who created it
+	movie := input.movie()
+	movie.CreatedBy = app.contextGetUser(r).ID
 
-	// Initialize a new Validator instance
-	v := validator.New()
+	// Initialize a new Validator instance, recording the failures of the rules which are
+	// being rolled out
+	v := app.newValidator(r)
+	defer app.recordShadowFailures(r, v)
 
 	// Perform validation checks
 	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	// Create a movie record in the database and update the movie struct with the system-generated information
-	err = app.models.Movie.Insert(movie)
+	err = app.saveWithEvent(r, func(ctx context.Context) (domainEvent, error) {
+		err := app.models.Movie.Insert(ctx, movie)
+
+		return domainEvent{Type: eventMovieCreated, ID: movie.ID, Data: movie}, err
+	})
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -59,7 +103,115 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 
 	// Write a JSON response with a 201 Created status code, the movie data in the
 	// response body, and the Location header
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": app.movieResponse(w, r, movie)}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Struct used for reporting a line of a bulk upload which couldn't be imported. The
+// error is either a message or, for invalid movies, a map of validation errors, and is
+// sent with its error code. Invalid movies have the code and parameters of each failed
+// check in Fields.
+type importFailure struct {
+	Line   int                             `json:"line"`
+	Code   string                          `json:"code"`
+	Error  interface{}                     `json:"error"`
+	Fields map[string]validator.FieldError `json:"fields,omitempty"`
+}
+
+// The number of failed lines listed in a bulk upload's response. Any further failures
+// are only counted.
+const maxImportFailures = 100
+
+// Handler for the "POST /v1/movies" endpoint with an application/x-ndjson body. Each
+// line is created as a separate movie, and lines which are malformed or invalid are
+// reported without stopping the upload. Movies are inserted as they are read, so an
+// error which stops the upload leaves the movies before it in place.
+func (app *application) createMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	imported, failed := 0, 0
+	failures := []importFailure{}
+
+	fail := func(line int, code string, err interface{}, fields map[string]validator.FieldError) {
+		failed++
+
+		if len(failures) < maxImportFailures {
+			failures = append(failures, importFailure{Line: line, Code: code, Error: err, Fields: fields})
+		}
+	}
+
+	// Errors inserting a movie are kept apart from errors reading the body, as they are
+	// ours rather than the client's
+	var insertErr error
+
+	err := app.readNDJSON(w, r, func(line int, decode func(target interface{}) error) error {
+		var input movieInput
+
+		err := decode(&input)
+		if err != nil {
+			code := codeBadRequest
+
+			var unknownField *unknownFieldError
+			if errors.As(err, &unknownField) {
+				code = codeUnknownField
+			}
+
+			fail(line, code, err.Error(), nil)
+			return nil
+		}
+
+		movie := input.movie()
+		movie.CreatedBy = app.contextGetUser(r).ID
+
+		v := app.newValidator(r)
+
+		data.ValidateMovie(v, movie)
+		app.recordShadowFailures(r, v)
+
+		if !v.Valid() {
+			fail(line, codeValidationFailed, v.Errors, v.Fields)
+			return nil
+		}
+
+		insertErr = app.saveWithEvent(r, func(ctx context.Context) (domainEvent, error) {
+			err := app.models.Movie.Insert(ctx, movie)
+
+			return domainEvent{Type: eventMovieCreated, ID: movie.ID, Data: movie}, err
+		})
+		if insertErr != nil {
+			return insertErr
+		}
+
+		imported++
+
+		return nil
+	})
+
+	if insertErr != nil {
+		app.serverErrorResponse(w, r, insertErr)
+		return
+	}
+
+	env := envelope{"imported": imported, "failed": failed, "failures": failures}
+
+	// An error reading the body stops the upload, and is sent along with what was
+	// imported before it
+	status := http.StatusOK
+
+	var tooLarge *bodyTooLargeError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		status = http.StatusRequestEntityTooLarge
+		env["error"] = map[string]interface{}{"message": tooLarge.Error(), "max_bytes": tooLarge.maxBytes}
+		env["code"] = codeBodyTooLarge
+	case err != nil:
+		status = http.StatusBadRequest
+		env["error"] = err.Error()
+		env["code"] = codeBadRequest
+	}
+
+	err = app.writeJSON(w, status, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -70,16 +222,25 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 	// Extract id parameter from request URL parameters
 	id, err := app.readIDParam(r)
 	if err != nil {
-		app.notFoundResponse(w, r)
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	v := validator.New()
+
+	include := app.readInclude(r.URL.Query(), v, "collection")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	// Fetch movie by given id
-	movie, err := app.models.Movie.Get(id)
+	movie, err := app.models.Movie.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.resourceNotFoundResponse(w, r, codeMovieNotFound)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -87,8 +248,45 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Unpublished movies are only shown to editors, and don't exist for anyone else
+	visible, err := app.movieVisible(r, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !visible {
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	// Count the view for the trending list
+	app.hits.Record(movie.ID)
+
+	if include["collection"] {
+		err = app.includeCollections(r, []*data.Movie{movie})
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	env := envelope{"movie": app.movieResponse(w, r, movie)}
+
+	// The view count and included collection can change without the movie's version
+	// changing, so the ETag is calculated from the whole response
+	etag, err := jsonETag(env)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag)
+	app.setSurrogateKeys(r, headers, movieSurrogateKey(movie.ID))
+
 	// Write the fetched movie record in a JSON response
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.writeJSON(w, http.StatusOK, env, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -99,16 +297,16 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	// Extract id parameter from request URL parameters
 	id, err := app.readIDParam(r)
 	if err != nil {
-		app.notFoundResponse(w, r)
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
 		return
 	}
 
 	// Fetch movie by given id
-	movie, err := app.models.Movie.Get(id)
+	movie, err := app.models.Movie.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.resourceNotFoundResponse(w, r, codeMovieNotFound)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -116,15 +314,19 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// We use pointers so that we get a nil value when decoding these values from JSON.
-	// This way we can check if a user has provided the key/value pair in the JSON or not.
-	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
+	editable, err := app.canEditMovie(r, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
+	if !editable {
+		app.movieNotOwnedResponse(w, r)
+		return
+	}
+
+	var input data.MoviePatch
+
 	// Read request body and decode it into the input struct
 	err = app.readJSON(w, r, &input)
 	if err != nil {
@@ -133,33 +335,25 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Copy the values from the input struct to the fetched movie if they exist
-	if input.Year != nil {
-		movie.Year = *input.Year
-	}
-
-	if input.Title != nil {
-		movie.Title = *input.Title
-	}
-
-	if input.Runtime != nil {
-		movie.Runtime = *input.Runtime
-	}
-
-	if input.Genres != nil {
-		movie.Genres = input.Genres
-	}
+	input.Apply(movie)
 
-	// Initialize a new Validator instance
-	v := validator.New()
+	// Initialize a new Validator instance, recording the failures of the rules which are
+	// being rolled out
+	v := app.newValidator(r)
+	defer app.recordShadowFailures(r, v)
 
 	// Perform validation checks
 	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	// Update movie
-	err = app.models.Movie.Update(movie)
+	err = app.saveWithEvent(r, func(ctx context.Context) (domainEvent, error) {
+		err := app.models.Movie.Update(ctx, movie)
+
+		return domainEvent{Type: eventMovieUpdated, ID: movie.ID, Data: movie}, err
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -172,7 +366,7 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Write the updated movie record in a JSON response
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": app.movieResponse(w, r, movie)}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -183,16 +377,24 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 	// Extract id parameter from request URL parameters
 	id, err := app.readIDParam(r)
 	if err != nil {
-		app.notFoundResponse(w, r)
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	if !app.requireMovieEditable(w, r, id) {
 		return
 	}
 
 	// Delete movie with given id
-	err = app.models.Movie.Delete(id)
+	err = app.saveWithEvent(r, func(ctx context.Context) (domainEvent, error) {
+		err := app.models.Movie.Delete(ctx, id)
+
+		return domainEvent{Type: eventMovieDeleted, ID: id}, err
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.resourceNotFoundResponse(w, r, codeMovieNotFound)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -210,8 +412,7 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 // Handler for the "GET /v1/movies" endpoint
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieSearch
 		data.Filters
 	}
 
@@ -223,33 +424,105 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 
 	// Parse query string values and store them in `input` struct
 	input.Title = app.readString(queryString, "title", "")
+	input.Synopsis = app.readString(queryString, "synopsis", "")
 	input.Genres = app.readCSV(queryString, "genres", []string{})
-	input.Page = app.readInt(queryString, "page", 1, v)
-	input.PageSize = app.readInt(queryString, "page_size", 20, v)
+	input.Language = app.readString(queryString, "language", "")
+	input.Countries = app.readCSV(queryString, "countries", []string{})
+	input.Certifications = app.readCertifications(queryString, "certification", v)
+	input.Metadata = app.readMetadataFilters(queryString, "metadata")
+
+	// Movies can only be filtered on their creator by the creator themselves
+	createdBy := app.readString(queryString, "created_by", "")
+	v.Check(createdBy == "" || createdBy == "me", "created_by", "must be me", validator.CodeNotOneOf, validator.Params{"allowed": []string{"me"}})
+	input.ReleasedBefore = app.readDate(queryString, "released_before", v)
+	input.ReleasedAfter = app.readDate(queryString, "released_after", v)
+	input.CreatedBefore = app.readTime(queryString, "created_before", v)
+	input.CreatedAfter = app.readTime(queryString, "created_after", v)
 
-	// Extract the sort query string value, falling back to "id" if it is not provided
-	// by the client (which will imply a ascending sort on movie ID)
-	input.Sort = app.readString(queryString, "sort", "id")
+	// Read the pagination and sort values, falling back to an ascending sort on movie ID
+	// if no sort is provided by the client
+	input.Filters = app.readListingFilters(queryString, movieListing, v)
 
-	// Add the supported sort values for this endpoint to the sort safelist
-	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	include := app.readInclude(queryString, v, movieListing.Include...)
 
 	// Check the Validator instance for any errors and use the failedValidationResponse()
 	// helper to send the client a response if necessary
+	data.ValidateMovieSearch(v, input.MovieSearch)
+
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// Anonymous users can list movies when the catalog is public, but haven't created any
+	if createdBy == "me" {
+		user := app.contextGetUser(r)
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+
+		input.CreatedBy = user.ID
+	}
+
+	// Draft and scheduled movies are only listed for editors
+	editor, err := app.canSeeUnpublished(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	input.PublishedOnly = !editor
+
 	// Fetch all movies that
-	movies, metadata, err := app.models.Movie.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.models.Movie.GetAll(r.Context(), input.MovieSearch, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if include["collection"] {
+		err = app.includeCollections(r, movies)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// Count the movies matching the search by genre, decade, certification and so on,
+	// so that clients can show how many movies each further filter would leave
+	if include["facets"] {
+		metadata.Facets, err = app.models.Movie.Facets(r.Context(), input.MovieSearch)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	env := envelope{"movies": app.movieListResponse(w, r, movies), "metadata": metadata}
+
+	etag, err := jsonETag(env)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	// Send the total number of matching records in a header too, so that clients can
+	// get it from a HEAD request
+	headers := make(http.Header)
+	headers.Set("ETag", etag)
+	headers.Set("X-Total-Count", strconv.Itoa(metadata.TotalRecords))
+
+	// Link to the neighbouring pages, so that generic HTTP clients can paginate without
+	// reading the metadata from the body
+	if links := paginationLinks(r.URL, metadata); links != "" {
+		headers.Set("Link", links)
+	}
+
+	app.setSurrogateKeys(r, headers, movieListSurrogateKey)
+
 	// Write the list of movies in a JSON response
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	err = app.writeJSON(w, http.StatusOK, env, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Define a notificationJob struct holding the payload of a job which delivers a
+// notification to a user through the channels they have enabled for its kind
+type notificationJob struct {
+	UserID int64                  `json:"user_id"`
+	Email  string                 `json:"email"`
+	Event  string                 `json:"event"`
+	Title  string                 `json:"title"`
+	Body   string                 `json:"body"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+func (notificationJob) Kind() string {
+	return "notification"
+}
+
+// The notify() helper queues a notification for a user. It is delivered by a job
+// worker, which looks up the user's preferences at delivery time.
+func (app *application) notify(ctx context.Context, user *data.User, kind, title, body string, details map[string]interface{}) error {
+	job := notificationJob{
+		UserID: user.ID,
+		Email:  user.Email,
+		Event:  kind,
+		Title:  title,
+		Body:   body,
+		Data:   details,
+	}
+
+	return app.jobs.Enqueue(ctx, job, jobs.EnqueueOptions{})
+}
+
+// Deliver a notification to the in-app inbox and by email, depending on the user's
+// preferences. If sending the email fails, the retried job stores the in-app
+// notification again, as we would rather show a duplicate than lose it.
+func (app *application) deliverNotification(ctx context.Context, job notificationJob) error {
+	preferences, err := app.models.Notifications.GetPreferences(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+
+	channels := preferences[job.Event]
+
+	if channels.InApp {
+		err = app.models.Notifications.Insert(ctx, &data.Notification{
+			UserID: job.UserID,
+			Kind:   job.Event,
+			Title:  job.Title,
+			Body:   job.Body,
+			Data:   job.Data,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if channels.Email {
+		err = app.mailer.Send(job.Email, "notification.tmpl", map[string]interface{}{
+			"title": job.Title,
+			"body":  job.Body,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handler for the "GET /v1/users/me/notifications" endpoint
+func (app *application) listNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Unread string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	queryString := r.URL.Query()
+
+	input.Unread = app.readString(queryString, "unread", "false")
+	input.Filters = app.readListingFilters(queryString, notificationListing, v)
+
+	v.Check(validator.In(input.Unread, "true", "false"), "unread", "must be true or false", validator.CodeNotOneOf, validator.Params{"allowed": []string{"true", "false"}})
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	notifications, metadata, err := app.models.Notifications.GetAllForUser(r.Context(), user.ID, input.Unread == "true", input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePage(w, r, "notifications", notifications, metadata)
+}
+
+// Handler for the "PUT /v1/users/me/notifications/:id/read" endpoint
+func (app *application) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeNotificationNotFound)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	// Notifications belonging to other users are reported as not found
+	err = app.models.Notifications.MarkRead(r.Context(), user.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeNotificationNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "notification marked as read"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/users/me/notification-preferences" endpoint
+func (app *application) showNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	preferences, err := app.models.Notifications.GetPreferences(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"preferences": preferences}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "PUT /v1/users/me/notification-preferences" endpoint. Only the kinds
+// of notification included in the request are changed.
+func (app *application) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	var input data.NotificationPreferences
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateNotificationPreferences(v, input); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Notifications.SetPreferences(r.Context(), user.ID, input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	preferences, err := app.models.Notifications.GetPreferences(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"preferences": preferences}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
+	"github.com/LuisBarroso37/Greenlight/internal/schema"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Define a schemaOperation struct for an online schema change which can be run from
+// the operations API. Changes which would block writes to a large table for as long
+// as they take, such as building an index or filling in a new column, are left out of
+// the migrations and made here instead: the migration only adds the column (which is
+// instant when its default isn't volatile), and the operation builds the index
+// concurrently or backfills the column in batches. Each operation has either an index
+// or a backfill.
+type schemaOperation struct {
+	name     string
+	index    *schema.Index
+	backfill *schema.Backfill
+}
+
+// The online schema changes which can be run. Operations are safe to run again, so
+// they are never removed once published.
+var schemaOperations = []schemaOperation{
+	{
+		// Movies which have never been updated were last updated when they were created
+		name: "movies_updated_at_backfill",
+		backfill: &schema.Backfill{
+			Table:     "movies",
+			Set:       "updated_at = created_at",
+			Where:     "version = 1 AND updated_at <> created_at",
+			BatchSize: 1000,
+		},
+	},
+	{
+		// The sitemap lists the movies with the time they were last updated
+		name: "movies_updated_at_idx",
+		index: &schema.Index{
+			Name:       "movies_updated_at_idx",
+			Table:      "movies",
+			Definition: "(updated_at)",
+		},
+	},
+}
+
+// Return the names of the schema operations
+func schemaOperationNames() []string {
+	names := make([]string, len(schemaOperations))
+
+	for i, op := range schemaOperations {
+		names[i] = op.name
+	}
+
+	return names
+}
+
+// Return the schema operation with the given name, reporting whether there is one
+func findSchemaOperation(name string) (schemaOperation, bool) {
+	for _, op := range schemaOperations {
+		if op.name == name {
+			return op, true
+		}
+	}
+
+	return schemaOperation{}, false
+}
+
+// Define a schemaOperationJob struct for the job which works on a queued or running
+// schema operation. Backfills run for at most -schema-batch-budget in each job, then
+// queue another job to carry on from where they stopped, so that a backfill of a large
+// table doesn't run into the job timeout.
+type schemaOperationJob struct {
+	OperationID int64 `json:"operation_id"`
+}
+
+func (schemaOperationJob) Kind() string {
+	return "schema_operation"
+}
+
+// Work on a schema operation from a job. A failed operation is recorded with its
+// error and isn't retried, as the lock timeout is usually the reason for the failure;
+// an admin can start the operation again once the table is quieter, and a backfill
+// picks up the rows it didn't get to.
+func (app *application) runSchemaOperationJob(ctx context.Context, job schemaOperationJob) error {
+	operation, err := app.models.Operations.Get(ctx, job.OperationID)
+	if err != nil {
+		return err
+	}
+
+	if operation.Status != data.OperationQueued && operation.Status != data.OperationRunning {
+		return nil
+	}
+
+	done, err := app.runSchemaOperation(ctx, operation, app.config.schema.budget)
+	if err != nil || done {
+		return err
+	}
+
+	return app.jobs.Enqueue(ctx, job, jobs.EnqueueOptions{MaxAttempts: 1})
+}
+
+// Work on a schema operation until it is done or the budget has run out, recording its
+// progress after each batch of a backfill. A budget of 0 works on it until it is done.
+// It reports whether the operation is done, in which case its outcome is recorded.
+func (app *application) runSchemaOperation(ctx context.Context, operation *data.Operation, budget time.Duration) (bool, error) {
+	op, ok := findSchemaOperation(operation.Name)
+	if !ok {
+		return true, app.finishSchemaOperation(operation, fmt.Errorf("unknown schema operation %q", operation.Name))
+	}
+
+	// Record that the operation has started before working on it, as building an index
+	// can take a while
+	err := app.models.Operations.UpdateProgress(ctx, operation)
+	if err != nil {
+		return false, err
+	}
+
+	if op.index != nil {
+		err = app.schema.CreateIndex(ctx, *op.index)
+		return true, app.finishSchemaOperation(operation, err)
+	}
+
+	// The backfill ends at the table's largest ID when it started, as rows inserted
+	// since are written with the column already set
+	if operation.Total == 0 {
+		operation.Total, err = app.schema.MaxID(ctx, *op.backfill)
+		if err != nil {
+			return true, app.finishSchemaOperation(operation, err)
+		}
+	}
+
+	started := time.Now()
+
+	for operation.Progress < operation.Total {
+		if budget > 0 && time.Since(started) >= budget {
+			return false, nil
+		}
+
+		updated, last, err := app.schema.Batch(ctx, *op.backfill, operation.Progress)
+		if err != nil {
+			return true, app.finishSchemaOperation(operation, err)
+		}
+
+		operation.Progress = last
+		if operation.Progress > operation.Total {
+			operation.Progress = operation.Total
+		}
+
+		operation.RowsUpdated += updated
+
+		err = app.models.Operations.UpdateProgress(ctx, operation)
+		if err != nil {
+			return false, err
+		}
+
+		// Pause between the batches, so that replicas can keep up and other writes to
+		// the table get a look in
+		select {
+		case <-time.After(app.config.schema.batchPause):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+
+	return true, app.finishSchemaOperation(operation, nil)
+}
+
+// Record the outcome of a schema operation, returning the error it failed with. The
+// outcome is recorded even if the job's context has run out, which is the usual reason
+// for a long operation to fail.
+func (app *application) finishSchemaOperation(operation *data.Operation, opErr error) error {
+	if opErr != nil {
+		operation.Error = opErr.Error()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := app.models.Operations.Finish(ctx, operation)
+	if err != nil {
+		return err
+	}
+
+	if opErr != nil {
+		return opErr
+	}
+
+	app.logger.PrintInfo("schema operation completed", map[string]string{
+		"name":         operation.Name,
+		"rows_updated": fmt.Sprint(operation.RowsUpdated),
+	})
+
+	return nil
+}
+
+// Run a schema operation to completion from the command line, without the job
+// timeout, which an index build on a very large table could run into
+func (app *application) runSchemaOperationCommand(ctx context.Context, name string) error {
+	if _, ok := findSchemaOperation(name); !ok {
+		return fmt.Errorf("unknown schema operation %q, expected one of %v", name, schemaOperationNames())
+	}
+
+	operation := &data.Operation{Name: name}
+
+	err := app.models.Operations.Insert(ctx, operation)
+	if err != nil {
+		return err
+	}
+
+	_, err = app.runSchemaOperation(ctx, operation, 0)
+
+	return err
+}
+
+// Handler for the "POST /v1/admin/operations" endpoint, which queues a schema
+// operation. An operation which is already queued or running can't be started again
+// until it has finished.
+func (app *application) createOperationHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	names := schemaOperationNames()
+
+	v.Check(input.Name != "", "name", "must be provided", validator.CodeRequired)
+	v.Check(input.Name == "" || validator.In(input.Name, names...), "name", "must be the name of a schema operation", validator.CodeNotOneOf, validator.Params{"allowed": names})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	operation := &data.Operation{Name: input.Name}
+
+	err = app.models.Operations.Insert(r.Context(), operation)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateOperation):
+			v.AddError("name", "the operation is already queued or running", validator.CodeAlreadyExists)
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.jobs.Enqueue(r.Context(), schemaOperationJob{OperationID: operation.ID}, jobs.EnqueueOptions{MaxAttempts: 1})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/operations/%d", operation.ID))
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"operation": operation}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/admin/operations/:id" endpoint, which shows the progress of
+// a schema operation
+func (app *application) showOperationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeOperationNotFound)
+		return
+	}
+
+	operation, err := app.models.Operations.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeOperationNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"operation": operation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/admin/operations" endpoint
+func (app *application) listOperationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	filters := app.readListingFilters(r.URL.Query(), operationListing, v)
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	operations, metadata, err := app.models.Operations.GetAll(r.Context(), filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePage(w, r, "operations", operations, metadata)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/notify"
+)
+
+// Define an opsNotifier type which posts operational messages to the configured chat
+// webhooks. Panic reports are throttled, as a bug in a popular endpoint would otherwise
+// post a message for every request.
+type opsNotifier struct {
+	notifier      notify.Notifier
+	panicCooldown time.Duration
+
+	mutex      sync.Mutex
+	lastPanic  time.Time
+	suppressed int
+}
+
+// Return an opsNotifier for the given webhooks, or nil if none are configured
+func newOpsNotifier(slackURL, discordURL string, panicCooldown time.Duration) *opsNotifier {
+	var notifiers notify.Multi
+
+	if slackURL != "" {
+		notifiers = append(notifiers, notify.NewSlack(slackURL))
+	}
+
+	if discordURL != "" {
+		notifiers = append(notifiers, notify.NewDiscord(discordURL))
+	}
+
+	if len(notifiers) == 0 {
+		return nil
+	}
+
+	return &opsNotifier{notifier: notifiers, panicCooldown: panicCooldown}
+}
+
+// Report whether a panic report may be posted now, returning the number of reports
+// which were suppressed since the last one posted
+func (n *opsNotifier) allowPanic(now time.Time) (bool, int) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if now.Sub(n.lastPanic) < n.panicCooldown {
+		n.suppressed++
+		return false, 0
+	}
+
+	suppressed := n.suppressed
+	n.lastPanic = now
+	n.suppressed = 0
+
+	return true, suppressed
+}
+
+// The notifyOps() helper posts a message to the chat webhooks in a background task, so
+// that the caller doesn't wait for the chat service. Failures are only logged. The
+// instance's hostname and environment are added to the message's fields.
+func (app *application) notifyOps(msg notify.Message) {
+	if app.ops == nil {
+		return
+	}
+
+	fields := map[string]string{"env": app.config.env}
+	if instance, err := os.Hostname(); err == nil {
+		fields["instance"] = instance
+	}
+
+	for name, value := range msg.Fields {
+		fields[name] = value
+	}
+
+	msg.Fields = fields
+
+	app.background("notify_ops", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := app.ops.notifier.Notify(ctx, msg)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"task": "notify_ops", "title": msg.Title})
+		}
+	})
+}
+
+// The notifyPanic() helper reports a recovered panic to the chat webhooks, unless
+// another panic was reported within the cooldown
+func (app *application) notifyPanic(title string, recovered interface{}, fields map[string]string) {
+	if app.ops == nil {
+		return
+	}
+
+	allowed, suppressed := app.ops.allowPanic(time.Now())
+	if !allowed {
+		return
+	}
+
+	text := fmt.Sprint(recovered)
+	if suppressed > 0 {
+		text += fmt.Sprintf("\n\n%d more panics were not reported since the previous report.", suppressed)
+	}
+
+	app.notifyOps(notify.Message{
+		Level:  notify.LevelError,
+		Title:  title,
+		Text:   text,
+		Fields: fields,
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Handler for the "/debug/pprof/*item" endpoints. The net/http/pprof handlers expect to
+// be mounted on a ServeMux, so we dispatch to the right one based on the catch-all
+// parameter. Named profiles (heap, goroutine, allocs etc.) are all served by Index.
+func (app *application) pprofHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	switch params.ByName("item") {
+	case "/cmdline":
+		pprof.Cmdline(w, r)
+	case "/profile":
+		pprof.Profile(w, r)
+	case "/symbol":
+		pprof.Symbol(w, r)
+	case "/trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Index(w, r)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
+)
+
+// Define a publishScheduledMoviesJob struct for the recurring job which publishes the
+// scheduled movies whose publish_at time has passed. It has no payload, as it goes
+// through every scheduled movie.
+type publishScheduledMoviesJob struct{}
+
+func (publishScheduledMoviesJob) Kind() string {
+	return "publish_scheduled_movies"
+}
+
+// Schedule the next run of the scheduled movies job. Only one run is ever pending,
+// however many instances of the application schedule it.
+func (app *application) schedulePublishing(ctx context.Context, runAt time.Time) error {
+	return app.jobs.Enqueue(ctx, publishScheduledMoviesJob{}, jobs.EnqueueOptions{
+		RunAt:  runAt,
+		Unique: true,
+	})
+}
+
+// Publish the scheduled movies which are due, emitting a movie.published event for each
+// and notifying the user who created it. The next run is scheduled first, so that the
+// job keeps recurring even if this run fails. The events are added in the same
+// transaction as publishing the movies, so a failed run is retried with neither saved.
+// A retried run wouldn't find the movies again once they are published, so failures
+// after that are logged rather than failing the job.
+func (app *application) publishScheduledMovies(ctx context.Context, _ publishScheduledMoviesJob) error {
+	if app.config.publishing.interval > 0 {
+		err := app.schedulePublishing(ctx, time.Now().Add(app.config.publishing.interval))
+		if err != nil {
+			return err
+		}
+	}
+
+	onError := func(err error) {
+		app.logger.PrintError(err, map[string]string{"component": "publishing"})
+	}
+
+	var movies []*data.Movie
+
+	err := app.saveWithEvents(ctx, "", func(ctx context.Context) ([]domainEvent, error) {
+		var err error
+
+		movies, err = app.models.Movie.PublishScheduled(ctx, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		pending := make([]domainEvent, len(movies))
+		for i, movie := range movies {
+			pending[i] = domainEvent{Type: eventMoviePublished, ID: movie.ID, Data: movie}
+		}
+
+		return pending, nil
+	}, onError)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		err = app.notifyMoviePublished(ctx, movie)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"component": "publishing",
+				"movie_id":  fmt.Sprint(movie.ID),
+			})
+		}
+	}
+
+	return nil
+}
+
+// Notify the user who created a movie that it has been published. Movies created before
+// their creator was recorded, or whose creator has since been deleted, are skipped.
+func (app *application) notifyMoviePublished(ctx context.Context, movie *data.Movie) error {
+	if movie.CreatedBy == 0 {
+		return nil
+	}
+
+	user, err := app.models.User.Get(ctx, movie.CreatedBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return nil
+		default:
+			return err
+		}
+	}
+
+	return app.notify(ctx, user, data.NotificationMoviePublished,
+		"Your movie has been published",
+		fmt.Sprintf("%q is now visible to everyone.", movie.Title),
+		map[string]interface{}{"movie_id": movie.ID})
+}
+
+// The canSeeUnpublished() helper reports whether the user making the request may see
+// draft and scheduled movies, which is the case for users holding the movies:write
+// permission. Everyone else only sees published movies.
+func (app *application) canSeeUnpublished(r *http.Request) (bool, error) {
+	return app.userHasPermissions(r, "movies:write")
+}
+
+// The movieVisible() helper reports whether the user making the request may see a
+// movie, so that unpublished movies can be reported as not found to everyone else
+func (app *application) movieVisible(r *http.Request, movie *data.Movie) (bool, error) {
+	if movie.Status == data.MoviePublished {
+		return true, nil
+	}
+
+	return app.canSeeUnpublished(r)
+}
+
+// Return the published movies of a list, in the same order, for lists which are built
+// without a search (such as the movies of a collection)
+func publishedMovies(movies []*data.Movie) []*data.Movie {
+	published := []*data.Movie{}
+
+	for _, movie := range movies {
+		if movie.Status == data.MoviePublished {
+			published = append(published, movie)
+		}
+	}
+
+	return published
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Struct used for holding the state of a client tracked by the rate limiter
+type rateLimitClient struct {
+	Key             string    `json:"key"`
+	TokensRemaining float64   `json:"tokens_remaining"`
+	LastSeen        time.Time `json:"last_seen"`
+}
+
+// Define a clientLimiter interface for the rate limiter used by the rateLimit()
+// middleware, which keeps a token bucket for each client. The state of the buckets is
+// exposed so that admins can see who is being limited and reset a client.
+type clientLimiter interface {
+	Allow(key string) bool
+	Clients() []rateLimitClient
+	Reset(key string) bool
+}
+
+// Define a tokenBucket struct for a client's bucket. Each request takes a token, and
+// tokens are added back at a steady rate up to the burst size.
+type tokenBucket struct {
+	tokens   float64
+	updated  time.Time
+	lastSeen time.Time
+}
+
+// Define a memoryLimiter type which keeps the clients' buckets in memory, so each
+// instance of the application limits clients separately. The buckets of clients which
+// haven't been seen for three minutes are removed once a minute.
+type memoryLimiter struct {
+	rps   float64
+	burst int
+
+	mutex   sync.Mutex
+	clients map[string]*tokenBucket
+}
+
+// Return a new memoryLimiter allowing each client rps requests per second on average,
+// with bursts of up to burst requests
+func newMemoryLimiter(rps float64, burst int) *memoryLimiter {
+	l := &memoryLimiter{
+		rps:     rps,
+		burst:   burst,
+		clients: make(map[string]*tokenBucket),
+	}
+
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+
+			l.mutex.Lock()
+
+			for key, bucket := range l.clients {
+				if time.Since(bucket.lastSeen) > 3*time.Minute {
+					delete(l.clients, key)
+				}
+			}
+
+			l.mutex.Unlock()
+		}
+	}()
+
+	return l
+}
+
+// Report whether the client may make a request now, taking a token from its bucket if
+// it may. New clients start with a full bucket.
+func (l *memoryLimiter) Allow(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+
+	bucket, found := l.clients[key]
+	if !found {
+		bucket = &tokenBucket{tokens: float64(l.burst), updated: now}
+		l.clients[key] = bucket
+	}
+
+	bucket.lastSeen = now
+	l.refill(bucket, now)
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+
+	return true
+}
+
+// Return the clients being tracked, with the tokens left in their buckets as of now
+func (l *memoryLimiter) Clients() []rateLimitClient {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	clients := make([]rateLimitClient, 0, len(l.clients))
+
+	for key, bucket := range l.clients {
+		l.refill(bucket, now)
+
+		clients = append(clients, rateLimitClient{
+			Key:             key,
+			TokensRemaining: bucket.tokens,
+			LastSeen:        bucket.lastSeen,
+		})
+	}
+
+	return clients
+}
+
+// Forget a client, so that its next request starts with a full bucket. It reports
+// whether the client was being tracked.
+func (l *memoryLimiter) Reset(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	_, found := l.clients[key]
+	delete(l.clients, key)
+
+	return found
+}
+
+// Add the tokens earned since the bucket was last updated, up to the burst size
+func (l *memoryLimiter) refill(bucket *tokenBucket, now time.Time) {
+	bucket.tokens += now.Sub(bucket.updated).Seconds() * l.rps
+	if bucket.tokens > float64(l.burst) {
+		bucket.tokens = float64(l.burst)
+	}
+
+	bucket.updated = now
+}
+
+// Handler for the "GET /v1/admin/rate-limits" endpoint, which lists the clients
+// tracked by this instance's rate limiters, the most limited first. Guest sessions are
+// listed with keys starting with "guest:", and the IP addresses getting guest tokens
+// with keys starting with "guest-token:".
+func (app *application) listRateLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	clients := app.limiter.Clients()
+
+	if app.guests != nil {
+		clients = append(clients, app.guests.Clients()...)
+		clients = append(clients, app.guestTokens.Clients()...)
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].TokensRemaining != clients[j].TokensRemaining {
+			return clients[i].TokensRemaining < clients[j].TokensRemaining
+		}
+
+		return clients[i].Key < clients[j].Key
+	})
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"rate_limits": clients}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "DELETE /v1/admin/rate-limits/:key" endpoint, which resets a client's
+// rate limit on this instance
+func (app *application) deleteRateLimitHandler(w http.ResponseWriter, r *http.Request) {
+	key := httprouter.ParamsFromContext(r.Context()).ByName("key")
+
+	found := app.limiter.Reset(key)
+
+	if app.guests != nil && (app.guests.Reset(key) || app.guestTokens.Reset(key)) {
+		found = true
+	}
+
+	if !found {
+		app.resourceNotFoundResponse(w, r, codeRateLimitNotFound)
+		return
+	}
+
+	app.logger.PrintInfo("client rate limit reset", map[string]string{"key": key})
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"message": "rate limit successfully reset"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
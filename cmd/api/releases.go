@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Read the movie ID from the URL and check that the movie exists and is visible to the
+// user, sending the error response if it isn't
+func (app *application) readExistingMovieID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	movieID, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		return 0, false
+	}
+
+	movie, err := app.models.Movie.Get(r.Context(), movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return 0, false
+	}
+
+	visible, err := app.movieVisible(r, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return 0, false
+	}
+
+	if !visible {
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		return 0, false
+	}
+
+	return movieID, true
+}
+
+// Handler for the "GET /v1/movies/:id/releases" endpoint
+func (app *application) listMovieReleasesHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, ok := app.readExistingMovieID(w, r)
+	if !ok {
+		return
+	}
+
+	releases, err := app.models.Releases.GetAllForMovie(r.Context(), movieID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"releases": releases}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "POST /v1/movies/:id/releases" endpoint
+func (app *application) createMovieReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, ok := app.readExistingMovieID(w, r)
+	if !ok || !app.requireMovieEditable(w, r, movieID) {
+		return
+	}
+
+	var input struct {
+		Country     string    `json:"country"`
+		Type        string    `json:"type"`
+		ReleaseDate data.Date `json:"release_date"`
+		Note        string    `json:"note"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	release := &data.MovieRelease{
+		MovieID:     movieID,
+		Country:     input.Country,
+		Type:        input.Type,
+		ReleaseDate: input.ReleaseDate,
+		Note:        input.Note,
+	}
+
+	v := validator.New()
+
+	if data.ValidateMovieRelease(v, release); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Releases.Insert(r.Context(), release)
+	if err != nil {
+		switch {
+		// The movie was deleted after we checked that it exists
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		case errors.Is(err, data.ErrDuplicateRelease):
+			v.AddError("type", "the movie already has a release of this type in this country", validator.CodeAlreadyExists)
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d/releases/%d", movieID, release.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"release": release}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "PATCH /v1/movies/:id/releases/:release_id" endpoint
+func (app *application) updateMovieReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	id, err := app.readNamedIDParam(r, "release_id")
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeReleaseNotFound)
+		return
+	}
+
+	if !app.requireMovieEditable(w, r, movieID) {
+		return
+	}
+
+	release, err := app.models.Releases.Get(r.Context(), movieID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeReleaseNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	var input struct {
+		Country     *string    `json:"country"`
+		Type        *string    `json:"type"`
+		ReleaseDate *data.Date `json:"release_date"`
+		Note        *string    `json:"note"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Country != nil {
+		release.Country = *input.Country
+	}
+
+	if input.Type != nil {
+		release.Type = *input.Type
+	}
+
+	if input.ReleaseDate != nil {
+		release.ReleaseDate = *input.ReleaseDate
+	}
+
+	if input.Note != nil {
+		release.Note = *input.Note
+	}
+
+	v := validator.New()
+
+	if data.ValidateMovieRelease(v, release); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Releases.Update(r.Context(), release)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateRelease):
+			v.AddError("type", "the movie already has a release of this type in this country", validator.CodeAlreadyExists)
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"release": release}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "DELETE /v1/movies/:id/releases/:release_id" endpoint
+func (app *application) deleteMovieReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		return
+	}
+
+	id, err := app.readNamedIDParam(r, "release_id")
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeReleaseNotFound)
+		return
+	}
+
+	if !app.requireMovieEditable(w, r, movieID) {
+		return
+	}
+
+	err = app.models.Releases.Delete(r.Context(), movieID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeReleaseNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "release successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
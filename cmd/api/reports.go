@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Handler for the "POST /v1/reviews/:id/reports" endpoint. Once a review has reached
+// the report threshold, it is hidden until a moderator has looked at it.
+func (app *application) createReviewReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeReviewNotFound)
+		return
+	}
+
+	// Only published reviews can be reported, so any others are reported as not found
+	review, err := app.models.Reviews.Get(r.Context(), id)
+	if err == nil && review.Status != data.ReviewApproved {
+		err = data.ErrRecordNotFound
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeReviewNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	var input struct {
+		Reason  string `json:"reason"`
+		Comment string `json:"comment"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	report := &data.ReviewReport{
+		ReviewID: review.ID,
+		UserID:   user.ID,
+		Reason:   input.Reason,
+		Comment:  input.Comment,
+	}
+
+	v := validator.New()
+
+	v.Check(review.UserID != user.ID, "review", "you can't report your own review", validator.CodeNotAllowed)
+
+	if data.ValidateReviewReport(v, report); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	open, err := app.models.Reports.Insert(r.Context(), report)
+	if err != nil {
+		switch {
+		// The review was deleted after we fetched it
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeReviewNotFound)
+		case errors.Is(err, data.ErrDuplicateReport):
+			v.AddError("review", "you have already reported this review", validator.CodeAlreadyExists)
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	threshold := app.config.reviews.reportThreshold
+	if threshold > 0 && open >= threshold {
+		app.hideReportedReview(r, review, open)
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"report": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Send a reported review back to the moderation queue. The report has already been
+// stored, so errors are only logged rather than failing the request.
+func (app *application) hideReportedReview(r *http.Request, review *data.Review, reports int) {
+	review.Status = data.ReviewPending
+	review.ModerationReason = fmt.Sprintf("hidden after %d reports", reports)
+	review.ModeratedBy = 0
+
+	err := app.models.Reviews.Moderate(r.Context(), review)
+	if err != nil {
+		switch {
+		// A moderator or another report changed the review at the same time, and the
+		// next report will hide it if it is still approved
+		case errors.Is(err, data.ErrEditConflict):
+		default:
+			app.logError(r, err)
+		}
+	}
+}
+
+// Handler for the "GET /v1/moderation/reports" endpoint. Reports are listed oldest
+// first, like the review moderation queue.
+func (app *application) listReportsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		Reason string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	queryString := r.URL.Query()
+
+	input.Status = app.readString(queryString, "status", data.ReportOpen)
+	input.Reason = app.readString(queryString, "reason", "")
+	input.Filters = app.readListingFilters(queryString, reportListing, v)
+
+	v.Check(validator.In(input.Status, data.ReportOpen, data.ReportUpheld, data.ReportDismissed), "status", "must be open, upheld or dismissed", validator.CodeNotOneOf, validator.Params{"allowed": []string{data.ReportOpen, data.ReportUpheld, data.ReportDismissed}})
+
+	if input.Reason != "" {
+		v.Check(validator.In(input.Reason, data.ReportReasons...), "reason", "must be a valid report reason", validator.CodeNotOneOf, validator.Params{"allowed": data.ReportReasons})
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	reports, metadata, err := app.models.Reports.GetAll(r.Context(), input.Status, input.Reason, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePage(w, r, "reports", reports, metadata)
+}
+
+// Handler for the "PUT /v1/moderation/reports/:id" endpoint, which upholds or dismisses
+// an open report. The reported review is left as it is, and is approved or rejected
+// separately.
+func (app *application) resolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeReportNotFound)
+		return
+	}
+
+	report, err := app.models.Reports.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeReportNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(validator.In(input.Status, data.ReportUpheld, data.ReportDismissed), "status", "must be upheld or dismissed", validator.CodeNotOneOf, validator.Params{"allowed": []string{data.ReportUpheld, data.ReportDismissed}})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	if report.Status != data.ReportOpen {
+		app.invalidTransitionResponse(w, r, "report", report.Status, input.Status)
+		return
+	}
+
+	report.Status = input.Status
+	report.ResolvedBy = app.contextGetUser(r).ID
+
+	err = app.models.Reports.Resolve(r.Context(), report)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"report": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
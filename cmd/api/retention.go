@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
+)
+
+// Publish the number of rows deleted by the data retention job, broken down by the
+// kind of data
+var retentionRowsDeleted = expvar.NewMap("retention_rows_deleted")
+
+// Define a retentionJob struct for the recurring job which deletes data that has been
+// kept for longer than its retention window. It has no payload, as it goes through
+// every kind of data with a window.
+type retentionJob struct{}
+
+func (retentionJob) Kind() string {
+	return "retention"
+}
+
+// Schedule the next run of the data retention job. Only one run is ever pending,
+// however many instances of the application schedule it.
+func (app *application) scheduleRetention(ctx context.Context, runAt time.Time) error {
+	return app.jobs.Enqueue(ctx, retentionJob{}, jobs.EnqueueOptions{
+		RunAt:  runAt,
+		Unique: true,
+	})
+}
+
+// Define a retentionPolicy type for one kind of data pruned by the retention job. The
+// delete function deletes up to limit rows which are older than the given time.
+type retentionPolicy struct {
+	name   string
+	window time.Duration
+	delete func(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// Return the retention policies of the application. A window of 0 keeps the data
+// forever, so its policy is left out.
+func (app *application) retentionPolicies() []retentionPolicy {
+	policies := []retentionPolicy{
+		{"expired_tokens", app.config.retention.expiredTokens, app.models.Token.DeleteExpired},
+		{"failed_jobs", app.config.retention.failedJobs, app.jobs.DeleteFailed},
+		{"read_notifications", app.config.retention.readNotifications, app.models.Notifications.DeleteRead},
+		{"reviewed_movie_changes", app.config.retention.movieChanges, app.models.MovieChanges.DeleteReviewed},
+	}
+
+	enabled := policies[:0]
+
+	for _, policy := range policies {
+		if policy.window > 0 {
+			enabled = append(enabled, policy)
+		}
+	}
+
+	return enabled
+}
+
+// Delete the data which has been kept for longer than its retention window. The rows
+// are deleted in batches, so that no single query holds locks on a large part of a
+// table, and a failure part way through keeps what was already deleted. The next run
+// is scheduled first, so that the job keeps recurring even if this run fails.
+func (app *application) pruneData(ctx context.Context, _ retentionJob) error {
+	if app.config.retention.interval > 0 {
+		err := app.scheduleRetention(ctx, time.Now().Add(app.config.retention.interval))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, policy := range app.retentionPolicies() {
+		before := time.Now().Add(-policy.window)
+
+		var total int64
+
+		for {
+			deleted, err := policy.delete(ctx, before, app.config.retention.batchSize)
+			if err != nil {
+				return fmt.Errorf("pruning %s: %w", policy.name, err)
+			}
+
+			total += deleted
+			retentionRowsDeleted.Add(policy.name, deleted)
+
+			if deleted < int64(app.config.retention.batchSize) {
+				break
+			}
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		if total > 0 {
+			app.logger.PrintInfo("pruned data past its retention window", map[string]string{
+				"kind":    policy.name,
+				"deleted": fmt.Sprint(total),
+			})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Handler for the "GET /v1/movies/:id/reviews" endpoint. Only approved reviews are listed.
+func (app *application) listMovieReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, ok := app.readExistingMovieID(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	queryString := r.URL.Query()
+
+	input.Filters = app.readListingFilters(queryString, movieReviewListing, v)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	reviews, metadata, err := app.models.Reviews.GetAllForMovie(r.Context(), movieID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePage(w, r, "reviews", reviews, metadata)
+}
+
+// Handler for the "POST /v1/movies/:id/reviews" endpoint. The review is published
+// straight away unless the screening holds it, or pre-moderation is enabled, in which
+// case it waits in the moderation queue.
+func (app *application) createMovieReviewHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, ok := app.readExistingMovieID(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Rating int16  `json:"rating"`
+		Body   string `json:"body"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	review := &data.Review{
+		MovieID:  movieID,
+		UserID:   user.ID,
+		UserName: user.Name,
+		Rating:   input.Rating,
+		Body:     input.Body,
+		Status:   data.ReviewApproved,
+	}
+
+	v := validator.New()
+
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	var reasons []string
+
+	if app.screener != nil {
+		reasons, err = app.screener.Screen(r.Context(), review.Body)
+		if err != nil {
+			// Hold the review rather than failing the request, so that a broken screener
+			// doesn't stop users from posting reviews
+			app.logError(r, err)
+			reasons = []string{"screening failed"}
+		}
+	}
+
+	if app.config.reviews.premoderation && len(reasons) == 0 {
+		reasons = []string{"pre-moderation"}
+	}
+
+	if len(reasons) > 0 {
+		review.Status = data.ReviewPending
+		review.ModerationReason = strings.Join(reasons, "; ")
+	}
+
+	err = app.models.Reviews.Insert(r.Context(), review)
+	if err != nil {
+		switch {
+		// The movie was deleted after we checked that it exists
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeMovieNotFound)
+		case errors.Is(err, data.ErrDuplicateReview):
+			v.AddError("movie_id", "you have already reviewed this movie", validator.CodeAlreadyExists)
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/moderation/reviews" endpoint. Reviews are listed oldest first,
+// so that moderators work through the queue in the order it was filled.
+func (app *application) listModerationReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	queryString := r.URL.Query()
+
+	input.Status = app.readString(queryString, "status", data.ReviewPending)
+	input.Filters = app.readListingFilters(queryString, moderationReviewListing, v)
+
+	v.Check(validator.In(input.Status, data.ReviewPending, data.ReviewApproved, data.ReviewRejected), "status", "must be pending, approved or rejected", validator.CodeNotOneOf, validator.Params{"allowed": []string{data.ReviewPending, data.ReviewApproved, data.ReviewRejected}})
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	reviews, metadata, err := app.models.Reviews.GetAllByStatus(r.Context(), input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePage(w, r, "reviews", reviews, metadata)
+}
+
+// Handler for the "PUT /v1/moderation/reviews/:id/approve" endpoint
+func (app *application) approveReviewHandler(w http.ResponseWriter, r *http.Request) {
+	app.moderateReview(w, r, data.ReviewApproved, "")
+}
+
+// Handler for the "PUT /v1/moderation/reviews/:id/reject" endpoint. The reason is
+// optional and is kept with the review.
+func (app *application) rejectReviewHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	// The body can be left out altogether when no reason is given
+	if r.ContentLength != 0 {
+		err := app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	v := validator.New()
+
+	v.Check(len(input.Reason) <= 500, "reason", "must not be more than 500 bytes long", validator.CodeTooLong, validator.Params{"max": 500})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	app.moderateReview(w, r, data.ReviewRejected, input.Reason)
+}
+
+// Move the review in the URL to the given status on behalf of the moderator making the
+// request, and send the updated review
+func (app *application) moderateReview(w http.ResponseWriter, r *http.Request, status, reason string) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeReviewNotFound)
+		return
+	}
+
+	review, err := app.models.Reviews.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeReviewNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if !review.CanTransitionTo(status) {
+		app.invalidTransitionResponse(w, r, "review", review.Status, status)
+		return
+	}
+
+	review.Status = status
+	review.ModerationReason = reason
+	review.ModeratedBy = app.contextGetUser(r).ID
+
+	err = app.models.Reviews.Moderate(r.Context(), review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	// The decision also settles any open reports on the review. The review has already
+	// been moderated, so an error here is only logged.
+	reportStatus := data.ReportDismissed
+	if status == data.ReviewRejected {
+		reportStatus = data.ReportUpheld
+	}
+
+	err = app.models.Reports.ResolveForReview(r.Context(), review.ID, reportStatus, review.ModeratedBy)
+	if err != nil {
+		app.logError(r, err)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Define a middleware struct for the functions which wrap an individual route's
+// handler, such as the permission checks. The describe function, if there is one,
+// records what the middleware requires of the requests in the route's registry entry.
+type middleware struct {
+	wrap     func(http.HandlerFunc) http.HandlerFunc
+	describe func(*routeInfo)
+}
+
+// Define a routeInfo struct describing a registered route. The entries are collected
+// in the route registry as the routes are registered, so that they can't drift from
+// what is actually served.
+type routeInfo struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Handler    string `json:"handler"`
+	Permission string `json:"permission,omitempty"`
+	RateLimit  string `json:"rate_limit"` // Either "client", when requests count towards the client's rate limit, or "none"
+}
+
+// Define a routeGroup type which registers routes on a httprouter.Router under a
+// common path prefix, wrapping each handler with the group's middleware. Groups can be
+// nested, in which case the child inherits the prefix and middleware of its parent.
+type routeGroup struct {
+	router     *httprouter.Router
+	chain      *chain
+	app        *application
+	prefix     string
+	middleware []middleware
+	skip       []string
+}
+
+// Return a new route group for the root of the given router, whose requests are handled
+// by the given middleware chain. The routes are added to the chain's route registry, and
+// the registry entry of each handler is recorded in the request context, for labelling
+// the request metrics.
+func (app *application) newRouteGroup(router *httprouter.Router, c *chain) *routeGroup {
+	return &routeGroup{router: router, chain: c, app: app}
+}
+
+// Group returns a child group whose routes are registered under the given prefix
+// (relative to the parent's) and are wrapped with the given middleware after the
+// parent's middleware
+func (g *routeGroup) Group(prefix string, mw ...middleware) *routeGroup {
+	chain := make([]middleware, 0, len(g.middleware)+len(mw))
+	chain = append(chain, g.middleware...)
+	chain = append(chain, mw...)
+
+	return &routeGroup{
+		router:     g.router,
+		chain:      g.chain,
+		app:        g.app,
+		prefix:     g.prefix + prefix,
+		middleware: chain,
+		skip:       g.skip,
+	}
+}
+
+// Without returns a child group with the same prefix whose routes bypass the named
+// middleware of the listener's chain, such as "rate_limit"
+func (g *routeGroup) Without(names ...string) *routeGroup {
+	child := g.Group("")
+
+	child.skip = make([]string, 0, len(g.skip)+len(names))
+	child.skip = append(child.skip, g.skip...)
+	child.skip = append(child.skip, names...)
+
+	return child
+}
+
+// Register a handler function for the given method and path. The first middleware of
+// the group is the outermost, so it runs first. GET handlers are registered for HEAD
+// requests too; the server discards the body written in response to a HEAD request,
+// so the client gets the same headers as for a GET without the body.
+func (g *routeGroup) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	g.handle(method, path, handler, handlerName(handler))
+}
+
+// Register a handler for the given method and path
+func (g *routeGroup) Handler(method, path string, handler http.Handler) {
+	if f, ok := handler.(http.HandlerFunc); ok {
+		g.HandlerFunc(method, path, f)
+		return
+	}
+
+	g.handle(method, path, handler.ServeHTTP, strings.TrimPrefix(reflect.TypeOf(handler).String(), "*"))
+}
+
+// Register the handler, adding its entry to the route registry
+func (g *routeGroup) handle(method, path string, handler http.HandlerFunc, name string) {
+	info := &routeInfo{
+		Method:    method,
+		Path:      g.prefix + path,
+		Handler:   name,
+		RateLimit: "client",
+	}
+
+	for i := len(g.middleware) - 1; i >= 0; i-- {
+		handler = g.middleware[i].wrap(handler)
+
+		if g.middleware[i].describe != nil {
+			g.middleware[i].describe(info)
+		}
+	}
+
+	next := handler
+	handler = func(w http.ResponseWriter, r *http.Request) {
+		g.app.contextSetRoute(r, info)
+		next(w, r)
+	}
+
+	g.router.HandlerFunc(method, info.Path, handler)
+
+	if method == http.MethodGet {
+		g.router.HandlerFunc(http.MethodHead, info.Path, handler)
+	}
+
+	for _, name := range g.skip {
+		g.chain.Skip(name, method, info.Path)
+
+		if method == http.MethodGet {
+			g.chain.Skip(name, http.MethodHead, info.Path)
+		}
+
+		if name == "rate_limit" {
+			info.RateLimit = "none"
+		}
+	}
+
+	if !g.app.config.limiter.enabled {
+		info.RateLimit = "none"
+	}
+
+	g.chain.routes = append(g.chain.routes, info)
+}
+
+// Return the name of a handler function, without the package path, such as
+// "listMoviesHandler" for the application's handlers or "expvar.expvarHandler"
+func handlerName(handler http.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.TrimPrefix(name, "main.(*application).")
+
+	// Method values are wrapped in a function with this suffix
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// Return a middleware which requires the user to hold the given permission, for
+// attaching to a route group
+func (app *application) withPermission(code string) middleware {
+	return middleware{
+		wrap: func(next http.HandlerFunc) http.HandlerFunc {
+			return app.requirePermission(code, next)
+		},
+		describe: func(info *routeInfo) {
+			info.Permission = code
+		},
+	}
+}
+
+// Return a middleware which requires the user to hold the given permission, like
+// withPermission(), except for GET and HEAD requests when the catalog is public. Anyone
+// can then read the routes of the group, while writing still requires the permission.
+func (app *application) withCatalogPermission(code string) middleware {
+	return middleware{
+		wrap: func(next http.HandlerFunc) http.HandlerFunc {
+			protected := app.requirePermission(code, next)
+
+			return func(w http.ResponseWriter, r *http.Request) {
+				if app.config.catalog.public && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+					next(w, r)
+					return
+				}
+
+				protected(w, r)
+			}
+		},
+		describe: func(info *routeInfo) {
+			if !app.config.catalog.public || info.Method != http.MethodGet {
+				info.Permission = code
+			}
+		},
+	}
+}
+
+// Return a middleware which requires an activated user, for the routes of a group
+// which need an account but no particular permission
+func (app *application) withActivatedUser() middleware {
+	return middleware{wrap: app.requireActivatedUser}
+}
+
+// Return a middleware which turns away service accounts, for attaching to a route group
+func (app *application) withHumanUser() middleware {
+	return middleware{wrap: app.requireHumanUser}
+}
+
+// Return a middleware which sets the maximum request body size for a group's routes,
+// in place of the default limit
+func (app *application) withBodyLimit(maxBytes int64) middleware {
+	return middleware{
+		wrap: func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				next(w, app.contextSetBodyLimit(r, maxBytes))
+			}
+		},
+	}
+}
+
+// Return a middleware which sets whether unknown fields in request bodies are ignored
+// for a group's routes, in place of the configured default
+func (app *application) withUnknownFields(allow bool) middleware {
+	return middleware{
+		wrap: func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				next(w, app.contextSetAllowUnknownFields(r, allow))
+			}
+		},
+	}
+}
+
+// The optionsHandler() method answers OPTIONS requests for any registered path. The
+// router has already set the Allow header to the methods registered for the path, so
+// we reuse it for CORS preflight requests (which have been let through by the
+// enableCORS middleware for trusted origins) so that they list the same methods.
+func (app *application) optionsHandler(w http.ResponseWriter, r *http.Request) {
+	if w.Header().Get("Access-Control-Allow-Origin") != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+		w.Header().Set("Access-Control-Allow-Methods", w.Header().Get("Allow"))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
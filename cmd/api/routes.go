@@ -14,25 +14,287 @@ func (app *application) routes() http.Handler {
 	// Set custom error handlers
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+	router.GlobalOPTIONS = http.HandlerFunc(app.optionsHandler)
 
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	global := newChain()
 
-	router.HandlerFunc(http.MethodGet, "/v1/movies", app.requirePermission("movies:read", app.listMoviesHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.createMovieHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requirePermission("movies:read", app.showMovieHandler))
-	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMovieHandler))
+	v1 := app.newRouteGroup(router, global).Group("/v1")
 
-	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/users/password", app.updateUserPasswordHandler)
+	// Load balancers and orchestrators poll the healthcheck, so they mustn't be rate
+	// limited (or banned for it)
+	v1.Without("detect_abuse", "rate_limit").HandlerFunc(http.MethodGet, "/healthcheck", app.healthcheckHandler)
+	v1.HandlerFunc(http.MethodGet, "/meta", app.metaHandler)
+	v1.HandlerFunc(http.MethodGet, "/meta/routes", app.routesHandler)
+	v1.HandlerFunc(http.MethodGet, "/changelog", app.changelogHandler)
+	v1.HandlerFunc(http.MethodGet, "/error-codes", app.errorCodesHandler)
 
-	router.HandlerFunc(http.MethodPost, "/v1/tokens/activation", app.createActivationTokenHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/tokens/password-reset", app.createPasswordResetTokenHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	// Reading and writing movies require separate permissions, so each gets its own group.
+	// In public catalog mode the movies can be read without authenticating.
+	moviesRead := v1.Group("/movies", app.withCatalogPermission("movies:read"))
+	moviesRead.HandlerFunc(http.MethodGet, "", app.listMoviesHandler)
+	moviesRead.HandlerFunc(http.MethodGet, "/:id", app.showMovieOrListHandler)
+	moviesRead.HandlerFunc(http.MethodGet, "/:id/releases", app.listMovieReleasesHandler)
+	moviesRead.HandlerFunc(http.MethodGet, "/:id/reviews", app.listMovieReviewsHandler)
+	moviesRead.HandlerFunc(http.MethodPost, "/:id/reviews", app.createMovieReviewHandler)
+	moviesRead.HandlerFunc(http.MethodPost, "/:id/pending-changes", app.createMovieChangeHandler)
 
-	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
+	// Streaming availability is only served when a watch-provider API is configured
+	if app.providers != nil {
+		moviesRead.HandlerFunc(http.MethodGet, "/:id/availability", app.showMovieAvailabilityHandler)
+	}
 
-	// Wrap the router with the panic recovery middleware
-	return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router)))))
+	moviesWrite := v1.Group("/movies", app.withPermission("movies:write"), app.withUnknownFields(false))
+	moviesWrite.HandlerFunc(http.MethodPost, "", app.createMovieHandler)
+	moviesWrite.HandlerFunc(http.MethodPatch, "/:id", app.updateMovieHandler)
+	moviesWrite.HandlerFunc(http.MethodDelete, "/:id", app.deleteMovieHandler)
+	moviesWrite.HandlerFunc(http.MethodPost, "/:id/releases", app.createMovieReleaseHandler)
+	moviesWrite.HandlerFunc(http.MethodPatch, "/:id/releases/:release_id", app.updateMovieReleaseHandler)
+	moviesWrite.HandlerFunc(http.MethodDelete, "/:id/releases/:release_id", app.deleteMovieReleaseHandler)
+	moviesWrite.HandlerFunc(http.MethodGet, "/:id/pending-changes", app.listMovieChangesHandler)
+	moviesWrite.HandlerFunc(http.MethodPut, "/:id/pending-changes/:change_id/approve", app.approveMovieChangeHandler)
+	moviesWrite.HandlerFunc(http.MethodPut, "/:id/pending-changes/:change_id/reject", app.rejectMovieChangeHandler)
+
+	// Collections of related movies share the movie permissions
+	collectionsRead := v1.Group("/collections", app.withCatalogPermission("movies:read"))
+	collectionsRead.HandlerFunc(http.MethodGet, "", app.listCollectionsHandler)
+	collectionsRead.HandlerFunc(http.MethodGet, "/:id", app.showCollectionHandler)
+
+	collectionsWrite := v1.Group("/collections", app.withPermission("movies:write"), app.withUnknownFields(false))
+	collectionsWrite.HandlerFunc(http.MethodPost, "", app.createCollectionHandler)
+	collectionsWrite.HandlerFunc(http.MethodPatch, "/:id", app.updateCollectionHandler)
+	collectionsWrite.HandlerFunc(http.MethodDelete, "/:id", app.deleteCollectionHandler)
+
+	reviews := v1.Group("/reviews", app.withPermission("movies:read"))
+	reviews.HandlerFunc(http.MethodPost, "/:id/reports", app.createReviewReportHandler)
+
+	moderation := v1.Group("/moderation", app.withPermission("moderation:write"))
+	moderation.HandlerFunc(http.MethodGet, "/reviews", app.listModerationReviewsHandler)
+	moderation.HandlerFunc(http.MethodPut, "/reviews/:id/approve", app.approveReviewHandler)
+	moderation.HandlerFunc(http.MethodPut, "/reviews/:id/reject", app.rejectReviewHandler)
+	moderation.HandlerFunc(http.MethodGet, "/reports", app.listReportsHandler)
+	moderation.HandlerFunc(http.MethodPut, "/reports/:id", app.resolveReportHandler)
+
+	admin := v1.Group("/admin", app.withPermission("admin:read"))
+	admin.HandlerFunc(http.MethodGet, "/stats", app.adminStatsHandler)
+	admin.HandlerFunc(http.MethodGet, "/users/:id", app.showUserHandler)
+	admin.HandlerFunc(http.MethodGet, "/mail-templates/:name/preview", app.previewMailTemplateHandler)
+
+	// Admins acting as another user can't start impersonating someone else
+	impersonate := v1.Group("/admin/impersonate", app.withPermission("users:impersonate"), app.withHumanUser())
+	impersonate.HandlerFunc(http.MethodPost, "/:id", app.createImpersonationTokenHandler)
+
+	invitations := v1.Group("/invitations", app.withPermission("invitations:write"), app.withUnknownFields(false))
+	invitations.HandlerFunc(http.MethodGet, "", app.listInvitationsHandler)
+	invitations.HandlerFunc(http.MethodPost, "", app.createInvitationHandler)
+	invitations.HandlerFunc(http.MethodDelete, "/:id", app.deleteInvitationHandler)
+
+	// Most user and token endpoints can be called without authenticating, so they only
+	// accept small bodies
+	users := v1.Group("/users", app.withBodyLimit(app.config.body.authMaxBytes))
+	users.HandlerFunc(http.MethodPost, "", app.registerUserHandler)
+	users.HandlerFunc(http.MethodPut, "/activated", app.activateUserHandler)
+	users.HandlerFunc(http.MethodPut, "/password", app.updateUserPasswordHandler)
+
+	// Endpoints for the authenticated user's own resources
+	me := users.Group("/me", app.withActivatedUser())
+	me.HandlerFunc(http.MethodGet, "/notifications", app.listNotificationsHandler)
+	me.HandlerFunc(http.MethodPut, "/notifications/:id/read", app.markNotificationReadHandler)
+	me.HandlerFunc(http.MethodGet, "/notification-preferences", app.showNotificationPreferencesHandler)
+	me.HandlerFunc(http.MethodPut, "/notification-preferences", app.updateNotificationPreferencesHandler)
+	me.HandlerFunc(http.MethodGet, "/saved-searches", app.listSavedSearchesHandler)
+	me.HandlerFunc(http.MethodPost, "/saved-searches", app.createSavedSearchHandler)
+	me.HandlerFunc(http.MethodDelete, "/saved-searches/:id", app.deleteSavedSearchHandler)
+	me.HandlerFunc(http.MethodGet, "/digest", app.showDigestSubscriptionHandler)
+	me.HandlerFunc(http.MethodPut, "/digest", app.updateDigestSubscriptionHandler)
+	me.HandlerFunc(http.MethodDelete, "/digest", app.deleteDigestSubscriptionHandler)
+
+	// The terms of service can be accepted without having accepted them already
+	if app.tosAccepted != nil {
+		me.Without("require_tos").HandlerFunc(http.MethodPost, "/tos-acceptance", app.createTOSAcceptanceHandler)
+	}
+
+	// Service accounts can't manage service accounts, so that a leaked key can't be
+	// used to mint more
+	serviceAccounts := me.Group("/service-accounts", app.withHumanUser(), app.withUnknownFields(false))
+	serviceAccounts.HandlerFunc(http.MethodGet, "", app.listServiceAccountsHandler)
+	serviceAccounts.HandlerFunc(http.MethodPost, "", app.createServiceAccountHandler)
+	serviceAccounts.HandlerFunc(http.MethodGet, "/:id", app.showServiceAccountHandler)
+	serviceAccounts.HandlerFunc(http.MethodPatch, "/:id", app.updateServiceAccountHandler)
+	serviceAccounts.HandlerFunc(http.MethodPost, "/:id/key", app.rotateServiceAccountKeyHandler)
+	serviceAccounts.HandlerFunc(http.MethodDelete, "/:id", app.deleteServiceAccountHandler)
+
+	tokens := v1.Group("/tokens", app.withBodyLimit(app.config.body.authMaxBytes))
+	tokens.HandlerFunc(http.MethodPost, "/activation", app.createActivationTokenHandler)
+	tokens.HandlerFunc(http.MethodPost, "/password-reset", app.createPasswordResetTokenHandler)
+	tokens.HandlerFunc(http.MethodPost, "/authentication", app.createAuthenticationTokenHandler)
+
+	if app.guests != nil {
+		tokens.HandlerFunc(http.MethodPost, "/guest", app.createGuestTokenHandler)
+	}
+
+	// The email provider's bounce and complaint notifications are authenticated with
+	// the webhook secret rather than a token, and providers send them in bursts with
+	// fields of their own
+	if app.config.smtp.webhookSecret != "" {
+		emailWebhooks := v1.Group("/webhooks/email", app.withUnknownFields(true)).Without("detect_abuse", "rate_limit")
+		emailWebhooks.HandlerFunc(http.MethodPost, "/ses", app.sesWebhookHandler)
+		emailWebhooks.HandlerFunc(http.MethodPost, "/sendgrid", app.sendgridWebhookHandler)
+	}
+
+	// Web crawlers look for the sitemap at the root of the site
+	if app.config.catalog.public && app.config.catalog.url != "" {
+		app.newRouteGroup(router, global).HandlerFunc(http.MethodGet, "/sitemap.xml", app.sitemapHandler)
+	}
+
+	// When no separate admin listener is configured, the operational endpoints are
+	// served on the public port instead, with ban and rate limit management restricted
+	// to users holding bans:write, the metrics snapshots to those holding
+	// metrics:write, backups to those holding backups:write and schema operations to
+	// those holding operations:write. Profiling is only served on the admin listener.
+	if app.config.admin.addr == "" {
+		root := app.newRouteGroup(router, global)
+
+		app.debugRoutes(root)
+		app.banRoutes(root, app.withPermission("bans:write"))
+		app.rateLimitRoutes(root, app.withPermission("bans:write"))
+		app.metricsRoutes(root, app.withPermission("metrics:write"))
+		app.backupRoutes(root, app.withPermission("backups:write"))
+		app.operationRoutes(root, app.withPermission("operations:write"))
+	}
+
+	// The middleware run in the order they are added. Requests are let through to the
+	// router by the middleware which don't stop them, so a middleware can rely on those
+	// added before it:
+	//   - The HTTP/3 advertisement is added to every response, even errors.
+	//   - The request context bag is added first of the rest, so that every other
+	//     middleware can record its values in it.
+	//   - The request ID and trace come before panic recovery, so that panics are
+	//     logged with them.
+	//   - Abuse detection bans clients which keep getting rate limited or failing
+	//     authentication, so it wraps both.
+	//   - The concurrency limit comes after the rate limiter, so that a single client
+	//     can't take up the queue, and before authentication, which hits the database.
+	//   - Signed requests from partner clients are checked after the bearer token
+	//     authentication, which leaves them with the anonymous user.
+	//   - The terms of service acceptance is checked once the user is known, and
+	//     before reads can go to the replica, so that a user who has just accepted
+	//     the terms isn't turned away by a lagging replica.
+	//   - Reads are only sent to the replica after authentication, so that the token
+	//     a client has just been given is always found, and the client's recent
+	//     writes can be looked up by user.
+	//   - Body logging runs after both kinds of authentication, as the per-request
+	//     switch depends on the user's permissions.
+	global.
+		UseIf(app.config.http3.enabled, "advertise_http3", app.advertiseHTTP3).
+		Use("request_context", app.requestContext).
+		Use("metrics", app.metrics).
+		Use("request_id", app.requestID).
+		Use("trace_context", app.traceContext).
+		Use("recover_panic", app.recoverPanic).
+		Use("cors", app.enableCORS).
+		Use("request_timeout", app.requestTimeout).
+		UseIf(app.config.abuse.threshold > 0, "detect_abuse", app.detectAbuse).
+		Use("rate_limit", app.rateLimit).
+		Use("limit_concurrency", app.limitConcurrency).
+		Use("authenticate", app.authenticate).
+		UseIf(app.config.signing.enabled, "verify_signature", app.verifySignature).
+		Use("require_tos", app.requireTOSAcceptance).
+		Use("read_consistency", app.readConsistency).
+		Use("log_bodies", app.logBodies)
+
+	// Keep the registry of the public routes for the "GET /v1/meta/routes" endpoint
+	app.routeTable = global.Routes()
+
+	return global.Then(router)
+}
+
+// The adminRoutes() method returns the handler for the admin listener, which only
+// carries the operational endpoints. The listener is meant to be bound to localhost or
+// a private network, so the endpoints don't require authentication.
+func (app *application) adminRoutes() http.Handler {
+	router := httprouter.New()
+
+	router.NotFound = http.HandlerFunc(app.notFoundResponse)
+	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+	router.GlobalOPTIONS = http.HandlerFunc(app.optionsHandler)
+
+	global := newChain().Use("recover_panic", app.recoverPanic)
+
+	root := app.newRouteGroup(router, global)
+
+	app.debugRoutes(root)
+	app.pprofRoutes(root)
+	app.banRoutes(root)
+	app.rateLimitRoutes(root)
+	app.metricsRoutes(root)
+	app.backupRoutes(root)
+	app.operationRoutes(root)
+
+	return global.Then(router)
+}
+
+// Register the expvar and metrics endpoints on the group
+func (app *application) debugRoutes(group *routeGroup) {
+	group.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
+	group.HandlerFunc(http.MethodGet, "/metrics", app.prometheusHandler)
+}
+
+// Register the profiling endpoints on the group, if they have been explicitly enabled.
+// They are only served on the admin listener, which has no write timeout and none of
+// the public port's request timeouts, as CPU profiles and execution traces take as
+// many seconds as they are asked for (30 by default).
+func (app *application) pprofRoutes(group *routeGroup) {
+	if app.config.pprof.enabled {
+		pprof := group.Group("/debug/pprof")
+		pprof.HandlerFunc(http.MethodGet, "/*item", app.pprofHandler)
+		pprof.HandlerFunc(http.MethodPost, "/*item", app.pprofHandler)
+	}
+}
+
+// Register the endpoints for listing and lifting client bans on the group, wrapped
+// with the protect middleware
+func (app *application) banRoutes(group *routeGroup, protect ...middleware) {
+	bans := group.Group("/v1/admin/bans", protect...)
+	bans.HandlerFunc(http.MethodGet, "", app.listBansHandler)
+	bans.HandlerFunc(http.MethodDelete, "/:ip", app.deleteBanHandler)
+}
+
+// Register the endpoints for inspecting and resetting the rate limits of clients on the
+// group, wrapped with the protect middleware
+func (app *application) rateLimitRoutes(group *routeGroup, protect ...middleware) {
+	limits := group.Group("/v1/admin/rate-limits", protect...)
+	limits.HandlerFunc(http.MethodGet, "", app.listRateLimitsHandler)
+	limits.HandlerFunc(http.MethodDelete, "/:key", app.deleteRateLimitHandler)
+}
+
+// Register the endpoints for reading, snapshotting and resetting the metrics on the
+// group, wrapped with the protect middleware
+func (app *application) metricsRoutes(group *routeGroup, protect ...middleware) {
+	metrics := group.Group("/v1/admin/metrics", protect...)
+	metrics.HandlerFunc(http.MethodGet, "", app.showMetricsHandler)
+	metrics.HandlerFunc(http.MethodPost, "/reset", app.resetMetricsHandler)
+	metrics.HandlerFunc(http.MethodGet, "/snapshots", app.listMetricSnapshotsHandler)
+	metrics.HandlerFunc(http.MethodPost, "/snapshots", app.createMetricSnapshotHandler)
+}
+
+// Register the endpoints for backing up the database and listing the backups on the
+// group, wrapped with the protect middleware. They are only registered when backups
+// are enabled.
+func (app *application) backupRoutes(group *routeGroup, protect ...middleware) {
+	if app.backups == nil {
+		return
+	}
+
+	backups := group.Group("/v1/admin/backups", protect...)
+	backups.HandlerFunc(http.MethodGet, "", app.listBackupsHandler)
+	backups.HandlerFunc(http.MethodPost, "", app.createBackupHandler)
+}
+
+// Register the endpoints for running online schema changes and following their
+// progress on the group, wrapped with the protect middleware
+func (app *application) operationRoutes(group *routeGroup, protect ...middleware) {
+	operations := group.Group("/v1/admin/operations", protect...)
+	operations.HandlerFunc(http.MethodGet, "", app.listOperationsHandler)
+	operations.HandlerFunc(http.MethodPost, "", app.createOperationHandler)
+	operations.HandlerFunc(http.MethodGet, "/:id", app.showOperationHandler)
 }
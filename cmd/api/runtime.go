@@ -0,0 +1,99 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+)
+
+// Define the options clients have for how movie runtimes are sent. They are chosen with
+// query parameters or with parameters on the application/json media type in the Accept
+// header.
+type runtimeOptions struct {
+	minutes bool // Add a runtime_minutes number (?runtime_minutes=true or runtime=minutes)
+	iso8601 bool // Send the runtime as an ISO 8601 duration (?duration_format=iso8601 or duration=iso8601)
+}
+
+// Define a movieView type which changes how a movie's runtime is sent. The runtime field
+// takes the place of the movie's own "<runtime> mins" runtime.
+type movieView struct {
+	*data.Movie
+	Runtime        interface{} `json:"runtime,omitempty"`
+	RuntimeMinutes int32       `json:"runtime_minutes,omitempty"`
+}
+
+// Read the runtime options which the client asked for
+func (app *application) readRuntimeOptions(r *http.Request) runtimeOptions {
+	queryString := r.URL.Query()
+
+	options := runtimeOptions{
+		minutes: queryString.Get("runtime_minutes") == "true",
+		iso8601: queryString.Get("duration_format") == "iso8601",
+	}
+
+	for _, accept := range strings.Split(strings.Join(r.Header.Values("Accept"), ","), ",") {
+		mediaType, params, err := mime.ParseMediaType(accept)
+		if err != nil || mediaType != "application/json" {
+			continue
+		}
+
+		options.minutes = options.minutes || params["runtime"] == "minutes"
+		options.iso8601 = options.iso8601 || params["duration"] == "iso8601"
+	}
+
+	return options
+}
+
+// Return the movie with its runtime sent as the options say
+func (o runtimeOptions) view(movie *data.Movie) movieView {
+	view := movieView{Movie: movie}
+
+	// Leave the runtime out like the movie does when it isn't known
+	if movie.Runtime != 0 {
+		view.Runtime = movie.Runtime
+
+		if o.iso8601 {
+			view.Runtime = movie.Runtime.ISO8601()
+		}
+	}
+
+	if o.minutes {
+		view.RuntimeMinutes = int32(movie.Runtime)
+	}
+
+	return view
+}
+
+// Return the value to send for a movie in a response, which is the movie itself unless
+// the client asked for its runtime in another format. The movie isn't modified, as it
+// may be shared with a cache.
+func (app *application) movieResponse(w http.ResponseWriter, r *http.Request, movie *data.Movie) interface{} {
+	// The response depends on the Accept header, so caches must store a copy per value
+	w.Header().Add("Vary", "Accept")
+
+	options := app.readRuntimeOptions(r)
+	if options == (runtimeOptions{}) {
+		return movie
+	}
+
+	return options.view(movie)
+}
+
+// Return the value to send for a list of movies in a response, like movieResponse()
+func (app *application) movieListResponse(w http.ResponseWriter, r *http.Request, movies []*data.Movie) interface{} {
+	w.Header().Add("Vary", "Accept")
+
+	options := app.readRuntimeOptions(r)
+	if options == (runtimeOptions{}) {
+		return movies
+	}
+
+	views := make([]movieView, len(movies))
+	for i, movie := range movies {
+		views[i] = options.view(movie)
+	}
+
+	return views
+}
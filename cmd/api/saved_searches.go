@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// The maximum number of new movies listed in a saved search alert
+const savedSearchAlertLimit = 20
+
+// Define a savedSearchAlertsJob struct for the recurring job which emails users about
+// new movies matching their saved searches. It has no payload, as it goes through
+// every saved search.
+type savedSearchAlertsJob struct{}
+
+func (savedSearchAlertsJob) Kind() string {
+	return "saved_search_alerts"
+}
+
+// Schedule the next run of the saved search alerts job. Only one run is ever pending,
+// however many instances of the application schedule it.
+func (app *application) scheduleSavedSearchAlerts(ctx context.Context, runAt time.Time) error {
+	return app.jobs.Enqueue(ctx, savedSearchAlertsJob{}, jobs.EnqueueOptions{
+		RunAt:  runAt,
+		Unique: true,
+	})
+}
+
+// Email users about the movies added since their saved searches were last checked. The
+// next run is scheduled first, so that the job keeps recurring even if this run fails.
+// Each search records the newest movie it has alerted on, so a retried run carries on
+// where the failed one stopped.
+func (app *application) sendSavedSearchAlerts(ctx context.Context, _ savedSearchAlertsJob) error {
+	err := app.scheduleSavedSearchAlerts(ctx, time.Now().Add(app.config.savedSearches.alertInterval))
+	if err != nil {
+		return err
+	}
+
+	var afterID int64
+
+	for {
+		searches, err := app.models.SavedSearches.GetBatchForAlerts(ctx, afterID, 100)
+		if err != nil {
+			return err
+		}
+
+		if len(searches) == 0 {
+			return nil
+		}
+
+		for _, search := range searches {
+			err = app.sendSavedSearchAlert(ctx, search)
+			if err != nil {
+				return err
+			}
+
+			afterID = search.ID
+		}
+	}
+}
+
+// Email a user about the movies matching a saved search which were added since it was
+// last checked, using the same query as the movie list endpoint
+func (app *application) sendSavedSearchAlert(ctx context.Context, search *data.SavedSearch) error {
+	// Fetch one more movie than we list, to find out if there are more
+	movieSearch := data.MovieSearch{Title: search.Title, Genres: search.Genres, PublishedOnly: true}
+
+	movies, _, err := app.models.Movie.GetAll(ctx, movieSearch, data.NewestMoviesFilters(savedSearchAlertLimit+1))
+	if err != nil {
+		return err
+	}
+
+	var newMovies []*data.Movie
+
+	for _, movie := range movies {
+		if movie.ID > search.LastMovieID {
+			newMovies = append(newMovies, movie)
+		}
+	}
+
+	if len(newMovies) == 0 {
+		return nil
+	}
+
+	more := len(newMovies) > savedSearchAlertLimit
+	if more {
+		newMovies = newMovies[:savedSearchAlertLimit]
+	}
+
+	msg := app.subscriptionMessage(search.UserEmail, "saved_search_alert.tmpl", map[string]interface{}{
+		"name":   search.Name,
+		"movies": newMovies,
+		"more":   more,
+	})
+
+	err = app.sendMessage(ctx, time.Time{}, msg)
+	if err != nil {
+		return err
+	}
+
+	// The movies are sorted newest first
+	return app.models.SavedSearches.SetLastMovieID(ctx, search.ID, newMovies[0].ID)
+}
+
+// Handler for the "POST /v1/users/me/saved-searches" endpoint
+func (app *application) createSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name   string   `json:"name"`
+		Title  string   `json:"title"`
+		Genres []string `json:"genres"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	search := &data.SavedSearch{
+		UserID: app.contextGetUser(r).ID,
+		Name:   input.Name,
+		Title:  input.Title,
+		Genres: input.Genres,
+	}
+
+	// A search without genres matches movies of any genre
+	if search.Genres == nil {
+		search.Genres = []string{}
+	}
+
+	v := validator.New()
+
+	if data.ValidateSavedSearch(v, search); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.SavedSearches.Insert(r.Context(), search)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"saved_search": search}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/users/me/saved-searches" endpoint
+func (app *application) listSavedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	searches, err := app.models.SavedSearches.GetAllForUser(r.Context(), app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"saved_searches": searches}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "DELETE /v1/users/me/saved-searches/:id" endpoint
+func (app *application) deleteSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeSavedSearchNotFound)
+		return
+	}
+
+	// Saved searches belonging to other users are reported as not found
+	err = app.models.SavedSearches.Delete(r.Context(), app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeSavedSearchNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "saved search successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/jobs"
+	"github.com/LuisBarroso37/Greenlight/internal/search"
+)
+
+// The number of movies indexed by each reindexing job
+const reindexBatchSize = 100
+
+// Define a movieIndexer struct holding the search index which movie searches are run
+// against, along with the movies it is built from. The movies mustn't be searched
+// through the index themselves, or an empty index would never be filled.
+type movieIndexer struct {
+	index  search.Index
+	movies data.MovieStore
+}
+
+// Define an indexMovieJob struct holding the payload of a job which brings the document
+// of a movie in the search index up to date after the movie has changed
+type indexMovieJob struct {
+	MovieID int64 `json:"movie_id"`
+}
+
+func (indexMovieJob) Kind() string {
+	return "index_movie"
+}
+
+// Define a reindexMoviesJob struct holding the payload of a job which indexes a page of
+// every movie, ordered by ID, and then queues a job for the next page
+type reindexMoviesJob struct {
+	Page int `json:"page"`
+}
+
+func (reindexMoviesJob) Kind() string {
+	return "reindex_movies"
+}
+
+// The queueMovieIndexing() helper queues a job to index a movie which has changed. It is
+// called for every movie event, so that the search index follows the same changes as
+// the consumers of the events.
+func (app *application) queueMovieIndexing(ctx context.Context, id int64) error {
+	if app.indexer == nil {
+		return nil
+	}
+
+	return app.jobs.Enqueue(ctx, indexMovieJob{MovieID: id}, jobs.EnqueueOptions{})
+}
+
+// Index the current version of a movie, or remove it from the index if it has been
+// deleted or isn't published. Reading the movie again, rather than indexing the data of
+// the event, means that jobs which run out of order or are retried still leave the
+// latest version in the index. Jobs left in the queue after the search index has been
+// turned off do nothing.
+func (app *application) indexMovie(ctx context.Context, job indexMovieJob) error {
+	if app.indexer == nil {
+		return nil
+	}
+
+	movie, err := app.indexer.movies.Get(ctx, job.MovieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.indexer.index.Delete(ctx, job.MovieID)
+		default:
+			return err
+		}
+	}
+
+	if movie.Status != data.MoviePublished {
+		return app.indexer.index.Delete(ctx, movie.ID)
+	}
+
+	return app.indexer.index.Index(ctx, movie)
+}
+
+// Index a page of the published movies, queueing the next page unless this was the last one
+func (app *application) reindexMovies(ctx context.Context, job reindexMoviesJob) error {
+	if app.indexer == nil {
+		return nil
+	}
+
+	filters := data.Filters{
+		Page:         job.Page,
+		PageSize:     reindexBatchSize,
+		Sort:         "id",
+		SortSafelist: []string{"id"},
+	}
+
+	movies, _, err := app.indexer.movies.GetAll(ctx, data.MovieSearch{PublishedOnly: true}, filters)
+	if err != nil {
+		return err
+	}
+
+	err = app.indexer.index.Index(ctx, movies...)
+	if err != nil {
+		return err
+	}
+
+	if len(movies) < reindexBatchSize {
+		return nil
+	}
+
+	return app.jobs.Enqueue(ctx, reindexMoviesJob{Page: job.Page + 1}, jobs.EnqueueOptions{})
+}
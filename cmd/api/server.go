@@ -2,21 +2,29 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/notify"
 )
 
 func (app *application) serve() error {
+	// Build the handler chain once, so that it can be shared between the HTTP/1.1 and
+	// HTTP/2 server and the HTTP/3 server
+	handler := app.routes()
+
 	// Declare a HTTP server
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", app.config.port),
-		Handler: app.routes(),
+		Handler: handler,
 		// Create a new Go log.Logger instance with the log.New() function, passing in
 		// our custom Logger as the first parameter. The "" and 0 indicate that the
 		// log.Logger instance should not use a prefix or any flags.
@@ -26,9 +34,57 @@ func (app *application) serve() error {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	// Create a shutdownError channel. We will use this to receive any errors returned
-	// by the graceful Shutdown() function.
-	shutdownError := make(chan error)
+	// When a certificate is configured, the server speaks TLS. Including "h2" in the
+	// NextProtos means that clients can negotiate HTTP/2 during the TLS handshake.
+	useTLS := app.config.tls.certFile != "" && app.config.tls.keyFile != ""
+
+	if useTLS {
+		server.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			NextProtos: []string{"h2", "http/1.1"},
+		}
+	}
+
+	if app.config.http3.enabled && !useTLS {
+		return errors.New("HTTP/3 requires -tls-cert and -tls-key to be set")
+	}
+
+	if app.config.http3.enabled && strings.HasPrefix(app.config.listen, "unix:") {
+		return errors.New("HTTP/3 can't be served when -listen is a Unix domain socket")
+	}
+
+	// Declare the admin server for the operational endpoints, if one is configured. It
+	// has no write timeout, as CPU profiles and execution traces are only written once
+	// they have been collected for the number of seconds asked for (30 by default for
+	// profiles), and the pprof handlers turn away durations longer than the timeout.
+	var adminServer *http.Server
+
+	if app.config.admin.addr != "" {
+		adminServer = &http.Server{
+			Addr:        app.config.admin.addr,
+			Handler:     app.adminRoutes(),
+			ErrorLog:    log.New(app.logger, "", 0),
+			IdleTimeout: time.Minute,
+			ReadTimeout: 10 * time.Second,
+		}
+	}
+
+	// Start the experimental HTTP/3 listener if it has been enabled
+	var closeHTTP3 func() error
+
+	if app.config.http3.enabled {
+		var err error
+
+		closeHTTP3, err = app.serveHTTP3(handler)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create a shutdownError channel. We will use this to receive the errors of the
+	// graceful shutdown, which are sent together once every step has been run. It is
+	// buffered so that the send never blocks, even if serve() has already returned.
+	shutdownError := make(chan error, 1)
 
 	// Start a background goroutine to catch SIGINT and SIGTERM signals
 	go func() {
@@ -51,6 +107,17 @@ func (app *application) serve() error {
 			"signal": sig.String(),
 		})
 
+		app.notifyOps(notify.Message{
+			Level:  notify.LevelInfo,
+			Title:  "Shutting down",
+			Text:   fmt.Sprintf("Greenlight is shutting down after receiving %s.", sig),
+			Fields: map[string]string{"signal": sig.String()},
+		})
+
+		// Every shutdown step is run even if an earlier one fails, so that the errors
+		// are collected and only sent once the background work has been drained
+		var errs []error
+
 		// Create a context with a 5-second timeout
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -59,12 +126,53 @@ func (app *application) serve() error {
 		// Shutdown() will return nil if the graceful shutdown was successful, or an
 		// error (which may happen because of a problem closing the listeners, or
 		// because the shutdown didn't complete before the 5-second context deadline is
-		// hit). We relay this return value to the shutdownError channel.
+		// hit).
 		err := server.Shutdown(ctx)
 		if err != nil {
-			shutdownError <- err
+			errs = append(errs, err)
 		}
 
+		// Close the HTTP/3 listener. QUIC connections aren't drained gracefully, but any
+		// in-flight requests on them are short compared to the shutdown timeout.
+		if closeHTTP3 != nil {
+			err = closeHTTP3()
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		// Shut the admin server down too, so that no listeners are left open
+		if adminServer != nil {
+			err = adminServer.Shutdown(ctx)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		// The background work gets a 5-second timeout of its own, so that it is still
+		// drained when the servers took all of theirs
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer drainCancel()
+
+		// Let the job workers finish the jobs they are running. Any which don't finish
+		// in time are picked up again after the next start.
+		err = app.jobs.Shutdown(drainCtx)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		// Stop relaying events. Any which haven't been published are left in the outbox
+		// for the next start.
+		if app.events != nil {
+			err = app.events.Shutdown(drainCtx)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		// Tell long-running background tasks to finish up
+		close(app.shutdown)
+
 		// Log a message to say that we're waiting for any background goroutines to
 		// complete their tasks.
 		app.logger.PrintInfo("completing background tasks", map[string]string{
@@ -72,31 +180,76 @@ func (app *application) serve() error {
 		})
 
 		// Call Wait() to block until our WaitGroup counter is zero --- essentially
-		// blocking until the background goroutines have finished. Then we return nil on
-		// the shutdownError channel, to indicate that the shutdown completed without
-		// any issues.
+		// blocking until the background goroutines have finished. Then we send the
+		// errors of the shutdown on the shutdownError channel, which is nil if it
+		// completed without any issues.
 		app.wg.Wait()
-		shutdownError <- nil
+		shutdownError <- errors.Join(errs...)
 	}()
 
+	// Start the admin server in a background goroutine. If it can't start (for example
+	// because the port is already in use) we treat it as fatal, rather than silently
+	// running without the operational endpoints.
+	if adminServer != nil {
+		go func() {
+			app.logger.PrintInfo("Starting admin server", map[string]string{
+				"addr": adminServer.Addr,
+			})
+
+			err := adminServer.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.PrintFatal(err, nil)
+			}
+		}()
+	}
+
+	// Create the listener for the HTTP server. This may be a TCP port, a Unix domain
+	// socket, or a socket handed to us by systemd.
+	listener, err := app.listen()
+	if err != nil {
+		return err
+	}
+
+	server.Addr = listener.Addr().String()
+
 	// Start the HTTP server
 	app.logger.PrintInfo("Starting server", map[string]string{
-		"addr": server.Addr,
-		"env":  app.config.env,
+		"addr":    server.Addr,
+		"network": listener.Addr().Network(),
+		"tls":     strconv.FormatBool(useTLS),
+		"env":     app.config.env,
 	})
 
-	// Calling Shutdown() on our server will cause ListenAndServe() to immediately
+	// Announce the start in the chat webhooks, which also serves as a deploy notice as
+	// the message carries the version
+	build := readBuildMetadata()
+
+	app.notifyOps(notify.Message{
+		Level: notify.LevelInfo,
+		Title: "Started",
+		Text:  fmt.Sprintf("Greenlight is serving requests on %s.", server.Addr),
+		Fields: map[string]string{
+			"version": build.Version,
+			"commit":  build.Commit,
+		},
+	})
+
+	// Calling Shutdown() on our server will cause Serve() to immediately
 	// return a http.ErrServerClosed error. So if we see this error, it is actually a
 	// good thing and an indication that the graceful shutdown has started. So we check
 	// specifically for this, only returning the error if it is NOT http.ErrServerClosed.
-	err := server.ListenAndServe()
+	if useTLS {
+		err = server.ServeTLS(listener, app.config.tls.certFile, app.config.tls.keyFile)
+	} else {
+		err = server.Serve(listener)
+	}
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
-	// Otherwise, we wait to receive the return value from Shutdown() on the
-	// shutdownError channel. If return value is an error, we know that there was a
-	// problem with the graceful shutdown and we return the error.
+	// Otherwise, we wait to receive the errors of the graceful shutdown on the
+	// shutdownError channel. If there were any, we know that there was a problem with
+	// the graceful shutdown and we return them.
 	err = <-shutdownError
 	if err != nil {
 		return err
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Handler for the "POST /v1/users/me/service-accounts" endpoint. The key is only
+// returned here and when it is rotated, as only its hash is stored.
+func (app *application) createServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string           `json:"name"`
+		Permissions data.Permissions `json:"permissions"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	account := &data.ServiceAccount{
+		OwnerID:     user.ID,
+		Name:        input.Name,
+		Permissions: input.Permissions,
+		Active:      true,
+	}
+
+	// The account can only be given permissions its owner holds
+	permissions, err := app.models.Permissions.GetAllForUser(r.Context(), user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	// A token restricted to some permissions can't be used to create a key which can do
+	// more than the token itself
+	data.ValidateServiceAccount(v, account, permissions)
+	data.ValidateTokenAllows(v, "permissions", account.Permissions, user)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	key, err := account.GenerateKey()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.ServiceAccounts.Insert(r.Context(), account)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/users/me/service-accounts/%d", account.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"service_account": account, "key": key}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/users/me/service-accounts" endpoint
+func (app *application) listServiceAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	accounts, err := app.models.ServiceAccounts.GetAllForOwner(r.Context(), app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"service_accounts": accounts}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Fetch the service account named by the id parameter, which must belong to the user
+// making the request. A response has been sent if it returns false.
+func (app *application) readServiceAccount(w http.ResponseWriter, r *http.Request) (*data.ServiceAccount, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeServiceAccountNotFound)
+		return nil, false
+	}
+
+	// Service accounts belonging to other users are reported as not found
+	account, err := app.models.ServiceAccounts.Get(r.Context(), app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeServiceAccountNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return nil, false
+	}
+
+	return account, true
+}
+
+// Handler for the "GET /v1/users/me/service-accounts/:id" endpoint
+func (app *application) showServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	account, ok := app.readServiceAccount(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"service_account": account}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "PATCH /v1/users/me/service-accounts/:id" endpoint. Deactivating an
+// account stops its key from being accepted without deleting it.
+func (app *application) updateServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	account, ok := app.readServiceAccount(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name        *string          `json:"name"`
+		Permissions data.Permissions `json:"permissions"`
+		Active      *bool            `json:"active"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		account.Name = *input.Name
+	}
+
+	if input.Permissions != nil {
+		account.Permissions = input.Permissions
+	}
+
+	if input.Active != nil {
+		account.Active = *input.Active
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(r.Context(), account.OwnerID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	// Permissions the owner has since lost don't need to be removed to change anything
+	// else, as they aren't granted to the account's requests anyway. The permissions
+	// may be shared with the permission cache, so they're copied before appending.
+	if input.Permissions == nil {
+		permissions = append(append(data.Permissions{}, permissions...), account.Permissions...)
+	}
+
+	// The account's permissions must also be within those of a restricted token, even
+	// if they aren't being changed, as the request could reactivate the account
+	data.ValidateServiceAccount(v, account, permissions)
+	data.ValidateTokenAllows(v, "permissions", account.Permissions, app.contextGetUser(r))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.ServiceAccounts.Update(r.Context(), account)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"service_account": account}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "POST /v1/users/me/service-accounts/:id/key" endpoint, which replaces
+// the account's key with a new one. The old key stops working straight away.
+func (app *application) rotateServiceAccountKeyHandler(w http.ResponseWriter, r *http.Request) {
+	account, ok := app.readServiceAccount(w, r)
+	if !ok {
+		return
+	}
+
+	// A restricted token can't be used to get a key which can do more than the token
+	// itself
+	v := validator.New()
+
+	data.ValidateTokenAllows(v, "permissions", account.Permissions, app.contextGetUser(r))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	key, err := account.GenerateKey()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.ServiceAccounts.Update(r.Context(), account)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"service_account": account, "key": key}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "DELETE /v1/users/me/service-accounts/:id" endpoint
+func (app *application) deleteServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.resourceNotFoundResponse(w, r, codeServiceAccountNotFound)
+		return
+	}
+
+	err = app.models.ServiceAccounts.Delete(r.Context(), app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.resourceNotFoundResponse(w, r, codeServiceAccountNotFound)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "service account successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+)
+
+// A token restricted to some of its user's permissions mustn't be usable to create,
+// update or rotate the key of a service account with permissions beyond the token's
+func TestServiceAccountsRespectTokenPermissions(t *testing.T) {
+	models := data.NewMockModels()
+	app := newTestApplication(t, models, "-limiter-enabled=false")
+
+	ts := &testServer{Server: httptest.NewServer(app.routes()), app: app}
+	t.Cleanup(ts.Close)
+
+	ctx := context.Background()
+
+	user := &data.User{Name: "Alice Smith", Email: "alice@example.com", Activated: true}
+
+	err := user.Password.Set("pa55word1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.User.Insert(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Permissions.AddForUser(ctx, user.ID, "movies:read", "movies:write")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unrestricted, err := models.Token.New(ctx, user.ID, time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scoped, err := models.Token.NewWithPermissions(ctx, user.ID, time.Hour, data.ScopeAuthentication, data.Permissions{"movies:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var created struct {
+		Account data.ServiceAccount `json:"service_account"`
+	}
+
+	status := ts.do(t, http.MethodPost, "/v1/users/me/service-accounts", unrestricted.PlainText, map[string]interface{}{
+		"name":        "importer",
+		"permissions": []string{"movies:write"},
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("creating with an unrestricted token: got status %d; want %d", status, http.StatusCreated)
+	}
+
+	path := "/v1/users/me/service-accounts/" + strconv.FormatInt(created.Account.ID, 10)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   map[string]interface{}
+		want   int
+	}{
+		{
+			name:   "create within the token",
+			method: http.MethodPost,
+			path:   "/v1/users/me/service-accounts",
+			body:   map[string]interface{}{"name": "reader", "permissions": []string{"movies:read"}},
+			want:   http.StatusCreated,
+		},
+		{
+			name:   "create beyond the token",
+			method: http.MethodPost,
+			path:   "/v1/users/me/service-accounts",
+			body:   map[string]interface{}{"name": "writer", "permissions": []string{"movies:write"}},
+			want:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "create with a wildcard",
+			method: http.MethodPost,
+			path:   "/v1/users/me/service-accounts",
+			body:   map[string]interface{}{"name": "everything", "permissions": []string{"movies:*"}},
+			want:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "widen an account",
+			method: http.MethodPatch,
+			path:   path,
+			body:   map[string]interface{}{"permissions": []string{"movies:read", "movies:write"}},
+			want:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "reactivate a wider account",
+			method: http.MethodPatch,
+			path:   path,
+			body:   map[string]interface{}{"active": true},
+			want:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "rotate the key of a wider account",
+			method: http.MethodPost,
+			path:   path + "/key",
+			want:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "narrow an account",
+			method: http.MethodPatch,
+			path:   path,
+			body:   map[string]interface{}{"permissions": []string{"movies:read"}},
+			want:   http.StatusOK,
+		},
+		{
+			name:   "rotate the key of an account within the token",
+			method: http.MethodPost,
+			path:   path + "/key",
+			want:   http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := ts.do(t, tt.method, tt.path, scoped.PlainText, tt.body, nil)
+			if status != tt.want {
+				t.Errorf("got status %d; want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestCappedBufferRedactsCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "password", body: `{"password":"pa55word"}`, want: `{"password":"[REDACTED]"}`},
+		{name: "token", body: `{"authentication_token":{"token":"ABC"}}`, want: `{"authentication_token":{"token":"[REDACTED]"}}`},
+		{name: "key", body: `{"key":"gl_sa_secret"}`, want: `{"key":"[REDACTED]"}`},
+		{name: "suffixed key", body: `{"api_key":"secret"}`, want: `{"api_key":"[REDACTED]"}`},
+		{name: "key prefix", body: `{"key_prefix":"gl_sa_ab"}`, want: `{"key_prefix":"gl_sa_ab"}`},
+		{name: "keywords", body: `{"keywords":"heist"}`, want: `{"keywords":"heist"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &cappedBuffer{limit: 1024}
+			b.Write([]byte(tt.body))
+
+			if got := b.String(); got != tt.want {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+)
+
+// The headers carrying a signed request's client key, timestamp and signature
+const (
+	clientKeyHeader = "X-Client-Key"
+	timestampHeader = "X-Timestamp"
+	signatureHeader = "X-Signature"
+)
+
+// The maximum size of a signed request's body, which is read in full to check the
+// signature. This matches the limit applied by readJSON().
+const maxSignedBodyBytes = 1_048_576
+
+// Define a replayCache type which remembers the signatures seen within the timestamp
+// window, so that a captured request can't be sent again while its timestamp is still
+// accepted. Each instance of the application has its own cache, so the window should
+// be kept short.
+type replayCache struct {
+	mutex     sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// Record a signature which is valid until the given time, returning false if it has
+// already been seen
+func (c *replayCache) add(signature string, expires time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+
+	// Remove expired signatures at most once a minute
+	if now.Sub(c.lastSweep) > time.Minute {
+		for key, expiry := range c.seen {
+			if now.After(expiry) {
+				delete(c.seen, key)
+			}
+		}
+
+		c.lastSweep = now
+	}
+
+	if expiry, found := c.seen[signature]; found && now.Before(expiry) {
+		return false
+	}
+
+	c.seen[signature] = expires
+
+	return true
+}
+
+// Return the string which is signed for a request: the method, the path and query,
+// the Unix timestamp and the hex-encoded SHA-256 hash of the body, separated by
+// newlines
+func signingString(r *http.Request, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+
+	return r.Method + "\n" + r.URL.RequestURI() + "\n" + timestamp + "\n" + hex.EncodeToString(bodyHash[:])
+}
+
+// The verifySignature() middleware authenticates partner clients which sign their
+// requests instead of sending a bearer token. A signed request carries the client's
+// key, the current Unix time and the hex-encoded HMAC-SHA256 of the signing string
+// using the client's shared secret. Requests without a signature are left to the
+// authenticate() middleware, which must run before this one.
+func (app *application) verifySignature(next http.Handler) http.Handler {
+	replays := &replayCache{seen: make(map[string]time.Time)}
+	window := app.config.signing.window
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", signatureHeader)
+
+		signature := r.Header.Get(signatureHeader)
+		if signature == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// A request must be authenticated in only one way
+		if r.Header.Get("Authorization") != "" {
+			app.invalidSignatureResponse(w, r)
+			return
+		}
+
+		// Reject timestamps outside the window in either direction, allowing for clock
+		// skew between the client and the server
+		timestamp := r.Header.Get(timestampHeader)
+
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			app.invalidSignatureResponse(w, r)
+			return
+		}
+
+		signedAt := time.Unix(seconds, 0)
+		if age := time.Since(signedAt); age > window || age < -window {
+			app.invalidSignatureResponse(w, r)
+			return
+		}
+
+		expected, err := hex.DecodeString(signature)
+		if err != nil {
+			app.invalidSignatureResponse(w, r)
+			return
+		}
+
+		client, user, err := app.models.Clients.GetForKey(r.Context(), r.Header.Get(clientKeyHeader))
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidSignatureResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		// Read the body to sign it, then replace it so that the handler can read it too
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodyBytes))
+		if err != nil {
+			switch {
+			case err.Error() == "http: request body too large":
+				app.bodyTooLargeResponse(w, r, &bodyTooLargeError{subject: "signed body", maxBytes: maxSignedBodyBytes})
+			default:
+				app.badRequestResponse(w, r, err)
+			}
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		mac := hmac.New(sha256.New, client.Secret)
+		mac.Write([]byte(signingString(r, timestamp, body)))
+
+		if !hmac.Equal(mac.Sum(nil), expected) {
+			app.invalidSignatureResponse(w, r)
+			return
+		}
+
+		// Only remember signatures which were valid, so that invalid requests can't
+		// fill up the cache
+		if !replays.add(client.Key+":"+signature, signedAt.Add(window)) {
+			app.invalidSignatureResponse(w, r)
+			return
+		}
+
+		// Requests signed by a partner client are made for that client's tenant
+		r = app.contextSetTenant(app.contextSetUser(r, user), client.Key)
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// The most URLs a single sitemap may list, according to the sitemap protocol. Larger
+// catalogs are split into several sitemaps, listed by a sitemap index.
+const sitemapMaxURLs = 50000
+
+// The XML namespace of sitemaps and sitemap indexes
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Define the structs encoded as a sitemap, which lists the URLs of the movies
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// Define the structs encoded as a sitemap index, which lists the pages of the sitemap
+type sitemapRef struct {
+	Loc string `xml:"loc"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapRef `xml:"sitemap"`
+}
+
+// Handler for the "GET /sitemap.xml" endpoint, which lists the URL of every movie for
+// web crawlers. Catalogs of up to 50,000 movies are listed in a single sitemap. Larger
+// catalogs get a sitemap index instead, pointing at the pages of the sitemap, which
+// are served from the same endpoint with a page parameter.
+func (app *application) sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	page := app.readInt(r.URL.Query(), "page", 0, v)
+
+	v.Check(page >= 0, "page", "must be greater than or equal to zero", validator.CodeTooSmall, validator.Params{"min": 0})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	offset := 0
+	if page > 0 {
+		offset = (page - 1) * sitemapMaxURLs
+	}
+
+	timestamps, totalRecords, err := app.models.Movie.GetTimestamps(r.Context(), offset, sitemapMaxURLs)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var doc interface{}
+
+	switch {
+	case page == 0 && totalRecords > sitemapMaxURLs:
+		index := sitemapIndex{XMLNS: sitemapNamespace}
+
+		for i := 1; (i-1)*sitemapMaxURLs < totalRecords; i++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapRef{Loc: app.config.catalog.url + "/sitemap.xml?page=" + strconv.Itoa(i)})
+		}
+
+		doc = index
+	case page > 0 && len(timestamps) == 0:
+		app.notFoundResponse(w, r)
+		return
+	default:
+		urlSet := sitemapURLSet{XMLNS: sitemapNamespace, URLs: make([]sitemapURL, 0, len(timestamps))}
+
+		for _, timestamp := range timestamps {
+			urlSet.URLs = append(urlSet.URLs, app.movieSitemapURL(timestamp))
+		}
+
+		doc = urlSet
+	}
+
+	body, err := xml.Marshal(doc)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Crawlers fetch the sitemap rarely, and don't need it to be up to the minute
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+// Return the sitemap entry of a movie
+func (app *application) movieSitemapURL(timestamp *data.MovieTimestamp) sitemapURL {
+	return sitemapURL{
+		Loc:     fmt.Sprintf("%s/v1/movies/%d", app.config.catalog.url, timestamp.ID),
+		LastMod: timestamp.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/hits"
+	"github.com/LuisBarroso37/Greenlight/internal/logger"
+	"github.com/LuisBarroso37/Greenlight/internal/mailer"
+	"github.com/LuisBarroso37/Greenlight/internal/moderation"
+)
+
+// Return an application using the given models, configured with the flag defaults
+// overridden by the given command-line arguments. Log entries are discarded and emails
+// are kept by a Recorder instead of being sent. The job queue isn't set up, as it needs
+// a database.
+func newTestApplication(t *testing.T, models data.Models, args ...string) *application {
+	t.Helper()
+
+	var cfg config
+
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+	defineFlags(fs, &cfg)
+
+	err := fs.Parse(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		config:      cfg,
+		logger:      logger.New(io.Discard, logger.LevelInfo),
+		models:      models,
+		mailer:      &mailer.Recorder{},
+		bans:        newMemoryBanStore(),
+		limiter:     newMemoryLimiter(cfg.limiter.rps, cfg.limiter.burst),
+		hits:        hits.New(cfg.trending.window, 1024),
+		movieLists:  newMovieListCache(),
+		suggestions: newSuggestionCache(cfg.autocomplete.cacheTTL, cfg.autocomplete.cacheSize),
+		adminStats:  &adminStatsCache{},
+		sampler:     newRateSampler(cfg.sampler.window),
+		shutdown:    make(chan struct{}),
+	}
+
+	app.screener = moderation.NewWordScreener(moderation.DefaultWords...)
+	app.emailDomains = data.NewEmailDomainPolicy(cfg.registration.allowedDomains, cfg.registration.deniedDomains, cfg.registration.blockDisposable)
+	app.mailLimiter = mailer.NewRecipientLimiter(cfg.smtp.perRecipient, time.Hour)
+
+	if cfg.guest.enabled {
+		app.guests = newMemoryLimiter(cfg.guest.rps, cfg.guest.burst)
+		app.guestTokens = newMemoryLimiter(cfg.guest.tokenRPS, cfg.guest.tokenBurst)
+	}
+
+	t.Cleanup(app.hits.Close)
+
+	return app
+}
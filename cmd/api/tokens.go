@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"time"
 
 	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/mailer"
 	"github.com/LuisBarroso37/Greenlight/internal/validator"
+	"github.com/tomasen/realip"
 )
 
 func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -24,18 +27,18 @@ func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *h
 	v := validator.New()
 
 	if data.ValidateEmail(v, input.Email); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	// Try to retrieve the corresponding user record for the email address. If it can't
 	// be found, return an error message to the client
-	user, err := app.models.User.GetByEmail(input.Email)
+	user, err := app.models.User.GetByEmail(r.Context(), input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			v.AddError("email", "no matching email address found")
-			app.failedValidationResponse(w, r, v.Errors)
+			v.AddError("email", "no matching email address found", validator.CodeNotFound)
+			app.failedValidationResponse(w, r, v)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -45,29 +48,40 @@ func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *h
 
 	// Return an error if the user has already been activated
 	if user.Activated {
-		v.AddError("email", "user has already been activated")
-		app.failedValidationResponse(w, r, v.Errors)
+		v.AddError("email", "user has already been activated", validator.CodeInvalidState)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
-	// Otherwise, create a new activation token
-	token, err := app.models.Token.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
+	// Refuse to email the address again if it has already been sent too many emails, before
+	// its earlier tokens are replaced
+	if !app.allowEmail(w, r, user.Email) {
+		return
+	}
+
+	// Otherwise, replace any earlier activation tokens with a new one, so that only the
+	// latest email can be used
+	err = app.models.Token.DeleteAllForUser(r.Context(), data.ScopeActivation, user.ID)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	// Email the user with their additional activation token
-	app.background(func() {
-		data := map[string]interface{}{
-			"activationToken": token.PlainText,
-		}
+	token, err := app.models.Token.New(r.Context(), user.ID, app.config.tokens.activationTTL, data.ScopeActivation)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
-		err := app.mailer.Send(user.Email, "token_activation.tmpl", data)
-		if err != nil {
-			app.logger.PrintError(err, nil)
-		}
+	// Email the user with their additional activation token
+	err = app.sendEmail(r.Context(), user.Email, "token_activation.tmpl", map[string]interface{}{
+		"activationToken": token.PlainText,
+		"expiresIn":       humanDuration(app.config.tokens.activationTTL),
 	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
 	// Send a 202 Accepted response and confirmation message to the client
 	env := envelope{"message": "an email will be sent to you containing activation instructions"}
@@ -94,18 +108,18 @@ func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r
 	v := validator.New()
 
 	if data.ValidateEmail(v, input.Email); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	// Try to retrieve the corresponding user record for the email address. If it can't
 	// be found, return an error message to the client.
-	user, err := app.models.User.GetByEmail(input.Email)
+	user, err := app.models.User.GetByEmail(r.Context(), input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			v.AddError("email", "no matching email address found")
-			app.failedValidationResponse(w, r, v.Errors)
+			v.AddError("email", "no matching email address found", validator.CodeNotFound)
+			app.failedValidationResponse(w, r, v)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -115,32 +129,34 @@ func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r
 
 	// Return an error message if the user is not activated
 	if !user.Activated {
-		v.AddError("email", "user account must be activated")
-		app.failedValidationResponse(w, r, v.Errors)
+		v.AddError("email", "user account must be activated", validator.CodeInvalidState)
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// Refuse to email the address again if it has already been sent too many emails
+	if !app.allowEmail(w, r, user.Email) {
 		return
 	}
 
 	// Otherwise, create a new password reset token with a 45-minute expiry time
-	token, err := app.models.Token.New(user.ID, 45*time.Minute, data.ScopePasswordReset)
+	token, err := app.models.Token.New(r.Context(), user.ID, 45*time.Minute, data.ScopePasswordReset)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	// Email the user with their password reset token
-	app.background(func() {
-		data := map[string]interface{}{
-			"passwordResetToken": token.PlainText,
-		}
-
-		// Since email addresses MAY be case sensitive, notice that we are sending this
-		// email using the address stored in our database for the user --- not to the
-		// input.Email address provided by the client in this request.
-		err = app.mailer.Send(user.Email, "token_password_reset.tmpl", data)
-		if err != nil {
-			app.logger.PrintError(err, nil)
-		}
+	// Email the user with their password reset token. Since email addresses MAY be case
+	// sensitive, notice that we are sending this email using the address stored in our
+	// database for the user --- not to the input.Email address provided by the client
+	// in this request.
+	err = app.sendEmail(r.Context(), user.Email, "token_password_reset.tmpl", map[string]interface{}{
+		"passwordResetToken": token.PlainText,
 	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
 	// Send a 202 Accepted response and confirmation message to the client
 	env := envelope{"message": "an email will be sent to you containing password reset instructions"}
@@ -153,10 +169,13 @@ func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r
 
 // Exchange user's credentials for an authentication token
 func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse the email and password from the request body
+	// Parse the email and password from the request body, along with the optional
+	// permissions to restrict the token to and its expiry
 	var input struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string           `json:"email"`
+		Password string           `json:"password"`
+		Scopes   data.Permissions `json:"scopes"`
+		Expiry   *time.Time       `json:"expiry"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -171,14 +190,25 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 	data.ValidateEmail(v, input.Email)
 	data.ValidatePassword(v, input.Password)
 
+	// Tokens last for the configured lifetime, unless the client asks for them to
+	// expire sooner
+	ttl := app.config.tokens.authenticationTTL
+
+	if input.Expiry != nil {
+		ttl = time.Until(*input.Expiry)
+
+		v.Check(ttl > 0, "expiry", "must be in the future", validator.CodeTooEarly)
+		v.Check(ttl <= app.config.tokens.authenticationTTL, "expiry", "must be no more than "+humanDuration(app.config.tokens.authenticationTTL)+" from now", validator.CodeTooLate, validator.Params{"max": time.Now().Add(app.config.tokens.authenticationTTL)})
+	}
+
 	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	// Lookup the user record based on the email address. If no matching user was
 	// found, then we send a 401 Unauthorized response to the client
-	user, err := app.models.User.GetByEmail(input.Email)
+	user, err := app.models.User.GetByEmail(r.Context(), input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -203,9 +233,32 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		return
 	}
 
-	// Otherwise, if the password is correct, we generate a new token with a 24-hour
-	// expiry time and the scope 'authentication'
-	token, err := app.models.Token.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	// A token can only be restricted to permissions the user holds. The scopes are
+	// checked once the credentials have been, so that they don't reveal anything about
+	// the account to someone without the password.
+	if input.Scopes != nil {
+		permissions, err := app.models.Permissions.GetAllForUser(r.Context(), user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if data.ValidateTokenPermissions(v, input.Scopes, permissions); !v.Valid() {
+			app.failedValidationResponse(w, r, v)
+			return
+		}
+	}
+
+	// Otherwise, if the password is correct, we generate a new token with the scope
+	// 'authentication', restricted to the requested permissions if there are any
+	token, err := app.models.Token.NewWithPermissions(r.Context(), user.ID, ttl, data.ScopeAuthentication, input.Scopes)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Let the user know if they signed in from a device they haven't used before
+	err = app.recordSignIn(r.Context(), user, r.UserAgent(), realip.FromRequest(r))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -218,3 +271,51 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// The recordSignIn() helper records the device a user signed in from, and notifies them
+// of a sign-in from a device they haven't signed in from before. The notification is
+// sent by email unless the user has turned it off in their notification preferences.
+func (app *application) recordSignIn(ctx context.Context, user *data.User, userAgent, ip string) error {
+	device := &data.Device{
+		UserID:      user.ID,
+		Fingerprint: data.DeviceFingerprint(userAgent, ip),
+		UserAgent:   userAgent,
+	}
+
+	isNew, err := app.models.Devices.Record(ctx, device)
+	if err != nil || !isNew {
+		return err
+	}
+
+	if userAgent == "" {
+		userAgent = "an unknown device"
+	}
+
+	return app.notify(ctx, user, data.NotificationNewSignIn,
+		"New sign-in to your account",
+		"Your Greenlight account was signed in to from "+userAgent+" at IP address "+ip+". If this wasn't you, please reset your password.",
+		map[string]interface{}{"user_agent": device.UserAgent, "ip_address": ip})
+}
+
+// The allowEmail() helper checks the recipient against the per-recipient limiter, so
+// that the token endpoints tell the client when an address has already been sent too
+// many emails rather than queueing one which would be dropped. It sends an error
+// response and returns false if so. The email itself is counted when the mailer sends
+// it.
+func (app *application) allowEmail(w http.ResponseWriter, r *http.Request, recipient string) bool {
+	err := app.mailLimiter.Check(recipient)
+	if err != nil {
+		var limitErr *mailer.RecipientLimitError
+
+		switch {
+		case errors.As(err, &limitErr):
+			app.emailRateLimitedResponse(w, r, limitErr)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return false
+	}
+
+	return true
+}
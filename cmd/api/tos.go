@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Define a tosAcceptanceCache type which remembers the users known to have accepted the
+// current terms of service, so that the check doesn't hit the database on every
+// request. Acceptances are never withdrawn, and a new version means a new instance of
+// the application, so entries never go stale.
+type tosAcceptanceCache struct {
+	mutex sync.RWMutex
+	users map[int64]struct{}
+}
+
+// Return a new, empty tosAcceptanceCache
+func newTOSAcceptanceCache() *tosAcceptanceCache {
+	return &tosAcceptanceCache{users: make(map[int64]struct{})}
+}
+
+// Report whether the user is known to have accepted the terms
+func (c *tosAcceptanceCache) accepted(userID int64) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	_, found := c.users[userID]
+
+	return found
+}
+
+// Record that the user has accepted the terms
+func (c *tosAcceptanceCache) add(userID int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.users[userID] = struct{}{}
+}
+
+// The requireTOSAcceptance() middleware turns away the requests of authenticated users
+// who haven't accepted the current version of the terms of service, telling them which
+// version to accept. Service accounts act under their owner's acceptance, and admins
+// impersonating a user aren't stopped, as they can't accept the terms for the user. It
+// does nothing when no terms of service version is configured.
+func (app *application) requireTOSAcceptance(next http.Handler) http.Handler {
+	if app.tosAccepted == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if user.IsAnonymous() || user.IsImpersonated() || app.contextGetServiceAccount(r) != nil || app.tosAccepted.accepted(user.ID) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		accepted, err := app.models.TOSAcceptances.HasAccepted(r.Context(), user.ID, app.config.tos.version)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !accepted {
+			app.tosNotAcceptedResponse(w, r)
+			return
+		}
+
+		app.tosAccepted.add(user.ID)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Handler for the "POST /v1/users/me/tos-acceptance" endpoint, which records that the
+// user accepts the current version of the terms of service. The client sends the
+// version it showed the user, so that an acceptance of terms which have changed in the
+// meantime isn't recorded.
+func (app *application) createTOSAcceptanceHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Version string `json:"version"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.Version != "", "version", "must be provided", validator.CodeRequired)
+	v.Check(input.Version == "" || input.Version == app.config.tos.version, "version", "must be the current terms of service version", validator.CodeNotOneOf, validator.Params{"allowed": []string{app.config.tos.version}})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	acceptance := &data.TOSAcceptance{UserID: user.ID, Version: input.Version}
+
+	err = app.models.TOSAcceptances.Accept(r.Context(), acceptance)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.tosAccepted.add(user.ID)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"tos_acceptance": acceptance}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Define a cached movie list along with the time at which it expires
+type movieListEntry struct {
+	movies  []*data.Movie
+	expires time.Time
+}
+
+// Define a movieListCache type which keeps the trending and recent movie lists in
+// memory for a short while. Home screens request these lists on every load, and they
+// don't need to be up to the second.
+type movieListCache struct {
+	mutex   sync.Mutex
+	entries map[string]movieListEntry
+}
+
+// Return a new, empty movieListCache
+func newMovieListCache() *movieListCache {
+	return &movieListCache{entries: make(map[string]movieListEntry)}
+}
+
+// Return the cached list for the key, calling fetch and caching its result for the
+// given ttl if there isn't a fresh one. Errors are never cached.
+func (c *movieListCache) get(key string, ttl time.Duration, fetch func() ([]*data.Movie, error)) ([]*data.Movie, error) {
+	c.mutex.Lock()
+	entry, found := c.entries[key]
+	c.mutex.Unlock()
+
+	if found && time.Now().Before(entry.expires) {
+		return entry.movies, nil
+	}
+
+	movies, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl > 0 {
+		c.mutex.Lock()
+		c.entries[key] = movieListEntry{movies: movies, expires: time.Now().Add(ttl)}
+		c.mutex.Unlock()
+	}
+
+	return movies, nil
+}
+
+// Handler for the "GET /v1/movies/:id" endpoint. httprouter doesn't allow static
+// segments such as /v1/movies/trending alongside the :id parameter, so the named movie
+// lists are dispatched from here.
+func (app *application) showMovieOrListHandler(w http.ResponseWriter, r *http.Request) {
+	switch httprouter.ParamsFromContext(r.Context()).ByName("id") {
+	case "trending":
+		app.trendingMoviesHandler(w, r)
+	case "recent":
+		app.recentMoviesHandler(w, r)
+	case "autocomplete":
+		app.autocompleteMoviesHandler(w, r)
+	case "_meta":
+		app.movieListingHandler(w, r)
+	default:
+		app.showMovieHandler(w, r)
+	}
+}
+
+// Read and validate the limit query string parameter used by the movie lists
+func (app *application) readListLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := validator.New()
+
+	limit := app.readInt(r.URL.Query(), "limit", 10, v)
+
+	v.Check(limit > 0, "limit", "must be greater than zero", validator.CodeTooSmall, validator.Params{"min": 1})
+	v.Check(limit <= 50, "limit", "must be a maximum of 50", validator.CodeTooLarge, validator.Params{"max": 50})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return 0, false
+	}
+
+	return limit, true
+}
+
+// Write a cached movie list, letting the client cache it for as long as we do
+func (app *application) writeMovieList(w http.ResponseWriter, r *http.Request, movies []*data.Movie) {
+	headers := make(http.Header)
+	headers.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(app.config.cache.listTTL.Seconds())))
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"movies": app.movieListResponse(w, r, movies)}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Handler for the "GET /v1/movies/trending" endpoint. Movies are ranked by the number of
+// times they were shown within the trending window. Views are counted separately by
+// each instance of the API, so the ranking reflects the traffic this instance served.
+func (app *application) trendingMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	limit, ok := app.readListLimit(w, r)
+	if !ok {
+		return
+	}
+
+	movies, err := app.movieLists.get("trending:"+strconv.Itoa(limit), app.config.cache.listTTL, func() ([]*data.Movie, error) {
+		movies := []*data.Movie{}
+
+		for _, count := range app.hits.Top(limit) {
+			movie, err := app.models.Movie.Get(r.Context(), count.ID)
+			if err != nil {
+				// Skip movies which have been deleted since they were viewed
+				if errors.Is(err, data.ErrRecordNotFound) {
+					continue
+				}
+
+				return nil, err
+			}
+
+			// The list is shared by every user, so it only includes published movies.
+			// Editors' views of unpublished movies are counted all the same.
+			if movie.Status != data.MoviePublished {
+				continue
+			}
+
+			movies = append(movies, movie)
+		}
+
+		return movies, nil
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeMovieList(w, r, movies)
+}
+
+// Handler for the "GET /v1/movies/recent" endpoint, which lists the most recently added
+// movies
+func (app *application) recentMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	limit, ok := app.readListLimit(w, r)
+	if !ok {
+		return
+	}
+
+	movies, err := app.movieLists.get("recent:"+strconv.Itoa(limit), app.config.cache.listTTL, func() ([]*data.Movie, error) {
+		movies, _, err := app.models.Movie.GetAll(r.Context(), data.MovieSearch{PublishedOnly: true}, data.NewestMoviesFilters(limit))
+
+		return movies, err
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeMovieList(w, r, movies)
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/LuisBarroso37/Greenlight/internal/data"
 	"github.com/LuisBarroso37/Greenlight/internal/validator"
@@ -12,9 +13,10 @@ import (
 func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	// Create an anonymous struct to hold the expected data from the request body
 	var input struct {
-		Name     string `json:"name"`
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Name       string `json:"name"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		Invitation string `json:"invitation"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -41,18 +43,65 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 	// Validate the user struct and return the error messages to the client if any of
 	// the checks fail
-	if data.ValidateUser(v, user); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+	data.ValidateUser(v, user)
+
+	// With closed registration an invitation code is required
+	if app.config.registration.closed {
+		v.Check(input.Invitation != "", "invitation", "must be provided", validator.CodeRequired)
+	}
+
+	// Invited users can register with any email domain, since they were invited by a
+	// user holding the invitations:write permission
+	if input.Invitation != "" {
+		data.ValidateTokenPlainText(v, input.Invitation)
+	} else if v.Valid() {
+		data.ValidateEmailDomain(v, user.Email, app.emailDomains)
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
-	// Insert the user data into the database
-	err = app.models.User.Insert(user)
+	// The invitation must be pending and sent to the email address the user registers
+	// with. Since that proves the user owns the email address, invited users are
+	// activated straight away.
+	var invitation *data.Invitation
+
+	if input.Invitation != "" {
+		invitation, err = app.models.Invitations.GetPendingForCode(r.Context(), input.Invitation)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if invitation == nil || !strings.EqualFold(invitation.Email, user.Email) {
+			v.AddError("invitation", "invalid or expired invitation code", validator.CodeInvalid)
+			app.failedValidationResponse(w, r, v)
+			return
+		}
+
+		user.Activated = true
+	}
+
+	// Insert the user data into the database, along with the "movies:read" permission
+	// for the new user. Invited users are already activated, which the event's data
+	// shows.
+	err = app.saveWithEvent(r, func(ctx context.Context) (domainEvent, error) {
+		err := app.models.User.Insert(ctx, user)
+		if err != nil {
+			return domainEvent{}, err
+		}
+
+		err = app.models.Permissions.AddForUser(ctx, user.ID, "movies:read")
+
+		return domainEvent{Type: eventUserRegistered, ID: user.ID, Data: newUserEvent(user)}, err
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
-			v.AddError("email", "a user with this email address already exists")
-			app.failedValidationResponse(w, r, v.Errors)
+			v.AddError("email", "a user with this email address already exists", validator.CodeAlreadyExists)
+			app.failedValidationResponse(w, r, v)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -60,33 +109,41 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Add the "movies:read" permission for the new user
-	err = app.models.Permissions.AddForUser(user.ID, "movies:read")
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
+	// An invited user has been activated, so the invitation only needs to be marked as
+	// accepted
+	if invitation != nil {
+		err = app.models.Invitations.Accept(r.Context(), invitation.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusCreated, envelope{"user": user}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+
 		return
 	}
 
 	// After the user record has been created in the database, generate a new activation
 	// token for the user
-	token, err := app.models.Token.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
+	token, err := app.models.Token.New(r.Context(), user.ID, app.config.tokens.activationTTL, data.ScopeActivation)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	// Launch a goroutine which runs an anonymous function that sends the welcome email
-	app.background(func() {
-		data := map[string]interface{}{
-			"activationToken": token.PlainText,
-			"userID":          user.ID,
-		}
-
-		err = app.mailer.Send(user.Email, "user_welcome.tmpl", data)
-		if err != nil {
-			app.logger.PrintError(err, nil)
-		}
+	// Queue the welcome email, which is sent by a job worker
+	err = app.sendEmail(r.Context(), user.Email, "user_welcome.tmpl", map[string]interface{}{
+		"activationToken": token.PlainText,
+		"userID":          user.ID,
+		"expiresIn":       humanDuration(app.config.tokens.activationTTL),
 	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
 	// Write a JSON response containing the user data along with a 202 Accepted status code.
 	// This status code indicates that the request has been accepted for processing, but
@@ -113,18 +170,20 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	v := validator.New()
 
 	if data.ValidateTokenPlainText(v, input.TokenPlainText); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	// Retrieve the details of the user associated with the token. If no matching record
 	// is found, then we let the client know that the token they provided is not valid.
-	user, err := app.models.User.GetForToken(data.ScopeActivation, input.TokenPlainText)
+	user, err := app.models.User.GetForToken(r.Context(), data.ScopeActivation, input.TokenPlainText)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			v.AddError("token", "invalid or expired activation token")
-			app.failedValidationResponse(w, r, v.Errors)
+			v.AddError("token", "invalid or expired activation token", validator.CodeInvalid)
+			app.failedValidationResponse(w, r, v)
+		case errors.Is(err, data.ErrTokenExpired):
+			app.expiredTokenResponse(w, r, data.ScopeActivation)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -135,8 +194,18 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	// Update the user's activation status.
 	user.Activated = true
 
-	// Save the updated user record in our database, checking for any edit conflicts
-	err = app.models.User.Update(user)
+	// Save the updated user record in our database, checking for any edit conflicts. If
+	// everything went successfully, then we delete all activation tokens for the user.
+	err = app.saveWithEvent(r, func(ctx context.Context) (domainEvent, error) {
+		err := app.models.User.Update(ctx, user)
+		if err != nil {
+			return domainEvent{}, err
+		}
+
+		err = app.models.Token.DeleteAllForUser(ctx, data.ScopeActivation, user.ID)
+
+		return domainEvent{Type: eventUserActivated, ID: user.ID, Data: newUserEvent(user)}, err
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -148,11 +217,13 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// If everything went successfully, then we delete all activation tokens for the
-	// user
-	err = app.models.Token.DeleteAllForUser(data.ScopeActivation, user.ID)
+	err = app.notify(r.Context(), user, data.NotificationAccountActivated,
+		"Your account is active",
+		"Your Greenlight account has been activated. Welcome aboard!",
+		nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
 	}
 
 	// Send the updated user details to the client in a JSON response.
@@ -164,6 +235,12 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 
 // Verify the password reset token and set a new password for the user
 func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	// Admins acting as a user can't change the user's password, even with a reset token
+	if user := app.contextLookupUser(r); user != nil && user.IsImpersonated() {
+		app.impersonationNotPermittedResponse(w, r)
+		return
+	}
+
 	// Parse and validate the user's new password and password reset token
 	var input struct {
 		Password       string `json:"password"`
@@ -182,18 +259,20 @@ func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http
 	data.ValidateTokenPlainText(v, input.TokenPlainText)
 
 	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	// Retrieve the details of the user associated with the password reset token,
 	// returning an error message if no matching record was found
-	user, err := app.models.User.GetForToken(data.ScopePasswordReset, input.TokenPlainText)
+	user, err := app.models.User.GetForToken(r.Context(), data.ScopePasswordReset, input.TokenPlainText)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			v.AddError("token", "invalid or expired password reset token")
-			app.failedValidationResponse(w, r, v.Errors)
+			v.AddError("token", "invalid or expired password reset token", validator.CodeInvalid)
+			app.failedValidationResponse(w, r, v)
+		case errors.Is(err, data.ErrTokenExpired):
+			app.expiredTokenResponse(w, r, data.ScopePasswordReset)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -202,7 +281,7 @@ func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http
 	}
 
 	// Save the updated user record in our database, checking for any edit conflicts as normal
-	err = app.models.User.Update(user)
+	err = app.models.User.Update(r.Context(), user)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -214,7 +293,17 @@ func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http
 	}
 
 	// If everything was successful, then delete all password reset tokens for the user
-	err = app.models.Token.DeleteAllForUser(data.ScopePasswordReset, user.ID)
+	err = app.models.Token.DeleteAllForUser(r.Context(), data.ScopePasswordReset, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Let the user know in case they didn't request the reset themselves
+	err = app.notify(r.Context(), user, data.NotificationPasswordReset,
+		"Your password was reset",
+		"The password for your Greenlight account was just reset. If you didn't do this, please reset it again and contact us.",
+		nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
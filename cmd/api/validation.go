@@ -0,0 +1,71 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/reqctx"
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Publish the number of requests which the validation rules run in shadow mode would
+// have rejected, by rule, so that the effect of enforcing a rule can be measured first
+var validationShadowFailures = expvar.NewMap("validation_shadow_failures")
+
+// The newValidator() method returns a new validator with the modes of the validation
+// rules being rolled out read from the request's feature flags. A rule listed in both
+// -validation-shadow and -validation-enforce is enforced.
+func (app *application) newValidator(r *http.Request) *validator.Validator {
+	v := validator.New()
+	v.Modes = make(map[string]validator.RuleMode)
+
+	flags := app.contextGetFlags(r)
+
+	for _, rule := range data.ValidationRules {
+		switch {
+		case flags["validation_enforce."+rule]:
+			v.Modes[rule] = validator.RuleEnforce
+		case flags["validation_shadow."+rule]:
+			v.Modes[rule] = validator.RuleShadow
+		}
+	}
+
+	return v
+}
+
+// The recordShadowFailures() method logs and counts the failures of the rules run in
+// shadow mode by a validator created with newValidator(). Handlers defer it straight
+// after creating the validator, so that the failures are recorded whether or not the
+// request passes the enforced checks.
+func (app *application) recordShadowFailures(r *http.Request, v *validator.Validator) {
+	for _, failure := range v.Shadowed {
+		validationShadowFailures.Add(failure.Rule, 1)
+
+		properties := reqctx.FromContext(r.Context()).LogProperties()
+		properties["rule"] = failure.Rule
+		properties["field"] = failure.Key
+		properties["code"] = failure.Error.Code
+		properties["message"] = failure.Error.Message
+		properties["request_method"] = r.Method
+		properties["request_url"] = r.URL.Path
+
+		app.logger.PrintInfo("validation rule in shadow mode failed", properties)
+	}
+}
+
+// Parse a space separated list of the validation rules being rolled out, returning an
+// error for unknown rules
+func parseValidationRules(val string) ([]string, error) {
+	rules := strings.Fields(val)
+
+	for _, rule := range rules {
+		if !validator.In(rule, data.ValidationRules...) {
+			return nil, fmt.Errorf("unknown validation rule %q", rule)
+		}
+	}
+
+	return rules, nil
+}
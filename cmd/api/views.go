@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// The flushViews() method writes the movie views counted since the last flush to the
+// database. If that fails, the views are kept and written by the next flush.
+func (app *application) flushViews() {
+	views := app.hits.Drain()
+	if len(views) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := app.models.MovieStats.AddViews(ctx, views)
+	if err != nil {
+		app.hits.Restore(views)
+		app.logger.PrintError(err, map[string]string{"component": "movie_views"})
+	}
+}
+
+// The startViewFlusher() method periodically writes the movie views to the database in
+// a background task, rather than updating a movie's counter on every request. The
+// views counted since the last flush are written once more when the application shuts
+// down.
+func (app *application) startViewFlusher(interval time.Duration) {
+	app.background("flush_movie_views", func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				app.flushViews()
+			case <-app.shutdown:
+				app.flushViews()
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Define a route struct holding an entry of the API's route registry
+type route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// Fetch the route registry from a running API and report the endpoint definitions
+// which don't match any of its routes, and the v1 routes which have no endpoint
+// definition. The registry only lists the routes of the features enabled on that API,
+// so run it against an instance with every feature enabled.
+func checkEndpoints(url string) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	res, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching the routes: unexpected status %d", res.StatusCode)
+	}
+
+	var registry struct {
+		Routes []route `json:"routes"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&registry)
+	if err != nil {
+		return fmt.Errorf("decoding the routes: %w", err)
+	}
+
+	var problems []string
+
+	defined := make(map[route]bool)
+
+	for _, e := range endpoints {
+		matched := false
+
+		for _, r := range registry.Routes {
+			if r.Method == e.Method && matchPattern(r.Path, e.Path) {
+				defined[r] = true
+				matched = true
+			}
+		}
+
+		if !matched {
+			problems = append(problems, fmt.Sprintf("endpoint %s (%s %s) has no route", e.Name, e.Method, e.Path))
+		}
+	}
+
+	for _, r := range registry.Routes {
+		if strings.HasPrefix(r.Path, "/v1/") && !defined[r] {
+			problems = append(problems, fmt.Sprintf("route %s %s has no endpoint definition", r.Method, r.Path))
+		}
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d endpoint definitions and routes don't match", len(problems))
+	}
+
+	return nil
+}
+
+// Report whether an endpoint path matches a route pattern. A named parameter in the
+// pattern matches any segment, as some endpoints (such as /v1/movies/trending) are
+// dispatched by the handler of a parameterised route, and a catch-all parameter
+// matches the rest of the path.
+func matchPattern(pattern, path string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+
+		if i >= len(pathSegments) {
+			return false
+		}
+
+		if !strings.HasPrefix(segment, ":") && segment != pathSegments[i] {
+			return false
+		}
+	}
+
+	return len(patternSegments) == len(pathSegments)
+}
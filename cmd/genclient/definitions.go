@@ -0,0 +1,577 @@
+package main
+
+// Define a field struct describing a field of a generated type. The Go type is written
+// as it should appear in the Go client, and is converted for the TypeScript client.
+type field struct {
+	Name     string // Go field name
+	JSON     string // JSON key
+	GoType   string
+	Optional bool // Left out of the JSON when empty
+}
+
+// Define a typeDef struct describing a type sent to or returned by the API
+type typeDef struct {
+	Name   string
+	Doc    string
+	Fields []field
+}
+
+// Define an endpoint struct describing one of the API's routes. Path parameters are
+// written like in the router (e.g. "/v1/movies/:id"), and become arguments of the
+// generated method.
+type endpoint struct {
+	Name     string // Go method name
+	Doc      string
+	Method   string
+	Path     string
+	Query    bool   // Whether the method takes query string parameters
+	Body     string // Request body type, if any. Struct types are passed by pointer.
+	NDJSON   bool   // Send the body, a slice, as one JSON value per line
+	Response string // Response envelope type
+}
+
+// The types used by the API. These mirror the JSON sent and accepted by cmd/api, and
+// must be updated along with the handlers and internal/data types.
+var types = []typeDef{
+	{Name: "Metadata", Doc: "Pagination metadata of a list", Fields: []field{
+		{"CurrentPage", "current_page", "int", true},
+		{"PageSize", "page_size", "int", true},
+		{"FirstPage", "first_page", "int", true},
+		{"LastPage", "last_page", "int", true},
+		{"TotalRecords", "total_records", "int", true},
+		{"NextCursor", "next_cursor", "string", true},
+		{"Facets", "facets", "*MovieFacets", true},
+	}},
+	{Name: "MovieFacets", Doc: "Number of movies matching a search by genre, year, decade (such as \"1990s\"), runtime range (such as \"90-119\") and certification (such as \"US:PG-13\")", Fields: []field{
+		{"Genres", "genres", "map[string]int", false},
+		{"Years", "years", "map[string]int", false},
+		{"Decades", "decades", "map[string]int", false},
+		{"Runtimes", "runtimes", "map[string]int", false},
+		{"Certifications", "certifications", "map[string]int", false},
+	}},
+	{Name: "MovieSuggestion", Doc: "A movie title suggested as the user types", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"Title", "title", "string", false},
+		{"Year", "year", "int32", true},
+	}},
+	{Name: "Movie", Doc: "A movie. Runtimes are strings such as \"102 mins\".", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"Title", "title", "string", false},
+		{"Year", "year", "int32", true},
+		{"Runtime", "runtime", "string", true},
+		{"Genres", "genres", "[]string", true},
+		{"Synopsis", "synopsis", "string", true},
+		{"OriginalLanguage", "original_language", "string", true},
+		{"ProductionCountries", "production_countries", "[]string", true},
+		{"Certifications", "certifications", "map[string]string", true},
+		{"Metadata", "metadata", "map[string]string", true},
+		{"CreatedBy", "created_by", "int64", true},
+		{"Status", "status", "string", false},
+		{"PublishAt", "publish_at", "*time.Time", true},
+		{"Version", "version", "int32", false},
+		{"Views", "views", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"UpdatedAt", "updated_at", "time.Time", false},
+		{"Collection", "collection", "*Collection", true},
+	}},
+	{Name: "MovieInput", Doc: "The fields of a new movie", Fields: []field{
+		{"Title", "title", "string", false},
+		{"Year", "year", "int32", false},
+		{"Runtime", "runtime", "string", false},
+		{"Genres", "genres", "[]string", false},
+		{"Synopsis", "synopsis", "string", true},
+		{"OriginalLanguage", "original_language", "string", true},
+		{"ProductionCountries", "production_countries", "[]string", true},
+		{"Certifications", "certifications", "map[string]string", true},
+		{"Metadata", "metadata", "map[string]string", true},
+		{"Status", "status", "string", true},
+		{"PublishAt", "publish_at", "*time.Time", true},
+	}},
+	{Name: "MovieUpdate", Doc: "The fields to change on a movie. Nil fields are left as they are, and metadata keys set to nil are removed.", Fields: []field{
+		{"Title", "title", "*string", true},
+		{"Year", "year", "*int32", true},
+		{"Runtime", "runtime", "*string", true},
+		{"Genres", "genres", "[]string", true},
+		{"Synopsis", "synopsis", "*string", true},
+		{"OriginalLanguage", "original_language", "*string", true},
+		{"ProductionCountries", "production_countries", "[]string", true},
+		{"Certifications", "certifications", "map[string]string", true},
+		{"Metadata", "metadata", "map[string]*string", true},
+		{"Status", "status", "*string", true},
+		{"PublishAt", "publish_at", "*time.Time", true},
+	}},
+	{Name: "MovieChange", Doc: "A change to a movie proposed by a contributor, applied once an editor approves it", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"MovieID", "movie_id", "int64", false},
+		{"UserID", "user_id", "int64", false},
+		{"BaseVersion", "base_version", "int32", false},
+		{"Changes", "changes", "MovieUpdate", false},
+		{"Status", "status", "string", false},
+		{"ReviewReason", "review_reason", "string", true},
+		{"ReviewedAt", "reviewed_at", "*time.Time", true},
+		{"Version", "version", "int32", false},
+	}},
+	{Name: "Release", Doc: "A release of a movie in a country", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"MovieID", "movie_id", "int64", false},
+		{"Country", "country", "string", false},
+		{"Type", "type", "string", false},
+		{"ReleaseDate", "release_date", "string", false},
+		{"Note", "note", "string", true},
+		{"Version", "version", "int32", false},
+	}},
+	{Name: "ReleaseInput", Doc: "The fields of a new release. Dates are in the format \"2006-01-02\".", Fields: []field{
+		{"Country", "country", "string", false},
+		{"Type", "type", "string", false},
+		{"ReleaseDate", "release_date", "string", false},
+		{"Note", "note", "string", true},
+	}},
+	{Name: "ReleaseUpdate", Doc: "The fields to change on a release. Nil fields are left as they are.", Fields: []field{
+		{"Country", "country", "*string", true},
+		{"Type", "type", "*string", true},
+		{"ReleaseDate", "release_date", "*string", true},
+		{"Note", "note", "*string", true},
+	}},
+	{Name: "Collection", Doc: "A collection of related movies", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"Name", "name", "string", false},
+		{"Description", "description", "string", true},
+		{"MovieIDs", "movie_ids", "[]int64", false},
+		{"Version", "version", "int32", false},
+	}},
+	{Name: "CollectionInput", Doc: "The fields of a new collection", Fields: []field{
+		{"Name", "name", "string", false},
+		{"Description", "description", "string", true},
+		{"MovieIDs", "movie_ids", "[]int64", false},
+	}},
+	{Name: "CollectionUpdate", Doc: "The fields to change on a collection. Nil fields are left as they are.", Fields: []field{
+		{"Name", "name", "*string", true},
+		{"Description", "description", "*string", true},
+		{"MovieIDs", "movie_ids", "[]int64", true},
+	}},
+	{Name: "Review", Doc: "A user's review of a movie", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"MovieID", "movie_id", "int64", false},
+		{"UserID", "user_id", "int64", false},
+		{"UserName", "user_name", "string", false},
+		{"Rating", "rating", "int16", false},
+		{"Body", "body", "string", false},
+		{"Status", "status", "string", false},
+		{"ModerationReason", "moderation_reason", "string", true},
+		{"ModeratedAt", "moderated_at", "*time.Time", true},
+		{"Version", "version", "int32", false},
+	}},
+	{Name: "ReviewInput", Doc: "The fields of a new review", Fields: []field{
+		{"Rating", "rating", "int16", false},
+		{"Body", "body", "string", false},
+	}},
+	{Name: "RejectionInput", Doc: "The optional reason for rejecting a review or a proposed change", Fields: []field{
+		{"Reason", "reason", "string", true},
+	}},
+	{Name: "Report", Doc: "A user's report of an abusive review", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"ReviewID", "review_id", "int64", false},
+		{"UserID", "user_id", "int64", false},
+		{"Reason", "reason", "string", false},
+		{"Comment", "comment", "string", true},
+		{"Status", "status", "string", false},
+		{"ResolvedAt", "resolved_at", "*time.Time", true},
+		{"Version", "version", "int32", false},
+	}},
+	{Name: "ReportInput", Doc: "The fields of a new report", Fields: []field{
+		{"Reason", "reason", "string", false},
+		{"Comment", "comment", "string", true},
+	}},
+	{Name: "ResolutionInput", Doc: "How a report is resolved, either upheld or dismissed", Fields: []field{
+		{"Status", "status", "string", false},
+	}},
+	{Name: "User", Doc: "A registered user", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"Name", "name", "string", false},
+		{"Email", "email", "string", false},
+		{"Activated", "activated", "bool", false},
+	}},
+	{Name: "EmailStatus", Doc: "The delivery status of a user's email address, as reported by the email provider: ok, soft_bounce, bounced or complained", Fields: []field{
+		{"Status", "status", "string", false},
+		{"UpdatedAt", "updated_at", "*time.Time", false},
+	}},
+	{Name: "RegistrationInput", Doc: "The details of a new user, with the invitation code they were sent when registration is closed", Fields: []field{
+		{"Name", "name", "string", false},
+		{"Email", "email", "string", false},
+		{"Password", "password", "string", false},
+		{"Invitation", "invitation", "string", true},
+	}},
+	{Name: "Invitation", Doc: "An invitation for someone to register", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"Email", "email", "string", false},
+		{"InvitedBy", "invited_by", "int64", false},
+		{"Expiry", "expiry", "time.Time", false},
+		{"AcceptedAt", "accepted_at", "*time.Time", false},
+		{"Status", "status", "string", false},
+	}},
+	{Name: "ActivationInput", Doc: "The token sent to a new user", Fields: []field{
+		{"Token", "token", "string", false},
+	}},
+	{Name: "PasswordResetInput", Doc: "A new password and the password reset token", Fields: []field{
+		{"Password", "password", "string", false},
+		{"Token", "token", "string", false},
+	}},
+	{Name: "EmailInput", Doc: "The email address to send a token to", Fields: []field{
+		{"Email", "email", "string", false},
+	}},
+	{Name: "CredentialsInput", Doc: "A user's email address and password, with the permissions to restrict the token to and its expiry", Fields: []field{
+		{"Email", "email", "string", false},
+		{"Password", "password", "string", false},
+		{"Scopes", "scopes", "[]string", true},
+		{"Expiry", "expiry", "*time.Time", true},
+	}},
+	{Name: "Token", Doc: "An authentication token", Fields: []field{
+		{"Token", "token", "string", false},
+		{"Expiry", "expiry", "time.Time", false},
+		{"Scopes", "scopes", "[]string", true},
+	}},
+	{Name: "Notification", Doc: "A notification sent to the user", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"Kind", "kind", "string", false},
+		{"Title", "title", "string", false},
+		{"Body", "body", "string", false},
+		{"Data", "data", "map[string]interface{}", true},
+		{"ReadAt", "read_at", "*time.Time", false},
+	}},
+	{Name: "ChannelPreferences", Doc: "The channels a kind of notification is sent on", Fields: []field{
+		{"Email", "email", "bool", false},
+		{"InApp", "in_app", "bool", false},
+	}},
+	{Name: "SavedSearch", Doc: "A movie search the user is alerted about", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"Name", "name", "string", false},
+		{"Title", "title", "string", false},
+		{"Genres", "genres", "[]string", false},
+	}},
+	{Name: "SavedSearchInput", Doc: "The fields of a new saved search", Fields: []field{
+		{"Name", "name", "string", false},
+		{"Title", "title", "string", false},
+		{"Genres", "genres", "[]string", false},
+	}},
+	{Name: "DigestSubscription", Doc: "The user's subscription to the digest emails of new movies in their favorite genres", Fields: []field{
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"Frequency", "frequency", "string", false},
+		{"Genres", "genres", "[]string", false},
+		{"NextDigestAt", "next_digest_at", "time.Time", false},
+	}},
+	{Name: "DigestSubscriptionInput", Doc: "How often the user wants a digest (daily or weekly) and their favorite genres", Fields: []field{
+		{"Frequency", "frequency", "string", false},
+		{"Genres", "genres", "[]string", false},
+	}},
+	{Name: "TOSAcceptance", Doc: "The user's acceptance of a version of the terms of service", Fields: []field{
+		{"Version", "version", "string", false},
+		{"AcceptedAt", "accepted_at", "time.Time", false},
+	}},
+	{Name: "TOSAcceptanceInput", Doc: "The version of the terms of service the user accepts", Fields: []field{
+		{"Version", "version", "string", false},
+	}},
+	{Name: "ServiceAccount", Doc: "A non-human client acting for the user with some of their permissions", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"OwnerID", "owner_id", "int64", false},
+		{"Name", "name", "string", false},
+		{"KeyPrefix", "key_prefix", "string", false},
+		{"Permissions", "permissions", "[]string", false},
+		{"Active", "active", "bool", false},
+		{"Version", "version", "int", false},
+	}},
+	{Name: "ServiceAccountInput", Doc: "The fields of a new service account", Fields: []field{
+		{"Name", "name", "string", false},
+		{"Permissions", "permissions", "[]string", false},
+	}},
+	{Name: "ServiceAccountUpdate", Doc: "The fields to change on a service account. Nil fields are left as they are.", Fields: []field{
+		{"Name", "name", "*string", true},
+		{"Permissions", "permissions", "[]string", true},
+		{"Active", "active", "*bool", true},
+	}},
+	{Name: "ImportFailure", Doc: "A line of a bulk import which couldn't be imported", Fields: []field{
+		{"Line", "line", "int", false},
+		{"Code", "code", "string", false},
+		{"Error", "error", "interface{}", false},
+		{"Fields", "fields", "map[string]FieldError", true},
+	}},
+	{Name: "FieldError", Doc: "The code, message and parameters of a field which failed validation", Fields: []field{
+		{"Code", "code", "string", false},
+		{"Message", "message", "string", false},
+		{"Params", "params", "map[string]interface{}", true},
+	}},
+
+	// Response envelopes
+	{Name: "MessageResponse", Fields: []field{{"Message", "message", "string", false}}},
+	{Name: "MovieResponse", Fields: []field{{"Movie", "movie", "Movie", false}}},
+	{Name: "MovieListResponse", Fields: []field{
+		{"Movies", "movies", "[]Movie", false},
+		{"Metadata", "metadata", "Metadata", true},
+	}},
+	{Name: "SuggestionListResponse", Fields: []field{{"Suggestions", "suggestions", "[]MovieSuggestion", false}}},
+	{Name: "ReleaseResponse", Fields: []field{{"Release", "release", "Release", false}}},
+	{Name: "ReleaseListResponse", Fields: []field{{"Releases", "releases", "[]Release", false}}},
+	{Name: "CollectionResponse", Fields: []field{
+		{"Collection", "collection", "Collection", false},
+		{"Movies", "movies", "[]Movie", true},
+	}},
+	{Name: "CollectionListResponse", Fields: []field{
+		{"Collections", "collections", "[]Collection", false},
+		{"Metadata", "metadata", "Metadata", false},
+	}},
+	{Name: "MovieChangeResponse", Fields: []field{{"Change", "change", "MovieChange", false}}},
+	{Name: "MovieChangeApprovalResponse", Fields: []field{
+		{"Change", "change", "MovieChange", false},
+		{"Movie", "movie", "Movie", false},
+	}},
+	{Name: "MovieChangeListResponse", Fields: []field{
+		{"Changes", "changes", "[]MovieChange", false},
+		{"Metadata", "metadata", "Metadata", false},
+	}},
+	{Name: "ReviewResponse", Fields: []field{{"Review", "review", "Review", false}}},
+	{Name: "ReviewListResponse", Fields: []field{
+		{"Reviews", "reviews", "[]Review", false},
+		{"Metadata", "metadata", "Metadata", false},
+	}},
+	{Name: "ReportResponse", Fields: []field{{"Report", "report", "Report", false}}},
+	{Name: "ReportListResponse", Fields: []field{
+		{"Reports", "reports", "[]Report", false},
+		{"Metadata", "metadata", "Metadata", false},
+	}},
+	{Name: "InvitationResponse", Fields: []field{{"Invitation", "invitation", "Invitation", false}}},
+	{Name: "InvitationListResponse", Fields: []field{
+		{"Invitations", "invitations", "[]Invitation", false},
+		{"Metadata", "metadata", "Metadata", false},
+	}},
+	{Name: "UserResponse", Fields: []field{{"User", "user", "User", false}}},
+	{Name: "AdminUserResponse", Fields: []field{
+		{"User", "user", "User", false},
+		{"EmailStatus", "email_status", "EmailStatus", false},
+	}},
+	{Name: "TokenResponse", Fields: []field{{"AuthenticationToken", "authentication_token", "Token", false}}},
+	{Name: "GuestTokenResponse", Fields: []field{{"GuestToken", "guest_token", "Token", false}}},
+	{Name: "ImpersonationTokenResponse", Fields: []field{
+		{"ImpersonationToken", "impersonation_token", "Token", false},
+		{"User", "user", "User", false},
+	}},
+	{Name: "NotificationListResponse", Fields: []field{
+		{"Notifications", "notifications", "[]Notification", false},
+		{"Metadata", "metadata", "Metadata", false},
+	}},
+	{Name: "PreferencesResponse", Fields: []field{{"Preferences", "preferences", "map[string]ChannelPreferences", false}}},
+	{Name: "SavedSearchResponse", Fields: []field{{"SavedSearch", "saved_search", "SavedSearch", false}}},
+	{Name: "DigestSubscriptionResponse", Fields: []field{{"DigestSubscription", "digest_subscription", "DigestSubscription", false}}},
+	{Name: "TOSAcceptanceResponse", Fields: []field{{"TOSAcceptance", "tos_acceptance", "TOSAcceptance", false}}},
+	{Name: "SavedSearchListResponse", Fields: []field{{"SavedSearches", "saved_searches", "[]SavedSearch", false}}},
+	{Name: "ServiceAccountResponse", Fields: []field{{"ServiceAccount", "service_account", "ServiceAccount", false}}},
+	{Name: "ServiceAccountKeyResponse", Fields: []field{
+		{"ServiceAccount", "service_account", "ServiceAccount", false},
+		{"Key", "key", "string", false},
+	}},
+	{Name: "ServiceAccountListResponse", Fields: []field{{"ServiceAccounts", "service_accounts", "[]ServiceAccount", false}}},
+	{Name: "ImportResponse", Fields: []field{
+		{"Imported", "imported", "int", false},
+		{"Failed", "failed", "int", false},
+		{"Failures", "failures", "[]ImportFailure", false},
+	}},
+	{Name: "HealthcheckResponse", Fields: []field{
+		{"Status", "status", "string", false},
+		{"SystemInfo", "system_info", "map[string]string", false},
+	}},
+	{Name: "MetaResponse", Fields: []field{
+		{"Build", "build", "map[string]string", false},
+		{"Features", "features", "map[string]bool", false},
+	}},
+	{Name: "Route", Doc: "A route served by the API, with the permission it requires", Fields: []field{
+		{"Method", "method", "string", false},
+		{"Path", "path", "string", false},
+		{"Handler", "handler", "string", false},
+		{"Permission", "permission", "string", true},
+		{"RateLimit", "rate_limit", "string", false},
+	}},
+	{Name: "RouteListResponse", Fields: []field{{"Routes", "routes", "[]Route", false}}},
+	{Name: "ChangelogResponse", Fields: []field{{"Changelog", "changelog", "[]map[string]string", false}}},
+	{Name: "ErrorCode", Doc: "An error code sent with error responses", Fields: []field{
+		{"Code", "code", "string", false},
+		{"Status", "status", "int", false},
+		{"Description", "description", "string", false},
+	}},
+	{Name: "ErrorCodesResponse", Fields: []field{{"ErrorCodes", "error_codes", "[]ErrorCode", false}}},
+	{Name: "MovieListingResponse", Fields: []field{
+		{"Listing", "listing", "json.RawMessage", false},
+		{"PageSize", "page_size", "map[string]int", false},
+		{"MaxPage", "max_page", "int", false},
+	}},
+	{Name: "AvailabilityResponse", Fields: []field{{"Availability", "availability", "json.RawMessage", false}}},
+	{Name: "AdminStatsResponse", Fields: []field{
+		{"GeneratedAt", "generated_at", "time.Time", false},
+		{"Users", "users", "json.RawMessage", false},
+		{"Movies", "movies", "json.RawMessage", false},
+		{"Reviews", "reviews", "json.RawMessage", false},
+		{"Requests", "requests", "map[string]json.RawMessage", false},
+		{"MailerQueue", "mailer_queue", "json.RawMessage", true},
+		{"EventsOutbox", "events_outbox", "json.RawMessage", true},
+	}},
+	{Name: "MetricSnapshot", Doc: "The metrics of an instance at a point in time", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"Label", "label", "string", true},
+		{"Instance", "instance", "string", false},
+		{"Metrics", "metrics", "map[string]json.RawMessage", false},
+	}},
+	{Name: "MetricSnapshotInput", Doc: "The optional label of a snapshot, and whether to reset the counters once it is stored", Fields: []field{
+		{"Label", "label", "string", true},
+		{"Reset", "reset", "bool", true},
+	}},
+	{Name: "MetricsResponse", Fields: []field{
+		{"Metrics", "metrics", "map[string]json.RawMessage", false},
+		{"Rates", "rates", "map[string]float64", false},
+	}},
+	{Name: "MetricSnapshotResponse", Fields: []field{{"Snapshot", "snapshot", "MetricSnapshot", false}}},
+	{Name: "MetricSnapshotListResponse", Fields: []field{
+		{"Snapshots", "snapshots", "[]MetricSnapshot", false},
+		{"Metadata", "metadata", "Metadata", false},
+	}},
+	{Name: "BanListResponse", Fields: []field{{"Bans", "bans", "json.RawMessage", false}}},
+	{Name: "RateLimitClient", Doc: "A client tracked by the rate limiter of an API instance", Fields: []field{
+		{"Key", "key", "string", false},
+		{"TokensRemaining", "tokens_remaining", "float64", false},
+		{"LastSeen", "last_seen", "time.Time", false},
+	}},
+	{Name: "RateLimitListResponse", Fields: []field{{"RateLimits", "rate_limits", "[]RateLimitClient", false}}},
+	{Name: "Backup", Doc: "A dump of the database kept in the backup storage", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"Name", "name", "string", false},
+		{"Status", "status", "string", false},
+		{"Size", "size", "int64", false},
+		{"Error", "error", "string", true},
+		{"FinishedAt", "finished_at", "*time.Time", true},
+	}},
+	{Name: "BackupListResponse", Fields: []field{
+		{"Backups", "backups", "[]Backup", false},
+		{"Metadata", "metadata", "Metadata", false},
+	}},
+	{Name: "OperationInput", Fields: []field{{"Name", "name", "string", false}}},
+	{Name: "Operation", Doc: "A run of an online schema change, such as an index build or a batched backfill", Fields: []field{
+		{"ID", "id", "int64", false},
+		{"CreatedAt", "created_at", "time.Time", false},
+		{"Name", "name", "string", false},
+		{"Status", "status", "string", false},
+		{"Progress", "progress", "int64", false},
+		{"Total", "total", "int64", false},
+		{"RowsUpdated", "rows_updated", "int64", false},
+		{"Error", "error", "string", true},
+		{"UpdatedAt", "updated_at", "time.Time", false},
+		{"FinishedAt", "finished_at", "*time.Time", true},
+	}},
+	{Name: "OperationResponse", Fields: []field{{"Operation", "operation", "Operation", false}}},
+	{Name: "OperationListResponse", Fields: []field{
+		{"Operations", "operations", "[]Operation", false},
+		{"Metadata", "metadata", "Metadata", false},
+	}},
+}
+
+// The v1 endpoints. These mirror the routes registered in cmd/api/routes.go, and must
+// be updated along with them. Run genclient with -check-routes against a running API
+// to compare them with its route registry.
+var endpoints = []endpoint{
+	{Name: "Healthcheck", Doc: "Report the status of the API", Method: "GET", Path: "/v1/healthcheck", Response: "HealthcheckResponse"},
+	{Name: "Meta", Doc: "Describe the running build and its enabled features", Method: "GET", Path: "/v1/meta", Response: "MetaResponse"},
+	{Name: "ListRoutes", Doc: "List the routes served by the API, with the permission each requires", Method: "GET", Path: "/v1/meta/routes", Response: "RouteListResponse"},
+	{Name: "Changelog", Doc: "List the changes made to the API", Method: "GET", Path: "/v1/changelog", Response: "ChangelogResponse"},
+	{Name: "ErrorCodes", Doc: "List the error codes sent with error responses", Method: "GET", Path: "/v1/error-codes", Response: "ErrorCodesResponse"},
+
+	{Name: "ListMovies", Doc: "List the movies matching the query string filters", Method: "GET", Path: "/v1/movies", Query: true, Response: "MovieListResponse"},
+	{Name: "MovieListing", Doc: "Describe how movies can be sorted, filtered and paginated", Method: "GET", Path: "/v1/movies/_meta", Response: "MovieListingResponse"},
+	{Name: "TrendingMovies", Doc: "List the most viewed recent movies", Method: "GET", Path: "/v1/movies/trending", Query: true, Response: "MovieListResponse"},
+	{Name: "RecentMovies", Doc: "List the newest movies", Method: "GET", Path: "/v1/movies/recent", Query: true, Response: "MovieListResponse"},
+	{Name: "AutocompleteMovies", Doc: "Suggest movie titles matching the q parameter as the user types", Method: "GET", Path: "/v1/movies/autocomplete", Query: true, Response: "SuggestionListResponse"},
+	{Name: "GetMovie", Doc: "Fetch a movie", Method: "GET", Path: "/v1/movies/:id", Query: true, Response: "MovieResponse"},
+	{Name: "CreateMovie", Doc: "Create a movie", Method: "POST", Path: "/v1/movies", Body: "MovieInput", Response: "MovieResponse"},
+	{Name: "ImportMovies", Doc: "Create movies in bulk. Movies which fail validation are reported in the response rather than failing the import", Method: "POST", Path: "/v1/movies", Body: "[]MovieInput", NDJSON: true, Response: "ImportResponse"},
+	{Name: "UpdateMovie", Doc: "Change some of a movie's fields", Method: "PATCH", Path: "/v1/movies/:id", Body: "MovieUpdate", Response: "MovieResponse"},
+	{Name: "DeleteMovie", Doc: "Delete a movie", Method: "DELETE", Path: "/v1/movies/:id", Response: "MessageResponse"},
+	{Name: "ShowMovieAvailability", Doc: "List where a movie can be streamed, when the API has a watch-provider configured", Method: "GET", Path: "/v1/movies/:id/availability", Query: true, Response: "AvailabilityResponse"},
+
+	{Name: "ListMovieReleases", Doc: "List a movie's releases", Method: "GET", Path: "/v1/movies/:id/releases", Response: "ReleaseListResponse"},
+	{Name: "CreateMovieRelease", Doc: "Add a release to a movie", Method: "POST", Path: "/v1/movies/:id/releases", Body: "ReleaseInput", Response: "ReleaseResponse"},
+	{Name: "UpdateMovieRelease", Doc: "Change some of a release's fields", Method: "PATCH", Path: "/v1/movies/:id/releases/:release_id", Body: "ReleaseUpdate", Response: "ReleaseResponse"},
+	{Name: "DeleteMovieRelease", Doc: "Delete a release", Method: "DELETE", Path: "/v1/movies/:id/releases/:release_id", Response: "MessageResponse"},
+	{Name: "ProposeMovieChange", Doc: "Propose a change to a movie for an editor to approve", Method: "POST", Path: "/v1/movies/:id/pending-changes", Body: "MovieUpdate", Response: "MovieChangeResponse"},
+	{Name: "ListMovieChanges", Doc: "List the changes proposed for a movie with a status, pending by default", Method: "GET", Path: "/v1/movies/:id/pending-changes", Query: true, Response: "MovieChangeListResponse"},
+	{Name: "ApproveMovieChange", Doc: "Apply a proposed change to its movie", Method: "PUT", Path: "/v1/movies/:id/pending-changes/:change_id/approve", Response: "MovieChangeApprovalResponse"},
+	{Name: "RejectMovieChange", Doc: "Reject a proposed change", Method: "PUT", Path: "/v1/movies/:id/pending-changes/:change_id/reject", Body: "RejectionInput", Response: "MovieChangeResponse"},
+
+	{Name: "ListMovieReviews", Doc: "List a movie's published reviews", Method: "GET", Path: "/v1/movies/:id/reviews", Query: true, Response: "ReviewListResponse"},
+	{Name: "CreateMovieReview", Doc: "Review a movie", Method: "POST", Path: "/v1/movies/:id/reviews", Body: "ReviewInput", Response: "ReviewResponse"},
+	{Name: "ReportReview", Doc: "Report an abusive review", Method: "POST", Path: "/v1/reviews/:id/reports", Body: "ReportInput", Response: "ReportResponse"},
+
+	{Name: "ListCollections", Doc: "List the collections matching the query string filters", Method: "GET", Path: "/v1/collections", Query: true, Response: "CollectionListResponse"},
+	{Name: "GetCollection", Doc: "Fetch a collection along with its movies", Method: "GET", Path: "/v1/collections/:id", Response: "CollectionResponse"},
+	{Name: "CreateCollection", Doc: "Create a collection", Method: "POST", Path: "/v1/collections", Body: "CollectionInput", Response: "CollectionResponse"},
+	{Name: "UpdateCollection", Doc: "Change some of a collection's fields", Method: "PATCH", Path: "/v1/collections/:id", Body: "CollectionUpdate", Response: "CollectionResponse"},
+	{Name: "DeleteCollection", Doc: "Delete a collection", Method: "DELETE", Path: "/v1/collections/:id", Response: "MessageResponse"},
+
+	{Name: "ListModerationReviews", Doc: "List the reviews with a moderation status, pending by default", Method: "GET", Path: "/v1/moderation/reviews", Query: true, Response: "ReviewListResponse"},
+	{Name: "ApproveReview", Doc: "Publish a review", Method: "PUT", Path: "/v1/moderation/reviews/:id/approve", Response: "ReviewResponse"},
+	{Name: "RejectReview", Doc: "Reject a review", Method: "PUT", Path: "/v1/moderation/reviews/:id/reject", Body: "RejectionInput", Response: "ReviewResponse"},
+	{Name: "ListReports", Doc: "List the review reports with a status, open by default", Method: "GET", Path: "/v1/moderation/reports", Query: true, Response: "ReportListResponse"},
+	{Name: "ResolveReport", Doc: "Uphold or dismiss a report", Method: "PUT", Path: "/v1/moderation/reports/:id", Body: "ResolutionInput", Response: "ReportResponse"},
+
+	{Name: "ListInvitations", Doc: "List the invitations, optionally with a status", Method: "GET", Path: "/v1/invitations", Query: true, Response: "InvitationListResponse"},
+	{Name: "CreateInvitation", Doc: "Email an invitation to register", Method: "POST", Path: "/v1/invitations", Body: "EmailInput", Response: "InvitationResponse"},
+	{Name: "RevokeInvitation", Doc: "Revoke an invitation which hasn't been accepted", Method: "DELETE", Path: "/v1/invitations/:id", Response: "MessageResponse"},
+
+	{Name: "RegisterUser", Doc: "Register a new user, who is sent an activation token unless they were invited", Method: "POST", Path: "/v1/users", Body: "RegistrationInput", Response: "UserResponse"},
+	{Name: "ActivateUser", Doc: "Activate a user with their activation token", Method: "PUT", Path: "/v1/users/activated", Body: "ActivationInput", Response: "UserResponse"},
+	{Name: "ResetPassword", Doc: "Change a user's password with their password reset token", Method: "PUT", Path: "/v1/users/password", Body: "PasswordResetInput", Response: "MessageResponse"},
+
+	{Name: "ListNotifications", Doc: "List the user's notifications", Method: "GET", Path: "/v1/users/me/notifications", Query: true, Response: "NotificationListResponse"},
+	{Name: "MarkNotificationRead", Doc: "Mark a notification as read", Method: "PUT", Path: "/v1/users/me/notifications/:id/read", Response: "MessageResponse"},
+	{Name: "GetNotificationPreferences", Doc: "Fetch the user's notification preferences", Method: "GET", Path: "/v1/users/me/notification-preferences", Response: "PreferencesResponse"},
+	{Name: "UpdateNotificationPreferences", Doc: "Change the user's notification preferences", Method: "PUT", Path: "/v1/users/me/notification-preferences", Body: "map[string]ChannelPreferences", Response: "PreferencesResponse"},
+	{Name: "ListSavedSearches", Doc: "List the user's saved searches", Method: "GET", Path: "/v1/users/me/saved-searches", Response: "SavedSearchListResponse"},
+	{Name: "CreateSavedSearch", Doc: "Save a search to be alerted about", Method: "POST", Path: "/v1/users/me/saved-searches", Body: "SavedSearchInput", Response: "SavedSearchResponse"},
+	{Name: "DeleteSavedSearch", Doc: "Delete a saved search", Method: "DELETE", Path: "/v1/users/me/saved-searches/:id", Response: "MessageResponse"},
+	{Name: "ShowDigestSubscription", Doc: "Fetch the user's digest subscription", Method: "GET", Path: "/v1/users/me/digest", Response: "DigestSubscriptionResponse"},
+	{Name: "UpdateDigestSubscription", Doc: "Subscribe to the digest emails of new movies in the given genres, or change the subscription", Method: "PUT", Path: "/v1/users/me/digest", Body: "DigestSubscriptionInput", Response: "DigestSubscriptionResponse"},
+	{Name: "DeleteDigestSubscription", Doc: "Unsubscribe from the digest emails", Method: "DELETE", Path: "/v1/users/me/digest", Response: "MessageResponse"},
+	{Name: "AcceptTOS", Doc: "Accept the current version of the terms of service, which is required before using the API when the deployment has terms", Method: "POST", Path: "/v1/users/me/tos-acceptance", Body: "TOSAcceptanceInput", Response: "TOSAcceptanceResponse"},
+	{Name: "ListServiceAccounts", Doc: "List the user's service accounts", Method: "GET", Path: "/v1/users/me/service-accounts", Response: "ServiceAccountListResponse"},
+	{Name: "CreateServiceAccount", Doc: "Create a service account, returning its key", Method: "POST", Path: "/v1/users/me/service-accounts", Body: "ServiceAccountInput", Response: "ServiceAccountKeyResponse"},
+	{Name: "ShowServiceAccount", Doc: "Fetch one of the user's service accounts", Method: "GET", Path: "/v1/users/me/service-accounts/:id", Response: "ServiceAccountResponse"},
+	{Name: "UpdateServiceAccount", Doc: "Change some of a service account's fields", Method: "PATCH", Path: "/v1/users/me/service-accounts/:id", Body: "ServiceAccountUpdate", Response: "ServiceAccountResponse"},
+	{Name: "RotateServiceAccountKey", Doc: "Replace a service account's key, returning the new one", Method: "POST", Path: "/v1/users/me/service-accounts/:id/key", Response: "ServiceAccountKeyResponse"},
+	{Name: "DeleteServiceAccount", Doc: "Delete a service account", Method: "DELETE", Path: "/v1/users/me/service-accounts/:id", Response: "MessageResponse"},
+
+	{Name: "CreateActivationToken", Doc: "Send a new activation token to a user", Method: "POST", Path: "/v1/tokens/activation", Body: "EmailInput", Response: "MessageResponse"},
+	{Name: "CreatePasswordResetToken", Doc: "Send a password reset token to a user", Method: "POST", Path: "/v1/tokens/password-reset", Body: "EmailInput", Response: "MessageResponse"},
+	{Name: "CreateAuthenticationToken", Doc: "Exchange a user's credentials for an authentication token", Method: "POST", Path: "/v1/tokens/authentication", Body: "CredentialsInput", Response: "TokenResponse"},
+	{Name: "CreateGuestToken", Doc: "Start a guest session, returning a read-only token for browsing the catalog", Method: "POST", Path: "/v1/tokens/guest", Response: "GuestTokenResponse"},
+
+	{Name: "ImpersonateUser", Doc: "Get a short-lived token acting as a user, for reproducing the issues they report", Method: "POST", Path: "/v1/admin/impersonate/:id", Response: "ImpersonationTokenResponse"},
+	{Name: "AdminStats", Doc: "Fetch the figures shown on the ops dashboard", Method: "GET", Path: "/v1/admin/stats", Query: true, Response: "AdminStatsResponse"},
+	{Name: "ShowUser", Doc: "Fetch a user along with the delivery status of their email address", Method: "GET", Path: "/v1/admin/users/:id", Response: "AdminUserResponse"},
+	{Name: "ShowMetrics", Doc: "Fetch the current metrics and request rates", Method: "GET", Path: "/v1/admin/metrics", Response: "MetricsResponse"},
+	{Name: "CreateMetricSnapshot", Doc: "Store the current metrics in the database", Method: "POST", Path: "/v1/admin/metrics/snapshots", Body: "MetricSnapshotInput", Response: "MetricSnapshotResponse"},
+	{Name: "ListMetricSnapshots", Doc: "List the stored metric snapshots, newest first", Method: "GET", Path: "/v1/admin/metrics/snapshots", Query: true, Response: "MetricSnapshotListResponse"},
+	{Name: "ResetMetrics", Doc: "Reset the in-memory metric counters", Method: "POST", Path: "/v1/admin/metrics/reset", Response: "MessageResponse"},
+	{Name: "ListBans", Doc: "List the banned IP addresses", Method: "GET", Path: "/v1/admin/bans", Response: "BanListResponse"},
+	{Name: "DeleteBan", Doc: "Lift the ban on an IP address", Method: "DELETE", Path: "/v1/admin/bans/:ip", Response: "MessageResponse"},
+	{Name: "ListRateLimits", Doc: "List the clients tracked by the rate limiter of the instance handling the request", Method: "GET", Path: "/v1/admin/rate-limits", Response: "RateLimitListResponse"},
+	{Name: "DeleteRateLimit", Doc: "Reset a client's rate limit on the instance handling the request", Method: "DELETE", Path: "/v1/admin/rate-limits/:key", Response: "MessageResponse"},
+	{Name: "CreateBackup", Doc: "Queue a backup of the database", Method: "POST", Path: "/v1/admin/backups", Response: "MessageResponse"},
+	{Name: "ListBackups", Doc: "List the database backups, newest first", Method: "GET", Path: "/v1/admin/backups", Query: true, Response: "BackupListResponse"},
+	{Name: "CreateOperation", Doc: "Queue an online schema change", Method: "POST", Path: "/v1/admin/operations", Body: "OperationInput", Response: "OperationResponse"},
+	{Name: "ListOperations", Doc: "List the schema operations, newest first", Method: "GET", Path: "/v1/admin/operations", Query: true, Response: "OperationListResponse"},
+	{Name: "GetOperation", Doc: "Fetch a schema operation and its progress", Method: "GET", Path: "/v1/admin/operations/:id", Response: "OperationResponse"},
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// The genclient command generates the Go client package for the v1 API from the type
+// and endpoint definitions in definitions.go, and optionally a TypeScript client. The
+// generated files are committed under /client, so run it (with `make client/generate`)
+// whenever an endpoint is added or changed. With -check-routes, it compares the
+// endpoint definitions with the route registry of a running API instead.
+func main() {
+	out := flag.String("out", "client", "Directory to write the generated client to")
+	typescript := flag.Bool("typescript", false, "Also generate a TypeScript client")
+	checkRoutes := flag.String("check-routes", "", "URL of a running API's GET /v1/meta/routes endpoint to check the endpoint definitions against, instead of generating the clients")
+	flag.Parse()
+
+	var err error
+
+	if *checkRoutes != "" {
+		err = checkEndpoints(*checkRoutes)
+	} else {
+		err = generate(*out, *typescript)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Write the clients to the output directory
+func generate(out string, typescript bool) error {
+	err := os.MkdirAll(out, 0755)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	err = goTemplate.Execute(&buf, templateData())
+	if err != nil {
+		return err
+	}
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("formatting Go client: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(out, "client.go"), source, 0644)
+	if err != nil {
+		return err
+	}
+
+	if !typescript {
+		return nil
+	}
+
+	buf.Reset()
+
+	err = tsTemplate.Execute(&buf, templateData())
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(out, "client.ts"), buf.Bytes(), 0644)
+}
+
+// Define a param struct describing a path parameter of an endpoint
+type param struct {
+	Name string
+	Type string
+	verb string // The fmt verb which formats the parameter in the path
+}
+
+// Return the path parameters of an endpoint, in the order they appear in the path.
+// The ":ip" and ":key" parameters are strings, all others are int64 IDs.
+func (e endpoint) Params() []param {
+	var params []param
+
+	for _, segment := range strings.Split(e.Path, "/") {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+
+		name := strings.TrimPrefix(segment, ":")
+
+		if name == "ip" || name == "key" {
+			params = append(params, param{Name: name, Type: "string", verb: "%s"})
+			continue
+		}
+
+		params = append(params, param{Name: lowerCamel(name), Type: "int64", verb: "%d"})
+	}
+
+	return params
+}
+
+// Return the Go type of the request body argument
+func (e endpoint) BodyParam() string {
+	if strings.HasPrefix(e.Body, "[]") || strings.HasPrefix(e.Body, "map[") {
+		return e.Body
+	}
+
+	return "*" + e.Body
+}
+
+// Return the path for fmt.Sprintf(), with each parameter replaced by its verb
+func (e endpoint) GoPath() string {
+	path := e.Path
+	for _, p := range e.Params() {
+		path = replaceParam(path, p.verb)
+	}
+
+	return path
+}
+
+// Return the path as a TypeScript template literal
+func (e endpoint) TSPath() string {
+	path := e.Path
+	for _, p := range e.Params() {
+		path = replaceParam(path, "${encodeURIComponent(String("+p.Name+"))}")
+	}
+
+	return path
+}
+
+// Replace the first parameter in the path
+func replaceParam(path, replacement string) string {
+	start := strings.Index(path, ":")
+	if start == -1 {
+		return path
+	}
+
+	end := strings.Index(path[start:], "/")
+	if end == -1 {
+		return path[:start] + replacement
+	}
+
+	return path[:start] + replacement + path[start+end:]
+}
+
+// Convert a snake_case name to lowerCamelCase, keeping the "ID" initialism
+func lowerCamel(name string) string {
+	parts := strings.Split(name, "_")
+
+	for i := 1; i < len(parts); i++ {
+		if parts[i] == "id" {
+			parts[i] = "ID"
+			continue
+		}
+
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+
+	return strings.Join(parts, "")
+}
+
+// Convert a Go type from the definitions to the equivalent TypeScript type
+func tsType(goType string) string {
+	switch {
+	case strings.HasPrefix(goType, "*"):
+		return tsType(goType[1:])
+	case strings.HasPrefix(goType, "[]"):
+		return tsType(goType[2:]) + "[]"
+	case strings.HasPrefix(goType, "map[string]*"):
+		return "Record<string, " + tsType(strings.TrimPrefix(goType, "map[string]*")) + " | null>"
+	case strings.HasPrefix(goType, "map[string]"):
+		return "Record<string, " + tsType(strings.TrimPrefix(goType, "map[string]")) + ">"
+	}
+
+	switch goType {
+	case "int", "int16", "int32", "int64", "float64":
+		return "number"
+	case "string", "time.Time":
+		return "string"
+	case "bool":
+		return "boolean"
+	case "interface{}", "json.RawMessage":
+		return "unknown"
+	default:
+		return goType
+	}
+}
+
+// Return the data passed to the templates
+func templateData() map[string]interface{} {
+	return map[string]interface{}{
+		"Types":     types,
+		"Endpoints": endpoints,
+	}
+}
+
+var goTemplate = template.Must(template.New("go").Parse(goSource))
+
+var tsTemplate = template.Must(template.New("ts").Funcs(template.FuncMap{
+	"tsType":     tsType,
+	"lowerFirst": func(name string) string { return strings.ToLower(name[:1]) + name[1:] },
+}).Parse(tsSource))
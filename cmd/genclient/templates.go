@@ -0,0 +1,283 @@
+package main
+
+// The template of the Go client
+const goSource = `// Code generated by cmd/genclient. DO NOT EDIT.
+
+// Package client is a typed client for the Greenlight v1 API. Regenerate it with
+// "make client/generate" after changing the API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client sends requests to the API. Token is sent as a bearer token when it is set.
+// The consistency token of the last write is sent back with the following requests, so
+// that they see the write even when the API reads from a replica.
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+
+	mutex       sync.Mutex
+	consistency string
+}
+
+// New returns a client for the API at the base URL, such as "https://greenlight.example.com"
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Error is returned for responses with a 4xx or 5xx status code. Details holds the
+// "error" value of the response, which for failed validations maps each invalid
+// field to its error message. Code is the stable error code, such as "movie_not_found",
+// which is listed by the ErrorCodes endpoint. For failed validations, Fields holds the
+// code and parameters of each invalid field's error.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    json.RawMessage
+	Fields     map[string]FieldError
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("greenlight: %d: %s", e.StatusCode, e.Message)
+	}
+
+	return fmt.Sprintf("greenlight: %d: %s", e.StatusCode, e.Details)
+}
+
+// Send a request and decode the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader, out interface{}) error {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	c.mutex.Lock()
+	if c.consistency != "" {
+		req.Header.Set("X-Consistency-Token", c.consistency)
+	}
+	c.mutex.Unlock()
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if token := res.Header.Get("X-Consistency-Token"); token != "" {
+		c.mutex.Lock()
+		c.consistency = token
+		c.mutex.Unlock()
+	}
+
+	if res.StatusCode >= 400 {
+		return readError(res)
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// Read the error from a failed response. The error is either a message or an object,
+// which has a message unless it holds the errors of a failed validation.
+func readError(res *http.Response) error {
+	apiErr := &Error{StatusCode: res.StatusCode}
+
+	var env struct {
+		Code   string                ` + "`json:\"code\"`" + `
+		Error  json.RawMessage       ` + "`json:\"error\"`" + `
+		Fields map[string]FieldError ` + "`json:\"fields\"`" + `
+	}
+
+	err := json.NewDecoder(res.Body).Decode(&env)
+	if err != nil {
+		apiErr.Message = http.StatusText(res.StatusCode)
+		return apiErr
+	}
+
+	apiErr.Code = env.Code
+	apiErr.Details = env.Error
+	apiErr.Fields = env.Fields
+
+	var message string
+	if json.Unmarshal(env.Error, &message) == nil {
+		apiErr.Message = message
+		return apiErr
+	}
+
+	var object struct {
+		Message string ` + "`json:\"message\"`" + `
+	}
+	if json.Unmarshal(env.Error, &object) == nil {
+		apiErr.Message = object.Message
+	}
+
+	return apiErr
+}
+
+// Send a request with an optional JSON body
+func (c *Client) doJSON(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	if in == nil {
+		return c.do(ctx, method, path, query, "", nil, out)
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, method, path, query, "application/json", bytes.NewReader(body), out)
+}
+
+// Send a request with the items as newline-delimited JSON
+func doNDJSON[T any](ctx context.Context, c *Client, method, path string, items []T, out interface{}) error {
+	var body bytes.Buffer
+
+	encoder := json.NewEncoder(&body)
+	for _, item := range items {
+		err := encoder.Encode(item)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.do(ctx, method, path, nil, "application/x-ndjson", &body, out)
+}
+{{range .Types}}
+{{if .Doc}}// {{.Name}}: {{.Doc}}
+{{end}}type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.GoType}} ` + "`json:\"{{.JSON}}{{if .Optional}},omitempty{{end}}\"`" + `
+{{- end}}
+}
+{{end}}
+{{range .Endpoints}}
+// {{.Name}}: {{.Doc}}.
+//
+//	{{.Method}} {{.Path}}
+func (c *Client) {{.Name}}(ctx context.Context
+{{- range .Params}}, {{.Name}} {{.Type}}{{end}}
+{{- if .Query}}, query url.Values{{end}}
+{{- if .Body}}, input {{.BodyParam}}{{end}}) (*{{.Response}}, error) {
+	var out {{.Response}}
+
+	path := {{if .Params}}fmt.Sprintf("{{.GoPath}}"{{range .Params}}, {{if eq .Type "string"}}url.PathEscape({{.Name}}){{else}}{{.Name}}{{end}}{{end}}){{else}}"{{.Path}}"{{end}}
+
+	{{if .NDJSON -}}
+	err := doNDJSON(ctx, c, "{{.Method}}", path, input, &out)
+	{{- else -}}
+	err := c.doJSON(ctx, "{{.Method}}", path, {{if .Query}}query{{else}}nil{{end}}, {{if .Body}}input{{else}}nil{{end}}, &out)
+	{{- end}}
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+{{end}}`
+
+// The template of the TypeScript client
+const tsSource = `// Code generated by cmd/genclient. DO NOT EDIT.
+
+// A typed client for the Greenlight v1 API. Regenerate it with
+// "make client/generate" after changing the API.
+
+/**
+ * Thrown for error responses. code is the stable error code, such as "movie_not_found", listed by
+ * errorCodes(). For failed validations, fields holds the code and parameters of each invalid field's error.
+ */
+export class GreenlightError extends Error {
+  constructor(public status: number, public details: unknown, public code?: string, public fields?: Record<string, FieldError>) {
+    super(typeof details === "string" ? details : JSON.stringify(details));
+  }
+}
+{{range .Types}}
+{{if .Doc}}/** {{.Doc}} */
+{{end}}export interface {{.Name}} {
+{{- range .Fields}}
+  {{.JSON}}{{if .Optional}}?{{end}}: {{tsType .GoType}}{{if eq (printf "%.1s" .GoType) "*"}} | null{{end}};
+{{- end}}
+}
+{{end}}
+export class GreenlightClient {
+  // The consistency token of the last write, sent back so that reads see the write
+  private consistency?: string;
+
+  constructor(private baseURL: string, public token?: string) {
+    this.baseURL = baseURL.replace(/\/$/, "");
+  }
+
+  private async request<T>(method: string, path: string, query?: Record<string, string>, body?: string, contentType = "application/json"): Promise<T> {
+    let url = this.baseURL + path;
+    if (query && Object.keys(query).length > 0) {
+      url += "?" + new URLSearchParams(query).toString();
+    }
+
+    const headers: Record<string, string> = { Accept: "application/json" };
+    if (body !== undefined) {
+      headers["Content-Type"] = contentType;
+    }
+    if (this.token) {
+      headers["Authorization"] = "Bearer " + this.token;
+    }
+    if (this.consistency) {
+      headers["X-Consistency-Token"] = this.consistency;
+    }
+
+    const res = await fetch(url, { method, headers, body });
+    this.consistency = res.headers.get("X-Consistency-Token") ?? this.consistency;
+    const data = await res.json().catch(() => ({}));
+    if (!res.ok) {
+      throw new GreenlightError(res.status, data.error ?? res.statusText, data.code, data.fields);
+    }
+
+    return data as T;
+  }
+{{range .Endpoints}}
+  /** {{.Doc}}. {{.Method}} {{.Path}} */
+  {{lowerFirst .Name}}(
+  {{- range $i, $p := .Params}}{{if $i}}, {{end}}{{$p.Name}}: {{if eq $p.Type "string"}}string{{else}}number{{end}}{{end}}
+  {{- if .Query}}{{if .Params}}, {{end}}query?: Record<string, string>{{end}}
+  {{- if .Body}}{{if or .Params .Query}}, {{end}}input: {{tsType .Body}}{{end}}): Promise<{{.Response}}> {
+    return this.request("{{.Method}}", ` + "`{{.TSPath}}`" + `, {{if .Query}}query{{else}}undefined{{end}}
+    {{- if .NDJSON}}, input.map((item) => JSON.stringify(item)).join("\n") + "\n", "application/x-ndjson"
+    {{- else if .Body}}, JSON.stringify(input){{end}});
+  }
+{{end}}}
+`
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Define a target struct describing a kind of request sent during the load test
+type target struct {
+	name    string
+	request func() (*http.Request, error)
+}
+
+// Define a result struct holding the outcome of a single request
+type result struct {
+	target  string
+	latency time.Duration
+	failed  bool
+}
+
+// Define a config struct holding the settings of a load test run
+type config struct {
+	addr         string
+	token        string
+	email        string
+	password     string
+	seed         int
+	rate         int
+	duration     time.Duration
+	timeout      time.Duration
+	p95Budget    time.Duration
+	maxErrorRate float64
+}
+
+// The loadtest command sends a steady rate of list, show and create movie requests to
+// a running instance of the API and exits with a non-zero status if the p95 latency or
+// the error rate of any endpoint is over budget. The rate limiter of the API under test
+// should be disabled, as rejected requests count as errors.
+func main() {
+	var cfg config
+
+	flag.StringVar(&cfg.addr, "addr", "http://localhost:4000", "Base URL of the API")
+	flag.StringVar(&cfg.token, "token", os.Getenv("GREENLIGHT_LOADTEST_TOKEN"), "Authentication token with the movies:read and movies:write permissions")
+	flag.StringVar(&cfg.email, "email", "", "Email address used to create an authentication token, if -token is empty")
+	flag.StringVar(&cfg.password, "password", "", "Password used to create an authentication token, if -token is empty")
+	flag.IntVar(&cfg.seed, "seed", 100, "Number of movies to create before the test starts")
+	flag.IntVar(&cfg.rate, "rate", 50, "Requests per second")
+	flag.DurationVar(&cfg.duration, "duration", 30*time.Second, "Duration of the test")
+	flag.DurationVar(&cfg.timeout, "timeout", 5*time.Second, "Timeout for each request")
+	flag.DurationVar(&cfg.p95Budget, "p95", 200*time.Millisecond, "Maximum p95 latency for each endpoint")
+	flag.Float64Var(&cfg.maxErrorRate, "max-error-rate", 0.01, "Maximum ratio of failed requests for each endpoint")
+	flag.Parse()
+
+	client := &http.Client{Timeout: cfg.timeout}
+
+	err := run(client, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Authenticate, seed the database and run the load test, returning an error if setup
+// fails or a budget is exceeded
+func run(client *http.Client, cfg config) error {
+	cfg.addr = strings.TrimSuffix(cfg.addr, "/")
+
+	if cfg.token == "" {
+		token, err := authenticate(client, cfg)
+		if err != nil {
+			return fmt.Errorf("authenticate: %w", err)
+		}
+
+		cfg.token = token
+	}
+
+	if cfg.rate <= 0 {
+		return fmt.Errorf("rate must be greater than zero")
+	}
+
+	fmt.Printf("Seeding %d movies...\n", cfg.seed)
+
+	ids := make([]int64, 0, cfg.seed)
+
+	for i := 0; i < cfg.seed; i++ {
+		id, err := createMovie(client, cfg, fmt.Sprintf("Load Test Seed %d", i))
+		if err != nil {
+			return fmt.Errorf("seed: %w", err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	targets := []target{
+		{
+			name: "list",
+			request: func() (*http.Request, error) {
+				return newRequest(cfg, http.MethodGet, "/v1/movies?page_size=20&sort=-id", nil)
+			},
+		},
+		{
+			name: "create",
+			request: func() (*http.Request, error) {
+				return newRequest(cfg, http.MethodPost, "/v1/movies", movieBody("Load Test Movie"))
+			},
+		},
+	}
+
+	if len(ids) > 0 {
+		targets = append(targets, target{
+			name: "show",
+			request: func() (*http.Request, error) {
+				id := ids[rand.Intn(len(ids))]
+				return newRequest(cfg, http.MethodGet, "/v1/movies/"+strconv.FormatInt(id, 10), nil)
+			},
+		})
+	}
+
+	fmt.Printf("Sending %d requests/s for %s...\n", cfg.rate, cfg.duration)
+
+	results := attack(client, targets, cfg.rate, cfg.duration)
+
+	return report(results, targets, cfg)
+}
+
+// Send requests to the targets in turn at a constant rate for the given duration.
+// Requests are sent on schedule whether or not earlier ones have completed, so a slow
+// server can't reduce the load it is under.
+func attack(client *http.Client, targets []target, rate int, duration time.Duration) []result {
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+
+	deadline := time.After(duration)
+
+	var (
+		mutex   sync.Mutex
+		wg      sync.WaitGroup
+		results []result
+	)
+
+	for i := 0; ; i++ {
+		select {
+		case <-deadline:
+			wg.Wait()
+			return results
+		case <-ticker.C:
+			t := targets[i%len(targets)]
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				r := hit(client, t)
+
+				mutex.Lock()
+				results = append(results, r)
+				mutex.Unlock()
+			}()
+		}
+	}
+}
+
+// Send a single request to a target and record its latency and outcome. Responses with
+// a 4xx or 5xx status count as failures.
+func hit(client *http.Client, t target) result {
+	req, err := t.request()
+	if err != nil {
+		return result{target: t.name, failed: true}
+	}
+
+	start := time.Now()
+
+	res, err := client.Do(req)
+	if err != nil {
+		return result{target: t.name, latency: time.Since(start), failed: true}
+	}
+
+	defer res.Body.Close()
+
+	// Read the whole body, so the latency includes the time taken to send it
+	_, err = io.Copy(io.Discard, res.Body)
+
+	return result{
+		target:  t.name,
+		latency: time.Since(start),
+		failed:  err != nil || res.StatusCode >= 400,
+	}
+}
+
+// Print the latency percentiles and error rate for each target, returning an error if
+// any of them is over budget
+func report(results []result, targets []target, cfg config) error {
+	byTarget := make(map[string][]result)
+	for _, r := range results {
+		byTarget[r.target] = append(byTarget[r.target], r)
+	}
+
+	fmt.Printf("\n%-8s %8s %10s %10s %10s %8s\n", "endpoint", "requests", "p50", "p95", "p99", "errors")
+
+	var violations []string
+
+	for _, t := range targets {
+		rs := byTarget[t.name]
+		if len(rs) == 0 {
+			continue
+		}
+
+		latencies := make([]time.Duration, len(rs))
+		failures := 0
+
+		for i, r := range rs {
+			latencies[i] = r.latency
+			if r.failed {
+				failures++
+			}
+		}
+
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		p95 := percentile(latencies, 0.95)
+		errorRate := float64(failures) / float64(len(rs))
+
+		fmt.Printf("%-8s %8d %10s %10s %10s %7.2f%%\n", t.name, len(rs),
+			percentile(latencies, 0.50).Round(time.Microsecond), p95.Round(time.Microsecond),
+			percentile(latencies, 0.99).Round(time.Microsecond), errorRate*100)
+
+		if p95 > cfg.p95Budget {
+			violations = append(violations, fmt.Sprintf("%s: p95 latency %s exceeds budget of %s", t.name, p95.Round(time.Microsecond), cfg.p95Budget))
+		}
+
+		if errorRate > cfg.maxErrorRate {
+			violations = append(violations, fmt.Sprintf("%s: error rate %.2f%% exceeds budget of %.2f%%", t.name, errorRate*100, cfg.maxErrorRate*100))
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("\nbudgets exceeded:\n  %s", strings.Join(violations, "\n  "))
+	}
+
+	fmt.Println("\nAll endpoints within budget")
+
+	return nil
+}
+
+// Return the value at the given quantile of a sorted slice of latencies
+func percentile(sorted []time.Duration, quantile float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	index := int(float64(len(sorted))*quantile+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+
+	return sorted[index]
+}
+
+// Create a request to the API, authenticated with the configured token
+func newRequest(cfg config, method, path string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, cfg.addr+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+cfg.token)
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return req, nil
+}
+
+// Return the JSON body for creating a movie with the given title
+func movieBody(title string) []byte {
+	body, _ := json.Marshal(map[string]interface{}{
+		"title":   title,
+		"year":    2020,
+		"runtime": "120 mins",
+		"genres":  []string{"drama", "thriller"},
+	})
+
+	return body
+}
+
+// Create a movie through the API and return its ID
+func createMovie(client *http.Client, cfg config, title string) (int64, error) {
+	req, err := newRequest(cfg, http.MethodPost, "/v1/movies", movieBody(title))
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Movie struct {
+			ID int64 `json:"id"`
+		} `json:"movie"`
+	}
+
+	err = do(client, req, http.StatusCreated, &response)
+	if err != nil {
+		return 0, err
+	}
+
+	return response.Movie.ID, nil
+}
+
+// Create an authentication token using the configured email address and password
+func authenticate(client *http.Client, cfg config) (string, error) {
+	if cfg.email == "" || cfg.password == "" {
+		return "", fmt.Errorf("either -token or both -email and -password must be provided")
+	}
+
+	body, err := json.Marshal(map[string]string{"email": cfg.email, "password": cfg.password})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, cfg.addr+"/v1/tokens/authentication", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	var response struct {
+		AuthenticationToken struct {
+			Token string `json:"token"`
+		} `json:"authentication_token"`
+	}
+
+	err = do(client, req, http.StatusCreated, &response)
+	if err != nil {
+		return "", err
+	}
+
+	return response.AuthenticationToken.Token, nil
+}
+
+// Send a request, check that the response has the expected status and decode its JSON
+// body into dst
+func do(client *http.Client, req *http.Request, status int, dst interface{}) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != status {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("%s %s: unexpected status %d: %s", req.Method, req.URL.Path, res.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return json.NewDecoder(res.Body).Decode(dst)
+}
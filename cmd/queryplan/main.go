@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+
+	_ "github.com/lib/pq"
+)
+
+// Define a planCase struct describing a movie search whose query plan we want to check,
+// along with a string which must not appear in the plan
+type planCase struct {
+	name      string
+	search    data.MovieSearch
+	forbidden string
+}
+
+// The queryplan command checks the query plans of the movie search queries against a
+// migrated database, and exits with a non-zero status if a search can no longer use
+// its index. Run it after changing the movies table or the GetAll() query, so that list
+// and search latency doesn't silently regress.
+func main() {
+	dsn := flag.String("db-dsn", os.Getenv("GREENLIGHT_DB_DSN"), "PostgreSQL DSN")
+	verbose := flag.Bool("verbose", false, "Print the full query plan for each case")
+	flag.Parse()
+
+	db, err := sql.Open("postgres", *dsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	defer db.Close()
+
+	cases := []planCase{
+		{name: "title search", search: data.MovieSearch{Title: "black panther"}, forbidden: "Seq Scan on movies"},
+		{name: "genre filter", search: data.MovieSearch{Genres: []string{"action"}}, forbidden: "Seq Scan on movies"},
+		{name: "title and genre", search: data.MovieSearch{Title: "panther", Genres: []string{"action", "adventure"}}, forbidden: "Seq Scan on movies"},
+		{name: "synopsis search", search: data.MovieSearch{Synopsis: "wakanda"}, forbidden: "Seq Scan on movies"},
+		{name: "language filter", search: data.MovieSearch{Language: "en"}, forbidden: "Seq Scan on movies"},
+		{name: "country filter", search: data.MovieSearch{Countries: []string{"US"}}, forbidden: "Seq Scan on movies"},
+		{name: "certification", search: data.MovieSearch{Certifications: data.Certifications{"US": "PG-13"}}, forbidden: "Seq Scan on movies"},
+	}
+
+	failed := false
+
+	for _, c := range cases {
+		filters := data.Filters{
+			Page:         1,
+			PageSize:     20,
+			Sort:         "id",
+			SortSafelist: []string{"id"},
+		}
+
+		plan, err := data.ExplainGetAllMovies(context.Background(), db, c.search, filters)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", c.name, err)
+			os.Exit(1)
+		}
+
+		status := "ok"
+		if strings.Contains(plan, c.forbidden) {
+			status = "REGRESSION: plan contains " + c.forbidden
+			failed = true
+		}
+
+		fmt.Printf("%-20s %s\n", c.name, status)
+
+		if *verbose || status != "ok" {
+			fmt.Println(plan)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
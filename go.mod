@@ -1,6 +1,6 @@
 module github.com/LuisBarroso37/Greenlight
 
-go 1.18
+go 1.24
 
 require github.com/julienschmidt/httprouter v1.3.0
 
@@ -8,14 +8,19 @@ require github.com/lib/pq v1.10.6
 
 require (
 	github.com/felixge/httpsnoop v1.0.3
+	github.com/quic-go/quic-go v0.59.1
 	github.com/tomasen/realip v0.0.0-20180522021738-f0c99a92ddce
+	github.com/toorop/go-dkim v0.0.0-20201103131630-e1cd1a0a5208
 	github.com/xhit/go-simple-mail/v2 v2.11.0
-	golang.org/x/crypto v0.0.0-20220525230936-793ad666bf5e
+	golang.org/x/crypto v0.41.0
 	golang.org/x/time v0.0.0-20220411224347-583f2d630306
 )
 
 require (
 	github.com/go-test/deep v1.0.8 // indirect
-	github.com/stretchr/testify v1.7.4 // indirect
-	github.com/toorop/go-dkim v0.0.0-20201103131630-e1cd1a0a5208 // indirect
+	github.com/quic-go/qpack v0.6.0 // indirect
+	github.com/stretchr/testify v1.11.1 // indirect
+	golang.org/x/net v0.43.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
 )
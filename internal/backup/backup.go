@@ -0,0 +1,157 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// A Storage keeps the database dumps. Put stores a dump under the given name, returning
+// its size in bytes, and Delete removes one. Deleting a dump which doesn't exist isn't
+// an error, so that failed backups can be cleaned up like any other.
+type Storage interface {
+	Put(ctx context.Context, name string, r io.Reader) (int64, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// Define a Dir type which stores the dumps as files in a local directory, such as a
+// mounted volume which is itself backed up or synced elsewhere
+type Dir struct {
+	Path string
+}
+
+// Write a dump to the directory. The dump is written to a temporary file which is only
+// renamed once it is complete, so that a failed or interrupted dump never leaves a file
+// behind which looks like a backup.
+func (d Dir) Put(ctx context.Context, name string, r io.Reader) (int64, error) {
+	err := os.MkdirAll(d.Path, 0o700)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.CreateTemp(d.Path, "."+name+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+
+	defer os.Remove(file.Name())
+
+	size, err := io.Copy(file, r)
+	if err != nil {
+		file.Close()
+		return 0, err
+	}
+
+	err = file.Sync()
+	if err != nil {
+		file.Close()
+		return 0, err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	err = os.Rename(file.Name(), filepath.Join(d.Path, name))
+	if err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// Remove a dump from the directory
+func (d Dir) Delete(ctx context.Context, name string) error {
+	err := os.Remove(filepath.Join(d.Path, name))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Define a Dumper type which dumps a PostgreSQL database with pg_dump, in its custom
+// format so that the dump is compressed and can be restored selectively with
+// pg_restore
+type Dumper struct {
+	// The path of the pg_dump binary, which is looked up in the PATH if it has no slashes
+	Command string
+	DSN     string
+}
+
+// Dump the database and store the dump under the given name, returning its size in
+// bytes. The dump is streamed straight into the storage rather than buffered.
+func (d Dumper) Dump(ctx context.Context, storage Storage, name string) (int64, error) {
+	dsn, password := splitPassword(d.DSN)
+
+	// Pass the password through the environment, so that it doesn't show up in the
+	// process list
+	cmd := exec.CommandContext(ctx, d.Command, "--format=custom", "--no-owner", "--no-privileges", "--dbname="+dsn)
+	cmd.Env = os.Environ()
+	if password != "" {
+		cmd.Env = append(cmd.Env, "PGPASSWORD="+password)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return 0, err
+	}
+
+	size, putErr := storage.Put(ctx, name, stdout)
+
+	// Drain whatever the storage didn't read, so that pg_dump isn't left blocked on a
+	// full pipe and Wait() can return
+	io.Copy(io.Discard, stdout)
+
+	err = cmd.Wait()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = fmt.Errorf("%w: %s", err, message)
+		}
+
+		// The storage has already kept the dump, but it is incomplete
+		storage.Delete(ctx, name)
+
+		return 0, fmt.Errorf("pg_dump: %w", err)
+	}
+
+	if putErr != nil {
+		return 0, putErr
+	}
+
+	return size, nil
+}
+
+// Remove the password from a DSN in the URL format, returning the DSN without it and
+// the password. DSNs in the keyword/value format are returned as they are.
+func splitPassword(dsn string) (string, string) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil {
+		return dsn, ""
+	}
+
+	password, ok := u.User.Password()
+	if !ok {
+		return dsn, ""
+	}
+
+	u.User = url.User(u.User.Username())
+
+	return u.String(), password
+}
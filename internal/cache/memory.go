@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Define a memoryEntry struct holding a cached value along with the time it expires
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// Define a Memory type which is an in-process cache with the same methods as the Redis
+// client, for deployments without Redis. Each instance of the application has its own
+// copy, so deleting a key only affects the instance it is deleted on.
+type Memory struct {
+	maxEntries int
+	mutex      sync.Mutex
+	entries    map[string]memoryEntry
+}
+
+// Return a new Memory cache holding up to maxEntries values
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{
+		maxEntries: maxEntries,
+		entries:    make(map[string]memoryEntry),
+	}
+}
+
+// Get returns the value stored for key, or ErrCacheMiss if there isn't an unexpired one
+func (m *Memory) Get(key string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry, found := m.entries[key]
+	if !found {
+		return nil, ErrCacheMiss
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(m.entries, key)
+		return nil, ErrCacheMiss
+	}
+
+	return entry.value, nil
+}
+
+// Set stores value for key, expiring it after the given ttl. When the cache is full,
+// expired entries are removed first, and if that doesn't free up any space the cache is
+// cleared.
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, found := m.entries[key]; !found && len(m.entries) >= m.maxEntries {
+		now := time.Now()
+
+		for k, entry := range m.entries {
+			if now.After(entry.expires) {
+				delete(m.entries, k)
+			}
+		}
+
+		if len(m.entries) >= m.maxEntries {
+			m.entries = make(map[string]memoryEntry)
+		}
+	}
+
+	m.entries[key] = memoryEntry{value: value, expires: time.Now().Add(ttl)}
+
+	return nil
+}
+
+// Delete removes the given keys. Keys which don't exist are ignored.
+func (m *Memory) Delete(keys ...string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+
+	return nil
+}
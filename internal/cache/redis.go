@@ -0,0 +1,317 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// We'll return this from Get() when there is no value stored for a key
+var ErrCacheMiss = errors.New("cache miss")
+
+// Define a Redis type which is a minimal client for the subset of Redis commands that
+// we need for caching. It speaks the RESP protocol directly and keeps a small pool of
+// idle connections which are reused between commands.
+type Redis struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+	pool     chan *conn
+}
+
+// Define a conn struct which pairs a network connection with a buffered reader for
+// parsing replies
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Return a new Redis client for the server at addr. The password can be empty if the
+// server doesn't require authentication. At most poolSize idle connections are kept.
+func New(addr, password string, db, poolSize int) *Redis {
+	return &Redis{
+		addr:     addr,
+		password: password,
+		db:       db,
+		timeout:  time.Second,
+		pool:     make(chan *conn, poolSize),
+	}
+}
+
+// Get returns the value stored for key, or ErrCacheMiss if there isn't one
+func (r *Redis) Get(key string) ([]byte, error) {
+	reply, err := r.do("GET", key)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply == nil {
+		return nil, ErrCacheMiss
+	}
+
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply type %T for GET", reply)
+	}
+
+	return value, nil
+}
+
+// Set stores value for key, expiring it after the given ttl
+func (r *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	_, err := r.do("SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+
+	return err
+}
+
+// Delete removes the given keys. Keys which don't exist are ignored.
+func (r *Redis) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_, err := r.do(append([]string{"DEL"}, keys...)...)
+
+	return err
+}
+
+// Keys returns the keys matching the given glob-style pattern. It iterates with SCAN
+// rather than using KEYS, so that the server isn't blocked while a large keyspace is
+// searched.
+func (r *Redis) Keys(pattern string) ([]string, error) {
+	var keys []string
+
+	cursor := "0"
+
+	for {
+		reply, err := r.do("SCAN", cursor, "MATCH", pattern, "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+
+		elements, ok := reply.([]interface{})
+		if !ok || len(elements) != 2 {
+			return nil, fmt.Errorf("redis: unexpected reply for SCAN")
+		}
+
+		next, ok := elements[0].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("redis: unexpected cursor type %T for SCAN", elements[0])
+		}
+
+		batch, ok := elements[1].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("redis: unexpected keys type %T for SCAN", elements[1])
+		}
+
+		for _, key := range batch {
+			if key, ok := key.([]byte); ok {
+				keys = append(keys, string(key))
+			}
+		}
+
+		cursor = string(next)
+		if cursor == "0" {
+			return keys, nil
+		}
+	}
+}
+
+// Ping checks that the Redis server can be reached
+func (r *Redis) Ping() error {
+	_, err := r.do("PING")
+
+	return err
+}
+
+// Close closes all the idle connections in the pool
+func (r *Redis) Close() error {
+	for {
+		select {
+		case c := <-r.pool:
+			c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// Send a command to the server and return its reply. Connections which return an
+// error are closed rather than returned to the pool, as they may be left in an
+// unknown state.
+func (r *Redis) do(args ...string) (interface{}, error) {
+	c, err := r.get()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := c.command(r.timeout, args...)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	r.put(c)
+
+	// Errors returned by the server (such as a wrong type error) are protocol-level
+	// replies, so the connection can still be reused
+	if serverErr, ok := reply.(redisError); ok {
+		return nil, serverErr
+	}
+
+	return reply, nil
+}
+
+// Take an idle connection from the pool, or dial a new one if the pool is empty
+func (r *Redis) get() (*conn, error) {
+	select {
+	case c := <-r.pool:
+		return c, nil
+	default:
+	}
+
+	netConn, err := net.DialTimeout("tcp", r.addr, r.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &conn{Conn: netConn, reader: bufio.NewReader(netConn)}
+
+	// Authenticate and select the database for new connections
+	setup := [][]string{}
+	if r.password != "" {
+		setup = append(setup, []string{"AUTH", r.password})
+	}
+	if r.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+
+	for _, args := range setup {
+		reply, err := c.command(r.timeout, args...)
+		if err == nil {
+			if serverErr, ok := reply.(redisError); ok {
+				err = serverErr
+			}
+		}
+
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Return a connection to the pool, closing it if the pool is already full
+func (r *Redis) put(c *conn) {
+	select {
+	case r.pool <- c:
+	default:
+		c.Close()
+	}
+}
+
+// Define a redisError type for error replies sent by the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// Write a command as a RESP array of bulk strings and read the reply
+func (c *conn) command(timeout time.Duration, args ...string) (interface{}, error) {
+	err := c.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := make([]byte, 0, 64)
+	buffer = append(buffer, '*')
+	buffer = strconv.AppendInt(buffer, int64(len(args)), 10)
+	buffer = append(buffer, '\r', '\n')
+
+	for _, arg := range args {
+		buffer = append(buffer, '$')
+		buffer = strconv.AppendInt(buffer, int64(len(arg)), 10)
+		buffer = append(buffer, '\r', '\n')
+		buffer = append(buffer, arg...)
+		buffer = append(buffer, '\r', '\n')
+	}
+
+	_, err = c.Write(buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.readReply()
+}
+
+// Read a single RESP reply. Simple strings and bulk strings are returned as []byte,
+// integers as int64, arrays as []interface{}, null replies as nil and error replies
+// as a redisError value.
+func (c *conn) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: malformed reply")
+	}
+
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return []byte(payload), nil
+	case '-':
+		return redisError(payload), nil
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if length < 0 {
+			return nil, nil
+		}
+
+		// Read the value along with its trailing \r\n
+		value := make([]byte, length+2)
+		_, err = io.ReadFull(c.reader, value)
+		if err != nil {
+			return nil, err
+		}
+
+		return value[:length], nil
+	case '*':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if length < 0 {
+			return nil, nil
+		}
+
+		elements := make([]interface{}, length)
+		for i := range elements {
+			elements[i], err = c.readReply()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return elements, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %q", kind)
+	}
+}
@@ -0,0 +1,60 @@
+// Package cdn purges cached API responses from a content delivery network. Responses
+// are tagged with surrogate keys (cache tags) when they are served, and every response
+// tagged with a key is purged at once when the data behind it changes.
+package cdn
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// The most keys purged by a single request. Both Fastly and Cloudflare accept at
+// least this many.
+const maxKeysPerRequest = 30
+
+// Define a Purger interface for the CDNs which cached responses can be purged from
+type Purger interface {
+	Purge(ctx context.Context, keys ...string) error
+}
+
+// Split keys into batches of at most maxKeysPerRequest keys
+func batches(keys []string) [][]string {
+	var result [][]string
+
+	for len(keys) > maxKeysPerRequest {
+		result = append(result, keys[:maxKeysPerRequest])
+		keys = keys[maxKeysPerRequest:]
+	}
+
+	if len(keys) > 0 {
+		result = append(result, keys)
+	}
+
+	return result
+}
+
+// Send a request, returning an error including the start of the response body if it
+// doesn't succeed
+func send(client *http.Client, req *http.Request, provider string) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s returned unexpected status %d: %s", provider, res.StatusCode, body)
+	}
+
+	return nil
+}
+
+// Return the HTTP client used to call the CDN APIs
+func newClient() *http.Client {
+	return &http.Client{Timeout: 10 * time.Second}
+}
@@ -0,0 +1,53 @@
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// Define a Cloudflare type which purges responses from a Cloudflare zone by cache tag
+type Cloudflare struct {
+	baseURL  string
+	zoneID   string
+	apiToken string
+	client   *http.Client
+}
+
+// Return a new Cloudflare purger for the given zone, authenticating with an API token
+// which has the Cache Purge permission
+func NewCloudflare(zoneID, apiToken string) *Cloudflare {
+	return &Cloudflare{
+		baseURL:  "https://api.cloudflare.com/client/v4",
+		zoneID:   zoneID,
+		apiToken: apiToken,
+		client:   newClient(),
+	}
+}
+
+// Purge removes every response tagged with one of the keys from the cache
+func (c *Cloudflare) Purge(ctx context.Context, keys ...string) error {
+	for _, batch := range batches(keys) {
+		body, err := json.Marshal(map[string][]string{"tags": batch})
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/zones/"+url.PathEscape(c.zoneID)+"/purge_cache", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+c.apiToken)
+		req.Header.Set("Content-Type", "application/json")
+
+		err = send(c.client, req, "cloudflare")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
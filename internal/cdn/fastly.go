@@ -0,0 +1,50 @@
+package cdn
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Define a Fastly type which purges responses from a Fastly service by surrogate key
+type Fastly struct {
+	baseURL   string
+	serviceID string
+	apiToken  string
+	client    *http.Client
+}
+
+// Return a new Fastly purger for the given service, authenticating with an API token
+// which has the purge_select scope
+func NewFastly(serviceID, apiToken string) *Fastly {
+	return &Fastly{
+		baseURL:   "https://api.fastly.com",
+		serviceID: serviceID,
+		apiToken:  apiToken,
+		client:    newClient(),
+	}
+}
+
+// Purge marks every response tagged with one of the keys as stale, using a soft purge
+// so that Fastly can keep serving the stale response if the API can't be reached
+func (f *Fastly) Purge(ctx context.Context, keys ...string) error {
+	for _, batch := range batches(keys) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.baseURL+"/service/"+url.PathEscape(f.serviceID)+"/purge", nil)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Fastly-Key", f.apiToken)
+		req.Header.Set("Fastly-Soft-Purge", "1")
+		req.Header.Set("Surrogate-Key", strings.Join(batch, " "))
+		req.Header.Set("Accept", "application/json")
+
+		err = send(f.client, req, "fastly")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
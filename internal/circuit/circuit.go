@@ -0,0 +1,241 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrOpen is matched by the errors returned while a breaker is open, and can be checked
+// for with errors.Is()
+var ErrOpen = errors.New("circuit: breaker is open")
+
+// Define an OpenError type returned by a breaker which is open, saying when the
+// dependency will next be tried
+type OpenError struct {
+	Name       string
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit: %s breaker is open", e.Name)
+}
+
+// Is makes errors.Is(err, ErrOpen) true for an OpenError
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+// Define a State type for the states of a breaker
+type State int
+
+const (
+	// Calls go through, and consecutive failures are counted
+	Closed State = iota
+	// Calls fail fast until the cooldown has passed
+	Open
+	// A single call is let through to probe the dependency. It closes the breaker if it
+	// succeeds, or opens it again if it fails.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Define a Stats struct holding the state of a breaker, for publishing as a metric
+type Stats struct {
+	State               string `json:"state"`
+	Open                int    `json:"open"` // 1 unless the breaker is closed, for graphing
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Trips               int64  `json:"trips"` // The number of times the breaker has opened
+}
+
+// Define a Breaker type which stops calls to a dependency after a number of consecutive
+// failures, so that callers fail fast instead of piling up retries against a
+// dependency which is down. After the cooldown a single call is let through, and the
+// breaker closes again once one succeeds.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mutex    sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+	trips    int64
+}
+
+// Return a new, closed Breaker which opens after the given number of consecutive
+// failures and stays open for the cooldown. A threshold of zero disables the breaker,
+// so that every call goes through.
+func New(name string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Name returns the name of the dependency the breaker protects
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// Allow returns an *OpenError if a call shouldn't be made. Otherwise the outcome of the
+// call must be passed to Done().
+func (b *Breaker) Allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case Open:
+		remaining := b.cooldown - time.Since(b.openedAt)
+		if remaining > 0 {
+			return &OpenError{Name: b.name, RetryAfter: remaining}
+		}
+
+		b.state = HalfOpen
+		b.probing = true
+
+		return nil
+	case HalfOpen:
+		// Only one probe is let through at a time
+		if b.probing {
+			return &OpenError{Name: b.name, RetryAfter: time.Second}
+		}
+
+		b.probing = true
+
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Done records the outcome of a call allowed by Allow(). Calls cancelled by the caller
+// say nothing about the dependency, so they are neither failures nor successes.
+func (b *Breaker) Done(err error) {
+	b.done(err, errors.Is(err, context.Canceled))
+}
+
+// DoneContext records the outcome of a call made with ctx like Done(). A call which
+// failed after ctx was cancelled or its deadline passed ran out of the caller's time
+// rather than finding the dependency down, so it is neither a failure nor a success.
+// Deadlines which the dependency itself exceeds, such as a dial timeout, still count as
+// failures.
+func (b *Breaker) DoneContext(ctx context.Context, err error) {
+	b.done(err, errors.Is(err, context.Canceled) || (err != nil && ctx.Err() != nil))
+}
+
+// Record the outcome of a call, leaving the failures as they are if it is neutral
+func (b *Breaker) done(err error, neutral bool) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	wasProbe := b.probing
+	b.probing = false
+
+	switch {
+	case err == nil:
+		b.state = Closed
+		b.failures = 0
+	case neutral:
+		return
+	case wasProbe && b.state == HalfOpen:
+		b.open()
+	default:
+		b.failures++
+
+		if b.state == Closed && b.failures >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+// Open the breaker. The mutex must be held by the caller.
+func (b *Breaker) open() {
+	b.state = Open
+	b.openedAt = time.Now()
+	b.trips++
+}
+
+// Do calls fn if the breaker allows it and records the outcome, returning an
+// *OpenError without calling fn if not
+func (b *Breaker) Do(fn func() error) error {
+	err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	b.Done(err)
+
+	return err
+}
+
+// DoContext is like Do() for calls made with ctx, recording the outcome with
+// DoneContext()
+func (b *Breaker) DoContext(ctx context.Context, fn func() error) error {
+	err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	b.DoneContext(ctx, err)
+
+	return err
+}
+
+// State returns the current state of the breaker. An open breaker whose cooldown has
+// passed is reported as half-open, as the next call will be let through.
+func (b *Breaker) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == Open && time.Since(b.openedAt) >= b.cooldown {
+		return HalfOpen
+	}
+
+	return b.state
+}
+
+// Stats returns the state of the breaker for publishing as a metric
+func (b *Breaker) Stats() Stats {
+	state := b.State()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	stats := Stats{
+		State:               state.String(),
+		ConsecutiveFailures: b.failures,
+		Trips:               b.trips,
+	}
+
+	if state != Closed {
+		stats.Open = 1
+	}
+
+	return stats
+}
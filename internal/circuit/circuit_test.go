@@ -0,0 +1,52 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDoContextCallerDeadline(t *testing.T) {
+	errDown := errors.New("connection refused")
+
+	// A caller's expired deadline can't trip the breaker, however often it happens
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	b := New("database", 3, time.Minute)
+
+	for i := 0; i < 10; i++ {
+		b.DoContext(expired, func() error {
+			return context.DeadlineExceeded
+		})
+	}
+
+	if state := b.State(); state != Closed {
+		t.Fatalf("got state %s after calls past the caller's deadline; want closed", state)
+	}
+
+	// A deadline exceeded by the dependency while the caller still had time is a failure
+	for i := 0; i < 3; i++ {
+		b.DoContext(context.Background(), func() error {
+			return context.DeadlineExceeded
+		})
+	}
+
+	if state := b.State(); state != Open {
+		t.Fatalf("got state %s after the dependency timed out; want open", state)
+	}
+
+	// Other failures count as before
+	b = New("database", 3, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		b.DoContext(context.Background(), func() error {
+			return errDown
+		})
+	}
+
+	if state := b.State(); state != Open {
+		t.Errorf("got state %s after consecutive failures; want open", state)
+	}
+}
@@ -0,0 +1,35 @@
+package circuit
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// Define a connector type which wraps a driver.Connector, opening connections through
+// a breaker
+type connector struct {
+	driver.Connector
+	breaker *Breaker
+}
+
+// Connector wraps a database connector so that new connections are opened through the
+// breaker. The breaker sits at the connection rather than around each query, so that
+// it trips when the database can't be reached and not on errors like constraint
+// violations. Once the database goes down the pooled connections fail and are
+// discarded, and the connections opened to replace them fail fast while the breaker
+// is open.
+func Connector(c driver.Connector, breaker *Breaker) driver.Connector {
+	return connector{Connector: c, breaker: breaker}
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	var conn driver.Conn
+
+	err := c.breaker.DoContext(ctx, func() error {
+		var err error
+		conn, err = c.Connector.Connect(ctx)
+		return err
+	})
+
+	return conn, err
+}
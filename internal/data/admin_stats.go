@@ -0,0 +1,142 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// Define a DailyCount struct holding the number of records created on a day
+type DailyCount struct {
+	Date  Date `json:"date"`
+	Count int  `json:"count"`
+}
+
+// Define an AdminStats struct holding the record counts shown on the ops dashboard
+type AdminStats struct {
+	Users struct {
+		Total     int          `json:"total"`
+		Activated int          `json:"activated"`
+		NewPerDay []DailyCount `json:"new_per_day"` // Oldest day first, including days without any new users
+	} `json:"users"`
+	Movies struct {
+		Total int `json:"total"`
+	} `json:"movies"`
+	Reviews struct {
+		Total    int            `json:"total"`
+		ByStatus map[string]int `json:"by_status"`
+	} `json:"reviews"`
+}
+
+// Return the first day of a period of the given number of days ending today
+func statsStartDay(now time.Time, days int) time.Time {
+	year, month, day := now.Date()
+
+	return time.Date(year, month, day, 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1-days)
+}
+
+// Return a count for each day of the period starting on the given day, filled in from
+// the counts keyed by date
+func dailyCounts(start time.Time, days int, counts map[string]int) []DailyCount {
+	daily := make([]DailyCount, days)
+
+	for i := range daily {
+		date := Date{start.AddDate(0, 0, i)}
+		daily[i] = DailyCount{Date: date, Count: counts[date.String()]}
+	}
+
+	return daily
+}
+
+// Define an AdminStatsModel struct type which wraps a sql.DB connection pool
+type AdminStatsModel struct {
+	DB Querier
+}
+
+// Counts the users, movies and reviews, along with the users created on each of the
+// last given number of days
+func (m AdminStatsModel) Get(ctx context.Context, days int) (*AdminStats, error) {
+	stats := &AdminStats{}
+
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM users),
+			(SELECT COUNT(*) FROM users WHERE activated),
+			(SELECT COUNT(*) FROM movies)`
+
+	err := m.DB.QueryRowContext(ctx, query).Scan(
+		&stats.Users.Total,
+		&stats.Users.Activated,
+		&stats.Movies.Total,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	start := statsStartDay(time.Now(), days)
+
+	query = `
+		SELECT to_char(created_at, 'YYYY-MM-DD'), COUNT(*)
+		FROM users
+		WHERE created_at >= $1
+		GROUP BY 1`
+
+	rows, err := m.DB.QueryContext(ctx, query, start)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	counts := make(map[string]int)
+
+	for rows.Next() {
+		var day string
+		var count int
+
+		err := rows.Scan(&day, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[day] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats.Users.NewPerDay = dailyCounts(start, days, counts)
+
+	query = `
+		SELECT status, COUNT(*)
+		FROM reviews
+		GROUP BY status`
+
+	rows, err = m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	stats.Reviews.ByStatus = map[string]int{ReviewPending: 0, ReviewApproved: 0, ReviewRejected: 0}
+
+	for rows.Next() {
+		var status string
+		var count int
+
+		err := rows.Scan(&status, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Reviews.ByStatus[status] = count
+		stats.Reviews.Total += count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
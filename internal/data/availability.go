@@ -0,0 +1,164 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Define a StreamingOffer struct describing one way of watching a movie through a
+// provider. The type is one of "stream" (with a subscription), "free", "ads", "rent"
+// or "buy".
+type StreamingOffer struct {
+	Type         string `json:"type"`
+	ProviderID   int64  `json:"provider_id"`
+	ProviderName string `json:"provider_name"`
+	LogoURL      string `json:"logo_url,omitempty"`
+}
+
+// Define a CountryAvailability struct holding the offers for a movie in one country
+type CountryAvailability struct {
+	Link   string           `json:"link,omitempty"`
+	Offers []StreamingOffer `json:"offers"`
+}
+
+// Define an Availability struct to represent where a movie can be watched in a
+// country, as of the last time it was fetched from the watch-provider API. RefreshedAt
+// is nil if it hasn't been fetched yet.
+type Availability struct {
+	MovieID int64  `json:"movie_id"`
+	Country string `json:"country"`
+	CountryAvailability
+	RefreshedAt *time.Time `json:"refreshed_at"`
+}
+
+// Define an AvailabilityRefresh struct holding what's needed to fetch a movie's
+// availability. ProviderMovieID is the watch-provider API's ID for the movie, or 0 if
+// it hasn't been looked up yet.
+type AvailabilityRefresh struct {
+	MovieID         int64
+	Title           string
+	Year            int32
+	ProviderMovieID int64
+}
+
+// Define an AvailabilityModel struct type which wraps a sql.DB connection pool
+type AvailabilityModel struct {
+	DB Querier
+}
+
+// Fetches the availability of a movie in a country. Countries where the movie isn't
+// available have no offers.
+func (m AvailabilityModel) GetForCountry(ctx context.Context, movieID int64, country string) (*Availability, error) {
+	query := `
+		SELECT countries -> $2, refreshed_at
+		FROM movie_availability
+		WHERE movie_id = $1`
+
+	availability := &Availability{
+		MovieID:             movieID,
+		Country:             country,
+		CountryAvailability: CountryAvailability{Offers: []StreamingOffer{}},
+	}
+
+	var countryJSON []byte
+	var refreshedAt time.Time
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, country).Scan(&countryJSON, &refreshedAt)
+	if err != nil {
+		switch {
+		// The movie's availability hasn't been fetched yet
+		case errors.Is(err, sql.ErrNoRows):
+			return availability, nil
+		default:
+			return nil, err
+		}
+	}
+
+	availability.RefreshedAt = &refreshedAt
+
+	if countryJSON != nil {
+		err = json.Unmarshal(countryJSON, &availability.CountryAvailability)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return availability, nil
+}
+
+// Fetches up to limit movies with IDs greater than afterID whose availability hasn't
+// been fetched since refreshedBefore. Call it repeatedly with the last ID returned to
+// go through every stale movie.
+func (m AvailabilityModel) GetBatchForRefresh(ctx context.Context, refreshedBefore time.Time, afterID int64, limit int) ([]*AvailabilityRefresh, error) {
+	query := `
+		SELECT movies.id, movies.title, movies.year, COALESCE(movie_availability.provider_movie_id, 0)
+		FROM movies
+		LEFT JOIN movie_availability ON movie_availability.movie_id = movies.id
+		WHERE movies.id > $1
+		AND (movie_availability.refreshed_at IS NULL OR movie_availability.refreshed_at < $2)
+		ORDER BY movies.id
+		LIMIT $3`
+
+	rows, err := m.DB.QueryContext(ctx, query, afterID, refreshedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	refreshes := []*AvailabilityRefresh{}
+
+	for rows.Next() {
+		var refresh AvailabilityRefresh
+
+		err := rows.Scan(&refresh.MovieID, &refresh.Title, &refresh.Year, &refresh.ProviderMovieID)
+		if err != nil {
+			return nil, err
+		}
+
+		refreshes = append(refreshes, &refresh)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return refreshes, nil
+}
+
+// Replaces the availability of a movie in every country. A providerMovieID of 0 means
+// that the watch-provider API doesn't know about the movie. It returns
+// ErrRecordNotFound if the movie has been deleted.
+func (m AvailabilityModel) Set(ctx context.Context, movieID, providerMovieID int64, countries map[string]CountryAvailability) error {
+	if countries == nil {
+		countries = map[string]CountryAvailability{}
+	}
+
+	countriesJSON, err := json.Marshal(countries)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO movie_availability (movie_id, provider_movie_id, countries, refreshed_at)
+		VALUES ($1, NULLIF($2, 0), $3, NOW())
+		ON CONFLICT (movie_id) DO UPDATE
+		SET provider_movie_id = EXCLUDED.provider_movie_id, countries = EXCLUDED.countries,
+			refreshed_at = EXCLUDED.refreshed_at`
+
+	// The JSON is sent as text, as pq sends []byte values in binary format
+	_, err = m.DB.ExecContext(ctx, query, movieID, providerMovieID, string(countriesJSON))
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "movie_availability" violates foreign key constraint "movie_availability_movie_id_fkey"`:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
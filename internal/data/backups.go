@@ -0,0 +1,176 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Define the statuses of a database backup. Running backups are still being dumped, and
+// failed backups have no dump.
+const (
+	BackupRunning   = "running"
+	BackupCompleted = "completed"
+	BackupFailed    = "failed"
+)
+
+// Define a Backup struct to represent a dump of the database, which is kept in the
+// backup storage under its name
+type Backup struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Size       int64      `json:"size"` // The size of the dump in bytes
+	Error      string     `json:"error,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Define a BackupModel struct type which wraps a sql.DB connection pool
+type BackupModel struct {
+	DB Querier
+}
+
+// Records a new backup which has started running
+func (m BackupModel) Insert(ctx context.Context, backup *Backup) error {
+	query := `
+		INSERT INTO backups (name)
+		VALUES ($1)
+		RETURNING id, created_at, status`
+
+	return m.DB.QueryRowContext(ctx, query, backup.Name).Scan(&backup.ID, &backup.CreatedAt, &backup.Status)
+}
+
+// Records the outcome of a backup, which is failed if it has an error and completed
+// otherwise
+func (m BackupModel) Finish(ctx context.Context, backup *Backup) error {
+	backup.Status = BackupCompleted
+	if backup.Error != "" {
+		backup.Status = BackupFailed
+	}
+
+	query := `
+		UPDATE backups
+		SET status = $1, size = $2, error = $3, finished_at = NOW()
+		WHERE id = $4
+		RETURNING finished_at`
+
+	return m.DB.QueryRowContext(ctx, query, backup.Status, backup.Size, backup.Error, backup.ID).Scan(&backup.FinishedAt)
+}
+
+// Fetches a page of the backups
+func (m BackupModel) GetAll(ctx context.Context, filters Filters) ([]*Backup, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), id, created_at, name, status, size, error, finished_at
+		FROM backups
+		ORDER BY %s %s, id ASC
+		LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	backups := []*Backup{}
+
+	for rows.Next() {
+		var backup Backup
+
+		err := rows.Scan(
+			&totalRecords,
+			&backup.ID,
+			&backup.CreatedAt,
+			&backup.Name,
+			&backup.Status,
+			&backup.Size,
+			&backup.Error,
+			&backup.FinishedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		backups = append(backups, &backup)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return backups, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Fetches the backups which are no longer needed once the newest keep completed backups
+// are kept: older completed backups, and failed backups older than the newest completed
+// one. Running backups are never returned.
+func (m BackupModel) GetExpired(ctx context.Context, keep int) ([]*Backup, error) {
+	query := `
+		WITH kept AS (
+			SELECT id FROM backups
+			WHERE status = 'completed'
+			ORDER BY id DESC
+			LIMIT $1
+		)
+		SELECT id, created_at, name, status, size, error, finished_at
+		FROM backups
+		WHERE status <> 'running'
+		AND id NOT IN (SELECT id FROM kept)
+		AND id < (SELECT COALESCE(MAX(id), 0) FROM kept)
+		ORDER BY id`
+
+	rows, err := m.DB.QueryContext(ctx, query, keep)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	backups := []*Backup{}
+
+	for rows.Next() {
+		var backup Backup
+
+		err := rows.Scan(
+			&backup.ID,
+			&backup.CreatedAt,
+			&backup.Name,
+			&backup.Status,
+			&backup.Size,
+			&backup.Error,
+			&backup.FinishedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		backups = append(backups, &backup)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return backups, nil
+}
+
+// Deletes the record of a backup
+func (m BackupModel) Delete(ctx context.Context, id int64) error {
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM backups WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package data
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/cache"
+)
+
+// Define a Cache interface for the key/value store used by the caching model
+// decorators. This is satisfied by *cache.Redis and *cache.Memory.
+type Cache interface {
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(keys ...string) error
+}
+
+// Look up a cached value and decode it into target. The first return value reports
+// whether the value was found. Errors other than a cache miss are passed to onError,
+// and are otherwise treated as a miss so that an unavailable cache never fails a
+// request.
+func cacheGet(c Cache, key string, target interface{}, onError func(error)) bool {
+	value, err := c.Get(key)
+	if err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			onError(err)
+		}
+
+		return false
+	}
+
+	// We use gob rather than JSON, as the JSON representation of our types hides some
+	// fields (like User.Version) from clients
+	err = gob.NewDecoder(bytes.NewReader(value)).Decode(target)
+	if err != nil {
+		onError(err)
+		return false
+	}
+
+	return true
+}
+
+// Encode a value and store it in the cache for the given ttl
+func cacheSet(c Cache, key string, value interface{}, ttl time.Duration, onError func(error)) {
+	var buffer bytes.Buffer
+
+	err := gob.NewEncoder(&buffer).Encode(value)
+	if err != nil {
+		onError(err)
+		return
+	}
+
+	err = c.Set(key, buffer.Bytes(), ttl)
+	if err != nil {
+		onError(err)
+	}
+}
+
+// Remove a key from the cache
+func cacheDelete(c Cache, key string, onError func(error)) {
+	err := c.Delete(key)
+	if err != nil {
+		onError(err)
+	}
+}
@@ -0,0 +1,115 @@
+package data
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Define a CachedMovieModel type which wraps another MovieStore with a shared cache
+// (such as Redis). Movies are cached by ID for the given ttl. Updates write the new
+// version of the movie through to the cache and deletes remove it, so the cache never
+// serves a movie that was changed through the API. Changes made in a transaction only
+// reach the cache once it is committed (see OnCommit()).
+type CachedMovieModel struct {
+	MovieStore
+	cache   Cache
+	ttl     time.Duration
+	onError func(error)
+}
+
+// Return a new CachedMovieModel. Cache errors are passed to onError and otherwise
+// ignored, falling back to the wrapped model.
+func NewCachedMovieModel(movies MovieStore, cache Cache, ttl time.Duration, onError func(error)) CachedMovieModel {
+	return CachedMovieModel{
+		MovieStore: movies,
+		cache:      cache,
+		ttl:        ttl,
+		onError:    onError,
+	}
+}
+
+// Return the cache key for a movie
+func movieCacheKey(id int64) string {
+	return "movie:" + strconv.FormatInt(id, 10)
+}
+
+// Fetches a specific movie from the cache, or from the wrapped model on a cache miss
+func (m CachedMovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
+	// Reads made in a transaction may see its uncommitted changes, so they neither use
+	// nor fill the cache
+	if _, inTx := TxFromContext(ctx); inTx {
+		return m.MovieStore.Get(ctx, id)
+	}
+
+	var movie Movie
+
+	if cacheGet(m.cache, movieCacheKey(id), &movie, m.onError) {
+		return &movie, nil
+	}
+
+	// Fill the cache from the primary, so that a lagging replica can't put back a
+	// version which was just replaced
+	cached, err := m.MovieStore.Get(WithoutReplicaReads(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheSet(m.cache, movieCacheKey(id), cached, m.ttl, m.onError)
+
+	return cached, nil
+}
+
+// Updates a specific movie and writes the new version through to the cache
+func (m CachedMovieModel) Update(ctx context.Context, movie *Movie) error {
+	err := m.MovieStore.Update(ctx, movie)
+	if err != nil {
+		// The update may have failed because of an edit conflict, which means our
+		// cached copy could be out of date
+		cacheDelete(m.cache, movieCacheKey(movie.ID), m.onError)
+		return err
+	}
+
+	// The movie is copied, as the caller may go on to change it before the transaction
+	// is committed
+	cached := copyMovie(movie)
+
+	OnCommit(ctx, func() {
+		cacheSet(m.cache, movieCacheKey(cached.ID), cached, m.ttl, m.onError)
+	})
+
+	return nil
+}
+
+// Deletes a specific movie and removes it from the cache
+func (m CachedMovieModel) Delete(ctx context.Context, id int64) error {
+	err := m.MovieStore.Delete(ctx, id)
+	if err != nil {
+		cacheDelete(m.cache, movieCacheKey(id), m.onError)
+		return err
+	}
+
+	OnCommit(ctx, func() {
+		cacheDelete(m.cache, movieCacheKey(id), m.onError)
+	})
+
+	return nil
+}
+
+// Publishes the scheduled movies which are due and writes them through to the cache
+func (m CachedMovieModel) PublishScheduled(ctx context.Context, now time.Time) ([]*Movie, error) {
+	movies, err := m.MovieStore.PublishScheduled(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, movie := range movies {
+		cached := copyMovie(movie)
+
+		OnCommit(ctx, func() {
+			cacheSet(m.cache, movieCacheKey(cached.ID), cached, m.ttl, m.onError)
+		})
+	}
+
+	return movies, nil
+}
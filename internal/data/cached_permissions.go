@@ -0,0 +1,85 @@
+package data
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Define a CachedPermissionModel type which wraps another PermissionStore with a shared
+// cache. Permission lookups happen on every authorized request, so caching them
+// removes a database query from the hot path. Adding or removing permissions for a
+// user invalidates their cached permissions.
+type CachedPermissionModel struct {
+	PermissionStore
+	cache   Cache
+	ttl     time.Duration
+	onError func(error)
+}
+
+// Return a new CachedPermissionModel. Cache errors are passed to onError and otherwise
+// ignored, falling back to the wrapped model.
+func NewCachedPermissionModel(permissions PermissionStore, cache Cache, ttl time.Duration, onError func(error)) CachedPermissionModel {
+	return CachedPermissionModel{
+		PermissionStore: permissions,
+		cache:           cache,
+		ttl:             ttl,
+		onError:         onError,
+	}
+}
+
+// Return the cache key for a user's permissions
+func permissionsCacheKey(userID int64) string {
+	return "permissions:" + strconv.FormatInt(userID, 10)
+}
+
+// Returns all permission codes for a specific user, using the cached copy if there is one
+func (m CachedPermissionModel) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
+	var permissions Permissions
+
+	if cacheGet(m.cache, permissionsCacheKey(userID), &permissions, m.onError) {
+		return permissions, nil
+	}
+
+	// Fill the cache from the primary, so that a lagging replica can't put back the
+	// permissions a user had before they were changed
+	permissions, err := m.PermissionStore.GetAllForUser(WithoutReplicaReads(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheSet(m.cache, permissionsCacheKey(userID), permissions, m.ttl, m.onError)
+
+	return permissions, nil
+}
+
+// Add the provided permission codes for a specific user and invalidate their cached
+// permissions
+func (m CachedPermissionModel) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	err := m.PermissionStore.AddForUser(ctx, userID, codes...)
+
+	cacheDelete(m.cache, permissionsCacheKey(userID), m.onError)
+
+	return err
+}
+
+// Report whether a specific user holds all of the provided permission codes, checking
+// them against the user's cached permissions
+func (m CachedPermissionModel) HasPermission(ctx context.Context, userID int64, codes ...string) (bool, error) {
+	permissions, err := m.GetAllForUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return permissions.IncludeAll(codes...), nil
+}
+
+// Remove the provided permission codes from a specific user and invalidate their cached
+// permissions
+func (m CachedPermissionModel) RemoveForUser(ctx context.Context, userID int64, codes ...string) error {
+	err := m.PermissionStore.RemoveForUser(ctx, userID, codes...)
+
+	cacheDelete(m.cache, permissionsCacheKey(userID), m.onError)
+
+	return err
+}
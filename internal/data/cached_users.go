@@ -0,0 +1,158 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// Define a tokenUserEntry struct holding a cached user along with the time the entry
+// expires
+type tokenUserEntry struct {
+	user    User
+	expires time.Time
+}
+
+// Define a TokenUserCache type which maps authentication token hashes to the users
+// they belong to. It lives in memory, so each instance of the application has its own
+// copy. The ttl bounds how long a revoked or expired token can keep working for, so it
+// should be kept short.
+type TokenUserCache struct {
+	ttl        time.Duration
+	maxEntries int
+	mutex      sync.Mutex
+	entries    map[[sha256.Size]byte]tokenUserEntry
+}
+
+// Return a new TokenUserCache which holds up to maxEntries users for the given ttl
+func NewTokenUserCache(ttl time.Duration, maxEntries int) *TokenUserCache {
+	return &TokenUserCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[[sha256.Size]byte]tokenUserEntry),
+	}
+}
+
+// Return a copy of the cached user for a token hash, if there is an unexpired entry
+func (c *TokenUserCache) get(hash [sha256.Size]byte) (*User, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, found := c.entries[hash]
+	if !found {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(c.entries, hash)
+		return nil, false
+	}
+
+	user := entry.user
+
+	return &user, true
+}
+
+// Store a copy of the user for a token hash. When the cache is full, expired entries
+// are removed first, and if that doesn't free up any space the cache is cleared.
+func (c *TokenUserCache) set(hash [sha256.Size]byte, user *User) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		now := time.Now()
+
+		for key, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, key)
+			}
+		}
+
+		if len(c.entries) >= c.maxEntries {
+			c.entries = make(map[[sha256.Size]byte]tokenUserEntry)
+		}
+	}
+
+	c.entries[hash] = tokenUserEntry{user: *user, expires: time.Now().Add(c.ttl)}
+}
+
+// Remove all cached entries for a user, for example after their password has changed
+// or their tokens have been deleted
+func (c *TokenUserCache) invalidateUser(userID int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, entry := range c.entries {
+		if entry.user.ID == userID {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Define a CachedUserModel type which wraps another UserStore, serving authentication
+// token lookups from a TokenUserCache. Only the authentication scope is cached, as
+// activation and password reset tokens are only used once.
+type CachedUserModel struct {
+	UserStore
+	cache *TokenUserCache
+}
+
+// Return a new CachedUserModel using the given cache
+func NewCachedUserModel(users UserStore, cache *TokenUserCache) CachedUserModel {
+	return CachedUserModel{UserStore: users, cache: cache}
+}
+
+// Fetch user linked to given token, using the cached user for authentication tokens
+func (m CachedUserModel) GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error) {
+	if tokenScope != ScopeAuthentication {
+		return m.UserStore.GetForToken(ctx, tokenScope, tokenPlaintext)
+	}
+
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	if user, found := m.cache.get(hash); found {
+		return user, nil
+	}
+
+	user, err := m.UserStore.GetForToken(ctx, tokenScope, tokenPlaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	m.cache.set(hash, user)
+
+	return user, nil
+}
+
+// Update the details for a specific user and drop their cached entries, so that a
+// changed password or activation status takes effect immediately
+func (m CachedUserModel) Update(ctx context.Context, user *User) error {
+	err := m.UserStore.Update(ctx, user)
+
+	m.cache.invalidateUser(user.ID)
+
+	return err
+}
+
+// Define a CachedTokenModel type which wraps another TokenStore, invalidating the
+// TokenUserCache entries for a user when their tokens are deleted
+type CachedTokenModel struct {
+	TokenStore
+	cache *TokenUserCache
+}
+
+// Return a new CachedTokenModel using the given cache
+func NewCachedTokenModel(tokens TokenStore, cache *TokenUserCache) CachedTokenModel {
+	return CachedTokenModel{TokenStore: tokens, cache: cache}
+}
+
+// DeleteAllForUser() deletes all tokens for a specific user and scope, and drops any
+// cached lookups for the user's tokens
+func (m CachedTokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	err := m.TokenStore.DeleteAllForUser(ctx, scope, userID)
+
+	m.cache.invalidateUser(userID)
+
+	return err
+}
@@ -0,0 +1,85 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Define the age certifications which can be given to a movie in each country, keyed by
+// ISO 3166-1 alpha-2 country code. Movies can only be certified in these countries.
+var CertificationSafelist = map[string][]string{
+	"AU": {"E", "G", "PG", "M", "MA15+", "R18+", "X18+", "RC"},
+	"BR": {"L", "10", "12", "14", "16", "18"},
+	"CA": {"G", "PG", "14A", "18A", "R", "A"},
+	"DE": {"0", "6", "12", "16", "18"},
+	"ES": {"A", "7", "12", "16", "18", "X"},
+	"FR": {"U", "10", "12", "16", "18"},
+	"GB": {"U", "PG", "12A", "12", "15", "18", "R18"},
+	"IN": {"U", "UA", "A", "S"},
+	"JP": {"G", "PG12", "R15+", "R18+"},
+	"US": {"G", "PG", "PG-13", "R", "NC-17"},
+}
+
+// Define a Certifications type holding a movie's age certification in each country,
+// keyed by ISO 3166-1 alpha-2 country code (e.g. {"US": "PG-13", "GB": "12A"}). It is
+// stored in a JSONB column, so that movies can be filtered on a certification using
+// the column's index.
+type Certifications map[string]string
+
+// Implement the driver.Valuer interface, so that certifications can be written to the
+// database. A nil map is stored as an empty object rather than as null.
+func (c Certifications) Value() (driver.Value, error) {
+	if c == nil {
+		return "{}", nil
+	}
+
+	value, err := json.Marshal(map[string]string(c))
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the value as text, as pq sends []byte values in binary format
+	return string(value), nil
+}
+
+// Implement the sql.Scanner interface, so that certifications can be read from the
+// database
+func (c *Certifications) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case []byte:
+		return json.Unmarshal(value, c)
+	case string:
+		return json.Unmarshal([]byte(value), c)
+	default:
+		return errors.New("certifications must be scanned from []byte or string")
+	}
+}
+
+// Run validation checks on certifications, reporting errors under the given key
+func ValidateCertifications(v *validator.Validator, key string, certifications Certifications) {
+	// Check the countries in order, so that the same error is reported every time
+	countries := make([]string, 0, len(certifications))
+	for country := range certifications {
+		countries = append(countries, country)
+	}
+
+	sort.Strings(countries)
+
+	for _, country := range countries {
+		ratings, found := CertificationSafelist[country]
+		if !found {
+			v.AddError(key, fmt.Sprintf("must only contain supported countries (%s is not supported)", country), validator.CodeNotAllowed, validator.Params{"value": country})
+			return
+		}
+
+		if !validator.In(certifications[country], ratings...) {
+			v.AddError(key, fmt.Sprintf("must only contain valid certifications (%s is not valid in %s)", certifications[country], country), validator.CodeNotAllowed, validator.Params{"value": country + ":" + certifications[country]})
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Define an APIClient struct to represent a partner integration which authenticates
+// by signing its requests with a shared secret instead of using a bearer token. The
+// requests are made on behalf of the user the client belongs to, so they are subject
+// to that user's permissions.
+type APIClient struct {
+	ID        int64
+	CreatedAt time.Time
+	Name      string
+	Key       string
+	Secret    []byte
+	UserID    int64
+	Active    bool
+}
+
+// Define the ClientModel type
+type ClientModel struct {
+	DB Querier
+}
+
+// Fetch an active client by its key, along with the user it acts on behalf of
+func (m ClientModel) GetForKey(ctx context.Context, key string) (*APIClient, *User, error) {
+	var client APIClient
+	var user User
+
+	query := `
+        SELECT api_clients.id, api_clients.created_at, api_clients.name, api_clients.key,
+            api_clients.secret, api_clients.user_id, api_clients.active,
+            users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+        FROM api_clients
+        INNER JOIN users ON users.id = api_clients.user_id
+        WHERE api_clients.key = $1
+        AND api_clients.active`
+
+	err := m.DB.QueryRowContext(ctx, query, key).Scan(
+		&client.ID,
+		&client.CreatedAt,
+		&client.Name,
+		&client.Key,
+		&client.Secret,
+		&client.UserID,
+		&client.Active,
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	return &client, &user, nil
+}
@@ -0,0 +1,233 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Define a movieCall struct to represent a Get() call for a movie which is in flight.
+// Concurrent callers for the same ID wait on the WaitGroup and share its result.
+type movieCall struct {
+	wg    sync.WaitGroup
+	movie *Movie
+	err   error
+}
+
+// The error shared with the callers waiting on a query which panicked. The panic itself
+// carries on up the stack of the caller which made the query.
+var errMovieQueryPanicked = errors.New("coalescing: the movie query panicked")
+
+// Define a cached movie entry along with the time at which it expires
+type cachedMovie struct {
+	movie   *Movie
+	expires time.Time
+}
+
+// Define a CoalescingMovieModel type which wraps another MovieStore. Concurrent Get()
+// calls for the same movie ID result in a single query to the wrapped model, and the
+// result is kept in a short-lived micro-cache so that a burst of requests for a popular
+// movie doesn't turn into a burst of database queries. Updates and deletes made through
+// the model invalidate the cached entry, once their transaction has been committed if
+// they are made in one. Expired entries are removed once per ttl, so that movies which
+// are only read once don't stay in memory.
+type CoalescingMovieModel struct {
+	MovieStore
+	ttl       time.Duration
+	mutex     sync.Mutex
+	calls     map[int64]*movieCall
+	cache     map[int64]cachedMovie
+	lastSweep time.Time
+}
+
+// Return a new CoalescingMovieModel wrapping the given model. Results are cached for the
+// given ttl; a ttl of zero disables the micro-cache but still coalesces calls.
+func NewCoalescingMovieModel(movies MovieStore, ttl time.Duration) *CoalescingMovieModel {
+	return &CoalescingMovieModel{
+		MovieStore: movies,
+		ttl:        ttl,
+		calls:      make(map[int64]*movieCall),
+		cache:      make(map[int64]cachedMovie),
+		lastSweep:  time.Now(),
+	}
+}
+
+// Fetches a specific movie, sharing the query with any concurrent callers
+func (m *CoalescingMovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
+	// Reads made in a transaction may see its uncommitted changes, so they aren't shared
+	if _, inTx := TxFromContext(ctx); inTx {
+		return m.MovieStore.Get(ctx, id)
+	}
+
+	m.mutex.Lock()
+
+	// Serve the movie from the micro-cache if we have a fresh copy
+	if entry, found := m.cache[id]; found {
+		if time.Now().Before(entry.expires) {
+			m.mutex.Unlock()
+			return copyMovie(entry.movie), nil
+		}
+
+		delete(m.cache, id)
+	}
+
+	// If there's already a query in flight for this movie, wait for its result. The
+	// query runs with the context of the request which started it, so if that request
+	// was cancelled or ran out of its time budget we try again with our own context.
+	if call, found := m.calls[id]; found {
+		m.mutex.Unlock()
+		call.wg.Wait()
+
+		if (errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) && ctx.Err() == nil {
+			return m.Get(ctx, id)
+		}
+
+		return copyMovie(call.movie), call.err
+	}
+
+	call := &movieCall{}
+	call.wg.Add(1)
+	m.calls[id] = call
+	m.mutex.Unlock()
+
+	m.fetch(ctx, id, call)
+
+	return copyMovie(call.movie), call.err
+}
+
+// Run the query of an in-flight call and cache its result. The call is finished even
+// if the query panics, so that its waiters and later callers don't hang on it.
+func (m *CoalescingMovieModel) fetch(ctx context.Context, id int64, call *movieCall) {
+	completed := false
+
+	defer func() {
+		if !completed {
+			call.movie, call.err = nil, errMovieQueryPanicked
+		}
+
+		m.mutex.Lock()
+
+		// Only cache the result if the movie wasn't updated or deleted while the query
+		// was in flight. In that case invalidate() will have removed our call from the
+		// map.
+		if m.calls[id] == call {
+			delete(m.calls, id)
+
+			if call.err == nil && m.ttl > 0 {
+				now := time.Now()
+
+				m.cache[id] = cachedMovie{movie: call.movie, expires: now.Add(m.ttl)}
+				m.sweep(now)
+			}
+		}
+
+		m.mutex.Unlock()
+
+		call.wg.Done()
+	}()
+
+	// The query always runs on the primary, as its result is shared with callers which
+	// must see their own writes
+	call.movie, call.err = m.MovieStore.Get(WithoutReplicaReads(ctx), id)
+	completed = true
+}
+
+// Remove the expired entries from the micro-cache, at most once per ttl. The mutex
+// must be held.
+func (m *CoalescingMovieModel) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < m.ttl {
+		return
+	}
+
+	for id, entry := range m.cache {
+		if !now.Before(entry.expires) {
+			delete(m.cache, id)
+		}
+	}
+
+	m.lastSweep = now
+}
+
+// Updates a specific movie and invalidates its cached copy
+func (m *CoalescingMovieModel) Update(ctx context.Context, movie *Movie) error {
+	err := m.MovieStore.Update(ctx, movie)
+
+	m.invalidateOnCommit(ctx, movie.ID)
+
+	return err
+}
+
+// Deletes a specific movie and invalidates its cached copy
+func (m *CoalescingMovieModel) Delete(ctx context.Context, id int64) error {
+	err := m.MovieStore.Delete(ctx, id)
+
+	m.invalidateOnCommit(ctx, id)
+
+	return err
+}
+
+// Publishes the scheduled movies which are due and invalidates their cached copies
+func (m *CoalescingMovieModel) PublishScheduled(ctx context.Context, now time.Time) ([]*Movie, error) {
+	movies, err := m.MovieStore.PublishScheduled(ctx, now)
+
+	for _, movie := range movies {
+		m.invalidateOnCommit(ctx, movie.ID)
+	}
+
+	return movies, err
+}
+
+// Remove the cached copy of a movie, and detach any in-flight query for it so that
+// its (possibly stale) result isn't cached
+func (m *CoalescingMovieModel) invalidate(id int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.cache, id)
+	delete(m.calls, id)
+}
+
+// Invalidate a movie once the context's transaction has been committed, as a query
+// made before then would still read the old version of the movie
+func (m *CoalescingMovieModel) invalidateOnCommit(ctx context.Context, id int64) {
+	OnCommit(ctx, func() {
+		m.invalidate(id)
+	})
+}
+
+// Return a copy of a movie, so that callers which modify the movie they were given
+// (like the update handler does) can't change the cached or shared copy
+func copyMovie(movie *Movie) *Movie {
+	if movie == nil {
+		return nil
+	}
+
+	duplicate := *movie
+
+	if movie.Genres != nil {
+		duplicate.Genres = make([]string, len(movie.Genres))
+		copy(duplicate.Genres, movie.Genres)
+	}
+
+	if movie.ProductionCountries != nil {
+		duplicate.ProductionCountries = make([]string, len(movie.ProductionCountries))
+		copy(duplicate.ProductionCountries, movie.ProductionCountries)
+	}
+
+	if movie.Certifications != nil {
+		duplicate.Certifications = make(Certifications, len(movie.Certifications))
+		for country, rating := range movie.Certifications {
+			duplicate.Certifications[country] = rating
+		}
+	}
+
+	if movie.Metadata != nil {
+		duplicate.Metadata = make(MovieMetadata, len(movie.Metadata))
+		for key, value := range movie.Metadata {
+			duplicate.Metadata[key] = value
+		}
+	}
+
+	return &duplicate
+}
@@ -0,0 +1,81 @@
+package data_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+)
+
+// Define a panickingMovieStore type whose Get() panics, once started is closed and
+// release is, for as long as panics is set
+type panickingMovieStore struct {
+	data.MovieStore
+	started chan struct{}
+	release chan struct{}
+	panics  int32
+}
+
+func (s *panickingMovieStore) Get(ctx context.Context, id int64) (*data.Movie, error) {
+	if atomic.LoadInt32(&s.panics) == 1 {
+		close(s.started)
+		<-s.release
+		panic("boom")
+	}
+
+	return &data.Movie{ID: id, Title: "Moana"}, nil
+}
+
+// A query which panics must still finish the call, so that the callers waiting on it
+// and the later callers for the same movie don't hang
+func TestCoalescingMovieModelPanic(t *testing.T) {
+	store := &panickingMovieStore{started: make(chan struct{}), release: make(chan struct{}), panics: 1}
+	movies := data.NewCoalescingMovieModel(store, time.Minute)
+	ctx := context.Background()
+
+	go func() {
+		defer func() { recover() }()
+		movies.Get(ctx, 1)
+	}()
+
+	<-store.started
+
+	waiter := make(chan error, 1)
+
+	go func() {
+		_, err := movies.Get(ctx, 1)
+		waiter <- err
+	}()
+
+	// Give the waiter time to attach to the call in flight
+	time.Sleep(20 * time.Millisecond)
+	atomic.StoreInt32(&store.panics, 0)
+	close(store.release)
+
+	select {
+	case err := <-waiter:
+		if err == nil {
+			t.Error("got no error waiting on a query which panicked")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the caller waiting on a query which panicked is still waiting")
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := movies.Get(ctx, 1)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got error %v after a query panicked; want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("a later caller hangs after a query panicked")
+	}
+}
@@ -0,0 +1,347 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	// We'll return this when a collection would contain a movie which doesn't exist
+	ErrUnknownMovie = errors.New("unknown movie")
+
+	// We'll return this when a collection would contain a movie which already belongs
+	// to another collection. A movie can only be part of one collection.
+	ErrMovieInCollection = errors.New("movie already in a collection")
+)
+
+// Define a Collection struct to represent a group of related movies, such as a
+// franchise. The movies are kept in order, for example by release.
+type Collection struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	MovieIDs    []int64   `json:"movie_ids"`
+	Version     int32     `json:"version"`
+}
+
+// Run validation checks on `Collection` struct
+func ValidateCollection(v *validator.Validator, collection *Collection) {
+	v.Check(collection.Name != "", "name", "must be provided", validator.CodeRequired)
+	v.Check(len(collection.Name) <= 500, "name", "must not be more than 500 bytes long", validator.CodeTooLong, validator.Params{"max": 500})
+
+	v.Check(len(collection.Description) <= 2000, "description", "must not be more than 2000 bytes long", validator.CodeTooLong, validator.Params{"max": 2000})
+
+	v.Check(collection.MovieIDs != nil, "movie_ids", "must be provided", validator.CodeRequired)
+	v.Check(len(collection.MovieIDs) <= 100, "movie_ids", "must not contain more than 100 movies", validator.CodeTooMany, validator.Params{"max": 100})
+	v.Check(validator.Unique(collection.MovieIDs), "movie_ids", "must not contain duplicate values", validator.CodeDuplicate)
+
+	for _, id := range collection.MovieIDs {
+		if id < 1 {
+			v.AddError("movie_ids", "must only contain positive integers", validator.CodeTooSmall, validator.Params{"min": 1})
+			break
+		}
+	}
+}
+
+// Define a CollectionModel struct type which wraps a sql.DB connection pool
+type CollectionModel struct {
+	DB Querier
+}
+
+// Convert the constraint violations caused by a collection's members into our errors
+func collectionMembersError(err error) error {
+	switch {
+	case err.Error() == `pq: insert or update on table "collection_movies" violates foreign key constraint "collection_movies_movie_id_fkey"`:
+		return ErrUnknownMovie
+	case err.Error() == `pq: duplicate key value violates unique constraint "collection_movies_movie_id_key"`:
+		return ErrMovieInCollection
+	default:
+		return err
+	}
+}
+
+// Inserts a new collection along with its members, in a single statement
+func (m CollectionModel) Insert(ctx context.Context, collection *Collection) error {
+	query := `
+		WITH collection AS (
+			INSERT INTO collections (name, description)
+			VALUES ($1, $2)
+			RETURNING id, created_at, version
+		), members AS (
+			INSERT INTO collection_movies (collection_id, movie_id, position)
+			SELECT collection.id, members.movie_id, members.position
+			FROM collection, unnest($3::bigint[]) WITH ORDINALITY AS members (movie_id, position)
+		)
+		SELECT id, created_at, version FROM collection`
+
+	err := m.DB.QueryRowContext(
+		ctx,
+		query,
+		collection.Name,
+		collection.Description,
+		pq.Array(collection.MovieIDs),
+	).Scan(&collection.ID, &collection.CreatedAt, &collection.Version)
+	if err != nil {
+		return collectionMembersError(err)
+	}
+
+	return nil
+}
+
+// Build a query for collections along with the IDs of their movies in order, for the
+// given condition
+func collectionsQuery(where string) string {
+	return fmt.Sprintf(`
+		SELECT collections.id, collections.created_at, collections.name, collections.description,
+			collections.version,
+			COALESCE(array_agg(collection_movies.movie_id ORDER BY collection_movies.position)
+				FILTER (WHERE collection_movies.movie_id IS NOT NULL), '{}')
+		FROM collections
+		LEFT JOIN collection_movies ON collection_movies.collection_id = collections.id
+		WHERE %s
+		GROUP BY collections.id
+		ORDER BY collections.id`, where)
+}
+
+// Scan a row returned by a collectionsQuery() query
+func scanCollection(row interface{ Scan(...interface{}) error }) (*Collection, error) {
+	var collection Collection
+
+	err := row.Scan(
+		&collection.ID,
+		&collection.CreatedAt,
+		&collection.Name,
+		&collection.Description,
+		&collection.Version,
+		pq.Array(&collection.MovieIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
+
+// Fetches a specific collection
+func (m CollectionModel) Get(ctx context.Context, id int64) (*Collection, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	collection, err := scanCollection(m.DB.QueryRowContext(ctx, collectionsQuery("collections.id = $1"), id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return collection, nil
+}
+
+// Fetches the collections which the given movies belong to, keyed by movie ID. Movies
+// which aren't part of a collection are left out.
+func (m CollectionModel) GetForMovies(ctx context.Context, movieIDs []int64) (map[int64]*Collection, error) {
+	collections := make(map[int64]*Collection)
+
+	if len(movieIDs) == 0 {
+		return collections, nil
+	}
+
+	where := `collections.id IN (SELECT collection_id FROM collection_movies WHERE movie_id = ANY($1))`
+
+	rows, err := m.DB.QueryContext(ctx, collectionsQuery(where), pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	wanted := make(map[int64]bool, len(movieIDs))
+	for _, id := range movieIDs {
+		wanted[id] = true
+	}
+
+	for rows.Next() {
+		collection, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range collection.MovieIDs {
+			if wanted[id] {
+				collections[id] = collection
+			}
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// Fetches the movies of a collection, in order
+func (m CollectionModel) GetMovies(ctx context.Context, id int64) ([]*Movie, error) {
+	query := `
+		SELECT ` + movieColumns + `
+		FROM collection_movies
+		INNER JOIN movies ON movies.id = collection_movies.movie_id
+		LEFT JOIN movie_stats ON movie_stats.movie_id = movies.id
+		WHERE collection_movies.collection_id = $1
+		ORDER BY collection_movies.position`
+
+	rows, err := m.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(movieFields(&movie)...)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// Fetches a page of collections, optionally only those whose name contains the given
+// text (ignoring case)
+func (m CollectionModel) GetAll(ctx context.Context, name string, filters Filters) ([]*Collection, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), collections.id, collections.created_at, collections.name,
+			collections.description, collections.version,
+			COALESCE(array_agg(collection_movies.movie_id ORDER BY collection_movies.position)
+				FILTER (WHERE collection_movies.movie_id IS NOT NULL), '{}')
+		FROM collections
+		LEFT JOIN collection_movies ON collection_movies.collection_id = collections.id
+		WHERE collections.name ILIKE '%%' || $1 || '%%'
+		GROUP BY collections.id
+		ORDER BY collections.%s %s, collections.id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	// Escape the LIKE wildcards, so that the name is matched literally
+	name = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(name)
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	collections := []*Collection{}
+
+	for rows.Next() {
+		var collection Collection
+
+		err := rows.Scan(
+			&totalRecords,
+			&collection.ID,
+			&collection.CreatedAt,
+			&collection.Name,
+			&collection.Description,
+			&collection.Version,
+			pq.Array(&collection.MovieIDs),
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		collections = append(collections, &collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return collections, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Updates a collection and replaces its members, in a single statement. The members
+// are only replaced if the collection's version matches.
+func (m CollectionModel) Update(ctx context.Context, collection *Collection) error {
+	query := `
+		WITH collection AS (
+			UPDATE collections
+			SET name = $1, description = $2, version = version + 1
+			WHERE id = $3 AND version = $4
+			RETURNING id, version
+		), removed AS (
+			DELETE FROM collection_movies
+			WHERE collection_id IN (SELECT id FROM collection)
+		), members AS (
+			INSERT INTO collection_movies (collection_id, movie_id, position)
+			SELECT collection.id, members.movie_id, members.position
+			FROM collection, unnest($5::bigint[]) WITH ORDINALITY AS members (movie_id, position)
+		)
+		SELECT version FROM collection`
+
+	err := m.DB.QueryRowContext(
+		ctx,
+		query,
+		collection.Name,
+		collection.Description,
+		collection.ID,
+		collection.Version,
+		pq.Array(collection.MovieIDs),
+	).Scan(&collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return collectionMembersError(err)
+		}
+	}
+
+	return nil
+}
+
+// Deletes a specific collection. Its movies are left in place.
+func (m CollectionModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM collections WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+// Package datatest provides a harness for running the data models against a real
+// PostgreSQL database. The database is taken from the GREENLIGHT_TEST_DB_DSN
+// environment variable (`make db/test/up` starts a disposable one in Docker), and tests
+// using the harness are skipped when it isn't set.
+package datatest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+
+	_ "github.com/lib/pq"
+)
+
+// The name of the environment variable holding the test database DSN
+const dsnEnvVar = "GREENLIGHT_TEST_DB_DSN"
+
+// Migrations only need to be applied once per test binary
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+)
+
+// OpenDB returns a connection pool for the test database with all the up migrations
+// applied and every table emptied, so each test starts from a known state. The pool is
+// closed when the test finishes.
+func OpenDB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv(dsnEnvVar)
+	if dsn == "" {
+		t.Skipf("%s is not set, skipping database tests", dsnEnvVar)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrateOnce.Do(func() {
+		migrateErr = migrate(ctx, db)
+	})
+
+	if migrateErr != nil {
+		t.Fatal(migrateErr)
+	}
+
+	err = Reset(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// NewModels returns models backed by the test database
+func NewModels(t testing.TB) (data.Models, *sql.DB) {
+	t.Helper()
+
+	db := OpenDB(t)
+	models := data.NewModels(db)
+
+	t.Cleanup(func() {
+		models.Close()
+	})
+
+	return models, db
+}
+
+// The tables emptied by Reset(): every table created by the migrations except
+// permissions, whose rows are seeded by the migrations. Tables without a foreign key to
+// users or movies aren't reached by CASCADE, so each table must be listed here.
+var resetTables = []string{
+	"movies", "movie_stats", "movie_releases", "movie_availability", "movie_changes",
+	"collections", "collection_movies", "reviews", "review_reports",
+	"users", "tokens", "users_permissions", "user_devices", "tos_acceptances",
+	"api_clients", "service_accounts", "invitations",
+	"notifications", "notification_preferences", "saved_searches", "digest_subscriptions",
+	"jobs", "outbox_events", "metric_snapshots", "backups", "schema_operations",
+}
+
+// Reset empties the tables written to by the models
+func Reset(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `TRUNCATE `+strings.Join(resetTables, ", ")+` RESTART IDENTITY CASCADE`)
+
+	return err
+}
+
+// Apply every up migration in filename order. The schema is dropped first, so the
+// database always matches the migrations in the working tree. The citext extension,
+// which is normally created when the database is provisioned, is created here too.
+func migrate(ctx context.Context, db *sql.DB) error {
+	statements := []string{
+		`DROP SCHEMA IF EXISTS public CASCADE`,
+		`CREATE SCHEMA public`,
+		`CREATE EXTENSION IF NOT EXISTS citext`,
+	}
+
+	for _, statement := range statements {
+		_, err := db.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(migrationsDir(), "*.up.sql"))
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.ExecContext(ctx, string(contents))
+		if err != nil {
+			return &migrationError{file: filepath.Base(file), err: err}
+		}
+	}
+
+	return nil
+}
+
+// Return the path of the migrations directory at the root of the repository, based on
+// the location of this source file
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "migrations")
+}
+
+// Define a migrationError type which records which migration failed to apply
+type migrationError struct {
+	file string
+	err  error
+}
+
+func (e *migrationError) Error() string {
+	return "applying migration " + e.file + ": " + strings.TrimPrefix(e.err.Error(), "pq: ")
+}
+
+func (e *migrationError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,44 @@
+package datatest
+
+import (
+	"context"
+	"testing"
+)
+
+// Reset() must list every table, so that a table added by a new migration isn't left
+// holding rows from a previous test
+func TestResetCoversEveryTable(t *testing.T) {
+	db := OpenDB(t)
+
+	rows, err := db.QueryContext(context.Background(), `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_type = 'BASE TABLE'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	listed := make(map[string]bool)
+	for _, table := range resetTables {
+		listed[table] = true
+	}
+
+	for rows.Next() {
+		var table string
+
+		err := rows.Scan(&table)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if table != "permissions" && !listed[table] {
+			t.Errorf("table %s isn't emptied by Reset()", table)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,80 @@
+package datatest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+)
+
+// InsertMovie inserts a movie with sensible defaults through the given models and
+// returns it
+func InsertMovie(t testing.TB, models data.Models, title string) *data.Movie {
+	t.Helper()
+
+	movie := &data.Movie{
+		Title:   title,
+		Year:    2020,
+		Runtime: 120,
+		Genres:  []string{"drama"},
+		Status:  data.MoviePublished,
+	}
+
+	err := models.Movie.Insert(context.Background(), movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return movie
+}
+
+// InsertUser inserts an activated user with the given email address and password
+// through the given models and returns it
+func InsertUser(t testing.TB, models data.Models, email, password string) *data.User {
+	t.Helper()
+
+	user := &data.User{
+		Name:      "Test User",
+		Email:     email,
+		Activated: true,
+	}
+
+	err := user.Password.Set(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.User.Insert(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+// Concurrently runs fn in n goroutines which are released at the same time, and
+// returns the error from each call in order. This is used to exercise the
+// version-based edit conflict checks, where exactly one of several concurrent updates
+// to the same record version should succeed.
+func Concurrently(n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+
+	var start, done sync.WaitGroup
+	start.Add(1)
+	done.Add(n)
+
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer done.Done()
+
+			start.Wait()
+			errs[i] = fn(i)
+		}(i)
+	}
+
+	start.Done()
+	done.Wait()
+
+	return errs
+}
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Define an error that our UnmarshalJSON() method can return if we're unable to parse
+// the JSON string as a date
+var ErrInvalidDateFormat = errors.New("invalid date format")
+
+// The layout of dates in JSON and query strings
+const dateLayout = "2006-01-02"
+
+// Define a Date type for calendar dates, such as release dates, which don't have a time
+// of day or time zone. The zero value means that no date was given.
+type Date struct {
+	time.Time
+}
+
+// Parse a date in the "2006-01-02" format
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return Date{}, ErrInvalidDateFormat
+	}
+
+	return Date{t}, nil
+}
+
+// Return the date in the "2006-01-02" format
+func (d Date) String() string {
+	return d.Format(dateLayout)
+}
+
+// Implement the json.Marshaler interface, so that dates are written as "2006-01-02"
+// rather than as timestamps
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// Implement the json.Unmarshaler interface, so that dates are read in the "2006-01-02"
+// format
+func (d *Date) UnmarshalJSON(jsonValue []byte) error {
+	unquotedJsonValue, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	date, err := ParseDate(unquotedJsonValue)
+	if err != nil {
+		return err
+	}
+
+	*d = date
+
+	return nil
+}
+
+// Implement the driver.Valuer interface, so that dates are written to date columns
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Implement the sql.Scanner interface, so that dates can be read from date columns
+func (d *Date) Scan(src interface{}) error {
+	t, ok := src.(time.Time)
+	if !ok {
+		return errors.New("date must be scanned from time.Time")
+	}
+
+	*d = Date{t}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"time"
+)
+
+// Define a Device struct to represent a device a user has signed in from. Devices are
+// told apart by a fingerprint of their user agent and IP address, so the same browser
+// on another network counts as a new device.
+type Device struct {
+	UserID      int64
+	Fingerprint []byte
+	UserAgent   string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+}
+
+// Return the fingerprint of a device with the given user agent and IP address. Only the
+// hash is stored, so the devices table doesn't hold the IP addresses users sign in from.
+func DeviceFingerprint(userAgent, ip string) []byte {
+	hash := sha256.Sum256([]byte(userAgent + "\n" + ip))
+
+	return hash[:]
+}
+
+// Define the DeviceModel type
+type DeviceModel struct {
+	DB Querier
+}
+
+// Record that a user signed in from a device, updating the time it was last seen if it
+// is already known. It reports whether the device is new to a user who has signed in
+// from other devices before, so that a user's first device isn't reported as new.
+func (m DeviceModel) Record(ctx context.Context, device *Device) (bool, error) {
+	query := `
+        WITH known AS (
+            SELECT count(*) AS devices FROM user_devices WHERE user_id = $1
+        ), recorded AS (
+            INSERT INTO user_devices (user_id, fingerprint, user_agent)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (user_id, fingerprint) DO UPDATE SET last_seen = NOW()
+            RETURNING first_seen, last_seen, xmax = 0 AS inserted
+        )
+        SELECT recorded.first_seen, recorded.last_seen, recorded.inserted AND known.devices > 0
+        FROM recorded, known`
+
+	var isNew bool
+
+	err := m.DB.QueryRowContext(ctx, query, device.UserID, device.Fingerprint, device.UserAgent).Scan(&device.FirstSeen, &device.LastSeen, &isNew)
+	if err != nil {
+		return false, err
+	}
+
+	return isNew, nil
+}
@@ -0,0 +1,203 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Define the frequencies at which digest emails can be sent
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// The frequencies a digest subscription can have
+var DigestFrequencies = []string{DigestDaily, DigestWeekly}
+
+// Return the time between two digests sent at the given frequency
+func DigestPeriod(frequency string) time.Duration {
+	if frequency == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+
+	return 24 * time.Hour
+}
+
+// Define a DigestSubscription struct to represent a user's subscription to the digest
+// emails, which list the movies added to the catalog in their favorite genres since
+// the previous digest
+type DigestSubscription struct {
+	UserID       int64     `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	Frequency    string    `json:"frequency"`
+	Genres       []string  `json:"genres"`
+	NextDigestAt time.Time `json:"next_digest_at"`
+	LastMovieID  int64     `json:"-"` // The newest movie the user has already been told about
+	UserName     string    `json:"-"` // Only set by GetBatchDue()
+	UserEmail    string    `json:"-"` // Only set by GetBatchDue()
+}
+
+// Run validation checks on `DigestSubscription` struct
+func ValidateDigestSubscription(v *validator.Validator, subscription *DigestSubscription) {
+	v.Check(subscription.Frequency != "", "frequency", "must be provided", validator.CodeRequired)
+	v.Check(subscription.Frequency == "" || validator.In(subscription.Frequency, DigestFrequencies...), "frequency", "must be daily or weekly", validator.CodeNotOneOf, validator.Params{"allowed": DigestFrequencies})
+
+	v.Check(len(subscription.Genres) >= 1, "genres", "must contain at least 1 genre", validator.CodeTooFew, validator.Params{"min": 1})
+	v.Check(len(subscription.Genres) <= 5, "genres", "must not contain more than 5 genres", validator.CodeTooMany, validator.Params{"max": 5})
+	v.Check(validator.Unique(subscription.Genres), "genres", "must not contain duplicate values", validator.CodeDuplicate)
+}
+
+// Define the DigestSubscriptionModel type
+type DigestSubscriptionModel struct {
+	DB Querier
+}
+
+// Subscribes a user to the digests, or changes their subscription. New subscriptions
+// only list the movies added after them, and get their first digest one period later.
+// Changing the frequency of a subscription can bring its next digest forward, but never
+// puts it back.
+func (m DigestSubscriptionModel) Upsert(ctx context.Context, subscription *DigestSubscription) error {
+	query := `
+		INSERT INTO digest_subscriptions (user_id, frequency, genres, last_movie_id, next_digest_at)
+		VALUES ($1, $2, $3, (SELECT COALESCE(MAX(id), 0) FROM movies), $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET frequency = EXCLUDED.frequency, genres = EXCLUDED.genres,
+			next_digest_at = LEAST(digest_subscriptions.next_digest_at, EXCLUDED.next_digest_at)
+		RETURNING created_at, last_movie_id, next_digest_at`
+
+	args := []interface{}{
+		subscription.UserID,
+		subscription.Frequency,
+		pq.Array(subscription.Genres),
+		time.Now().Add(DigestPeriod(subscription.Frequency)),
+	}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&subscription.CreatedAt,
+		&subscription.LastMovieID,
+		&subscription.NextDigestAt,
+	)
+}
+
+// Fetches a user's digest subscription
+func (m DigestSubscriptionModel) Get(ctx context.Context, userID int64) (*DigestSubscription, error) {
+	query := `
+		SELECT user_id, created_at, frequency, genres, last_movie_id, next_digest_at
+		FROM digest_subscriptions
+		WHERE user_id = $1`
+
+	var subscription DigestSubscription
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(
+		&subscription.UserID,
+		&subscription.CreatedAt,
+		&subscription.Frequency,
+		pq.Array(&subscription.Genres),
+		&subscription.LastMovieID,
+		&subscription.NextDigestAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &subscription, nil
+}
+
+// Fetches up to limit subscriptions which are due a digest, of users with IDs greater
+// than afterUserID, along with the users' names and email addresses. Subscriptions of
+// users who aren't activated are skipped. Call it repeatedly with the last user ID
+// returned to go through every due subscription.
+func (m DigestSubscriptionModel) GetBatchDue(ctx context.Context, afterUserID int64, limit int) ([]*DigestSubscription, error) {
+	query := `
+		SELECT digest_subscriptions.user_id, digest_subscriptions.created_at, digest_subscriptions.frequency,
+			digest_subscriptions.genres, digest_subscriptions.last_movie_id, digest_subscriptions.next_digest_at,
+			users.name, users.email
+		FROM digest_subscriptions
+		INNER JOIN users ON users.id = digest_subscriptions.user_id
+		WHERE digest_subscriptions.user_id > $1
+		AND digest_subscriptions.next_digest_at <= NOW()
+		AND users.activated
+		ORDER BY digest_subscriptions.user_id
+		LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, query, afterUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	subscriptions := []*DigestSubscription{}
+
+	for rows.Next() {
+		var subscription DigestSubscription
+
+		err := rows.Scan(
+			&subscription.UserID,
+			&subscription.CreatedAt,
+			&subscription.Frequency,
+			pq.Array(&subscription.Genres),
+			&subscription.LastMovieID,
+			&subscription.NextDigestAt,
+			&subscription.UserName,
+			&subscription.UserEmail,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, &subscription)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+// Records that a user's digest was sent, with the newest movie it told them about and
+// when their next digest is due
+func (m DigestSubscriptionModel) MarkSent(ctx context.Context, userID, lastMovieID int64, nextDigestAt time.Time) error {
+	query := `
+		UPDATE digest_subscriptions
+		SET last_movie_id = $1, next_digest_at = $2
+		WHERE user_id = $3`
+
+	_, err := m.DB.ExecContext(ctx, query, lastMovieID, nextDigestAt, userID)
+
+	return err
+}
+
+// Unsubscribes a user from the digests
+func (m DigestSubscriptionModel) Delete(ctx context.Context, userID int64) error {
+	query := `
+		DELETE FROM digest_subscriptions
+		WHERE user_id = $1`
+
+	result, err := m.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
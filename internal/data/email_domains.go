@@ -0,0 +1,123 @@
+package data
+
+import (
+	_ "embed"
+	"strings"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// A list of well-known disposable email domains, one per line, which new users can be
+// kept from registering with
+//
+//go:embed "disposable_domains.txt"
+var disposableDomainsFile string
+
+// The disposable email domains, parsed from disposableDomainsFile
+var DisposableDomains = parseDomains(disposableDomainsFile)
+
+// Define an EmailDomainPolicy type which decides which email domains new users can
+// register with. A domain matches a list when it, or any domain it is a subdomain of, is
+// in the list. The deny list and disposable domains take precedence over the allow
+// list, and an empty allow list allows every other domain.
+type EmailDomainPolicy struct {
+	allow           map[string]bool
+	deny            map[string]bool
+	blockDisposable bool
+}
+
+// Return a new EmailDomainPolicy with the given allow and deny lists, which also
+// rejects the disposable domains if blockDisposable is true
+func NewEmailDomainPolicy(allow, deny []string, blockDisposable bool) *EmailDomainPolicy {
+	policy := &EmailDomainPolicy{
+		allow:           make(map[string]bool, len(allow)),
+		deny:            make(map[string]bool, len(deny)),
+		blockDisposable: blockDisposable,
+	}
+
+	for _, domain := range allow {
+		policy.allow[normalizeDomain(domain)] = true
+	}
+
+	for _, domain := range deny {
+		policy.deny[normalizeDomain(domain)] = true
+	}
+
+	return policy
+}
+
+// Check the domain of the email address against the policy, returning the validation
+// message to report if it isn't allowed, or an empty string if it is. A nil policy
+// allows every domain.
+func (p *EmailDomainPolicy) Check(email string) string {
+	if p == nil {
+		return ""
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at == -1 {
+		return ""
+	}
+
+	domain := normalizeDomain(email[at+1:])
+
+	switch {
+	case matchesDomain(p.deny, domain):
+		return "must not use an email domain which is blocked from registering"
+	case p.blockDisposable && matchesDomain(DisposableDomains, domain):
+		return "must not use a disposable email address"
+	case len(p.allow) > 0 && !matchesDomain(p.allow, domain):
+		return "must use an email domain which is allowed to register"
+	default:
+		return ""
+	}
+}
+
+// Check that new users register with an email address whose domain is allowed by the
+// policy
+func ValidateEmailDomain(v *validator.Validator, email string, policy *EmailDomainPolicy) {
+	if message := policy.Check(email); message != "" {
+		domain := normalizeDomain(email[strings.LastIndex(email, "@")+1:])
+		v.AddError("email", message, validator.CodeNotAllowed, validator.Params{"value": domain})
+	}
+}
+
+// Report whether the domain, or any domain it is a subdomain of, is in the set
+func matchesDomain(domains map[string]bool, domain string) bool {
+	for domain != "" {
+		if domains[domain] {
+			return true
+		}
+
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			return false
+		}
+
+		domain = parent
+	}
+
+	return false
+}
+
+// Return the domain in lower case without a trailing dot
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// Parse a list of domains, one per line. Blank lines and lines starting with "#" are
+// ignored.
+func parseDomains(list string) map[string]bool {
+	domains := make(map[string]bool)
+
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		domains[normalizeDomain(line)] = true
+	}
+
+	return domains
+}
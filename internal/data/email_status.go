@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Define the delivery statuses of a user's email address, as reported by the email
+// provider. Soft bounces are temporary, so emails are still sent to those addresses.
+const (
+	EmailStatusOK         = "ok"
+	EmailStatusSoftBounce = "soft_bounce"
+	EmailStatusBounced    = "bounced"
+	EmailStatusComplained = "complained"
+)
+
+// Define an EmailStatus struct holding the delivery status of a user's email address
+// and when it was last changed
+type EmailStatus struct {
+	Status    string     `json:"status"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// Report whether emails mustn't be sent to an address with the status, because it has
+// bounced for good or its owner marked our emails as spam
+func (s EmailStatus) Suppressed() bool {
+	return s.Status == EmailStatusBounced || s.Status == EmailStatusComplained
+}
+
+// Set the delivery status of the user with the given email address, returning
+// ErrRecordNotFound if no user has it. A soft bounce doesn't replace a hard bounce or a
+// complaint, as the provider reports soft bounces for suppressed addresses too.
+func (m UserModel) SetEmailStatus(ctx context.Context, email, status string) error {
+	query := `
+        UPDATE users
+        SET email_status = $2, email_status_updated_at = NOW()
+        WHERE email = $1 AND ($2 <> 'soft_bounce' OR email_status IN ('ok', 'soft_bounce'))
+        RETURNING id`
+
+	var id int64
+
+	err := m.DB.QueryRowContext(ctx, query, email, status).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The user exists if the update was skipped for a soft bounce
+		err = m.DB.QueryRowContext(ctx, `SELECT id FROM users WHERE email = $1`, email).Scan(&id)
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecordNotFound
+	}
+
+	return err
+}
+
+// Return the delivery status of a user's email address
+func (m UserModel) GetEmailStatus(ctx context.Context, userID int64) (*EmailStatus, error) {
+	query := `
+        SELECT email_status, email_status_updated_at
+        FROM users
+        WHERE id = $1`
+
+	var status EmailStatus
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&status.Status, &status.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &status, nil
+}
+
+// Report whether emails to an address mustn't be sent, because it belongs to a user
+// whose address has bounced or complained. Addresses which don't belong to a user, such
+// as those of invited users, aren't suppressed.
+func (m UserModel) EmailSuppressed(ctx context.Context, email string) (bool, error) {
+	query := `
+        SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND email_status IN ('bounced', 'complained'))`
+
+	var suppressed bool
+
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(&suppressed)
+
+	return suppressed, err
+}
@@ -0,0 +1,54 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// ExplainGetAllMovies returns the query plan PostgreSQL uses for the query behind
+// MovieModel.GetAll(). Sequential scans are disabled for the transaction, so that on
+// small development databases (where a sequential scan is always cheapest) the plan
+// shows whether the indexes *can* be used at all. The transaction is rolled back, so
+// nothing is changed.
+func ExplainGetAllMovies(ctx context.Context, db *sql.DB, search MovieSearch, filters Filters) (string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "SET LOCAL enable_seqscan = off")
+	if err != nil {
+		return "", err
+	}
+
+	query, args := getAllMoviesQuery(search, filters)
+
+	rows, err := tx.QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return "", err
+	}
+
+	defer rows.Close()
+
+	var plan []string
+
+	for rows.Next() {
+		var line string
+
+		err := rows.Scan(&line)
+		if err != nil {
+			return "", err
+		}
+
+		plan = append(plan, line)
+	}
+
+	if err = rows.Err(); err != nil {
+		return "", err
+	}
+
+	return strings.Join(plan, "\n"), nil
+}
@@ -1,26 +1,81 @@
 package data
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"math"
+	"strconv"
 	"strings"
 
 	"github.com/LuisBarroso37/Greenlight/internal/validator"
 )
 
+// Define the limits on the pagination of lists
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+	MaxPage         = 10_000_000
+)
+
+// Define a Filters struct holding the pagination and sort of a list. Lists are paginated
+// either by page number, or (for the lists which support it) with a cursor holding the
+// ID of the last record of the previous page. Cursors only work on lists sorted by ID,
+// and don't skip or repeat records when records are added while paginating.
 type Filters struct {
 	Page         int
 	PageSize     int
 	Sort         string
 	SortSafelist []string // Holds the supported sort values
+	After        int64    // The ID from the cursor, or 0 when paginating by page number
 }
 
+var errInvalidCursor = errors.New("invalid cursor")
+
 // Validate filters received as query parameters
 func ValidateFilters(v *validator.Validator, filters Filters) {
-	v.Check(filters.Page > 0, "page", "must be greater than zero")
-	v.Check(filters.Page <= 10_000_000, "page", "must be a maximum of 10 million")
-	v.Check(filters.PageSize > 0, "page_size", "must be greater than zero")
-	v.Check(filters.PageSize <= 100, "page_size", " must be a maximum of 100")
-	v.Check(validator.In(filters.Sort, filters.SortSafelist...), "sort", "invalid sort value")
+	v.Check(filters.Page > 0, "page", "must be greater than zero", validator.CodeTooSmall, validator.Params{"min": 1})
+	v.Check(filters.Page <= MaxPage, "page", "must be a maximum of 10 million", validator.CodeTooLarge, validator.Params{"max": MaxPage})
+	v.Check(filters.PageSize > 0, "page_size", "must be greater than zero", validator.CodeTooSmall, validator.Params{"min": 1})
+	v.Check(filters.PageSize <= MaxPageSize, "page_size", " must be a maximum of 100", validator.CodeTooLarge, validator.Params{"max": MaxPageSize})
+	v.Check(validator.In(filters.Sort, filters.SortSafelist...), "sort", "invalid sort value", validator.CodeNotOneOf, validator.Params{"allowed": filters.SortSafelist})
+
+	if filters.After != 0 {
+		v.Check(filters.Page == 1, "page", "can't be used with a cursor", validator.CodeNotAllowed)
+		v.Check(strings.TrimPrefix(filters.Sort, "-") == "id", "cursor", "can only be used when sorting by id", validator.CodeNotAllowed)
+	}
+}
+
+// Return the cursor pointing after the record with the given ID. Cursors are opaque to
+// clients, so that they can later hold more than an ID.
+func EncodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// Return the ID held by a cursor
+func DecodeCursor(cursor string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(string(decoded), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errInvalidCursor
+	}
+
+	return id, nil
+}
+
+// Return the filters for the first page of the newest movies. Movie IDs are assigned
+// in insertion order, so sorting on them avoids the need for an index on created_at.
+func NewestMoviesFilters(limit int) Filters {
+	return Filters{
+		Page:         1,
+		PageSize:     limit,
+		Sort:         "-id",
+		SortSafelist: []string{"-id"},
+	}
 }
 
 // Check that the client-provided `Sort` field matches one of the entries in our safelist
@@ -55,6 +110,52 @@ func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 
+// Return the condition restricting a query to the records after the cursor, given the
+// ID column and the number of the query argument holding f.After. The condition holds
+// for every record when there is no cursor.
+func (f Filters) keysetCondition(column string, arg int) string {
+	operator := ">"
+	if f.sortDirection() == "DESC" {
+		operator = "<"
+	}
+
+	return fmt.Sprintf("($%d::bigint = 0 OR %s %s $%d)", arg, column, operator, arg)
+}
+
+// Report whether the record with the given ID is after the cursor, for the mocks
+func (f Filters) afterCursor(id int64) bool {
+	if f.After == 0 {
+		return true
+	}
+
+	if f.sortDirection() == "DESC" {
+		return id < f.After
+	}
+
+	return id > f.After
+}
+
+// Return the pagination metadata of a page of records, given the number of records
+// matching the query (which, with a cursor, only counts those after it) and the ID of
+// the last record of the page. Lists sorted by ID are given the cursor of the next page
+// whenever there is one, so that clients can switch to cursors from the first page.
+func (f Filters) metadata(totalRecords, records int, lastID int64) Metadata {
+	var metadata Metadata
+
+	switch {
+	case f.After == 0:
+		metadata = calculateMetadata(totalRecords, f.Page, f.PageSize)
+	case totalRecords > 0:
+		metadata = Metadata{PageSize: f.PageSize}
+	}
+
+	if strings.TrimPrefix(f.Sort, "-") == "id" && records > 0 && f.offset()+records < totalRecords {
+		metadata.NextCursor = EncodeCursor(lastID)
+	}
+
+	return metadata
+}
+
 // Struct used for holding the pagination metadata
 type Metadata struct {
 	CurrentPage  int `json:"current_page,omitempty"`
@@ -62,6 +163,13 @@ type Metadata struct {
 	FirstPage    int `json:"first_page,omitempty"`
 	LastPage     int `json:"last_page,omitempty"`
 	TotalRecords int `json:"total_records,omitempty"`
+
+	// The cursor of the next page, for lists sorted by ID. Pages fetched with a cursor
+	// only have a page size and the next cursor.
+	NextCursor string `json:"next_cursor,omitempty"`
+
+	// Only set for movie lists when requested with ?include=facets
+	Facets *MovieFacets `json:"facets,omitempty"`
 }
 
 // Calculates the appropriate pagination metadata values
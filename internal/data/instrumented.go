@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/metrics"
+	"github.com/LuisBarroso37/Greenlight/internal/reqctx"
+)
+
+// Define a SlowQuery struct holding the details of a query which took longer than the
+// configured threshold. The values of the query arguments are never included, as they
+// can contain personal data or secrets (like password hashes), only their types.
+// Request holds the values of the request which ran the query, and is nil for queries
+// run outside of a request.
+type SlowQuery struct {
+	Name     string
+	Query    string
+	ArgTypes []string
+	Duration time.Duration
+	Request  *reqctx.Values
+}
+
+// Define an InstrumentedQuerier type which wraps another Querier, recording the
+// duration of every query in a histogram and reporting queries slower than the
+// threshold. Queries are named after the model method that ran them, such as
+// "MovieModel.Get".
+type InstrumentedQuerier struct {
+	Querier
+	threshold   time.Duration
+	durations   *metrics.HistogramVec
+	onSlowQuery func(SlowQuery)
+}
+
+// Return a new InstrumentedQuerier. A threshold of zero disables slow query reporting.
+func NewInstrumentedQuerier(querier Querier, durations *metrics.HistogramVec, threshold time.Duration, onSlowQuery func(SlowQuery)) InstrumentedQuerier {
+	return InstrumentedQuerier{
+		Querier:     querier,
+		threshold:   threshold,
+		durations:   durations,
+		onSlowQuery: onSlowQuery,
+	}
+}
+
+func (q InstrumentedQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer q.observe(ctx, callerName(), query, args, time.Now())
+
+	return q.Querier.QueryRowContext(ctx, query, args...)
+}
+
+func (q InstrumentedQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer q.observe(ctx, callerName(), query, args, time.Now())
+
+	return q.Querier.QueryContext(ctx, query, args...)
+}
+
+func (q InstrumentedQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer q.observe(ctx, callerName(), query, args, time.Now())
+
+	return q.Querier.ExecContext(ctx, query, args...)
+}
+
+// Record the duration of a query, reporting it if it was slower than the threshold
+func (q InstrumentedQuerier) observe(ctx context.Context, name, query string, args []interface{}, start time.Time) {
+	duration := time.Since(start)
+
+	q.durations.Observe(duration.Seconds(), name)
+
+	if q.threshold == 0 || duration < q.threshold {
+		return
+	}
+
+	argTypes := make([]string, len(args))
+	for i, arg := range args {
+		argTypes[i] = fmt.Sprintf("%T", arg)
+	}
+
+	q.onSlowQuery(SlowQuery{
+		Name:     name,
+		Query:    strings.Join(strings.Fields(query), " "),
+		ArgTypes: argTypes,
+		Duration: duration,
+		Request:  reqctx.FromContext(ctx),
+	})
+}
+
+// Return the name of the model method which called the querier, without its package
+// path (e.g. "MovieModel.Get")
+func callerName() string {
+	pc, _, _, ok := runtime.Caller(2)
+	if !ok {
+		return "unknown"
+	}
+
+	name := runtime.FuncForPC(pc).Name()
+
+	// Strip the package path, which ends at the last slash and the following dot
+	if slash := strings.LastIndex(name, "/"); slash >= 0 {
+		name = name[slash+1:]
+	}
+
+	if dot := strings.Index(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+
+	return name
+}
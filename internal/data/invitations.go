@@ -0,0 +1,216 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// The statuses of an invitation, which are derived from its expiry and whether it has
+// been accepted
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationExpired  = "expired"
+)
+
+// The SQL expression giving the status of an invitation
+const invitationStatus = `
+	CASE
+		WHEN accepted_at IS NOT NULL THEN 'accepted'
+		WHEN expiry <= NOW() THEN 'expired'
+		ELSE 'pending'
+	END`
+
+// Define an Invitation struct to represent an invitation for someone to register with
+// the given email address. The code sent to them is stored hashed, like tokens are.
+type Invitation struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Email      string     `json:"email"`
+	InvitedBy  int64      `json:"invited_by"` // The ID of the user who sent the invitation
+	Expiry     time.Time  `json:"expiry"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	Status     string     `json:"status"`
+	PlainText  string     `json:"-"`
+	Hash       []byte     `json:"-"`
+}
+
+// Return a new invitation for the email address, with a random code which expires
+// after the ttl
+func NewInvitation(email string, invitedBy int64, ttl time.Duration) (*Invitation, error) {
+	token, err := generateToken(invitedBy, ttl, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Invitation{
+		Email:     email,
+		InvitedBy: invitedBy,
+		Expiry:    token.Expiry,
+		Status:    InvitationPending,
+		PlainText: token.PlainText,
+		Hash:      token.Hash,
+	}, nil
+}
+
+// Run validation checks on `Invitation` struct
+func ValidateInvitation(v *validator.Validator, invitation *Invitation) {
+	ValidateEmail(v, invitation.Email)
+}
+
+// Define the InvitationModel type
+type InvitationModel struct {
+	DB Querier
+}
+
+// Inserts a new invitation
+func (m InvitationModel) Insert(ctx context.Context, invitation *Invitation) error {
+	query := `
+		INSERT INTO invitations (email, invited_by, hash, expiry)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	return m.DB.QueryRowContext(
+		ctx,
+		query,
+		invitation.Email,
+		invitation.InvitedBy,
+		invitation.Hash,
+		invitation.Expiry,
+	).Scan(&invitation.ID, &invitation.CreatedAt)
+}
+
+// Fetches a page of the invitations with the given status, or with any status if it is
+// empty
+func (m InvitationModel) GetAll(ctx context.Context, status string, filters Filters) ([]*Invitation, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), id, created_at, email, invited_by, expiry, accepted_at, %s
+		FROM invitations
+		WHERE (%s = $1 OR $1 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, invitationStatus, invitationStatus, filters.sortColumn(), filters.sortDirection())
+
+	rows, err := m.DB.QueryContext(ctx, query, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	invitations := []*Invitation{}
+
+	for rows.Next() {
+		var invitation Invitation
+
+		err := rows.Scan(
+			&totalRecords,
+			&invitation.ID,
+			&invitation.CreatedAt,
+			&invitation.Email,
+			&invitation.InvitedBy,
+			&invitation.Expiry,
+			&invitation.AcceptedAt,
+			&invitation.Status,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		invitations = append(invitations, &invitation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return invitations, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Fetches the pending invitation with the given plaintext code
+func (m InvitationModel) GetPendingForCode(ctx context.Context, code string) (*Invitation, error) {
+	var invitation Invitation
+
+	hash := sha256.Sum256([]byte(code))
+
+	query := `
+		SELECT id, created_at, email, invited_by, expiry, accepted_at
+		FROM invitations
+		WHERE hash = $1
+		AND accepted_at IS NULL
+		AND expiry > NOW()`
+
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(
+		&invitation.ID,
+		&invitation.CreatedAt,
+		&invitation.Email,
+		&invitation.InvitedBy,
+		&invitation.Expiry,
+		&invitation.AcceptedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	invitation.Status = InvitationPending
+
+	return &invitation, nil
+}
+
+// Marks a pending invitation as accepted, so that its code can't be used again
+func (m InvitationModel) Accept(ctx context.Context, id int64) error {
+	query := `
+		UPDATE invitations
+		SET accepted_at = NOW()
+		WHERE id = $1 AND accepted_at IS NULL`
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Deletes an invitation which hasn't been accepted, revoking its code
+func (m InvitationModel) Delete(ctx context.Context, id int64) error {
+	query := `
+		DELETE FROM invitations
+		WHERE id = $1 AND accepted_at IS NULL`
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Define a MetricSnapshot struct holding the values of the application's metrics at a
+// point in time, such as the end of a deploy window. Each snapshot is taken from a
+// single instance.
+type MetricSnapshot struct {
+	ID        int64           `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Label     string          `json:"label,omitempty"`
+	Instance  string          `json:"instance"` // The hostname of the instance the metrics were taken from
+	Metrics   json.RawMessage `json:"metrics"`
+}
+
+// Run validation checks on `MetricSnapshot` struct
+func ValidateMetricSnapshot(v *validator.Validator, snapshot *MetricSnapshot) {
+	v.Check(len(snapshot.Label) <= 100, "label", "must not be more than 100 bytes long", validator.CodeTooLong, validator.Params{"max": 100})
+}
+
+// Define a MetricSnapshotModel struct type which wraps a sql.DB connection pool
+type MetricSnapshotModel struct {
+	DB Querier
+}
+
+// Inserts a new snapshot
+func (m MetricSnapshotModel) Insert(ctx context.Context, snapshot *MetricSnapshot) error {
+	query := `
+		INSERT INTO metric_snapshots (label, instance, metrics)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	return m.DB.QueryRowContext(
+		ctx,
+		query,
+		snapshot.Label,
+		snapshot.Instance,
+		[]byte(snapshot.Metrics),
+	).Scan(&snapshot.ID, &snapshot.CreatedAt)
+}
+
+// Fetches a page of the snapshots
+func (m MetricSnapshotModel) GetAll(ctx context.Context, filters Filters) ([]*MetricSnapshot, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), id, created_at, label, instance, metrics
+		FROM metric_snapshots
+		ORDER BY %s %s, id ASC
+		LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	snapshots := []*MetricSnapshot{}
+
+	for rows.Next() {
+		var snapshot MetricSnapshot
+
+		err := rows.Scan(
+			&totalRecords,
+			&snapshot.ID,
+			&snapshot.CreatedAt,
+			&snapshot.Label,
+			&snapshot.Instance,
+			&snapshot.Metrics,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		snapshots = append(snapshots, &snapshot)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return snapshots, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// Define a mock of the `AdminStatsModel` struct type. The counts are taken from the
+// movie, user and review mocks. Errors can be injected with SetError().
+type MockAdminStatsModel struct {
+	mockErrors
+	movies  *MockMovieModel
+	users   *MockUserModel
+	reviews *MockReviewModel
+}
+
+// Return a new MockAdminStatsModel which counts the records in the given mocks
+func NewMockAdminStatsModel(movies *MockMovieModel, users *MockUserModel, reviews *MockReviewModel) *MockAdminStatsModel {
+	return &MockAdminStatsModel{
+		movies:  movies,
+		users:   users,
+		reviews: reviews,
+	}
+}
+
+// Counts the users, movies and reviews, along with the users created on each of the
+// last given number of days
+func (m *MockAdminStatsModel) Get(ctx context.Context, days int) (*AdminStats, error) {
+	if err := m.err("Get"); err != nil {
+		return nil, err
+	}
+
+	stats := &AdminStats{}
+
+	start := statsStartDay(time.Now(), days)
+	counts := make(map[string]int)
+
+	m.users.mutex.Lock()
+	for _, user := range m.users.users {
+		stats.Users.Total++
+
+		if user.Activated {
+			stats.Users.Activated++
+		}
+
+		if !user.CreatedAt.Before(start) {
+			counts[user.CreatedAt.In(start.Location()).Format(dateLayout)]++
+		}
+	}
+	m.users.mutex.Unlock()
+
+	stats.Users.NewPerDay = dailyCounts(start, days, counts)
+
+	m.movies.mutex.Lock()
+	stats.Movies.Total = len(m.movies.movies)
+	m.movies.mutex.Unlock()
+
+	stats.Reviews.ByStatus = map[string]int{ReviewPending: 0, ReviewApproved: 0, ReviewRejected: 0}
+
+	m.reviews.mutex.Lock()
+	for _, review := range m.reviews.reviews {
+		stats.Reviews.ByStatus[review.Status]++
+		stats.Reviews.Total++
+	}
+	m.reviews.mutex.Unlock()
+
+	return stats, nil
+}
@@ -0,0 +1,131 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Define a mockAvailability struct holding a movie's stored availability
+type mockAvailability struct {
+	providerMovieID int64
+	countries       map[string]CountryAvailability
+	refreshedAt     time.Time
+}
+
+// Define a mock of the `AvailabilityModel` struct type. Availability is kept in memory
+// and movies are looked up in the movie mock. Errors can be injected with SetError().
+type MockAvailabilityModel struct {
+	mockErrors
+	mutex        sync.Mutex
+	availability map[int64]mockAvailability
+	movies       *MockMovieModel
+}
+
+// Return a new, empty MockAvailabilityModel for the movies in the movie mock
+func NewMockAvailabilityModel(movies *MockMovieModel) *MockAvailabilityModel {
+	return &MockAvailabilityModel{
+		availability: make(map[int64]mockAvailability),
+		movies:       movies,
+	}
+}
+
+// Fetches the availability of a movie in a country
+func (m *MockAvailabilityModel) GetForCountry(ctx context.Context, movieID int64, country string) (*Availability, error) {
+	if err := m.err("GetForCountry"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	availability := &Availability{
+		MovieID:             movieID,
+		Country:             country,
+		CountryAvailability: CountryAvailability{Offers: []StreamingOffer{}},
+	}
+
+	stored, found := m.availability[movieID]
+	if !found {
+		return availability, nil
+	}
+
+	refreshedAt := stored.refreshedAt
+	availability.RefreshedAt = &refreshedAt
+
+	if countryAvailability, found := stored.countries[country]; found {
+		availability.Link = countryAvailability.Link
+		availability.Offers = append(availability.Offers, countryAvailability.Offers...)
+	}
+
+	return availability, nil
+}
+
+// Fetches up to limit movies with IDs greater than afterID whose availability hasn't
+// been fetched since refreshedBefore
+func (m *MockAvailabilityModel) GetBatchForRefresh(ctx context.Context, refreshedBefore time.Time, afterID int64, limit int) ([]*AvailabilityRefresh, error) {
+	if err := m.err("GetBatchForRefresh"); err != nil {
+		return nil, err
+	}
+
+	m.movies.mutex.Lock()
+	var refreshes []*AvailabilityRefresh
+	for _, movie := range m.movies.movies {
+		if movie.ID > afterID {
+			refreshes = append(refreshes, &AvailabilityRefresh{MovieID: movie.ID, Title: movie.Title, Year: movie.Year})
+		}
+	}
+	m.movies.mutex.Unlock()
+
+	sort.Slice(refreshes, func(i, j int) bool {
+		return refreshes[i].MovieID < refreshes[j].MovieID
+	})
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	batch := []*AvailabilityRefresh{}
+
+	for _, refresh := range refreshes {
+		if len(batch) == limit {
+			break
+		}
+
+		stored, found := m.availability[refresh.MovieID]
+		if found && !stored.refreshedAt.Before(refreshedBefore) {
+			continue
+		}
+
+		refresh.ProviderMovieID = stored.providerMovieID
+		batch = append(batch, refresh)
+	}
+
+	return batch, nil
+}
+
+// Replaces the availability of a movie in every country
+func (m *MockAvailabilityModel) Set(ctx context.Context, movieID, providerMovieID int64, countries map[string]CountryAvailability) error {
+	if err := m.err("Set"); err != nil {
+		return err
+	}
+
+	m.movies.mutex.Lock()
+	_, found := m.movies.movies[movieID]
+	m.movies.mutex.Unlock()
+
+	if !found {
+		return ErrRecordNotFound
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.availability[movieID] = mockAvailability{
+		providerMovieID: providerMovieID,
+		countries:       countries,
+		refreshedAt:     time.Now(),
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `BackupModel` struct type. Backup records are kept in memory.
+// Errors can be injected with SetError().
+type MockBackupModel struct {
+	mockErrors
+	mutex   sync.Mutex
+	nextID  int64
+	backups map[int64]*Backup
+}
+
+// Return a new, empty MockBackupModel
+func NewMockBackupModel() *MockBackupModel {
+	return &MockBackupModel{
+		nextID:  1,
+		backups: make(map[int64]*Backup),
+	}
+}
+
+// Return a copy of a backup
+func copyBackup(backup *Backup) *Backup {
+	duplicate := *backup
+
+	if backup.FinishedAt != nil {
+		finishedAt := *backup.FinishedAt
+		duplicate.FinishedAt = &finishedAt
+	}
+
+	return &duplicate
+}
+
+// Records a new backup which has started running
+func (m *MockBackupModel) Insert(ctx context.Context, backup *Backup) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	backup.ID = m.nextID
+	backup.CreatedAt = time.Now()
+	backup.Status = BackupRunning
+	m.nextID++
+
+	m.backups[backup.ID] = copyBackup(backup)
+
+	return nil
+}
+
+// Records the outcome of a backup
+func (m *MockBackupModel) Finish(ctx context.Context, backup *Backup) error {
+	if err := m.err("Finish"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	backup.Status = BackupCompleted
+	if backup.Error != "" {
+		backup.Status = BackupFailed
+	}
+
+	now := time.Now()
+	backup.FinishedAt = &now
+
+	m.backups[backup.ID] = copyBackup(backup)
+
+	return nil
+}
+
+// Return copies of all the backups, oldest first
+func (m *MockBackupModel) sorted() []*Backup {
+	backups := []*Backup{}
+
+	for _, backup := range m.backups {
+		backups = append(backups, copyBackup(backup))
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ID < backups[j].ID
+	})
+
+	return backups
+}
+
+// Fetches a page of the backups
+func (m *MockBackupModel) GetAll(ctx context.Context, filters Filters) ([]*Backup, Metadata, error) {
+	if err := m.err("GetAll"); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	backups := m.sorted()
+
+	if filters.sortDirection() == "DESC" {
+		for i, j := 0, len(backups)-1; i < j; i, j = i+1, j-1 {
+			backups[i], backups[j] = backups[j], backups[i]
+		}
+	}
+
+	totalRecords := len(backups)
+
+	start := filters.offset()
+	if start > totalRecords {
+		start = totalRecords
+	}
+
+	end := start + filters.limit()
+	if end > totalRecords {
+		end = totalRecords
+	}
+
+	return backups[start:end], calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Fetches the backups which are no longer needed once the newest keep completed backups
+// are kept
+func (m *MockBackupModel) GetExpired(ctx context.Context, keep int) ([]*Backup, error) {
+	if err := m.err("GetExpired"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	backups := m.sorted()
+
+	// Walk the backups newest first, so that the newest completed ones are kept
+	var newestKept int64
+
+	kept := make(map[int64]bool)
+
+	for i := len(backups) - 1; i >= 0 && len(kept) < keep; i-- {
+		if backups[i].Status == BackupCompleted {
+			kept[backups[i].ID] = true
+
+			if newestKept == 0 {
+				newestKept = backups[i].ID
+			}
+		}
+	}
+
+	expired := []*Backup{}
+
+	for _, backup := range backups {
+		if backup.Status != BackupRunning && !kept[backup.ID] && backup.ID < newestKept {
+			expired = append(expired, backup)
+		}
+	}
+
+	return expired, nil
+}
+
+// Deletes the record of a backup
+func (m *MockBackupModel) Delete(ctx context.Context, id int64) error {
+	if err := m.err("Delete"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, found := m.backups[id]; !found {
+		return ErrRecordNotFound
+	}
+
+	delete(m.backups, id)
+
+	return nil
+}
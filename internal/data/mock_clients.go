@@ -0,0 +1,55 @@
+package data
+
+import (
+	"context"
+	"sync"
+)
+
+// Define a mock of the `ClientModel` struct type. Clients and the users they act for
+// are kept in memory. Errors can be injected with SetError().
+type MockClientModel struct {
+	mockErrors
+	mutex   sync.Mutex
+	clients map[string]mockClient
+}
+
+// Define a mockClient struct pairing a client with its user
+type mockClient struct {
+	client APIClient
+	user   User
+}
+
+// Return a new, empty MockClientModel
+func NewMockClientModel() *MockClientModel {
+	return &MockClientModel{
+		clients: make(map[string]mockClient),
+	}
+}
+
+// Seed adds a client acting on behalf of the given user to the mock
+func (m *MockClientModel) Seed(client *APIClient, user *User) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	client.UserID = user.ID
+	m.clients[client.Key] = mockClient{client: *client, user: *user}
+}
+
+// Fetch an active client by its key, along with the user it acts on behalf of
+func (m *MockClientModel) GetForKey(ctx context.Context, key string) (*APIClient, *User, error) {
+	if err := m.err("GetForKey"); err != nil {
+		return nil, nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	found, ok := m.clients[key]
+	if !ok || !found.client.Active {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	client, user := found.client, found.user
+
+	return &client, &user, nil
+}
@@ -0,0 +1,251 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `CollectionModel` struct type. Collections are kept in memory
+// and their members are checked against the movie mock, which stands in for the
+// foreign key. Errors can be injected with SetError().
+type MockCollectionModel struct {
+	mockErrors
+	mutex       sync.Mutex
+	nextID      int64
+	collections map[int64]*Collection
+	movies      *MockMovieModel
+}
+
+// Return a new, empty MockCollectionModel whose members must exist in the movie mock
+func NewMockCollectionModel(movies *MockMovieModel) *MockCollectionModel {
+	return &MockCollectionModel{
+		nextID:      1,
+		collections: make(map[int64]*Collection),
+		movies:      movies,
+	}
+}
+
+// Return a copy of a collection
+func copyCollection(collection *Collection) *Collection {
+	duplicate := *collection
+	duplicate.MovieIDs = append([]int64{}, collection.MovieIDs...)
+
+	return &duplicate
+}
+
+// Check that the members of a collection exist and don't belong to another collection
+func (m *MockCollectionModel) checkMembers(collection *Collection) error {
+	m.movies.mutex.Lock()
+	for _, id := range collection.MovieIDs {
+		if _, found := m.movies.movies[id]; !found {
+			m.movies.mutex.Unlock()
+			return ErrUnknownMovie
+		}
+	}
+	m.movies.mutex.Unlock()
+
+	for _, other := range m.collections {
+		if other.ID == collection.ID {
+			continue
+		}
+
+		for _, id := range other.MovieIDs {
+			for _, member := range collection.MovieIDs {
+				if id == member {
+					return ErrMovieInCollection
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Inserts a new collection along with its members
+func (m *MockCollectionModel) Insert(ctx context.Context, collection *Collection) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	collection.ID = 0
+
+	if err := m.checkMembers(collection); err != nil {
+		return err
+	}
+
+	collection.ID = m.nextID
+	collection.CreatedAt = time.Now()
+	collection.Version = 1
+	m.nextID++
+
+	m.collections[collection.ID] = copyCollection(collection)
+
+	return nil
+}
+
+// Fetches a specific collection
+func (m *MockCollectionModel) Get(ctx context.Context, id int64) (*Collection, error) {
+	if err := m.err("Get"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	collection, found := m.collections[id]
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	return copyCollection(collection), nil
+}
+
+// Fetches the collections which the given movies belong to, keyed by movie ID
+func (m *MockCollectionModel) GetForMovies(ctx context.Context, movieIDs []int64) (map[int64]*Collection, error) {
+	if err := m.err("GetForMovies"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	collections := make(map[int64]*Collection)
+
+	for _, collection := range m.collections {
+		for _, member := range collection.MovieIDs {
+			for _, id := range movieIDs {
+				if id == member {
+					collections[id] = copyCollection(collection)
+				}
+			}
+		}
+	}
+
+	return collections, nil
+}
+
+// Fetches the movies of a collection, in order
+func (m *MockCollectionModel) GetMovies(ctx context.Context, id int64) ([]*Movie, error) {
+	if err := m.err("GetMovies"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	collection, found := m.collections[id]
+	var movieIDs []int64
+	if found {
+		movieIDs = append(movieIDs, collection.MovieIDs...)
+	}
+	m.mutex.Unlock()
+
+	m.movies.mutex.Lock()
+	defer m.movies.mutex.Unlock()
+
+	movies := []*Movie{}
+
+	for _, movieID := range movieIDs {
+		if movie, found := m.movies.movies[movieID]; found {
+			movies = append(movies, copyMovie(movie))
+		}
+	}
+
+	return movies, nil
+}
+
+// Fetches a page of collections whose name contains the given text (ignoring case),
+// sorted by ID or name
+func (m *MockCollectionModel) GetAll(ctx context.Context, name string, filters Filters) ([]*Collection, Metadata, error) {
+	if err := m.err("GetAll"); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var collections []*Collection
+
+	for _, collection := range m.collections {
+		if strings.Contains(strings.ToLower(collection.Name), strings.ToLower(name)) {
+			collections = append(collections, copyCollection(collection))
+		}
+	}
+
+	column, descending := filters.sortColumn(), filters.sortDirection() == "DESC"
+
+	sort.Slice(collections, func(i, j int) bool {
+		a, b := collections[i], collections[j]
+
+		if column == "name" && a.Name != b.Name {
+			return (a.Name < b.Name) != descending
+		}
+
+		if column == "id" && descending {
+			return a.ID > b.ID
+		}
+
+		return a.ID < b.ID
+	})
+
+	totalRecords := len(collections)
+
+	start := filters.offset()
+	if start > totalRecords {
+		start = totalRecords
+	}
+
+	end := start + filters.limit()
+	if end > totalRecords {
+		end = totalRecords
+	}
+
+	return collections[start:end], calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Updates a collection and replaces its members
+func (m *MockCollectionModel) Update(ctx context.Context, collection *Collection) error {
+	if err := m.err("Update"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	existing, found := m.collections[collection.ID]
+	if !found || existing.Version != collection.Version {
+		return ErrEditConflict
+	}
+
+	if err := m.checkMembers(collection); err != nil {
+		return err
+	}
+
+	collection.Version++
+	collection.CreatedAt = existing.CreatedAt
+	m.collections[collection.ID] = copyCollection(collection)
+
+	return nil
+}
+
+// Deletes a specific collection
+func (m *MockCollectionModel) Delete(ctx context.Context, id int64) error {
+	if err := m.err("Delete"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, found := m.collections[id]; !found {
+		return ErrRecordNotFound
+	}
+
+	delete(m.collections, id)
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `DeviceModel` struct type. Devices are kept in memory for each
+// user, keyed by their fingerprint. Errors can be injected with SetError().
+type MockDeviceModel struct {
+	mockErrors
+	mutex   sync.Mutex
+	devices map[int64]map[string]*Device
+}
+
+// Return a new, empty MockDeviceModel
+func NewMockDeviceModel() *MockDeviceModel {
+	return &MockDeviceModel{
+		devices: make(map[int64]map[string]*Device),
+	}
+}
+
+// Record that a user signed in from a device, reporting whether the device is new to a
+// user who has signed in from other devices before
+func (m *MockDeviceModel) Record(ctx context.Context, device *Device) (bool, error) {
+	if err := m.err("Record"); err != nil {
+		return false, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	devices, found := m.devices[device.UserID]
+	if !found {
+		devices = make(map[string]*Device)
+		m.devices[device.UserID] = devices
+	}
+
+	now := time.Now()
+
+	if known, found := devices[string(device.Fingerprint)]; found {
+		known.LastSeen = now
+		device.FirstSeen, device.LastSeen = known.FirstSeen, now
+
+		return false, nil
+	}
+
+	device.FirstSeen, device.LastSeen = now, now
+
+	stored := *device
+	devices[string(device.Fingerprint)] = &stored
+
+	return len(devices) > 1, nil
+}
@@ -0,0 +1,171 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `DigestSubscriptionModel` struct type. Subscriptions are kept in
+// memory, keyed by user. New subscriptions start from the newest movie in the movie
+// mock, if one is given. Errors can be injected with SetError().
+type MockDigestSubscriptionModel struct {
+	mockErrors
+	mutex         sync.Mutex
+	subscriptions map[int64]*DigestSubscription
+	users         map[int64][2]string
+	movies        *MockMovieModel
+}
+
+// Return a new, empty MockDigestSubscriptionModel. The movie mock may be nil.
+func NewMockDigestSubscriptionModel(movies *MockMovieModel) *MockDigestSubscriptionModel {
+	return &MockDigestSubscriptionModel{
+		subscriptions: make(map[int64]*DigestSubscription),
+		users:         make(map[int64][2]string),
+		movies:        movies,
+	}
+}
+
+// Set the name and email address returned by GetBatchDue() for a user's subscription.
+// Subscriptions of users without an email address are treated like those of users who
+// aren't activated.
+func (m *MockDigestSubscriptionModel) SetUser(userID int64, name, email string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.users[userID] = [2]string{name, email}
+}
+
+// Subscribes a user to the digests, or changes their subscription
+func (m *MockDigestSubscriptionModel) Upsert(ctx context.Context, subscription *DigestSubscription) error {
+	if err := m.err("Upsert"); err != nil {
+		return err
+	}
+
+	var lastMovieID int64
+
+	if m.movies != nil {
+		m.movies.mutex.Lock()
+		lastMovieID = m.movies.nextID - 1
+		m.movies.mutex.Unlock()
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	nextDigestAt := time.Now().Add(DigestPeriod(subscription.Frequency)).Truncate(time.Second)
+
+	stored, found := m.subscriptions[subscription.UserID]
+	if !found {
+		stored = &DigestSubscription{
+			UserID:       subscription.UserID,
+			CreatedAt:    time.Now(),
+			LastMovieID:  lastMovieID,
+			NextDigestAt: nextDigestAt,
+		}
+		m.subscriptions[subscription.UserID] = stored
+	}
+
+	if nextDigestAt.Before(stored.NextDigestAt) {
+		stored.NextDigestAt = nextDigestAt
+	}
+
+	stored.Frequency = subscription.Frequency
+	stored.Genres = append([]string{}, subscription.Genres...)
+
+	subscription.CreatedAt = stored.CreatedAt
+	subscription.LastMovieID = stored.LastMovieID
+	subscription.NextDigestAt = stored.NextDigestAt
+
+	return nil
+}
+
+// Fetches a user's digest subscription
+func (m *MockDigestSubscriptionModel) Get(ctx context.Context, userID int64) (*DigestSubscription, error) {
+	if err := m.err("Get"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	subscription, found := m.subscriptions[userID]
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	duplicate := *subscription
+
+	return &duplicate, nil
+}
+
+// Fetches up to limit subscriptions which are due a digest, of users with IDs greater
+// than afterUserID
+func (m *MockDigestSubscriptionModel) GetBatchDue(ctx context.Context, afterUserID int64, limit int) ([]*DigestSubscription, error) {
+	if err := m.err("GetBatchDue"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	subscriptions := []*DigestSubscription{}
+
+	for userID, subscription := range m.subscriptions {
+		user := m.users[userID]
+
+		if userID > afterUserID && !subscription.NextDigestAt.After(now) && user[1] != "" {
+			duplicate := *subscription
+			duplicate.UserName = user[0]
+			duplicate.UserEmail = user[1]
+			subscriptions = append(subscriptions, &duplicate)
+		}
+	}
+
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].UserID < subscriptions[j].UserID
+	})
+
+	if len(subscriptions) > limit {
+		subscriptions = subscriptions[:limit]
+	}
+
+	return subscriptions, nil
+}
+
+// Records that a user's digest was sent
+func (m *MockDigestSubscriptionModel) MarkSent(ctx context.Context, userID, lastMovieID int64, nextDigestAt time.Time) error {
+	if err := m.err("MarkSent"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if subscription, found := m.subscriptions[userID]; found {
+		subscription.LastMovieID = lastMovieID
+		subscription.NextDigestAt = nextDigestAt
+	}
+
+	return nil
+}
+
+// Unsubscribes a user from the digests
+func (m *MockDigestSubscriptionModel) Delete(ctx context.Context, userID int64) error {
+	if err := m.err("Delete"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, found := m.subscriptions[userID]; !found {
+		return ErrRecordNotFound
+	}
+
+	delete(m.subscriptions, userID)
+
+	return nil
+}
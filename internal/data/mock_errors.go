@@ -0,0 +1,37 @@
+package data
+
+import "sync"
+
+// Define a mockErrors type which holds errors injected into a mock model, keyed by the
+// name of the method which should return them (e.g. "Get"). It is embedded in each of
+// the mock models so that tests can exercise the error paths of the handlers.
+type mockErrors struct {
+	mutex sync.Mutex
+	errs  map[string]error
+}
+
+// SetError makes every following call to the named method return err. Passing a nil
+// error clears a previously injected one.
+func (m *mockErrors) SetError(method string, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.errs == nil {
+		m.errs = make(map[string]error)
+	}
+
+	if err == nil {
+		delete(m.errs, method)
+		return
+	}
+
+	m.errs[method] = err
+}
+
+// Return the error injected for the named method, if any
+func (m *mockErrors) err(method string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.errs[method]
+}
@@ -0,0 +1,164 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `InvitationModel` struct type. Invitations are kept in memory.
+// Errors can be injected with SetError().
+type MockInvitationModel struct {
+	mockErrors
+	mutex       sync.Mutex
+	nextID      int64
+	invitations map[int64]*Invitation
+}
+
+// Return a new, empty MockInvitationModel
+func NewMockInvitationModel() *MockInvitationModel {
+	return &MockInvitationModel{
+		nextID:      1,
+		invitations: make(map[int64]*Invitation),
+	}
+}
+
+// Return the status of an invitation at the given time
+func (invitation *Invitation) statusAt(now time.Time) string {
+	switch {
+	case invitation.AcceptedAt != nil:
+		return InvitationAccepted
+	case !invitation.Expiry.After(now):
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
+
+// Inserts a new invitation
+func (m *MockInvitationModel) Insert(ctx context.Context, invitation *Invitation) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	invitation.ID = m.nextID
+	invitation.CreatedAt = time.Now()
+	m.nextID++
+
+	stored := *invitation
+	m.invitations[invitation.ID] = &stored
+
+	return nil
+}
+
+// Fetches a page of the invitations with the given status, or with any status if it is
+// empty
+func (m *MockInvitationModel) GetAll(ctx context.Context, status string, filters Filters) ([]*Invitation, Metadata, error) {
+	if err := m.err("GetAll"); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	invitations := []*Invitation{}
+
+	for _, invitation := range m.invitations {
+		result := *invitation
+		result.Status = invitation.statusAt(now)
+
+		if status == "" || result.Status == status {
+			invitations = append(invitations, &result)
+		}
+	}
+
+	descending := filters.sortDirection() == "DESC"
+
+	sort.Slice(invitations, func(i, j int) bool {
+		return (invitations[i].ID < invitations[j].ID) != descending
+	})
+
+	totalRecords := len(invitations)
+
+	start := filters.offset()
+	if start > totalRecords {
+		start = totalRecords
+	}
+
+	end := start + filters.limit()
+	if end > totalRecords {
+		end = totalRecords
+	}
+
+	return invitations[start:end], calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Fetches the pending invitation with the given plaintext code
+func (m *MockInvitationModel) GetPendingForCode(ctx context.Context, code string) (*Invitation, error) {
+	if err := m.err("GetPendingForCode"); err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256([]byte(code))
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+
+	for _, invitation := range m.invitations {
+		if string(invitation.Hash) == string(hash[:]) && invitation.statusAt(now) == InvitationPending {
+			result := *invitation
+			result.Status = InvitationPending
+
+			return &result, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+// Marks a pending invitation as accepted
+func (m *MockInvitationModel) Accept(ctx context.Context, id int64) error {
+	if err := m.err("Accept"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	invitation, found := m.invitations[id]
+	if !found || invitation.AcceptedAt != nil {
+		return ErrRecordNotFound
+	}
+
+	now := time.Now()
+	invitation.AcceptedAt = &now
+
+	return nil
+}
+
+// Deletes an invitation which hasn't been accepted
+func (m *MockInvitationModel) Delete(ctx context.Context, id int64) error {
+	if err := m.err("Delete"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	invitation, found := m.invitations[id]
+	if !found || invitation.AcceptedAt != nil {
+		return ErrRecordNotFound
+	}
+
+	delete(m.invitations, id)
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `MetricSnapshotModel` struct type. Snapshots are kept in memory.
+// Errors can be injected with SetError().
+type MockMetricSnapshotModel struct {
+	mockErrors
+	mutex     sync.Mutex
+	nextID    int64
+	snapshots map[int64]*MetricSnapshot
+}
+
+// Return a new, empty MockMetricSnapshotModel
+func NewMockMetricSnapshotModel() *MockMetricSnapshotModel {
+	return &MockMetricSnapshotModel{
+		nextID:    1,
+		snapshots: make(map[int64]*MetricSnapshot),
+	}
+}
+
+// Inserts a new snapshot
+func (m *MockMetricSnapshotModel) Insert(ctx context.Context, snapshot *MetricSnapshot) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	snapshot.ID = m.nextID
+	snapshot.CreatedAt = time.Now()
+	m.nextID++
+
+	duplicate := *snapshot
+	m.snapshots[snapshot.ID] = &duplicate
+
+	return nil
+}
+
+// Fetches a page of the snapshots
+func (m *MockMetricSnapshotModel) GetAll(ctx context.Context, filters Filters) ([]*MetricSnapshot, Metadata, error) {
+	if err := m.err("GetAll"); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	snapshots := []*MetricSnapshot{}
+
+	for _, snapshot := range m.snapshots {
+		duplicate := *snapshot
+		snapshots = append(snapshots, &duplicate)
+	}
+
+	descending := filters.sortDirection() == "DESC"
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return (snapshots[i].ID < snapshots[j].ID) != descending
+	})
+
+	totalRecords := len(snapshots)
+
+	start := filters.offset()
+	if start > totalRecords {
+		start = totalRecords
+	}
+
+	end := start + filters.limit()
+	if end > totalRecords {
+		end = totalRecords
+	}
+
+	return snapshots[start:end], calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
@@ -0,0 +1,171 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `MovieChangeModel` struct type. Changes are kept in memory and
+// their movies are checked against the movie mock, which stands in for the foreign key.
+// Errors can be injected with SetError().
+type MockMovieChangeModel struct {
+	mockErrors
+	mutex   sync.Mutex
+	nextID  int64
+	changes map[int64]*MovieChange
+	movies  *MockMovieModel
+}
+
+// Return a new, empty MockMovieChangeModel whose changes must be for movies in the movie
+// mock
+func NewMockMovieChangeModel(movies *MockMovieModel) *MockMovieChangeModel {
+	return &MockMovieChangeModel{
+		nextID:  1,
+		changes: make(map[int64]*MovieChange),
+		movies:  movies,
+	}
+}
+
+// Return a copy of a change. The patch isn't copied, as it is never modified in place.
+func copyMovieChange(change *MovieChange) *MovieChange {
+	duplicate := *change
+
+	if change.ReviewedAt != nil {
+		reviewedAt := *change.ReviewedAt
+		duplicate.ReviewedAt = &reviewedAt
+	}
+
+	return &duplicate
+}
+
+// Inserts a new proposed change
+func (m *MockMovieChangeModel) Insert(ctx context.Context, change *MovieChange) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.movies.mutex.Lock()
+	_, found := m.movies.movies[change.MovieID]
+	m.movies.mutex.Unlock()
+
+	if !found {
+		return ErrRecordNotFound
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	change.ID = m.nextID
+	change.CreatedAt = time.Now()
+	change.Status = MovieChangePending
+	change.Version = 1
+	m.nextID++
+
+	m.changes[change.ID] = copyMovieChange(change)
+
+	return nil
+}
+
+// Fetches a specific change proposed for a movie
+func (m *MockMovieChangeModel) Get(ctx context.Context, movieID, id int64) (*MovieChange, error) {
+	if err := m.err("Get"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	change, found := m.changes[id]
+	if !found || change.MovieID != movieID {
+		return nil, ErrRecordNotFound
+	}
+
+	return copyMovieChange(change), nil
+}
+
+// Fetches a page of the changes with the given status proposed for a movie
+func (m *MockMovieChangeModel) GetAllForMovie(ctx context.Context, movieID int64, status string, filters Filters) ([]*MovieChange, Metadata, error) {
+	if err := m.err("GetAllForMovie"); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	changes := []*MovieChange{}
+
+	for _, change := range m.changes {
+		if change.MovieID == movieID && change.Status == status {
+			changes = append(changes, copyMovieChange(change))
+		}
+	}
+
+	descending := filters.sortDirection() == "DESC"
+
+	sort.Slice(changes, func(i, j int) bool {
+		return (changes[i].ID < changes[j].ID) != descending
+	})
+
+	totalRecords := len(changes)
+
+	start := filters.offset()
+	if start > totalRecords {
+		start = totalRecords
+	}
+
+	end := start + filters.limit()
+	if end > totalRecords {
+		end = totalRecords
+	}
+
+	return changes[start:end], calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Records an editor's decision on a pending change
+func (m *MockMovieChangeModel) Review(ctx context.Context, change *MovieChange) error {
+	if err := m.err("Review"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	existing, found := m.changes[change.ID]
+	if !found || existing.Version != change.Version || existing.Status != MovieChangePending {
+		return ErrEditConflict
+	}
+
+	now := time.Now()
+	change.ReviewedAt = &now
+	change.Version++
+	m.changes[change.ID] = copyMovieChange(change)
+
+	return nil
+}
+
+// Deletes up to limit changes which were reviewed before the given time
+func (m *MockMovieChangeModel) DeleteReviewed(ctx context.Context, before time.Time, limit int) (int64, error) {
+	if err := m.err("DeleteReviewed"); err != nil {
+		return 0, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var deleted int64
+
+	for id, change := range m.changes {
+		if deleted >= int64(limit) {
+			break
+		}
+
+		if change.ReviewedAt != nil && change.ReviewedAt.Before(before) {
+			delete(m.changes, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
@@ -1,29 +1,506 @@
 package data
 
-// Define a mock of the `MovieModel` struct type
-type MockMovieModel struct{}
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `MovieModel` struct type. Movies are kept in memory and behave
+// like the real model: IDs and versions are assigned on insert, missing movies return
+// ErrRecordNotFound and stale versions return ErrEditConflict. Errors can be injected
+// with SetError(). The movies' releases are kept here too, for the release mock, so
+// that GetAll() can filter on them.
+type MockMovieModel struct {
+	mockErrors
+	mutex         sync.Mutex
+	nextID        int64
+	movies        map[int64]*Movie
+	nextReleaseID int64
+	releases      map[int64]*MovieRelease
+}
+
+// Return a new MockMovieModel containing the given movies
+func NewMockMovieModel(movies ...*Movie) *MockMovieModel {
+	m := &MockMovieModel{
+		nextID:        1,
+		movies:        make(map[int64]*Movie),
+		nextReleaseID: 1,
+		releases:      make(map[int64]*MovieRelease),
+	}
+
+	m.Seed(movies...)
+
+	return m
+}
+
+// Seed adds movies to the mock without going through Insert(), so injected errors don't
+// apply. Movies without an ID or version are given one.
+func (m *MockMovieModel) Seed(movies ...*Movie) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, movie := range movies {
+		m.store(movie)
+	}
+}
+
+// Store a copy of a movie, assigning the fields which the database would fill in
+func (m *MockMovieModel) store(movie *Movie) {
+	if movie.ID == 0 {
+		movie.ID = m.nextID
+	}
+
+	if movie.ID >= m.nextID {
+		m.nextID = movie.ID + 1
+	}
+
+	if movie.Version == 0 {
+		movie.Version = 1
+	}
+
+	if movie.Status == "" {
+		movie.Status = MoviePublished
+	}
+
+	if movie.CreatedAt.IsZero() {
+		movie.CreatedAt = time.Now()
+	}
+
+	if movie.UpdatedAt.IsZero() {
+		movie.UpdatedAt = movie.CreatedAt
+	}
+
+	m.movies[movie.ID] = copyMovie(movie)
+}
 
 // Inserts a new record in the `movies` table
-func (m MockMovieModel) Insert(movie *Movie) error {
+func (m *MockMovieModel) Insert(ctx context.Context, movie *Movie) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	movie.ID = 0
+	movie.Version = 0
+	movie.CreatedAt = time.Time{}
+	movie.UpdatedAt = time.Time{}
+
+	m.store(movie)
+
 	return nil
 }
 
 // Fetches a specific record from the `movies` table
-func (m MockMovieModel) Get(id int64) (*Movie, error) {
-	return nil, nil
+func (m *MockMovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
+	if err := m.err("Get"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	movie, found := m.movies[id]
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	return copyMovie(movie), nil
+}
+
+// Fetches the movies with the given IDs, in the same order
+func (m *MockMovieModel) GetMany(ctx context.Context, ids []int64) ([]*Movie, error) {
+	if err := m.err("GetMany"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	movies := []*Movie{}
+
+	for _, id := range ids {
+		if movie, found := m.movies[id]; found {
+			movies = append(movies, copyMovie(movie))
+		}
+	}
+
+	return movies, nil
 }
 
 // Updates a specific record from the `movies` table
-func (m MockMovieModel) Update(movie *Movie) error {
+func (m *MockMovieModel) Update(ctx context.Context, movie *Movie) error {
+	if err := m.err("Update"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	existing, found := m.movies[movie.ID]
+	if !found || existing.Version != movie.Version {
+		return ErrEditConflict
+	}
+
+	movie.Version++
+	movie.CreatedAt = existing.CreatedAt
+	movie.UpdatedAt = time.Now()
+	m.movies[movie.ID] = copyMovie(movie)
+
 	return nil
 }
 
 // Deletes a specific record from the `movies` table
-func (m MockMovieModel) Delete(id int64) error {
+func (m *MockMovieModel) Delete(ctx context.Context, id int64) error {
+	if err := m.err("Delete"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, found := m.movies[id]; !found {
+		return ErrRecordNotFound
+	}
+
+	delete(m.movies, id)
+
+	for releaseID, release := range m.releases {
+		if release.MovieID == id {
+			delete(m.releases, releaseID)
+		}
+	}
+
 	return nil
 }
 
-// Fetches all movie records from the `movies` table
-func (m MockMovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
-	return nil, Metadata{}, nil
+// Publishes the scheduled movies whose publish_at time is at or before the given time
+func (m *MockMovieModel) PublishScheduled(ctx context.Context, now time.Time) ([]*Movie, error) {
+	if err := m.err("PublishScheduled"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	movies := []*Movie{}
+
+	for _, movie := range m.movies {
+		if movie.Status != MovieScheduled || movie.PublishAt == nil || movie.PublishAt.After(now) {
+			continue
+		}
+
+		movie.Status = MoviePublished
+		movie.Version++
+		movie.UpdatedAt = time.Now()
+
+		movies = append(movies, copyMovie(movie))
+	}
+
+	sort.Slice(movies, func(i, j int) bool {
+		return movies[i].ID < movies[j].ID
+	})
+
+	return movies, nil
+}
+
+// Fetches all movie records from the `movies` table. The title and synopsis match
+// movies whose title or synopsis contains every word of them (ignoring case), which
+// approximates the full-text search used by the real model.
+func (m *MockMovieModel) GetAll(ctx context.Context, search MovieSearch, filters Filters) ([]*Movie, Metadata, error) {
+	if err := m.err("GetAll"); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var movies []*Movie
+
+	for _, movie := range m.movies {
+		if matchesSearch(movie, search) && m.matchesReleases(movie.ID, search) {
+			movies = append(movies, copyMovie(movie))
+		}
+	}
+
+	column, descending := filters.sortColumn(), filters.sortDirection() == "DESC"
+
+	// Sort on the requested column, falling back to the ID like the real query does
+	sort.Slice(movies, func(i, j int) bool {
+		a, b := movies[i], movies[j]
+
+		var less, equal bool
+
+		switch column {
+		case "title":
+			less, equal = a.Title < b.Title, a.Title == b.Title
+		case "year":
+			less, equal = a.Year < b.Year, a.Year == b.Year
+		case "runtime":
+			less, equal = a.Runtime < b.Runtime, a.Runtime == b.Runtime
+		default:
+			less, equal = a.ID < b.ID, a.ID == b.ID
+		}
+
+		if equal {
+			return a.ID < b.ID
+		}
+
+		if descending {
+			return !less
+		}
+
+		return less
+	})
+
+	totalRecords := len(movies)
+
+	start := filters.offset()
+	if start > totalRecords {
+		start = totalRecords
+	}
+
+	end := start + filters.limit()
+	if end > totalRecords {
+		end = totalRecords
+	}
+
+	return movies[start:end], calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Fetches a page of the IDs and update times of all published movies, ordered by ID,
+// along with the total number of published movies
+func (m *MockMovieModel) GetTimestamps(ctx context.Context, offset, limit int) ([]*MovieTimestamp, int, error) {
+	if err := m.err("GetTimestamps"); err != nil {
+		return nil, 0, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	timestamps := []*MovieTimestamp{}
+
+	for _, movie := range m.movies {
+		if movie.Status != MoviePublished {
+			continue
+		}
+
+		timestamps = append(timestamps, &MovieTimestamp{ID: movie.ID, UpdatedAt: movie.UpdatedAt})
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].ID < timestamps[j].ID
+	})
+
+	totalRecords := len(timestamps)
+
+	if offset > totalRecords {
+		offset = totalRecords
+	}
+
+	if offset+limit < totalRecords {
+		timestamps = timestamps[offset : offset+limit]
+	} else {
+		timestamps = timestamps[offset:]
+	}
+
+	return timestamps, totalRecords, nil
+}
+
+// Fetches up to limit published movies whose title contains the query, ignoring case. Titles
+// starting with the query come first. Unlike the real query, similar titles aren't
+// matched.
+func (m *MockMovieModel) Autocomplete(ctx context.Context, query string, limit int) ([]*MovieSuggestion, error) {
+	if err := m.err("Autocomplete"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	query = strings.ToLower(query)
+
+	var movies []*Movie
+
+	for _, movie := range m.movies {
+		if movie.Status == MoviePublished && strings.Contains(strings.ToLower(movie.Title), query) {
+			movies = append(movies, movie)
+		}
+	}
+
+	sort.Slice(movies, func(i, j int) bool {
+		a, b := movies[i], movies[j]
+
+		aPrefix := strings.HasPrefix(strings.ToLower(a.Title), query)
+		bPrefix := strings.HasPrefix(strings.ToLower(b.Title), query)
+
+		if aPrefix != bPrefix {
+			return aPrefix
+		}
+
+		if a.Title != b.Title {
+			return a.Title < b.Title
+		}
+
+		return a.ID < b.ID
+	})
+
+	if len(movies) > limit {
+		movies = movies[:limit]
+	}
+
+	suggestions := []*MovieSuggestion{}
+
+	for _, movie := range movies {
+		suggestions = append(suggestions, &MovieSuggestion{ID: movie.ID, Title: movie.Title, Year: movie.Year})
+	}
+
+	return suggestions, nil
+}
+
+// Counts the movies matching a search by genre, year, runtime range and certification
+func (m *MockMovieModel) Facets(ctx context.Context, search MovieSearch) (*MovieFacets, error) {
+	if err := m.err("Facets"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	facets := NewMovieFacets()
+
+	for _, movie := range m.movies {
+		if !matchesSearch(movie, search) || !m.matchesReleases(movie.ID, search) {
+			continue
+		}
+
+		for _, genre := range movie.Genres {
+			facets.Genres[genre]++
+		}
+
+		facets.AddYear(int(movie.Year), 1)
+		facets.AddRuntime(int(movie.Runtime), 1)
+
+		for country, certification := range movie.Certifications {
+			facets.Certifications[country+":"+certification]++
+		}
+	}
+
+	return facets, nil
+}
+
+// Report whether a movie matches every condition of a search
+func matchesSearch(movie *Movie, search MovieSearch) bool {
+	if search.Language != "" && movie.OriginalLanguage != search.Language {
+		return false
+	}
+
+	if !search.CreatedBefore.IsZero() && !movie.CreatedAt.Before(search.CreatedBefore) {
+		return false
+	}
+
+	if !search.CreatedAfter.IsZero() && !movie.CreatedAt.After(search.CreatedAfter) {
+		return false
+	}
+
+	for country, rating := range search.Certifications {
+		if movie.Certifications[country] != rating {
+			return false
+		}
+	}
+
+	if search.CreatedBy != 0 && movie.CreatedBy != search.CreatedBy {
+		return false
+	}
+
+	if search.PublishedOnly && movie.Status != MoviePublished {
+		return false
+	}
+
+	for key, value := range search.Metadata {
+		if stored, found := movie.Metadata[key]; !found || stored != value {
+			return false
+		}
+	}
+
+	return matchesTitle(movie.Title, search.Title) &&
+		matchesTitle(movie.Synopsis, search.Synopsis) &&
+		containsAll(movie.Genres, search.Genres) &&
+		(len(search.AnyGenres) == 0 || containsAny(movie.Genres, search.AnyGenres)) &&
+		containsAll(movie.ProductionCountries, search.Countries)
+}
+
+// Report whether a movie has a release within the dates of a search, if it has any
+func (m *MockMovieModel) matchesReleases(movieID int64, search MovieSearch) bool {
+	if search.ReleasedBefore.IsZero() && search.ReleasedAfter.IsZero() {
+		return true
+	}
+
+	for _, release := range m.releases {
+		if release.MovieID != movieID {
+			continue
+		}
+
+		if !search.ReleasedBefore.IsZero() && !release.ReleaseDate.Before(search.ReleasedBefore.Time) {
+			continue
+		}
+
+		if !search.ReleasedAfter.IsZero() && !release.ReleaseDate.After(search.ReleasedAfter.Time) {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// Report whether a title contains every word of the search term, ignoring case
+func matchesTitle(title, search string) bool {
+	title = strings.ToLower(title)
+
+	for _, word := range strings.Fields(strings.ToLower(search)) {
+		if !strings.Contains(title, word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Report whether values contains every one of the wanted values
+func containsAll(values, wanted []string) bool {
+	for _, w := range wanted {
+		found := false
+
+		for _, v := range values {
+			if v == w {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Report whether values contains at least one of the wanted values
+func containsAny(values, wanted []string) bool {
+	for _, w := range wanted {
+		for _, v := range values {
+			if v == w {
+				return true
+			}
+		}
+	}
+
+	return false
 }
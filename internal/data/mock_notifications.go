@@ -0,0 +1,175 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `NotificationModel` struct type. Notifications and preferences
+// are kept in memory. Errors can be injected with SetError().
+type MockNotificationModel struct {
+	mockErrors
+	mutex         sync.Mutex
+	nextID        int64
+	notifications []*Notification
+	preferences   map[int64]NotificationPreferences
+}
+
+// Return a new, empty MockNotificationModel
+func NewMockNotificationModel() *MockNotificationModel {
+	return &MockNotificationModel{
+		nextID:      1,
+		preferences: make(map[int64]NotificationPreferences),
+	}
+}
+
+// Inserts a new notification
+func (m *MockNotificationModel) Insert(ctx context.Context, notification *Notification) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	notification.ID = m.nextID
+	notification.CreatedAt = time.Now()
+	m.nextID++
+
+	stored := *notification
+	m.notifications = append(m.notifications, &stored)
+
+	return nil
+}
+
+// Fetches a page of a user's notifications, newest first
+func (m *MockNotificationModel) GetAllForUser(ctx context.Context, userID int64, unreadOnly bool, filters Filters) ([]*Notification, Metadata, error) {
+	if err := m.err("GetAllForUser"); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var notifications []*Notification
+
+	for i := len(m.notifications) - 1; i >= 0; i-- {
+		n := m.notifications[i]
+
+		if n.UserID == userID && (n.ReadAt == nil || !unreadOnly) && filters.afterCursor(n.ID) {
+			duplicate := *n
+			notifications = append(notifications, &duplicate)
+		}
+	}
+
+	totalRecords := len(notifications)
+
+	start := filters.offset()
+	if start > totalRecords {
+		start = totalRecords
+	}
+
+	end := start + filters.limit()
+	if end > totalRecords {
+		end = totalRecords
+	}
+
+	var lastID int64
+	if end > start {
+		lastID = notifications[end-1].ID
+	}
+
+	return notifications[start:end], filters.metadata(totalRecords, end-start, lastID), nil
+}
+
+// Marks one of a user's notifications as read
+func (m *MockNotificationModel) MarkRead(ctx context.Context, userID, id int64) error {
+	if err := m.err("MarkRead"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, n := range m.notifications {
+		if n.ID == id && n.UserID == userID {
+			if n.ReadAt == nil {
+				now := time.Now()
+				n.ReadAt = &now
+			}
+
+			return nil
+		}
+	}
+
+	return ErrRecordNotFound
+}
+
+// Deletes up to limit notifications which were read before the given time
+func (m *MockNotificationModel) DeleteRead(ctx context.Context, before time.Time, limit int) (int64, error) {
+	if err := m.err("DeleteRead"); err != nil {
+		return 0, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var deleted int64
+
+	notifications := m.notifications[:0]
+
+	for _, n := range m.notifications {
+		if n.ReadAt != nil && n.ReadAt.Before(before) && deleted < int64(limit) {
+			deleted++
+			continue
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	m.notifications = notifications
+
+	return deleted, nil
+}
+
+// Returns a user's preferences for every kind of notification
+func (m *MockNotificationModel) GetPreferences(ctx context.Context, userID int64) (NotificationPreferences, error) {
+	if err := m.err("GetPreferences"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	preferences := make(NotificationPreferences, len(DefaultNotificationPreferences))
+	for kind, channels := range DefaultNotificationPreferences {
+		preferences[kind] = channels
+	}
+
+	for kind, channels := range m.preferences[userID] {
+		preferences[kind] = channels
+	}
+
+	return preferences, nil
+}
+
+// Saves a user's preferences for the given kinds of notification
+func (m *MockNotificationModel) SetPreferences(ctx context.Context, userID int64, preferences NotificationPreferences) error {
+	if err := m.err("SetPreferences"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.preferences[userID] == nil {
+		m.preferences[userID] = make(NotificationPreferences)
+	}
+
+	for kind, channels := range preferences {
+		m.preferences[userID][kind] = channels
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `OperationModel` struct type. Operations are kept in memory.
+// Errors can be injected with SetError().
+type MockOperationModel struct {
+	mockErrors
+	mutex      sync.Mutex
+	nextID     int64
+	operations map[int64]*Operation
+}
+
+// Return a new, empty MockOperationModel
+func NewMockOperationModel() *MockOperationModel {
+	return &MockOperationModel{
+		nextID:     1,
+		operations: make(map[int64]*Operation),
+	}
+}
+
+// Return a copy of an operation
+func copyOperation(operation *Operation) *Operation {
+	duplicate := *operation
+
+	if operation.FinishedAt != nil {
+		finishedAt := *operation.FinishedAt
+		duplicate.FinishedAt = &finishedAt
+	}
+
+	return &duplicate
+}
+
+// Records a new queued operation
+func (m *MockOperationModel) Insert(ctx context.Context, operation *Operation) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, existing := range m.operations {
+		if existing.Name == operation.Name && (existing.Status == OperationQueued || existing.Status == OperationRunning) {
+			return ErrDuplicateOperation
+		}
+	}
+
+	operation.ID = m.nextID
+	operation.CreatedAt = time.Now()
+	operation.UpdatedAt = operation.CreatedAt
+	operation.Status = OperationQueued
+	m.nextID++
+
+	m.operations[operation.ID] = copyOperation(operation)
+
+	return nil
+}
+
+// Fetches an operation
+func (m *MockOperationModel) Get(ctx context.Context, id int64) (*Operation, error) {
+	if err := m.err("Get"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	operation, found := m.operations[id]
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	return copyOperation(operation), nil
+}
+
+// Fetches a page of the operations
+func (m *MockOperationModel) GetAll(ctx context.Context, filters Filters) ([]*Operation, Metadata, error) {
+	if err := m.err("GetAll"); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	operations := []*Operation{}
+
+	for _, operation := range m.operations {
+		operations = append(operations, copyOperation(operation))
+	}
+
+	sort.Slice(operations, func(i, j int) bool {
+		if filters.sortDirection() == "DESC" {
+			return operations[i].ID > operations[j].ID
+		}
+
+		return operations[i].ID < operations[j].ID
+	})
+
+	totalRecords := len(operations)
+
+	start := filters.offset()
+	if start > totalRecords {
+		start = totalRecords
+	}
+
+	end := start + filters.limit()
+	if end > totalRecords {
+		end = totalRecords
+	}
+
+	return operations[start:end], calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Records the progress of an operation
+func (m *MockOperationModel) UpdateProgress(ctx context.Context, operation *Operation) error {
+	if err := m.err("UpdateProgress"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	operation.Status = OperationRunning
+	operation.UpdatedAt = time.Now()
+
+	m.operations[operation.ID] = copyOperation(operation)
+
+	return nil
+}
+
+// Records the outcome of an operation
+func (m *MockOperationModel) Finish(ctx context.Context, operation *Operation) error {
+	if err := m.err("Finish"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	operation.Status = OperationCompleted
+	if operation.Error != "" {
+		operation.Status = OperationFailed
+	}
+
+	now := time.Now()
+	operation.UpdatedAt = now
+	operation.FinishedAt = &now
+
+	m.operations[operation.ID] = copyOperation(operation)
+
+	return nil
+}
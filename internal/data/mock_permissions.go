@@ -1,15 +1,95 @@
 package data
 
-// Define a mock of the `TokenModel` struct type
-type MockPermissionsModel struct{}
+import (
+	"context"
+	"sync"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Define a mock of the `PermissionModel` struct type. Permission codes are kept in
+// memory for each user. Errors can be injected with SetError().
+type MockPermissionsModel struct {
+	mockErrors
+	mutex       sync.Mutex
+	permissions map[int64]Permissions
+}
+
+// Return a new, empty MockPermissionsModel
+func NewMockPermissionsModel() *MockPermissionsModel {
+	return &MockPermissionsModel{
+		permissions: make(map[int64]Permissions),
+	}
+}
 
 // This method returns all permission codes for a specific user in a
 // Permissions slice
-func (m MockPermissionsModel) GetAllForUser(userID int64) (Permissions, error) {
-	return nil, nil
+func (m *MockPermissionsModel) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
+	if err := m.err("GetAllForUser"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	permissions := make(Permissions, len(m.permissions[userID]))
+	copy(permissions, m.permissions[userID])
+
+	return permissions, nil
 }
 
 // Add the provided permission codes for a specific user
-func (m MockPermissionsModel) AddForUser(userID int64, codes ...string) error {
+func (m *MockPermissionsModel) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	if err := m.err("AddForUser"); err != nil {
+		return err
+	}
+
+	if err := validatePermissionCodes(codes); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, code := range codes {
+		if !validator.In(code, m.permissions[userID]...) {
+			m.permissions[userID] = append(m.permissions[userID], code)
+		}
+	}
+
+	return nil
+}
+
+// Report whether a specific user holds all of the provided permission codes
+func (m *MockPermissionsModel) HasPermission(ctx context.Context, userID int64, codes ...string) (bool, error) {
+	if err := m.err("HasPermission"); err != nil {
+		return false, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.permissions[userID].IncludeAll(codes...), nil
+}
+
+// Remove the provided permission codes from a specific user
+func (m *MockPermissionsModel) RemoveForUser(ctx context.Context, userID int64, codes ...string) error {
+	if err := m.err("RemoveForUser"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var kept Permissions
+
+	for _, code := range m.permissions[userID] {
+		if !validator.In(code, codes...) {
+			kept = append(kept, code)
+		}
+	}
+
+	m.permissions[userID] = kept
+
 	return nil
 }
@@ -0,0 +1,160 @@
+package data
+
+import (
+	"context"
+	"sort"
+)
+
+// Define a mock of the `MovieReleaseModel` struct type. Releases are kept in the movie
+// mock, which stands in for the foreign key and filters on them in GetAll(). Errors can
+// be injected with SetError().
+type MockMovieReleaseModel struct {
+	mockErrors
+	movies *MockMovieModel
+}
+
+// Return a new MockMovieReleaseModel keeping its releases in the movie mock
+func NewMockMovieReleaseModel(movies *MockMovieModel) *MockMovieReleaseModel {
+	return &MockMovieReleaseModel{movies: movies}
+}
+
+// Return a copy of a release
+func copyMovieRelease(release *MovieRelease) *MovieRelease {
+	duplicate := *release
+
+	return &duplicate
+}
+
+// Report whether another release of the same movie has the same country and type.
+// The movie mock's mutex must be held.
+func (m *MockMovieReleaseModel) duplicate(release *MovieRelease) bool {
+	for _, other := range m.movies.releases {
+		if other.ID != release.ID && other.MovieID == release.MovieID &&
+			other.Country == release.Country && other.Type == release.Type {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Inserts a new release for a movie
+func (m *MockMovieReleaseModel) Insert(ctx context.Context, release *MovieRelease) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.movies.mutex.Lock()
+	defer m.movies.mutex.Unlock()
+
+	if _, found := m.movies.movies[release.MovieID]; !found {
+		return ErrRecordNotFound
+	}
+
+	release.ID = 0
+
+	if m.duplicate(release) {
+		return ErrDuplicateRelease
+	}
+
+	release.ID = m.movies.nextReleaseID
+	release.Version = 1
+	m.movies.nextReleaseID++
+
+	m.movies.releases[release.ID] = copyMovieRelease(release)
+
+	return nil
+}
+
+// Fetches a specific release of a movie
+func (m *MockMovieReleaseModel) Get(ctx context.Context, movieID, id int64) (*MovieRelease, error) {
+	if err := m.err("Get"); err != nil {
+		return nil, err
+	}
+
+	m.movies.mutex.Lock()
+	defer m.movies.mutex.Unlock()
+
+	release, found := m.movies.releases[id]
+	if !found || release.MovieID != movieID {
+		return nil, ErrRecordNotFound
+	}
+
+	return copyMovieRelease(release), nil
+}
+
+// Fetches all of a movie's releases, earliest first
+func (m *MockMovieReleaseModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieRelease, error) {
+	if err := m.err("GetAllForMovie"); err != nil {
+		return nil, err
+	}
+
+	m.movies.mutex.Lock()
+	defer m.movies.mutex.Unlock()
+
+	releases := []*MovieRelease{}
+
+	for _, release := range m.movies.releases {
+		if release.MovieID == movieID {
+			releases = append(releases, copyMovieRelease(release))
+		}
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		a, b := releases[i], releases[j]
+
+		switch {
+		case !a.ReleaseDate.Equal(b.ReleaseDate.Time):
+			return a.ReleaseDate.Before(b.ReleaseDate.Time)
+		case a.Country != b.Country:
+			return a.Country < b.Country
+		default:
+			return a.Type < b.Type
+		}
+	})
+
+	return releases, nil
+}
+
+// Updates a specific release of a movie
+func (m *MockMovieReleaseModel) Update(ctx context.Context, release *MovieRelease) error {
+	if err := m.err("Update"); err != nil {
+		return err
+	}
+
+	m.movies.mutex.Lock()
+	defer m.movies.mutex.Unlock()
+
+	existing, found := m.movies.releases[release.ID]
+	if !found || existing.MovieID != release.MovieID || existing.Version != release.Version {
+		return ErrEditConflict
+	}
+
+	if m.duplicate(release) {
+		return ErrDuplicateRelease
+	}
+
+	release.Version++
+	m.movies.releases[release.ID] = copyMovieRelease(release)
+
+	return nil
+}
+
+// Deletes a specific release of a movie
+func (m *MockMovieReleaseModel) Delete(ctx context.Context, movieID, id int64) error {
+	if err := m.err("Delete"); err != nil {
+		return err
+	}
+
+	m.movies.mutex.Lock()
+	defer m.movies.mutex.Unlock()
+
+	release, found := m.movies.releases[id]
+	if !found || release.MovieID != movieID {
+		return ErrRecordNotFound
+	}
+
+	delete(m.movies.releases, id)
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `ReportModel` struct type. Reports are kept in memory and their
+// reviews are checked against the review mock, which stands in for the foreign key.
+// Errors can be injected with SetError().
+type MockReportModel struct {
+	mockErrors
+	mutex   sync.Mutex
+	nextID  int64
+	reports map[int64]*ReviewReport
+	reviews *MockReviewModel
+}
+
+// Return a new, empty MockReportModel whose reports must be for reviews in the review mock
+func NewMockReportModel(reviews *MockReviewModel) *MockReportModel {
+	return &MockReportModel{
+		nextID:  1,
+		reports: make(map[int64]*ReviewReport),
+		reviews: reviews,
+	}
+}
+
+// Return a copy of a report
+func copyReport(report *ReviewReport) *ReviewReport {
+	duplicate := *report
+
+	if report.ResolvedAt != nil {
+		resolvedAt := *report.ResolvedAt
+		duplicate.ResolvedAt = &resolvedAt
+	}
+
+	return &duplicate
+}
+
+// Inserts a new open report and returns the number of open reports on the review
+func (m *MockReportModel) Insert(ctx context.Context, report *ReviewReport) (int, error) {
+	if err := m.err("Insert"); err != nil {
+		return 0, err
+	}
+
+	m.reviews.mutex.Lock()
+	_, found := m.reviews.reviews[report.ReviewID]
+	m.reviews.mutex.Unlock()
+
+	if !found {
+		return 0, ErrRecordNotFound
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	open := 1
+
+	for _, other := range m.reports {
+		if other.ReviewID != report.ReviewID {
+			continue
+		}
+
+		if other.UserID == report.UserID {
+			return 0, ErrDuplicateReport
+		}
+
+		if other.Status == ReportOpen {
+			open++
+		}
+	}
+
+	report.ID = m.nextID
+	report.CreatedAt = time.Now()
+	report.Status = ReportOpen
+	report.Version = 1
+	m.nextID++
+
+	m.reports[report.ID] = copyReport(report)
+
+	return open, nil
+}
+
+// Fetches a specific report
+func (m *MockReportModel) Get(ctx context.Context, id int64) (*ReviewReport, error) {
+	if err := m.err("Get"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	report, found := m.reports[id]
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	return copyReport(report), nil
+}
+
+// Fetches a page of the reports with the given status and, if given, reason
+func (m *MockReportModel) GetAll(ctx context.Context, status, reason string, filters Filters) ([]*ReviewReport, Metadata, error) {
+	if err := m.err("GetAll"); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var reports []*ReviewReport
+
+	for _, report := range m.reports {
+		if report.Status == status && (reason == "" || report.Reason == reason) {
+			reports = append(reports, copyReport(report))
+		}
+	}
+
+	descending := filters.sortDirection() == "DESC"
+
+	sort.Slice(reports, func(i, j int) bool {
+		return (reports[i].ID < reports[j].ID) != descending
+	})
+
+	totalRecords := len(reports)
+
+	start := filters.offset()
+	if start > totalRecords {
+		start = totalRecords
+	}
+
+	end := start + filters.limit()
+	if end > totalRecords {
+		end = totalRecords
+	}
+
+	return reports[start:end], calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Closes a report with the report's status
+func (m *MockReportModel) Resolve(ctx context.Context, report *ReviewReport) error {
+	if err := m.err("Resolve"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	existing, found := m.reports[report.ID]
+	if !found || existing.Version != report.Version {
+		return ErrEditConflict
+	}
+
+	now := time.Now()
+	report.ResolvedAt = &now
+	report.Version++
+	m.reports[report.ID] = copyReport(report)
+
+	return nil
+}
+
+// Closes all of a review's open reports with the given status
+func (m *MockReportModel) ResolveForReview(ctx context.Context, reviewID int64, status string, resolvedBy int64) error {
+	if err := m.err("ResolveForReview"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+
+	for _, report := range m.reports {
+		if report.ReviewID == reviewID && report.Status == ReportOpen {
+			resolvedAt := now
+			report.Status = status
+			report.ResolvedBy = resolvedBy
+			report.ResolvedAt = &resolvedAt
+			report.Version++
+		}
+	}
+
+	return nil
+}
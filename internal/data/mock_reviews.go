@@ -0,0 +1,188 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `ReviewModel` struct type. Reviews are kept in memory and their
+// movies are checked against the movie mock, which stands in for the foreign key. The
+// user names are stored as given on insert. Errors can be injected with SetError().
+type MockReviewModel struct {
+	mockErrors
+	mutex   sync.Mutex
+	nextID  int64
+	reviews map[int64]*Review
+	movies  *MockMovieModel
+}
+
+// Return a new, empty MockReviewModel whose reviews must be for movies in the movie mock
+func NewMockReviewModel(movies *MockMovieModel) *MockReviewModel {
+	return &MockReviewModel{
+		nextID:  1,
+		reviews: make(map[int64]*Review),
+		movies:  movies,
+	}
+}
+
+// Return a copy of a review
+func copyReview(review *Review) *Review {
+	duplicate := *review
+
+	if review.ModeratedAt != nil {
+		moderatedAt := *review.ModeratedAt
+		duplicate.ModeratedAt = &moderatedAt
+	}
+
+	return &duplicate
+}
+
+// Inserts a new review
+func (m *MockReviewModel) Insert(ctx context.Context, review *Review) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.movies.mutex.Lock()
+	_, found := m.movies.movies[review.MovieID]
+	m.movies.mutex.Unlock()
+
+	if !found {
+		return ErrRecordNotFound
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, other := range m.reviews {
+		if other.MovieID == review.MovieID && other.UserID == review.UserID {
+			return ErrDuplicateReview
+		}
+	}
+
+	review.ID = m.nextID
+	review.CreatedAt = time.Now()
+	review.Version = 1
+	m.nextID++
+
+	m.reviews[review.ID] = copyReview(review)
+
+	return nil
+}
+
+// Fetches a specific review, whatever its status
+func (m *MockReviewModel) Get(ctx context.Context, id int64) (*Review, error) {
+	if err := m.err("Get"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	review, found := m.reviews[id]
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	return copyReview(review), nil
+}
+
+// Fetches a page of the reviews matching the given condition, sorted on the filters'
+// column
+func (m *MockReviewModel) getPage(filters Filters, match func(*Review) bool) ([]*Review, Metadata) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var reviews []*Review
+
+	for _, review := range m.reviews {
+		if match(review) && filters.afterCursor(review.ID) {
+			reviews = append(reviews, copyReview(review))
+		}
+	}
+
+	column, descending := filters.sortColumn(), filters.sortDirection() == "DESC"
+
+	sort.Slice(reviews, func(i, j int) bool {
+		a, b := reviews[i], reviews[j]
+
+		if column == "rating" && a.Rating != b.Rating {
+			return (a.Rating < b.Rating) != descending
+		}
+
+		if column == "id" && descending {
+			return a.ID > b.ID
+		}
+
+		return a.ID < b.ID
+	})
+
+	totalRecords := len(reviews)
+
+	start := filters.offset()
+	if start > totalRecords {
+		start = totalRecords
+	}
+
+	end := start + filters.limit()
+	if end > totalRecords {
+		end = totalRecords
+	}
+
+	var lastID int64
+	if end > start {
+		lastID = reviews[end-1].ID
+	}
+
+	return reviews[start:end], filters.metadata(totalRecords, end-start, lastID)
+}
+
+// Fetches a page of a movie's approved reviews
+func (m *MockReviewModel) GetAllForMovie(ctx context.Context, movieID int64, filters Filters) ([]*Review, Metadata, error) {
+	if err := m.err("GetAllForMovie"); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	reviews, metadata := m.getPage(filters, func(review *Review) bool {
+		return review.MovieID == movieID && review.Status == ReviewApproved
+	})
+
+	return reviews, metadata, nil
+}
+
+// Fetches a page of the reviews with the given status
+func (m *MockReviewModel) GetAllByStatus(ctx context.Context, status string, filters Filters) ([]*Review, Metadata, error) {
+	if err := m.err("GetAllByStatus"); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	reviews, metadata := m.getPage(filters, func(review *Review) bool {
+		return review.Status == status
+	})
+
+	return reviews, metadata, nil
+}
+
+// Records a moderation decision on a review
+func (m *MockReviewModel) Moderate(ctx context.Context, review *Review) error {
+	if err := m.err("Moderate"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	existing, found := m.reviews[review.ID]
+	if !found || existing.Version != review.Version {
+		return ErrEditConflict
+	}
+
+	now := time.Now()
+	review.ModeratedAt = &now
+	review.Version++
+	m.reviews[review.ID] = copyReview(review)
+
+	return nil
+}
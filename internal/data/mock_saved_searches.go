@@ -0,0 +1,159 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `SavedSearchModel` struct type. Saved searches are kept in
+// memory. New searches start from the newest movie in the movie mock, if one is given.
+// Errors can be injected with SetError().
+type MockSavedSearchModel struct {
+	mockErrors
+	mutex    sync.Mutex
+	nextID   int64
+	searches map[int64]*SavedSearch
+	emails   map[int64]string
+	movies   *MockMovieModel
+}
+
+// Return a new, empty MockSavedSearchModel. The movie mock may be nil.
+func NewMockSavedSearchModel(movies *MockMovieModel) *MockSavedSearchModel {
+	return &MockSavedSearchModel{
+		nextID:   1,
+		searches: make(map[int64]*SavedSearch),
+		emails:   make(map[int64]string),
+		movies:   movies,
+	}
+}
+
+// Set the email address returned by GetBatchForAlerts() for a user's searches. Searches
+// of users without an email address are treated like those of users who aren't
+// activated.
+func (m *MockSavedSearchModel) SetUserEmail(userID int64, email string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.emails[userID] = email
+}
+
+// Inserts a new saved search
+func (m *MockSavedSearchModel) Insert(ctx context.Context, search *SavedSearch) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	var lastMovieID int64
+
+	if m.movies != nil {
+		m.movies.mutex.Lock()
+		lastMovieID = m.movies.nextID - 1
+		m.movies.mutex.Unlock()
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	search.ID = m.nextID
+	search.CreatedAt = time.Now()
+	search.LastMovieID = lastMovieID
+	m.nextID++
+
+	stored := *search
+	m.searches[search.ID] = &stored
+
+	return nil
+}
+
+// Return copies of the searches matching fn, ordered by ID
+func (m *MockSavedSearchModel) filter(fn func(*SavedSearch) bool) []*SavedSearch {
+	searches := []*SavedSearch{}
+
+	for _, search := range m.searches {
+		if fn(search) {
+			duplicate := *search
+			searches = append(searches, &duplicate)
+		}
+	}
+
+	sort.Slice(searches, func(i, j int) bool {
+		return searches[i].ID < searches[j].ID
+	})
+
+	return searches
+}
+
+// Fetches all of a user's saved searches, oldest first
+func (m *MockSavedSearchModel) GetAllForUser(ctx context.Context, userID int64) ([]*SavedSearch, error) {
+	if err := m.err("GetAllForUser"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.filter(func(search *SavedSearch) bool {
+		return search.UserID == userID
+	}), nil
+}
+
+// Fetches up to limit saved searches with IDs greater than afterID
+func (m *MockSavedSearchModel) GetBatchForAlerts(ctx context.Context, afterID int64, limit int) ([]*SavedSearch, error) {
+	if err := m.err("GetBatchForAlerts"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	searches := m.filter(func(search *SavedSearch) bool {
+		return search.ID > afterID && m.emails[search.UserID] != ""
+	})
+
+	if len(searches) > limit {
+		searches = searches[:limit]
+	}
+
+	for _, search := range searches {
+		search.UserEmail = m.emails[search.UserID]
+	}
+
+	return searches, nil
+}
+
+// Records the newest movie the user has been told about for a saved search
+func (m *MockSavedSearchModel) SetLastMovieID(ctx context.Context, id, lastMovieID int64) error {
+	if err := m.err("SetLastMovieID"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if search, found := m.searches[id]; found {
+		search.LastMovieID = lastMovieID
+	}
+
+	return nil
+}
+
+// Deletes one of a user's saved searches
+func (m *MockSavedSearchModel) Delete(ctx context.Context, userID, id int64) error {
+	if err := m.err("Delete"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	search, found := m.searches[id]
+	if !found || search.UserID != userID {
+		return ErrRecordNotFound
+	}
+
+	delete(m.searches, id)
+
+	return nil
+}
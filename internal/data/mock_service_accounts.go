@@ -0,0 +1,175 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `ServiceAccountModel` struct type. Service accounts are kept in
+// memory, and their owners are looked up in the user mock. Errors can be injected with
+// SetError().
+type MockServiceAccountModel struct {
+	mockErrors
+	mutex    sync.Mutex
+	nextID   int64
+	accounts map[int64]*ServiceAccount
+	users    *MockUserModel
+}
+
+// Return a new, empty MockServiceAccountModel
+func NewMockServiceAccountModel(users *MockUserModel) *MockServiceAccountModel {
+	return &MockServiceAccountModel{
+		nextID:   1,
+		accounts: make(map[int64]*ServiceAccount),
+		users:    users,
+	}
+}
+
+// Inserts a new service account
+func (m *MockServiceAccountModel) Insert(ctx context.Context, account *ServiceAccount) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	account.ID = m.nextID
+	account.CreatedAt = time.Now()
+	account.Version = 1
+	m.nextID++
+
+	stored := *account
+	m.accounts[account.ID] = &stored
+
+	return nil
+}
+
+// Fetches one of a user's service accounts
+func (m *MockServiceAccountModel) Get(ctx context.Context, ownerID, id int64) (*ServiceAccount, error) {
+	if err := m.err("Get"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	account, found := m.accounts[id]
+	if !found || account.OwnerID != ownerID {
+		return nil, ErrRecordNotFound
+	}
+
+	result := *account
+
+	return &result, nil
+}
+
+// Fetches all of a user's service accounts, oldest first
+func (m *MockServiceAccountModel) GetAllForOwner(ctx context.Context, ownerID int64) ([]*ServiceAccount, error) {
+	if err := m.err("GetAllForOwner"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	accounts := []*ServiceAccount{}
+
+	for _, account := range m.accounts {
+		if account.OwnerID == ownerID {
+			result := *account
+			accounts = append(accounts, &result)
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].ID < accounts[j].ID
+	})
+
+	return accounts, nil
+}
+
+// Fetches an active service account by its plaintext key, along with its owner
+// restricted to the account's permissions
+func (m *MockServiceAccountModel) GetForKey(ctx context.Context, key string) (*ServiceAccount, *User, error) {
+	if err := m.err("GetForKey"); err != nil {
+		return nil, nil, err
+	}
+
+	hash := sha256.Sum256([]byte(key))
+
+	m.mutex.Lock()
+
+	var account *ServiceAccount
+
+	for _, candidate := range m.accounts {
+		if string(candidate.KeyHash) == string(hash[:]) && candidate.Active {
+			result := *candidate
+			account = &result
+			break
+		}
+	}
+
+	m.mutex.Unlock()
+
+	if account == nil {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	m.users.mutex.Lock()
+	defer m.users.mutex.Unlock()
+
+	owner, found := m.users.users[account.OwnerID]
+	if !found {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	user := *owner
+	user.TokenPermissions = account.Permissions
+
+	return account, &user, nil
+}
+
+// Updates a service account, using optimistic locking on its version
+func (m *MockServiceAccountModel) Update(ctx context.Context, account *ServiceAccount) error {
+	if err := m.err("Update"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	existing, found := m.accounts[account.ID]
+	if !found || existing.OwnerID != account.OwnerID || existing.Version != account.Version {
+		return ErrEditConflict
+	}
+
+	account.Version++
+
+	stored := *account
+	m.accounts[account.ID] = &stored
+
+	return nil
+}
+
+// Deletes one of a user's service accounts
+func (m *MockServiceAccountModel) Delete(ctx context.Context, ownerID, id int64) error {
+	if err := m.err("Delete"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	account, found := m.accounts[id]
+	if !found || account.OwnerID != ownerID {
+		return ErrRecordNotFound
+	}
+
+	delete(m.accounts, id)
+
+	return nil
+}
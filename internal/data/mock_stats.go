@@ -0,0 +1,45 @@
+package data
+
+import (
+	"context"
+	"sync"
+)
+
+// Define a mock of the `MovieStatsModel` struct type. View counts are kept in memory.
+// Errors can be injected with SetError().
+type MockMovieStatsModel struct {
+	mockErrors
+	mutex sync.Mutex
+	views map[int64]int64
+}
+
+// Return a new, empty MockMovieStatsModel
+func NewMockMovieStatsModel() *MockMovieStatsModel {
+	return &MockMovieStatsModel{
+		views: make(map[int64]int64),
+	}
+}
+
+// Add the given number of views to each movie
+func (m *MockMovieStatsModel) AddViews(ctx context.Context, views map[int64]int64) error {
+	if err := m.err("AddViews"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for id, count := range views {
+		m.views[id] += count
+	}
+
+	return nil
+}
+
+// Return the number of views recorded for a movie
+func (m *MockMovieStatsModel) Views(id int64) int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.views[id]
+}
@@ -1,22 +1,150 @@
 package data
 
-import "time"
+import (
+	"context"
+	"sync"
+	"time"
+)
 
-// Define a mock of the `TokenModel` struct type
-type MockTokenModel struct{}
+// Define a mock of the `TokenModel` struct type. Tokens are kept in memory, and are
+// used by MockUserModel to look up the user for a token. Errors can be injected with
+// SetError().
+type MockTokenModel struct {
+	mockErrors
+	mutex  sync.Mutex
+	tokens []Token
+}
+
+// Return a new, empty MockTokenModel
+func NewMockTokenModel() *MockTokenModel {
+	return &MockTokenModel{}
+}
 
 // The New() method is a shortcut which creates a new Token struct and then inserts the
 // data in the tokens table
-func (m MockTokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
-	return nil, nil
+func (m *MockTokenModel) New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
+	if err := m.err("New"); err != nil {
+		return nil, err
+	}
+
+	return m.NewWithPermissions(ctx, userID, ttl, scope, nil)
+}
+
+// The NewWithPermissions() method creates and inserts a new token which is restricted
+// to the given permissions
+func (m *MockTokenModel) NewWithPermissions(ctx context.Context, userID int64, ttl time.Duration, scope string, permissions Permissions) (*Token, error) {
+	if err := m.err("NewWithPermissions"); err != nil {
+		return nil, err
+	}
+
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Permissions = permissions
+
+	m.mutex.Lock()
+	m.tokens = append(m.tokens, *token)
+	m.mutex.Unlock()
+
+	return token, nil
+}
+
+// The NewImpersonation() method creates and inserts a new authentication token for an
+// admin to act as the given user
+func (m *MockTokenModel) NewImpersonation(ctx context.Context, userID, impersonatorID int64, ttl time.Duration) (*Token, error) {
+	if err := m.err("NewImpersonation"); err != nil {
+		return nil, err
+	}
+
+	token, err := generateToken(userID, ttl, ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+
+	token.ImpersonatorID = impersonatorID
+
+	m.mutex.Lock()
+	m.tokens = append(m.tokens, *token)
+	m.mutex.Unlock()
+
+	return token, nil
 }
 
 // Insert() adds the data for a specific token to the tokens table
-func (m MockTokenModel) Insert(token *Token) error {
+func (m *MockTokenModel) Insert(ctx context.Context, token *Token) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.tokens = append(m.tokens, *token)
+
 	return nil
 }
 
+// DeleteExpired() deletes up to limit tokens which expired before the given time
+func (m *MockTokenModel) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	if err := m.err("DeleteExpired"); err != nil {
+		return 0, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var deleted int64
+
+	tokens := m.tokens[:0]
+
+	for _, token := range m.tokens {
+		if token.Expiry.Before(before) && deleted < int64(limit) {
+			deleted++
+			continue
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	m.tokens = tokens
+
+	return deleted, nil
+}
+
 // DeleteAllForUser() deletes all tokens for a specific user and scope
-func (m MockTokenModel) DeleteAllForUser(scope string, userID int64) error {
+func (m *MockTokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	if err := m.err("DeleteAllForUser"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	tokens := m.tokens[:0]
+
+	for _, token := range m.tokens {
+		if token.Scope != scope || token.UserID != userID {
+			tokens = append(tokens, token)
+		}
+	}
+
+	m.tokens = tokens
+
 	return nil
 }
+
+// Return the token with the given hash and scope, which may have expired
+func (m *MockTokenModel) tokenFor(hash []byte, scope string) (Token, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, token := range m.tokens {
+		if string(token.Hash) == string(hash) && token.Scope == scope {
+			return token, true
+		}
+	}
+
+	return Token{}, false
+}
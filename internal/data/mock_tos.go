@@ -0,0 +1,61 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `TOSAcceptanceModel` struct type. Acceptances are kept in memory,
+// keyed by user and version. Errors can be injected with SetError().
+type MockTOSAcceptanceModel struct {
+	mockErrors
+	mutex       sync.Mutex
+	acceptances map[int64]map[string]time.Time
+}
+
+// Return a new, empty MockTOSAcceptanceModel
+func NewMockTOSAcceptanceModel() *MockTOSAcceptanceModel {
+	return &MockTOSAcceptanceModel{
+		acceptances: make(map[int64]map[string]time.Time),
+	}
+}
+
+// Record that a user accepted a version of the terms of service, keeping the time it
+// was first accepted
+func (m *MockTOSAcceptanceModel) Accept(ctx context.Context, acceptance *TOSAcceptance) error {
+	if err := m.err("Accept"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	versions, found := m.acceptances[acceptance.UserID]
+	if !found {
+		versions = make(map[string]time.Time)
+		m.acceptances[acceptance.UserID] = versions
+	}
+
+	if _, found := versions[acceptance.Version]; !found {
+		versions[acceptance.Version] = time.Now()
+	}
+
+	acceptance.AcceptedAt = versions[acceptance.Version]
+
+	return nil
+}
+
+// Report whether a user has accepted a version of the terms of service
+func (m *MockTOSAcceptanceModel) HasAccepted(ctx context.Context, userID int64, version string) (bool, error) {
+	if err := m.err("HasAccepted"); err != nil {
+		return false, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, accepted := m.acceptances[userID][version]
+
+	return accepted, nil
+}
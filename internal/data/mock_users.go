@@ -1,24 +1,265 @@
 package data
 
-// Define a mock of the `UserModel` struct type
-type MockUserModel struct{}
+import (
+	"context"
+	"crypto/sha256"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Define a mock of the `UserModel` struct type. Users are kept in memory, and email
+// addresses are unique ignoring case (like the citext column in the database). Tokens
+// are looked up in the given MockTokenModel. Errors can be injected with SetError().
+type MockUserModel struct {
+	mockErrors
+	mutex    sync.Mutex
+	nextID   int64
+	users    map[int64]*User
+	statuses map[int64]EmailStatus
+	tokens   *MockTokenModel
+}
+
+// Return a new MockUserModel containing the given users, which looks up tokens in the
+// given token model
+func NewMockUserModel(tokens *MockTokenModel, users ...*User) *MockUserModel {
+	m := &MockUserModel{
+		nextID:   1,
+		users:    make(map[int64]*User),
+		statuses: make(map[int64]EmailStatus),
+		tokens:   tokens,
+	}
+
+	m.Seed(users...)
+
+	return m
+}
+
+// Seed adds users to the mock without going through Insert(), so injected errors don't
+// apply. Users without an ID or version are given one.
+func (m *MockUserModel) Seed(users ...*User) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, user := range users {
+		m.store(user)
+	}
+}
+
+// Store a copy of a user, assigning the fields which the database would fill in
+func (m *MockUserModel) store(user *User) {
+	if user.ID == 0 {
+		user.ID = m.nextID
+	}
+
+	if user.ID >= m.nextID {
+		m.nextID = user.ID + 1
+	}
+
+	if user.Version == 0 {
+		user.Version = 1
+	}
+
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+
+	stored := *user
+	m.users[user.ID] = &stored
+}
+
+// Report whether another user already has the given email address
+func (m *MockUserModel) emailTaken(email string, exceptID int64) bool {
+	for _, user := range m.users {
+		if user.ID != exceptID && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+
+	return false
+}
 
 // Inserts a new record in the `users` table
-func (m MockUserModel) Insert(user *User) error {
+func (m *MockUserModel) Insert(ctx context.Context, user *User) error {
+	if err := m.err("Insert"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.emailTaken(user.Email, 0) {
+		return ErrDuplicateEmail
+	}
+
+	user.ID = 0
+	user.Version = 0
+	user.CreatedAt = time.Time{}
+
+	m.store(user)
+
 	return nil
 }
 
+// Fetches a specific user by ID
+func (m *MockUserModel) Get(ctx context.Context, id int64) (*User, error) {
+	if err := m.err("Get"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	user, found := m.users[id]
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	stored := *user
+
+	return &stored, nil
+}
+
 // Fetches a specific record from the `users` table by given email
-func (m MockUserModel) GetByEmail(email string) (*User, error) {
-	return nil, nil
+func (m *MockUserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	if err := m.err("GetByEmail"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, user := range m.users {
+		if strings.EqualFold(user.Email, email) {
+			found := *user
+			return &found, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
 }
 
 // Updates a specific record from the `users` table
-func (m MockUserModel) Update(user *User) error {
+func (m *MockUserModel) Update(ctx context.Context, user *User) error {
+	if err := m.err("Update"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.emailTaken(user.Email, user.ID) {
+		return ErrDuplicateEmail
+	}
+
+	existing, found := m.users[user.ID]
+	if !found || existing.Version != user.Version {
+		return ErrEditConflict
+	}
+
+	user.Version++
+
+	stored := *user
+	m.users[user.ID] = &stored
+
 	return nil
 }
 
 // Fetch user linked to given token
-func (m MockUserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
-	return nil, nil
+func (m *MockUserModel) GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error) {
+	if err := m.err("GetForToken"); err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	token, found := m.tokens.tokenFor(hash[:], tokenScope)
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	if !token.Expiry.After(time.Now()) {
+		return nil, ErrTokenExpired
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	user, found := m.users[token.UserID]
+	if !found {
+		return nil, ErrRecordNotFound
+	}
+
+	result := *user
+	result.TokenPermissions = token.Permissions
+	result.ImpersonatorID = token.ImpersonatorID
+
+	return &result, nil
+}
+
+// Set the delivery status of the user with the given email address. A soft bounce
+// doesn't replace a hard bounce or a complaint.
+func (m *MockUserModel) SetEmailStatus(ctx context.Context, email, status string) error {
+	if err := m.err("SetEmailStatus"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for id, user := range m.users {
+		if !strings.EqualFold(user.Email, email) {
+			continue
+		}
+
+		if status == EmailStatusSoftBounce && m.statuses[id].Suppressed() {
+			return nil
+		}
+
+		now := time.Now()
+		m.statuses[id] = EmailStatus{Status: status, UpdatedAt: &now}
+
+		return nil
+	}
+
+	return ErrRecordNotFound
+}
+
+// Return the delivery status of a user's email address
+func (m *MockUserModel) GetEmailStatus(ctx context.Context, userID int64) (*EmailStatus, error) {
+	if err := m.err("GetEmailStatus"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, found := m.users[userID]; !found {
+		return nil, ErrRecordNotFound
+	}
+
+	status, found := m.statuses[userID]
+	if !found {
+		status = EmailStatus{Status: EmailStatusOK}
+	}
+
+	return &status, nil
+}
+
+// Report whether emails to an address mustn't be sent
+func (m *MockUserModel) EmailSuppressed(ctx context.Context, email string) (bool, error) {
+	if err := m.err("EmailSuppressed"); err != nil {
+		return false, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for id, user := range m.users {
+		if strings.EqualFold(user.Email, email) {
+			return m.statuses[id].Suppressed(), nil
+		}
+	}
+
+	return false, nil
 }
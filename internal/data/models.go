@@ -1,9 +1,12 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/metrics"
 )
 
 // We'll return this from our Get() method when
@@ -14,47 +17,314 @@ var ErrRecordNotFound = errors.New("record not found")
 // a movie is being updated by more than 1 person at the the same time - data race.
 var ErrEditConflict = errors.New("edit conflict")
 
+// Define the interfaces satisfied by each model. Naming them (rather than declaring
+// them inline in the Models struct) lets us wrap a model with decorators, such as
+// caches, which implement the same interface.
+type MovieStore interface {
+	Insert(ctx context.Context, movie *Movie) error
+	Get(ctx context.Context, id int64) (*Movie, error)
+	Update(ctx context.Context, movie *Movie) error
+	Delete(ctx context.Context, id int64) error
+	GetAll(ctx context.Context, search MovieSearch, filters Filters) ([]*Movie, Metadata, error)
+	GetMany(ctx context.Context, ids []int64) ([]*Movie, error)
+	Facets(ctx context.Context, search MovieSearch) (*MovieFacets, error)
+	Autocomplete(ctx context.Context, query string, limit int) ([]*MovieSuggestion, error)
+	GetTimestamps(ctx context.Context, offset, limit int) ([]*MovieTimestamp, int, error)
+	PublishScheduled(ctx context.Context, now time.Time) ([]*Movie, error)
+}
+
+type MovieStatsStore interface {
+	AddViews(ctx context.Context, views map[int64]int64) error
+}
+
+type MovieReleaseStore interface {
+	Insert(ctx context.Context, release *MovieRelease) error
+	Get(ctx context.Context, movieID, id int64) (*MovieRelease, error)
+	GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieRelease, error)
+	Update(ctx context.Context, release *MovieRelease) error
+	Delete(ctx context.Context, movieID, id int64) error
+}
+
+type MovieChangeStore interface {
+	Insert(ctx context.Context, change *MovieChange) error
+	Get(ctx context.Context, movieID, id int64) (*MovieChange, error)
+	GetAllForMovie(ctx context.Context, movieID int64, status string, filters Filters) ([]*MovieChange, Metadata, error)
+	Review(ctx context.Context, change *MovieChange) error
+	DeleteReviewed(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+type AvailabilityStore interface {
+	GetForCountry(ctx context.Context, movieID int64, country string) (*Availability, error)
+	GetBatchForRefresh(ctx context.Context, refreshedBefore time.Time, afterID int64, limit int) ([]*AvailabilityRefresh, error)
+	Set(ctx context.Context, movieID, providerMovieID int64, countries map[string]CountryAvailability) error
+}
+
+type ReviewStore interface {
+	Insert(ctx context.Context, review *Review) error
+	Get(ctx context.Context, id int64) (*Review, error)
+	GetAllForMovie(ctx context.Context, movieID int64, filters Filters) ([]*Review, Metadata, error)
+	GetAllByStatus(ctx context.Context, status string, filters Filters) ([]*Review, Metadata, error)
+	Moderate(ctx context.Context, review *Review) error
+}
+
+type ReportStore interface {
+	Insert(ctx context.Context, report *ReviewReport) (int, error)
+	Get(ctx context.Context, id int64) (*ReviewReport, error)
+	GetAll(ctx context.Context, status, reason string, filters Filters) ([]*ReviewReport, Metadata, error)
+	Resolve(ctx context.Context, report *ReviewReport) error
+	ResolveForReview(ctx context.Context, reviewID int64, status string, resolvedBy int64) error
+}
+
+type CollectionStore interface {
+	Insert(ctx context.Context, collection *Collection) error
+	Get(ctx context.Context, id int64) (*Collection, error)
+	GetForMovies(ctx context.Context, movieIDs []int64) (map[int64]*Collection, error)
+	GetMovies(ctx context.Context, id int64) ([]*Movie, error)
+	GetAll(ctx context.Context, name string, filters Filters) ([]*Collection, Metadata, error)
+	Update(ctx context.Context, collection *Collection) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type UserStore interface {
+	Insert(ctx context.Context, user *User) error
+	Get(ctx context.Context, id int64) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	Update(ctx context.Context, user *User) error
+	GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error)
+	SetEmailStatus(ctx context.Context, email, status string) error
+	GetEmailStatus(ctx context.Context, userID int64) (*EmailStatus, error)
+	EmailSuppressed(ctx context.Context, email string) (bool, error)
+}
+
+type TokenStore interface {
+	New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error)
+	NewWithPermissions(ctx context.Context, userID int64, ttl time.Duration, scope string, permissions Permissions) (*Token, error)
+	NewImpersonation(ctx context.Context, userID, impersonatorID int64, ttl time.Duration) (*Token, error)
+	Insert(ctx context.Context, token *Token) error
+	DeleteAllForUser(ctx context.Context, scope string, userID int64) error
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+type PermissionStore interface {
+	GetAllForUser(ctx context.Context, userID int64) (Permissions, error)
+	AddForUser(ctx context.Context, userID int64, codes ...string) error
+	HasPermission(ctx context.Context, userID int64, codes ...string) (bool, error)
+	RemoveForUser(ctx context.Context, userID int64, codes ...string) error
+}
+
+type DeviceStore interface {
+	Record(ctx context.Context, device *Device) (bool, error)
+}
+
+type TOSAcceptanceStore interface {
+	Accept(ctx context.Context, acceptance *TOSAcceptance) error
+	HasAccepted(ctx context.Context, userID int64, version string) (bool, error)
+}
+
+type NotificationStore interface {
+	Insert(ctx context.Context, notification *Notification) error
+	GetAllForUser(ctx context.Context, userID int64, unreadOnly bool, filters Filters) ([]*Notification, Metadata, error)
+	MarkRead(ctx context.Context, userID, id int64) error
+	GetPreferences(ctx context.Context, userID int64) (NotificationPreferences, error)
+	SetPreferences(ctx context.Context, userID int64, preferences NotificationPreferences) error
+	DeleteRead(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+type SavedSearchStore interface {
+	Insert(ctx context.Context, search *SavedSearch) error
+	GetAllForUser(ctx context.Context, userID int64) ([]*SavedSearch, error)
+	GetBatchForAlerts(ctx context.Context, afterID int64, limit int) ([]*SavedSearch, error)
+	SetLastMovieID(ctx context.Context, id, lastMovieID int64) error
+	Delete(ctx context.Context, userID, id int64) error
+}
+
+type DigestSubscriptionStore interface {
+	Upsert(ctx context.Context, subscription *DigestSubscription) error
+	Get(ctx context.Context, userID int64) (*DigestSubscription, error)
+	GetBatchDue(ctx context.Context, afterUserID int64, limit int) ([]*DigestSubscription, error)
+	MarkSent(ctx context.Context, userID, lastMovieID int64, nextDigestAt time.Time) error
+	Delete(ctx context.Context, userID int64) error
+}
+
+type AdminStatsStore interface {
+	Get(ctx context.Context, days int) (*AdminStats, error)
+}
+
+type MetricSnapshotStore interface {
+	Insert(ctx context.Context, snapshot *MetricSnapshot) error
+	GetAll(ctx context.Context, filters Filters) ([]*MetricSnapshot, Metadata, error)
+}
+
+type ServiceAccountStore interface {
+	Insert(ctx context.Context, account *ServiceAccount) error
+	Get(ctx context.Context, ownerID, id int64) (*ServiceAccount, error)
+	GetAllForOwner(ctx context.Context, ownerID int64) ([]*ServiceAccount, error)
+	GetForKey(ctx context.Context, key string) (*ServiceAccount, *User, error)
+	Update(ctx context.Context, account *ServiceAccount) error
+	Delete(ctx context.Context, ownerID, id int64) error
+}
+
+type InvitationStore interface {
+	Insert(ctx context.Context, invitation *Invitation) error
+	GetAll(ctx context.Context, status string, filters Filters) ([]*Invitation, Metadata, error)
+	GetPendingForCode(ctx context.Context, code string) (*Invitation, error)
+	Accept(ctx context.Context, id int64) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type BackupStore interface {
+	Insert(ctx context.Context, backup *Backup) error
+	Finish(ctx context.Context, backup *Backup) error
+	GetAll(ctx context.Context, filters Filters) ([]*Backup, Metadata, error)
+	GetExpired(ctx context.Context, keep int) ([]*Backup, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+type OperationStore interface {
+	Insert(ctx context.Context, operation *Operation) error
+	Get(ctx context.Context, id int64) (*Operation, error)
+	GetAll(ctx context.Context, filters Filters) ([]*Operation, Metadata, error)
+	UpdateProgress(ctx context.Context, operation *Operation) error
+	Finish(ctx context.Context, operation *Operation) error
+}
+
+type ClientStore interface {
+	GetForKey(ctx context.Context, key string) (*APIClient, *User, error)
+}
+
 type Models struct {
-	Movie interface {
-		Insert(movie *Movie) error
-		Get(id int64) (*Movie, error)
-		Update(movie *Movie) error
-		Delete(id int64) error
-		GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error)
-	}
-	User interface {
-		Insert(user *User) error
-		GetByEmail(email string) (*User, error)
-		Update(user *User) error
-		GetForToken(tokenScope, tokenPlaintext string) (*User, error)
-	}
-	Token interface {
-		New(userID int64, ttl time.Duration, scope string) (*Token, error)
-		Insert(token *Token) error
-		DeleteAllForUser(scope string, userID int64) error
-	}
-	Permissions interface {
-		GetAllForUser(userID int64) (Permissions, error)
-		AddForUser(userID int64, codes ...string) error
-	}
+	Movie           MovieStore
+	MovieStats      MovieStatsStore
+	Releases        MovieReleaseStore
+	MovieChanges    MovieChangeStore
+	Availability    AvailabilityStore
+	Reviews         ReviewStore
+	Reports         ReportStore
+	Collections     CollectionStore
+	User            UserStore
+	Token           TokenStore
+	Permissions     PermissionStore
+	Clients         ClientStore
+	ServiceAccounts ServiceAccountStore
+	Invitations     InvitationStore
+	Devices         DeviceStore
+	TOSAcceptances  TOSAcceptanceStore
+	Notifications   NotificationStore
+	SavedSearches   SavedSearchStore
+	Digests         DigestSubscriptionStore
+	AdminStats      AdminStatsStore
+	MetricSnapshots MetricSnapshotStore
+	Backups         BackupStore
+	Operations      OperationStore
+	statements      []*Statements
 }
 
-// Method used to initialize `Models` struct
+// Method used to initialize `Models` struct. The models share a cache of prepared
+// statements, which is released by calling Close().
 func NewModels(db *sql.DB) Models {
+	statements := NewStatements(db)
+
+	return newModels(statements, statements)
+}
+
+// Method used to initialize `Models` struct with query instrumentation. The duration of
+// every query is recorded in the given histogram, and queries slower than the
+// threshold are passed to onSlowQuery. If a replica is given, the read-only queries of
+// contexts created with WithReplicaReads() are sent to it.
+func NewInstrumentedModels(db, replica *sql.DB, durations *metrics.HistogramVec, threshold time.Duration, onSlowQuery func(SlowQuery)) Models {
+	primary := NewStatements(db)
+
+	if replica == nil {
+		return newModels(NewInstrumentedQuerier(primary, durations, threshold, onSlowQuery), primary)
+	}
+
+	replicated := NewStatements(replica)
+	routed := RoutedQuerier{Primary: primary, Replica: replicated}
+
+	return newModels(NewInstrumentedQuerier(routed, durations, threshold, onSlowQuery), primary, replicated)
+}
+
+// Initialize the models so that they run their queries through the given querier
+func newModels(querier Querier, statements ...*Statements) Models {
 	return Models{
-		Movie:       MovieModel{DB: db},
-		User:        UserModel{DB: db},
-		Token:       TokenModel{DB: db},
-		Permissions: PermissionModel{DB: db},
+		Movie:           MovieModel{DB: querier},
+		MovieStats:      MovieStatsModel{DB: querier},
+		Releases:        MovieReleaseModel{DB: querier},
+		MovieChanges:    MovieChangeModel{DB: querier},
+		Availability:    AvailabilityModel{DB: querier},
+		Reviews:         ReviewModel{DB: querier},
+		Reports:         ReportModel{DB: querier},
+		Collections:     CollectionModel{DB: querier},
+		User:            UserModel{DB: querier},
+		Token:           TokenModel{DB: querier},
+		Permissions:     PermissionModel{DB: querier},
+		Clients:         ClientModel{DB: querier},
+		ServiceAccounts: ServiceAccountModel{DB: querier},
+		Invitations:     InvitationModel{DB: querier},
+		Devices:         DeviceModel{DB: querier},
+		TOSAcceptances:  TOSAcceptanceModel{DB: querier},
+		Notifications:   NotificationModel{DB: querier},
+		SavedSearches:   SavedSearchModel{DB: querier},
+		Digests:         DigestSubscriptionModel{DB: querier},
+		AdminStats:      AdminStatsModel{DB: querier},
+		MetricSnapshots: MetricSnapshotModel{DB: querier},
+		Backups:         BackupModel{DB: querier},
+		Operations:      OperationModel{DB: querier},
+		statements:      statements,
+	}
+}
+
+// Close releases the prepared statements used by the models
+func (m Models) Close() error {
+	var firstErr error
+
+	for _, statements := range m.statements {
+		err := statements.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
+
+	return firstErr
 }
 
-// Method used to initialize mock of `Models` struct
-func NewMockModels(db *sql.DB) Models {
+// Method used to initialize mock of `Models` struct. The mocks keep their data in
+// memory, the user mock looks up tokens in the token mock, and the release, movie
+// change, availability, review, collection, saved search and digest mocks look up movies in the
+// movie mock. The report mock looks up reviews in the review mock, the service account mock
+// looks up owners in the user mock, and the admin stats mock counts the records in the
+// movie, user and review mocks. Tests which need to seed
+// data or inject errors can assert the fields back to their mock types, or build the
+// Models struct from the NewMock*Model() constructors directly.
+func NewMockModels() Models {
+	tokens := NewMockTokenModel()
+	movies := NewMockMovieModel()
+	reviews := NewMockReviewModel(movies)
+	users := NewMockUserModel(tokens)
+
 	return Models{
-		Movie:       MockMovieModel{},
-		User:        MockUserModel{},
-		Token:       MockTokenModel{},
-		Permissions: MockPermissionsModel{},
+		Movie:           movies,
+		MovieStats:      NewMockMovieStatsModel(),
+		Releases:        NewMockMovieReleaseModel(movies),
+		MovieChanges:    NewMockMovieChangeModel(movies),
+		Availability:    NewMockAvailabilityModel(movies),
+		Reviews:         reviews,
+		Reports:         NewMockReportModel(reviews),
+		Collections:     NewMockCollectionModel(movies),
+		User:            users,
+		Token:           tokens,
+		Permissions:     NewMockPermissionsModel(),
+		Clients:         NewMockClientModel(),
+		ServiceAccounts: NewMockServiceAccountModel(users),
+		Invitations:     NewMockInvitationModel(),
+		Devices:         NewMockDeviceModel(),
+		TOSAcceptances:  NewMockTOSAcceptanceModel(),
+		Notifications:   NewMockNotificationModel(),
+		SavedSearches:   NewMockSavedSearchModel(movies),
+		Digests:         NewMockDigestSubscriptionModel(movies),
+		AdminStats:      NewMockAdminStatsModel(movies, users, reviews),
+		MetricSnapshots: NewMockMetricSnapshotModel(),
+		Backups:         NewMockBackupModel(),
+		Operations:      NewMockOperationModel(),
 	}
 }
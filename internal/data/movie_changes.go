@@ -0,0 +1,377 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Define the statuses of a proposed change to a movie. Approved changes have been applied
+// to the movie, and neither approved nor rejected changes can be reviewed again.
+const (
+	MovieChangePending  = "pending"
+	MovieChangeApproved = "approved"
+	MovieChangeRejected = "rejected"
+)
+
+// Define a MoviePatch struct holding the fields to change on a movie, as sent to
+// "PATCH /v1/movies/:id" or proposed for approval. We use pointers so that we get a nil
+// value when decoding these values from JSON. This way we can check if a user has
+// provided the key/value pair in the JSON or not.
+type MoviePatch struct {
+	Title               *string            `json:"title"`
+	Year                *int32             `json:"year"`
+	Runtime             *Runtime           `json:"runtime"`
+	Genres              []string           `json:"genres"`
+	Synopsis            *string            `json:"synopsis"`
+	OriginalLanguage    *string            `json:"original_language"`
+	ProductionCountries []string           `json:"production_countries"`
+	Certifications      Certifications     `json:"certifications"`
+	Metadata            map[string]*string `json:"metadata"`
+	Status              *string            `json:"status"`
+	PublishAt           *time.Time         `json:"publish_at"`
+}
+
+// Report whether the patch doesn't change any field
+func (p MoviePatch) IsEmpty() bool {
+	return p.Title == nil && p.Year == nil && p.Runtime == nil && p.Genres == nil && p.Synopsis == nil &&
+		p.OriginalLanguage == nil && p.ProductionCountries == nil && p.Certifications == nil && p.Metadata == nil &&
+		p.Status == nil && p.PublishAt == nil
+}
+
+// Copy the values of the patch to a movie if they exist
+func (p MoviePatch) Apply(movie *Movie) {
+	if p.Year != nil {
+		movie.Year = *p.Year
+	}
+
+	if p.Title != nil {
+		movie.Title = *p.Title
+	}
+
+	if p.Runtime != nil {
+		movie.Runtime = *p.Runtime
+	}
+
+	if p.Genres != nil {
+		movie.Genres = p.Genres
+	}
+
+	if p.Synopsis != nil {
+		movie.Synopsis = *p.Synopsis
+	}
+
+	if p.OriginalLanguage != nil {
+		movie.OriginalLanguage = *p.OriginalLanguage
+	}
+
+	if p.ProductionCountries != nil {
+		movie.ProductionCountries = p.ProductionCountries
+	}
+
+	// An empty object removes all of the movie's certifications
+	if p.Certifications != nil {
+		movie.Certifications = p.Certifications
+	}
+
+	// Metadata is merged into the movie's metadata, so that clients only send the keys
+	// they change. A null value removes the key.
+	if p.Metadata != nil {
+		metadata := make(MovieMetadata, len(movie.Metadata)+len(p.Metadata))
+		for key, value := range movie.Metadata {
+			metadata[key] = value
+		}
+
+		for key, value := range p.Metadata {
+			if value == nil {
+				delete(metadata, key)
+				continue
+			}
+
+			metadata[key] = *value
+		}
+
+		movie.Metadata = metadata
+	}
+
+	if p.Status != nil {
+		movie.Status = *p.Status
+	}
+
+	if p.PublishAt != nil {
+		movie.PublishAt = p.PublishAt
+	}
+}
+
+// Implement the json.Marshaler interface, leaving out the fields which aren't changed.
+// Empty lists and objects are kept, as they clear the field.
+func (p MoviePatch) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{})
+
+	if p.Title != nil {
+		fields["title"] = *p.Title
+	}
+
+	if p.Year != nil {
+		fields["year"] = *p.Year
+	}
+
+	if p.Runtime != nil {
+		fields["runtime"] = *p.Runtime
+	}
+
+	if p.Genres != nil {
+		fields["genres"] = p.Genres
+	}
+
+	if p.Synopsis != nil {
+		fields["synopsis"] = *p.Synopsis
+	}
+
+	if p.OriginalLanguage != nil {
+		fields["original_language"] = *p.OriginalLanguage
+	}
+
+	if p.ProductionCountries != nil {
+		fields["production_countries"] = p.ProductionCountries
+	}
+
+	if p.Certifications != nil {
+		fields["certifications"] = map[string]string(p.Certifications)
+	}
+
+	if p.Metadata != nil {
+		fields["metadata"] = p.Metadata
+	}
+
+	if p.Status != nil {
+		fields["status"] = *p.Status
+	}
+
+	if p.PublishAt != nil {
+		fields["publish_at"] = *p.PublishAt
+	}
+
+	return json.Marshal(fields)
+}
+
+// Implement the driver.Valuer interface, so that a patch can be written to the database
+func (p MoviePatch) Value() (driver.Value, error) {
+	value, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the value as text, as pq sends []byte values in binary format
+	return string(value), nil
+}
+
+// Implement the sql.Scanner interface, so that a patch can be read from the database
+func (p *MoviePatch) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case []byte:
+		return json.Unmarshal(value, p)
+	case string:
+		return json.Unmarshal([]byte(value), p)
+	default:
+		return errors.New("movie patch must be scanned from []byte or string")
+	}
+}
+
+// Define a MovieChange struct to represent a change to a movie proposed by a contributor,
+// which is only applied to the movie once an editor approves it
+type MovieChange struct {
+	ID           int64      `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	MovieID      int64      `json:"movie_id"`
+	UserID       int64      `json:"user_id"`
+	BaseVersion  int32      `json:"base_version"` // The version of the movie the change was proposed against
+	Changes      MoviePatch `json:"changes"`
+	Status       string     `json:"status"`
+	ReviewReason string     `json:"review_reason,omitempty"` // Why the change was rejected
+	ReviewedBy   int64      `json:"-"`                       // The editor's user ID
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+	Version      int32      `json:"version"`
+}
+
+// Run validation checks on a proposed change, given the movie it is proposed for
+func ValidateMovieChange(v *validator.Validator, change *MovieChange, movie *Movie) {
+	v.Check(!change.Changes.IsEmpty(), "changes", "must change at least one field", validator.CodeRequired)
+
+	// The changed movie must be valid as a whole, so that approving the change can't fail
+	// validation unless the movie has changed in the meantime
+	changed := copyMovie(movie)
+	change.Changes.Apply(changed)
+
+	ValidateMovie(v, changed)
+}
+
+// Define a MovieChangeModel struct type which wraps a sql.DB connection pool
+type MovieChangeModel struct {
+	DB Querier
+}
+
+// The columns selected for a change, in the order scanned by movieChangeFields()
+const movieChangeColumns = `id, created_at, movie_id, user_id, base_version, changes, status,
+	review_reason, COALESCE(reviewed_by, 0), reviewed_at, version`
+
+// Return the destinations for scanning the movieChangeColumns into a change
+func movieChangeFields(change *MovieChange) []interface{} {
+	return []interface{}{
+		&change.ID,
+		&change.CreatedAt,
+		&change.MovieID,
+		&change.UserID,
+		&change.BaseVersion,
+		&change.Changes,
+		&change.Status,
+		&change.ReviewReason,
+		&change.ReviewedBy,
+		&change.ReviewedAt,
+		&change.Version,
+	}
+}
+
+// Inserts a new proposed change. It returns ErrRecordNotFound if the movie doesn't exist.
+func (m MovieChangeModel) Insert(ctx context.Context, change *MovieChange) error {
+	query := `
+		INSERT INTO movie_changes (movie_id, user_id, base_version, changes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, status, version`
+
+	err := m.DB.QueryRowContext(
+		ctx,
+		query,
+		change.MovieID,
+		change.UserID,
+		change.BaseVersion,
+		change.Changes,
+	).Scan(&change.ID, &change.CreatedAt, &change.Status, &change.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "movie_changes" violates foreign key constraint "movie_changes_movie_id_fkey"`:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fetches a specific change proposed for a movie
+func (m MovieChangeModel) Get(ctx context.Context, movieID, id int64) (*MovieChange, error) {
+	if movieID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + movieChangeColumns + `
+		FROM movie_changes
+		WHERE movie_id = $1 AND id = $2`
+
+	var change MovieChange
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, id).Scan(movieChangeFields(&change)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &change, nil
+}
+
+// Fetches a page of the changes with the given status proposed for a movie
+func (m MovieChangeModel) GetAllForMovie(ctx context.Context, movieID int64, status string, filters Filters) ([]*MovieChange, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), %s
+		FROM movie_changes
+		WHERE movie_id = $1 AND status = $2
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4`, movieChangeColumns, filters.sortColumn(), filters.sortDirection())
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	changes := []*MovieChange{}
+
+	for rows.Next() {
+		var change MovieChange
+
+		err := rows.Scan(append([]interface{}{&totalRecords}, movieChangeFields(&change)...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		changes = append(changes, &change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return changes, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Records an editor's decision on a change, moving it to the change's status. The
+// decision is only recorded if the change is still pending and its version matches, so
+// that two editors can't both decide on the same change.
+func (m MovieChangeModel) Review(ctx context.Context, change *MovieChange) error {
+	query := `
+		UPDATE movie_changes
+		SET status = $1, review_reason = $2, reviewed_by = NULLIF($3, 0), reviewed_at = NOW(),
+			version = version + 1
+		WHERE id = $4 AND version = $5 AND status = 'pending'
+		RETURNING reviewed_at, version`
+
+	err := m.DB.QueryRowContext(
+		ctx,
+		query,
+		change.Status,
+		change.ReviewReason,
+		change.ReviewedBy,
+		change.ID,
+		change.Version,
+	).Scan(&change.ReviewedAt, &change.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deletes up to limit changes which were reviewed before the given time, returning how
+// many were deleted. Pending changes are kept however old they are, as an editor still
+// has to decide on them.
+func (m MovieChangeModel) DeleteReviewed(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM movie_changes
+		WHERE id IN (SELECT id FROM movie_changes WHERE reviewed_at < $1 LIMIT $2)`
+
+	result, err := m.DB.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
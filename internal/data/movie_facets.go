@@ -0,0 +1,60 @@
+package data
+
+import (
+	"strconv"
+)
+
+// The width in minutes of the runtime ranges movies are counted in by facets
+const RuntimeRangeWidth = 30
+
+// Define a MovieFacets struct holding the number of movies matching a search for each
+// genre, release year, decade, runtime range and certification, so that clients can show
+// how many movies each filter would leave. Decades are keyed such as "1990s", runtime
+// ranges by their bounds in minutes, such as "90-119", and certifications in the
+// "<country>:<certification>" format of the certification filter. Movies without a year
+// or runtime aren't counted in those facets.
+type MovieFacets struct {
+	Genres         map[string]int `json:"genres"`
+	Years          map[string]int `json:"years"`
+	Decades        map[string]int `json:"decades"`
+	Runtimes       map[string]int `json:"runtimes"`
+	Certifications map[string]int `json:"certifications"`
+}
+
+// Return an empty set of facets
+func NewMovieFacets() *MovieFacets {
+	return &MovieFacets{
+		Genres:         map[string]int{},
+		Years:          map[string]int{},
+		Decades:        map[string]int{},
+		Runtimes:       map[string]int{},
+		Certifications: map[string]int{},
+	}
+}
+
+// Count movies released in a year, in both the year and decade facets
+func (f *MovieFacets) AddYear(year int, count int) {
+	if year <= 0 {
+		return
+	}
+
+	f.Years[strconv.Itoa(year)] += count
+	f.Decades[strconv.Itoa(year-year%10)+"s"] += count
+}
+
+// Count movies with a runtime in the range starting at the given number of minutes
+func (f *MovieFacets) AddRuntime(minutes int, count int) {
+	if minutes <= 0 {
+		return
+	}
+
+	f.Runtimes[RuntimeRange(minutes)] += count
+}
+
+// Return the key of the runtime range holding the given number of minutes, such as
+// "90-119"
+func RuntimeRange(minutes int) string {
+	start := minutes - minutes%RuntimeRangeWidth
+
+	return strconv.Itoa(start) + "-" + strconv.Itoa(start+RuntimeRangeWidth-1)
+}
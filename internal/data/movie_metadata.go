@@ -0,0 +1,82 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Declare a regular expression for sanity checking metadata keys. Keys are used in query
+// strings (?metadata.<key>=<value>), so they are limited to characters which don't need
+// escaping there.
+var MetadataKeyRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// The limits on the metadata of a movie
+const (
+	MaxMetadataKeys        = 50
+	MaxMetadataValueLength = 1000
+)
+
+// Define a MovieMetadata type holding deployment-specific attributes of a movie as string
+// keys and values (e.g. {"studio": "A24", "imdb_id": "tt0111161"}), which the API stores
+// and filters on without knowing what they mean. Like certifications it is stored in a
+// JSONB column, so that movies can be filtered on it using the column's index.
+type MovieMetadata map[string]string
+
+// Implement the driver.Valuer interface, so that metadata can be written to the
+// database. A nil map is stored as an empty object rather than as null.
+func (m MovieMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+
+	value, err := json.Marshal(map[string]string(m))
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the value as text, as pq sends []byte values in binary format
+	return string(value), nil
+}
+
+// Implement the sql.Scanner interface, so that metadata can be read from the database
+func (m *MovieMetadata) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case []byte:
+		return json.Unmarshal(value, m)
+	case string:
+		return json.Unmarshal([]byte(value), m)
+	default:
+		return errors.New("metadata must be scanned from []byte or string")
+	}
+}
+
+// Run validation checks on metadata, reporting errors under the given key
+func ValidateMovieMetadata(v *validator.Validator, key string, metadata MovieMetadata) {
+	v.Check(len(metadata) <= MaxMetadataKeys, key, fmt.Sprintf("must not contain more than %d keys", MaxMetadataKeys), validator.CodeTooMany, validator.Params{"max": MaxMetadataKeys})
+
+	// Check the keys in order, so that the same error is reported every time
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !validator.Matches(k, MetadataKeyRegex) {
+			v.AddError(key, fmt.Sprintf("must only contain keys of up to 64 lowercase letters, digits, underscores and hyphens (%q is invalid)", k), validator.CodeInvalidFormat, validator.Params{"format": MetadataKeyRegex.String()})
+			return
+		}
+
+		if len(metadata[k]) > MaxMetadataValueLength {
+			v.AddError(key, fmt.Sprintf("must not contain values more than %d bytes long (%s is too long)", MaxMetadataValueLength, k), validator.CodeTooLong, validator.Params{"max": MaxMetadataValueLength, "value": k})
+			return
+		}
+	}
+}
@@ -5,54 +5,186 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/LuisBarroso37/Greenlight/internal/validator"
 	"github.com/lib/pq"
 )
 
+// Declare regular expressions for sanity checking language and country codes
+var (
+	LanguageRegex = regexp.MustCompile(`^[a-z]{2}$`) // ISO 639-1 language code
+	CountryRegex  = regexp.MustCompile(`^[A-Z]{2}$`) // ISO 3166-1 alpha-2 country code
+)
+
+// Define the publishing statuses of a movie. Only published movies are shown to users
+// who can't edit movies. Scheduled movies are published by a background job once their
+// publish_at time has passed.
+const (
+	MovieDraft     = "draft"
+	MovieScheduled = "scheduled"
+	MoviePublished = "published"
+)
+
+// The validation rules being rolled out, which are switched to shadow mode and then
+// enforced with the -validation-shadow and -validation-enforce settings. Add new,
+// stricter rules here rather than enforcing them straight away, and move their checks
+// to v.Check() once they have been enforced for a while.
+const (
+	RuleMovieRuntimeLimit = "movie_runtime_limit" // Runtimes must be at most MaxMovieRuntime minutes
+)
+
+// ValidationRules lists the validation rules being rolled out
+var ValidationRules = []string{RuleMovieRuntimeLimit}
+
+// The longest runtime allowed for a movie, in minutes. Longer runtimes are almost always
+// typos or runtimes in seconds.
+const MaxMovieRuntime = 1000
+
 type Movie struct {
-	ID        int64     `json:"id"`
-	Title     string    `json:"title"`
-	Year      int32     `json:"year,omitempty"`    // Movie release year
-	Runtime   Runtime   `json:"runtime,omitempty"` // Movie runtime (in minutes)
-	Genres    []string  `json:"genres,omitempty"`
-	Version   int32     `json:"version"` // The version number starts at 1 and will be incremented each time the movie information is updated
-	CreatedAt time.Time `json:"-"`
+	ID                  int64          `json:"id"`
+	Title               string         `json:"title"`
+	Year                int32          `json:"year,omitempty"`    // Movie release year
+	Runtime             Runtime        `json:"runtime,omitempty"` // Movie runtime (in minutes)
+	Genres              []string       `json:"genres,omitempty"`
+	Synopsis            string         `json:"synopsis,omitempty"`
+	OriginalLanguage    string         `json:"original_language,omitempty"`    // ISO 639-1 language code
+	ProductionCountries []string       `json:"production_countries,omitempty"` // ISO 3166-1 alpha-2 country codes
+	Certifications      Certifications `json:"certifications,omitempty"`       // Age certification by country
+	Metadata            MovieMetadata  `json:"metadata,omitempty"`             // Deployment-specific attributes
+	CreatedBy           int64          `json:"created_by,omitempty"`           // ID of the user who created the movie, if known
+	Status              string         `json:"status"`                         // Draft, scheduled or published
+	PublishAt           *time.Time     `json:"publish_at,omitempty"`           // When a scheduled movie is (or was) published
+	Version             int32          `json:"version"`                        // The version number starts at 1 and will be incremented each time the movie information is updated
+	Views               int64          `json:"views"`                          // Number of times the movie has been viewed, updated in batches
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`           // Set when the movie is created and each time it is updated
+	Collection          *Collection    `json:"collection,omitempty"` // Only set when requested with ?include=collection
+}
+
+// Define a MovieSearch struct holding the conditions which GetAll() filters movies on.
+// Empty fields match every movie.
+type MovieSearch struct {
+	Title          string
+	Synopsis       string
+	Genres         []string       // Movies must have all of these genres
+	AnyGenres      []string       // Movies must have at least one of these genres
+	Language       string         // Original language
+	Countries      []string       // Movies must have been produced in all of these countries
+	Certifications Certifications // Movies must have all of these certifications
+	Metadata       MovieMetadata  // Movies must have all of these metadata values
+	CreatedBy      int64          // Movies must have been created by this user
+	PublishedOnly  bool           // Leave out draft and scheduled movies
+	ReleasedBefore Date           // Movies must have a release before this date
+	ReleasedAfter  Date           // Movies must have a release after this date
+	CreatedBefore  time.Time      // Movies must have been added before this time
+	CreatedAfter   time.Time      // Movies must have been added after this time
+}
+
+// The columns selected for a movie, in the order scanned by movieFields(). Queries
+// selecting them must join movie_stats.
+const movieColumns = `movies.id, movies.title, movies.year, movies.runtime, movies.genres, movies.synopsis,
+	movies.original_language, movies.production_countries, movies.certifications, movies.metadata,
+	COALESCE(movies.created_by, 0), movies.status, movies.publish_at, movies.version, movies.created_at,
+	movies.updated_at, COALESCE(movie_stats.views, 0)`
+
+// Return the destinations for scanning the movieColumns into a movie
+func movieFields(movie *Movie) []interface{} {
+	return []interface{}{
+		&movie.ID,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Synopsis,
+		&movie.OriginalLanguage,
+		pq.Array(&movie.ProductionCountries),
+		&movie.Certifications,
+		&movie.Metadata,
+		&movie.CreatedBy,
+		&movie.Status,
+		&movie.PublishAt,
+		&movie.Version,
+		&movie.CreatedAt,
+		&movie.UpdatedAt,
+		&movie.Views,
+	}
 }
 
 // Run validation checks on `Movie` struct
 func ValidateMovie(v *validator.Validator, movie *Movie) {
-	v.Check(movie.Title != "", "title", "must be provided")
-	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
+	v.Check(movie.Title != "", "title", "must be provided", validator.CodeRequired)
+	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long", validator.CodeTooLong, validator.Params{"max": 500})
+
+	v.Check(movie.Year != 0, "year", "must be provided", validator.CodeRequired)
+	v.Check(movie.Year >= 1888, "year", "must be greater than 1888", validator.CodeTooSmall, validator.Params{"min": 1888})
+	v.Check(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future", validator.CodeTooLarge, validator.Params{"max": time.Now().Year()})
 
-	v.Check(movie.Year != 0, "year", "must be provided")
-	v.Check(movie.Year >= 1888, "year", "must be greater than 1888")
-	v.Check(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future")
+	v.Check(movie.Runtime != 0, "runtime", "must be provided", validator.CodeRequired)
+	v.Check(movie.Runtime > 0, "runtime", "must be a positive integer", validator.CodeTooSmall, validator.Params{"min": 1})
+	v.CheckRule(RuleMovieRuntimeLimit, movie.Runtime <= MaxMovieRuntime, "runtime", fmt.Sprintf("must not be more than %d minutes", MaxMovieRuntime), validator.CodeTooLarge, validator.Params{"max": MaxMovieRuntime})
 
-	v.Check(movie.Runtime != 0, "runtime", "must be provided")
-	v.Check(movie.Runtime > 0, "runtime", "must be a positive integer")
+	v.Check(movie.Genres != nil, "genres", "must be provided", validator.CodeRequired)
+	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre", validator.CodeTooFew, validator.Params{"min": 1})
+	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres", validator.CodeTooMany, validator.Params{"max": 5})
+	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values", validator.CodeDuplicate)
 
-	v.Check(movie.Genres != nil, "genres", "must be provided")
-	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
-	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
-	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+	v.Check(len(movie.Synopsis) <= 5000, "synopsis", "must not be more than 5000 bytes long", validator.CodeTooLong, validator.Params{"max": 5000})
+
+	v.Check(movie.OriginalLanguage == "" || validator.Matches(movie.OriginalLanguage, LanguageRegex), "original_language", "must be a lowercase ISO 639-1 language code", validator.CodeInvalidFormat, validator.Params{"format": "ISO 639-1"})
+
+	v.Check(len(movie.ProductionCountries) <= 20, "production_countries", "must not contain more than 20 countries", validator.CodeTooMany, validator.Params{"max": 20})
+	v.Check(validator.Unique(movie.ProductionCountries), "production_countries", "must not contain duplicate values", validator.CodeDuplicate)
+	validateCountries(v, "production_countries", movie.ProductionCountries)
+
+	ValidateCertifications(v, "certifications", movie.Certifications)
+
+	ValidateMovieMetadata(v, "metadata", movie.Metadata)
+
+	v.Check(validator.In(movie.Status, MovieDraft, MovieScheduled, MoviePublished), "status", "must be draft, scheduled or published", validator.CodeNotOneOf, validator.Params{"allowed": []string{MovieDraft, MovieScheduled, MoviePublished}})
+	v.Check(movie.Status != MovieScheduled || movie.PublishAt != nil, "publish_at", "must be provided for scheduled movies", validator.CodeRequired)
+}
+
+// Run validation checks on `MovieSearch` struct
+func ValidateMovieSearch(v *validator.Validator, search MovieSearch) {
+	v.Check(search.Language == "" || validator.Matches(search.Language, LanguageRegex), "language", "must be a lowercase ISO 639-1 language code", validator.CodeInvalidFormat, validator.Params{"format": "ISO 639-1"})
+	validateCountries(v, "countries", search.Countries)
+	ValidateCertifications(v, "certification", search.Certifications)
+	ValidateMovieMetadata(v, "metadata", search.Metadata)
+
+	if !search.ReleasedBefore.IsZero() && !search.ReleasedAfter.IsZero() {
+		v.Check(search.ReleasedAfter.Before(search.ReleasedBefore.Time), "released_after", "must be before released_before", validator.CodeTooLate, validator.Params{"max": search.ReleasedBefore})
+	}
+
+	if !search.CreatedBefore.IsZero() && !search.CreatedAfter.IsZero() {
+		v.Check(search.CreatedAfter.Before(search.CreatedBefore), "created_after", "must be before created_before", validator.CodeTooLate, validator.Params{"max": search.CreatedBefore})
+	}
+}
+
+// Check that every one of the given values is an uppercase ISO 3166-1 alpha-2 country code
+func validateCountries(v *validator.Validator, key string, countries []string) {
+	for _, country := range countries {
+		if !validator.Matches(country, CountryRegex) {
+			v.AddError(key, "must only contain uppercase ISO 3166-1 alpha-2 country codes", validator.CodeInvalidFormat, validator.Params{"format": "ISO 3166-1 alpha-2"})
+			return
+		}
+	}
 }
 
 // Define a MovieModel struct type which wraps a sql.DB connection pool
 type MovieModel struct {
-	DB *sql.DB
+	DB Querier
 }
 
 // Inserts a new record in the `movies` table
-func (m MovieModel) Insert(movie *Movie) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
+func (m MovieModel) Insert(ctx context.Context, movie *Movie) error {
 	query := `
-  	INSERT INTO movies (title, year, runtime, genres) 
-    VALUES ($1, $2, $3, $4)
-    RETURNING id, created_at, version`
+  	INSERT INTO movies (title, year, runtime, genres, synopsis, original_language, production_countries, certifications, metadata, created_by, status, publish_at) 
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+    RETURNING id, created_at, updated_at, version`
 
 	return m.DB.QueryRowContext(
 		ctx,
@@ -61,40 +193,38 @@ func (m MovieModel) Insert(movie *Movie) error {
 		movie.Year,
 		movie.Runtime,
 		pq.Array(movie.Genres),
-	).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+		movie.Synopsis,
+		movie.OriginalLanguage,
+		pq.Array(movie.ProductionCountries),
+		movie.Certifications,
+		movie.Metadata,
+		sql.NullInt64{Int64: movie.CreatedBy, Valid: movie.CreatedBy != 0},
+		movie.Status,
+		movie.PublishAt,
+	).Scan(&movie.ID, &movie.CreatedAt, &movie.UpdatedAt, &movie.Version)
 }
 
 // Fetches a specific record from the `movies` table
-func (m MovieModel) Get(id int64) (*Movie, error) {
+func (m MovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
 	// To avoid making an unnecessary database call, we return an error if received id
 	// is less than 1
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
 	var movie Movie
 
 	query := `
-  	SELECT id, title, year, runtime, genres, version, created_at
+  	SELECT ` + movieColumns + `
     FROM movies
+    LEFT JOIN movie_stats ON movie_stats.movie_id = movies.id
     WHERE id = $1`
 
 	err := m.DB.QueryRowContext(
 		ctx,
 		query,
 		id,
-	).Scan(
-		&movie.ID,
-		&movie.Title,
-		&movie.Year,
-		&movie.Runtime,
-		pq.Array(&movie.Genres),
-		&movie.Version,
-		&movie.CreatedAt,
-	)
+	).Scan(movieFields(&movie)...)
 
 	// If there was no matching movie found, Scan() will return
 	// a sql.ErrNoRows error. We check for this and return our custom ErrRecordNotFound
@@ -111,17 +241,57 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	return &movie, nil
 }
 
+// Fetches the movies with the given IDs, in the same order. IDs which don't match a
+// movie are skipped.
+func (m MovieModel) GetMany(ctx context.Context, ids []int64) ([]*Movie, error) {
+	movies := []*Movie{}
+
+	if len(ids) == 0 {
+		return movies, nil
+	}
+
+	query := `
+  	SELECT ` + movieColumns + `
+    FROM movies
+    LEFT JOIN movie_stats ON movie_stats.movie_id = movies.id
+    WHERE id = ANY($1)
+    ORDER BY array_position($1, id)`
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(movieFields(&movie)...)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
 // Updates a specific record from the `movies` table
 // JSON items with null values will be ignored and will remain unchanged
-func (m MovieModel) Update(movie *Movie) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
+func (m MovieModel) Update(ctx context.Context, movie *Movie) error {
 	query := `
   	UPDATE movies
-		SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-    WHERE id = $5 and version = $6
-		RETURNING version`
+		SET title = $1, year = $2, runtime = $3, genres = $4, synopsis = $5, original_language = $6,
+			production_countries = $7, certifications = $8, metadata = $9, status = $10, publish_at = $11,
+			updated_at = NOW(), version = version + 1
+    WHERE id = $12 and version = $13
+		RETURNING updated_at, version`
 
 	err := m.DB.QueryRowContext(
 		ctx,
@@ -130,9 +300,16 @@ func (m MovieModel) Update(movie *Movie) error {
 		movie.Year,
 		movie.Runtime,
 		pq.Array(movie.Genres),
+		movie.Synopsis,
+		movie.OriginalLanguage,
+		pq.Array(movie.ProductionCountries),
+		movie.Certifications,
+		movie.Metadata,
+		movie.Status,
+		movie.PublishAt,
 		movie.ID,
 		movie.Version,
-	).Scan(&movie.Version)
+	).Scan(&movie.UpdatedAt, &movie.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -146,15 +323,12 @@ func (m MovieModel) Update(movie *Movie) error {
 }
 
 // Deletes a specific record from the `movies` table
-func (m MovieModel) Delete(id int64) error {
+func (m MovieModel) Delete(ctx context.Context, id int64) error {
 	// Return an ErrRecordNotFound error if the movie ID is less than 1
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
 	query := `
 		DELETE FROM movies
 		WHERE id = $1`
@@ -176,32 +350,58 @@ func (m MovieModel) Delete(id int64) error {
 	return nil
 }
 
-// Fetches all movie records from the `movies` table
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// Publishes the scheduled movies whose publish_at time is at or before the given time,
+// returning the movies as they are after being published. The update takes a row lock on
+// each movie, so that two instances running it at once can't both publish (and report)
+// the same movie.
+func (m MovieModel) PublishScheduled(ctx context.Context, now time.Time) ([]*Movie, error) {
+	query := `
+		WITH published AS (
+			UPDATE movies
+			SET status = 'published', updated_at = NOW(), version = version + 1
+			WHERE status = 'scheduled' AND publish_at <= $1
+			RETURNING *
+		)
+		SELECT ` + movieColumns + `
+		FROM published AS movies
+		LEFT JOIN movie_stats ON movie_stats.movie_id = movies.id
+		ORDER BY movies.id`
+
+	rows, err := m.DB.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	movies := []*Movie{}
 
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(movieFields(&movie)...)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// Fetches all movie records from the `movies` table
+func (m MovieModel) GetAll(ctx context.Context, search MovieSearch, filters Filters) ([]*Movie, Metadata, error) {
 	totalRecords := 0
 	movies := []*Movie{}
 
-	// We also include a secondary sort on the movie ID to ensure a
-	// consistent ordering
-	query := fmt.Sprintf(`
-		SELECT COUNT(*) OVER(), id, title, year, runtime, genres, version, created_at
-		FROM movies
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-    AND (genres @> $2 OR $2 = '{}')
-		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+	query, args := getAllMoviesQuery(search, filters)
 
-	rows, err := m.DB.QueryContext(
-		ctx,
-		query,
-		title,
-		pq.Array(genres),
-		filters.limit(),
-		filters.offset(),
-	)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -213,16 +413,7 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	for rows.Next() {
 		var movie Movie
 
-		err := rows.Scan(
-			&totalRecords,
-			&movie.ID,
-			&movie.Title,
-			&movie.Year,
-			&movie.Runtime,
-			pq.Array(&movie.Genres),
-			&movie.Version,
-			&movie.CreatedAt,
-		)
+		err := rows.Scan(append([]interface{}{&totalRecords}, movieFields(&movie)...)...)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
@@ -241,3 +432,271 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 
 	return movies, metadata, nil
 }
+
+// Build the query and arguments for GetAll(). The search conditions are only included
+// when they are actually being filtered on. Writing them so that an empty value matches
+// everything (as in "OR $1 = <empty string>") would stop PostgreSQL from using the
+// indexes on the searched columns, as a prepared statement's generic plan has to work
+// for the empty value too.
+func getAllMoviesQuery(search MovieSearch, filters Filters) (string, []interface{}) {
+	where, args := movieSearchConditions(search)
+
+	args = append(args, filters.limit(), filters.offset())
+
+	// We also include a secondary sort on the movie ID to ensure a
+	// consistent ordering
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), %s
+		FROM movies
+		LEFT JOIN movie_stats ON movie_stats.movie_id = movies.id
+		%s
+		ORDER BY %s %s, id ASC
+		LIMIT $%d OFFSET $%d`, movieColumns, where, filters.sortColumn(), filters.sortDirection(), len(args)-1, len(args))
+
+	return query, args
+}
+
+// Return the WHERE clause (empty if nothing is searched on) and arguments matching the
+// movies of a search
+func movieSearchConditions(search MovieSearch) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if search.Title != "" {
+		args = append(args, search.Title)
+		conditions = append(conditions, fmt.Sprintf("title_tsv @@ plainto_tsquery('simple', $%d)", len(args)))
+	}
+
+	if search.Synopsis != "" {
+		args = append(args, search.Synopsis)
+		conditions = append(conditions, fmt.Sprintf("synopsis_tsv @@ plainto_tsquery('english', $%d)", len(args)))
+	}
+
+	if len(search.Genres) > 0 {
+		args = append(args, pq.Array(search.Genres))
+		conditions = append(conditions, fmt.Sprintf("genres @> $%d", len(args)))
+	}
+
+	if len(search.AnyGenres) > 0 {
+		args = append(args, pq.Array(search.AnyGenres))
+		conditions = append(conditions, fmt.Sprintf("genres && $%d", len(args)))
+	}
+
+	if search.Language != "" {
+		args = append(args, search.Language)
+		conditions = append(conditions, fmt.Sprintf("original_language = $%d", len(args)))
+	}
+
+	if len(search.Countries) > 0 {
+		args = append(args, pq.Array(search.Countries))
+		conditions = append(conditions, fmt.Sprintf("production_countries @> $%d", len(args)))
+	}
+
+	if len(search.Certifications) > 0 {
+		args = append(args, search.Certifications)
+		conditions = append(conditions, fmt.Sprintf("certifications @> $%d", len(args)))
+	}
+
+	if len(search.Metadata) > 0 {
+		args = append(args, search.Metadata)
+		conditions = append(conditions, fmt.Sprintf("metadata @> $%d", len(args)))
+	}
+
+	if search.CreatedBy != 0 {
+		args = append(args, search.CreatedBy)
+		conditions = append(conditions, fmt.Sprintf("movies.created_by = $%d", len(args)))
+	}
+
+	if search.PublishedOnly {
+		conditions = append(conditions, "movies.status = 'published'")
+	}
+
+	// Both release dates apply to the same release, so that a movie released before one
+	// date in one country and after the other in another country doesn't match
+	if !search.ReleasedBefore.IsZero() || !search.ReleasedAfter.IsZero() {
+		releaseConditions := []string{"movie_releases.movie_id = movies.id"}
+
+		if !search.ReleasedBefore.IsZero() {
+			args = append(args, search.ReleasedBefore)
+			releaseConditions = append(releaseConditions, fmt.Sprintf("movie_releases.release_date < $%d", len(args)))
+		}
+
+		if !search.ReleasedAfter.IsZero() {
+			args = append(args, search.ReleasedAfter)
+			releaseConditions = append(releaseConditions, fmt.Sprintf("movie_releases.release_date > $%d", len(args)))
+		}
+
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM movie_releases WHERE "+strings.Join(releaseConditions, " AND ")+")")
+	}
+
+	if !search.CreatedBefore.IsZero() {
+		args = append(args, search.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("movies.created_at < $%d", len(args)))
+	}
+
+	if !search.CreatedAfter.IsZero() {
+		args = append(args, search.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("movies.created_at > $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	return where, args
+}
+
+// Define a MovieTimestamp struct holding when a movie was last updated, for listing
+// every movie cheaply, as in sitemaps
+type MovieTimestamp struct {
+	ID        int64
+	UpdatedAt time.Time
+}
+
+// Fetches a page of the IDs and update times of all published movies, ordered by ID,
+// along with the total number of published movies
+func (m MovieModel) GetTimestamps(ctx context.Context, offset, limit int) ([]*MovieTimestamp, int, error) {
+	query := `
+		SELECT COUNT(*) OVER(), id, updated_at
+		FROM movies
+		WHERE status = 'published'
+		ORDER BY id
+		LIMIT $1 OFFSET $2`
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	timestamps := []*MovieTimestamp{}
+
+	for rows.Next() {
+		var timestamp MovieTimestamp
+
+		err := rows.Scan(&totalRecords, &timestamp.ID, &timestamp.UpdatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		timestamps = append(timestamps, &timestamp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return timestamps, totalRecords, nil
+}
+
+// Define a MovieSuggestion struct holding the few fields of a movie shown when
+// suggesting titles as the user types
+type MovieSuggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year,omitempty"`
+}
+
+// Fetches up to limit published movies whose title contains the query (ignoring case) or
+// has words similar to it, which catches typos. Titles starting with the query come
+// first, then the closest matches. Both conditions can use the trigram index on the title.
+func (m MovieModel) Autocomplete(ctx context.Context, query string, limit int) ([]*MovieSuggestion, error) {
+	stmt := `
+		SELECT id, title, year
+		FROM movies
+		WHERE (title ILIKE '%' || $1 || '%' OR $2 <% title) AND status = 'published'
+		ORDER BY title ILIKE $1 || '%' DESC, word_similarity($2, title) DESC, title, id
+		LIMIT $3`
+
+	// Escape the LIKE wildcards, so that the query is matched literally
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query)
+
+	rows, err := m.DB.QueryContext(ctx, stmt, escaped, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	suggestions := []*MovieSuggestion{}
+
+	for rows.Next() {
+		var suggestion MovieSuggestion
+
+		err := rows.Scan(&suggestion.ID, &suggestion.Title, &suggestion.Year)
+		if err != nil {
+			return nil, err
+		}
+
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// Counts the movies matching a search by genre, year, runtime range and certification,
+// with a grouping query per facet over the matching movies. Decades are counted from
+// the years.
+func (m MovieModel) Facets(ctx context.Context, search MovieSearch) (*MovieFacets, error) {
+	where, args := movieSearchConditions(search)
+
+	query := fmt.Sprintf(`
+		WITH matches AS (
+			SELECT movies.genres, movies.year, movies.runtime, movies.certifications
+			FROM movies
+			%s
+		)
+		SELECT 'genre', genre, COUNT(*) FROM matches, unnest(genres) AS genre GROUP BY genre
+		UNION ALL
+		SELECT 'year', year::text, COUNT(*) FROM matches WHERE year > 0 GROUP BY year
+		UNION ALL
+		SELECT 'runtime', (runtime - runtime %% %d)::text, COUNT(*) FROM matches WHERE runtime > 0 GROUP BY 2
+		UNION ALL
+		SELECT 'certification', key || ':' || value, COUNT(*) FROM matches, jsonb_each_text(certifications) GROUP BY key, value`,
+		where, RuntimeRangeWidth)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	facets := NewMovieFacets()
+
+	for rows.Next() {
+		var facet, value string
+		var count int
+
+		err := rows.Scan(&facet, &value, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		switch facet {
+		case "genre":
+			facets.Genres[value] = count
+		case "year":
+			year, _ := strconv.Atoi(value)
+			facets.AddYear(year, count)
+		case "runtime":
+			minutes, _ := strconv.Atoi(value)
+			facets.AddRuntime(minutes, count)
+		case "certification":
+			facets.Certifications[value] = count
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
@@ -0,0 +1,206 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/data/datatest"
+)
+
+func TestMovieModelInsertAndGet(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	movie := &data.Movie{
+		Title:               "Moana",
+		Year:                2016,
+		Runtime:             107,
+		Genres:              []string{"animation", "adventure"},
+		OriginalLanguage:    "en",
+		ProductionCountries: []string{"US"},
+		Status:              data.MoviePublished,
+	}
+
+	err := models.Movie.Insert(ctx, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if movie.ID == 0 || movie.Version != 1 {
+		t.Fatalf("got ID %d and version %d after inserting; want a new ID and version 1", movie.ID, movie.Version)
+	}
+
+	got, err := models.Movie.Get(ctx, movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != movie.Title || got.Year != movie.Year || got.Runtime != movie.Runtime {
+		t.Errorf("got %q (%d, %d mins); want %q (%d, %d mins)", got.Title, got.Year, got.Runtime, movie.Title, movie.Year, movie.Runtime)
+	}
+
+	if len(got.Genres) != 2 || got.Genres[0] != "animation" || got.Genres[1] != "adventure" {
+		t.Errorf("got genres %v; want %v", got.Genres, movie.Genres)
+	}
+
+	_, err = models.Movie.Get(ctx, movie.ID+1)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("got error %v for a missing movie; want ErrRecordNotFound", err)
+	}
+}
+
+func TestMovieModelUpdate(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	movie := datatest.InsertMovie(t, models, "Black Panther")
+
+	movie.Title = "Black Panther: Wakanda Forever"
+
+	err := models.Movie.Update(ctx, movie)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if movie.Version != 2 {
+		t.Errorf("got version %d after updating; want 2", movie.Version)
+	}
+
+	got, err := models.Movie.Get(ctx, movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != movie.Title {
+		t.Errorf("got title %q; want %q", got.Title, movie.Title)
+	}
+
+	// Updating from the version read before the update must fail
+	stale := *movie
+	stale.Version = 1
+
+	err = models.Movie.Update(ctx, &stale)
+	if !errors.Is(err, data.ErrEditConflict) {
+		t.Errorf("got error %v updating a stale version; want ErrEditConflict", err)
+	}
+}
+
+// Of several concurrent updates to the same version of a movie, exactly one must succeed
+// and the others must be reported as edit conflicts
+func TestMovieModelConcurrentUpdates(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	movie := datatest.InsertMovie(t, models, "Deadpool")
+
+	errs := datatest.Concurrently(10, func(i int) error {
+		update := *movie
+		update.Runtime = data.Runtime(100 + i)
+
+		return models.Movie.Update(ctx, &update)
+	})
+
+	var succeeded int
+
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, data.ErrEditConflict):
+			t.Errorf("got unexpected error %v", err)
+		}
+	}
+
+	if succeeded != 1 {
+		t.Errorf("%d concurrent updates succeeded; want 1", succeeded)
+	}
+
+	got, err := models.Movie.Get(ctx, movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Version != 2 {
+		t.Errorf("got version %d; want 2", got.Version)
+	}
+}
+
+func TestMovieModelDelete(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	movie := datatest.InsertMovie(t, models, "The Breakfast Club")
+
+	err := models.Movie.Delete(ctx, movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = models.Movie.Get(ctx, movie.ID)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("got error %v for a deleted movie; want ErrRecordNotFound", err)
+	}
+
+	err = models.Movie.Delete(ctx, movie.ID)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("got error %v deleting a deleted movie; want ErrRecordNotFound", err)
+	}
+}
+
+func TestMovieModelGetAll(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	datatest.InsertMovie(t, models, "Black Panther")
+	datatest.InsertMovie(t, models, "Avengers: Endgame")
+
+	comedy := &data.Movie{Title: "Monty Python and the Holy Grail", Year: 1975, Runtime: 91, Genres: []string{"comedy"}, Status: data.MoviePublished}
+
+	err := models.Movie.Insert(ctx, comedy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}}
+
+	tests := []struct {
+		name   string
+		search data.MovieSearch
+		want   []string
+	}{
+		{name: "everything", search: data.MovieSearch{}, want: []string{"Black Panther", "Avengers: Endgame", comedy.Title}},
+		{name: "title", search: data.MovieSearch{Title: "panther"}, want: []string{"Black Panther"}},
+		{name: "genre", search: data.MovieSearch{Genres: []string{"comedy"}}, want: []string{comedy.Title}},
+		{name: "no match", search: data.MovieSearch{Genres: []string{"horror"}}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movies, metadata, err := models.Movie.GetAll(ctx, tt.search, filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var titles []string
+			for _, movie := range movies {
+				titles = append(titles, movie.Title)
+			}
+
+			if len(titles) != len(tt.want) {
+				t.Fatalf("got %v; want %v", titles, tt.want)
+			}
+
+			for i := range titles {
+				if titles[i] != tt.want[i] {
+					t.Fatalf("got %v; want %v", titles, tt.want)
+				}
+			}
+
+			if metadata.TotalRecords != len(tt.want) {
+				t.Errorf("got %d total records; want %d", metadata.TotalRecords, len(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// Define the kinds of notification which can be sent to users
+const (
+	NotificationAccountActivated = "account_activated"
+	NotificationPasswordReset    = "password_reset"
+	NotificationMoviePublished   = "movie_published"
+	NotificationNewSignIn        = "new_sign_in"
+)
+
+// Define a Notification struct to represent a notification delivered to a user's
+// in-app inbox
+type Notification struct {
+	ID        int64                  `json:"id"`
+	CreatedAt time.Time              `json:"created_at"`
+	UserID    int64                  `json:"-"`
+	Kind      string                 `json:"kind"`
+	Title     string                 `json:"title"`
+	Body      string                 `json:"body"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	ReadAt    *time.Time             `json:"read_at"`
+}
+
+// Define the channels a user wants to receive a kind of notification through
+type ChannelPreferences struct {
+	Email bool `json:"email"`
+	InApp bool `json:"in_app"`
+}
+
+// Define a NotificationPreferences map holding the channel preferences for each kind of
+// notification
+type NotificationPreferences map[string]ChannelPreferences
+
+// The preferences used for users who haven't changed them. Every kind of notification
+// must have an entry here.
+var DefaultNotificationPreferences = NotificationPreferences{
+	NotificationAccountActivated: {Email: false, InApp: true},
+	NotificationPasswordReset:    {Email: true, InApp: true},
+	NotificationMoviePublished:   {Email: false, InApp: true},
+	NotificationNewSignIn:        {Email: true, InApp: true},
+}
+
+// Run validation checks on preferences sent by a client
+func ValidateNotificationPreferences(v *validator.Validator, preferences NotificationPreferences) {
+	v.Check(len(preferences) > 0, "preferences", "must be provided", validator.CodeRequired)
+
+	for kind := range preferences {
+		_, known := DefaultNotificationPreferences[kind]
+		v.Check(known, kind, "unknown notification kind", validator.CodeNotAllowed)
+	}
+}
+
+// Define the NotificationModel type
+type NotificationModel struct {
+	DB Querier
+}
+
+// Inserts a new notification in the `notifications` table
+func (m NotificationModel) Insert(ctx context.Context, notification *Notification) error {
+	data, err := json.Marshal(notification.Data)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO notifications (user_id, kind, title, body, data)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	return m.DB.QueryRowContext(
+		ctx,
+		query,
+		notification.UserID,
+		notification.Kind,
+		notification.Title,
+		notification.Body,
+		data,
+	).Scan(&notification.ID, &notification.CreatedAt)
+}
+
+// Fetches a page of a user's notifications, newest first. If unreadOnly is set, only
+// the notifications which haven't been marked as read are returned.
+func (m NotificationModel) GetAllForUser(ctx context.Context, userID int64, unreadOnly bool, filters Filters) ([]*Notification, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), id, created_at, user_id, kind, title, body, data, read_at
+		FROM notifications
+		WHERE user_id = $1
+		AND (read_at IS NULL OR NOT $2)
+		AND ` + filters.keysetCondition("id", 5) + `
+		ORDER BY id DESC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, unreadOnly, filters.limit(), filters.offset(), filters.After)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	notifications := []*Notification{}
+
+	for rows.Next() {
+		var notification Notification
+		var data []byte
+
+		err := rows.Scan(
+			&totalRecords,
+			&notification.ID,
+			&notification.CreatedAt,
+			&notification.UserID,
+			&notification.Kind,
+			&notification.Title,
+			&notification.Body,
+			&data,
+			&notification.ReadAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		err = json.Unmarshal(data, &notification.Data)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		notifications = append(notifications, &notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	var lastID int64
+	if len(notifications) > 0 {
+		lastID = notifications[len(notifications)-1].ID
+	}
+
+	return notifications, filters.metadata(totalRecords, len(notifications), lastID), nil
+}
+
+// Marks one of a user's notifications as read. Notifications which have already been
+// read keep their original read time.
+func (m NotificationModel) MarkRead(ctx context.Context, userID, id int64) error {
+	query := `
+		UPDATE notifications
+		SET read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND user_id = $2`
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Deletes up to limit notifications which were read before the given time, returning
+// how many were deleted. Unread notifications are kept however old they are.
+func (m NotificationModel) DeleteRead(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM notifications
+		WHERE id IN (SELECT id FROM notifications WHERE read_at < $1 LIMIT $2)`
+
+	result, err := m.DB.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Returns a user's preferences for every kind of notification, using the defaults for
+// the kinds they haven't changed
+func (m NotificationModel) GetPreferences(ctx context.Context, userID int64) (NotificationPreferences, error) {
+	query := `
+		SELECT kind, email, in_app
+		FROM notification_preferences
+		WHERE user_id = $1`
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	preferences := make(NotificationPreferences, len(DefaultNotificationPreferences))
+	for kind, channels := range DefaultNotificationPreferences {
+		preferences[kind] = channels
+	}
+
+	for rows.Next() {
+		var kind string
+		var channels ChannelPreferences
+
+		err := rows.Scan(&kind, &channels.Email, &channels.InApp)
+		if err != nil {
+			return nil, err
+		}
+
+		// Ignore preferences for kinds of notification which no longer exist
+		if _, known := DefaultNotificationPreferences[kind]; known {
+			preferences[kind] = channels
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+// Saves a user's preferences for the given kinds of notification, leaving the others
+// unchanged
+func (m NotificationModel) SetPreferences(ctx context.Context, userID int64, preferences NotificationPreferences) error {
+	query := `
+		INSERT INTO notification_preferences (user_id, kind, email, in_app)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, kind) DO UPDATE
+		SET email = EXCLUDED.email, in_app = EXCLUDED.in_app`
+
+	for kind, channels := range preferences {
+		_, err := m.DB.ExecContext(ctx, query, userID, kind, channels.Email, channels.InApp)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Define the statuses of a schema operation. Queued operations are waiting for a job
+// worker, and running ones have been worked on. Only one operation with a given name
+// can be queued or running at a time.
+const (
+	OperationQueued    = "queued"
+	OperationRunning   = "running"
+	OperationCompleted = "completed"
+	OperationFailed    = "failed"
+)
+
+var ErrDuplicateOperation = errors.New("duplicate operation")
+
+// Define an Operation struct to represent a run of an online schema change, such as
+// building an index concurrently or backfilling a column in batches. For backfills,
+// Progress is the last ID of the table which has been backfilled, out of Total.
+type Operation struct {
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Progress    int64      `json:"progress"`
+	Total       int64      `json:"total"`
+	RowsUpdated int64      `json:"rows_updated"`
+	Error       string     `json:"error,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// Define an OperationModel struct type which wraps a sql.DB connection pool
+type OperationModel struct {
+	DB Querier
+}
+
+// The columns read into an operation, in the order of operationFields()
+const operationColumns = `id, created_at, name, status, progress, total, rows_updated, error, updated_at, finished_at`
+
+// Return the destinations for scanning the operationColumns into an operation
+func operationFields(operation *Operation) []interface{} {
+	return []interface{}{
+		&operation.ID,
+		&operation.CreatedAt,
+		&operation.Name,
+		&operation.Status,
+		&operation.Progress,
+		&operation.Total,
+		&operation.RowsUpdated,
+		&operation.Error,
+		&operation.UpdatedAt,
+		&operation.FinishedAt,
+	}
+}
+
+// Records a new queued operation. It returns ErrDuplicateOperation if an operation with
+// the same name is already queued or running.
+func (m OperationModel) Insert(ctx context.Context, operation *Operation) error {
+	query := `
+		INSERT INTO schema_operations (name)
+		VALUES ($1)
+		RETURNING id, created_at, status, updated_at`
+
+	err := m.DB.QueryRowContext(ctx, query, operation.Name).Scan(&operation.ID, &operation.CreatedAt, &operation.Status, &operation.UpdatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "schema_operations_active_name_idx"`:
+			return ErrDuplicateOperation
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fetches an operation
+func (m OperationModel) Get(ctx context.Context, id int64) (*Operation, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + operationColumns + `
+		FROM schema_operations
+		WHERE id = $1`
+
+	var operation Operation
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(operationFields(&operation)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &operation, nil
+}
+
+// Fetches a page of the operations
+func (m OperationModel) GetAll(ctx context.Context, filters Filters) ([]*Operation, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), %s
+		FROM schema_operations
+		ORDER BY %s %s, id ASC
+		LIMIT $1 OFFSET $2`, operationColumns, filters.sortColumn(), filters.sortDirection())
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	operations := []*Operation{}
+
+	for rows.Next() {
+		var operation Operation
+
+		err := rows.Scan(append([]interface{}{&totalRecords}, operationFields(&operation)...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		operations = append(operations, &operation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return operations, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Records the progress of an operation, which is then running
+func (m OperationModel) UpdateProgress(ctx context.Context, operation *Operation) error {
+	operation.Status = OperationRunning
+
+	query := `
+		UPDATE schema_operations
+		SET status = $1, progress = $2, total = $3, rows_updated = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING updated_at`
+
+	args := []interface{}{operation.Status, operation.Progress, operation.Total, operation.RowsUpdated, operation.ID}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&operation.UpdatedAt)
+}
+
+// Records the outcome of an operation, which is failed if it has an error and completed
+// otherwise
+func (m OperationModel) Finish(ctx context.Context, operation *Operation) error {
+	operation.Status = OperationCompleted
+	if operation.Error != "" {
+		operation.Status = OperationFailed
+	}
+
+	query := `
+		UPDATE schema_operations
+		SET status = $1, progress = $2, total = $3, rows_updated = $4, error = $5,
+			updated_at = NOW(), finished_at = NOW()
+		WHERE id = $6
+		RETURNING updated_at, finished_at`
+
+	args := []interface{}{operation.Status, operation.Progress, operation.Total, operation.RowsUpdated, operation.Error, operation.ID}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&operation.UpdatedAt, &operation.FinishedAt)
+}
@@ -2,21 +2,33 @@ package data
 
 import (
 	"context"
-	"database/sql"
-	"time"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
 	"github.com/lib/pq"
 )
 
+// Permission codes are made of lowercase segments separated by colons, going from the
+// broadest to the most specific (like "movies:reviews:write"). A code whose last segment
+// is "*" is a wildcard, granting every code below its other segments, and "*" on its own
+// grants every code.
+var PermissionCodeRegex = regexp.MustCompile(`^(\*|[a-z][a-z_]*(:[a-z][a-z_]*)*(:\*)?)$`)
+
+// We'll return this from AddForUser() when one of the codes isn't a valid permission code
+var ErrInvalidPermissionCode = errors.New("invalid permission code")
+
 // Define a Permissions slice, which we will use to hold the permission codes (like
 // "movies:read" and "movies:write") for a single user
 type Permissions []string
 
-// Add a helper method to check whether the Permissions slice contains a specific
-// permission code
+// Add a helper method to check whether the Permissions slice grants a specific
+// permission code, either holding the code itself or a wildcard covering it
 func (p Permissions) Include(code string) bool {
 	for i := range p {
-		if code == p[i] {
+		if code == p[i] || wildcardCovers(p[i], code) {
 			return true
 		}
 	}
@@ -24,17 +36,116 @@ func (p Permissions) Include(code string) bool {
 	return false
 }
 
+// Report whether the wildcard permission code covers the given code. A wildcard covers
+// the codes with at least one more segment than the segments before its "*", including
+// narrower wildcards, so "movies:*" covers "movies:write" and "movies:reviews:*" but not
+// "movies".
+func wildcardCovers(wildcard, code string) bool {
+	if wildcard == "*" {
+		return true
+	}
+
+	prefix := strings.TrimSuffix(wildcard, "*")
+	if prefix == wildcard {
+		return false
+	}
+
+	return strings.HasPrefix(code, prefix) && len(code) > len(prefix)
+}
+
+// Return the codes which would grant the given code: the code itself and the wildcards
+// above it, from the narrowest to "*"
+func grantingCodes(code string) []string {
+	codes := []string{code}
+	if code == "*" {
+		return codes
+	}
+
+	// The wildcards above a code are built from the segments before its last one
+	segments := strings.Split(strings.TrimSuffix(code, ":*"), ":")
+
+	for i := len(segments) - 1; i > 0; i-- {
+		codes = append(codes, strings.Join(segments[:i], ":")+":*")
+	}
+
+	return append(codes, "*")
+}
+
+// Check that the given permission codes are all valid, returning ErrInvalidPermissionCode
+// for the first which isn't
+func validatePermissionCodes(codes []string) error {
+	for _, code := range codes {
+		if !validator.Matches(code, PermissionCodeRegex) {
+			return fmt.Errorf("%w: %q", ErrInvalidPermissionCode, code)
+		}
+	}
+
+	return nil
+}
+
+// Check whether the Permissions slice contains all of the given permission codes
+func (p Permissions) IncludeAll(codes ...string) bool {
+	for _, code := range codes {
+		if !p.Include(code) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Return the distinct codes of the given permission codes, in their original order
+func uniqueCodes(codes []string) []string {
+	unique := make([]string, 0, len(codes))
+
+	for _, code := range codes {
+		if !validator.In(code, unique...) {
+			unique = append(unique, code)
+		}
+	}
+
+	return unique
+}
+
+// Check that the requested permissions are some of those held by the user, reporting
+// any problem under the given key
+func ValidatePermissionSubset(v *validator.Validator, key string, requested, held Permissions) {
+	v.Check(len(requested) > 0, key, "must contain at least one permission", validator.CodeTooFew, validator.Params{"min": 1})
+	v.Check(validator.Unique(requested), key, "must not contain duplicate values", validator.CodeDuplicate)
+
+	for _, code := range requested {
+		if !validator.Matches(code, PermissionCodeRegex) {
+			v.AddError(key, fmt.Sprintf("must only contain valid permission codes (%q isn't one)", code), validator.CodeInvalid, validator.Params{"value": code})
+			break
+		}
+
+		if !held.Include(code) {
+			v.AddError(key, fmt.Sprintf("must only contain permissions you hold (%q isn't one of them)", code), validator.CodeNotAllowed, validator.Params{"value": code})
+			break
+		}
+	}
+}
+
+// Check that the requested permissions are all allowed by the token the user
+// authenticated with, which may be restricted to some of the user's permissions,
+// reporting any problem under the given key
+func ValidateTokenAllows(v *validator.Validator, key string, requested Permissions, user *User) {
+	for _, code := range requested {
+		if !user.TokenAllows(code) {
+			v.AddError(key, fmt.Sprintf("must only contain permissions your token allows (%q isn't one of them)", code), validator.CodeNotAllowed, validator.Params{"value": code})
+			break
+		}
+	}
+}
+
 // Define the PermissionModel type
 type PermissionModel struct {
-	DB *sql.DB
+	DB Querier
 }
 
 // This method returns all permission codes for a specific user in a
 // Permissions slice
-func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
+func (m PermissionModel) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
 	query := `
         SELECT permissions.code
         FROM permissions
@@ -69,15 +180,82 @@ func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 	return permissions, nil
 }
 
-// Add the provided permission codes for a specific user
-func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// Add the provided permission codes for a specific user. Codes which aren't valid
+// permission codes are rejected with ErrInvalidPermissionCode.
+func (m PermissionModel) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	err := validatePermissionCodes(codes)
+	if err != nil {
+		return err
+	}
 
 	query := `
         INSERT INTO users_permissions
         SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)`
 
+	_, err = m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
+
+	return err
+}
+
+// Report whether a specific user holds all of the provided permission codes, either
+// directly or through a wildcard. A single query fetches the codes the user holds out of
+// those which could grant the provided ones. A user holds every permission of an empty
+// list.
+func (m PermissionModel) HasPermission(ctx context.Context, userID int64, codes ...string) (bool, error) {
+	codes = uniqueCodes(codes)
+	if len(codes) == 0 {
+		return true, nil
+	}
+
+	var candidates []string
+
+	for _, code := range codes {
+		candidates = append(candidates, grantingCodes(code)...)
+	}
+
+	query := `
+        SELECT permissions.code
+        FROM permissions
+        INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+        WHERE users_permissions.user_id = $1 AND permissions.code = ANY($2)`
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, pq.Array(uniqueCodes(candidates)))
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+
+	var held Permissions
+
+	for rows.Next() {
+		var code string
+
+		err := rows.Scan(&code)
+		if err != nil {
+			return false, err
+		}
+
+		held = append(held, code)
+	}
+
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+
+	return held.IncludeAll(codes...), nil
+}
+
+// Remove the provided permission codes from a specific user. Codes the user doesn't
+// hold are ignored.
+func (m PermissionModel) RemoveForUser(ctx context.Context, userID int64, codes ...string) error {
+	query := `
+        DELETE FROM users_permissions
+        USING permissions
+        WHERE users_permissions.permission_id = permissions.id
+        AND users_permissions.user_id = $1
+        AND permissions.code = ANY($2)`
+
 	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
 
 	return err
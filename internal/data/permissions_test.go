@@ -0,0 +1,97 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/data/datatest"
+)
+
+func TestPermissionModelAddAndRemove(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	user := datatest.InsertUser(t, models, "alice@example.com", "pa55word1234")
+
+	err := models.Permissions.AddForUser(ctx, user.ID, "movies:read", "movies:write")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	permissions, err := models.Permissions.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(permissions)
+
+	if len(permissions) != 2 || permissions[0] != "movies:read" || permissions[1] != "movies:write" {
+		t.Errorf("got permissions %v; want [movies:read movies:write]", permissions)
+	}
+
+	err = models.Permissions.RemoveForUser(ctx, user.ID, "movies:write", "admin:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	permissions, err = models.Permissions.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(permissions) != 1 || permissions[0] != "movies:read" {
+		t.Errorf("got permissions %v after removing movies:write; want [movies:read]", permissions)
+	}
+
+	err = models.Permissions.AddForUser(ctx, user.ID, "Movies:Read")
+	if !errors.Is(err, data.ErrInvalidPermissionCode) {
+		t.Errorf("got error %v adding an invalid code; want ErrInvalidPermissionCode", err)
+	}
+}
+
+func TestPermissionModelHasPermission(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	reader := datatest.InsertUser(t, models, "reader@example.com", "pa55word1234")
+	editor := datatest.InsertUser(t, models, "editor@example.com", "pa55word1234")
+	admin := datatest.InsertUser(t, models, "admin@example.com", "pa55word1234")
+
+	for userID, code := range map[int64]string{reader.ID: "movies:read", editor.ID: "movies:*", admin.ID: "*"} {
+		err := models.Permissions.AddForUser(ctx, userID, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		userID int64
+		codes  []string
+		want   bool
+	}{
+		{name: "held code", userID: reader.ID, codes: []string{"movies:read"}, want: true},
+		{name: "missing code", userID: reader.ID, codes: []string{"movies:write"}, want: false},
+		{name: "some missing", userID: reader.ID, codes: []string{"movies:read", "movies:write"}, want: false},
+		{name: "no codes", userID: reader.ID, codes: nil, want: true},
+		{name: "wildcard", userID: editor.ID, codes: []string{"movies:read", "movies:write"}, want: true},
+		{name: "outside wildcard", userID: editor.ID, codes: []string{"admin:read"}, want: false},
+		{name: "global wildcard", userID: admin.ID, codes: []string{"admin:read", "movies:write"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := models.Permissions.HasPermission(ctx, tt.userID, tt.codes...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// We'll return this when a movie would have two releases of the same type in the same
+// country
+var ErrDuplicateRelease = errors.New("duplicate release")
+
+// Define the supported types of release
+var ReleaseTypes = []string{"premiere", "theatrical", "limited_theatrical", "streaming", "digital", "physical", "tv"}
+
+// Define a MovieRelease struct to represent the date on which a movie was (or will be)
+// released in a country, such as its theatrical release in Portugal
+type MovieRelease struct {
+	ID          int64  `json:"id"`
+	MovieID     int64  `json:"movie_id"`
+	Country     string `json:"country"` // ISO 3166-1 alpha-2 country code
+	Type        string `json:"type"`
+	ReleaseDate Date   `json:"release_date"`
+	Note        string `json:"note,omitempty"`
+	Version     int32  `json:"version"`
+}
+
+// Run validation checks on `MovieRelease` struct
+func ValidateMovieRelease(v *validator.Validator, release *MovieRelease) {
+	v.Check(release.Country != "", "country", "must be provided", validator.CodeRequired)
+	v.Check(validator.Matches(release.Country, CountryRegex), "country", "must be an uppercase ISO 3166-1 alpha-2 country code", validator.CodeInvalidFormat, validator.Params{"format": "ISO 3166-1 alpha-2"})
+
+	v.Check(release.Type != "", "type", "must be provided", validator.CodeRequired)
+	v.Check(validator.In(release.Type, ReleaseTypes...), "type", "invalid release type", validator.CodeNotOneOf, validator.Params{"allowed": ReleaseTypes})
+
+	v.Check(!release.ReleaseDate.IsZero(), "release_date", "must be provided", validator.CodeRequired)
+	v.Check(release.ReleaseDate.Year() >= 1888, "release_date", "must not be before 1888", validator.CodeTooEarly, validator.Params{"min": "1888-01-01"})
+
+	v.Check(len(release.Note) <= 500, "note", "must not be more than 500 bytes long", validator.CodeTooLong, validator.Params{"max": 500})
+}
+
+// Define a MovieReleaseModel struct type which wraps a sql.DB connection pool
+type MovieReleaseModel struct {
+	DB Querier
+}
+
+// Convert the constraint violations caused by a release into our errors
+func movieReleaseError(err error) error {
+	switch {
+	case err.Error() == `pq: insert or update on table "movie_releases" violates foreign key constraint "movie_releases_movie_id_fkey"`:
+		return ErrRecordNotFound
+	case err.Error() == `pq: duplicate key value violates unique constraint "movie_releases_movie_id_country_type_key"`:
+		return ErrDuplicateRelease
+	default:
+		return err
+	}
+}
+
+// Inserts a new release for a movie. It returns ErrRecordNotFound if the movie doesn't
+// exist.
+func (m MovieReleaseModel) Insert(ctx context.Context, release *MovieRelease) error {
+	query := `
+		INSERT INTO movie_releases (movie_id, country, type, release_date, note)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, version`
+
+	err := m.DB.QueryRowContext(
+		ctx,
+		query,
+		release.MovieID,
+		release.Country,
+		release.Type,
+		release.ReleaseDate,
+		release.Note,
+	).Scan(&release.ID, &release.Version)
+	if err != nil {
+		return movieReleaseError(err)
+	}
+
+	return nil
+}
+
+// Fetches a specific release of a movie
+func (m MovieReleaseModel) Get(ctx context.Context, movieID, id int64) (*MovieRelease, error) {
+	query := `
+		SELECT id, movie_id, country, type, release_date, note, version
+		FROM movie_releases
+		WHERE id = $1 AND movie_id = $2`
+
+	var release MovieRelease
+
+	err := m.DB.QueryRowContext(ctx, query, id, movieID).Scan(
+		&release.ID,
+		&release.MovieID,
+		&release.Country,
+		&release.Type,
+		&release.ReleaseDate,
+		&release.Note,
+		&release.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &release, nil
+}
+
+// Fetches all of a movie's releases, earliest first
+func (m MovieReleaseModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieRelease, error) {
+	query := `
+		SELECT id, movie_id, country, type, release_date, note, version
+		FROM movie_releases
+		WHERE movie_id = $1
+		ORDER BY release_date, country, type`
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	releases := []*MovieRelease{}
+
+	for rows.Next() {
+		var release MovieRelease
+
+		err := rows.Scan(
+			&release.ID,
+			&release.MovieID,
+			&release.Country,
+			&release.Type,
+			&release.ReleaseDate,
+			&release.Note,
+			&release.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		releases = append(releases, &release)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+// Updates a specific release of a movie
+func (m MovieReleaseModel) Update(ctx context.Context, release *MovieRelease) error {
+	query := `
+		UPDATE movie_releases
+		SET country = $1, type = $2, release_date = $3, note = $4, version = version + 1
+		WHERE id = $5 AND movie_id = $6 AND version = $7
+		RETURNING version`
+
+	err := m.DB.QueryRowContext(
+		ctx,
+		query,
+		release.Country,
+		release.Type,
+		release.ReleaseDate,
+		release.Note,
+		release.ID,
+		release.MovieID,
+		release.Version,
+	).Scan(&release.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return movieReleaseError(err)
+		}
+	}
+
+	return nil
+}
+
+// Deletes a specific release of a movie
+func (m MovieReleaseModel) Delete(ctx context.Context, movieID, id int64) error {
+	query := `
+		DELETE FROM movie_releases
+		WHERE id = $1 AND movie_id = $2`
+
+	result, err := m.DB.ExecContext(ctx, query, id, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
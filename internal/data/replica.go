@@ -0,0 +1,75 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// Define a replicaContextKey type so that the key can't collide with keys from other
+// packages
+type replicaContextKey struct{}
+
+// Return a copy of the context whose read-only queries may be served by a read
+// replica, which can lag a little behind the primary. Queries run with any other
+// context go to the primary, so only the callers which can do with slightly stale data
+// (such as requests which didn't just write) opt in.
+func WithReplicaReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaContextKey{}, true)
+}
+
+// Return a copy of the context whose queries all go to the primary, even if the
+// context was allowed to read from a replica
+func WithoutReplicaReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaContextKey{}, false)
+}
+
+// Report whether the context's read-only queries may be served by a replica
+func ReplicaReadsAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(replicaContextKey{}).(bool)
+
+	return allowed
+}
+
+// Define a RoutedQuerier type which sends the read-only queries of contexts which allow
+// it to a read replica, and every other query to the primary
+type RoutedQuerier struct {
+	Primary Querier
+	Replica Querier
+}
+
+// Return the querier a query should run on. The queries of a transaction are run on
+// the primary, where the transaction is.
+func (q RoutedQuerier) route(ctx context.Context, query string) Querier {
+	if _, inTx := TxFromContext(ctx); inTx {
+		return q.Primary
+	}
+
+	if ReplicaReadsAllowed(ctx) && isReadOnly(query) {
+		return q.Replica
+	}
+
+	return q.Primary
+}
+
+func (q RoutedQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return q.route(ctx, query).QueryRowContext(ctx, query, args...)
+}
+
+func (q RoutedQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return q.route(ctx, query).QueryContext(ctx, query, args...)
+}
+
+// Queries which don't return rows always change something, so they go to the primary
+func (q RoutedQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return q.Primary.ExecContext(ctx, query, args...)
+}
+
+// Report whether a query only reads. Only plain SELECT queries are counted as reads;
+// WITH queries can hold INSERT, UPDATE or DELETE statements, and locking reads must run
+// on the primary.
+func isReadOnly(query string) bool {
+	query = strings.ToUpper(strings.TrimSpace(query))
+
+	return strings.HasPrefix(query, "SELECT") && !strings.Contains(query, " FOR UPDATE") && !strings.Contains(query, " FOR SHARE")
+}
@@ -0,0 +1,218 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// We'll return this when a user reports a review they have already reported
+var ErrDuplicateReport = errors.New("duplicate report")
+
+// Define the reasons a review can be reported for
+var ReportReasons = []string{"spam", "harassment", "hate_speech", "spoilers", "off_topic", "other"}
+
+// Define the statuses of a report. Open reports count towards hiding the review, and
+// are closed by a moderator either upholding or dismissing them.
+const (
+	ReportOpen      = "open"
+	ReportUpheld    = "upheld"
+	ReportDismissed = "dismissed"
+)
+
+// Define a ReviewReport struct to represent a user flagging a review as abusive
+type ReviewReport struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReviewID   int64      `json:"review_id"`
+	UserID     int64      `json:"user_id"`
+	Reason     string     `json:"reason"`
+	Comment    string     `json:"comment,omitempty"`
+	Status     string     `json:"status"`
+	ResolvedBy int64      `json:"-"` // The moderator's user ID, or 0 while the report is open
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Version    int32      `json:"version"`
+}
+
+// Run validation checks on `ReviewReport` struct
+func ValidateReviewReport(v *validator.Validator, report *ReviewReport) {
+	v.Check(report.Reason != "", "reason", "must be provided", validator.CodeRequired)
+	v.Check(validator.In(report.Reason, ReportReasons...), "reason", "must be one of "+strings.Join(ReportReasons, ", "), validator.CodeNotOneOf, validator.Params{"allowed": ReportReasons})
+
+	v.Check(report.Reason != "other" || report.Comment != "", "comment", "must be provided when the reason is other", validator.CodeRequired)
+	v.Check(len(report.Comment) <= 500, "comment", "must not be more than 500 bytes long", validator.CodeTooLong, validator.Params{"max": 500})
+}
+
+// Define a ReportModel struct type which wraps a sql.DB connection pool
+type ReportModel struct {
+	DB Querier
+}
+
+// The columns selected for a report, in the order scanned by reportFields()
+const reportColumns = `id, created_at, review_id, user_id, reason, comment, status,
+	COALESCE(resolved_by, 0), resolved_at, version`
+
+// Return the destinations for scanning the reportColumns into a report
+func reportFields(report *ReviewReport) []interface{} {
+	return []interface{}{
+		&report.ID,
+		&report.CreatedAt,
+		&report.ReviewID,
+		&report.UserID,
+		&report.Reason,
+		&report.Comment,
+		&report.Status,
+		&report.ResolvedBy,
+		&report.ResolvedAt,
+		&report.Version,
+	}
+}
+
+// Inserts a new open report and returns the number of open reports on the review,
+// including the new one. It returns ErrRecordNotFound if the review doesn't exist and
+// ErrDuplicateReport if the user has already reported it.
+func (m ReportModel) Insert(ctx context.Context, report *ReviewReport) (int, error) {
+	query := `
+		WITH report AS (
+			INSERT INTO review_reports (review_id, user_id, reason, comment)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at, status, version
+		)
+		SELECT report.id, report.created_at, report.status, report.version,
+			(SELECT COUNT(*) FROM review_reports WHERE review_id = $1 AND status = 'open') + 1
+		FROM report`
+
+	var open int
+
+	err := m.DB.QueryRowContext(
+		ctx,
+		query,
+		report.ReviewID,
+		report.UserID,
+		report.Reason,
+		report.Comment,
+	).Scan(&report.ID, &report.CreatedAt, &report.Status, &report.Version, &open)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "review_reports" violates foreign key constraint "review_reports_review_id_fkey"`:
+			return 0, ErrRecordNotFound
+		case err.Error() == `pq: duplicate key value violates unique constraint "review_reports_review_id_user_id_key"`:
+			return 0, ErrDuplicateReport
+		default:
+			return 0, err
+		}
+	}
+
+	return open, nil
+}
+
+// Fetches a specific report
+func (m ReportModel) Get(ctx context.Context, id int64) (*ReviewReport, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + reportColumns + `
+		FROM review_reports
+		WHERE id = $1`
+
+	var report ReviewReport
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(reportFields(&report)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &report, nil
+}
+
+// Fetches a page of the reports with the given status, optionally only those for the
+// given reason
+func (m ReportModel) GetAll(ctx context.Context, status, reason string, filters Filters) ([]*ReviewReport, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), %s
+		FROM review_reports
+		WHERE status = $1
+		AND (reason = $2 OR $2 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4`, reportColumns, filters.sortColumn(), filters.sortDirection())
+
+	rows, err := m.DB.QueryContext(ctx, query, status, reason, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	reports := []*ReviewReport{}
+
+	for rows.Next() {
+		var report ReviewReport
+
+		err := rows.Scan(append([]interface{}{&totalRecords}, reportFields(&report)...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		reports = append(reports, &report)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return reports, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Closes a report with the report's status. The report is only updated if its version
+// matches, so that two moderators can't overwrite each other's decisions.
+func (m ReportModel) Resolve(ctx context.Context, report *ReviewReport) error {
+	query := `
+		UPDATE review_reports
+		SET status = $1, resolved_by = NULLIF($2, 0), resolved_at = NOW(), version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING resolved_at, version`
+
+	err := m.DB.QueryRowContext(
+		ctx,
+		query,
+		report.Status,
+		report.ResolvedBy,
+		report.ID,
+		report.Version,
+	).Scan(&report.ResolvedAt, &report.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Closes all of a review's open reports with the given status, once a moderator has
+// decided on the review itself
+func (m ReportModel) ResolveForReview(ctx context.Context, reviewID int64, status string, resolvedBy int64) error {
+	query := `
+		UPDATE review_reports
+		SET status = $1, resolved_by = NULLIF($2, 0), resolved_at = NOW(), version = version + 1
+		WHERE review_id = $3 AND status = 'open'`
+
+	_, err := m.DB.ExecContext(ctx, query, status, resolvedBy, reviewID)
+	return err
+}
@@ -0,0 +1,237 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+)
+
+// We'll return this when a user reviews a movie they have already reviewed
+var ErrDuplicateReview = errors.New("duplicate review")
+
+// Define the moderation statuses of a review. Only approved reviews are shown publicly.
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Define the statuses which a moderator can move a review to from each status. A
+// moderator can change their mind about a decision, but only reports send a review
+// back to pending.
+var reviewTransitions = map[string][]string{
+	ReviewPending:  {ReviewApproved, ReviewRejected},
+	ReviewApproved: {ReviewRejected},
+	ReviewRejected: {ReviewApproved},
+}
+
+// Define a Review struct to represent a user's review of a movie
+type Review struct {
+	ID               int64      `json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	MovieID          int64      `json:"movie_id"`
+	UserID           int64      `json:"user_id"`
+	UserName         string     `json:"user_name"`
+	Rating           int16      `json:"rating"` // From 1 to 10
+	Body             string     `json:"body"`
+	Status           string     `json:"status"`
+	ModerationReason string     `json:"moderation_reason,omitempty"` // Why the review was held or rejected
+	ModeratedBy      int64      `json:"-"`                           // The moderator's user ID, or 0 for automatic decisions
+	ModeratedAt      *time.Time `json:"moderated_at,omitempty"`
+	Version          int32      `json:"version"`
+}
+
+// Run validation checks on `Review` struct
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Rating != 0, "rating", "must be provided", validator.CodeRequired)
+	v.Check(review.Rating >= 1 && review.Rating <= 10, "rating", "must be between 1 and 10", validator.CodeOutOfRange, validator.Params{"min": 1, "max": 10})
+
+	v.Check(review.Body != "", "body", "must be provided", validator.CodeRequired)
+	v.Check(len(review.Body) <= 10_000, "body", "must not be more than 10000 bytes long", validator.CodeTooLong, validator.Params{"max": 10000})
+}
+
+// Report whether the review can be moved to the given status
+func (r *Review) CanTransitionTo(status string) bool {
+	return validator.In(status, reviewTransitions[r.Status]...)
+}
+
+// Define a ReviewModel struct type which wraps a sql.DB connection pool
+type ReviewModel struct {
+	DB Querier
+}
+
+// The columns selected for a review, in the order scanned by reviewFields()
+const reviewColumns = `reviews.id, reviews.created_at, reviews.movie_id, reviews.user_id, users.name,
+	reviews.rating, reviews.body, reviews.status, reviews.moderation_reason,
+	COALESCE(reviews.moderated_by, 0), reviews.moderated_at, reviews.version`
+
+// Return the destinations for scanning the reviewColumns into a review
+func reviewFields(review *Review) []interface{} {
+	return []interface{}{
+		&review.ID,
+		&review.CreatedAt,
+		&review.MovieID,
+		&review.UserID,
+		&review.UserName,
+		&review.Rating,
+		&review.Body,
+		&review.Status,
+		&review.ModerationReason,
+		&review.ModeratedBy,
+		&review.ModeratedAt,
+		&review.Version,
+	}
+}
+
+// Inserts a new review. It returns ErrRecordNotFound if the movie doesn't exist and
+// ErrDuplicateReview if the user has already reviewed it.
+func (m ReviewModel) Insert(ctx context.Context, review *Review) error {
+	query := `
+		INSERT INTO reviews (movie_id, user_id, rating, body, status, moderation_reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, version`
+
+	err := m.DB.QueryRowContext(
+		ctx,
+		query,
+		review.MovieID,
+		review.UserID,
+		review.Rating,
+		review.Body,
+		review.Status,
+		review.ModerationReason,
+	).Scan(&review.ID, &review.CreatedAt, &review.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "reviews" violates foreign key constraint "reviews_movie_id_fkey"`:
+			return ErrRecordNotFound
+		case err.Error() == `pq: duplicate key value violates unique constraint "reviews_movie_id_user_id_key"`:
+			return ErrDuplicateReview
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fetches a specific review, whatever its status
+func (m ReviewModel) Get(ctx context.Context, id int64) (*Review, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + reviewColumns + `
+		FROM reviews
+		INNER JOIN users ON users.id = reviews.user_id
+		WHERE reviews.id = $1`
+
+	var review Review
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(reviewFields(&review)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &review, nil
+}
+
+// Fetches a page of reviews matching the given condition, whose arguments start at $3
+func (m ReviewModel) getPage(ctx context.Context, where string, filters Filters, args ...interface{}) ([]*Review, Metadata, error) {
+	keyset := filters.keysetCondition("reviews.id", len(args)+3)
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), %s
+		FROM reviews
+		INNER JOIN users ON users.id = reviews.user_id
+		WHERE %s AND %s
+		ORDER BY reviews.%s %s, reviews.id ASC
+		LIMIT $1 OFFSET $2`, reviewColumns, where, keyset, filters.sortColumn(), filters.sortDirection())
+
+	args = append([]interface{}{filters.limit(), filters.offset()}, args...)
+	args = append(args, filters.After)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	reviews := []*Review{}
+
+	for rows.Next() {
+		var review Review
+
+		err := rows.Scan(append([]interface{}{&totalRecords}, reviewFields(&review)...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	var lastID int64
+	if len(reviews) > 0 {
+		lastID = reviews[len(reviews)-1].ID
+	}
+
+	return reviews, filters.metadata(totalRecords, len(reviews), lastID), nil
+}
+
+// Fetches a page of a movie's approved reviews, which are the ones shown publicly
+func (m ReviewModel) GetAllForMovie(ctx context.Context, movieID int64, filters Filters) ([]*Review, Metadata, error) {
+	return m.getPage(ctx, "reviews.movie_id = $3 AND reviews.status = $4", filters, movieID, ReviewApproved)
+}
+
+// Fetches a page of the reviews with the given status, for moderators
+func (m ReviewModel) GetAllByStatus(ctx context.Context, status string, filters Filters) ([]*Review, Metadata, error) {
+	return m.getPage(ctx, "reviews.status = $3", filters, status)
+}
+
+// Records a moderation decision on a review, moving it to the review's status. The
+// decision is only recorded if the review's version matches, so that two moderators
+// can't overwrite each other's decisions.
+func (m ReviewModel) Moderate(ctx context.Context, review *Review) error {
+	query := `
+		UPDATE reviews
+		SET status = $1, moderation_reason = $2, moderated_by = NULLIF($3, 0), moderated_at = NOW(),
+			version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING moderated_at, version`
+
+	err := m.DB.QueryRowContext(
+		ctx,
+		query,
+		review.Status,
+		review.ModerationReason,
+		review.ModeratedBy,
+		review.ID,
+		review.Version,
+	).Scan(&review.ModeratedAt, &review.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -3,6 +3,8 @@ package data
 import (
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -33,7 +35,20 @@ func (r Runtime) MarshalJSON() ([]byte, error) {
 // correctly. Otherwise, we will only be modifying a copy (which is then discarded when
 // this method returns)
 func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
-	// We expect that the incoming JSON value will be a string in the format
+	// Many clients send the runtime as a bare number of minutes, so accept a JSON
+	// integer as well as the string we send
+	if len(jsonValue) > 0 && jsonValue[0] != '"' {
+		minutes, err := strconv.ParseInt(string(jsonValue), 10, 32)
+		if err != nil {
+			return ErrInvalidRuntimeFormat
+		}
+
+		*r = Runtime(minutes)
+
+		return nil
+	}
+
+	// Otherwise we expect that the incoming JSON value will be a string in the format
 	// "<runtime> mins", and the first thing we need to do is remove the surrounding
 	// double-quotes from this string. If we can't unquote it, then we return the
 	// ErrInvalidRuntimeFormat error.
@@ -42,6 +57,18 @@ func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
 		return ErrInvalidRuntimeFormat
 	}
 
+	// ISO 8601 durations such as "PT2H28M" are accepted too
+	if strings.HasPrefix(unquotedJsonValue, "P") {
+		runtime, err := ParseISO8601Runtime(unquotedJsonValue)
+		if err != nil {
+			return err
+		}
+
+		*r = runtime
+
+		return nil
+	}
+
 	// Split the string to isolate the part containing the number of minutes
 	parts := strings.Split(unquotedJsonValue, " ")
 
@@ -61,3 +88,51 @@ func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
 
 	return nil
 }
+
+// Match the ISO 8601 durations which can be a movie runtime, made up of hours, minutes
+// and seconds
+var iso8601RuntimeRegex = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+
+// Parse an ISO 8601 duration such as "PT2H28M" into a runtime. Runtimes are a whole
+// number of minutes, so durations with seconds must add up to whole minutes.
+func ParseISO8601Runtime(value string) (Runtime, error) {
+	matches := iso8601RuntimeRegex.FindStringSubmatch(value)
+	if matches == nil || value == "PT" {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	var seconds int64
+
+	for i, unit := range []int64{3600, 60, 1} {
+		if matches[i+1] == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(matches[i+1], 10, 32)
+		if err != nil {
+			return 0, ErrInvalidRuntimeFormat
+		}
+
+		seconds += n * unit
+	}
+
+	if seconds%60 != 0 || seconds/60 > math.MaxInt32 {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	return Runtime(seconds / 60), nil
+}
+
+// Return the runtime as an ISO 8601 duration, such as "PT2H28M"
+func (r Runtime) ISO8601() string {
+	hours, minutes := r/60, r%60
+
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("PT%dM", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("PT%dH", hours)
+	default:
+		return fmt.Sprintf("PT%dH%dM", hours, minutes)
+	}
+}
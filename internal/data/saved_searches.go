@@ -0,0 +1,184 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Define a SavedSearch struct to represent a set of movie list filters saved by a user.
+// Users are emailed when movies matching one of their saved searches are added.
+type SavedSearch struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UserID      int64     `json:"-"`
+	Name        string    `json:"name"`
+	Title       string    `json:"title"`
+	Genres      []string  `json:"genres"`
+	LastMovieID int64     `json:"-"` // The newest movie the user has already been told about
+	UserEmail   string    `json:"-"` // Only set by GetBatchForAlerts()
+}
+
+// Run validation checks on `SavedSearch` struct
+func ValidateSavedSearch(v *validator.Validator, search *SavedSearch) {
+	v.Check(search.Name != "", "name", "must be provided", validator.CodeRequired)
+	v.Check(len(search.Name) <= 100, "name", "must not be more than 100 bytes long", validator.CodeTooLong, validator.Params{"max": 100})
+
+	v.Check(len(search.Title) <= 500, "title", "must not be more than 500 bytes long", validator.CodeTooLong, validator.Params{"max": 500})
+
+	v.Check(len(search.Genres) <= 5, "genres", "must not contain more than 5 genres", validator.CodeTooMany, validator.Params{"max": 5})
+	v.Check(validator.Unique(search.Genres), "genres", "must not contain duplicate values", validator.CodeDuplicate)
+}
+
+// Define the SavedSearchModel type
+type SavedSearchModel struct {
+	DB Querier
+}
+
+// Inserts a new saved search. Only movies added after the search was saved are alerted
+// on, so the newest existing movie is recorded as already seen.
+func (m SavedSearchModel) Insert(ctx context.Context, search *SavedSearch) error {
+	query := `
+		INSERT INTO saved_searches (user_id, name, title, genres, last_movie_id)
+		VALUES ($1, $2, $3, $4, (SELECT COALESCE(MAX(id), 0) FROM movies))
+		RETURNING id, created_at, last_movie_id`
+
+	return m.DB.QueryRowContext(
+		ctx,
+		query,
+		search.UserID,
+		search.Name,
+		search.Title,
+		pq.Array(search.Genres),
+	).Scan(&search.ID, &search.CreatedAt, &search.LastMovieID)
+}
+
+// Fetches all of a user's saved searches, oldest first
+func (m SavedSearchModel) GetAllForUser(ctx context.Context, userID int64) ([]*SavedSearch, error) {
+	query := `
+		SELECT id, created_at, user_id, name, title, genres, last_movie_id
+		FROM saved_searches
+		WHERE user_id = $1
+		ORDER BY id`
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	searches := []*SavedSearch{}
+
+	for rows.Next() {
+		var search SavedSearch
+
+		err := rows.Scan(
+			&search.ID,
+			&search.CreatedAt,
+			&search.UserID,
+			&search.Name,
+			&search.Title,
+			pq.Array(&search.Genres),
+			&search.LastMovieID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		searches = append(searches, &search)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return searches, nil
+}
+
+// Fetches up to limit saved searches with IDs greater than afterID, along with the email
+// addresses of their users. Searches belonging to users who aren't activated are
+// skipped. Call it repeatedly with the last ID returned to go through every search.
+func (m SavedSearchModel) GetBatchForAlerts(ctx context.Context, afterID int64, limit int) ([]*SavedSearch, error) {
+	query := `
+		SELECT saved_searches.id, saved_searches.created_at, saved_searches.user_id, saved_searches.name,
+			saved_searches.title, saved_searches.genres, saved_searches.last_movie_id, users.email
+		FROM saved_searches
+		INNER JOIN users ON users.id = saved_searches.user_id
+		WHERE saved_searches.id > $1
+		AND users.activated
+		ORDER BY saved_searches.id
+		LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	searches := []*SavedSearch{}
+
+	for rows.Next() {
+		var search SavedSearch
+
+		err := rows.Scan(
+			&search.ID,
+			&search.CreatedAt,
+			&search.UserID,
+			&search.Name,
+			&search.Title,
+			pq.Array(&search.Genres),
+			&search.LastMovieID,
+			&search.UserEmail,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		searches = append(searches, &search)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return searches, nil
+}
+
+// Records the newest movie the user has been told about for a saved search
+func (m SavedSearchModel) SetLastMovieID(ctx context.Context, id, lastMovieID int64) error {
+	query := `
+		UPDATE saved_searches
+		SET last_movie_id = $1
+		WHERE id = $2`
+
+	_, err := m.DB.ExecContext(ctx, query, lastMovieID, id)
+
+	return err
+}
+
+// Deletes one of a user's saved searches
+func (m SavedSearchModel) Delete(ctx context.Context, userID, id int64) error {
+	query := `
+		DELETE FROM saved_searches
+		WHERE id = $1 AND user_id = $2`
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package data
+
+import (
+	"context"
+)
+
+// Define a MovieIndexQuery struct holding a movie search to run against a search index,
+// along with the sort order and the window of results to return
+type MovieIndexQuery struct {
+	MovieSearch
+	SortColumn string
+	Descending bool
+	Offset     int
+	Limit      int
+}
+
+// Define a MovieIndexResult struct holding the IDs of the movies matching a search in the
+// window, in order, along with the total number of matches
+type MovieIndexResult struct {
+	IDs          []int64
+	TotalRecords int
+}
+
+// Define a MovieIndex interface for a search engine holding a copy of the movies, such
+// as Elasticsearch or Meilisearch
+type MovieIndex interface {
+	Search(ctx context.Context, query MovieIndexQuery) (MovieIndexResult, error)
+	Facets(ctx context.Context, search MovieSearch) (*MovieFacets, error)
+}
+
+// Define a SearchMovieModel type which wraps another MovieStore, running GetAll() searches
+// against a search index and then fetching the matching movies from the wrapped model.
+// Facets are counted by the index too. Only published movies are indexed. Searches the
+// index can't answer are run against the wrapped model instead: filtering on release
+// dates, metadata or the creating user (as none of them are indexed), searches including
+// unpublished movies and pages past maxWindow results. If the index can't be reached the
+// error is passed to onError and the search falls back to the wrapped model too.
+type SearchMovieModel struct {
+	MovieStore
+	index     MovieIndex
+	maxWindow int
+	onError   func(error)
+}
+
+// Return a new SearchMovieModel. maxWindow is the number of results the index can page
+// through, which is 10,000 by default for Elasticsearch.
+func NewSearchMovieModel(movies MovieStore, index MovieIndex, maxWindow int, onError func(error)) SearchMovieModel {
+	return SearchMovieModel{
+		MovieStore: movies,
+		index:      index,
+		maxWindow:  maxWindow,
+		onError:    onError,
+	}
+}
+
+// Fetches the movies matching the search from the index
+func (m SearchMovieModel) GetAll(ctx context.Context, search MovieSearch, filters Filters) ([]*Movie, Metadata, error) {
+	if !indexed(search) || filters.offset()+filters.limit() > m.maxWindow {
+		return m.MovieStore.GetAll(ctx, search, filters)
+	}
+
+	result, err := m.index.Search(ctx, MovieIndexQuery{
+		MovieSearch: search,
+		SortColumn:  filters.sortColumn(),
+		Descending:  filters.sortDirection() == "DESC",
+		Offset:      filters.offset(),
+		Limit:       filters.limit(),
+	})
+	if err != nil {
+		m.onError(err)
+		return m.MovieStore.GetAll(ctx, search, filters)
+	}
+
+	movies, err := m.MovieStore.GetMany(ctx, result.IDs)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return movies, calculateMetadata(result.TotalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Counts the facets of the movies matching the search in the index
+func (m SearchMovieModel) Facets(ctx context.Context, search MovieSearch) (*MovieFacets, error) {
+	if !indexed(search) {
+		return m.MovieStore.Facets(ctx, search)
+	}
+
+	facets, err := m.index.Facets(ctx, search)
+	if err != nil {
+		m.onError(err)
+		return m.MovieStore.Facets(ctx, search)
+	}
+
+	return facets, nil
+}
+
+// Report whether a search only filters on fields which are in the index, and only
+// matches the published movies the index holds
+func indexed(search MovieSearch) bool {
+	return search.ReleasedBefore.IsZero() && search.ReleasedAfter.IsZero() && len(search.Metadata) == 0 && search.CreatedBy == 0 &&
+		search.PublishedOnly
+}
@@ -0,0 +1,278 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// The prefix of service account keys, which tells them apart from the authentication
+// tokens of users
+const ServiceAccountKeyPrefix = "sa_"
+
+// Define a ServiceAccount struct to represent a non-human client, such as a CI system,
+// which authenticates with a long-lived key instead of an email address and password.
+// Its requests are made on behalf of the user owning it, restricted to the account's
+// permissions, so it can never do more than its owner.
+type ServiceAccount struct {
+	ID          int64       `json:"id"`
+	CreatedAt   time.Time   `json:"created_at"`
+	OwnerID     int64       `json:"owner_id"`
+	Name        string      `json:"name"`
+	KeyHash     []byte      `json:"-"`
+	KeyPrefix   string      `json:"key_prefix"` // The start of the key, for telling keys apart
+	Permissions Permissions `json:"permissions"`
+	Active      bool        `json:"active"`
+	Version     int         `json:"version"`
+}
+
+// Generate a new random key for the service account, returning its plaintext. Only the
+// hash of the key is stored, so the plaintext must be handed to the client straight
+// away.
+func (a *ServiceAccount) GenerateKey() (string, error) {
+	randomBytes := make([]byte, 20)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	key := ServiceAccountKeyPrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes))
+
+	hash := sha256.Sum256([]byte(key))
+	a.KeyHash = hash[:]
+	a.KeyPrefix = key[:len(ServiceAccountKeyPrefix)+8]
+
+	return key, nil
+}
+
+// Report whether a bearer token is a service account key rather than a user's
+// authentication token
+func IsServiceAccountKey(token string) bool {
+	return strings.HasPrefix(token, ServiceAccountKeyPrefix)
+}
+
+// Run validation checks on `ServiceAccount` struct, whose permissions must be some of
+// those held by its owner
+func ValidateServiceAccount(v *validator.Validator, account *ServiceAccount, ownerPermissions Permissions) {
+	v.Check(account.Name != "", "name", "must be provided", validator.CodeRequired)
+	v.Check(len(account.Name) <= 100, "name", "must not be more than 100 bytes long", validator.CodeTooLong, validator.Params{"max": 100})
+
+	ValidatePermissionSubset(v, "permissions", account.Permissions, ownerPermissions)
+}
+
+// Define the ServiceAccountModel type
+type ServiceAccountModel struct {
+	DB Querier
+}
+
+// Inserts a new service account
+func (m ServiceAccountModel) Insert(ctx context.Context, account *ServiceAccount) error {
+	query := `
+		INSERT INTO service_accounts (owner_id, name, key_hash, key_prefix, permissions, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, version`
+
+	return m.DB.QueryRowContext(
+		ctx,
+		query,
+		account.OwnerID,
+		account.Name,
+		account.KeyHash,
+		account.KeyPrefix,
+		pq.Array([]string(account.Permissions)),
+		account.Active,
+	).Scan(&account.ID, &account.CreatedAt, &account.Version)
+}
+
+// Fetches one of a user's service accounts
+func (m ServiceAccountModel) Get(ctx context.Context, ownerID, id int64) (*ServiceAccount, error) {
+	var account ServiceAccount
+
+	query := `
+		SELECT id, created_at, owner_id, name, key_hash, key_prefix, permissions, active, version
+		FROM service_accounts
+		WHERE id = $1 AND owner_id = $2`
+
+	err := m.DB.QueryRowContext(ctx, query, id, ownerID).Scan(
+		&account.ID,
+		&account.CreatedAt,
+		&account.OwnerID,
+		&account.Name,
+		&account.KeyHash,
+		&account.KeyPrefix,
+		pq.Array((*[]string)(&account.Permissions)),
+		&account.Active,
+		&account.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &account, nil
+}
+
+// Fetches all of a user's service accounts, oldest first
+func (m ServiceAccountModel) GetAllForOwner(ctx context.Context, ownerID int64) ([]*ServiceAccount, error) {
+	query := `
+		SELECT id, created_at, owner_id, name, key_hash, key_prefix, permissions, active, version
+		FROM service_accounts
+		WHERE owner_id = $1
+		ORDER BY id`
+
+	rows, err := m.DB.QueryContext(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	accounts := []*ServiceAccount{}
+
+	for rows.Next() {
+		var account ServiceAccount
+
+		err := rows.Scan(
+			&account.ID,
+			&account.CreatedAt,
+			&account.OwnerID,
+			&account.Name,
+			&account.KeyHash,
+			&account.KeyPrefix,
+			pq.Array((*[]string)(&account.Permissions)),
+			&account.Active,
+			&account.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, &account)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// Fetches an active service account by its plaintext key, along with its owner. The
+// owner is returned restricted to the account's permissions, ready to be used as the
+// user making the request.
+func (m ServiceAccountModel) GetForKey(ctx context.Context, key string) (*ServiceAccount, *User, error) {
+	var account ServiceAccount
+	var user User
+
+	hash := sha256.Sum256([]byte(key))
+
+	query := `
+		SELECT service_accounts.id, service_accounts.created_at, service_accounts.owner_id, service_accounts.name,
+			service_accounts.key_hash, service_accounts.key_prefix, service_accounts.permissions,
+			service_accounts.active, service_accounts.version,
+			users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+		FROM service_accounts
+		INNER JOIN users ON users.id = service_accounts.owner_id
+		WHERE service_accounts.key_hash = $1
+		AND service_accounts.active`
+
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(
+		&account.ID,
+		&account.CreatedAt,
+		&account.OwnerID,
+		&account.Name,
+		&account.KeyHash,
+		&account.KeyPrefix,
+		pq.Array((*[]string)(&account.Permissions)),
+		&account.Active,
+		&account.Version,
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	user.TokenPermissions = account.Permissions
+
+	return &account, &user, nil
+}
+
+// Updates a service account's name, key, permissions and whether it is active, using
+// optimistic locking on its version
+func (m ServiceAccountModel) Update(ctx context.Context, account *ServiceAccount) error {
+	query := `
+		UPDATE service_accounts
+		SET name = $1, key_hash = $2, key_prefix = $3, permissions = $4, active = $5, version = version + 1
+		WHERE id = $6 AND owner_id = $7 AND version = $8
+		RETURNING version`
+
+	err := m.DB.QueryRowContext(
+		ctx,
+		query,
+		account.Name,
+		account.KeyHash,
+		account.KeyPrefix,
+		pq.Array([]string(account.Permissions)),
+		account.Active,
+		account.ID,
+		account.OwnerID,
+		account.Version,
+	).Scan(&account.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deletes one of a user's service accounts
+func (m ServiceAccountModel) Delete(ctx context.Context, ownerID, id int64) error {
+	query := `
+		DELETE FROM service_accounts
+		WHERE id = $1 AND owner_id = $2`
+
+	result, err := m.DB.ExecContext(ctx, query, id, ownerID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package data
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Define a Querier interface for the query methods used by our models. This is
+// satisfied by both *sql.DB and *Statements, so the models can run their queries
+// either directly or through prepared statements.
+type Querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// The maximum number of prepared statements kept by a Statements instance. Queries such
+// as the movie listings are built from the filters they are given, so the number of
+// distinct queries isn't fixed, and each statement is prepared on every connection of
+// the pool which runs it.
+const maxStatements = 256
+
+// Define a Statements type which prepares each distinct query the first time it is
+// run and reuses the prepared statement afterwards, so that PostgreSQL doesn't have to
+// parse and plan hot queries (like looking up the user for a token) on every request.
+// A sql.Stmt is safe for concurrent use and is transparently re-prepared on other
+// connections in the pool as needed. Only the most recently used statements are kept;
+// the others are closed once no query is about to use them.
+type Statements struct {
+	db    *sql.DB
+	size  int
+	mutex sync.Mutex
+	cache map[string]*preparedStmt
+	lru   *list.List
+}
+
+// Define a preparedStmt struct for a cached statement, counting the queries which have
+// been handed it and have yet to run it
+type preparedStmt struct {
+	stmt    *sql.Stmt
+	element *list.Element
+	refs    int
+	evicted bool
+}
+
+// Return a new Statements instance which prepares statements on the given pool
+func NewStatements(db *sql.DB) *Statements {
+	return &Statements{
+		db:    db,
+		size:  maxStatements,
+		cache: make(map[string]*preparedStmt),
+		lru:   list.New(),
+	}
+}
+
+// Return the prepared statement for a query, preparing it if this is the first time
+// the query has been run. The mutex isn't held while preparing, so that a slow prepare
+// doesn't hold up the queries whose statements are already prepared; if two callers
+// prepare the same query at once, the statement which loses is closed. The statement
+// must be released with release() once the query has been started.
+func (s *Statements) prepare(ctx context.Context, query string) (*preparedStmt, error) {
+	s.mutex.Lock()
+
+	if prepared, found := s.cache[query]; found {
+		s.lru.MoveToFront(prepared.element)
+		prepared.refs++
+		s.mutex.Unlock()
+
+		return prepared, nil
+	}
+
+	s.mutex.Unlock()
+
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+
+	if prepared, found := s.cache[query]; found {
+		s.lru.MoveToFront(prepared.element)
+		prepared.refs++
+		s.mutex.Unlock()
+
+		stmt.Close()
+
+		return prepared, nil
+	}
+
+	prepared := &preparedStmt{stmt: stmt, refs: 1}
+	prepared.element = s.lru.PushFront(query)
+	s.cache[query] = prepared
+
+	// Evict the least recently used statements over the limit. Those which are about
+	// to be used are closed when they are released instead.
+	var evicted []*sql.Stmt
+
+	for s.lru.Len() > s.size {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+
+		query := oldest.Value.(string)
+		victim := s.cache[query]
+		delete(s.cache, query)
+
+		victim.evicted = true
+		if victim.refs == 0 {
+			evicted = append(evicted, victim.stmt)
+		}
+	}
+
+	s.mutex.Unlock()
+
+	for _, stmt := range evicted {
+		stmt.Close()
+	}
+
+	return prepared, nil
+}
+
+// Release a statement returned by prepare(), closing it if it has been evicted and no
+// other query is about to use it. A statement can be closed once its queries have been
+// started, as database/sql only closes it for good when their rows are closed.
+func (s *Statements) release(prepared *preparedStmt) {
+	s.mutex.Lock()
+	prepared.refs--
+	closeNow := prepared.evicted && prepared.refs == 0
+	s.mutex.Unlock()
+
+	if closeNow {
+		prepared.stmt.Close()
+	}
+}
+
+// Return the prepared statement to run a query with, along with the function to call
+// once the query has been started. If the context carries a transaction (see
+// WithTx()), the statement is bound to it, which reuses the prepared statement on the
+// transaction's connection.
+func (s *Statements) stmt(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	prepared, err := s.prepare(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	release := func() { s.release(prepared) }
+
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.StmtContext(ctx, prepared.stmt), release, nil
+	}
+
+	return prepared.stmt, release, nil
+}
+
+// Run a query which returns at most one row using a prepared statement. If the
+// statement can't be prepared we run the query directly, which returns the same error
+// through the Row's Scan() method.
+func (s *Statements) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	stmt, release, err := s.stmt(ctx, query)
+	if err != nil {
+		if tx, ok := TxFromContext(ctx); ok {
+			return tx.QueryRowContext(ctx, query, args...)
+		}
+
+		return s.db.QueryRowContext(ctx, query, args...)
+	}
+	defer release()
+
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+// Run a query which returns rows using a prepared statement
+func (s *Statements) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, release, err := s.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return stmt.QueryContext(ctx, args...)
+}
+
+// Execute a query without returning any rows using a prepared statement
+func (s *Statements) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, release, err := s.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return stmt.ExecContext(ctx, args...)
+}
+
+// Close all the prepared statements. This should be called before the connection pool
+// is closed on shutdown.
+func (s *Statements) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var firstErr error
+
+	for query, prepared := range s.cache {
+		err := prepared.stmt.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		delete(s.cache, query)
+	}
+
+	s.lru.Init()
+
+	return firstErr
+}
@@ -0,0 +1,142 @@
+package data_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/data/datatest"
+)
+
+// Running more distinct queries than the statements kept, from several goroutines at
+// once, must evict and close the least recently used statements without failing the
+// queries which are using them
+func TestStatementsEviction(t *testing.T) {
+	_, db := datatest.NewModels(t)
+	ctx := context.Background()
+
+	statements := data.NewStatements(db)
+	t.Cleanup(func() { statements.Close() })
+
+	errs := datatest.Concurrently(8, func(i int) error {
+		for n := 0; n < 200; n++ {
+			offset := (i*37 + n) % 600
+
+			var sum int
+
+			err := statements.QueryRowContext(ctx, fmt.Sprintf("SELECT $1::int + %d", offset), n).Scan(&sum)
+			if err != nil {
+				return err
+			}
+
+			if sum != n+offset {
+				return fmt.Errorf("got %d; want %d", sum, n+offset)
+			}
+		}
+
+		return nil
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// Compare running the hot queries directly on the pool, which has PostgreSQL parse and
+// plan them on every call, with running them through the prepared statement cache.
+// Run with `go test -run=^$ -bench=. ./internal/data` against the test database.
+
+func BenchmarkUserGetForToken(b *testing.B) {
+	models, db := datatest.NewModels(b)
+	ctx := context.Background()
+
+	user := datatest.InsertUser(b, models, "alice@example.com", "pa55word1234")
+
+	token, err := models.Token.New(ctx, user.ID, time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	run := func(b *testing.B, users data.UserModel) {
+		for i := 0; i < b.N; i++ {
+			_, err := users.GetForToken(ctx, data.ScopeAuthentication, token.PlainText)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("unprepared", func(b *testing.B) {
+		run(b, data.UserModel{DB: db})
+	})
+
+	b.Run("prepared", func(b *testing.B) {
+		statements := data.NewStatements(db)
+		defer statements.Close()
+
+		run(b, data.UserModel{DB: statements})
+	})
+}
+
+func BenchmarkMovieGet(b *testing.B) {
+	models, db := datatest.NewModels(b)
+	ctx := context.Background()
+
+	movie := datatest.InsertMovie(b, models, "Moana")
+
+	run := func(b *testing.B, movies data.MovieModel) {
+		for i := 0; i < b.N; i++ {
+			_, err := movies.Get(ctx, movie.ID)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("unprepared", func(b *testing.B) {
+		run(b, data.MovieModel{DB: db})
+	})
+
+	b.Run("prepared", func(b *testing.B) {
+		statements := data.NewStatements(db)
+		defer statements.Close()
+
+		run(b, data.MovieModel{DB: statements})
+	})
+}
+
+func BenchmarkPermissionsGetAllForUser(b *testing.B) {
+	models, db := datatest.NewModels(b)
+	ctx := context.Background()
+
+	user := datatest.InsertUser(b, models, "alice@example.com", "pa55word1234")
+
+	err := models.Permissions.AddForUser(ctx, user.ID, "movies:read", "movies:write")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	run := func(b *testing.B, permissions data.PermissionModel) {
+		for i := 0; i < b.N; i++ {
+			_, err := permissions.GetAllForUser(ctx, user.ID)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("unprepared", func(b *testing.B) {
+		run(b, data.PermissionModel{DB: db})
+	})
+
+	b.Run("prepared", func(b *testing.B) {
+		statements := data.NewStatements(db)
+		defer statements.Close()
+
+		run(b, data.PermissionModel{DB: statements})
+	})
+}
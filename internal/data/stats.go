@@ -0,0 +1,41 @@
+package data
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+)
+
+// Define the MovieStatsModel type, which keeps per-movie counters such as the number
+// of views in the `movie_stats` table
+type MovieStatsModel struct {
+	DB Querier
+}
+
+// Add the given number of views to each movie in a single query. Views for movies which
+// have since been deleted are ignored.
+func (m MovieStatsModel) AddViews(ctx context.Context, views map[int64]int64) error {
+	if len(views) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(views))
+	counts := make([]int64, 0, len(views))
+
+	for id, count := range views {
+		ids = append(ids, id)
+		counts = append(counts, count)
+	}
+
+	query := `
+		INSERT INTO movie_stats (movie_id, views)
+		SELECT views.movie_id, views.count
+		FROM unnest($1::bigint[], $2::bigint[]) AS views (movie_id, count)
+		INNER JOIN movies ON movies.id = views.movie_id
+		ON CONFLICT (movie_id) DO UPDATE
+		SET views = movie_stats.views + EXCLUDED.views, updated_at = NOW()`
+
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(ids), pq.Array(counts))
+
+	return err
+}
@@ -4,11 +4,12 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/base32"
+	"errors"
 	"time"
 
 	"github.com/LuisBarroso37/Greenlight/internal/validator"
+	"github.com/lib/pq"
 )
 
 const (
@@ -17,15 +18,29 @@ const (
 	ScopePasswordReset  = "password-reset"
 )
 
+// The default lifetimes of activation and authentication tokens
+const (
+	DefaultActivationTokenTTL     = 3 * 24 * time.Hour
+	DefaultAuthenticationTokenTTL = 24 * time.Hour
+)
+
+// We'll return this from GetForToken() when the token exists but has expired, so that
+// clients can be told to ask for a new one
+var ErrTokenExpired = errors.New("token expired")
+
 // Define a Token struct to hold the data for an individual token. This includes the
 // plaintext and hashed versions of the token, associated user ID, expiry time and
-// scope.
+// scope. Authentication tokens can be restricted to some of the user's permissions,
+// which are returned to the client as the token's scopes; a nil Permissions means the
+// token carries all of them.
 type Token struct {
-	UserID    int64     `json:"-"`
-	PlainText string    `json:"token"`
-	Hash      []byte    `json:"-"`
-	Expiry    time.Time `json:"expiry"`
-	Scope     string    `json:"-"`
+	UserID         int64       `json:"-"`
+	PlainText      string      `json:"token"`
+	Hash           []byte      `json:"-"`
+	Expiry         time.Time   `json:"expiry"`
+	Scope          string      `json:"-"`
+	Permissions    Permissions `json:"scopes,omitempty"`
+	ImpersonatorID int64       `json:"-"` // The admin acting as the user, for impersonation tokens
 }
 
 func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
@@ -72,36 +87,63 @@ func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error
 
 // Check that the plaintext token has been provided and is exactly 26 bytes long
 func ValidateTokenPlainText(v *validator.Validator, tokenPlainText string) {
-	v.Check(tokenPlainText != "", "token", "must be provided")
-	v.Check(len(tokenPlainText) == 26, "token", "must be 26 bytes long")
+	v.Check(tokenPlainText != "", "token", "must be provided", validator.CodeRequired)
+	v.Check(len(tokenPlainText) == 26, "token", "must be 26 bytes long", validator.CodeWrongLength, validator.Params{"length": 26})
+}
+
+// Check the permissions requested for an authentication token, which must be some of
+// the permissions held by the user
+func ValidateTokenPermissions(v *validator.Validator, requested, held Permissions) {
+	ValidatePermissionSubset(v, "scopes", requested, held)
 }
 
 // Define the TokenModel type.
 type TokenModel struct {
-	DB *sql.DB
+	DB Querier
 }
 
 // The New() method is a shortcut which creates a new Token struct and then inserts the
 // data in the tokens table
-func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
+func (m TokenModel) New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
+	return m.NewWithPermissions(ctx, userID, ttl, scope, nil)
+}
+
+// The NewWithPermissions() method creates and inserts a new token which is restricted
+// to the given permissions
+func (m TokenModel) NewWithPermissions(ctx context.Context, userID int64, ttl time.Duration, scope string, permissions Permissions) (*Token, error) {
 	token, err := generateToken(userID, ttl, scope)
 	if err != nil {
 		return nil, err
 	}
 
-	err = m.Insert(token)
+	token.Permissions = permissions
+
+	err = m.Insert(ctx, token)
 
 	return token, err
 }
 
-// Insert() adds the data for a specific token to the tokens table
-func (m TokenModel) Insert(token *Token) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// The NewImpersonation() method creates and inserts a new authentication token for an
+// admin to act as the given user. Requests made with it are recorded as made by the
+// admin on behalf of the user.
+func (m TokenModel) NewImpersonation(ctx context.Context, userID, impersonatorID int64, ttl time.Duration) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+
+	token.ImpersonatorID = impersonatorID
 
+	err = m.Insert(ctx, token)
+
+	return token, err
+}
+
+// Insert() adds the data for a specific token to the tokens table
+func (m TokenModel) Insert(ctx context.Context, token *Token) error {
 	query := `
-	INSERT INTO tokens (user_id, hash, expiry, scope)
-	VALUES ($1, $2, $3, $4)`
+	INSERT INTO tokens (user_id, hash, expiry, scope, permissions, impersonator_id)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6::bigint, 0))`
 
 	_, err := m.DB.ExecContext(
 		ctx,
@@ -110,16 +152,15 @@ func (m TokenModel) Insert(token *Token) error {
 		token.Hash,
 		token.Expiry,
 		token.Scope,
+		pq.Array([]string(token.Permissions)),
+		token.ImpersonatorID,
 	)
 
 	return err
 }
 
 // DeleteAllForUser() deletes all tokens for a specific user and scope
-func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
+func (m TokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
 	query := `
 	DELETE FROM tokens
 	WHERE scope = $1 AND user_id = $2`
@@ -133,3 +174,19 @@ func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
 
 	return err
 }
+
+// Deletes up to limit tokens which expired before the given time, returning how many
+// were deleted. Expired tokens can't be used any more, but are kept for a while so that
+// clients presenting one are told it has expired rather than that it is invalid.
+func (m TokenModel) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+	DELETE FROM tokens
+	WHERE hash IN (SELECT hash FROM tokens WHERE expiry < $1 LIMIT $2)`
+
+	result, err := m.DB.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
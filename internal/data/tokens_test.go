@@ -0,0 +1,98 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/data/datatest"
+)
+
+func TestTokenModelDeleteAllForUser(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	alice := datatest.InsertUser(t, models, "alice@example.com", "pa55word1234")
+	bob := datatest.InsertUser(t, models, "bob@example.com", "pa55word1234")
+
+	activation, err := models.Token.New(ctx, alice.ID, time.Hour, data.ScopeActivation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authentication, err := models.Token.New(ctx, alice.ID, time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := models.Token.New(ctx, bob.ID, time.Hour, data.ScopeActivation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Token.DeleteAllForUser(ctx, data.ScopeActivation, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = models.User.GetForToken(ctx, data.ScopeActivation, activation.PlainText)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("got error %v for a deleted token; want ErrRecordNotFound", err)
+	}
+
+	// Tokens of other scopes and users are kept
+	_, err = models.User.GetForToken(ctx, data.ScopeAuthentication, authentication.PlainText)
+	if err != nil {
+		t.Errorf("got error %v for the user's token of another scope", err)
+	}
+
+	_, err = models.User.GetForToken(ctx, data.ScopeActivation, other.PlainText)
+	if err != nil {
+		t.Errorf("got error %v for another user's token", err)
+	}
+}
+
+func TestTokenModelDeleteExpired(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	user := datatest.InsertUser(t, models, "alice@example.com", "pa55word1234")
+
+	for i := 0; i < 3; i++ {
+		_, err := models.Token.New(ctx, user.ID, -time.Hour, data.ScopeAuthentication)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	valid, err := models.Token.New(ctx, user.ID, time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Expired tokens are deleted up to the limit at a time
+	deleted, err := models.Token.DeleteExpired(ctx, time.Now(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted != 2 {
+		t.Errorf("deleted %d tokens; want 2", deleted)
+	}
+
+	deleted, err = models.Token.DeleteExpired(ctx, time.Now(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted != 1 {
+		t.Errorf("deleted %d tokens; want 1", deleted)
+	}
+
+	_, err = models.User.GetForToken(ctx, data.ScopeAuthentication, valid.PlainText)
+	if err != nil {
+		t.Errorf("got error %v for a token which hasn't expired", err)
+	}
+}
@@ -0,0 +1,44 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// Define a TOSAcceptance struct to represent a user's acceptance of a version of the
+// terms of service
+type TOSAcceptance struct {
+	UserID     int64     `json:"-"`
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"accepted_at"`
+}
+
+// Define the TOSAcceptanceModel type
+type TOSAcceptanceModel struct {
+	DB Querier
+}
+
+// Record that a user accepted a version of the terms of service. Accepting a version
+// again keeps the time it was first accepted, which is set on the acceptance.
+func (m TOSAcceptanceModel) Accept(ctx context.Context, acceptance *TOSAcceptance) error {
+	// The no-op update makes the query return the existing row on a conflict
+	query := `
+        INSERT INTO tos_acceptances (user_id, version)
+        VALUES ($1, $2)
+        ON CONFLICT (user_id, version) DO UPDATE SET version = EXCLUDED.version
+        RETURNING accepted_at`
+
+	return m.DB.QueryRowContext(ctx, query, acceptance.UserID, acceptance.Version).Scan(&acceptance.AcceptedAt)
+}
+
+// Report whether a user has accepted a version of the terms of service
+func (m TOSAcceptanceModel) HasAccepted(ctx context.Context, userID int64, version string) (bool, error) {
+	query := `
+        SELECT EXISTS (SELECT 1 FROM tos_acceptances WHERE user_id = $1 AND version = $2)`
+
+	var accepted bool
+
+	err := m.DB.QueryRowContext(ctx, query, userID, version).Scan(&accepted)
+
+	return accepted, err
+}
@@ -0,0 +1,73 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Define a txContextKey type so that the key can't collide with keys from other
+// packages
+type txContextKey struct{}
+
+// Define a txState struct holding a context's transaction, along with the functions to
+// run once it has been committed
+type txState struct {
+	tx       *sql.Tx
+	mutex    sync.Mutex
+	onCommit []func()
+}
+
+// Return a copy of the context whose queries are run in the given transaction. This
+// lets a change saved through the models be committed together with other writes,
+// such as the domain event about it being added to the outbox, without the models
+// having to know about the transaction.
+func WithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, &txState{tx: tx})
+}
+
+// Return the transaction the context's queries are run in, if it has one
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	state, ok := ctx.Value(txContextKey{}).(*txState)
+	if !ok {
+		return nil, false
+	}
+
+	return state.tx, true
+}
+
+// OnCommit runs fn once the context's transaction has been committed, as reported by
+// Committed(), or straight away if the context has no transaction. It is used for side
+// effects of a change outside the database, such as updating caches, which mustn't
+// happen if the transaction is rolled back nor be seen before the change is.
+func OnCommit(ctx context.Context, fn func()) {
+	state, ok := ctx.Value(txContextKey{}).(*txState)
+	if !ok {
+		fn()
+		return
+	}
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	state.onCommit = append(state.onCommit, fn)
+}
+
+// Committed runs the functions passed to OnCommit() with the context, in order. Call it
+// once the context's transaction has been committed; if it is rolled back, the functions
+// are simply dropped.
+func Committed(ctx context.Context) {
+	state, ok := ctx.Value(txContextKey{}).(*txState)
+	if !ok {
+		return
+	}
+
+	state.mutex.Lock()
+	onCommit := state.onCommit
+	state.onCommit = nil
+	state.mutex.Unlock()
+
+	for _, fn := range onCommit {
+		fn()
+	}
+}
@@ -0,0 +1,113 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/cache"
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/data/datatest"
+)
+
+// The models' queries made with a context carrying a transaction must only be saved if
+// the transaction is committed
+func TestWithTx(t *testing.T) {
+	models, db := datatest.NewModels(t)
+	ctx := context.Background()
+
+	for _, commit := range []bool{false, true} {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		movie := &data.Movie{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, Status: data.MoviePublished}
+
+		err = models.Movie.Insert(data.WithTx(ctx, tx), movie)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = models.Movie.Get(ctx, movie.ID)
+
+		switch {
+		case commit && err != nil:
+			t.Errorf("got error %v for a movie inserted in a committed transaction", err)
+		case !commit && !errors.Is(err, data.ErrRecordNotFound):
+			t.Errorf("got error %v for a movie inserted in a rolled back transaction; want ErrRecordNotFound", err)
+		}
+	}
+}
+
+// The caching models must only cache a change made in a transaction once it has been
+// committed, and never one which was rolled back
+func TestWithTxCachedMovies(t *testing.T) {
+	models, db := datatest.NewModels(t)
+	ctx := context.Background()
+
+	movies := data.NewCoalescingMovieModel(data.NewCachedMovieModel(models.Movie, cache.NewMemory(100), time.Minute, func(err error) {
+		t.Error(err)
+	}), time.Minute)
+
+	movie := datatest.InsertMovie(t, models, "Moana")
+
+	// Fill the caches with the movie as it was inserted
+	_, err := movies.Get(ctx, movie.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, commit := range []bool{false, true} {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		txCtx := data.WithTx(ctx, tx)
+
+		update, err := movies.Get(txCtx, movie.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		update.Title = "Moana 2"
+
+		err = movies.Update(txCtx, update)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if commit {
+			err = tx.Commit()
+			data.Committed(txCtx)
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := movies.Get(ctx, movie.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch {
+		case commit && got.Title != "Moana 2":
+			t.Errorf("got title %q after committing the update; want %q", got.Title, "Moana 2")
+		case !commit && got.Title != "Moana":
+			t.Errorf("got title %q after rolling back the update; want %q", got.Title, "Moana")
+		}
+	}
+}
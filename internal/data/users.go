@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/LuisBarroso37/Greenlight/internal/validator"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -18,13 +19,40 @@ var AnonymousUser = &User{}
 
 // Define a User struct to represent an individual user
 type User struct {
-	ID        int64    `json:"id"`
-	CreatedAt string   `json:"created_at"`
-	Name      string   `json:"name"`
-	Email     string   `json:"email"`
-	Password  Password `json:"-"`
-	Activated bool     `json:"activated"`
-	Version   int      `json:"-"`
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Password  Password  `json:"-"`
+	Activated bool      `json:"activated"`
+	Version   int       `json:"-"`
+
+	// The permissions the authentication token or service account the user was fetched
+	// with is restricted to, or nil if the token carries all of the user's permissions
+	TokenPermissions Permissions `json:"-"`
+
+	// The ID of the guest session, for guests browsing without an account
+	GuestID string `json:"-"`
+
+	// The ID of the admin acting as the user, if the user was fetched with an
+	// impersonation token
+	ImpersonatorID int64 `json:"-"`
+}
+
+// Return a new guest user for the guest session with the given ID. Guests have no
+// account, so they only get the given permissions, through the token restricting them.
+func NewGuestUser(guestID string, permissions Permissions) *User {
+	return &User{
+		Activated:        true,
+		GuestID:          guestID,
+		TokenPermissions: permissions,
+	}
+}
+
+// Check whether the token the user was fetched with allows the given permission. The
+// user must still hold the permission themselves.
+func (u *User) TokenAllows(code string) bool {
+	return u.TokenPermissions == nil || u.TokenPermissions.Include(code)
 }
 
 // Create a custom password type which is a struct containing the plaintext and hashed
@@ -68,19 +96,19 @@ func (p *Password) Matches(plainTextPassword string) (bool, error) {
 }
 
 func ValidateEmail(v *validator.Validator, email string) {
-	v.Check(email != "", "email", "must be provided")
-	v.Check(validator.Matches(email, validator.EmailRegex), "email", "must be a valid email address")
+	v.Check(email != "", "email", "must be provided", validator.CodeRequired)
+	v.Check(validator.Matches(email, validator.EmailRegex), "email", "must be a valid email address", validator.CodeInvalidFormat, validator.Params{"format": "email"})
 }
 
 func ValidatePassword(v *validator.Validator, password string) {
-	v.Check(password != "", "password", "must be provided")
-	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
-	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
+	v.Check(password != "", "password", "must be provided", validator.CodeRequired)
+	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long", validator.CodeTooShort, validator.Params{"min": 8})
+	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long", validator.CodeTooLong, validator.Params{"max": 72})
 }
 
 func ValidateUser(v *validator.Validator, user *User) {
-	v.Check(user.Name != "", "name", "must be provided")
-	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
+	v.Check(user.Name != "", "name", "must be provided", validator.CodeRequired)
+	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long", validator.CodeTooLong, validator.Params{"max": 500})
 
 	ValidateEmail(v, user.Email)
 
@@ -100,17 +128,14 @@ func ValidateUser(v *validator.Validator, user *User) {
 
 // Create a UserModel struct which wraps the connection pool
 type UserModel struct {
-	DB *sql.DB
+	DB Querier
 }
 
 // Insert a new record in the database for the user. Note that the id, created_at and
 // version fields are all automatically generated by our database, so we use the
 // RETURNING clause to read them into the User struct after the insert, in the same way
 // that we did when creating a movie.
-func (m UserModel) Insert(user *User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
+func (m UserModel) Insert(ctx context.Context, user *User) error {
 	query := `
 		INSERT INTO users (name, email, password_hash, activated)
 		VALUES ($1, $2, $3, $4)
@@ -136,13 +161,44 @@ func (m UserModel) Insert(user *User) error {
 	return nil
 }
 
+// Retrieve the User details from the database based on the user's ID
+func (m UserModel) Get(ctx context.Context, id int64) (*User, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	var user User
+
+	query := `
+		SELECT id, created_at, name, email, password_hash, activated, version
+		FROM users
+		WHERE id = $1`
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
 // Retrieve the User details from the database based on the user's email address.
 // Because we have a UNIQUE constraint on the email column, this SQL query will only
 // return one record (or none at all, in which case we return a ErrRecordNotFound error).
-func (m UserModel) GetByEmail(email string) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
+func (m UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
 	var user User
 
 	query := `
@@ -176,10 +232,7 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 // when updating a movie. And we also check for a violation of the "users_email_key"
 // constraint when performing the update, just like we did when inserting the user
 // record originally.
-func (m UserModel) Update(user *User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
+func (m UserModel) Update(ctx context.Context, user *User) error {
 	query := `
 		UPDATE users
 		SET name = $1, email= $2, password_hash = $3, activated = $4, version = version + 1
@@ -211,10 +264,7 @@ func (m UserModel) Update(user *User) error {
 }
 
 // Fetch user linked to given token
-func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
+func (m UserModel) GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error) {
 	var user User
 
 	// Calculate the SHA-256 hash of the plaintext token provided by the client.
@@ -222,13 +272,16 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
 	query := `
-        SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+        SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version, tokens.permissions, COALESCE(tokens.impersonator_id, 0), tokens.expiry
         FROM users
         INNER JOIN tokens
         ON users.id = tokens.user_id
         WHERE tokens.hash = $1
-        AND tokens.scope = $2 
-        AND tokens.expiry > $3`
+        AND tokens.scope = $2`
+
+	// Expired tokens are still fetched, so that they can be reported as expired rather
+	// than as not found
+	var expiry time.Time
 
 	// Create a slice containing the query arguments. Notice how we use the [:] operator
 	// to get a slice containing the token hash, rather than passing in the array (which
@@ -238,7 +291,6 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 		query,
 		tokenHash[:],
 		tokenScope,
-		time.Now(),
 	).Scan(
 		&user.ID,
 		&user.CreatedAt,
@@ -247,6 +299,9 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 		&user.Password.hash,
 		&user.Activated,
 		&user.Version,
+		pq.Array((*[]string)(&user.TokenPermissions)),
+		&user.ImpersonatorID,
+		&expiry,
 	)
 	if err != nil {
 		switch {
@@ -257,10 +312,26 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 		}
 	}
 
+	if !expiry.After(time.Now()) {
+		return nil, ErrTokenExpired
+	}
+
 	return &user, nil
 }
 
-// Check if a User instance is the AnonymousUser
+// Check if a User instance is anonymous, which is either the AnonymousUser or a guest.
+// Guests are anonymous too, as they don't have an account.
 func (u *User) IsAnonymous() bool {
-	return u == AnonymousUser
+	return u == AnonymousUser || u.IsGuest()
+}
+
+// Check if a User instance was fetched with an impersonation token, so that the
+// requests are made by an admin acting as the user
+func (u *User) IsImpersonated() bool {
+	return u.ImpersonatorID != 0
+}
+
+// Check if a User instance is a guest with a guest session
+func (u *User) IsGuest() bool {
+	return u.GuestID != ""
 }
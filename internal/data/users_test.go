@@ -0,0 +1,160 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+	"github.com/LuisBarroso37/Greenlight/internal/data/datatest"
+)
+
+func TestUserModelInsertAndGetByEmail(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	user := datatest.InsertUser(t, models, "alice@example.com", "pa55word1234")
+
+	got, err := models.User.GetByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.ID != user.ID || got.Name != user.Name || !got.Activated {
+		t.Errorf("got user %d (%q, activated %t); want %d (%q, activated)", got.ID, got.Name, got.Activated, user.ID, user.Name)
+	}
+
+	matches, err := got.Password.Matches("pa55word1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !matches {
+		t.Error("the stored password hash doesn't match the password")
+	}
+
+	// Email addresses are case insensitive
+	_, err = models.User.GetByEmail(ctx, "ALICE@example.com")
+	if err != nil {
+		t.Errorf("got error %v fetching the user by their address in capitals", err)
+	}
+
+	_, err = models.User.GetByEmail(ctx, "bob@example.com")
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("got error %v for a missing user; want ErrRecordNotFound", err)
+	}
+}
+
+func TestUserModelDuplicateEmail(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	datatest.InsertUser(t, models, "alice@example.com", "pa55word1234")
+	bob := datatest.InsertUser(t, models, "bob@example.com", "pa55word1234")
+
+	duplicate := &data.User{Name: "Alice Again", Email: "alice@example.com"}
+
+	err := duplicate.Password.Set("pa55word1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.User.Insert(ctx, duplicate)
+	if !errors.Is(err, data.ErrDuplicateEmail) {
+		t.Errorf("got error %v inserting a duplicate address; want ErrDuplicateEmail", err)
+	}
+
+	bob.Email = "alice@example.com"
+
+	err = models.User.Update(ctx, bob)
+	if !errors.Is(err, data.ErrDuplicateEmail) {
+		t.Errorf("got error %v changing to a taken address; want ErrDuplicateEmail", err)
+	}
+}
+
+// Of several concurrent updates to the same version of a user, exactly one must succeed
+// and the others must be reported as edit conflicts
+func TestUserModelConcurrentUpdates(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	user := datatest.InsertUser(t, models, "alice@example.com", "pa55word1234")
+
+	errs := datatest.Concurrently(10, func(i int) error {
+		update := *user
+		update.Name = "Alice " + string(rune('A'+i))
+
+		return models.User.Update(ctx, &update)
+	})
+
+	var succeeded int
+
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, data.ErrEditConflict):
+			t.Errorf("got unexpected error %v", err)
+		}
+	}
+
+	if succeeded != 1 {
+		t.Errorf("%d concurrent updates succeeded; want 1", succeeded)
+	}
+}
+
+func TestUserModelGetForToken(t *testing.T) {
+	models, _ := datatest.NewModels(t)
+	ctx := context.Background()
+
+	user := datatest.InsertUser(t, models, "alice@example.com", "pa55word1234")
+
+	token, err := models.Token.New(ctx, user.ID, time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := models.User.GetForToken(ctx, data.ScopeAuthentication, token.PlainText)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.ID != user.ID {
+		t.Errorf("got user %d; want %d", got.ID, user.ID)
+	}
+
+	if got.TokenPermissions != nil {
+		t.Errorf("got token permissions %v for an unrestricted token; want nil", got.TokenPermissions)
+	}
+
+	// A token is only accepted for its own scope
+	_, err = models.User.GetForToken(ctx, data.ScopeActivation, token.PlainText)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("got error %v for a token of another scope; want ErrRecordNotFound", err)
+	}
+
+	scoped, err := models.Token.NewWithPermissions(ctx, user.ID, time.Hour, data.ScopeAuthentication, data.Permissions{"movies:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = models.User.GetForToken(ctx, data.ScopeAuthentication, scoped.PlainText)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.TokenAllows("movies:read") || got.TokenAllows("movies:write") {
+		t.Errorf("got token permissions %v; want only movies:read", got.TokenPermissions)
+	}
+
+	expired, err := models.Token.New(ctx, user.ID, -time.Minute, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = models.User.GetForToken(ctx, data.ScopeAuthentication, expired.PlainText)
+	if !errors.Is(err, data.ErrTokenExpired) {
+		t.Errorf("got error %v for an expired token; want ErrTokenExpired", err)
+	}
+}
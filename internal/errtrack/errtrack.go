@@ -0,0 +1,274 @@
+// Package errtrack reports errors, along with their stack traces and the context of
+// the request which caused them, to an error tracking service such as Sentry.
+package errtrack
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Define a Frame struct holding a single frame of a stack trace
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Define a Request struct holding the details of the HTTP request during which an
+// error occurred
+type Request struct {
+	Method   string
+	URL      string
+	Headers  map[string]string
+	RemoteIP string
+}
+
+// Define a Context struct holding what is known about the circumstances of an error.
+// All of the fields are optional.
+type Context struct {
+	Request   *http.Request
+	RequestID string
+	UserID    int64
+}
+
+// Define an Event struct holding everything that is reported about an error
+type Event struct {
+	Message   string
+	Type      string
+	Level     string
+	Timestamp time.Time
+	Stack     []Frame
+	Request   *Request
+	UserID    int64
+	Tags      map[string]string
+}
+
+// Define a Reporter interface for sending events to an error tracking service. It is
+// called from a single background goroutine, so implementations don't need to be safe
+// for concurrent use.
+type Reporter interface {
+	Report(ctx context.Context, event *Event) error
+}
+
+// Define an Options struct holding the settings of a Tracker
+type Options struct {
+	// The fraction of events which are reported, between 0 and 1
+	SampleRate float64
+
+	// Remove personal data, such as client IP addresses (including those in proxy
+	// headers), query string values and email addresses in error messages, before events
+	// are reported
+	ScrubPII bool
+
+	// Tags added to every event, such as the environment and release
+	Tags map[string]string
+
+	// Called when an event can't be reported
+	OnError func(error)
+}
+
+// Headers which can carry credentials and are never reported
+var sensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"X-Client-Key":  true,
+	"X-Signature":   true,
+}
+
+// Headers which carry the client's IP address, set by proxies and load balancers. They
+// are left out when personal data is scrubbed.
+var clientIPHeaders = map[string]bool{
+	"Forwarded":       true,
+	"X-Forwarded-For": true,
+	"X-Real-Ip":       true,
+}
+
+// Match email addresses in error messages
+var emailAddress = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// Define a Tracker type which captures errors and sends them to a Reporter from a
+// background goroutine, so that reporting never slows down a request. Events are
+// dropped if the queue is full. A Tracker with a nil Reporter discards every event.
+type Tracker struct {
+	reporter Reporter
+	options  Options
+	queue    chan *Event
+	wg       sync.WaitGroup
+}
+
+// Return a new Tracker which sends events to the given reporter
+func New(reporter Reporter, options Options) *Tracker {
+	t := &Tracker{
+		reporter: reporter,
+		options:  options,
+		queue:    make(chan *Event, 100),
+	}
+
+	if reporter == nil {
+		return t
+	}
+
+	t.wg.Add(1)
+
+	go func() {
+		defer t.wg.Done()
+
+		for event := range t.queue {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			err := t.reporter.Report(ctx, event)
+			cancel()
+
+			if err != nil && t.options.OnError != nil {
+				t.options.OnError(err)
+			}
+		}
+	}()
+
+	return t
+}
+
+// Capture reports an error along with its context. The skip argument is the number of
+// stack frames to leave out above the caller, so that helpers which call Capture can
+// be left out of the stack trace.
+func (t *Tracker) Capture(err error, c Context, skip int) {
+	if t.reporter == nil || rand.Float64() >= t.options.SampleRate {
+		return
+	}
+
+	event := &Event{
+		Message:   err.Error(),
+		Type:      errorType(err),
+		Level:     "error",
+		Timestamp: time.Now().UTC(),
+		Stack:     stack(skip + 1),
+		UserID:    c.UserID,
+		Tags:      make(map[string]string, len(t.options.Tags)+1),
+	}
+
+	for key, value := range t.options.Tags {
+		event.Tags[key] = value
+	}
+
+	if c.RequestID != "" {
+		event.Tags["request_id"] = c.RequestID
+	}
+
+	if c.Request != nil {
+		event.Request = t.request(c.Request)
+	}
+
+	if t.options.ScrubPII {
+		event.Message = emailAddress.ReplaceAllString(event.Message, "[email]")
+	}
+
+	select {
+	case t.queue <- event:
+	default:
+	}
+}
+
+// Close stops accepting events and waits up to the given timeout for the queued ones
+// to be reported
+func (t *Tracker) Close(timeout time.Duration) {
+	close(t.queue)
+
+	done := make(chan struct{})
+
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// Return the details of a request, leaving out credentials and (if scrubbing is
+// enabled) personal data
+func (t *Tracker) request(r *http.Request) *Request {
+	req := &Request{
+		Method:  r.Method,
+		Headers: make(map[string]string),
+	}
+
+	u := *r.URL
+	u.Host = r.Host
+
+	if r.TLS != nil {
+		u.Scheme = "https"
+	} else {
+		u.Scheme = "http"
+	}
+
+	if t.options.ScrubPII && u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query.Set(key, "[filtered]")
+		}
+
+		u.RawQuery = query.Encode()
+	}
+
+	req.URL = u.String()
+
+	for name, values := range r.Header {
+		if sensitiveHeaders[name] || (t.options.ScrubPII && clientIPHeaders[name]) {
+			continue
+		}
+
+		req.Headers[name] = strings.Join(values, ", ")
+	}
+
+	if !t.options.ScrubPII {
+		req.RemoteIP = r.RemoteAddr
+	}
+
+	return req
+}
+
+// Return the stack trace of the caller, innermost frame first, skipping the given
+// number of frames above the caller of stack()
+func stack(skip int) []Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []Frame
+
+	for {
+		frame, more := frames.Next()
+
+		// Leave out the runtime's own frames, such as the panic machinery
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			stack = append(stack, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+
+		if !more {
+			return stack
+		}
+	}
+}
+
+// Return the type name of the innermost wrapped error, which is used to group events
+func errorType(err error) string {
+	for {
+		unwrapper, ok := err.(interface{ Unwrap() error })
+		if !ok || unwrapper.Unwrap() == nil {
+			break
+		}
+
+		err = unwrapper.Unwrap()
+	}
+
+	return strings.TrimPrefix(reflect.TypeOf(err).String(), "*")
+}
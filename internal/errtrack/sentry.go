@@ -0,0 +1,143 @@
+package errtrack
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// We'll return this from NewSentryReporter() when the DSN isn't in the expected format
+var ErrInvalidDSN = errors.New("invalid sentry DSN")
+
+// Define a SentryReporter type which sends events to Sentry using its HTTP store API
+type SentryReporter struct {
+	endpoint  string
+	publicKey string
+	client    *http.Client
+}
+
+// Return a new SentryReporter for the given DSN, which is in the format
+// "https://<public key>@<host>/<project id>"
+func NewSentryReporter(dsn string) (*SentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return nil, ErrInvalidDSN
+	}
+
+	// The project ID is the last segment of the path. Anything before it is a path
+	// prefix for self-hosted installations.
+	path := strings.TrimSuffix(u.Path, "/")
+
+	slash := strings.LastIndex(path, "/")
+	if slash < 0 || path[slash+1:] == "" {
+		return nil, ErrInvalidDSN
+	}
+
+	prefix, projectID := path[:slash], path[slash+1:]
+
+	return &SentryReporter{
+		endpoint:  fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, projectID),
+		publicKey: u.User.Username(),
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Report sends an event to Sentry
+func (s *SentryReporter) Report(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(sentryEvent(event))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=greenlight/1.0, sentry_key=%s", s.publicKey))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("sentry returned unexpected status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Convert an event to the JSON structure expected by Sentry. Sentry expects stack
+// frames with the outermost call first, which is the reverse of our order.
+func sentryEvent(event *Event) map[string]interface{} {
+	frames := make([]map[string]interface{}, len(event.Stack))
+	for i, frame := range event.Stack {
+		frames[len(frames)-1-i] = map[string]interface{}{
+			"function": frame.Function,
+			"abs_path": frame.File,
+			"lineno":   frame.Line,
+		}
+	}
+
+	out := map[string]interface{}{
+		"event_id":  eventID(),
+		"timestamp": event.Timestamp.Format(time.RFC3339),
+		"level":     event.Level,
+		"platform":  "go",
+		"exception": map[string]interface{}{
+			"values": []map[string]interface{}{
+				{
+					"type":       event.Type,
+					"value":      event.Message,
+					"stacktrace": map[string]interface{}{"frames": frames},
+				},
+			},
+		},
+		"tags": event.Tags,
+	}
+
+	if event.Request != nil {
+		out["request"] = map[string]interface{}{
+			"method":  event.Request.Method,
+			"url":     event.Request.URL,
+			"headers": event.Request.Headers,
+		}
+	}
+
+	user := map[string]interface{}{}
+
+	if event.UserID != 0 {
+		user["id"] = strconv.FormatInt(event.UserID, 10)
+	}
+
+	if event.Request != nil && event.Request.RemoteIP != "" {
+		user["ip_address"] = event.Request.RemoteIP
+	}
+
+	if len(user) > 0 {
+		out["user"] = user
+	}
+
+	return out
+}
+
+// Return a random event ID, which Sentry expects to be 32 hex characters
+func eventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,350 @@
+// Package events publishes domain events, such as a movie being created or a user
+// being activated, to a message broker so that other services (like analytics or a
+// search indexer) can follow changes without polling the API. Events are first written
+// to an outbox table in PostgreSQL and then relayed to the broker in order by a
+// background goroutine, so that requests don't wait for the broker and events aren't
+// lost while it is unavailable.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Define an Event struct holding a single domain event. Type is in the format
+// "<aggregate>.<action>", such as "movie.created", and AggregateID is the ID of the
+// record the event is about.
+type Event struct {
+	ID          string      `json:"id"`
+	Type        string      `json:"type"`
+	AggregateID int64       `json:"aggregate_id"`
+	OccurredAt  time.Time   `json:"occurred_at"`
+	RequestID   string      `json:"request_id,omitempty"`
+	Data        interface{} `json:"data"`
+}
+
+// Return the aggregate part of the event type, e.g. "movie" for "movie.created"
+func (e Event) Aggregate() string {
+	for i := 0; i < len(e.Type); i++ {
+		if e.Type[i] == '.' {
+			return e.Type[:i]
+		}
+	}
+
+	return e.Type
+}
+
+// Define a Message struct holding an encoded event ready to be sent to a broker. Events
+// about the same record share a key, which brokers that partition topics (like Kafka)
+// use to keep them in order.
+type Message struct {
+	Topic string
+	Key   string
+	Value []byte
+}
+
+// Define a Publisher interface for sending messages to a message broker. It is called
+// from a single background goroutine, so implementations don't need to be safe for
+// concurrent use. Publish() must only return once the broker has accepted the message.
+type Publisher interface {
+	Publish(ctx context.Context, message Message) error
+	Close() error
+}
+
+// Define an Options struct holding the settings of an Outbox
+type Options struct {
+	// Prepended to the aggregate of each event to give the topic (or subject) it is
+	// published to, e.g. "greenlight." publishes movie events to "greenlight.movie"
+	TopicPrefix string
+
+	// How often the outbox is checked for events added by another instance, or which
+	// couldn't be published before
+	PollInterval time.Duration
+
+	// The maximum number of events relayed in one transaction
+	BatchSize int
+
+	// Called with the details of every failed relay. Its signature matches the logger's
+	// PrintError() method.
+	OnError func(err error, properties map[string]string)
+}
+
+// Define an Outbox type which stores events in the outbox_events table and relays them
+// to a Publisher
+type Outbox struct {
+	db        *sql.DB
+	publisher Publisher
+	options   Options
+	wake      chan struct{}
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// Return a new Outbox which stores its events in the given database and relays them to
+// the publisher
+func New(db *sql.DB, publisher Publisher, options Options) *Outbox {
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+
+	if options.BatchSize < 1 {
+		options.BatchSize = 100
+	}
+
+	if options.OnError == nil {
+		options.OnError = func(error, map[string]string) {}
+	}
+
+	return &Outbox{
+		db:        db,
+		publisher: publisher,
+		options:   options,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Add stores an event in the outbox to be published. The ID and the time the event
+// occurred are filled in if they aren't set.
+func (o *Outbox) Add(ctx context.Context, event Event) error {
+	err := o.insert(ctx, o.db, event)
+	if err != nil {
+		return err
+	}
+
+	o.Wake()
+
+	return nil
+}
+
+// AddTx stores an event in the outbox as part of the given transaction, so that the
+// event is only published if the change it is about is committed along with it. Call
+// Wake() once the transaction is committed, or the event waits for the next poll.
+func (o *Outbox) AddTx(ctx context.Context, tx *sql.Tx, event Event) error {
+	return o.insert(ctx, tx, event)
+}
+
+// Wake lets the relay know that there are events, without waiting for it to poll
+func (o *Outbox) Wake() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Define an execer interface satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Insert an event using the given database handle, which may be a transaction
+func (o *Outbox) insert(ctx context.Context, db execer, event Event) error {
+	if event.ID == "" {
+		b := make([]byte, 16)
+
+		_, err := rand.Read(b)
+		if err != nil {
+			return err
+		}
+
+		event.ID = hex.EncodeToString(b)
+	}
+
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	js, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO outbox_events (topic, key, payload)
+        VALUES ($1, $2, $3)`
+
+	_, err = db.ExecContext(ctx, query, o.options.TopicPrefix+event.Aggregate(), strconv.FormatInt(event.AggregateID, 10), js)
+
+	return err
+}
+
+// Start launches the relay
+func (o *Outbox) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel = cancel
+
+	o.wg.Add(1)
+
+	go func() {
+		defer o.wg.Done()
+		o.relay(ctx)
+	}()
+}
+
+// Shutdown stops the relay, waiting for the batch it is publishing to be finished or
+// for the context to be cancelled, and closes the publisher. Events which haven't been
+// published are kept in the outbox for the next start.
+func (o *Outbox) Shutdown(ctx context.Context) error {
+	if o.cancel == nil {
+		return o.publisher.Close()
+	}
+
+	o.cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return o.publisher.Close()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Relay batches of events until the context is cancelled, waiting for a wake up or the
+// poll interval whenever the outbox is empty or the broker can't be reached
+func (o *Outbox) relay(ctx context.Context) {
+	for {
+		relayed, err := o.relayBatch(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			o.options.OnError(err, map[string]string{"component": "events"})
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if relayed && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		case <-time.After(o.options.PollInterval):
+		}
+	}
+}
+
+// Publish the oldest events in the outbox, removing each one once the broker has
+// accepted it, and report whether there were any. A transaction-level advisory lock
+// makes sure that only one instance relays at a time, so that events are published in
+// the order they were added. If publishing fails the events published so far are still
+// removed, and the rest are retried on the next run.
+func (o *Outbox) relayBatch(ctx context.Context) (bool, error) {
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// Rollback() is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	var locked bool
+
+	err = tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock(hashtext('outbox_events'))`).Scan(&locked)
+	if err != nil || !locked {
+		return false, err
+	}
+
+	query := `
+        SELECT id, topic, key, payload
+        FROM outbox_events
+        ORDER BY id
+        LIMIT $1`
+
+	rows, err := tx.QueryContext(ctx, query, o.options.BatchSize)
+	if err != nil {
+		return false, err
+	}
+
+	var ids []int64
+	var messages []Message
+
+	for rows.Next() {
+		var id int64
+		var message Message
+
+		err = rows.Scan(&id, &message.Topic, &message.Key, &message.Value)
+		if err != nil {
+			rows.Close()
+			return false, err
+		}
+
+		ids = append(ids, id)
+		messages = append(messages, message)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+
+	if len(messages) == 0 {
+		return false, nil
+	}
+
+	published := 0
+
+	var publishErr error
+
+	for _, message := range messages {
+		publishErr = o.publisher.Publish(ctx, message)
+		if publishErr != nil {
+			break
+		}
+
+		published++
+	}
+
+	if published > 0 {
+		_, err = tx.ExecContext(ctx, `DELETE FROM outbox_events WHERE id = ANY($1)`, pq.Array(ids[:published]))
+		if err != nil {
+			return true, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return true, err
+		}
+	}
+
+	return true, publishErr
+}
+
+// Define a Stats struct holding the number of events waiting in the outbox
+type Stats struct {
+	Pending int `json:"pending"`
+
+	// How long the oldest event has been waiting, in seconds. A growing value means
+	// that the broker can't be reached.
+	OldestPendingSeconds float64 `json:"oldest_pending_seconds"`
+}
+
+// Stats counts the events waiting in the outbox
+func (o *Outbox) Stats(ctx context.Context) (Stats, error) {
+	query := `
+        SELECT COUNT(*), COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(created_at)), 0)
+        FROM outbox_events`
+
+	var stats Stats
+
+	err := o.db.QueryRowContext(ctx, query).Scan(&stats.Pending, &stats.OldestPendingSeconds)
+
+	return stats, err
+}
@@ -0,0 +1,539 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// The Kafka API keys and versions of the requests we send
+const (
+	kafkaProduceKey      = 0
+	kafkaProduceVersion  = 3
+	kafkaMetadataKey     = 3
+	kafkaMetadataVersion = 1
+)
+
+// How long partition leaders are remembered before being looked up again. They are also
+// looked up again whenever a produce request fails.
+const kafkaMetadataTTL = 5 * time.Minute
+
+// We'll return this when a response from a broker can't be decoded
+var errKafkaMalformed = errors.New("kafka: malformed response")
+
+// The CRC of record batches uses the Castagnoli polynomial
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Define a Kafka type which is a minimal producer for Kafka. It speaks the Kafka wire
+// protocol directly: the leader of each partition is looked up with a Metadata request,
+// and each message is sent to the leader of its partition in a batch of its own with a
+// Produce request, waiting for all in-sync replicas to acknowledge it. Messages are
+// assigned to partitions by hashing their key in the same way as the Java client, so
+// that events about the same record stay in order.
+type Kafka struct {
+	brokers       []string
+	clientID      string
+	timeout       time.Duration
+	correlationID int32
+	conns         map[string]*kafkaConn
+	topics        map[string]*kafkaTopic
+}
+
+// Define a kafkaConn struct which pairs a connection to a broker with a buffered reader
+// for its responses
+type kafkaConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Define a kafkaTopic struct holding the address of the leader of each partition of a
+// topic, indexed by partition
+type kafkaTopic struct {
+	leaders   []string
+	fetchedAt time.Time
+}
+
+// Return a new Kafka producer which finds the cluster through the given bootstrap
+// brokers, each in the format "host:port"
+func NewKafka(brokers []string) (*Kafka, error) {
+	if len(brokers) == 0 {
+		return nil, errors.New("kafka: no brokers")
+	}
+
+	for _, broker := range brokers {
+		_, _, err := net.SplitHostPort(broker)
+		if err != nil {
+			return nil, fmt.Errorf("kafka: invalid broker address %q", broker)
+		}
+	}
+
+	return &Kafka{
+		brokers:  brokers,
+		clientID: "greenlight",
+		timeout:  10 * time.Second,
+		conns:    make(map[string]*kafkaConn),
+		topics:   make(map[string]*kafkaTopic),
+	}, nil
+}
+
+// Publish sends a message to the leader of its partition. Partition leaders are looked
+// up again on the next publish if it fails, in case leadership has moved.
+func (k *Kafka) Publish(ctx context.Context, message Message) error {
+	topic, err := k.topic(ctx, message.Topic)
+	if err != nil {
+		return err
+	}
+
+	partition := int32(murmur2([]byte(message.Key))&0x7fffffff) % int32(len(topic.leaders))
+	leader := topic.leaders[partition]
+
+	if leader == "" {
+		delete(k.topics, message.Topic)
+		return fmt.Errorf("kafka: no leader for partition %d of topic %q", partition, message.Topic)
+	}
+
+	err = k.produce(ctx, leader, message, partition)
+	if err != nil {
+		delete(k.topics, message.Topic)
+		return err
+	}
+
+	return nil
+}
+
+// Close closes the connections to the brokers
+func (k *Kafka) Close() error {
+	var firstErr error
+
+	for addr, conn := range k.conns {
+		err := conn.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		delete(k.conns, addr)
+	}
+
+	return firstErr
+}
+
+// Return the partition leaders of a topic, looking them up if they aren't known or are
+// too old
+func (k *Kafka) topic(ctx context.Context, name string) (*kafkaTopic, error) {
+	if topic, ok := k.topics[name]; ok && time.Since(topic.fetchedAt) < kafkaMetadataTTL {
+		return topic, nil
+	}
+
+	var lastErr error
+
+	// Ask each bootstrap broker in turn, until one answers
+	for _, broker := range k.brokers {
+		topic, err := k.metadata(ctx, broker, name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		k.topics[name] = topic
+
+		return topic, nil
+	}
+
+	return nil, lastErr
+}
+
+// Send a Metadata request for a topic to a broker and return its partition leaders
+func (k *Kafka) metadata(ctx context.Context, broker, name string) (*kafkaTopic, error) {
+	var body bytes.Buffer
+
+	writeInt32(&body, 1)
+	writeString(&body, name)
+
+	d, err := k.request(ctx, broker, kafkaMetadataKey, kafkaMetadataVersion, body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	brokers := make(map[int32]string)
+
+	for i, n := 0, d.int32(); i < int(n) && d.err == nil; i++ {
+		nodeID := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+
+		brokers[nodeID] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+
+	d.int32() // controller ID
+
+	topic := &kafkaTopic{fetchedAt: time.Now()}
+
+	for i, n := 0, d.int32(); i < int(n) && d.err == nil; i++ {
+		errorCode := d.int16()
+		topicName := d.string()
+		d.int8() // is internal
+
+		if errorCode != 0 {
+			return nil, fmt.Errorf("kafka: metadata for topic %q failed with error code %d", topicName, errorCode)
+		}
+
+		for j, m := 0, d.int32(); j < int(m) && d.err == nil; j++ {
+			d.int16() // partition error code
+			partition := d.int32()
+			leader := d.int32()
+			d.int32Array() // replicas
+			d.int32Array() // in-sync replicas
+
+			if partition < 0 || partition >= 1<<16 {
+				return nil, errKafkaMalformed
+			}
+
+			for int(partition) >= len(topic.leaders) {
+				topic.leaders = append(topic.leaders, "")
+			}
+
+			topic.leaders[partition] = brokers[leader]
+		}
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	if len(topic.leaders) == 0 {
+		return nil, fmt.Errorf("kafka: topic %q has no partitions", name)
+	}
+
+	return topic, nil
+}
+
+// Send a Produce request for a single message to the leader of its partition
+func (k *Kafka) produce(ctx context.Context, leader string, message Message, partition int32) error {
+	var body bytes.Buffer
+
+	writeInt16(&body, -1) // no transactional ID
+	writeInt16(&body, -1) // wait for all in-sync replicas
+	writeInt32(&body, int32(k.timeout/time.Millisecond))
+	writeInt32(&body, 1)
+	writeString(&body, message.Topic)
+	writeInt32(&body, 1)
+	writeInt32(&body, partition)
+
+	batch := recordBatch([]byte(message.Key), message.Value, time.Now())
+	writeInt32(&body, int32(len(batch)))
+	body.Write(batch)
+
+	d, err := k.request(ctx, leader, kafkaProduceKey, kafkaProduceVersion, body.Bytes())
+	if err != nil {
+		return err
+	}
+
+	for i, n := 0, d.int32(); i < int(n) && d.err == nil; i++ {
+		d.string() // topic
+
+		for j, m := 0, d.int32(); j < int(m) && d.err == nil; j++ {
+			d.int32() // partition
+			errorCode := d.int16()
+			d.int64() // base offset
+			d.int64() // log append time
+
+			if d.err == nil && errorCode != 0 {
+				return fmt.Errorf("kafka: produce to topic %q failed with error code %d", message.Topic, errorCode)
+			}
+		}
+	}
+
+	return d.err
+}
+
+// Send a request to a broker and return a decoder for the body of its response. The
+// connection is closed if anything goes wrong, so that the next request opens a new one.
+func (k *Kafka) request(ctx context.Context, addr string, apiKey, version int16, body []byte) (*kafkaDecoder, error) {
+	conn, err := k.conn(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := k.roundTrip(ctx, conn, apiKey, version, body)
+	if err != nil {
+		conn.Close()
+		delete(k.conns, addr)
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Write a request to the connection and read its response
+func (k *Kafka) roundTrip(ctx context.Context, conn *kafkaConn, apiKey, version int16, body []byte) (*kafkaDecoder, error) {
+	deadline := time.Now().Add(k.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	conn.SetDeadline(deadline)
+
+	k.correlationID++
+
+	var header bytes.Buffer
+
+	writeInt16(&header, apiKey)
+	writeInt16(&header, version)
+	writeInt32(&header, k.correlationID)
+	writeString(&header, k.clientID)
+
+	var request bytes.Buffer
+
+	writeInt32(&request, int32(header.Len()+len(body)))
+	request.Write(header.Bytes())
+	request.Write(body)
+
+	_, err := conn.Write(request.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	var size int32
+
+	err = binary.Read(conn.reader, binary.BigEndian, &size)
+	if err != nil {
+		return nil, err
+	}
+
+	if size < 4 || size > 64<<20 {
+		return nil, errKafkaMalformed
+	}
+
+	response := make([]byte, size)
+
+	_, err = io.ReadFull(conn.reader, response)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &kafkaDecoder{b: response}
+
+	if d.int32() != k.correlationID {
+		return nil, errKafkaMalformed
+	}
+
+	return d, nil
+}
+
+// Return the open connection to a broker, or open a new one
+func (k *Kafka) conn(ctx context.Context, addr string) (*kafkaConn, error) {
+	if conn, ok := k.conns[addr]; ok {
+		return conn, nil
+	}
+
+	dialer := net.Dialer{Timeout: k.timeout}
+
+	c, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &kafkaConn{Conn: c, reader: bufio.NewReader(c)}
+	k.conns[addr] = conn
+
+	return conn, nil
+}
+
+// Encode a message as a record batch (magic version 2) holding a single record
+func recordBatch(key, value []byte, timestamp time.Time) []byte {
+	var record bytes.Buffer
+
+	record.WriteByte(0)     // attributes
+	writeVarint(&record, 0) // timestamp delta
+	writeVarint(&record, 0) // offset delta
+
+	if len(key) == 0 {
+		writeVarint(&record, -1)
+	} else {
+		writeVarint(&record, int64(len(key)))
+		record.Write(key)
+	}
+
+	writeVarint(&record, int64(len(value)))
+	record.Write(value)
+	writeVarint(&record, 0) // headers
+
+	// The CRC covers everything from the attributes to the end of the records
+	var crcd bytes.Buffer
+
+	millis := timestamp.UnixNano() / int64(time.Millisecond)
+
+	writeInt16(&crcd, 0) // attributes
+	writeInt32(&crcd, 0) // last offset delta
+	writeInt64(&crcd, millis)
+	writeInt64(&crcd, millis)
+	writeInt64(&crcd, -1) // producer ID
+	writeInt16(&crcd, -1) // producer epoch
+	writeInt32(&crcd, -1) // base sequence
+	writeInt32(&crcd, 1)  // number of records
+	writeVarint(&crcd, int64(record.Len()))
+	crcd.Write(record.Bytes())
+
+	var batch bytes.Buffer
+
+	writeInt64(&batch, 0) // base offset
+	writeInt32(&batch, int32(4+1+4+crcd.Len()))
+	writeInt32(&batch, -1) // partition leader epoch
+	batch.WriteByte(2)     // magic
+	binary.Write(&batch, binary.BigEndian, crc32.Checksum(crcd.Bytes(), castagnoli))
+	batch.Write(crcd.Bytes())
+
+	return batch.Bytes()
+}
+
+// Return the murmur2 hash of a key, as computed by the Java client's default
+// partitioner
+func murmur2(data []byte) int32 {
+	const m = 0x5bd1e995
+	const r = 24
+
+	length := len(data)
+	h := uint32(0x9747b28c) ^ uint32(length)
+
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := data[length&^3:]
+
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+
+	return int32(h)
+}
+
+func writeInt16(b *bytes.Buffer, v int16) {
+	binary.Write(b, binary.BigEndian, v)
+}
+
+func writeInt32(b *bytes.Buffer, v int32) {
+	binary.Write(b, binary.BigEndian, v)
+}
+
+func writeInt64(b *bytes.Buffer, v int64) {
+	binary.Write(b, binary.BigEndian, v)
+}
+
+func writeString(b *bytes.Buffer, s string) {
+	writeInt16(b, int16(len(s)))
+	b.WriteString(s)
+}
+
+func writeVarint(b *bytes.Buffer, v int64) {
+	var buf [binary.MaxVarintLen64]byte
+
+	n := binary.PutVarint(buf[:], v)
+	b.Write(buf[:n])
+}
+
+// Define a kafkaDecoder type which reads big-endian values from a response. The first
+// read past the end of the response sets err, after which every read returns zero.
+type kafkaDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *kafkaDecoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+
+	if n < 0 || len(d.b) < n {
+		d.err = errKafkaMalformed
+		return nil
+	}
+
+	v := d.b[:n]
+	d.b = d.b[n:]
+
+	return v
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.read(1); b != nil {
+		return int8(b[0])
+	}
+
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.read(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.read(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.read(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+
+	return 0
+}
+
+// Read a string, returning an empty string for a null one
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+
+	return string(d.read(int(n)))
+}
+
+func (d *kafkaDecoder) int32Array() []int32 {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+
+	var values []int32
+
+	for i := 0; i < int(n) && d.err == nil; i++ {
+		values = append(values, d.int32())
+	}
+
+	return values
+}
@@ -0,0 +1,219 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// We'll return this from NewNATS() when the URL isn't in the expected format
+var ErrInvalidNATSURL = errors.New("invalid NATS URL")
+
+// Define a NATS type which is a minimal client for publishing to a NATS server. It
+// speaks the NATS text protocol directly over a single connection, which is opened on
+// the first publish and reopened after an error.
+type NATS struct {
+	addr     string
+	user     string
+	password string
+	token    string
+	timeout  time.Duration
+	conn     net.Conn
+	reader   *bufio.Reader
+}
+
+// Return a new NATS client for the server at the given URL, in the format
+// "nats://[user:password@]host[:port]" or "nats://token@host[:port]"
+func NewNATS(rawURL string) (*NATS, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "nats" || u.Hostname() == "" {
+		return nil, ErrInvalidNATSURL
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	n := &NATS{
+		addr:    addr,
+		timeout: 5 * time.Second,
+	}
+
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			n.user = u.User.Username()
+			n.password = password
+		} else {
+			n.token = u.User.Username()
+		}
+	}
+
+	return n, nil
+}
+
+// Publish sends a message to the subject named after its topic. A PING is sent after
+// the message and the server's PONG is awaited, which guarantees that the server has
+// processed the message (or has reported an error for it) before returning.
+func (n *NATS) Publish(ctx context.Context, message Message) error {
+	if strings.ContainsAny(message.Topic, " \t\r\n") || message.Topic == "" {
+		return fmt.Errorf("invalid NATS subject %q", message.Topic)
+	}
+
+	if n.conn == nil {
+		err := n.connect(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := n.publish(ctx, message)
+	if err != nil {
+		n.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Send the message and wait for the PONG, answering any PINGs from the server
+func (n *NATS) publish(ctx context.Context, message Message) error {
+	deadline := time.Now().Add(n.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	n.conn.SetDeadline(deadline)
+
+	_, err := fmt.Fprintf(n.conn, "PUB %s %d\r\n%s\r\nPING\r\n", message.Topic, len(message.Value), message.Value)
+	if err != nil {
+		return err
+	}
+
+	for {
+		line, err := n.readLine()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			_, err = n.conn.Write([]byte("PONG\r\n"))
+			if err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// Open a connection to the server, reading its INFO and sending our CONNECT. The
+// connection is checked with a PING, as the server only reports authentication errors
+// after the CONNECT.
+func (n *NATS) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: n.timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+
+	n.conn = conn
+	n.reader = bufio.NewReader(conn)
+
+	n.conn.SetDeadline(time.Now().Add(n.timeout))
+
+	line, err := n.readLine()
+	if err != nil {
+		n.Close()
+		return err
+	}
+
+	if !strings.HasPrefix(line, "INFO ") {
+		n.Close()
+		return fmt.Errorf("nats: unexpected greeting %q", line)
+	}
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "greenlight",
+		"lang":     "go",
+		"version":  "1.0.0",
+	}
+
+	if n.user != "" {
+		options["user"] = n.user
+		options["pass"] = n.password
+	}
+
+	if n.token != "" {
+		options["auth_token"] = n.token
+	}
+
+	js, err := json.Marshal(options)
+	if err != nil {
+		n.Close()
+		return err
+	}
+
+	_, err = fmt.Fprintf(n.conn, "CONNECT %s\r\nPING\r\n", js)
+	if err != nil {
+		n.Close()
+		return err
+	}
+
+	for {
+		line, err := n.readLine()
+		if err != nil {
+			n.Close()
+			return err
+		}
+
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			_, err = n.conn.Write([]byte("PONG\r\n"))
+			if err != nil {
+				n.Close()
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			n.Close()
+			return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// Read a line from the server, without its trailing CRLF
+func (n *NATS) readLine() (string, error) {
+	line, err := n.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Close closes the connection to the server, if it is open
+func (n *NATS) Close() error {
+	if n.conn == nil {
+		return nil
+	}
+
+	err := n.conn.Close()
+	n.conn = nil
+	n.reader = nil
+
+	return err
+}
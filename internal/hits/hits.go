@@ -0,0 +1,172 @@
+package hits
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The number of buckets the window is split into. Hits are counted per bucket, so the
+// window slides forward one bucket at a time.
+const numBuckets = 24
+
+// Define a Count struct to hold the number of hits recorded for an ID
+type Count struct {
+	ID   int64
+	Hits int64
+}
+
+// Define a bucket holding the hits recorded during one slice of the window
+type bucket struct {
+	start  time.Time
+	counts map[int64]int64
+}
+
+// Define a Tracker type which counts hits per ID over a sliding window. Hits are
+// recorded asynchronously: Record() only hands the ID to a buffered channel, which is
+// drained by a single goroutine, so recording never blocks a request. When the buffer
+// is full the hit is dropped instead.
+type Tracker struct {
+	hits       chan int64
+	window     time.Duration
+	resolution time.Duration
+	mutex      sync.Mutex
+	buckets    [numBuckets]bucket
+	pending    map[int64]int64
+	dropped    uint64
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// Return a new Tracker counting hits over the given window, and start the goroutine
+// which records them. Call Close() to stop it.
+func New(window time.Duration, buffer int) *Tracker {
+	t := &Tracker{
+		hits:       make(chan int64, buffer),
+		window:     window,
+		resolution: window / numBuckets,
+		pending:    make(map[int64]int64),
+		done:       make(chan struct{}),
+	}
+
+	// Guard against windows too small to be split into buckets
+	if t.resolution <= 0 {
+		t.resolution = time.Nanosecond
+	}
+
+	t.wg.Add(1)
+
+	go func() {
+		defer t.wg.Done()
+
+		for {
+			select {
+			case id := <-t.hits:
+				t.add(id, time.Now())
+			case <-t.done:
+				return
+			}
+		}
+	}()
+
+	return t
+}
+
+// Record a hit for the given ID without blocking
+func (t *Tracker) Record(id int64) {
+	select {
+	case t.hits <- id:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+// Return the number of hits dropped because the buffer was full
+func (t *Tracker) Dropped() uint64 {
+	return atomic.LoadUint64(&t.dropped)
+}
+
+// Add a hit to the bucket for the given time, clearing the bucket first if it was
+// last used for an earlier slice of the window
+func (t *Tracker) add(id int64, now time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	start := now.Truncate(t.resolution)
+	b := &t.buckets[(start.UnixNano()/int64(t.resolution))%numBuckets]
+
+	if !b.start.Equal(start) {
+		b.start = start
+		b.counts = make(map[int64]int64)
+	}
+
+	b.counts[id]++
+	t.pending[id]++
+}
+
+// Return the hits recorded for each ID since the last call to Drain(), and reset them.
+// This lets the totals be written to a database in batches.
+func (t *Tracker) Drain() map[int64]int64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	pending := t.pending
+	t.pending = make(map[int64]int64)
+
+	return pending
+}
+
+// Add drained hits back to the pending totals, for example when writing them failed
+func (t *Tracker) Restore(pending map[int64]int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for id, hits := range pending {
+		t.pending[id] += hits
+	}
+}
+
+// Return up to n IDs with the most hits within the window, most hits first. IDs with
+// the same number of hits are ordered by ID.
+func (t *Tracker) Top(n int) []Count {
+	cutoff := time.Now().Add(-t.window)
+	totals := make(map[int64]int64)
+
+	t.mutex.Lock()
+
+	for _, b := range t.buckets {
+		if b.start.After(cutoff) {
+			for id, hits := range b.counts {
+				totals[id] += hits
+			}
+		}
+	}
+
+	t.mutex.Unlock()
+
+	counts := make([]Count, 0, len(totals))
+	for id, hits := range totals {
+		counts = append(counts, Count{ID: id, Hits: hits})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Hits == counts[j].Hits {
+			return counts[i].ID < counts[j].ID
+		}
+
+		return counts[i].Hits > counts[j].Hits
+	})
+
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+
+	return counts
+}
+
+// Stop recording hits. Hits still in the buffer are discarded.
+func (t *Tracker) Close() {
+	close(t.done)
+	t.wg.Wait()
+}
@@ -0,0 +1,502 @@
+// Package jobs implements a persistent job queue backed by PostgreSQL. Jobs survive
+// restarts, are retried with exponential backoff when they fail, can be scheduled to
+// run later, and are run by a fixed number of workers. Several instances of the
+// application can share the same queue, as jobs are claimed with SELECT ... FOR UPDATE
+// SKIP LOCKED.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/tracing"
+)
+
+// Define a Payload interface for the typed payload of a job. Each payload type has its
+// own kind, which is stored with the job and used to find its handler.
+type Payload interface {
+	Kind() string
+}
+
+// Define a Job struct holding a job claimed from the queue
+type Job struct {
+	ID          int64
+	Kind        string
+	Payload     json.RawMessage
+	Attempts    int
+	MaxAttempts int
+	TraceParent string
+	TraceState  string
+}
+
+// Define an EnqueueOptions struct holding the optional settings for a new job. The
+// zero value runs the job as soon as possible, with the queue's default number of
+// attempts.
+type EnqueueOptions struct {
+	RunAt       time.Time
+	MaxAttempts int
+
+	// Skip enqueueing the job if a job of the same kind is already pending. This is
+	// used for recurring jobs, which each instance schedules when it starts and which
+	// schedule their own next run.
+	Unique bool
+}
+
+// Define an Options struct holding the settings of a Queue
+type Options struct {
+	// The number of jobs which are run at the same time
+	Concurrency int
+
+	// How often idle workers check for new jobs which were enqueued by another instance
+	// or whose scheduled time has come
+	PollInterval time.Duration
+
+	// How long a job can run before it is considered abandoned (for example because the
+	// instance running it crashed) and is made available to other workers again
+	Timeout time.Duration
+
+	// The number of times a job is attempted when EnqueueOptions.MaxAttempts isn't set
+	MaxAttempts int
+
+	// Called with the details of every failed attempt. Its signature matches the
+	// logger's PrintError() method.
+	OnError func(err error, properties map[string]string)
+}
+
+// Define a handler type which decodes a job's payload and runs it
+type handler func(ctx context.Context, payload json.RawMessage) error
+
+// Define a Queue type which stores jobs in the jobs table and runs them
+type Queue struct {
+	db       *sql.DB
+	options  Options
+	mutex    sync.RWMutex
+	handlers map[string]handler
+	wake     chan struct{}
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// Return a new Queue which stores its jobs in the given database
+func New(db *sql.DB, options Options) *Queue {
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
+
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = 10 * time.Minute
+	}
+
+	if options.MaxAttempts < 1 {
+		options.MaxAttempts = 5
+	}
+
+	if options.OnError == nil {
+		options.OnError = func(error, map[string]string) {}
+	}
+
+	return &Queue{
+		db:       db,
+		options:  options,
+		handlers: make(map[string]handler),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Handle registers the function which runs jobs with payloads of type T. It should be
+// called for every payload type before the queue is started.
+func Handle[T Payload](q *Queue, fn func(ctx context.Context, payload T) error) {
+	var zero T
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.handlers[zero.Kind()] = func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+
+		err := json.Unmarshal(raw, &payload)
+		if err != nil {
+			return err
+		}
+
+		return fn(ctx, payload)
+	}
+}
+
+// Enqueue adds a job to the queue
+func (q *Queue) Enqueue(ctx context.Context, payload Payload, options EnqueueOptions) error {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if options.MaxAttempts < 1 {
+		options.MaxAttempts = q.options.MaxAttempts
+	}
+
+	runAt := options.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+
+	job := &Job{
+		Kind:        payload.Kind(),
+		Payload:     js,
+		MaxAttempts: options.MaxAttempts,
+	}
+
+	// Keep the trace of the work which enqueued the job, such as a request, so that the
+	// job's outgoing requests and errors can be followed back to it
+	if trace, ok := tracing.FromContext(ctx); ok {
+		job.TraceParent = trace.TraceParent()
+		job.TraceState = trace.State
+	}
+
+	if options.Unique {
+		err = q.insertUnique(ctx, job, runAt)
+	} else {
+		err = q.insert(ctx, q.db, job, runAt)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Let an idle worker know that there is a job, without waiting for it to poll
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Define an execer interface satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Insert a job using the given database handle, which may be a transaction
+func (q *Queue) insert(ctx context.Context, db execer, job *Job, runAt time.Time) error {
+	query := `
+        INSERT INTO jobs (kind, payload, max_attempts, run_at, traceparent, tracestate)
+        VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := db.ExecContext(ctx, query, job.Kind, []byte(job.Payload), job.MaxAttempts, runAt, job.TraceParent, job.TraceState)
+
+	return err
+}
+
+// Insert a job unless one of the same kind is already pending. The check and insert
+// are made while holding a transaction-level advisory lock for the kind, so that two
+// instances starting at the same time can't both insert the job.
+func (q *Queue) insertUnique(ctx context.Context, job *Job, runAt time.Time) error {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Rollback() is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "jobs:"+job.Kind)
+	if err != nil {
+		return err
+	}
+
+	var exists bool
+
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM jobs WHERE kind = $1 AND status = 'pending')`, job.Kind).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		err = q.insert(ctx, tx, job, runAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Start launches the workers
+func (q *Queue) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+
+	for i := 0; i < q.options.Concurrency; i++ {
+		q.wg.Add(1)
+
+		go func() {
+			defer q.wg.Done()
+			q.work(ctx)
+		}()
+	}
+}
+
+// Shutdown stops the workers from claiming new jobs and waits for the running jobs to
+// finish, or for the context to be cancelled. Jobs which are still running when the
+// context is cancelled are picked up again once their timeout has passed.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	if q.cancel == nil {
+		return nil
+	}
+
+	q.cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run jobs until the context is cancelled, waiting for a wake up or the poll interval
+// whenever the queue is empty
+func (q *Queue) work(ctx context.Context) {
+	for {
+		ran, err := q.runNext()
+		if err != nil {
+			q.options.OnError(err, map[string]string{"component": "jobs"})
+		}
+
+		// Check for cancellation before claiming another job, so that shutdown isn't
+		// held up by a long queue
+		if ctx.Err() != nil {
+			return
+		}
+
+		if ran {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(q.options.PollInterval):
+		}
+	}
+}
+
+// Claim the next job which is due and run it, reporting whether there was one. Jobs
+// which were abandoned by a crashed worker are claimed as if they were pending.
+func (q *Queue) runNext() (bool, error) {
+	query := `
+        UPDATE jobs
+        SET status = 'running', attempts = attempts + 1, locked_at = NOW()
+        WHERE id = (
+            SELECT id FROM jobs
+            WHERE (status = 'pending' AND run_at <= NOW())
+            OR (status = 'running' AND locked_at < NOW() - $1 * interval '1 second')
+            ORDER BY run_at
+            LIMIT 1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id, kind, payload, attempts, max_attempts, traceparent, tracestate`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var job Job
+
+	err := q.db.QueryRowContext(ctx, query, q.options.Timeout.Seconds()).Scan(
+		&job.ID,
+		&job.Kind,
+		&job.Payload,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.TraceParent,
+		&job.TraceState,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	runErr := q.run(&job)
+
+	if runErr == nil {
+		return true, q.complete(&job)
+	}
+
+	properties := map[string]string{
+		"component": "jobs",
+		"job_id":    strconv.FormatInt(job.ID, 10),
+		"job_kind":  job.Kind,
+		"attempt":   strconv.Itoa(job.Attempts),
+	}
+
+	if trace, ok := tracing.Parse(job.TraceParent, job.TraceState); ok {
+		properties["trace_id"] = trace.TraceID
+	}
+
+	q.options.OnError(runErr, properties)
+
+	return true, q.fail(&job, runErr)
+}
+
+// Run a job's handler with the job timeout, turning a panic into an error
+func (q *Queue) run(job *Job) (err error) {
+	q.mutex.RLock()
+	fn, found := q.handlers[job.Kind]
+	q.mutex.RUnlock()
+
+	if !found {
+		return fmt.Errorf("no handler registered for job kind %q", job.Kind)
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v\n%s", recovered, debug.Stack())
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), q.options.Timeout)
+	defer cancel()
+
+	// Continue the trace of the work which enqueued the job, as a span of its own
+	if trace, ok := tracing.Parse(job.TraceParent, job.TraceState); ok {
+		child, err := trace.Child()
+		if err != nil {
+			return err
+		}
+
+		ctx = tracing.NewContext(ctx, child)
+	}
+
+	return fn(ctx, job.Payload)
+}
+
+// Remove a completed job. Completed jobs aren't kept, as their payloads can contain
+// secrets such as activation tokens.
+func (q *Queue) complete(job *Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := q.db.ExecContext(ctx, `DELETE FROM jobs WHERE id = $1`, job.ID)
+
+	return err
+}
+
+// Record a failed attempt, scheduling a retry with exponential backoff unless the job
+// has run out of attempts, in which case it is kept with the failed status so that it
+// can be inspected
+func (q *Queue) fail(job *Job, runErr error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if job.Attempts >= job.MaxAttempts {
+		query := `
+            UPDATE jobs
+            SET status = 'failed', last_error = $1, locked_at = NULL
+            WHERE id = $2`
+
+		_, err := q.db.ExecContext(ctx, query, runErr.Error(), job.ID)
+
+		return err
+	}
+
+	query := `
+        UPDATE jobs
+        SET status = 'pending', last_error = $1, locked_at = NULL, run_at = $2
+        WHERE id = $3`
+
+	_, err := q.db.ExecContext(ctx, query, runErr.Error(), time.Now().Add(backoff(job.Attempts)), job.ID)
+
+	return err
+}
+
+// Define a Stats struct holding the number of jobs of a kind in each status. Completed
+// jobs are deleted, so they aren't counted.
+type Stats struct {
+	Pending int `json:"pending"`
+	Running int `json:"running"`
+	Failed  int `json:"failed"`
+
+	// How long the oldest pending job which is due has been waiting, in seconds. A
+	// growing value means that the workers aren't keeping up.
+	OldestPendingSeconds float64 `json:"oldest_pending_seconds"`
+}
+
+// Stats counts the jobs of the given kind in each status
+func (q *Queue) Stats(ctx context.Context, kind string) (Stats, error) {
+	query := `
+        SELECT
+            COUNT(*) FILTER (WHERE status = 'pending'),
+            COUNT(*) FILTER (WHERE status = 'running'),
+            COUNT(*) FILTER (WHERE status = 'failed'),
+            COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(run_at) FILTER (WHERE status = 'pending' AND run_at <= NOW())), 0)
+        FROM jobs
+        WHERE kind = $1`
+
+	var stats Stats
+
+	err := q.db.QueryRowContext(ctx, query, kind).Scan(
+		&stats.Pending,
+		&stats.Running,
+		&stats.Failed,
+		&stats.OldestPendingSeconds,
+	)
+
+	return stats, err
+}
+
+// DeleteFailed deletes up to limit jobs which ran out of attempts before the given time,
+// returning how many were deleted. Failed jobs are kept so that they can be inspected,
+// but their payloads can hold personal data such as email addresses, so they shouldn't
+// be kept forever. A failed job's run_at is the time of its last attempt.
+func (q *Queue) DeleteFailed(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+        DELETE FROM jobs
+        WHERE id IN (SELECT id FROM jobs WHERE status = 'failed' AND run_at < $1 LIMIT $2)`
+
+	result, err := q.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Return the delay before retrying a job which has failed the given number of times.
+// The delay doubles with each attempt, starting from 10 seconds and capped at an hour,
+// with up to 20% jitter so that jobs which failed together don't all retry together.
+func backoff(attempts int) time.Duration {
+	delay := 10 * time.Second
+
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay/5)+1))
+}
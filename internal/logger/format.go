@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Define a Format type to represent the way log entries are written out
+type Format int8
+
+// Initialize constants for the supported formats. JSON is meant for production, logfmt
+// for the log collectors which expect it, and pretty for reading the logs in a terminal
+// during development.
+const (
+	FormatJSON Format = iota
+	FormatLogfmt
+	FormatPretty
+)
+
+// The names of the formats, as accepted by SetFormat()
+var Formats = []string{"json", "logfmt", "pretty"}
+
+// Return the name of the format
+func (f Format) String() string {
+	if int(f) < len(Formats) {
+		return Formats[f]
+	}
+
+	return ""
+}
+
+// Define an entry struct holding the data for a log entry. Every format uses the same
+// field names, with the properties as a nested object in JSON and as extra key=value
+// pairs in the other formats.
+type entry struct {
+	Level      string            `json:"level"`
+	Time       string            `json:"time"`
+	Message    string            `json:"message"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Trace      string            `json:"trace,omitempty"`
+}
+
+// Encode a log entry as a single line of JSON
+func encodeJSON(e entry) []byte {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return []byte(LevelError.String() + ": unable to marshal log message: " + err.Error())
+	}
+
+	return line
+}
+
+// Encode a log entry as a single line of logfmt, such as level=INFO time=...
+// message="starting server" addr=:4000. The properties follow the fixed fields, sorted
+// by key.
+func encodeLogfmt(e entry) []byte {
+	var b strings.Builder
+
+	b.WriteString("level=" + logfmtValue(e.Level))
+	b.WriteString(" time=" + logfmtValue(e.Time))
+	b.WriteString(" message=" + logfmtValue(e.Message))
+
+	for _, key := range sortedKeys(e.Properties) {
+		b.WriteString(" " + key + "=" + logfmtValue(e.Properties[key]))
+	}
+
+	if e.Trace != "" {
+		b.WriteString(" trace=" + logfmtValue(e.Trace))
+	}
+
+	return []byte(b.String())
+}
+
+// ANSI escape codes used by the pretty format
+const (
+	colorReset   = "\x1b[0m"
+	colorDim     = "\x1b[2m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorCyan    = "\x1b[36m"
+	colorBoldRed = "\x1b[1;31m"
+)
+
+// Encode a log entry for reading in a terminal, with the level colored and the
+// properties after the message. The stack trace of errors is written out on the
+// following lines as it is, rather than quoted. Colors are left out if the NO_COLOR
+// environment variable is set.
+func encodePretty(e entry) []byte {
+	color := func(code, s string) string {
+		if os.Getenv("NO_COLOR") != "" {
+			return s
+		}
+
+		return code + s + colorReset
+	}
+
+	levelColor := colorGreen
+
+	switch e.Level {
+	case LevelError.String():
+		levelColor = colorRed
+	case LevelFatal.String():
+		levelColor = colorBoldRed
+	}
+
+	var b strings.Builder
+
+	b.WriteString(color(colorDim, e.Time) + " ")
+	b.WriteString(color(levelColor, fmt.Sprintf("%-5s", e.Level)) + " ")
+	b.WriteString(e.Message)
+
+	for _, key := range sortedKeys(e.Properties) {
+		b.WriteString(" " + color(colorCyan, key+"=") + logfmtValue(e.Properties[key]))
+	}
+
+	if e.Trace != "" {
+		b.WriteString("\n" + color(colorDim, strings.TrimRight(e.Trace, "\n")))
+	}
+
+	return []byte(b.String())
+}
+
+// Return a logfmt value, which is quoted if it is empty or contains spaces, quotes,
+// equals signs or control characters
+func logfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+
+	needsQuotes := strings.IndexFunc(value, func(r rune) bool {
+		return r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}) >= 0
+
+	if needsQuotes {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
+
+// Return the keys of the properties in sorted order, so that the entries of the
+// text formats are easy to compare
+func sortedKeys(properties map[string]string) []string {
+	keys := make([]string, 0, len(properties))
+
+	for key := range properties {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
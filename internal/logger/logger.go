@@ -1,10 +1,11 @@
 package logger
 
 import (
-	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"time"
 )
@@ -37,11 +38,14 @@ func (l Level) String() string {
 
 // Define a custom Logger type. This holds the output destination that the log entries
 // will be written to, the minimum severity level that log entries will be written for,
-// plus a mutex for coordinating the writes.
+// the base properties included in every log entry, the format the entries are written
+// in, plus a mutex for coordinating the writes.
 type Logger struct {
-	output   io.Writer
-	minLevel Level
-	mutex    sync.Mutex
+	output         io.Writer
+	minLevel       Level
+	baseProperties map[string]string
+	format         Format
+	mutex          sync.Mutex
 }
 
 // Return a new Logger instance which writes log entries at or above a minimum severity
@@ -53,6 +57,32 @@ func New(output io.Writer, minLevel Level) *Logger {
 	}
 }
 
+// Set the properties which are included in every log entry, such as the application
+// version. Properties passed to an individual log call take precedence over these.
+func (l *Logger) SetBaseProperties(properties map[string]string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.baseProperties = properties
+}
+
+// Set the format the log entries are written in, by its name (one of Formats). New
+// loggers write JSON.
+func (l *Logger) SetFormat(name string) error {
+	for i, format := range Formats {
+		if name == format {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+
+			l.format = Format(i)
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("logger: unknown format %q, expected one of %s", name, strings.Join(Formats, ", "))
+}
+
 // Declare some helper methods for writing log entries at the different levels. Notice
 // that these all accept a map as the second parameter which can contain any arbitrary
 // 'properties' that you want to appear in the log entry.
@@ -77,14 +107,26 @@ func (l *Logger) print(level Level, message string, properties map[string]string
 		return 0, nil
 	}
 
-	// Declare an anonymous struct holding the data for the log entry
-	aux := struct {
-		Level      string            `json:"level"`
-		Time       string            `json:"time"`
-		Message    string            `json:"message"`
-		Properties map[string]string `json:"properties,omitempty"`
-		Trace      string            `json:"trace,omitempty"`
-	}{
+	// Merge the base properties with the properties for this entry
+	l.mutex.Lock()
+	format := l.format
+	if len(l.baseProperties) > 0 {
+		merged := make(map[string]string, len(l.baseProperties)+len(properties))
+
+		for key, value := range l.baseProperties {
+			merged[key] = value
+		}
+
+		for key, value := range properties {
+			merged[key] = value
+		}
+
+		properties = merged
+	}
+	l.mutex.Unlock()
+
+	// Declare an entry holding the data for the log entry
+	e := entry{
 		Level:      level.String(),
 		Time:       time.Now().UTC().Format(time.RFC3339),
 		Message:    message,
@@ -93,16 +135,19 @@ func (l *Logger) print(level Level, message string, properties map[string]string
 
 	// Include a stack trace for entries at the ERROR and FATAL levels
 	if level >= LevelError {
-		aux.Trace = string(debug.Stack())
+		e.Trace = string(debug.Stack())
 	}
 
-	// Declare a log variable for holding the actual log entry
+	// Encode the entry in the logger's format, and store it in the "log" variable
 	var log []byte
 
-	// Convert the anonymous struct into JSON and store it in the "log" variable
-	log, err := json.Marshal(aux)
-	if err != nil {
-		log = []byte(LevelError.String() + ": unable to marshal log message: " + err.Error())
+	switch format {
+	case FormatLogfmt:
+		log = encodeLogfmt(e)
+	case FormatPretty:
+		log = encodePretty(e)
+	default:
+		log = encodeJSON(e)
 	}
 
 	// Lock the mutex so that no two writes to the output destination can happen
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+
+	mail "github.com/xhit/go-simple-mail/v2"
+)
+
+// The largest file which can be attached to an email. Most mail servers reject messages
+// much larger than this.
+const MaxAttachmentSize = 10 << 20
+
+// ErrNoFiles is returned when sending an email with attachments from a Mailer which
+// wasn't given a storage to read them from
+var ErrNoFiles = errors.New("mailer: no storage to read attachments from")
+
+// Define an Attachment struct referring to a file in the mailer's storage which is sent
+// with an email. Inline attachments are images embedded in the HTML body, which refers
+// to them by their file name, as in <img src="cid:logo.png">. Attachments only hold
+// the path of the file, so that messages can be stored in the job queue.
+type Attachment struct {
+	Path   string `json:"path"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// Read an attachment from the storage into a file to add to an email, refusing files
+// larger than MaxAttachmentSize
+func readAttachment(files fs.FS, attachment Attachment) (*mail.File, error) {
+	if files == nil {
+		return nil, ErrNoFiles
+	}
+
+	f, err := files.Open(attachment.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	// Read one byte more than the limit, to find out if the file is larger
+	content, err := io.ReadAll(io.LimitReader(f, MaxAttachmentSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) > MaxAttachmentSize {
+		return nil, fmt.Errorf("mailer: attachment %s is larger than %d bytes", attachment.Path, MaxAttachmentSize)
+	}
+
+	// The MIME type is worked out from the file name's extension
+	return &mail.File{
+		Name:   path.Base(attachment.Path),
+		Data:   content,
+		Inline: attachment.Inline,
+	}, nil
+}
@@ -0,0 +1,26 @@
+package mailer
+
+import "github.com/LuisBarroso37/Greenlight/internal/circuit"
+
+// Define a breakerSender type which sends emails through a circuit breaker
+type breakerSender struct {
+	Sender
+	breaker *circuit.Breaker
+}
+
+// WithBreaker wraps a Sender so that emails fail fast with a circuit.OpenError while
+// the SMTP server is failing, rather than each one waiting for the connection to time
+// out. Queued emails are retried by the job worker as usual.
+func WithBreaker(sender Sender, breaker *circuit.Breaker) Sender {
+	return breakerSender{Sender: sender, breaker: breaker}
+}
+
+func (s breakerSender) Send(recipient, templateFile string, data interface{}) error {
+	return s.SendMessage(NewMessage(recipient, templateFile, data))
+}
+
+func (s breakerSender) SendMessage(msg *Message) error {
+	return s.breaker.Do(func() error {
+		return s.Sender.SendMessage(msg)
+	})
+}
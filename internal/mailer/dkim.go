@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+
+	"github.com/toorop/go-dkim"
+)
+
+// The headers covered by the DKIM signature. Signing a header which an email doesn't
+// have stops it from being added in transit.
+var dkimHeaders = []string{
+	"from", "to", "subject", "date", "message-id", "reply-to",
+	"list-unsubscribe", "mime-version", "content-type",
+}
+
+// WithDKIM returns a copy of the Mailer which signs emails with DKIM, using the given
+// PEM encoded RSA private key. The public key must be published in DNS as a TXT record
+// at <selector>._domainkey.<domain>. An empty domain is the domain of the sender.
+func (m Mailer) WithDKIM(privateKey []byte, domain, selector string) (Mailer, error) {
+	if domain == "" {
+		var err error
+
+		domain, err = senderDomain(m.sender)
+		if err != nil {
+			return m, err
+		}
+	}
+
+	// Check the key now, rather than failing every email
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return m, errors.New("mailer: the DKIM key isn't PEM encoded")
+	}
+
+	_, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return m, errors.New("mailer: the DKIM key must be a PKCS #1 RSA private key")
+	}
+
+	options := dkim.NewSigOptions()
+	options.PrivateKey = privateKey
+	options.Domain = domain
+	options.Selector = selector
+	options.Canonicalization = "relaxed/relaxed"
+	options.Headers = dkimHeaders
+	options.AddSignatureTimestamp = true
+
+	m.dkim = &options
+
+	return m, nil
+}
+
+// WithReplyTo returns a copy of the Mailer which sets the Reply-To header of emails to
+// the given address, so that replies reach people rather than the no-reply sender
+func (m Mailer) WithReplyTo(address string) Mailer {
+	m.replyTo = address
+	return m
+}
@@ -3,9 +3,18 @@ package mailer
 import (
 	"bytes"
 	"embed"
+	"errors"
+	"fmt"
 	"html/template"
+	"io/fs"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/toorop/go-dkim"
 	mail "github.com/xhit/go-simple-mail/v2"
 )
 
@@ -17,12 +26,27 @@ import (
 //go:embed "templates"
 var templateFS embed.FS
 
+// Define a Sender interface for sending templated emails. It is satisfied by Mailer and
+// by Recorder, which lets the application run without a SMTP server. SendMessage()
+// sends emails with attachments, and Send() is a shortcut for emails without any.
+type Sender interface {
+	Send(recipient, templateFile string, data interface{}) error
+	SendMessage(msg *Message) error
+}
+
 // Define a Mailer struct which contains a mail.Dialer instance (used to connect to a
 // SMTP server) and the sender information for your emails (the name and address you
-// want the email to be from, such as "Alice Smith <alice@example.com>").
+// want the email to be from, such as "Alice Smith <alice@example.com>"). The mutex
+// guards the server credentials, which can be changed while emails are being sent.
+// Attachments are read from the files storage, if it has one, and emails are signed
+// with DKIM if it is set up.
 type Mailer struct {
-	server *mail.SMTPServer
-	sender string
+	server  *mail.SMTPServer
+	sender  string
+	mutex   *sync.Mutex
+	files   fs.FS
+	replyTo string
+	dkim    *dkim.SigOptions
 }
 
 func New(host string, port int, username, password, sender string) Mailer {
@@ -39,52 +63,87 @@ func New(host string, port int, username, password, sender string) Mailer {
 	return Mailer{
 		server: server,
 		sender: sender,
+		mutex:  &sync.Mutex{},
 	}
 }
 
+// WithFiles returns a copy of the Mailer which reads the attachments of emails from the
+// given storage, such as a directory opened with os.DirFS()
+func (m Mailer) WithFiles(files fs.FS) Mailer {
+	m.files = files
+	return m
+}
+
+// SetPassword replaces the password used to authenticate with the SMTP server. It is
+// used to pick up a rotated credential without restarting the application.
+func (m Mailer) SetPassword(password string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.server.Password = password
+}
+
 // Define a Send() method on the Mailer type. This takes the recipient email address
 // as the first parameter, the name of the file containing the templates and any
 // dynamic data for the templates as an interface{} parameter.
 func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
-	// Use the ParseFS() method to parse the required template file from the embedded file system
-	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
-	if err != nil {
-		return err
-	}
-
-	// Execute the named template "subject", passing in the dynamic data and storing the
-	// result in a bytes.Buffer variable.
-	subject := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(subject, "subject", data)
-	if err != nil {
-		return err
-	}
+	return m.SendMessage(NewMessage(recipient, templateFile, data))
+}
 
-	// Follow the same pattern to execute the "plainBody" template and store the result
-	// in the plainBody variable.
-	plainBody := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+// SendMessage sends an email along with its attachments, which are read from the
+// storage before connecting to the SMTP server. A message without an ID is given one,
+// which is set on the message so that the caller can record it.
+func (m Mailer) SendMessage(msg *Message) error {
+	subject, plainBody, htmlBody, err := render(msg.TemplateFile, msg.Data)
 	if err != nil {
 		return err
 	}
 
-	// And likewise with the "htmlBody" template.
-	htmlBody := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
-	if err != nil {
-		return err
+	if msg.ID == "" {
+		msg.ID, err = NewMessageID(m.sender)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Setup email message
 	email := mail.NewMSG()
 	email.SetFrom(m.sender)
-	email.AddTo(recipient)
-	email.SetSubject(subject.String())
-	email.SetBody(mail.TextPlain, plainBody.String())
-	email.AddAlternative(mail.TextHTML, htmlBody.String())
+	email.AddTo(msg.Recipient)
+	email.SetSubject(subject)
+	email.AddHeader("Message-ID", msg.ID)
+	email.SetBody(mail.TextPlain, plainBody)
+	email.AddAlternative(mail.TextHTML, htmlBody)
+
+	if m.replyTo != "" {
+		email.SetReplyTo(m.replyTo)
+	}
+
+	for name, value := range msg.Headers {
+		email.AddHeader(name, value)
+	}
+
+	for _, attachment := range msg.Attachments {
+		file, err := readAttachment(m.files, attachment)
+		if err != nil {
+			return err
+		}
+
+		email.Attach(file)
+	}
+
+	if m.dkim != nil {
+		email.SetDkim(*m.dkim)
+	}
+
+	if email.Error != nil {
+		return email.Error
+	}
 
 	// Connect to email server
+	m.mutex.Lock()
 	client, err := m.server.Connect()
+	m.mutex.Unlock()
 	if err != nil {
 		return err
 	}
@@ -105,3 +164,107 @@ func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
 
 	return err
 }
+
+// ErrAuthFailed is matched by the errors returned by Verify() when the SMTP server is
+// reachable but rejects the username or password
+var ErrAuthFailed = errors.New("mailer: smtp server rejected the credentials")
+
+// Verify connects and authenticates to the SMTP server without sending anything, to
+// check that the server is reachable and the credentials are valid. Errors name the
+// server, and authentication failures match ErrAuthFailed, so that they can be told
+// apart from connection problems.
+func (m Mailer) Verify() error {
+	address := m.Address()
+
+	m.mutex.Lock()
+	client, err := m.server.Connect()
+	m.mutex.Unlock()
+	if err != nil {
+		// 530 and 535 are the replies to a missing or rejected login, and 534 asks for a
+		// stronger authentication mechanism
+		var reply *textproto.Error
+		if errors.As(err, &reply) && (reply.Code == 530 || reply.Code == 534 || reply.Code == 535) {
+			return fmt.Errorf("%w at %s: %v", ErrAuthFailed, address, err)
+		}
+
+		return fmt.Errorf("mailer: connect to %s: %w", address, err)
+	}
+
+	return client.Quit()
+}
+
+// Address returns the host and port of the SMTP server
+func (m Mailer) Address() string {
+	return net.JoinHostPort(m.server.Host, strconv.Itoa(m.server.Port))
+}
+
+// Define an Email struct holding the parts of a rendered email
+type Email struct {
+	Subject   string
+	PlainBody string
+	HTMLBody  string
+}
+
+// Render renders an email from the named template file without sending it, for
+// previewing templates
+func Render(templateFile string, data interface{}) (Email, error) {
+	subject, plainBody, htmlBody, err := render(templateFile, data)
+	if err != nil {
+		return Email{}, err
+	}
+
+	return Email{Subject: subject, PlainBody: plainBody, HTMLBody: htmlBody}, nil
+}
+
+// Templates returns the names of the template files, such as "user_welcome.tmpl"
+func Templates() ([]string, error) {
+	entries, err := fs.ReadDir(templateFS, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmpl") {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// Render the subject, plain text body and HTML body of an email from the named
+// template file
+func render(templateFile string, data interface{}) (string, string, string, error) {
+	// Use the ParseFS() method to parse the required template file from the embedded file system
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	// Execute the named template "subject", passing in the dynamic data and storing the
+	// result in a bytes.Buffer variable.
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	// Follow the same pattern to execute the "plainBody" template and store the result
+	// in the plainBody variable.
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	// And likewise with the "htmlBody" template.
+	htmlBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return subject.String(), plainBody.String(), htmlBody.String(), nil
+}
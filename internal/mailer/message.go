@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// Define a Message struct holding an email to send: the recipient, the name of the file
+// containing its templates and the dynamic data for them, its attachments, and any
+// extra headers. The ID is sent as the Message-ID header, and is made up when the
+// email is sent if it is empty.
+type Message struct {
+	ID           string
+	Recipient    string
+	TemplateFile string
+	Data         interface{}
+	Attachments  []Attachment
+	Headers      map[string]string
+}
+
+// Return a new Message sending the named template file to the recipient
+func NewMessage(recipient, templateFile string, data interface{}) *Message {
+	return &Message{Recipient: recipient, TemplateFile: templateFile, Data: data}
+}
+
+// Attach adds the file at the given path in the mailer's storage as an attachment
+func (msg *Message) Attach(filePath string) *Message {
+	msg.Attachments = append(msg.Attachments, Attachment{Path: filePath})
+	return msg
+}
+
+// Embed adds the image at the given path in the mailer's storage as an inline
+// attachment, for the HTML body to show as cid:<file name>
+func (msg *Message) Embed(filePath string) *Message {
+	msg.Attachments = append(msg.Attachments, Attachment{Path: filePath, Inline: true})
+	return msg
+}
+
+// Header sets an extra header of the email, such as List-Unsubscribe
+func (msg *Message) Header(name, value string) *Message {
+	if msg.Headers == nil {
+		msg.Headers = make(map[string]string)
+	}
+
+	msg.Headers[name] = value
+
+	return msg
+}
+
+// NewMessageID returns a new, unique Message-ID for an email sent from the given sender
+// address, such as "Greenlight <no-reply@greenlight.alexedwards.net>". The ID is in the
+// domain of the sender, as spam filters expect.
+func NewMessageID(sender string) (string, error) {
+	domain, err := senderDomain(sender)
+	if err != nil {
+		return "", err
+	}
+
+	randomBytes := make([]byte, 16)
+
+	_, err = rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return "<" + hex.EncodeToString(randomBytes) + "@" + domain + ">", nil
+}
+
+// Return the domain of a sender address
+func senderDomain(sender string) (string, error) {
+	address, err := mail.ParseAddress(sender)
+	if err != nil {
+		return "", fmt.Errorf("mailer: invalid sender: %w", err)
+	}
+
+	return address.Address[strings.LastIndex(address.Address, "@")+1:], nil
+}
@@ -0,0 +1,153 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRecipientRateLimited is matched by the errors returned when an address has already
+// been sent as many emails as it is allowed
+var ErrRecipientRateLimited = errors.New("mailer: too many emails sent to the recipient")
+
+// Define a RecipientLimitError type returned by a RecipientLimiter, saying when the next
+// email can be sent to the address
+type RecipientLimitError struct {
+	Recipient  string
+	RetryAfter time.Duration
+}
+
+func (e *RecipientLimitError) Error() string {
+	return fmt.Sprintf("mailer: too many emails sent to %s", e.Recipient)
+}
+
+// Is makes errors.Is(err, ErrRecipientRateLimited) true for a RecipientLimitError
+func (e *RecipientLimitError) Is(target error) bool {
+	return target == ErrRecipientRateLimited
+}
+
+// Define a RecipientLimiter type which limits the number of emails sent to each address
+// over a sliding window, so that endpoints such as password resets can't be used to
+// flood someone's inbox and harm the sender's reputation. The send times are kept in
+// memory, so each instance of the application limits addresses separately.
+type RecipientLimiter struct {
+	limit  int
+	window time.Duration
+
+	mutex     sync.Mutex
+	sent      map[string][]time.Time
+	lastSweep time.Time
+}
+
+// Return a new RecipientLimiter allowing limit emails to each address per window. A
+// limit of 0 or less allows any number of emails.
+func NewRecipientLimiter(limit int, window time.Duration) *RecipientLimiter {
+	return &RecipientLimiter{
+		limit:     limit,
+		window:    window,
+		sent:      make(map[string][]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow records an email to the recipient, or returns a *RecipientLimitError without
+// recording it if the address has already been sent its limit of emails in the window.
+// Addresses are compared case insensitively.
+func (l *RecipientLimiter) Allow(recipient string) error {
+	return l.take(recipient, true)
+}
+
+// Check returns a *RecipientLimitError if the address has already been sent its limit of
+// emails in the window, like Allow, but doesn't record an email. It lets a handler turn
+// the request down before queueing an email, which is then recorded when it is sent.
+func (l *RecipientLimiter) Check(recipient string) error {
+	return l.take(recipient, false)
+}
+
+func (l *RecipientLimiter) take(recipient string, record bool) error {
+	if l.limit <= 0 {
+		return nil
+	}
+
+	key := strings.ToLower(strings.TrimSpace(recipient))
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+
+	// The addresses which haven't been sent anything within the window are forgotten
+	// once per window, so that the map doesn't keep growing
+	if now.Sub(l.lastSweep) > l.window {
+		for address, times := range l.sent {
+			if now.Sub(times[len(times)-1]) > l.window {
+				delete(l.sent, address)
+			}
+		}
+
+		l.lastSweep = now
+	}
+
+	// The send times are in order, so the ones which have left the window are at the
+	// start
+	times := l.sent[key]
+	for len(times) > 0 && now.Sub(times[0]) > l.window {
+		times = times[1:]
+	}
+
+	if len(times) >= l.limit {
+		l.sent[key] = times
+
+		return &RecipientLimitError{
+			Recipient:  recipient,
+			RetryAfter: l.window - now.Sub(times[0]),
+		}
+	}
+
+	if record {
+		times = append(times, now)
+	}
+
+	if len(times) > 0 {
+		l.sent[key] = times
+	}
+
+	return nil
+}
+
+// Define a limitingSender type which skips the emails to addresses which have already
+// been sent too many
+type limitingSender struct {
+	Sender
+	limiter   *RecipientLimiter
+	onLimited func(recipient, templateFile string, err *RecipientLimitError)
+}
+
+// WithRecipientLimit wraps a Sender so that every email it sends counts towards the
+// limiter's limit for the recipient, whichever part of the application it comes from.
+// Emails over the limit are passed to onLimited and otherwise treated as sent, so that
+// they aren't retried.
+func WithRecipientLimit(sender Sender, limiter *RecipientLimiter, onLimited func(recipient, templateFile string, err *RecipientLimitError)) Sender {
+	return limitingSender{Sender: sender, limiter: limiter, onLimited: onLimited}
+}
+
+func (s limitingSender) Send(recipient, templateFile string, data interface{}) error {
+	return s.SendMessage(NewMessage(recipient, templateFile, data))
+}
+
+func (s limitingSender) SendMessage(msg *Message) error {
+	err := s.limiter.Allow(msg.Recipient)
+	if err != nil {
+		var limitErr *RecipientLimitError
+		if !errors.As(err, &limitErr) {
+			return err
+		}
+
+		s.onLimited(msg.Recipient, msg.TemplateFile, limitErr)
+		return nil
+	}
+
+	return s.Sender.SendMessage(msg)
+}
@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecipientLimiterCheck(t *testing.T) {
+	limiter := NewRecipientLimiter(1, time.Hour)
+
+	// Checking an address doesn't use up its limit
+	for i := 0; i < 3; i++ {
+		err := limiter.Check("alice@example.com")
+		if err != nil {
+			t.Fatalf("got error %v checking an address which hasn't been sent anything", err)
+		}
+	}
+
+	err := limiter.Allow("Alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = limiter.Check("alice@example.com")
+	if !errors.Is(err, ErrRecipientRateLimited) {
+		t.Errorf("got error %v checking an address at its limit; want ErrRecipientRateLimited", err)
+	}
+}
+
+// Every email sent through the wrapped sender counts towards the limit, whatever its
+// template, and the emails over the limit are skipped without an error
+func TestWithRecipientLimit(t *testing.T) {
+	recorder := &Recorder{}
+
+	var skipped []string
+
+	sender := WithRecipientLimit(recorder, NewRecipientLimiter(2, time.Hour), func(recipient, templateFile string, err *RecipientLimitError) {
+		skipped = append(skipped, templateFile)
+	})
+
+	data := map[string]interface{}{"title": "Hello", "body": "Hello there"}
+
+	for i := 0; i < 3; i++ {
+		err := sender.Send("alice@example.com", "notification.tmpl", data)
+		if err != nil {
+			t.Fatalf("got error %v sending email %d; want nil", err, i+1)
+		}
+	}
+
+	err := sender.Send("bob@example.com", "notification.tmpl", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(recorder.Messages()); got != 3 {
+		t.Errorf("got %d emails sent; want 3", got)
+	}
+
+	if len(skipped) != 1 {
+		t.Errorf("got %d emails skipped; want 1", len(skipped))
+	}
+}
@@ -0,0 +1,45 @@
+package mailer
+
+import "sync"
+
+// Define a Recorder type which satisfies the Sender interface by keeping the emails it
+// is asked to send in memory instead of delivering them. This is useful for running the
+// application end-to-end without a SMTP server, for example to read the activation
+// token out of the welcome email.
+type Recorder struct {
+	mutex    sync.Mutex
+	messages []Message
+}
+
+// Send records the email and always succeeds. The template is still rendered so that
+// template errors aren't hidden.
+func (r *Recorder) Send(recipient, templateFile string, data interface{}) error {
+	return r.SendMessage(NewMessage(recipient, templateFile, data))
+}
+
+// SendMessage records the email like Send(). The attachments aren't read, so they
+// needn't exist.
+func (r *Recorder) SendMessage(msg *Message) error {
+	_, _, _, err := render(msg.TemplateFile, msg.Data)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.messages = append(r.messages, *msg)
+
+	return nil
+}
+
+// Messages returns a copy of the emails recorded so far
+func (r *Recorder) Messages() []Message {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	messages := make([]Message, len(r.messages))
+	copy(messages, r.messages)
+
+	return messages
+}
@@ -0,0 +1,34 @@
+package mailer
+
+// Define a suppressingSender type which skips the emails to suppressed addresses
+type suppressingSender struct {
+	Sender
+	suppressed   func(recipient string) (bool, error)
+	onSuppressed func(recipient, templateFile string)
+}
+
+// WithSuppression wraps a Sender so that emails aren't sent to the addresses for which
+// suppressed returns true, such as addresses which have bounced. Skipped emails are
+// passed to onSuppressed and otherwise treated as sent, so that they aren't retried.
+// Errors looking up an address fail the email, which is then retried as usual.
+func WithSuppression(sender Sender, suppressed func(recipient string) (bool, error), onSuppressed func(recipient, templateFile string)) Sender {
+	return suppressingSender{Sender: sender, suppressed: suppressed, onSuppressed: onSuppressed}
+}
+
+func (s suppressingSender) Send(recipient, templateFile string, data interface{}) error {
+	return s.SendMessage(NewMessage(recipient, templateFile, data))
+}
+
+func (s suppressingSender) SendMessage(msg *Message) error {
+	skip, err := s.suppressed(msg.Recipient)
+	if err != nil {
+		return err
+	}
+
+	if skip {
+		s.onSuppressed(msg.Recipient, msg.TemplateFile)
+		return nil
+	}
+
+	return s.Sender.SendMessage(msg)
+}
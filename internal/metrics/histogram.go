@@ -0,0 +1,233 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds (in seconds) used for latency histograms. They
+// range from 5ms to 10s, which covers everything from a cached read to a request
+// which is about to hit the server's write timeout.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Hold every histogram which has been created, so that they can all be written out by
+// the metrics endpoint
+var (
+	registryMutex sync.Mutex
+	registry      []*HistogramVec
+)
+
+// The quantiles estimated for each series in the JSON output
+var quantiles = []float64{0.5, 0.95, 0.99}
+
+// Define a series struct holding the observations for a single combination of label
+// values
+type series struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// Define a HistogramVec type which tracks the distribution of observed values (such as
+// durations) in cumulative buckets, with a separate series for each combination of
+// label values. It is published as an expvar variable, and can also write itself in
+// the Prometheus text exposition format.
+type HistogramVec struct {
+	name    string
+	labels  []string
+	buckets []float64
+	mutex   sync.Mutex
+	series  map[string]*series
+}
+
+// Create a new HistogramVec with the given labels and publish it under the given name.
+// As with the expvar package, creating two histograms with the same name causes a
+// panic.
+func NewHistogramVec(name string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		name:    name,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+
+	expvar.Publish(name, h)
+
+	registryMutex.Lock()
+	registry = append(registry, h)
+	registryMutex.Unlock()
+
+	return h
+}
+
+// Record an observation for the given label values, which must be given in the same
+// order as the labels
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// The key joins the values with a byte which can't appear in UTF-8 text
+	key := strings.Join(labelValues, "\xff")
+
+	s, found := h.series[key]
+	if !found {
+		s = &series{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+
+	s.count++
+	s.sum += value
+}
+
+// Reset discards all the observations recorded so far
+func (h *HistogramVec) Reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.series = make(map[string]*series)
+}
+
+// Estimate the value below which the given fraction of the observations fall, by
+// interpolating linearly within the bucket holding that rank (like Prometheus's
+// histogram_quantile() function). Observations above the largest bucket are reported
+// as its upper bound.
+func (h *HistogramVec) quantile(s *series, q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+
+	rank := q * float64(s.count)
+
+	lower, below := 0.0, 0.0
+
+	for i, bound := range h.buckets {
+		count := float64(s.counts[i])
+
+		if count >= rank {
+			if count == below {
+				return bound
+			}
+
+			return lower + (bound-lower)*(rank-below)/(count-below)
+		}
+
+		lower, below = bound, count
+	}
+
+	return lower
+}
+
+// String returns the histogram as JSON, which makes HistogramVec satisfy the
+// expvar.Var interface. Each series is keyed by its label values joined with spaces,
+// and includes estimates of the quantiles.
+func (h *HistogramVec) String() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	type jsonSeries struct {
+		Buckets   map[string]uint64  `json:"buckets"`
+		Count     uint64             `json:"count"`
+		Sum       float64            `json:"sum"`
+		Quantiles map[string]float64 `json:"quantiles"`
+	}
+
+	out := make(map[string]jsonSeries, len(h.series))
+
+	for _, s := range h.series {
+		buckets := make(map[string]uint64, len(h.buckets))
+		for i, bound := range h.buckets {
+			buckets[formatFloat(bound)] = s.counts[i]
+		}
+
+		estimates := make(map[string]float64, len(quantiles))
+		for _, q := range quantiles {
+			estimates[fmt.Sprintf("p%g", q*100)] = h.quantile(s, q)
+		}
+
+		out[strings.Join(s.labelValues, " ")] = jsonSeries{Buckets: buckets, Count: s.count, Sum: s.sum, Quantiles: estimates}
+	}
+
+	js, err := json.Marshal(out)
+	if err != nil {
+		return "{}"
+	}
+
+	return string(js)
+}
+
+// Write the histogram in the Prometheus text exposition format, with the given prefix
+// added to the metric name
+func (h *HistogramVec) WritePrometheus(w io.Writer, prefix string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	name := prefix + h.name
+
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+
+	for _, key := range keys {
+		s := h.series[key]
+		labels := h.formatLabels(s)
+
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket{%sle=%q} %d\n", name, labels, formatFloat(bound), s.counts[i])
+		}
+
+		fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, labels, s.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, strings.TrimSuffix(labels, ","), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, strings.TrimSuffix(labels, ","), s.count)
+	}
+}
+
+// Format a series' labels for the Prometheus text format, each followed by a comma
+func (h *HistogramVec) formatLabels(s *series) string {
+	var labels strings.Builder
+
+	for i, label := range h.labels {
+		value := ""
+		if i < len(s.labelValues) {
+			value = s.labelValues[i]
+		}
+
+		fmt.Fprintf(&labels, "%s=%q,", label, value)
+	}
+
+	return labels.String()
+}
+
+// Do calls fn for every histogram which has been created
+func Do(fn func(h *HistogramVec)) {
+	registryMutex.Lock()
+	histograms := make([]*HistogramVec, len(registry))
+	copy(histograms, registry)
+	registryMutex.Unlock()
+
+	for _, h := range histograms {
+		fn(h)
+	}
+}
+
+// Format a float without an exponent or trailing zeros
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
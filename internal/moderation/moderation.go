@@ -0,0 +1,98 @@
+package moderation
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Define a Screener interface for the checks run on user-submitted text before it is
+// published. Screen returns the reasons for holding the text for a moderator, which
+// are empty if it can be published straight away.
+type Screener interface {
+	Screen(ctx context.Context, text string) ([]string, error)
+}
+
+// A short list of English profanities which are screened by default. Deployments can
+// add their own words with LoadWords().
+var DefaultWords = []string{
+	"arsehole", "asshole", "bastard", "bitch", "bollocks", "bullshit", "cunt", "dickhead",
+	"fuck", "fucked", "fucker", "fucking", "motherfucker", "shit", "shitty", "twat", "wanker",
+}
+
+// Define a WordScreener type which holds text containing any word from a blocklist.
+// Words are matched whole and ignoring case, so "Scunthorpe" doesn't match "cunt".
+type WordScreener struct {
+	words map[string]bool
+}
+
+// Return a new WordScreener for the given words
+func NewWordScreener(words ...string) *WordScreener {
+	s := &WordScreener{words: make(map[string]bool, len(words))}
+
+	for _, word := range words {
+		s.words[strings.ToLower(word)] = true
+	}
+
+	return s
+}
+
+// Screen holds text containing blocklisted words, listing the words found in the reason
+func (s *WordScreener) Screen(ctx context.Context, text string) ([]string, error) {
+	found := make(map[string]bool)
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	for _, word := range words {
+		if s.words[word] {
+			found[word] = true
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	blocked := make([]string, 0, len(found))
+	for word := range found {
+		blocked = append(blocked, word)
+	}
+
+	sort.Strings(blocked)
+
+	return []string{"profanity: " + strings.Join(blocked, ", ")}, nil
+}
+
+// LoadWords reads a blocklist file containing one word per line. Blank lines and lines
+// starting with "#" are ignored.
+func LoadWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var words []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		words = append(words, line)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return words, nil
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+)
+
+// The embed colors of each level in Discord messages, as RGB integers
+var discordColors = map[string]int{
+	LevelInfo:    0x2eb67d,
+	LevelWarning: 0xecb22e,
+	LevelError:   0xe01e5a,
+}
+
+// Discord rejects embeds whose description is longer than this many characters
+const discordMaxDescription = 4096
+
+// Define a Discord type which posts messages to a Discord webhook
+type Discord struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// Return a new Discord notifier for the given webhook URL
+func NewDiscord(webhookURL string) *Discord {
+	return &Discord{
+		webhookURL: webhookURL,
+		client:     newClient(),
+	}
+}
+
+// Notify posts the message as an embed colored by its level, with the fields as inline
+// embed fields. Mentions in the text are disabled, so that a panic message can't ping
+// everyone in the channel.
+func (d *Discord) Notify(ctx context.Context, msg Message) error {
+	type field struct {
+		Name   string `json:"name"`
+		Value  string `json:"value"`
+		Inline bool   `json:"inline"`
+	}
+
+	type embed struct {
+		Title       string  `json:"title"`
+		Description string  `json:"description"`
+		Color       int     `json:"color"`
+		Fields      []field `json:"fields,omitempty"`
+	}
+
+	text := []rune(msg.Text)
+	if len(text) > discordMaxDescription {
+		text = append(text[:discordMaxDescription-1], '…')
+	}
+
+	e := embed{
+		Title:       msg.Title,
+		Description: string(text),
+		Color:       discordColors[msg.Level],
+	}
+
+	for _, name := range msg.fieldNames() {
+		e.Fields = append(e.Fields, field{Name: name, Value: msg.Fields[name], Inline: true})
+	}
+
+	payload := map[string]interface{}{
+		"embeds":           []embed{e},
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	}
+
+	return post(ctx, d.client, d.webhookURL, payload, "discord")
+}
@@ -0,0 +1,101 @@
+// Package notify posts operational messages, such as the application starting or a
+// request panicking, to chat webhooks so that the people running a deployment see
+// them without watching the logs. Slack and Discord incoming webhooks are supported.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Define the levels of a message, which set the color it is shown with
+const (
+	LevelInfo    = "info"
+	LevelWarning = "warning"
+	LevelError   = "error"
+)
+
+// Define a Message struct holding an operational message. The fields are shown below
+// the text as a list of name/value pairs.
+type Message struct {
+	Level  string
+	Title  string
+	Text   string
+	Fields map[string]string
+}
+
+// Return the names of the message's fields in a stable order
+func (m Message) fieldNames() []string {
+	names := make([]string, 0, len(m.Fields))
+	for name := range m.Fields {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Define a Notifier interface for the chat services messages can be posted to
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Define a Multi type which posts each message to several notifiers
+type Multi []Notifier
+
+// Notify posts the message to every notifier, even if posting to some of them fails,
+// and returns the first error
+func (m Multi) Notify(ctx context.Context, msg Message) error {
+	var firstErr error
+
+	for _, notifier := range m {
+		err := notifier.Notify(ctx, msg)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// Post a JSON payload to a webhook, returning an error including the start of the
+// response body if it doesn't succeed
+func post(ctx context.Context, client *http.Client, url string, payload interface{}, service string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s webhook returned unexpected status %d: %s", service, res.StatusCode, body)
+	}
+
+	return nil
+}
+
+// Return the HTTP client used to call the webhooks
+func newClient() *http.Client {
+	return &http.Client{Timeout: 10 * time.Second}
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+)
+
+// The attachment colors of each level in Slack messages
+var slackColors = map[string]string{
+	LevelInfo:    "#2eb67d",
+	LevelWarning: "#ecb22e",
+	LevelError:   "#e01e5a",
+}
+
+// Define a Slack type which posts messages to a Slack incoming webhook
+type Slack struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// Return a new Slack notifier for the given incoming webhook URL
+func NewSlack(webhookURL string) *Slack {
+	return &Slack{
+		webhookURL: webhookURL,
+		client:     newClient(),
+	}
+}
+
+// Notify posts the message as an attachment colored by its level, with the fields as
+// short attachment fields
+func (s *Slack) Notify(ctx context.Context, msg Message) error {
+	type field struct {
+		Title string `json:"title"`
+		Value string `json:"value"`
+		Short bool   `json:"short"`
+	}
+
+	type attachment struct {
+		Fallback string  `json:"fallback"`
+		Color    string  `json:"color"`
+		Title    string  `json:"title"`
+		Text     string  `json:"text"`
+		Fields   []field `json:"fields,omitempty"`
+	}
+
+	a := attachment{
+		Fallback: msg.Title,
+		Color:    slackColors[msg.Level],
+		Title:    msg.Title,
+		Text:     msg.Text,
+	}
+
+	for _, name := range msg.fieldNames() {
+		a.Fields = append(a.Fields, field{Title: name, Value: msg.Fields[name], Short: true})
+	}
+
+	payload := map[string]interface{}{
+		"attachments": []attachment{a},
+	}
+
+	return post(ctx, s.client, s.webhookURL, payload, "slack")
+}
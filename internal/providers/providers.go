@@ -0,0 +1,201 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// We'll return this when the watch-provider API doesn't know about a movie
+var ErrNotFound = errors.New("movie not found by the watch-provider API")
+
+// The base URL of the images referenced by the API, such as provider logos
+const imageBaseURL = "https://image.tmdb.org/t/p/original"
+
+// Define an Offer struct describing one way of watching a movie through a provider.
+// The type is one of "stream" (with a subscription), "free", "ads", "rent" or "buy".
+type Offer struct {
+	Type         string
+	ProviderID   int64
+	ProviderName string
+	LogoURL      string
+}
+
+// Define a CountryAvailability struct holding the offers for a movie in one country,
+// along with a link to the page listing them
+type CountryAvailability struct {
+	Link   string
+	Offers []Offer
+}
+
+// Define a Client type for a watch-provider API compatible with TMDB's version 3 API,
+// which tells us where movies can be streamed, rented or bought in each country.
+// Requests are rate limited, so that refreshing a large catalogue doesn't exceed the
+// API's limits.
+type Client struct {
+	BaseURL string
+	APIKey  string
+	Client  *http.Client
+	limiter *rate.Limiter
+}
+
+// Return a new Client for the API at the given base URL (such as
+// "https://api.themoviedb.org/3"), making at most rps requests per second
+func New(baseURL, apiKey string, rps float64) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		APIKey:  apiKey,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+		limiter: rate.NewLimiter(rate.Limit(rps), 1),
+	}
+}
+
+// Send a GET request to the API and decode the JSON response into dst. A 404 response
+// is returned as ErrNotFound.
+func (c *Client) get(ctx context.Context, path string, query url.Values, dst interface{}) error {
+	err := c.limiter.Wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("watch-provider API returned unexpected status %d for %s", res.StatusCode, path)
+	}
+
+	return json.NewDecoder(res.Body).Decode(dst)
+}
+
+// FindMovie returns the API's ID for the movie with the given title and release year.
+// A result whose title matches exactly (ignoring case) is preferred over the API's
+// best match.
+func (c *Client) FindMovie(ctx context.Context, title string, year int32) (int64, error) {
+	var response struct {
+		Results []struct {
+			ID    int64  `json:"id"`
+			Title string `json:"title"`
+		} `json:"results"`
+	}
+
+	query := url.Values{
+		"query":         {title},
+		"year":          {strconv.Itoa(int(year))},
+		"include_adult": {"false"},
+	}
+
+	err := c.get(ctx, "/search/movie", query, &response)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(response.Results) == 0 {
+		return 0, ErrNotFound
+	}
+
+	for _, result := range response.Results {
+		if strings.EqualFold(result.Title, title) {
+			return result.ID, nil
+		}
+	}
+
+	return response.Results[0].ID, nil
+}
+
+// Define the JSON structure of a provider in the API's responses
+type apiProvider struct {
+	ID              int64  `json:"provider_id"`
+	Name            string `json:"provider_name"`
+	LogoPath        string `json:"logo_path"`
+	DisplayPriority int    `json:"display_priority"`
+}
+
+// Availability returns the offers for the movie with the given API ID, keyed by
+// ISO 3166-1 alpha-2 country code. Within each type, offers are in the order the API
+// recommends displaying them.
+func (c *Client) Availability(ctx context.Context, id int64) (map[string]CountryAvailability, error) {
+	var response struct {
+		Results map[string]struct {
+			Link     string        `json:"link"`
+			Flatrate []apiProvider `json:"flatrate"`
+			Free     []apiProvider `json:"free"`
+			Ads      []apiProvider `json:"ads"`
+			Rent     []apiProvider `json:"rent"`
+			Buy      []apiProvider `json:"buy"`
+		} `json:"results"`
+	}
+
+	err := c.get(ctx, fmt.Sprintf("/movie/%d/watch/providers", id), nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	availability := make(map[string]CountryAvailability, len(response.Results))
+
+	for country, result := range response.Results {
+		var offers []Offer
+
+		for _, group := range []struct {
+			offerType string
+			providers []apiProvider
+		}{
+			{"stream", result.Flatrate},
+			{"free", result.Free},
+			{"ads", result.Ads},
+			{"rent", result.Rent},
+			{"buy", result.Buy},
+		} {
+			sort.SliceStable(group.providers, func(i, j int) bool {
+				return group.providers[i].DisplayPriority < group.providers[j].DisplayPriority
+			})
+
+			for _, provider := range group.providers {
+				offer := Offer{
+					Type:         group.offerType,
+					ProviderID:   provider.ID,
+					ProviderName: provider.Name,
+				}
+
+				if provider.LogoPath != "" {
+					offer.LogoURL = imageBaseURL + provider.LogoPath
+				}
+
+				offers = append(offers, offer)
+			}
+		}
+
+		availability[country] = CountryAvailability{Link: result.Link, Offers: offers}
+	}
+
+	return availability, nil
+}
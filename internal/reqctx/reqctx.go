@@ -0,0 +1,86 @@
+package reqctx
+
+import (
+	"context"
+	"strconv"
+)
+
+// Define a contextKey type so that the key can't collide with keys from other packages
+type contextKey struct{}
+
+// Define a Values struct holding the values scoped to a single request which are
+// useful outside of the HTTP handlers, such as in the models and in log entries. It
+// is added to the context once per request and filled in as the request passes
+// through the middleware, so it is shared by every copy of the request.
+type Values struct {
+	RequestID string
+	TraceID   string
+	SpanID    string
+	UserID    int64
+	Locale    string
+	Tenant    string
+	Flags     map[string]bool
+
+	// The admin acting as the user, for requests made with an impersonation token
+	ImpersonatorID int64
+}
+
+// Return a copy of the context carrying the given values
+func NewContext(ctx context.Context, values *Values) context.Context {
+	return context.WithValue(ctx, contextKey{}, values)
+}
+
+// Return the values carried by the context, or nil if there aren't any (for example
+// for background jobs)
+func FromContext(ctx context.Context) *Values {
+	values, _ := ctx.Value(contextKey{}).(*Values)
+
+	return values
+}
+
+// Report whether the feature flag was enabled when the request started. It is safe to
+// call on nil values, in which case every flag is off.
+func (v *Values) Enabled(flag string) bool {
+	if v == nil {
+		return false
+	}
+
+	return v.Flags[flag]
+}
+
+// Return the values to include in a log entry for the request, leaving out the ones
+// which aren't set. It is safe to call on nil values.
+func (v *Values) LogProperties() map[string]string {
+	properties := map[string]string{}
+
+	if v == nil {
+		return properties
+	}
+
+	if v.RequestID != "" {
+		properties["request_id"] = v.RequestID
+	}
+
+	if v.TraceID != "" {
+		properties["trace_id"] = v.TraceID
+		properties["span_id"] = v.SpanID
+	}
+
+	if v.UserID != 0 {
+		properties["user_id"] = strconv.FormatInt(v.UserID, 10)
+	}
+
+	if v.ImpersonatorID != 0 {
+		properties["impersonator_id"] = strconv.FormatInt(v.ImpersonatorID, 10)
+	}
+
+	if v.Locale != "" {
+		properties["locale"] = v.Locale
+	}
+
+	if v.Tenant != "" {
+		properties["tenant"] = v.Tenant
+	}
+
+	return properties
+}
@@ -0,0 +1,158 @@
+// Package schema carries out schema changes on large tables which can't be made in a
+// migration without blocking writes to the table for as long as they take: building
+// indexes, which is done concurrently, and backfilling columns, which is done in small
+// batches so that each batch only holds its row locks briefly.
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// The identifiers allowed in index and table names. The changes are defined in code,
+// but they are written into the statements, so they are checked all the same.
+var identifierRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Define an Index struct describing an index to build concurrently. The definition is
+// what follows the table name in the CREATE INDEX statement, such as "(updated_at)" or
+// "USING GIN (genres) WHERE status = 'published'".
+type Index struct {
+	Name       string
+	Table      string
+	Definition string
+}
+
+// Define a Backfill struct describing a column backfill. The rows of the table which
+// match Where are updated with the Set clause, in batches of BatchSize consecutive IDs.
+// The table must have a bigserial id column, and Where must stop matching the rows once
+// they have been updated, so that a backfill which is interrupted and run again
+// doesn't update them twice.
+type Backfill struct {
+	Table     string
+	Set       string
+	Where     string
+	BatchSize int64
+}
+
+// Define a Runner type which carries out schema changes on a database
+type Runner struct {
+	DB *sql.DB
+
+	// How long each statement waits for the locks it needs before failing, so that a
+	// change stuck behind a long transaction doesn't make every query on the table
+	// queue up behind it. Zero waits forever.
+	LockTimeout time.Duration
+}
+
+// CreateIndex builds the index without blocking writes to the table. A build which
+// failed or was interrupted leaves an invalid index behind, which is dropped and built
+// again. It does nothing if the index already exists and is valid.
+func (r Runner) CreateIndex(ctx context.Context, index Index) error {
+	if !identifierRegex.MatchString(index.Name) || !identifierRegex.MatchString(index.Table) {
+		return fmt.Errorf("invalid index or table name %q on %q", index.Name, index.Table)
+	}
+
+	// The lock timeout is set on the connection, so all the statements must use the
+	// same one. CREATE INDEX CONCURRENTLY can't run in a transaction.
+	conn, err := r.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("SET lock_timeout = %d", r.LockTimeout.Milliseconds()))
+	if err != nil {
+		return err
+	}
+
+	// Reset the setting before the connection goes back to the pool
+	defer conn.ExecContext(context.Background(), "RESET lock_timeout")
+
+	var valid sql.NullBool
+
+	query := `
+		SELECT i.indisvalid
+		FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		WHERE c.relname = $1 AND pg_table_is_visible(c.oid)`
+
+	err = conn.QueryRowContext(ctx, query, index.Name).Scan(&valid)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if valid.Valid && valid.Bool {
+		return nil
+	}
+
+	if valid.Valid {
+		_, err = conn.ExecContext(ctx, fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s", index.Name))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("CREATE INDEX CONCURRENTLY %s ON %s %s", index.Name, index.Table, index.Definition))
+
+	return err
+}
+
+// MaxID returns the largest ID of the backfill's table, which is where the backfill
+// ends. Rows inserted after the backfill starts are expected to be written with the
+// new column already set.
+func (r Runner) MaxID(ctx context.Context, backfill Backfill) (int64, error) {
+	if !identifierRegex.MatchString(backfill.Table) {
+		return 0, fmt.Errorf("invalid table name %q", backfill.Table)
+	}
+
+	var maxID int64
+
+	err := r.DB.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(id), 0) FROM %s", backfill.Table)).Scan(&maxID)
+
+	return maxID, err
+}
+
+// Batch backfills the rows with IDs after the given one, up to BatchSize IDs later,
+// returning the number of rows updated and the last ID of the batch
+func (r Runner) Batch(ctx context.Context, backfill Backfill, after int64) (int64, int64, error) {
+	if !identifierRegex.MatchString(backfill.Table) {
+		return 0, 0, fmt.Errorf("invalid table name %q", backfill.Table)
+	}
+
+	if backfill.BatchSize < 1 {
+		return 0, 0, fmt.Errorf("invalid batch size %d", backfill.BatchSize)
+	}
+
+	last := after + backfill.BatchSize
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer tx.Rollback()
+
+	// SET LOCAL only lasts until the end of the transaction
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL lock_timeout = %d", r.LockTimeout.Milliseconds()))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id > $1 AND id <= $2 AND (%s)", backfill.Table, backfill.Set, backfill.Where)
+
+	result, err := tx.ExecContext(ctx, query, after, last)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return updated, last, tx.Commit()
+}
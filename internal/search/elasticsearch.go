@@ -0,0 +1,514 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+)
+
+// The mappings of the movies index. Titles are only lowercased and split into words
+// (the Elasticsearch "simple" analyzer would drop digits too) and synopses are stemmed,
+// like the "simple" and "english" configurations of the PostgreSQL search. Titles are
+// also kept as keywords so that they can be sorted on.
+var movieMappings = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"dynamic": "strict",
+		"properties": map[string]interface{}{
+			"id": map[string]string{"type": "long"},
+			"title": map[string]interface{}{
+				"type":     "text",
+				"analyzer": "standard",
+				"fields": map[string]interface{}{
+					"raw": map[string]string{"type": "keyword"},
+				},
+			},
+			"year":                 map[string]string{"type": "integer"},
+			"runtime":              map[string]string{"type": "integer"},
+			"genres":               map[string]string{"type": "keyword"},
+			"synopsis":             map[string]string{"type": "text", "analyzer": "english"},
+			"original_language":    map[string]string{"type": "keyword"},
+			"production_countries": map[string]string{"type": "keyword"},
+			"certifications":       map[string]string{"type": "keyword"},
+			"created_at":           map[string]string{"type": "date"},
+		},
+	},
+}
+
+// The fields movies can be sorted on, mapped to the fields of the index
+var sortFields = map[string]string{
+	"id":      "id",
+	"title":   "title.raw",
+	"year":    "year",
+	"runtime": "runtime",
+}
+
+// The aggregations counting the facets of a search. Movies without a runtime are stored
+// with a zero runtime, which is left out of the runtime ranges.
+var facetAggregations = map[string]interface{}{
+	"genres": map[string]interface{}{
+		"terms": map[string]interface{}{"field": "genres", "size": 100},
+	},
+	"years": map[string]interface{}{
+		"terms": map[string]interface{}{"field": "year", "size": 500},
+	},
+	"runtimes": map[string]interface{}{
+		"filter": map[string]interface{}{
+			"range": map[string]interface{}{"runtime": map[string]int{"gt": 0}},
+		},
+		"aggs": map[string]interface{}{
+			"ranges": map[string]interface{}{
+				"histogram": map[string]interface{}{"field": "runtime", "interval": data.RuntimeRangeWidth, "min_doc_count": 1},
+			},
+		},
+	},
+	"certifications": map[string]interface{}{
+		"terms": map[string]interface{}{"field": "certifications", "size": 500},
+	},
+}
+
+// Define a document struct holding the indexed fields of a movie. Certifications are
+// indexed as "<country>:<certification>" keywords.
+type document struct {
+	ID                  int64     `json:"id"`
+	Title               string    `json:"title"`
+	Year                int32     `json:"year"`
+	Runtime             int32     `json:"runtime"`
+	Genres              []string  `json:"genres"`
+	Synopsis            string    `json:"synopsis"`
+	OriginalLanguage    string    `json:"original_language"`
+	ProductionCountries []string  `json:"production_countries"`
+	Certifications      []string  `json:"certifications"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// Return the document indexed for a movie
+func newDocument(movie *data.Movie) document {
+	return document{
+		ID:                  movie.ID,
+		Title:               movie.Title,
+		Year:                movie.Year,
+		Runtime:             int32(movie.Runtime),
+		Genres:              movie.Genres,
+		Synopsis:            movie.Synopsis,
+		OriginalLanguage:    movie.OriginalLanguage,
+		ProductionCountries: movie.ProductionCountries,
+		Certifications:      certificationTerms(movie.Certifications),
+		CreatedAt:           movie.CreatedAt,
+	}
+}
+
+// Define an Elasticsearch type which is a client for the subset of the Elasticsearch
+// REST API needed to index and search movies. OpenSearch serves the same API, so it
+// works with it too.
+type Elasticsearch struct {
+	baseURL  string
+	index    string
+	username string
+	password string
+	client   *http.Client
+}
+
+// Return a new Elasticsearch client for the cluster at the given URL, in the format
+// "http[s]://[user:password@]host:port", storing movies in the given index
+func NewElasticsearch(rawURL, index string) (*Elasticsearch, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+
+	es := &Elasticsearch{
+		index:  index,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if u.User != nil {
+		es.username = u.User.Username()
+		es.password, _ = u.User.Password()
+		u.User = nil
+	}
+
+	es.baseURL = strings.TrimSuffix(u.String(), "/")
+
+	return es, nil
+}
+
+// EnsureIndex creates the movies index if it doesn't exist yet, reporting whether it
+// did, in which case the movies need to be indexed
+func (es *Elasticsearch) EnsureIndex(ctx context.Context) (bool, error) {
+	res, err := es.do(ctx, http.MethodHead, "/"+es.index, nil)
+	if err != nil {
+		return false, err
+	}
+
+	res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return false, nil
+	case http.StatusNotFound:
+	default:
+		return false, fmt.Errorf("elasticsearch returned unexpected status %d", res.StatusCode)
+	}
+
+	res, err = es.do(ctx, http.MethodPut, "/"+es.index, movieMappings)
+	if err != nil {
+		return false, err
+	}
+
+	defer res.Body.Close()
+
+	// Another instance may have created the index at the same time
+	if res.StatusCode == http.StatusBadRequest {
+		body, _ := io.ReadAll(res.Body)
+		if bytes.Contains(body, []byte("resource_already_exists_exception")) {
+			return false, nil
+		}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("elasticsearch returned unexpected status %d", res.StatusCode)
+	}
+
+	return true, nil
+}
+
+// Index adds or replaces the documents of the given movies. The movie version is used
+// as the document version, so that an older version of a movie never replaces a newer
+// one when indexing requests race.
+func (es *Elasticsearch) Index(ctx context.Context, movies ...*data.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+
+	enc := json.NewEncoder(&body)
+
+	for _, movie := range movies {
+		action := map[string]interface{}{
+			"index": map[string]interface{}{
+				"_index":       es.index,
+				"_id":          strconv.FormatInt(movie.ID, 10),
+				"version":      movie.Version,
+				"version_type": "external",
+			},
+		}
+
+		err := enc.Encode(action)
+		if err != nil {
+			return err
+		}
+
+		err = enc.Encode(newDocument(movie))
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := es.do(ctx, http.MethodPost, "/_bulk", &body)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("elasticsearch returned unexpected status %d", res.StatusCode)
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&result)
+	if err != nil {
+		return err
+	}
+
+	if !result.Errors {
+		return nil
+	}
+
+	// A conflict means that a newer version of the movie is already indexed
+	for _, item := range result.Items {
+		for _, outcome := range item {
+			if outcome.Status >= 300 && outcome.Status != http.StatusConflict {
+				return fmt.Errorf("elasticsearch failed to index movie %s: %s", outcome.ID, outcome.Error)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the document of a movie, if there is one
+func (es *Elasticsearch) Delete(ctx context.Context, id int64) error {
+	res, err := es.do(ctx, http.MethodDelete, "/"+es.index+"/_doc/"+strconv.FormatInt(id, 10), nil)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("elasticsearch returned unexpected status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Search returns the IDs of the movies matching the query in the query's window, along
+// with the total number of matches. It implements the data.MovieIndex interface.
+func (es *Elasticsearch) Search(ctx context.Context, query data.MovieIndexQuery) (data.MovieIndexResult, error) {
+	field, ok := sortFields[query.SortColumn]
+	if !ok {
+		return data.MovieIndexResult{}, fmt.Errorf("unsupported sort column %q", query.SortColumn)
+	}
+
+	order := "asc"
+	if query.Descending {
+		order = "desc"
+	}
+
+	body := map[string]interface{}{
+		"from":             query.Offset,
+		"size":             query.Limit,
+		"track_total_hits": true,
+		"_source":          false,
+		"query":            searchQuery(query.MovieSearch),
+		"sort": []map[string]string{
+			{field: order},
+			{"id": "asc"},
+		},
+	}
+
+	res, err := es.do(ctx, http.MethodPost, "/"+es.index+"/_search", body)
+	if err != nil {
+		return data.MovieIndexResult{}, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return data.MovieIndexResult{}, fmt.Errorf("elasticsearch returned unexpected status %d", res.StatusCode)
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				ID string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&result)
+	if err != nil {
+		return data.MovieIndexResult{}, err
+	}
+
+	ids := make([]int64, 0, len(result.Hits.Hits))
+
+	for _, hit := range result.Hits.Hits {
+		id, err := strconv.ParseInt(hit.ID, 10, 64)
+		if err != nil {
+			return data.MovieIndexResult{}, fmt.Errorf("elasticsearch returned invalid movie ID %q", hit.ID)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return data.MovieIndexResult{
+		IDs:          ids,
+		TotalRecords: result.Hits.Total.Value,
+	}, nil
+}
+
+// Facets counts the movies matching a search by genre, year, runtime range and
+// certification, with an aggregation per facet. Decades are counted from the years. It
+// implements the data.MovieIndex interface.
+func (es *Elasticsearch) Facets(ctx context.Context, search data.MovieSearch) (*data.MovieFacets, error) {
+	body := map[string]interface{}{
+		"size":  0,
+		"query": searchQuery(search),
+		"aggs":  facetAggregations,
+	}
+
+	res, err := es.do(ctx, http.MethodPost, "/"+es.index+"/_search", body)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("elasticsearch returned unexpected status %d", res.StatusCode)
+	}
+
+	// Keys are strings for keyword fields, integers for the year and floats (such as
+	// 90.0) for the runtime histogram
+	type buckets struct {
+		Buckets []struct {
+			Key      json.Number `json:"key"`
+			DocCount int         `json:"doc_count"`
+		} `json:"buckets"`
+	}
+
+	var result struct {
+		Aggregations struct {
+			Genres   buckets `json:"genres"`
+			Years    buckets `json:"years"`
+			Runtimes struct {
+				Ranges buckets `json:"ranges"`
+			} `json:"runtimes"`
+			Certifications buckets `json:"certifications"`
+		} `json:"aggregations"`
+	}
+
+	dec := json.NewDecoder(res.Body)
+	dec.UseNumber()
+
+	err = dec.Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	facets := data.NewMovieFacets()
+
+	for _, b := range result.Aggregations.Genres.Buckets {
+		facets.Genres[b.Key.String()] = b.DocCount
+	}
+
+	for _, b := range result.Aggregations.Years.Buckets {
+		year, err := b.Key.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("elasticsearch returned invalid year bucket %q", b.Key)
+		}
+
+		facets.AddYear(int(year), b.DocCount)
+	}
+
+	for _, b := range result.Aggregations.Runtimes.Ranges.Buckets {
+		start, err := b.Key.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("elasticsearch returned invalid runtime bucket %q", b.Key)
+		}
+
+		facets.AddRuntime(int(start), b.DocCount)
+	}
+
+	for _, b := range result.Aggregations.Certifications.Buckets {
+		facets.Certifications[b.Key.String()] = b.DocCount
+	}
+
+	return facets, nil
+}
+
+// Return the bool query matching a movie search. Every word of the title and synopsis
+// searches must match, like plainto_tsquery() in PostgreSQL. Release dates aren't
+// indexed, so searches on them are run against PostgreSQL instead.
+func searchQuery(search data.MovieSearch) map[string]interface{} {
+	must := []interface{}{}
+	filter := []interface{}{}
+
+	if search.Title != "" {
+		must = append(must, map[string]interface{}{
+			"match": map[string]interface{}{"title": map[string]string{"query": search.Title, "operator": "and"}},
+		})
+	}
+
+	if search.Synopsis != "" {
+		must = append(must, map[string]interface{}{
+			"match": map[string]interface{}{"synopsis": map[string]string{"query": search.Synopsis, "operator": "and"}},
+		})
+	}
+
+	for _, genre := range search.Genres {
+		filter = append(filter, map[string]interface{}{"term": map[string]string{"genres": genre}})
+	}
+
+	if search.Language != "" {
+		filter = append(filter, map[string]interface{}{"term": map[string]string{"original_language": search.Language}})
+	}
+
+	for _, country := range search.Countries {
+		filter = append(filter, map[string]interface{}{"term": map[string]string{"production_countries": country}})
+	}
+
+	for _, term := range certificationTerms(search.Certifications) {
+		filter = append(filter, map[string]interface{}{"term": map[string]string{"certifications": term}})
+	}
+
+	created := map[string]string{}
+
+	if !search.CreatedBefore.IsZero() {
+		created["lt"] = search.CreatedBefore.Format(time.RFC3339Nano)
+	}
+
+	if !search.CreatedAfter.IsZero() {
+		created["gt"] = search.CreatedAfter.Format(time.RFC3339Nano)
+	}
+
+	if len(created) > 0 {
+		filter = append(filter, map[string]interface{}{"range": map[string]interface{}{"created_at": created}})
+	}
+
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":   must,
+			"filter": filter,
+		},
+	}
+}
+
+// Send a request to the cluster. The body is encoded as JSON, unless it is already a
+// buffer of newline delimited JSON for the bulk API.
+func (es *Elasticsearch) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	contentType := "application/json"
+
+	switch b := body.(type) {
+	case nil:
+	case *bytes.Buffer:
+		reader = b
+		contentType = "application/x-ndjson"
+	default:
+		js, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+
+		reader = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, es.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if reader != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if es.username != "" {
+		req.SetBasicAuth(es.username, es.password)
+	}
+
+	return es.client.Do(req)
+}
@@ -0,0 +1,466 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+)
+
+// The settings of the movies index. Meilisearch is typo tolerant by default and doesn't
+// stem words, so searches are more forgiving of misspellings and less of word forms than
+// the PostgreSQL and Elasticsearch ones. The facets are counted over the filterable
+// attributes.
+var movieSettings = map[string]interface{}{
+	"searchableAttributes": []string{"title", "synopsis"},
+	"filterableAttributes": []string{
+		"genres", "year", "runtime_range", "original_language", "production_countries",
+		"certifications", "created_at",
+	},
+	"sortableAttributes": []string{"id", "title", "year", "runtime"},
+	"typoTolerance":      map[string]bool{"enabled": true},
+	"faceting":           map[string]int{"maxValuesPerFacet": 200},
+	"pagination":         map[string]int{"maxTotalHits": MaxResultWindow},
+}
+
+// The attributes facets are counted for
+var facetAttributes = []string{"genres", "year", "runtime_range", "certifications"}
+
+// Define a meiliDocument struct holding the indexed fields of a movie. Meilisearch can
+// only filter on numbers, so the creation time is indexed in Unix milliseconds. Runtime
+// ranges are only indexed for movies with a runtime, so that unknown runtimes aren't
+// counted in the facets.
+type meiliDocument struct {
+	ID                  int64    `json:"id"`
+	Title               string   `json:"title"`
+	Year                int32    `json:"year"`
+	Runtime             int32    `json:"runtime"`
+	RuntimeRange        string   `json:"runtime_range,omitempty"`
+	Genres              []string `json:"genres"`
+	Synopsis            string   `json:"synopsis"`
+	OriginalLanguage    string   `json:"original_language"`
+	ProductionCountries []string `json:"production_countries"`
+	Certifications      []string `json:"certifications"`
+	CreatedAt           int64    `json:"created_at"`
+}
+
+// Return the document indexed for a movie
+func newMeiliDocument(movie *data.Movie) meiliDocument {
+	doc := meiliDocument{
+		ID:                  movie.ID,
+		Title:               movie.Title,
+		Year:                movie.Year,
+		Runtime:             int32(movie.Runtime),
+		Genres:              movie.Genres,
+		Synopsis:            movie.Synopsis,
+		OriginalLanguage:    movie.OriginalLanguage,
+		ProductionCountries: movie.ProductionCountries,
+		Certifications:      certificationTerms(movie.Certifications),
+		CreatedAt:           unixMilli(movie.CreatedAt),
+	}
+
+	if movie.Runtime > 0 {
+		doc.RuntimeRange = data.RuntimeRange(int(movie.Runtime))
+	}
+
+	return doc
+}
+
+// Define a Meilisearch type which is a client for the subset of the Meilisearch API
+// needed to index and search movies. Meilisearch processes writes asynchronously, so
+// the client waits for each write to be processed, in order to report its errors.
+type Meilisearch struct {
+	baseURL string
+	index   string
+	apiKey  string
+	client  *http.Client
+}
+
+// Return a new Meilisearch client for the instance at the given URL, in the format
+// "http[s]://host:port", storing movies in the given index. The API key may be empty if
+// the instance doesn't require one.
+func NewMeilisearch(rawURL, index, apiKey string) (*Meilisearch, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+
+	return &Meilisearch{
+		baseURL: strings.TrimSuffix(u.String(), "/"),
+		index:   index,
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// EnsureIndex creates the movies index if it doesn't exist yet, reporting whether it
+// did, in which case the movies need to be indexed. The settings are applied either way,
+// so that indexes created by older versions are brought up to date.
+func (ms *Meilisearch) EnsureIndex(ctx context.Context) (bool, error) {
+	res, err := ms.do(ctx, http.MethodGet, "/indexes/"+ms.index, nil)
+	if err != nil {
+		return false, err
+	}
+
+	res.Body.Close()
+
+	created := false
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		err = ms.write(ctx, http.MethodPost, "/indexes", map[string]string{"uid": ms.index, "primaryKey": "id"})
+		if err != nil {
+			// Another instance may have created the index at the same time
+			if !strings.Contains(err.Error(), "index_already_exists") {
+				return false, err
+			}
+		} else {
+			created = true
+		}
+	default:
+		return false, fmt.Errorf("meilisearch returned unexpected status %d", res.StatusCode)
+	}
+
+	// The movies are indexed even if the settings failed to apply, as they are applied
+	// again on the next start
+	err = ms.write(ctx, http.MethodPatch, "/indexes/"+ms.index+"/settings", movieSettings)
+	if err != nil {
+		return created, err
+	}
+
+	return created, nil
+}
+
+// Index adds or replaces the documents of the given movies
+func (ms *Meilisearch) Index(ctx context.Context, movies ...*data.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	docs := make([]meiliDocument, 0, len(movies))
+
+	for _, movie := range movies {
+		docs = append(docs, newMeiliDocument(movie))
+	}
+
+	return ms.write(ctx, http.MethodPost, "/indexes/"+ms.index+"/documents", docs)
+}
+
+// Delete removes the document of a movie, if there is one
+func (ms *Meilisearch) Delete(ctx context.Context, id int64) error {
+	return ms.write(ctx, http.MethodDelete, "/indexes/"+ms.index+"/documents/"+strconv.FormatInt(id, 10), nil)
+}
+
+// Search returns the IDs of the movies matching the query in the query's window, along
+// with the total number of matches. It implements the data.MovieIndex interface. The
+// window is requested as a page, so its offset must be a multiple of its limit, which is
+// always the case for the windows of data.Filters.
+func (ms *Meilisearch) Search(ctx context.Context, query data.MovieIndexQuery) (data.MovieIndexResult, error) {
+	if _, ok := sortFields[query.SortColumn]; !ok {
+		return data.MovieIndexResult{}, fmt.Errorf("unsupported sort column %q", query.SortColumn)
+	}
+
+	order := "asc"
+	if query.Descending {
+		order = "desc"
+	}
+
+	body := meiliSearch(query.MovieSearch)
+	body["page"] = query.Offset/query.Limit + 1
+	body["hitsPerPage"] = query.Limit
+	body["sort"] = []string{query.SortColumn + ":" + order, "id:asc"}
+	body["attributesToRetrieve"] = []string{"id"}
+
+	var result struct {
+		TotalHits int `json:"totalHits"`
+		Hits      []struct {
+			ID int64 `json:"id"`
+		} `json:"hits"`
+	}
+
+	err := ms.search(ctx, body, &result)
+	if err != nil {
+		return data.MovieIndexResult{}, err
+	}
+
+	ids := make([]int64, 0, len(result.Hits))
+
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.ID)
+	}
+
+	return data.MovieIndexResult{
+		IDs:          ids,
+		TotalRecords: result.TotalHits,
+	}, nil
+}
+
+// Facets counts the movies matching a search by genre, year, runtime range and
+// certification, from the facet distribution of a search returning no hits. Decades
+// are counted from the years. It implements the data.MovieIndex interface.
+func (ms *Meilisearch) Facets(ctx context.Context, search data.MovieSearch) (*data.MovieFacets, error) {
+	body := meiliSearch(search)
+	body["page"] = 1
+	body["hitsPerPage"] = 0
+	body["facets"] = facetAttributes
+
+	var result struct {
+		FacetDistribution map[string]map[string]int `json:"facetDistribution"`
+	}
+
+	err := ms.search(ctx, body, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	facets := data.NewMovieFacets()
+
+	for genre, count := range result.FacetDistribution["genres"] {
+		facets.Genres[genre] = count
+	}
+
+	for value, count := range result.FacetDistribution["year"] {
+		year, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("meilisearch returned invalid year facet %q", value)
+		}
+
+		facets.AddYear(year, count)
+	}
+
+	// Runtime ranges are indexed with the same keys as the facets
+	for runtime, count := range result.FacetDistribution["runtime_range"] {
+		facets.Runtimes[runtime] = count
+	}
+
+	for certification, count := range result.FacetDistribution["certifications"] {
+		facets.Certifications[certification] = count
+	}
+
+	return facets, nil
+}
+
+// Return the body of a search request for the movies matching a search. Every word of
+// the title and synopsis searches must match, like plainto_tsquery() in PostgreSQL.
+// When both are given, each word may match either attribute.
+func meiliSearch(search data.MovieSearch) map[string]interface{} {
+	body := map[string]interface{}{
+		"filter": meiliFilter(search),
+	}
+
+	switch {
+	case search.Title != "" && search.Synopsis != "":
+		body["q"] = search.Title + " " + search.Synopsis
+	case search.Title != "":
+		body["q"] = search.Title
+		body["attributesToSearchOn"] = []string{"title"}
+	case search.Synopsis != "":
+		body["q"] = search.Synopsis
+		body["attributesToSearchOn"] = []string{"synopsis"}
+	}
+
+	if _, ok := body["q"]; ok {
+		body["matchingStrategy"] = "all"
+	}
+
+	return body
+}
+
+// Run a search request, decoding the response into dst
+func (ms *Meilisearch) search(ctx context.Context, body map[string]interface{}, dst interface{}) error {
+	res, err := ms.do(ctx, http.MethodPost, "/indexes/"+ms.index+"/search", body)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return meiliError(res)
+	}
+
+	return json.NewDecoder(res.Body).Decode(dst)
+}
+
+// Return the filter expressions matching a movie search, which must all match. Release
+// dates aren't indexed, so searches on them are run against PostgreSQL instead.
+func meiliFilter(search data.MovieSearch) []string {
+	filter := []string{}
+
+	for _, genre := range search.Genres {
+		filter = append(filter, "genres = "+quoteFilterValue(genre))
+	}
+
+	if search.Language != "" {
+		filter = append(filter, "original_language = "+quoteFilterValue(search.Language))
+	}
+
+	for _, country := range search.Countries {
+		filter = append(filter, "production_countries = "+quoteFilterValue(country))
+	}
+
+	for _, term := range certificationTerms(search.Certifications) {
+		filter = append(filter, "certifications = "+quoteFilterValue(term))
+	}
+
+	if !search.CreatedBefore.IsZero() {
+		filter = append(filter, "created_at < "+strconv.FormatInt(unixMilli(search.CreatedBefore), 10))
+	}
+
+	if !search.CreatedAfter.IsZero() {
+		filter = append(filter, "created_at > "+strconv.FormatInt(unixMilli(search.CreatedAfter), 10))
+	}
+
+	return filter
+}
+
+// Return a string quoted for use in a filter expression
+func quoteFilterValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// Return the number of milliseconds since the Unix epoch of a time
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// Send a write request and wait for the task it enqueued to be processed, returning
+// an error if it failed. Waiting is bounded by the context.
+func (ms *Meilisearch) write(ctx context.Context, method, path string, body interface{}) error {
+	res, err := ms.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusAccepted {
+		return meiliError(res)
+	}
+
+	var task struct {
+		TaskUID int64 `json:"taskUid"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&task)
+	if err != nil {
+		return err
+	}
+
+	delay := 10 * time.Millisecond
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		if delay < time.Second {
+			delay *= 2
+		}
+
+		status, err := ms.taskStatus(ctx, task.TaskUID)
+		if err != nil || status == "" {
+			return err
+		}
+	}
+}
+
+// Return the status of a task, or an empty string once it has succeeded. An error is
+// returned if it failed.
+func (ms *Meilisearch) taskStatus(ctx context.Context, uid int64) (string, error) {
+	res, err := ms.do(ctx, http.MethodGet, "/tasks/"+strconv.FormatInt(uid, 10), nil)
+	if err != nil {
+		return "", err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", meiliError(res)
+	}
+
+	var task struct {
+		Status string `json:"status"`
+		Error  *struct {
+			Message string `json:"message"`
+			Code    string `json:"code"`
+		} `json:"error"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&task)
+	if err != nil {
+		return "", err
+	}
+
+	switch task.Status {
+	case "succeeded":
+		return "", nil
+	case "failed":
+		if task.Error != nil {
+			return "", fmt.Errorf("meilisearch task %d failed: %s (%s)", uid, task.Error.Message, task.Error.Code)
+		}
+
+		return "", fmt.Errorf("meilisearch task %d failed", uid)
+	case "canceled":
+		return "", fmt.Errorf("meilisearch task %d was canceled", uid)
+	default:
+		return task.Status, nil
+	}
+}
+
+// Return an error describing an unexpected response, including the error code
+// Meilisearch sent if there is one
+func meiliError(res *http.Response) error {
+	var body struct {
+		Message string `json:"message"`
+		Code    string `json:"code"`
+	}
+
+	js, _ := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+
+	if json.Unmarshal(js, &body) == nil && body.Code != "" {
+		return fmt.Errorf("meilisearch returned status %d: %s (%s)", res.StatusCode, body.Message, body.Code)
+	}
+
+	return fmt.Errorf("meilisearch returned unexpected status %d", res.StatusCode)
+}
+
+// Send a request to the instance, with the body encoded as JSON
+func (ms *Meilisearch) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		reader = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, ms.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if ms.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+ms.apiKey)
+	}
+
+	return ms.client.Do(req)
+}
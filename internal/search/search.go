@@ -0,0 +1,43 @@
+// Package search keeps a copy of the movies in a search engine, for catalogs too large
+// for PostgreSQL's full-text search to handle well, and runs movie searches against it.
+// Elasticsearch (or OpenSearch) suits large deployments, and Meilisearch smaller ones.
+package search
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/LuisBarroso37/Greenlight/internal/data"
+)
+
+// The number of results searches can page through. This is the default of the
+// Elasticsearch index.max_result_window setting, and the Meilisearch indexes are created
+// with the same limit.
+const MaxResultWindow = 10000
+
+// We'll return this from the constructors when the URL isn't in the expected format
+var ErrInvalidURL = errors.New("invalid search engine URL")
+
+// Define an Index interface for a search engine holding a copy of the movies. EnsureIndex()
+// creates the index if it doesn't exist yet, reporting whether it did, in which case the
+// movies need to be indexed.
+type Index interface {
+	data.MovieIndex
+	EnsureIndex(ctx context.Context) (bool, error)
+	Index(ctx context.Context, movies ...*data.Movie) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// Return the keywords for a set of certifications, sorted so that they are stable
+func certificationTerms(certifications data.Certifications) []string {
+	terms := make([]string, 0, len(certifications))
+
+	for country, certification := range certifications {
+		terms = append(terms, country+":"+certification)
+	}
+
+	sort.Strings(terms)
+
+	return terms
+}
@@ -0,0 +1,185 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Define an AWSProvider type which reads secrets from AWS Secrets Manager. Requests are
+// signed with AWS Signature Version 4 using the static credentials in the struct.
+type AWSProvider struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Endpoint        string
+	Client          *http.Client
+}
+
+// Return a new AWSProvider for the given region and credentials. The session token is
+// only required when using temporary credentials.
+func NewAWSProvider(region, accessKeyID, secretAccessKey, sessionToken string) *AWSProvider {
+	return &AWSProvider{
+		Region:          region,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SessionToken:    sessionToken,
+		Endpoint:        fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region),
+		Client:          &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Fetch retrieves the current version of the secret whose name is the given path. If
+// a key is provided then the secret string is expected to be a JSON object and the
+// value of that key is returned, otherwise the whole secret string is returned.
+func (p *AWSProvider) Fetch(ctx context.Context, path, key string) (string, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": path})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+
+	p.sign(req, payload, time.Now().UTC())
+
+	res, err := p.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var awsError struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+
+		json.NewDecoder(res.Body).Decode(&awsError)
+
+		if strings.HasSuffix(awsError.Type, "ResourceNotFoundException") {
+			return "", fmt.Errorf("%w: awssm:%s", ErrSecretNotFound, path)
+		}
+
+		return "", fmt.Errorf("secrets manager returned unexpected status %d for %s: %s", res.StatusCode, path, awsError.Message)
+	}
+
+	var body struct {
+		SecretString string `json:"SecretString"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		return "", err
+	}
+
+	if key == "" {
+		return body.SecretString, nil
+	}
+
+	var fields map[string]interface{}
+
+	err = json.Unmarshal([]byte(body.SecretString), &fields)
+	if err != nil {
+		return "", fmt.Errorf("%w: awssm:%s is not a JSON object", ErrInvalidReference, path)
+	}
+
+	value, found := fields[key]
+	if !found {
+		return "", fmt.Errorf("%w: awssm:%s#%s", ErrSecretNotFound, path, key)
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+// Add the AWS Signature Version 4 headers to the request. See
+// https://docs.aws.amazon.com/general/latest/gr/sigv4_signing.html for the details of
+// each step.
+func (p *AWSProvider) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if p.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.SessionToken)
+	}
+
+	// Build the canonical list of headers. The header names must be lowercase and sorted,
+	// which the fixed order below already is.
+	headers := [][2]string{
+		{"content-type", req.Header.Get("Content-Type")},
+		{"host", req.URL.Host},
+		{"x-amz-date", amzDate},
+	}
+	if p.SessionToken != "" {
+		headers = append(headers, [2]string{"x-amz-security-token", p.SessionToken})
+	}
+	headers = append(headers, [2]string{"x-amz-target", req.Header.Get("X-Amz-Target")})
+
+	var canonicalHeaders strings.Builder
+	signedHeaders := make([]string, 0, len(headers))
+
+	for _, header := range headers {
+		canonicalHeaders.WriteString(header[0] + ":" + strings.TrimSpace(header[1]) + "\n")
+		signedHeaders = append(signedHeaders, header[0])
+	}
+
+	payloadHash := sha256.Sum256(payload)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		"",
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/secretsmanager/aws4_request", date, p.Region)
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	// Derive the signing key from the secret access key, then sign the string
+	signingKey := hmacSHA256([]byte("AWS4"+p.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, p.Region)
+	signingKey = hmacSHA256(signingKey, "secretsmanager")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.AccessKeyID,
+		scope,
+		strings.Join(signedHeaders, ";"),
+		signature,
+	))
+}
+
+// Return the HMAC-SHA256 of data using the given key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,247 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Define the errors that can be returned when resolving a secret reference
+var (
+	ErrUnknownBackend       = errors.New("unknown secrets backend")
+	ErrBackendNotConfigured = errors.New("secrets backend not configured")
+	ErrInvalidReference     = errors.New("invalid secret reference")
+	ErrSecretNotFound       = errors.New("secret not found")
+)
+
+// The names of the backends config values can refer to. Values starting with one of
+// them are always treated as references, even if the backend isn't registered, so that
+// a missing backend setting fails startup instead of the reference being used as the
+// value itself.
+var Backends = []string{"vault", "awssm"}
+
+// A Provider fetches the value of a secret from a specific backend. The path is the
+// location of the secret in the backend and the key (which may be empty) selects a
+// single field from it.
+type Provider interface {
+	Fetch(ctx context.Context, path, key string) (string, error)
+}
+
+// Define a Reference struct to hold the parsed form of a config value such as
+// "vault:kv/greenlight#db-dsn" or "awssm:greenlight/smtp-password"
+type Reference struct {
+	Backend string
+	Path    string
+	Key     string
+}
+
+// Return the reference in the same format it was written in
+func (ref Reference) String() string {
+	if ref.Key == "" {
+		return ref.Backend + ":" + ref.Path
+	}
+
+	return ref.Backend + ":" + ref.Path + "#" + ref.Key
+}
+
+// Define a Resolver type which turns secret references into their values. Resolved
+// values are cached until they are refreshed by Rotate(), so that each secret is only
+// fetched once at startup, and a background goroutine can optionally refresh them
+// periodically. The new values only reach the application through Watch().
+type Resolver struct {
+	providers map[string]Provider
+	timeout   time.Duration
+	mutex     sync.Mutex
+	cache     map[Reference]string
+	watchers  map[Reference][]func(string)
+}
+
+// Return a new Resolver without any providers
+func New() *Resolver {
+	return &Resolver{
+		providers: make(map[string]Provider),
+		timeout:   5 * time.Second,
+		cache:     make(map[Reference]string),
+		watchers:  make(map[Reference][]func(string)),
+	}
+}
+
+// Register a Provider under the given backend name (e.g. "vault" or "awssm")
+func (r *Resolver) Register(backend string, provider Provider) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.providers[backend] = provider
+}
+
+// IsReference reports whether a config value is written as a secret reference, either
+// for one of the known Backends or one which has been registered
+func (r *Resolver) IsReference(value string) bool {
+	backend, _, found := strings.Cut(value, ":")
+	if !found {
+		return false
+	}
+
+	for _, known := range Backends {
+		if backend == known {
+			return true
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, exists := r.providers[backend]
+
+	return exists
+}
+
+// Parse a config value in the format "<backend>:<path>[#<key>]" into a Reference
+func Parse(value string) (Reference, error) {
+	backend, rest, found := strings.Cut(value, ":")
+	if !found || backend == "" {
+		return Reference{}, fmt.Errorf("%w: %q", ErrInvalidReference, value)
+	}
+
+	path, key, _ := strings.Cut(rest, "#")
+	if path == "" {
+		return Reference{}, fmt.Errorf("%w: %q", ErrInvalidReference, value)
+	}
+
+	return Reference{Backend: backend, Path: path, Key: key}, nil
+}
+
+// Resolve returns the secret value for a config value written as a reference. Values
+// which aren't references are returned unchanged, so plain config values can be passed
+// through the resolver too. A reference to a known backend which hasn't been
+// registered returns ErrBackendNotConfigured.
+func (r *Resolver) Resolve(value string) (string, error) {
+	if !r.IsReference(value) {
+		return value, nil
+	}
+
+	ref, err := Parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	// Return the cached value if the secret has already been fetched
+	r.mutex.Lock()
+	cached, found := r.cache[ref]
+	r.mutex.Unlock()
+
+	if found {
+		return cached, nil
+	}
+
+	secret, err := r.fetch(ref)
+	if err != nil {
+		return "", err
+	}
+
+	r.mutex.Lock()
+	r.cache[ref] = secret
+	r.mutex.Unlock()
+
+	return secret, nil
+}
+
+// Watch registers a function which is called with the new value whenever the secret
+// referenced by value changes during a rotation. Values which aren't references are
+// ignored.
+func (r *Resolver) Watch(value string, fn func(string)) {
+	if !r.IsReference(value) {
+		return
+	}
+
+	ref, err := Parse(value)
+	if err != nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.watchers[ref] = append(r.watchers[ref], fn)
+}
+
+// Rotate re-fetches every cached secret and notifies the watchers of any secret whose
+// value has changed. Errors don't stop the other secrets from being refreshed; the
+// previously cached value is kept and the error messages are returned together.
+func (r *Resolver) Rotate() error {
+	r.mutex.Lock()
+	refs := make([]Reference, 0, len(r.cache))
+	for ref := range r.cache {
+		refs = append(refs, ref)
+	}
+	r.mutex.Unlock()
+
+	var failures []string
+
+	for _, ref := range refs {
+		secret, err := r.fetch(ref)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", ref, err))
+			continue
+		}
+
+		r.mutex.Lock()
+		changed := r.cache[ref] != secret
+		r.cache[ref] = secret
+		watchers := r.watchers[ref]
+		r.mutex.Unlock()
+
+		if changed {
+			for _, fn := range watchers {
+				fn(secret)
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("unable to rotate secrets: %s", strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+// StartRotation launches a background goroutine which calls Rotate() once every
+// interval for the lifetime of the application. Any errors are passed to onError.
+func (r *Resolver) StartRotation(interval time.Duration, onError func(error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := r.Rotate(); err != nil {
+				onError(err)
+			}
+		}
+	}()
+}
+
+// Fetch the value for a reference from its provider, using a context with a timeout
+// so that an unreachable backend can't block startup indefinitely
+func (r *Resolver) fetch(ref Reference) (string, error) {
+	r.mutex.Lock()
+	provider, found := r.providers[ref.Backend]
+	r.mutex.Unlock()
+
+	if !found {
+		for _, known := range Backends {
+			if ref.Backend == known {
+				return "", fmt.Errorf("%w: %q is needed for %s", ErrBackendNotConfigured, ref.Backend, ref)
+			}
+		}
+
+		return "", fmt.Errorf("%w: %q", ErrUnknownBackend, ref.Backend)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return provider.Fetch(ctx, ref.Path, ref.Key)
+}
@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Define a VaultProvider type which reads secrets from a HashiCorp Vault KV version 2
+// secrets engine using Vault's HTTP API
+type VaultProvider struct {
+	Address string
+	Token   string
+	Client  *http.Client
+}
+
+// Return a new VaultProvider for the Vault server at the given address
+func NewVaultProvider(address, token string) *VaultProvider {
+	return &VaultProvider{
+		Address: strings.TrimSuffix(address, "/"),
+		Token:   token,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Fetch reads the secret at the given path, which is expected to be in the format
+// "<mount>/<secret path>" (e.g. "kv/greenlight"), and returns the value of the given key
+func (p *VaultProvider) Fetch(ctx context.Context, path, key string) (string, error) {
+	mount, secretPath, found := strings.Cut(path, "/")
+	if !found || mount == "" || secretPath == "" || key == "" {
+		return "", fmt.Errorf("%w: vault references must be in the format vault:<mount>/<path>#<key>", ErrInvalidReference)
+	}
+
+	// KV version 2 engines expose the latest version of a secret under the "data" prefix
+	url := fmt.Sprintf("%s/v1/%s/data/%s", p.Address, mount, secretPath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("X-Vault-Token", p.Token)
+
+	res, err := p.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%w: vault:%s", ErrSecretNotFound, path)
+	case res.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("vault returned unexpected status %d for %s", res.StatusCode, path)
+	}
+
+	// The secret's key/value pairs are nested two levels deep in the response body
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		return "", err
+	}
+
+	value, found := body.Data.Data[key]
+	if !found {
+		return "", fmt.Errorf("%w: vault:%s#%s", ErrSecretNotFound, path, key)
+	}
+
+	return fmt.Sprint(value), nil
+}
@@ -0,0 +1,184 @@
+// Package tracing propagates W3C Trace Context (https://www.w3.org/TR/trace-context/)
+// headers, so that a request can be followed through the logs of the API and of the
+// services it calls. Spans aren't recorded or exported anywhere: the trace ID of an
+// incoming traceparent header is kept, each request gets its own span ID, and both are
+// passed on in the traceparent header of outgoing requests.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The names of the Trace Context headers
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// The longest tracestate header passed on. The specification allows up to 32 entries
+// of up to 256 characters each, but vendors only ever send a few short ones.
+const maxTraceStateLength = 512
+
+// Define a contextKey type so that the key can't collide with keys from other packages
+type contextKey struct{}
+
+// Define a Trace struct holding the trace context of a unit of work, such as a request
+// or a job. SpanID identifies the unit of work itself, and is sent as the parent ID to
+// the services it calls.
+type Trace struct {
+	TraceID string
+	SpanID  string
+	Flags   byte
+	State   string
+}
+
+// Return a new trace with random trace and span IDs, for work which wasn't started by
+// a traced request
+func New() (Trace, error) {
+	traceID, err := randomHex(16)
+	if err != nil {
+		return Trace{}, err
+	}
+
+	spanID, err := randomHex(8)
+	if err != nil {
+		return Trace{}, err
+	}
+
+	return Trace{TraceID: traceID, SpanID: spanID}, nil
+}
+
+// Parse the values of the traceparent and tracestate headers, reporting whether the
+// traceparent is valid. The tracestate is dropped if it is too long, as the
+// specification allows.
+func Parse(traceparent, tracestate string) (Trace, bool) {
+	// Later versions may append fields, but must keep the first four as they are
+	if len(traceparent) < 55 || (len(traceparent) > 55 && traceparent[55] != '-') {
+		return Trace{}, false
+	}
+
+	version, traceID, spanID, flags := traceparent[0:2], traceparent[3:35], traceparent[36:52], traceparent[53:55]
+
+	if traceparent[2] != '-' || traceparent[35] != '-' || traceparent[52] != '-' {
+		return Trace{}, false
+	}
+
+	if !isHex(version) || version == "ff" || (version == "00" && len(traceparent) != 55) {
+		return Trace{}, false
+	}
+
+	if !isHex(traceID) || isZero(traceID) || !isHex(spanID) || isZero(spanID) || !isHex(flags) {
+		return Trace{}, false
+	}
+
+	decoded, _ := hex.DecodeString(flags)
+
+	trace := Trace{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Flags:   decoded[0],
+	}
+
+	if len(tracestate) <= maxTraceStateLength {
+		trace.State = tracestate
+	}
+
+	return trace, true
+}
+
+// Return a trace for work carried out on behalf of this one, with the same trace ID,
+// flags and state but a new span ID
+func (t Trace) Child() (Trace, error) {
+	spanID, err := randomHex(8)
+	if err != nil {
+		return Trace{}, err
+	}
+
+	t.SpanID = spanID
+
+	return t, nil
+}
+
+// Return the value of the traceparent header for the trace, which is always written in
+// version 00 of the format
+func (t Trace) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", t.TraceID, t.SpanID, t.Flags&0x01)
+}
+
+// Set the traceparent and tracestate headers of an outgoing request
+func (t Trace) Inject(header http.Header) {
+	header.Set(TraceParentHeader, t.TraceParent())
+
+	if t.State != "" {
+		header.Set(TraceStateHeader, t.State)
+	}
+}
+
+// Return a copy of the context carrying the given trace
+func NewContext(ctx context.Context, trace Trace) context.Context {
+	return context.WithValue(ctx, contextKey{}, trace)
+}
+
+// Return the trace carried by the context, reporting whether there is one
+func FromContext(ctx context.Context) (Trace, bool) {
+	trace, ok := ctx.Value(contextKey{}).(Trace)
+
+	return trace, ok
+}
+
+// Define a Transport type which adds the trace carried by a request's context to the
+// request's headers, unless the caller has set them itself
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	trace, ok := FromContext(req.Context())
+	if !ok || req.Header.Get(TraceParentHeader) != "" {
+		return base.RoundTrip(req)
+	}
+
+	// A RoundTripper mustn't modify the request it was given
+	req = req.Clone(req.Context())
+	trace.Inject(req.Header)
+
+	return base.RoundTrip(req)
+}
+
+// Return n random bytes encoded as lowercase hex
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Report whether s only holds lowercase hex digits, as the specification requires
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Report whether s only holds zeros, which is an invalid trace or span ID
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
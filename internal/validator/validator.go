@@ -7,14 +7,73 @@ import (
 // Declare a regular expression for sanity checking the format of email addresses
 var EmailRegex = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
-// Define a new Validator type which contains a map of validation errors
+// The machine-readable codes for failed checks, which clients can use to localize the
+// messages or handle particular failures
+const (
+	CodeRequired      = "required"       // The value is missing
+	CodeTooShort      = "too_short"      // Params: min, the minimum length in bytes
+	CodeTooLong       = "too_long"       // Params: max, the maximum length in bytes
+	CodeWrongLength   = "wrong_length"   // Params: length, the required length in bytes
+	CodeTooSmall      = "too_small"      // Params: min, the smallest allowed value
+	CodeTooLarge      = "too_large"      // Params: max, the largest allowed value
+	CodeOutOfRange    = "out_of_range"   // Params: min and max, the allowed range
+	CodeTooFew        = "too_few"        // Params: min, the fewest allowed items
+	CodeTooMany       = "too_many"       // Params: max, the most allowed items
+	CodeDuplicate     = "duplicate"      // The list contains a value more than once
+	CodeInvalidFormat = "invalid_format" // Params: format, a description of the format
+	CodeNotOneOf      = "not_one_of"     // Params: allowed, the allowed values
+	CodeNotAllowed    = "not_allowed"    // Params: value, when a particular value isn't allowed
+	CodeTooEarly      = "too_early"      // Params: min, the earliest allowed time, if fixed
+	CodeTooLate       = "too_late"       // Params: max, the latest allowed time, if fixed
+	CodeAlreadyExists = "already_exists" // A record with the value already exists
+	CodeNotFound      = "not_found"      // The value refers to a record which doesn't exist
+	CodeConflict      = "conflict"       // The value conflicts with another record
+	CodeInvalidState  = "invalid_state"  // The record isn't in a state allowing the request
+	CodeInvalid       = "invalid"        // The value is invalid, unknown or has expired
+)
+
+// Define a Params type holding the parameters of a failed check, such as the maximum
+// length of a field
+type Params map[string]interface{}
+
+// Define a FieldError struct holding the code, message and parameters of a failed check
+type FieldError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Params  Params `json:"params,omitempty"`
+}
+
+// Define a RuleMode type for how a validation rule which is being rolled out is applied.
+// New, stricter rules can be run in shadow mode first, to find out how many requests
+// they would reject before they are enforced.
+type RuleMode int
+
+const (
+	RuleOff     RuleMode = iota // The rule isn't evaluated
+	RuleShadow                  // Failures are recorded in Shadowed, but don't make the validator invalid
+	RuleEnforce                 // Failures are added to the errors like any other check
+)
+
+// Define a ShadowFailure struct holding a failed check of a rule run in shadow mode
+type ShadowFailure struct {
+	Rule  string
+	Key   string
+	Error FieldError
+}
+
+// Define a new Validator type which contains a map of validation error messages, along
+// with the codes and parameters of the failed checks. Modes holds the modes of the
+// rules being rolled out, which are off unless listed.
 type Validator struct {
-	Errors map[string]string
+	Errors   map[string]string
+	Fields   map[string]FieldError
+	Modes    map[string]RuleMode
+	Shadowed []ShadowFailure
 }
 
-// New is a helper which creates a new Validator instance with an empty errors map
+// New is a helper which creates a new Validator instance with empty errors maps
 func New() *Validator {
-	return &Validator{Errors: make(map[string]string)}
+	return &Validator{Errors: make(map[string]string), Fields: make(map[string]FieldError)}
 }
 
 // Valid returns true if the errors map doesn't contain any entries
@@ -22,17 +81,45 @@ func (v *Validator) Valid() bool {
 	return len(v.Errors) == 0
 }
 
-// AddError adds an error message to the map (so long as no entry already exists for the given key)
-func (v *Validator) AddError(key, message string) {
+// AddError adds an error message with its code and optional parameters to the maps (so
+// long as no entry already exists for the given key)
+func (v *Validator) AddError(key, message, code string, params ...Params) {
 	if _, exists := v.Errors[key]; !exists {
 		v.Errors[key] = message
+
+		fieldError := FieldError{Code: code, Message: message}
+		if len(params) > 0 {
+			fieldError.Params = params[0]
+		}
+
+		v.Fields[key] = fieldError
 	}
 }
 
-// Check adds an error message to the map only if a validation check is not 'ok'
-func (v *Validator) Check(ok bool, key, message string) {
+// Check adds an error message with its code and optional parameters to the maps only if
+// a validation check is not 'ok'
+func (v *Validator) Check(ok bool, key, message, code string, params ...Params) {
 	if !ok {
-		v.AddError(key, message)
+		v.AddError(key, message, code, params...)
+	}
+}
+
+// CheckRule is like Check, for a check belonging to a rule which is being rolled out.
+// Depending on the rule's mode, the check is skipped, its failure is only recorded in
+// Shadowed, or it is enforced.
+func (v *Validator) CheckRule(rule string, ok bool, key, message, code string, params ...Params) {
+	switch v.Modes[rule] {
+	case RuleShadow:
+		if !ok {
+			fieldError := FieldError{Code: code, Message: message}
+			if len(params) > 0 {
+				fieldError.Params = params[0]
+			}
+
+			v.Shadowed = append(v.Shadowed, ShadowFailure{Rule: rule, Key: key, Error: fieldError})
+		}
+	case RuleEnforce:
+		v.Check(ok, key, message, code, params...)
 	}
 }
 
@@ -52,9 +139,9 @@ func Matches(value string, regex *regexp.Regexp) bool {
 	return regex.MatchString(value)
 }
 
-// `Unique` returns true if all string values in a slice are unique
-func Unique(values []string) bool {
-	uniqueValues := make(map[string]bool)
+// `Unique` returns true if all values in a slice are unique
+func Unique[T comparable](values []T) bool {
+	uniqueValues := make(map[T]bool)
 
 	for _, value := range values {
 		uniqueValues[value] = true
@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code = 'debug:read';
\ No newline at end of file
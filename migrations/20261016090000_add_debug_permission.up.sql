@@ -0,0 +1,2 @@
+INSERT INTO permissions (code)
+VALUES ('debug:read');
\ No newline at end of file
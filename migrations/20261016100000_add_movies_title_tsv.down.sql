@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS movies_title_idx ON movies USING GIN (to_tsvector('simple', title));
+
+DROP INDEX IF EXISTS movies_title_tsv_idx;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS title_tsv;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS title_tsv tsvector GENERATED ALWAYS AS (to_tsvector('simple', title)) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_title_tsv_idx ON movies USING GIN (title_tsv);
+
+DROP INDEX IF EXISTS movies_title_idx;
\ No newline at end of file
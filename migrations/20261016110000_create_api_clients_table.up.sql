@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS api_clients (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    key text UNIQUE NOT NULL,
+    secret bytea NOT NULL,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    active boolean NOT NULL DEFAULT true
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code = 'bans:write';
\ No newline at end of file
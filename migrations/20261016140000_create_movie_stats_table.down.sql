@@ -0,0 +1 @@
+DROP TABLE IF EXISTS movie_stats;
\ No newline at end of file
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS movie_stats (
+    movie_id bigint PRIMARY KEY REFERENCES movies ON DELETE CASCADE,
+    views bigint NOT NULL DEFAULT 0,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
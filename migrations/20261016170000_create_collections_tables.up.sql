@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS collections (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    description text NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+-- The constraints are deferred so that a collection's members can be replaced by
+-- deleting and reinserting them in a single statement
+CREATE TABLE IF NOT EXISTS collection_movies (
+    collection_id bigint NOT NULL REFERENCES collections ON DELETE CASCADE,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    position integer NOT NULL,
+    CONSTRAINT collection_movies_pkey PRIMARY KEY (collection_id, movie_id) DEFERRABLE INITIALLY DEFERRED,
+    CONSTRAINT collection_movies_movie_id_key UNIQUE (movie_id) DEFERRABLE INITIALLY DEFERRED
+);
\ No newline at end of file
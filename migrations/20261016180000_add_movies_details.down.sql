@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS movies_certifications_idx;
+DROP INDEX IF EXISTS movies_production_countries_idx;
+DROP INDEX IF EXISTS movies_original_language_idx;
+DROP INDEX IF EXISTS movies_synopsis_tsv_idx;
+
+ALTER TABLE movies
+    DROP COLUMN IF EXISTS synopsis_tsv,
+    DROP COLUMN IF EXISTS certifications,
+    DROP COLUMN IF EXISTS production_countries,
+    DROP COLUMN IF EXISTS original_language,
+    DROP COLUMN IF EXISTS synopsis;
\ No newline at end of file
@@ -0,0 +1,12 @@
+ALTER TABLE movies
+    ADD COLUMN IF NOT EXISTS synopsis text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS original_language text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS production_countries text[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS certifications jsonb NOT NULL DEFAULT '{}';
+
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS synopsis_tsv tsvector GENERATED ALWAYS AS (to_tsvector('english', synopsis)) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_synopsis_tsv_idx ON movies USING GIN (synopsis_tsv);
+CREATE INDEX IF NOT EXISTS movies_original_language_idx ON movies (original_language);
+CREATE INDEX IF NOT EXISTS movies_production_countries_idx ON movies USING GIN (production_countries);
+CREATE INDEX IF NOT EXISTS movies_certifications_idx ON movies USING GIN (certifications jsonb_path_ops);
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS movie_releases (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    country text NOT NULL,
+    type text NOT NULL,
+    release_date date NOT NULL,
+    note text NOT NULL DEFAULT '',
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT movie_releases_movie_id_country_type_key UNIQUE (movie_id, country, type)
+);
+
+CREATE INDEX IF NOT EXISTS movie_releases_release_date_idx ON movie_releases (release_date, movie_id);
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS movie_availability (
+    movie_id bigint PRIMARY KEY REFERENCES movies ON DELETE CASCADE,
+    provider_movie_id bigint,
+    countries jsonb NOT NULL DEFAULT '{}',
+    refreshed_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS movie_availability_refreshed_at_idx ON movie_availability (refreshed_at);
\ No newline at end of file
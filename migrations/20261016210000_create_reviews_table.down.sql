@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code = 'moderation:write';
+
+DROP TABLE IF EXISTS reviews;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS review_reports;
\ No newline at end of file
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS review_reports (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    review_id bigint NOT NULL REFERENCES reviews ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    reason text NOT NULL,
+    comment text NOT NULL DEFAULT '',
+    status text NOT NULL DEFAULT 'open',
+    resolved_by bigint REFERENCES users ON DELETE SET NULL,
+    resolved_at timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT review_reports_reason_check CHECK (reason IN ('spam', 'harassment', 'hate_speech', 'spoilers', 'off_topic', 'other')),
+    CONSTRAINT review_reports_status_check CHECK (status IN ('open', 'upheld', 'dismissed')),
+    CONSTRAINT review_reports_review_id_user_id_key UNIQUE (review_id, user_id)
+);
+
+-- Moderators triage the open reports, and the open reports on a review are counted
+-- when deciding whether to hide it
+CREATE INDEX IF NOT EXISTS review_reports_status_idx ON review_reports (status, id);
+CREATE INDEX IF NOT EXISTS review_reports_review_id_status_idx ON review_reports (review_id, status);
\ No newline at end of file
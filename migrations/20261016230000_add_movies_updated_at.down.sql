@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS movies_created_at_idx;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS updated_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+INSERT INTO permissions (code)
+VALUES ('admin:read');
\ No newline at end of file
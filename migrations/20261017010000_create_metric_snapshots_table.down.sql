@@ -0,0 +1 @@
+DROP TABLE IF EXISTS metric_snapshots;
\ No newline at end of file
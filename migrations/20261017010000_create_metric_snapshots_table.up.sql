@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS metric_snapshots (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    label text NOT NULL DEFAULT '',
+    instance text NOT NULL,
+    metrics jsonb NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code = 'metrics:write';
\ No newline at end of file
@@ -0,0 +1,2 @@
+INSERT INTO permissions (code)
+VALUES ('metrics:write');
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE tokens DROP COLUMN permissions;
\ No newline at end of file
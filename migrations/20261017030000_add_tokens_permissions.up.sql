@@ -0,0 +1 @@
+ALTER TABLE tokens ADD COLUMN permissions text[];
\ No newline at end of file
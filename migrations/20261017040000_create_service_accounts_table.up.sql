@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS service_accounts (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    owner_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    name text NOT NULL,
+    key_hash bytea NOT NULL UNIQUE,
+    key_prefix text NOT NULL,
+    permissions text[] NOT NULL,
+    active boolean NOT NULL DEFAULT true,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS service_accounts_owner_id_idx ON service_accounts (owner_id);
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS invitations (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    email citext NOT NULL,
+    invited_by bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    hash bytea NOT NULL UNIQUE,
+    expiry timestamp(0) with time zone NOT NULL,
+    accepted_at timestamp(0) with time zone
+);
\ No newline at end of file
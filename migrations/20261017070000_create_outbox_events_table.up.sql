@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    topic text NOT NULL,
+    key text NOT NULL,
+    payload jsonb NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS movies_metadata_idx;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS metadata;
\ No newline at end of file
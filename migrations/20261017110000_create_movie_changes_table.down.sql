@@ -0,0 +1 @@
+DROP TABLE IF EXISTS movie_changes;
\ No newline at end of file
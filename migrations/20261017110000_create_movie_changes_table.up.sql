@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS movie_changes (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    base_version integer NOT NULL,
+    changes jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    review_reason text NOT NULL DEFAULT '',
+    reviewed_by bigint REFERENCES users ON DELETE SET NULL,
+    reviewed_at timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT movie_changes_status_check CHECK (status IN ('pending', 'approved', 'rejected'))
+);
+
+-- Editors work through the changes proposed for each movie in each status
+CREATE INDEX IF NOT EXISTS movie_changes_movie_id_status_idx ON movie_changes (movie_id, status, id);
\ No newline at end of file
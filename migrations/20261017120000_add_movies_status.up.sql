@@ -0,0 +1,7 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'published';
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+
+ALTER TABLE movies ADD CONSTRAINT movies_status_check CHECK (status IN ('draft', 'scheduled', 'published'));
+ALTER TABLE movies ADD CONSTRAINT movies_publish_at_check CHECK (status <> 'scheduled' OR publish_at IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS movies_publish_at_idx ON movies (publish_at) WHERE status = 'scheduled';
\ No newline at end of file
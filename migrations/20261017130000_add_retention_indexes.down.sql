@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS movie_changes_reviewed_at_idx;
+DROP INDEX IF EXISTS notifications_read_at_idx;
+DROP INDEX IF EXISTS tokens_expiry_idx;
\ No newline at end of file
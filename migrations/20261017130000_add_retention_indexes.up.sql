@@ -0,0 +1,4 @@
+-- Old rows are pruned in batches by the data retention job
+CREATE INDEX IF NOT EXISTS tokens_expiry_idx ON tokens (expiry);
+CREATE INDEX IF NOT EXISTS notifications_read_at_idx ON notifications (read_at) WHERE read_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS movie_changes_reviewed_at_idx ON movie_changes (reviewed_at) WHERE reviewed_at IS NOT NULL;
\ No newline at end of file
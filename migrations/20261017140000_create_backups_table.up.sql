@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS backups (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL UNIQUE,
+    status text NOT NULL DEFAULT 'running',
+    size bigint NOT NULL DEFAULT 0,
+    error text NOT NULL DEFAULT '',
+    finished_at timestamp(0) with time zone,
+    CONSTRAINT backups_status_check CHECK (status IN ('running', 'completed', 'failed'))
+);
@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code = 'backups:write';
\ No newline at end of file
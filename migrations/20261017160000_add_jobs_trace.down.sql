@@ -0,0 +1,2 @@
+ALTER TABLE jobs DROP COLUMN IF EXISTS tracestate;
+ALTER TABLE jobs DROP COLUMN IF EXISTS traceparent;
\ No newline at end of file
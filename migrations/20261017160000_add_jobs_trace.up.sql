@@ -0,0 +1,2 @@
+ALTER TABLE jobs ADD COLUMN traceparent text NOT NULL DEFAULT '';
+ALTER TABLE jobs ADD COLUMN tracestate text NOT NULL DEFAULT '';
\ No newline at end of file
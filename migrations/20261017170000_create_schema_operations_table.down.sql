@@ -0,0 +1 @@
+DROP TABLE IF EXISTS schema_operations;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS schema_operations (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    status text NOT NULL DEFAULT 'queued',
+    progress bigint NOT NULL DEFAULT 0,
+    total bigint NOT NULL DEFAULT 0,
+    rows_updated bigint NOT NULL DEFAULT 0,
+    error text NOT NULL DEFAULT '',
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    finished_at timestamp(0) with time zone,
+    CONSTRAINT schema_operations_status_check CHECK (status IN ('queued', 'running', 'completed', 'failed'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS schema_operations_active_name_idx ON schema_operations (name) WHERE status IN ('queued', 'running');
@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code = 'operations:write';
\ No newline at end of file
@@ -0,0 +1,2 @@
+INSERT INTO permissions (code)
+VALUES ('operations:write');
\ No newline at end of file
@@ -0,0 +1 @@
+DELETE FROM permissions WHERE code = '*' OR code LIKE '%:*';
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- Wildcard permissions grant every permission below them, so that admins can be given
+-- a whole area (or everything, with '*') without listing each permission
+INSERT INTO permissions (code)
+VALUES
+    ('*'),
+    ('movies:*'),
+    ('debug:*'),
+    ('bans:*'),
+    ('moderation:*'),
+    ('admin:*'),
+    ('metrics:*'),
+    ('invitations:*'),
+    ('backups:*'),
+    ('operations:*');
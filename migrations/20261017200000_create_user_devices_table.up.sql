@@ -0,0 +1,10 @@
+-- The devices users have signed in from, so that they can be told when they sign in
+-- from a new one. Devices are identified by a hash of their user agent and IP address.
+CREATE TABLE IF NOT EXISTS user_devices (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    fingerprint bytea NOT NULL,
+    user_agent text NOT NULL,
+    first_seen timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_seen timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, fingerprint)
+);
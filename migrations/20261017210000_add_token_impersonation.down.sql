@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code = 'users:impersonate';
+
+ALTER TABLE tokens DROP COLUMN IF EXISTS impersonator_id;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- Impersonation tokens let support staff act as a user, recording who they are
+ALTER TABLE tokens ADD COLUMN impersonator_id bigint REFERENCES users ON DELETE CASCADE;
+
+INSERT INTO permissions (code)
+VALUES ('users:impersonate');
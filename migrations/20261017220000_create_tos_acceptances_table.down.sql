@@ -0,0 +1 @@
+DROP TABLE IF EXISTS tos_acceptances;
\ No newline at end of file
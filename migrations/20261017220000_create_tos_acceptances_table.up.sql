@@ -0,0 +1,7 @@
+-- The versions of the terms of service each user has accepted, and when
+CREATE TABLE IF NOT EXISTS tos_acceptances (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    version text NOT NULL,
+    accepted_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, version)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_status_check;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_status_updated_at;
+ALTER TABLE users DROP COLUMN IF EXISTS email_status;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- Whether emails to the user's address are being delivered, as reported by the email
+-- provider. Emails aren't sent to addresses which have bounced or complained.
+ALTER TABLE users ADD COLUMN email_status text NOT NULL DEFAULT 'ok';
+ALTER TABLE users ADD COLUMN email_status_updated_at timestamp(0) with time zone;
+
+ALTER TABLE users ADD CONSTRAINT users_email_status_check CHECK (email_status IN ('ok', 'soft_bounce', 'bounced', 'complained'));
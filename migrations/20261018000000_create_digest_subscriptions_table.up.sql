@@ -0,0 +1,11 @@
+-- Each user's subscription to the digest emails of new movies in their favorite genres
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    frequency text NOT NULL CHECK (frequency IN ('daily', 'weekly')),
+    genres text[] NOT NULL,
+    last_movie_id bigint NOT NULL,
+    next_digest_at timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS digest_subscriptions_next_digest_at_idx ON digest_subscriptions (next_digest_at);